@@ -3,24 +3,55 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/eargollo/ditto/internal/agent"
+	"github.com/eargollo/ditto/internal/bench"
 	"github.com/eargollo/ditto/internal/config"
 	"github.com/eargollo/ditto/internal/db"
 	"github.com/eargollo/ditto/internal/hash"
-	"github.com/eargollo/ditto/internal/server"
+	"github.com/eargollo/ditto/internal/ioprio"
+	"github.com/eargollo/ditto/internal/limits"
+	"github.com/eargollo/ditto/internal/manifest"
+	"github.com/eargollo/ditto/internal/notify"
+	"github.com/eargollo/ditto/internal/offline"
+	"github.com/eargollo/ditto/internal/photoexport"
+	"github.com/eargollo/ditto/internal/remote"
+	"github.com/eargollo/ditto/internal/rootlist"
 	"github.com/eargollo/ditto/internal/scan"
+	"github.com/eargollo/ditto/internal/server"
+	"github.com/eargollo/ditto/internal/similarity"
 )
 
 func main() {
+	// verify-manifest works offline: it needs only the file and the signing key, not a database.
+	if len(os.Args) >= 3 && os.Args[1] == "verify-manifest" {
+		verifyManifest(os.Args[2])
+		return
+	}
+	// agent runs on machines without a database and ships its catalog to a coordinator.
+	if len(os.Args) >= 2 && os.Args[1] == "agent" {
+		runAgent(context.Background(), os.Args[2:])
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("config: %v", err)
 	}
 
+	limits.SetMaxOpenFiles(cfg.MaxOpenFiles())
+	limits.SetMaxCPUPercent(cfg.MaxCPUPercent())
+
 	dataDir := cfg.DataDir()
 	if err := os.MkdirAll(dataDir, 0750); err != nil {
 		log.Fatalf("create data dir %q: %v", dataDir, err)
@@ -31,21 +62,101 @@ func main() {
 		log.Fatalf("open database: %v", err)
 	}
 	defer database.Close()
+	db.ConfigurePool(database, db.PoolSettings{
+		MaxOpenConns: cfg.DBMaxOpenConns(),
+		MaxIdleConns: cfg.DBMaxIdleConns(),
+		MaxLifetime:  cfg.DBConnMaxLifetime(),
+	})
 
+	// migrate runs before the automatic upgrade so "down" and "status" see the schema as it is.
+	if len(os.Args) >= 2 && os.Args[1] == "migrate" {
+		runMigrate(context.Background(), database, os.Args[2:])
+		return
+	}
 	if err := db.MigratePostgres(database); err != nil {
 		log.Fatalf("migrate: %v", err)
 	}
 
 	if len(os.Args) >= 3 && os.Args[1] == "scan" {
-		runScan(context.Background(), database, os.Args[2])
+		runScan(context.Background(), database, cfg, os.Args[2], modeScan)
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "verify" {
+		runScan(context.Background(), database, cfg, os.Args[2], modeVerify)
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "catalog" {
+		runScan(context.Background(), database, cfg, os.Args[2], modeCatalog)
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "pause" {
+		pauseScan(context.Background(), database, os.Args[2])
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "resume" {
+		resumeScan(context.Background(), database, cfg, os.Args[2])
 		return
 	}
 
-	// Single DB; Postgres handles concurrent readers and writers.
+	if len(os.Args) >= 2 && os.Args[1] == "bench" {
+		runBench(context.Background(), database, os.Args[2:])
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "lock" {
+		lockScan(context.Background(), database, os.Args[2])
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "verify-scan" {
+		verifyScan(context.Background(), database, os.Args[2])
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "export-manifest" {
+		exportManifest(context.Background(), database, cfg, os.Args[2])
+		return
+	}
+	if len(os.Args) >= 4 && os.Args[1] == "import-manifest" {
+		importManifest(context.Background(), database, os.Args[2], os.Args[3])
+		return
+	}
+	if len(os.Args) >= 4 && os.Args[1] == "import-photos" {
+		importPhotos(context.Background(), database, os.Args[2], os.Args[3])
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "scan-remote" {
+		scanRemote(context.Background(), database, os.Args[2:])
+		return
+	}
+	if len(os.Args) >= 3 && os.Args[1] == "import-roots" {
+		importRoots(context.Background(), database, os.Args[2])
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "maintain" {
+		runMaintain(context.Background(), database, os.Args[2:])
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "export-manifest-index" {
+		exportManifestIndex(context.Background(), database, cfg)
+		return
+	}
+
+	// Postgres handles concurrent readers and writers; a replica for the UI's reads is optional.
 	srv, err := server.NewServer(cfg, database)
 	if err != nil {
 		log.Fatalf("server: %v", err)
 	}
+	if url := cfg.DatabaseURLRead(); url != "" {
+		readDB, err := db.OpenPostgres(url)
+		if err != nil {
+			log.Fatalf("open read database: %v", err)
+		}
+		defer readDB.Close()
+		db.ConfigurePool(readDB, db.PoolSettings{
+			MaxOpenConns: cfg.DBMaxOpenConns(),
+			MaxIdleConns: cfg.DBMaxIdleConns(),
+			MaxLifetime:  cfg.DBConnMaxLifetime(),
+		})
+		srv.SetReadDB(readDB)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go func() {
@@ -60,19 +171,534 @@ func main() {
 	}
 }
 
-func runScan(ctx context.Context, database *sql.DB, rootPath string) {
+// scanMode is the kind of scan runScan runs.
+type scanMode int
+
+const (
+	modeScan    scanMode = iota
+	modeVerify           // then re-read the files unchanged since their last hash and report mismatches (bit rot)
+	modeCatalog          // catalog a removable drive (see offline.Catalog)
+)
+
+// runScan scans rootPath and hashes it. A verification scan then re-reads the files unchanged since their last
+// hash and reports mismatches (bit rot); a catalog records the drive it was taken from.
+func runScan(ctx context.Context, database *sql.DB, cfg *config.Config, rootPath string, mode scanMode) {
+	folderID, err := db.GetOrCreateFolderByPath(ctx, database, rootPath)
+	if err != nil {
+		log.Fatalf("folder: %v", err)
+	}
+	folder, err := db.GetFolder(ctx, database, folderID)
+	if err != nil {
+		log.Fatalf("folder: %v", err)
+	}
+	// A disabled root (a drive offline for a while) is skipped without failing, so scheduled runs keep working.
+	if folder.Disabled() {
+		log.Printf("Skipping %s: the scan root is disabled; enable it on the Scans page to scan it", rootPath)
+		return
+	}
+	// Offline media must be present (or mountable) so an unplugged drive does not record an empty scan.
+	detach, err := offline.Attach(ctx, cfg.MountHelper(), folder)
+	if err != nil {
+		log.Fatalf("scan: %v", err)
+	}
+	defer detach()
+	var catalogLabel, catalogUUID string
+	if mode == modeCatalog {
+		if catalogLabel, catalogUUID, err = offline.Catalog(ctx, database, folder); err != nil {
+			detach()
+			log.Fatalf("catalog: %v", err)
+		}
+	}
 	opts, err := scan.OptionsForRoot(rootPath)
 	if err != nil {
+		detach()
 		log.Fatalf("exclude file: %v", err)
 	}
+	opts.Symlinks = folder.Symlinks
+	opts.NetworkFS = folder.NetworkFS
+	opts.SameDevice = folder.OneFileSystem
+	opts.Archives = folder.Archives
+	opts.UnicodeForm = folder.UnicodeForm
+	opts.MaxDepth = folder.MaxDepth
+	opts.MaxFiles = folder.MaxFiles
+	if folder.LowPriority {
+		opts.Priority = ioprio.LowPriority
+	}
+	patterns, err := db.FolderExcludePatterns(ctx, database, folderID)
+	if err != nil {
+		detach()
+		log.Fatalf("exclude patterns: %v", err)
+	}
+	opts.ExcludePatterns = append(opts.ExcludePatterns, patterns...)
 	scanID, err := scan.RunScan(ctx, database, rootPath, opts)
 	if err != nil {
+		detach() // log.Fatalf skips deferred calls
 		log.Fatalf("scan: %v", err)
 	}
 	log.Printf("Scan complete: id=%d", scanID)
+	switch mode {
+	case modeVerify:
+		if err := db.SetScanVerify(ctx, database, scanID); err != nil {
+			detach()
+			log.Fatalf("verify: %v", err)
+		}
+	case modeCatalog:
+		if err := db.SetScanCatalog(ctx, database, scanID, catalogLabel, catalogUUID); err != nil {
+			detach()
+			log.Fatalf("catalog: %v", err)
+		}
+	}
+
+	runHash(ctx, database, cfg, scanID)
+	sendReport(ctx, database, cfg, scanID)
+}
+
+// sendReport sends the scan's report through the configured channels (DITTO_SMTP_URL, DITTO_NOTIFY) when the
+// hash phase finished (a paused scan reports when it is resumed and completes). A failed send is logged; the
+// scan itself succeeded.
+func sendReport(ctx context.Context, database *sql.DB, cfg *config.Config, scanID int64) {
+	notifiers := cfg.Notifiers()
+	if len(notifiers) == 0 {
+		return
+	}
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil || sn.HashCompletedAt == nil {
+		return
+	}
+	report, err := notify.BuildReport(ctx, database, scanID, cfg.BaseURL())
+	if err != nil {
+		log.Printf("report for scan %d: %v", scanID, err)
+		return
+	}
+	if err := notify.Send(ctx, report, notifiers...); err != nil {
+		log.Printf("report for scan %d: %v", scanID, err)
+		return
+	}
+	log.Printf("Report for scan %d sent (%d channel(s))", scanID, len(notifiers))
+}
+
+// hashOptions returns the hash and verify options for the scan, with its folder's read rate limit and I/O
+// priority applied the same way as for scans run by the server.
+func hashOptions(ctx context.Context, database *sql.DB, cfg *config.Config, scanID int64) (*hash.HashOptions, error) {
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		return nil, err
+	}
+	opts := &hash.HashOptions{Workers: 6, ReuseMoved: cfg.HashReuse()}
+	folder, err := db.GetFolder(ctx, database, sn.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	opts.MaxBytesPerSecond = folder.MaxReadBytesPerSec
+	if folder.LowPriority {
+		opts.Priority = ioprio.LowPriority
+	}
+	return opts, nil
+}
 
-	if err := hash.RunHashPhase(ctx, database, scanID, &hash.HashOptions{Workers: 6}); err != nil {
+// runVerify runs the bit-rot check if the scan is a verification scan, and logs the outcome.
+func runVerify(ctx context.Context, database *sql.DB, scanID int64, opts *hash.HashOptions) {
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		log.Fatalf("scan %d: %v", scanID, err)
+	}
+	if !sn.Verify {
+		return
+	}
+	if err := hash.RunVerifyPhase(ctx, database, scanID, opts); err != nil {
+		log.Fatalf("verify: %v", err)
+	}
+	n, err := db.CountBitrotFindings(ctx, database, scanID)
+	if err != nil {
+		log.Fatalf("verify: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Verification of scan %d: %d file(s) changed without a size or mtime change; see /scans/%d/bitrot", scanID, n, scanID)
+		return
+	}
+	log.Printf("Verification of scan %d: no mismatches", scanID)
+}
+
+// pauseScan requests a pause of the scan's hash phase. The process running it (server or "ditto scan")
+// finishes the files in progress and stops; remaining files stay pending.
+func pauseScan(ctx context.Context, database *sql.DB, idStr string) {
+	scanID := parseScanIDArg(idStr)
+	ok, err := db.RequestScanHashPause(ctx, database, scanID)
+	if err != nil {
+		log.Fatalf("pause: %v", err)
+	}
+	if !ok {
+		log.Printf("Scan %d not paused (not found, already paused, or hash phase complete)", scanID)
+		return
+	}
+	log.Printf("Pause requested for scan %d", scanID)
+}
+
+// resumeScan clears the pause flag and runs the rest of the hash phase in this process.
+func resumeScan(ctx context.Context, database *sql.DB, cfg *config.Config, idStr string) {
+	scanID := parseScanIDArg(idStr)
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		log.Fatalf("scan %d: %v", scanID, err)
+	}
+	if sn.CompletedAt == nil {
+		log.Fatalf("scan %d: scan phase not complete; use Continue in the Web UI", scanID)
+	}
+	if err := db.ClearScanHashPause(ctx, database, scanID); err != nil {
+		log.Fatalf("resume: %v", err)
+	}
+	runHash(ctx, database, cfg, scanID)
+	sendReport(ctx, database, cfg, scanID)
+}
+
+func runHash(ctx context.Context, database *sql.DB, cfg *config.Config, scanID int64) {
+	opts, err := hashOptions(ctx, database, cfg, scanID)
+	if err != nil {
+		log.Fatalf("scan %d: %v", scanID, err)
+	}
+	if err := hash.RunHashPhase(ctx, database, scanID, opts); err != nil {
+		if errors.Is(err, hash.ErrPaused) {
+			log.Printf("Hash phase paused for scan %d. Run \"ditto resume %d\" to continue.", scanID, scanID)
+			return
+		}
 		log.Fatalf("hash phase: %v", err)
 	}
 	log.Printf("Hash phase complete for scan %d. Use the Web UI to view duplicates.", scanID)
+	runVerify(ctx, database, scanID, opts)
+	runSimilar(ctx, database, scanID)
+}
+
+// runSimilar runs the near-duplicate phases (images, audio/video) and the photo metadata phase the scan's folder
+// has enabled.
+func runSimilar(ctx context.Context, database *sql.DB, scanID int64) {
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		log.Fatalf("scan %d: %v", scanID, err)
+	}
+	folder, err := db.GetFolder(ctx, database, sn.FolderID)
+	if err != nil {
+		return
+	}
+	opts := &similarity.Options{Workers: 2}
+	if folder.LowPriority {
+		opts.Priority = ioprio.LowPriority
+	}
+	if folder.SimilarImages {
+		if err := similarity.RunPhase(ctx, database, scanID, opts); err != nil {
+			log.Fatalf("similar images: %v", err)
+		}
+	}
+	if folder.SimilarMedia {
+		if err := similarity.RunMediaPhase(ctx, database, scanID, opts); err != nil {
+			log.Fatalf("similar audio/video: %v", err)
+		}
+	}
+	if folder.PhotoMetadata {
+		if err := similarity.RunMetadataPhase(ctx, database, scanID, opts); err != nil {
+			log.Fatalf("photo metadata: %v", err)
+		}
+	}
+}
+
+// lockScan freezes a completed scan's ledger snapshot and stores its checksum.
+func lockScan(ctx context.Context, database *sql.DB, idStr string) {
+	scanID := parseScanIDArg(idStr)
+	checksum, err := db.LockScan(ctx, database, scanID)
+	if err != nil {
+		log.Fatalf("lock scan %d: %v", scanID, err)
+	}
+	log.Printf("Scan %d locked (checksum %s)", scanID, checksum)
+}
+
+// verifyScan recomputes a locked scan's checksum and exits non-zero if it no longer matches.
+func verifyScan(ctx context.Context, database *sql.DB, idStr string) {
+	scanID := parseScanIDArg(idStr)
+	ok, current, err := db.CheckScanChecksum(ctx, database, scanID)
+	if err != nil {
+		log.Fatalf("verify scan %d: %v", scanID, err)
+	}
+	if !ok {
+		log.Fatalf("Scan %d: checksum mismatch (snapshot now hashes to %s)", scanID, current)
+	}
+	log.Printf("Scan %d: checksum OK (%s)", scanID, current)
+}
+
+// exportManifest writes the scan's (signed) hash manifest as JSON to stdout.
+func exportManifest(ctx context.Context, database *sql.DB, cfg *config.Config, idStr string) {
+	scanID := parseScanIDArg(idStr)
+	m, err := manifest.BuildScan(ctx, database, scanID, manifestSigner(cfg.ManifestSigningKey()))
+	if err != nil {
+		log.Fatalf("export manifest: %v", err)
+	}
+	if err := manifest.Write(os.Stdout, m); err != nil {
+		log.Fatalf("export manifest: %v", err)
+	}
+}
+
+// exportManifestIndex writes the (signed) manifest-of-manifests as JSON to stdout.
+func exportManifestIndex(ctx context.Context, database *sql.DB, cfg *config.Config) {
+	idx, err := manifest.BuildIndex(ctx, database, manifestSigner(cfg.ManifestSigningKey()))
+	if err != nil {
+		log.Fatalf("export manifest index: %v", err)
+	}
+	if err := manifest.Write(os.Stdout, idx); err != nil {
+		log.Fatalf("export manifest index: %v", err)
+	}
+}
+
+// importManifest stores an external hash list (CSV, sha256sum/rclone hashsum, or ditto manifest) as an import scan.
+func importManifest(ctx context.Context, database *sql.DB, name, path string) {
+	f, err := os.Open(path) // #nosec G304 -- path is the CLI argument
+	if err != nil {
+		log.Fatalf("import manifest: %v", err)
+	}
+	defer f.Close()
+	entries, err := manifest.ParseExternal(f)
+	if err != nil {
+		log.Fatalf("import manifest: %v", err)
+	}
+	scanID, err := manifest.Import(ctx, database, name, "", entries)
+	if err != nil {
+		log.Fatalf("import manifest: %v", err)
+	}
+	log.Printf("Imported %d files as scan %d", len(entries), scanID)
+}
+
+// importPhotos catalogs a photo-library export (Google Takeout, Apple Photos) and stores it as an import scan.
+func importPhotos(ctx context.Context, database *sql.DB, name, path string) {
+	scanID, st, err := photoexport.Import(ctx, database, name, path)
+	if err != nil {
+		log.Fatalf("import photos: %v", err)
+	}
+	log.Printf("Imported %d photos and videos (%s, %d metadata files skipped) as scan %d", st.Photos, humanBytes(st.Bytes), st.Sidecars, scanID)
+}
+
+// scanRemote handles "ditto scan-remote [-download] [-min-size bytes] [-host name] <name> <remote:path>": catalog
+// an rclone remote path and store it as a scan of the import name.
+func scanRemote(ctx context.Context, database *sql.DB, args []string) {
+	fs := flag.NewFlagSet("scan-remote", flag.ExitOnError)
+	download := fs.Bool("download", false, "read and hash files the provider stores no SHA-256 for (S3, B2: all of them)")
+	minSize := fs.Int64("min-size", 0, "skip files smaller than this many bytes")
+	host := fs.String("host", "", "host shown in cross-host duplicate groups (default: the remote's name)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatalf("usage: ditto scan-remote [-download] [-min-size bytes] [-host name] <name> <remote:path>")
+	}
+	backend, err := remote.NewRclone(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("scan remote: %v", err)
+	}
+	if *host == "" {
+		*host = backend.Host()
+	}
+	scanID, st, err := remote.Import(ctx, database, fs.Arg(0), *host, backend, remote.Options{MinSize: *minSize, Download: *download})
+	if err != nil {
+		log.Fatalf("scan remote: %v", err)
+	}
+	log.Printf("Scanned %s as scan %d: %d files listed, %d hashed by download (%s read), %d skipped",
+		fs.Arg(1), scanID, st.Listed, st.Downloaded, humanBytes(st.ReadBytes), st.Skipped)
+}
+
+// importRoots registers the scan roots listed in path ("-" reads stdin) and prints one result per listed root.
+// Exits non-zero when any root could not be added.
+func importRoots(ctx context.Context, database *sql.DB, path string) {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path) // #nosec G304 -- path is the CLI argument
+		if err != nil {
+			log.Fatalf("import roots: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	entries, err := rootlist.Parse(in)
+	if err != nil {
+		log.Fatalf("import roots: %v", err)
+	}
+	results, err := rootlist.Import(ctx, database, entries)
+	for _, r := range results {
+		switch r.Status {
+		case rootlist.StatusAdded:
+			fmt.Printf("line %d: added %s (root %d)\n", r.Line, r.Path, r.FolderID)
+		case rootlist.StatusExists:
+			fmt.Printf("line %d: already registered %s (root %d)\n", r.Line, r.Path, r.FolderID)
+		default:
+			fmt.Printf("line %d: error %s: %v\n", r.Line, r.Path, r.Err)
+		}
+	}
+	if err != nil {
+		log.Fatalf("import roots: %v", err)
+	}
+	added, exists, failed := rootlist.Counts(results)
+	fmt.Printf("%d added, %d already registered, %d failed\n", added, exists, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// verifyManifest checks a manifest or index file's digest and, when a signing key is configured, its signature.
+func verifyManifest(path string) {
+	f, err := os.Open(path) // #nosec G304 -- path is the CLI argument
+	if err != nil {
+		log.Fatalf("verify manifest: %v", err)
+	}
+	defer f.Close()
+	res, err := manifest.Verify(f, manifestSigner(os.Getenv(config.EnvManifestSigningKey)))
+	if err != nil {
+		log.Fatalf("verify manifest: %s: %v", path, err)
+	}
+	sig := "signature not checked (" + config.EnvManifestSigningKey + " not set)"
+	if res.Signed {
+		sig = "signature OK"
+	}
+	log.Printf("%s: OK (%s, %d entries, digest %s, %s)", path, res.Kind, res.Entries, res.Digest, sig)
+}
+
+func manifestSigner(key string) *manifest.Signer {
+	signer, err := manifest.ParseKey(key)
+	if err != nil {
+		log.Fatalf("%s: %v", config.EnvManifestSigningKey, err)
+	}
+	return signer
+}
+
+// runBench generates a synthetic tree (or uses -dir), scans and hashes it, and prints per-phase throughput.
+// The run is recorded in the database as a normal scan of the tree's folder.
+func runBench(ctx context.Context, database *sql.DB, args []string) {
+	spec := bench.DefaultTreeSpec
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	dir := fs.String("dir", "", "benchmark this existing directory instead of generating a tree")
+	keep := fs.Bool("keep", false, "keep the generated tree (printed path) instead of deleting it")
+	workers := fs.Int("workers", 6, "hash workers")
+	fs.IntVar(&spec.Files, "files", spec.Files, "number of files to generate")
+	fs.Int64Var(&spec.MinSize, "min-size", spec.MinSize, "smallest file size in bytes")
+	fs.Int64Var(&spec.MaxSize, "max-size", spec.MaxSize, "largest file size in bytes (sizes are log-uniform)")
+	fs.Float64Var(&spec.DuplicateRatio, "dup-ratio", spec.DuplicateRatio, "fraction of files that duplicate an earlier file")
+	fs.IntVar(&spec.Depth, "depth", spec.Depth, "directory levels")
+	fs.IntVar(&spec.FanOut, "fanout", spec.FanOut, "subdirectories per directory")
+	fs.Int64Var(&spec.Seed, "seed", spec.Seed, "random seed (same seed = same tree)")
+	_ = fs.Parse(args)
+
+	root := *dir
+	if root == "" {
+		tmp, err := os.MkdirTemp("", "ditto-bench-")
+		if err != nil {
+			log.Fatalf("bench: %v", err)
+		}
+		if !*keep {
+			defer os.RemoveAll(tmp)
+		}
+		start := time.Now()
+		stats, err := bench.GenerateTree(tmp, spec)
+		if err != nil {
+			log.Fatalf("bench: generate tree: %v", err)
+		}
+		fmt.Printf("generated %d files (%d duplicates, %s) in %d dirs at %s in %v\n",
+			stats.Files, stats.Duplicates, humanBytes(stats.Bytes), stats.Dirs, tmp, time.Since(start).Round(time.Millisecond))
+		root = tmp
+	}
+	res, err := bench.Run(ctx, database, root, &hash.HashOptions{Workers: *workers})
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+	fmt.Printf("scan %d: %d files in %v (%.0f files/s)\n", res.ScanID, res.Files, res.ScanTime.Round(time.Millisecond), res.FilesPerSecond())
+	fmt.Printf("hash: %d files, %s in %v (%s/s, %d reused)\n", res.HashedFiles, humanBytes(res.HashedBytes), res.HashTime.Round(time.Millisecond), humanBytes(int64(res.HashBytesPerSecond())), res.HashReused)
+	fmt.Printf("duplicate groups: %d\n", res.DupGroups)
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// runMigrate handles "ditto migrate [status | up | to <version>]". With no subcommand it prints the status.
+func runMigrate(ctx context.Context, database *sql.DB, args []string) {
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "status":
+	case "up":
+		if err := db.MigratePostgres(database); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+	case "to":
+		if len(args) < 2 {
+			log.Fatalf("usage: ditto migrate to <version>")
+		}
+		v, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("invalid version %q", args[1])
+		}
+		if err := db.MigrateTo(ctx, database, v); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+	default:
+		log.Fatalf("usage: ditto migrate [status | up | to <version>]")
+	}
+	states, err := db.MigrationStatus(ctx, database)
+	if err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	for _, s := range states {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = "applied " + s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Printf("%04d  %-28s %s\n", s.Version, s.Name, applied)
+	}
+}
+
+// runAgent handles "ditto agent [-min-size bytes] [-host name] <coordinator url> <name> <path>": catalog path and ship it to
+// the coordinator as the import name, with the admin API token in DITTO_AGENT_TOKEN.
+func runAgent(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	minSize := fs.Int64("min-size", 0, "skip files smaller than this many bytes")
+	host := fs.String("host", "", "machine name shown in cross-host duplicate groups (default: the hostname)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 3 {
+		log.Fatalf("usage: ditto agent [-min-size bytes] [-host name] <coordinator url> <name> <path>")
+	}
+	token := os.Getenv(config.EnvAgentToken)
+	if token == "" {
+		log.Fatalf("agent: %s must hold an admin API token of the coordinator", config.EnvAgentToken)
+	}
+	res, err := agent.Ship(ctx, http.DefaultClient, fs.Arg(0), token, fs.Arg(1), fs.Arg(2), agent.Options{MinSize: *minSize, Host: *host})
+	if err != nil {
+		log.Fatalf("agent: %v", err)
+	}
+	log.Printf("Shipped %d files to %s as scan %d", res.Files, fs.Arg(0), res.ScanID)
+}
+
+// runMaintain handles "ditto maintain [-reindex]": VACUUM/ANALYZE every table and print the size change.
+func runMaintain(ctx context.Context, database *sql.DB, args []string) {
+	fs := flag.NewFlagSet("maintain", flag.ExitOnError)
+	reindex := fs.Bool("reindex", false, "also rebuild all indexes (blocks writes while it runs)")
+	_ = fs.Parse(args)
+	rep, err := db.Maintain(ctx, database, db.MaintainOptions{Reindex: *reindex})
+	if err != nil {
+		log.Fatalf("maintain: %v", err)
+	}
+	fmt.Printf("%d tables vacuumed", rep.Tables)
+	if rep.Reindexed {
+		fmt.Print(" and reindexed")
+	}
+	fmt.Printf(" in %v\ndatabase size: %s -> %s (%s freed)\n", rep.Duration, humanBytes(rep.SizeBefore), humanBytes(rep.SizeAfter), humanBytes(rep.Freed()))
+}
+
+func parseScanIDArg(s string) int64 {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		log.Fatalf("invalid scan id %q", s)
+	}
+	return id
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/config"
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/ioprio"
+)
+
+func TestHashOptions_appliesFolderThrottleAndPriority(t *testing.T) {
+	database := db.TestPostgresDB(t)
+	ctx := context.Background()
+	cfg := &config.Config{}
+
+	throttled, _ := db.AddFolder(ctx, database, "/mnt/nas")
+	if err := db.UpdateFolderIOSettings(ctx, database, throttled, 50<<20, true); err != nil {
+		t.Fatalf("UpdateFolderIOSettings: %v", err)
+	}
+	plain, _ := db.AddFolder(ctx, database, "/data")
+	for _, tc := range []struct {
+		folderID int64
+		wantRate int64
+		wantPrio ioprio.Settings
+	}{
+		{throttled, 50 << 20, ioprio.LowPriority},
+		{plain, 0, ioprio.Settings{}},
+	} {
+		sn, err := db.CreateScan(ctx, database, tc.folderID)
+		if err != nil {
+			t.Fatalf("CreateScan: %v", err)
+		}
+		opts, err := hashOptions(ctx, database, cfg, sn.ID)
+		if err != nil {
+			t.Fatalf("hashOptions: %v", err)
+		}
+		if opts.MaxBytesPerSecond != tc.wantRate {
+			t.Errorf("folder %d: MaxBytesPerSecond = %d, want %d", tc.folderID, opts.MaxBytesPerSecond, tc.wantRate)
+		}
+		if opts.Priority != tc.wantPrio {
+			t.Errorf("folder %d: Priority = %v, want %v", tc.folderID, opts.Priority, tc.wantPrio)
+		}
+		if opts.Workers != 6 {
+			t.Errorf("folder %d: Workers = %d, want 6", tc.folderID, opts.Workers)
+		}
+	}
+}
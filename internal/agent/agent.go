@@ -0,0 +1,171 @@
+// Package agent catalogs a directory on a machine without a database and ships the result to a ditto
+// coordinator, which stores it as an import (see manifest.Import). Running the agent on a laptop or desktop lets
+// one ditto instance find duplicates across machines.
+package agent
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/hash"
+	"github.com/eargollo/ditto/internal/scan"
+)
+
+// ImportPath is the coordinator endpoint that receives a catalog.
+const ImportPath = "/api/v1/imports"
+
+// Options tunes a catalog run.
+type Options struct {
+	ExcludePatterns []string // as for scans (scan.Excludes); the root's .dittoignore is added by Ship
+	MinSize         int64    // skip files smaller than this many bytes (0: all)
+	Host            string   // machine name sent with the catalog, shown in cross-host groups; Ship defaults it to the hostname
+}
+
+// Result is the coordinator's answer to a shipped catalog.
+type Result struct {
+	ScanID int64 `json:"scan_id"`
+	Files  int   `json:"files"`
+}
+
+// Catalog walks root, hashes every regular file and writes a CSV (path, size, hash) with paths relative to root,
+// in the format manifest.ParseExternal reads. Symlinks and files that cannot be read are skipped and logged.
+// Returns the number of files written.
+func Catalog(ctx context.Context, root string, opts Options, w io.Writer) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"path", "size", "hash"}); err != nil {
+		return 0, err
+	}
+	n := 0
+	excludes := scan.CompileExcludes(opts.ExcludePatterns)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			log.Printf("[agent] skip %s: %v", path, err)
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path != root && excludes.Excluded(root, path, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			log.Printf("[agent] skip %s: %v", path, err)
+			return nil
+		}
+		if info.Size() < opts.MinSize {
+			return nil
+		}
+		h, err := hash.HashFileLimited(ctx, path, nil)
+		if err != nil {
+			log.Printf("[agent] skip %s: %v", path, err)
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		n++
+		return cw.Write([]string{filepath.ToSlash(rel), strconv.FormatInt(info.Size(), 10), h})
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return n, err
+}
+
+// Ship catalogs root and uploads it to the coordinator at baseURL as the import name, authenticated with an
+// admin API token. The catalog is written to a temporary file first, so hashing a large tree does not hold a
+// request open. Each run becomes a new scan of the same import, so the coordinator keeps the machine's history.
+func Ship(ctx context.Context, client *http.Client, baseURL, token, name, root string, opts Options) (*Result, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("agent: a name is required")
+	}
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+	if rootOpts, err := scan.OptionsForRoot(root); err == nil {
+		opts.ExcludePatterns = append(opts.ExcludePatterns, rootOpts.ExcludePatterns...)
+	}
+	tmp, err := os.CreateTemp("", "ditto-agent-*.csv")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	n, err := Catalog(ctx, root, opts, tmp)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[agent] cataloged %d files under %s", n, root)
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := func() error {
+			if err := mw.WriteField("name", name); err != nil {
+				return err
+			}
+			if err := mw.WriteField("host", opts.Host); err != nil {
+				return err
+			}
+			part, err := mw.CreateFormFile("manifest", "catalog.csv")
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, tmp); err != nil {
+				return err
+			}
+			return mw.Close()
+		}()
+		_ = pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+ImportPath, pr)
+	if err != nil {
+		_ = pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("coordinator: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var res Result
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("coordinator response: %w", err)
+	}
+	return &res, nil
+}
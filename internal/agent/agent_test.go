@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/manifest"
+)
+
+func TestShip_uploadsCatalogOfTree(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "photos", "cache"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		"photos/a.jpg":       "same",
+		"b.jpg":              "same",
+		"tiny.txt":           "x",
+		"photos/cache/c.tmp": "excluded",
+	} {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "b.jpg"), filepath.Join(root, "link.jpg")); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotName, gotHost string
+	var got []manifest.ExternalEntry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ImportPath || r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		gotName, gotHost = r.FormValue("name"), r.FormValue("host")
+		f, _, err := r.FormFile("manifest")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		if got, err = manifest.ParseExternal(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(Result{ScanID: 9, Files: len(got)})
+	}))
+	defer srv.Close()
+
+	res, err := Ship(context.Background(), srv.Client(), srv.URL+"/", "tok", "laptop", root,
+		Options{ExcludePatterns: []string{"cache"}, MinSize: 2, Host: "laptop.lan"})
+	if err != nil {
+		t.Fatalf("Ship: %v", err)
+	}
+	if res.ScanID != 9 || res.Files != 2 || gotName != "laptop" || gotHost != "laptop.lan" {
+		t.Errorf("Ship = %+v, name %q, host %q; want scan 9 with 2 files as laptop on laptop.lan", res, gotName, gotHost)
+	}
+	paths := map[string]manifest.ExternalEntry{}
+	for _, e := range got {
+		paths[e.Path] = e
+	}
+	a, b := paths["photos/a.jpg"], paths["b.jpg"]
+	if len(paths) != 2 || a.Hash == "" || a.Hash != b.Hash || a.Size != 4 {
+		t.Errorf("catalog = %+v; want photos/a.jpg and b.jpg with the same hash (tiny, excluded and symlinked files skipped)", got)
+	}
+}
+
+func TestShip_reportsCoordinatorError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	if _, err := Ship(context.Background(), srv.Client(), srv.URL, "bad", "laptop", t.TempDir(), Options{}); err == nil {
+		t.Error("Ship to a coordinator that refuses = nil error, want one")
+	}
+}
@@ -0,0 +1,292 @@
+// Package archive lists and reads the files inside zip and tar archives, so scans can record them under a
+// virtual path "<archive>!/<member>" and the hash phase can hash them like any other file. Archives inside
+// archives are listed as plain members, not opened.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/pathnorm"
+)
+
+// Sep separates an archive's path from the name of a member in a virtual path.
+const Sep = "!/"
+
+// ErrNotFound is returned by Open when the archive has no member with the name.
+var ErrNotFound = errors.New("no such file in archive")
+
+// Member is a regular file inside an archive.
+type Member struct {
+	Name  string // slash-separated path inside the archive, without a leading slash
+	Size  int64  // uncompressed size
+	MTime int64  // Unix seconds
+}
+
+// extensions are the lowercase suffixes of the archives IsArchive recognises.
+var extensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether name has the extension of an archive List can read.
+func IsArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the virtual path of member inside the archive at archivePath.
+func Path(archivePath, member string) string {
+	return archivePath + Sep + member
+}
+
+// Split splits a virtual path into the archive's path and the member's name. ok is false for the path of a
+// plain file, including one whose name merely contains Sep.
+func Split(p string) (archivePath, member string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(p[i:], Sep)
+		if j < 0 {
+			return "", "", false
+		}
+		i += j
+		if IsArchive(p[:i]) && i+len(Sep) < len(p) {
+			return p[:i], p[i+len(Sep):], true
+		}
+		i += len(Sep)
+	}
+}
+
+// IsVirtual reports whether p names a file inside an archive.
+func IsVirtual(p string) bool {
+	_, _, ok := Split(p)
+	return ok
+}
+
+// List calls fn for each regular file in the archive at p, in archive order. Members with an unsafe name
+// (absolute, or escaping the archive with ..) are skipped. An error from fn stops the listing and is returned.
+func List(p string, fn func(Member) error) error {
+	if isZip(p) {
+		r, err := zip.OpenReader(p)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			name, ok := cleanName(f.Name)
+			if !ok || !f.Mode().IsRegular() {
+				continue
+			}
+			if err := fn(Member{Name: name, Size: int64(f.UncompressedSize64), MTime: f.Modified.Unix()}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	f, tr, err := openTar(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := cleanName(h.Name)
+		if !ok || h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(Member{Name: name, Size: h.Size, MTime: h.ModTime.Unix()}); err != nil {
+			return err
+		}
+	}
+}
+
+// Read calls fn with each regular file in the archive at p and its content, in archive order and in one pass, so
+// reading every member of a tar archive does not re-read the archive up to each one (as Open would). Members are
+// skipped like List skips them. The reader is valid only during the call.
+func Read(p string, fn func(Member, io.Reader) error) error {
+	if isZip(p) {
+		r, err := zip.OpenReader(p)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			name, ok := cleanName(f.Name)
+			if !ok || !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(Member{Name: name, Size: int64(f.UncompressedSize64), MTime: f.Modified.Unix()}, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	f, tr, err := openTar(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := cleanName(h.Name)
+		if !ok || h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(Member{Name: name, Size: h.Size, MTime: h.ModTime.Unix()}, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Open opens p for reading. A virtual path opens the member inside its archive (a tar archive is read up to
+// the member); any other path opens the file like pathnorm.Open.
+func Open(p string) (io.ReadCloser, error) {
+	archivePath, member, ok := Split(p)
+	if !ok {
+		return pathnorm.Open(p)
+	}
+	archivePath = pathnorm.Resolve(archivePath)
+	if isZip(archivePath) {
+		r, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range r.File {
+			if name, ok := cleanName(f.Name); ok && name == member && f.Mode().IsRegular() {
+				rc, err := f.Open()
+				if err != nil {
+					r.Close()
+					return nil, err
+				}
+				return readCloser{rc, multiCloser{rc, r}}, nil
+			}
+		}
+		r.Close()
+		return nil, fmt.Errorf("%s: %w", p, ErrNotFound)
+	}
+	f, tr, err := openTar(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			f.Close()
+			if err == io.EOF {
+				return nil, fmt.Errorf("%s: %w", p, ErrNotFound)
+			}
+			return nil, err
+		}
+		if name, ok := cleanName(h.Name); ok && name == member && h.Typeflag == tar.TypeReg {
+			return readCloser{tr, f}, nil
+		}
+	}
+}
+
+// Stat returns the size and modification time of the file at p, inside its archive for a virtual path.
+func Stat(p string) (size, mtime int64, err error) {
+	archivePath, member, ok := Split(p)
+	if !ok {
+		info, err := os.Stat(pathnorm.Resolve(p))
+		if err != nil {
+			return 0, 0, err
+		}
+		return info.Size(), info.ModTime().Unix(), nil
+	}
+	found := false
+	err = List(pathnorm.Resolve(archivePath), func(m Member) error {
+		if m.Name != member {
+			return nil
+		}
+		size, mtime, found = m.Size, m.MTime, true
+		return errStop
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return 0, 0, err
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("%s: %w", p, fs.ErrNotExist)
+	}
+	return size, mtime, nil
+}
+
+// errStop ends a List early once Stat found its member.
+var errStop = errors.New("stop")
+
+// cleanName returns the slash-separated member name without a leading "./", and false for a name that is
+// absolute or leaves the archive.
+func cleanName(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+func isZip(p string) bool {
+	return strings.HasSuffix(strings.ToLower(p), ".zip")
+}
+
+// openTar opens a tar archive, uncompressing it first when gzipped. Closing the returned file ends both.
+func openTar(p string) (io.Closer, *tar.Reader, error) {
+	f, err := pathnorm.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	lower := strings.ToLower(p)
+	if !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") {
+		return f, tar.NewReader(f), nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return multiCloser{gz, f}, tar.NewReader(gz), nil
+}
+
+// readCloser reads from one reader and closes another (the archive holding it).
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// multiCloser closes each closer in order and returns the first error.
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
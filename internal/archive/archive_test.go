@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var members = map[string]string{"a.txt": "hello", "dir/b.txt": "world!"}
+
+func writeZip(t *testing.T, p string) {
+	t.Helper()
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	if _, err := w.Create("dir/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "dir/b.txt", "../evil.txt"} {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Unix(1600000000, 0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(fw, members[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, p string) {
+	t.Helper()
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "./dir/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "dir/b.txt"} {
+		body := members[name]
+		if err := tw.WriteHeader(&tar.Header{Name: "./" + name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(body)), ModTime: time.Unix(1600000000, 0)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListAndOpen(t *testing.T) {
+	dir := t.TempDir()
+	zipPath, tgzPath := filepath.Join(dir, "backup.zip"), filepath.Join(dir, "backup.tar.gz")
+	writeZip(t, zipPath)
+	writeTarGz(t, tgzPath)
+
+	for _, archivePath := range []string{zipPath, tgzPath} {
+		got := make(map[string]Member)
+		if err := List(archivePath, func(m Member) error { got[m.Name] = m; return nil }); err != nil {
+			t.Fatalf("List(%s): %v", archivePath, err)
+		}
+		if len(got) != len(members) {
+			t.Errorf("List(%s) = %v, want only the regular files %v", archivePath, got, members)
+		}
+		for name, body := range members {
+			m, ok := got[name]
+			if !ok || m.Size != int64(len(body)) || m.MTime != 1600000000 {
+				t.Errorf("List(%s)[%s] = %+v, want size %d", archivePath, name, m, len(body))
+				continue
+			}
+			rc, err := Open(Path(archivePath, name))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil || string(b) != body {
+				t.Errorf("Open(%s) read %q, %v; want %q", Path(archivePath, name), b, err, body)
+			}
+			if size, _, err := Stat(Path(archivePath, name)); err != nil || size != int64(len(body)) {
+				t.Errorf("Stat(%s) = %d, %v", Path(archivePath, name), size, err)
+			}
+		}
+		read := make(map[string]string)
+		if err := Read(archivePath, func(m Member, r io.Reader) error {
+			b, err := io.ReadAll(r)
+			read[m.Name] = string(b)
+			return err
+		}); err != nil || len(read) != len(members) || read["a.txt"] != "hello" || read["dir/b.txt"] != "world!" {
+			t.Errorf("Read(%s) = %v, %v; want %v", archivePath, read, err, members)
+		}
+		if _, err := Open(Path(archivePath, "missing.txt")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open of a missing member: err = %v, want ErrNotFound", err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for _, tc := range []struct {
+		p, archive, member string
+		ok                 bool
+	}{
+		{"/d/a.zip!/x/y.txt", "/d/a.zip", "x/y.txt", true},
+		{"/d/A.TGZ!/y", "/d/A.TGZ", "y", true},
+		{"/d/wow!/a.zip!/y", "/d/wow!/a.zip", "y", true},
+		{"/d/wow!/y.txt", "", "", false},
+		{"/d/a.zip", "", "", false},
+		{"/d/a.zip!/", "", "", false},
+	} {
+		archive, member, ok := Split(tc.p)
+		if archive != tc.archive || member != tc.member || ok != tc.ok {
+			t.Errorf("Split(%q) = %q, %q, %v; want %q, %q, %v", tc.p, archive, member, ok, tc.archive, tc.member, tc.ok)
+		}
+	}
+	if !IsArchive("x.tar") || !IsArchive("X.Zip") || IsArchive("x.gz") {
+		t.Error("IsArchive: wrong result for .tar, .Zip or .gz")
+	}
+}
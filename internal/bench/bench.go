@@ -0,0 +1,75 @@
+package bench
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/hash"
+	"github.com/eargollo/ditto/internal/scan"
+)
+
+// Result is the outcome of one benchmark run.
+type Result struct {
+	ScanID      int64
+	ScanTime    time.Duration
+	HashTime    time.Duration
+	Files       int64 // files recorded by the scan
+	HashedFiles int64
+	HashedBytes int64
+	HashReused  int64
+	DupGroups   int64
+}
+
+// FilesPerSecond returns the scan phase throughput.
+func (r *Result) FilesPerSecond() float64 {
+	if r.ScanTime <= 0 {
+		return 0
+	}
+	return float64(r.Files) / r.ScanTime.Seconds()
+}
+
+// HashBytesPerSecond returns the hash phase throughput.
+func (r *Result) HashBytesPerSecond() float64 {
+	if r.HashTime <= 0 {
+		return 0
+	}
+	return float64(r.HashedBytes) / r.HashTime.Seconds()
+}
+
+// Run scans dir and runs the hash phase with hashOpts, timing each phase. The scan is recorded in the
+// database like any other (the folder for dir is created if needed).
+func Run(ctx context.Context, database *sql.DB, dir string, hashOpts *hash.HashOptions) (*Result, error) {
+	res := &Result{}
+	start := time.Now()
+	scanID, err := scan.RunScan(ctx, database, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.ScanID, res.ScanTime = scanID, time.Since(start)
+
+	start = time.Now()
+	if err := hash.RunHashPhase(ctx, database, scanID, hashOpts); err != nil {
+		return nil, err
+	}
+	res.HashTime = time.Since(start)
+
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		return nil, err
+	}
+	res.Files = deref(sn.FileCount)
+	res.HashedFiles, res.HashedBytes, res.HashReused = deref(sn.HashedFileCount), deref(sn.HashedByteCount), deref(sn.HashReusedCount)
+	if res.DupGroups, err = db.DuplicateGroupsByHashCount(ctx, database, scanID); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func deref(p *int64) int64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
@@ -0,0 +1,61 @@
+package bench
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/hash"
+	"github.com/eargollo/ditto/internal/scan"
+)
+
+// benchSpec is small enough for go test -bench to finish quickly; use "ditto bench" for larger trees.
+var benchSpec = TreeSpec{Files: 500, MinSize: 1 << 10, MaxSize: 256 << 10, DuplicateRatio: 0.3, Depth: 2, FanOut: 4, Seed: 1}
+
+func BenchmarkGenerateTree(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := GenerateTree(b.TempDir(), benchSpec); err != nil {
+			b.Fatalf("GenerateTree: %v", err)
+		}
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	dir := b.TempDir()
+	if _, err := GenerateTree(dir, benchSpec); err != nil {
+		b.Fatalf("GenerateTree: %v", err)
+	}
+	database := db.TestPostgresDB(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scan.RunScan(ctx, database, dir, nil); err != nil {
+			b.Fatalf("RunScan: %v", err)
+		}
+	}
+	b.ReportMetric(float64(benchSpec.Files*b.N)/b.Elapsed().Seconds(), "files/s")
+}
+
+func BenchmarkHashPhase(b *testing.B) {
+	dir := b.TempDir()
+	stats, err := GenerateTree(dir, benchSpec)
+	if err != nil {
+		b.Fatalf("GenerateTree: %v", err)
+	}
+	ctx := context.Background()
+	b.SetBytes(stats.Bytes)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// A fresh database each iteration so no hash is reused from a previous scan.
+		b.StopTimer()
+		database := db.TestPostgresDB(b)
+		scanID, err := scan.RunScan(ctx, database, dir, nil)
+		if err != nil {
+			b.Fatalf("RunScan: %v", err)
+		}
+		b.StartTimer()
+		if err := hash.RunHashPhase(ctx, database, scanID, &hash.HashOptions{Workers: 4}); err != nil {
+			b.Fatalf("RunHashPhase: %v", err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package bench generates synthetic file trees and times the scan and hash phases on them, so performance
+// changes can be compared reproducibly (ditto bench, and the Go benchmarks in this package).
+package bench
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// writeChunk is the buffer size used when writing generated file content.
+const writeChunk = 1 << 20
+
+// TreeSpec describes a synthetic tree. The same spec and seed always produce the same tree.
+type TreeSpec struct {
+	Files          int     // number of files
+	MinSize        int64   // smallest file size in bytes
+	MaxSize        int64   // largest file size in bytes; sizes are log-uniform between MinSize and MaxSize
+	DuplicateRatio float64 // fraction of files (0..1) that copy the content of an earlier file
+	Depth          int     // directory levels below the root
+	FanOut         int     // subdirectories per directory
+	Seed           int64
+}
+
+// DefaultTreeSpec is a small mixed tree: 1000 files from 1 KiB to 4 MiB, 30% duplicates, 3 levels of 4 dirs.
+var DefaultTreeSpec = TreeSpec{Files: 1000, MinSize: 1 << 10, MaxSize: 4 << 20, DuplicateRatio: 0.3, Depth: 3, FanOut: 4, Seed: 1}
+
+// TreeStats summarizes a generated tree.
+type TreeStats struct {
+	Files      int
+	Dirs       int
+	Duplicates int   // files whose content copies an earlier file
+	Bytes      int64 // total bytes written
+}
+
+// original is a generated unique file; its content is regenerated from seed when duplicated.
+type original struct {
+	seed int64
+	size int64
+}
+
+// GenerateTree writes the tree described by spec under root (created if missing).
+func GenerateTree(root string, spec TreeSpec) (TreeStats, error) {
+	if spec.Files < 0 || spec.MinSize < 0 || spec.MaxSize < spec.MinSize || spec.DuplicateRatio < 0 || spec.DuplicateRatio > 1 {
+		return TreeStats{}, fmt.Errorf("invalid tree spec %+v", spec)
+	}
+	rng := rand.New(rand.NewSource(spec.Seed)) // #nosec G404 -- deterministic test data, not security sensitive
+	dirs := []string{root}
+	level := []string{root}
+	for d := 0; d < spec.Depth; d++ {
+		var next []string
+		for _, parent := range level {
+			for i := 0; i < spec.FanOut; i++ {
+				next = append(next, filepath.Join(parent, fmt.Sprintf("d%d", i)))
+			}
+		}
+		dirs = append(dirs, next...)
+		level = next
+	}
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0750); err != nil {
+			return TreeStats{}, err
+		}
+	}
+	stats := TreeStats{Dirs: len(dirs)}
+	var originals []original
+	for i := 0; i < spec.Files; i++ {
+		var o original
+		if len(originals) > 0 && rng.Float64() < spec.DuplicateRatio {
+			o = originals[rng.Intn(len(originals))]
+			stats.Duplicates++
+		} else {
+			o = original{seed: rng.Int63(), size: logUniform(rng, spec.MinSize, spec.MaxSize)}
+			originals = append(originals, o)
+		}
+		path := filepath.Join(dirs[rng.Intn(len(dirs))], fmt.Sprintf("f%06d.bin", i))
+		if err := writeContent(path, o); err != nil {
+			return stats, err
+		}
+		stats.Files++
+		stats.Bytes += o.size
+	}
+	return stats, nil
+}
+
+func logUniform(rng *rand.Rand, lo, hi int64) int64 {
+	if lo == hi {
+		return lo
+	}
+	l, h := math.Log(float64(lo)+1), math.Log(float64(hi)+1)
+	return int64(math.Exp(l+rng.Float64()*(h-l))) - 1
+}
+
+// writeContent writes o.size pseudo-random bytes derived from o.seed.
+func writeContent(path string, o original) error {
+	f, err := os.Create(path) // #nosec G304 -- path under the caller's tree root
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(f, writeChunk)
+	src := rand.New(rand.NewSource(o.seed)) // #nosec G404 -- deterministic test data
+	buf := make([]byte, writeChunk)
+	for left := o.size; left > 0; {
+		n := int64(len(buf))
+		if left < n {
+			n = left
+		}
+		_, _ = src.Read(buf[:n])
+		if _, err := w.Write(buf[:n]); err != nil {
+			f.Close()
+			return err
+		}
+		left -= n
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
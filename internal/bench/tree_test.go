@@ -0,0 +1,69 @@
+package bench
+
+import (
+	"crypto/sha256"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func treeDigests(t *testing.T, root string) map[string][32]byte {
+	t.Helper()
+	out := make(map[string][32]byte)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		out[rel] = sha256.Sum256(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	return out
+}
+
+func TestGenerateTree_deterministicWithDuplicates(t *testing.T) {
+	spec := TreeSpec{Files: 200, MinSize: 1, MaxSize: 4096, DuplicateRatio: 0.5, Depth: 2, FanOut: 3, Seed: 42}
+	a, b := t.TempDir(), t.TempDir()
+	stats, err := GenerateTree(a, spec)
+	if err != nil {
+		t.Fatalf("GenerateTree: %v", err)
+	}
+	if _, err := GenerateTree(b, spec); err != nil {
+		t.Fatalf("GenerateTree again: %v", err)
+	}
+	if stats.Files != 200 || stats.Dirs != 1+3+9 {
+		t.Errorf("stats = %+v, want 200 files in 13 dirs", stats)
+	}
+	if stats.Duplicates < 60 || stats.Duplicates > 140 {
+		t.Errorf("Duplicates = %d, want about half of 200", stats.Duplicates)
+	}
+
+	da, db := treeDigests(t, a), treeDigests(t, b)
+	if len(da) != 200 {
+		t.Fatalf("tree has %d files, want 200", len(da))
+	}
+	distinct := make(map[[32]byte]bool)
+	for rel, sum := range da {
+		if db[rel] != sum {
+			t.Fatalf("%s differs between runs with the same seed", rel)
+		}
+		distinct[sum] = true
+	}
+	if got, want := len(distinct), stats.Files-stats.Duplicates; got != want {
+		t.Errorf("distinct contents = %d, want %d", got, want)
+	}
+}
+
+func TestGenerateTree_invalidSpec(t *testing.T) {
+	if _, err := GenerateTree(t.TempDir(), TreeSpec{Files: 1, MinSize: 10, MaxSize: 5}); err == nil {
+		t.Error("GenerateTree(MaxSize < MinSize): want error")
+	}
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// notAcknowledged filters out files (alias f) of acknowledged duplicate groups.
+const notAcknowledged = `f.hash NOT IN (SELECT hash FROM acknowledged_groups)`
+
+// AcknowledgedGroup is a duplicate group marked as intentional.
+type AcknowledgedGroup struct {
+	Hash      string
+	Note      string // why the copies are kept (optional)
+	CreatedAt time.Time
+}
+
+// AcknowledgeGroup marks the duplicate group with this hash as intentional (or updates its note). The group no
+// longer appears in duplicate listings or reclaimable-space totals; stored summaries of scans that contain it
+// are dropped so they are recomputed without it.
+func AcknowledgeGroup(ctx context.Context, database *sql.DB, hash, note string) error {
+	return AcknowledgeGroups(ctx, database, []string{hash}, note)
+}
+
+// AcknowledgeGroups acknowledges several groups with the same note in one transaction.
+func AcknowledgeGroups(ctx context.Context, database *sql.DB, hashes []string, note string) error {
+	return setGroupsAcknowledged(ctx, database, hashes,
+		`INSERT INTO acknowledged_groups (hash, note, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (hash) DO UPDATE SET note = EXCLUDED.note`, note, NowUTC())
+}
+
+// UnacknowledgeGroup removes the mark: the group is listed and counted again.
+func UnacknowledgeGroup(ctx context.Context, database *sql.DB, hash string) error {
+	return setGroupsAcknowledged(ctx, database, []string{hash}, `DELETE FROM acknowledged_groups WHERE hash = $1`)
+}
+
+// setGroupsAcknowledged runs query for each hash ($1, followed by args) and drops the stored summaries of the
+// scans containing it.
+func setGroupsAcknowledged(ctx context.Context, database *sql.DB, hashes []string, query string, args ...any) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, query, append([]any{hash}, args...)...); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, dropSummariesForHash, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAcknowledgedGroup returns the mark of the group with this hash, or (nil, nil) when it is not acknowledged.
+func GetAcknowledgedGroup(ctx context.Context, database *sql.DB, hash string) (*AcknowledgedGroup, error) {
+	g := AcknowledgedGroup{Hash: hash}
+	err := database.QueryRowContext(ctx,
+		`SELECT note, created_at FROM acknowledged_groups WHERE hash = $1`, hash).Scan(&g.Note, &g.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// CountAcknowledgedGroups returns how many groups are acknowledged.
+func CountAcknowledgedGroups(ctx context.Context, database *sql.DB) (int64, error) {
+	var n int64
+	err := database.QueryRowContext(ctx, `SELECT COUNT(*) FROM acknowledged_groups`).Scan(&n)
+	return n, err
+}
+
+// ListAcknowledgedGroups returns all acknowledged groups, most recent first.
+func ListAcknowledgedGroups(ctx context.Context, database *sql.DB) ([]AcknowledgedGroup, error) {
+	rows, err := database.QueryContext(ctx, `SELECT hash, note, created_at FROM acknowledged_groups ORDER BY created_at DESC, hash`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AcknowledgedGroup
+	for rows.Next() {
+		var g AcknowledgedGroup
+		if err := rows.Scan(&g.Hash, &g.Note, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
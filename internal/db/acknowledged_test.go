@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcknowledgeGroup_hidesFromListingsAndTotals(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	add := func(path, hash string, inode int64) {
+		id, err := UpsertFile(ctx, database, folderID, path, 100, 1, inode, nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+	}
+	add("a", "kept", 1)
+	add("backup/a", "kept", 2)
+	add("b", "dup", 3)
+	add("c", "dup", 4)
+	if _, err := RefreshScanSummary(ctx, database, sn.ID); err != nil {
+		t.Fatalf("RefreshScanSummary: %v", err)
+	}
+
+	if err := AcknowledgeGroup(ctx, database, "kept", "backup copy"); err != nil {
+		t.Fatalf("AcknowledgeGroup: %v", err)
+	}
+	g, err := GetAcknowledgedGroup(ctx, database, "kept")
+	if err != nil || g == nil || g.Note != "backup copy" {
+		t.Fatalf("GetAcknowledgedGroup = %+v, %v; want note %q", g, err, "backup copy")
+	}
+	groups, err := DuplicateGroupsByHash(ctx, database, sn.ID)
+	if err != nil {
+		t.Fatalf("DuplicateGroupsByHash: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Hash != "dup" {
+		t.Errorf("DuplicateGroupsByHash = %+v, want only group dup", groups)
+	}
+	p, err := ProjectSavings(ctx, database, []int64{sn.ID})
+	if err != nil {
+		t.Fatalf("ProjectSavings: %v", err)
+	}
+	if p.Groups != 1 || p.DeleteSavings != 100 {
+		t.Errorf("ProjectSavings = %+v, want 1 group and 100 bytes", *p)
+	}
+	if _, err := GetScanSummary(ctx, database, sn.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetScanSummary after acknowledge err = %v, want sql.ErrNoRows", err)
+	}
+	sum, err := ScanSummaryOrRefresh(ctx, database, sn.ID)
+	if err != nil {
+		t.Fatalf("ScanSummaryOrRefresh: %v", err)
+	}
+	if sum.Savings.Groups != 1 {
+		t.Errorf("summary groups = %d, want 1", sum.Savings.Groups)
+	}
+
+	if err := UnacknowledgeGroup(ctx, database, "kept"); err != nil {
+		t.Fatalf("UnacknowledgeGroup: %v", err)
+	}
+	if g, err := GetAcknowledgedGroup(ctx, database, "kept"); err != nil || g != nil {
+		t.Errorf("GetAcknowledgedGroup after unacknowledge = %+v, %v; want nil", g, err)
+	}
+	groups, _ = DuplicateGroupsByHash(ctx, database, sn.ID)
+	if len(groups) != 2 {
+		t.Errorf("DuplicateGroupsByHash after unacknowledge = %d groups, want 2", len(groups))
+	}
+}
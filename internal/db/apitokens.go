@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TokenScope is what an API token may do: read-only tokens only look; admin tokens may also start scans,
+// link or delete files and change settings (as far as their user's role allows).
+type TokenScope string
+
+const (
+	TokenScopeRead  TokenScope = "read"
+	TokenScopeAdmin TokenScope = "admin"
+)
+
+// APITokenPrefix starts every API token, so a leaked one is easy to recognise.
+const APITokenPrefix = "ditto_"
+
+var (
+	// ErrAPITokenInvalid is returned by GetAPIToken for an unknown or revoked token.
+	ErrAPITokenInvalid = errors.New("API token is invalid or revoked")
+	// ErrUnknownTokenScope is returned by CreateAPIToken for a scope it does not know.
+	ErrUnknownTokenScope = errors.New("unknown token scope")
+)
+
+// APIToken lets a script call ditto with an Authorization: Bearer header.
+type APIToken struct {
+	ID         int64
+	Name       string // what the token is for, e.g. "home assistant"
+	Scope      TokenScope
+	UserID     *int64 // user the token acts for; nil on a single-user instance
+	UserName   string // set by ListAPITokens
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// CreateAPIToken creates a token acting for userID (nil on a single-user instance) and returns it. The token
+// is not stored (only its hash), so it can only be shown now.
+func CreateAPIToken(ctx context.Context, database *sql.DB, name string, scope TokenScope, userID *int64) (string, *APIToken, error) {
+	if scope != TokenScopeRead && scope != TokenScopeAdmin {
+		return "", nil, ErrUnknownTokenScope
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("token name is required")
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token = APITokenPrefix + token
+	t := &APIToken{Name: name, Scope: scope, UserID: userID, CreatedAt: NowUTC()}
+	err = database.QueryRowContext(ctx,
+		`INSERT INTO api_tokens (token_hash, name, scope, user_id, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		hashToken(token), t.Name, t.Scope, t.UserID, t.CreatedAt).Scan(&t.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, t, nil
+}
+
+// GetAPIToken returns the token and records that it was used, or returns ErrAPITokenInvalid.
+func GetAPIToken(ctx context.Context, database *sql.DB, token string) (*APIToken, error) {
+	var t APIToken
+	var userID sql.NullInt64
+	err := database.QueryRowContext(ctx,
+		`UPDATE api_tokens SET last_used_at = $2 WHERE token_hash = $1
+		 RETURNING id, name, scope, user_id, created_at, last_used_at`,
+		hashToken(token), NowUTC()).Scan(&t.ID, &t.Name, &t.Scope, &userID, &t.CreatedAt, &t.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPITokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		t.UserID = &userID.Int64
+	}
+	return &t, nil
+}
+
+// ListAPITokens returns all API tokens with their user's name, newest first.
+func ListAPITokens(ctx context.Context, database *sql.DB) ([]APIToken, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT t.id, t.name, t.scope, t.user_id, COALESCE(u.name, ''), t.created_at, t.last_used_at
+		 FROM api_tokens t LEFT JOIN users u ON u.id = t.user_id
+		 ORDER BY t.created_at DESC, t.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []APIToken
+	for rows.Next() {
+		var t APIToken
+		var userID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.Name, &t.Scope, &userID, &t.UserName, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			t.UserID = &userID.Int64
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAPIToken revokes a token. Requests carrying it are refused from then on.
+func DeleteAPIToken(ctx context.Context, database *sql.DB, id int64) error {
+	_, err := database.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAPITokens_createLookUpAndRevoke(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	alice, _ := GetOrCreateUser(ctx, database, "alice", RoleAdmin)
+	token, created, err := CreateAPIToken(ctx, database, " home assistant ", TokenScopeRead, &alice.ID)
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	if !strings.HasPrefix(token, APITokenPrefix) || created.Name != "home assistant" {
+		t.Errorf("token %q, %+v; want prefix %q and a trimmed name", token, created, APITokenPrefix)
+	}
+	if _, _, err := CreateAPIToken(ctx, database, "x", "root", nil); !errors.Is(err, ErrUnknownTokenScope) {
+		t.Errorf("CreateAPIToken(unknown scope) err = %v, want ErrUnknownTokenScope", err)
+	}
+
+	got, err := GetAPIToken(ctx, database, token)
+	if err != nil || got.ID != created.ID || got.Scope != TokenScopeRead || got.UserID == nil || *got.UserID != alice.ID || got.LastUsedAt == nil {
+		t.Fatalf("GetAPIToken = %+v, %v; want token %d for alice, marked used", got, err, created.ID)
+	}
+	if _, err := GetAPIToken(ctx, database, token+"x"); !errors.Is(err, ErrAPITokenInvalid) {
+		t.Errorf("GetAPIToken(wrong token) err = %v, want ErrAPITokenInvalid", err)
+	}
+
+	list, err := ListAPITokens(ctx, database)
+	if err != nil || len(list) != 1 || list[0].UserName != "alice" {
+		t.Fatalf("ListAPITokens = %+v, %v; want alice's token", list, err)
+	}
+	if err := DeleteAPIToken(ctx, database, created.ID); err != nil {
+		t.Fatalf("DeleteAPIToken: %v", err)
+	}
+	if _, err := GetAPIToken(ctx, database, token); !errors.Is(err, ErrAPITokenInvalid) {
+		t.Errorf("GetAPIToken(revoked) err = %v, want ErrAPITokenInvalid", err)
+	}
+}
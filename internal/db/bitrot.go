@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// BitrotFinding is a file whose content no longer matches its stored hash although its size and mtime are
+// unchanged: possible silent corruption (or a tool that restored the mtime after writing).
+type BitrotFinding struct {
+	ID           int64
+	FileID       int64
+	Path         string // full path
+	Size         int64
+	MTime        int64
+	ExpectedHash string     // hash stored when the file was last read
+	ActualHash   string     // hash read by the verification scan
+	HashedAt     *time.Time // when the expected hash was computed
+	DetectedAt   time.Time
+}
+
+// SetScanVerify makes the scan a verification scan: after its hash phase, files unchanged since their last
+// hash are read again and compared (see VerifyCandidates).
+func SetScanVerify(ctx context.Context, database *sql.DB, scanID int64) error {
+	_, err := database.ExecContext(ctx, `UPDATE scans SET verify = true WHERE id = $1`, scanID)
+	return err
+}
+
+// VerifyCandidates returns up to limit files of the scan with id > afterID, in id order, whose stored hash
+// predates the scan: the walk found their size and mtime unchanged, so their content should still match it.
+// Path is the full path. Page through all candidates by passing the last id returned.
+func VerifyCandidates(ctx context.Context, database *sql.DB, scanID, afterID int64, limit int) ([]File, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, $1::bigint, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id JOIN scans s ON s.id = fs.scan_id
+		 WHERE fs.scan_id = $1 AND f.id > $2 AND f.hash_status = 'done' AND f.hash IS NOT NULL AND f.hashed_at < s.started_at
+		 ORDER BY f.id LIMIT $3`,
+		scanID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
+
+// ResetScanVerify deletes the scan's findings and verify errors and clears its verify result, so an
+// interrupted verification starts over.
+func ResetScanVerify(ctx context.Context, database *sql.DB, scanID int64) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM bitrot_findings WHERE scan_id = $1`, scanID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scan_errors WHERE scan_id = $1 AND phase = $2`, scanID, PhaseVerify); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE scans SET verify_completed_at = NULL, verified_file_count = NULL WHERE id = $1`, scanID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertBitrotFinding records a hash mismatch found by the scan.
+func InsertBitrotFinding(ctx context.Context, database *sql.DB, scanID int64, f BitrotFinding) error {
+	at := f.DetectedAt
+	if at.IsZero() {
+		at = NowUTC()
+	}
+	_, err := database.ExecContext(ctx,
+		`INSERT INTO bitrot_findings (scan_id, file_id, path, size, mtime, expected_hash, actual_hash, hashed_at, detected_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		scanID, f.FileID, f.Path, f.Size, f.MTime, f.ExpectedHash, f.ActualHash, f.HashedAt, at)
+	return err
+}
+
+// UpdateScanVerifyCompletedAt records the end of the scan's verification and how many files it read.
+func UpdateScanVerifyCompletedAt(ctx context.Context, database *sql.DB, scanID, verifiedFileCount int64) error {
+	_, err := database.ExecContext(ctx,
+		`UPDATE scans SET verify_completed_at = $1, verified_file_count = $2 WHERE id = $3`,
+		NowUTC(), verifiedFileCount, scanID)
+	return err
+}
+
+// CountBitrotFindings returns how many mismatches the scan found.
+func CountBitrotFindings(ctx context.Context, database *sql.DB, scanID int64) (int64, error) {
+	var n int64
+	err := database.QueryRowContext(ctx, `SELECT COUNT(*) FROM bitrot_findings WHERE scan_id = $1`, scanID).Scan(&n)
+	return n, err
+}
+
+// ListBitrotFindings returns up to limit mismatches of the scan in the order they were found.
+func ListBitrotFindings(ctx context.Context, database *sql.DB, scanID int64, limit int) ([]BitrotFinding, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT id, file_id, path, size, mtime, expected_hash, actual_hash, hashed_at, detected_at
+		 FROM bitrot_findings WHERE scan_id = $1 ORDER BY id LIMIT $2`,
+		scanID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BitrotFinding
+	for rows.Next() {
+		var f BitrotFinding
+		var hashedAt sql.NullTime
+		if err := rows.Scan(&f.ID, &f.FileID, &f.Path, &f.Size, &f.MTime, &f.ExpectedHash, &f.ActualHash, &hashedAt, &f.DetectedAt); err != nil {
+			return nil, err
+		}
+		if hashedAt.Valid {
+			f.HashedAt = &hashedAt.Time
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
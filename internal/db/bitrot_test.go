@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVerifyCandidates_onlyFilesHashedBeforeTheScan(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	old, _ := UpsertFile(ctx, database, folderID, "old", 10, 1, 1, nil)
+	_ = UpdateFileHash(ctx, database, old, "h-old", time.Now().Add(-time.Hour))
+	scan, _ := CreateScan(ctx, database, folderID)
+	fresh, _ := UpsertFile(ctx, database, folderID, "fresh", 10, 1, 2, nil) // hashed by this scan
+	_ = UpdateFileHash(ctx, database, fresh, "h-fresh", time.Now().Add(time.Hour))
+	pending, _ := UpsertFile(ctx, database, folderID, "pending", 10, 1, 3, nil)
+	for _, id := range []int64{old, fresh, pending} {
+		_ = InsertFileScan(ctx, database, id, scan.ID)
+	}
+
+	got, err := VerifyCandidates(ctx, database, scan.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("VerifyCandidates: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != old || got[0].Path != "/data/old" {
+		t.Fatalf("VerifyCandidates = %+v, want only /data/old", got)
+	}
+	if more, _ := VerifyCandidates(ctx, database, scan.ID, old, 10); len(more) != 0 {
+		t.Errorf("after last id: %+v, want none", more)
+	}
+}
+
+func TestBitrotFindings_insertListAndReset(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	scan, _ := CreateScan(ctx, database, folderID)
+	_ = SetScanVerify(ctx, database, scan.ID)
+	f := BitrotFinding{FileID: 7, Path: "/data/a", Size: 10, MTime: 1, ExpectedHash: "x", ActualHash: "y"}
+	if err := InsertBitrotFinding(ctx, database, scan.ID, f); err != nil {
+		t.Fatalf("InsertBitrotFinding: %v", err)
+	}
+	if err := UpdateScanVerifyCompletedAt(ctx, database, scan.ID, 5); err != nil {
+		t.Fatalf("UpdateScanVerifyCompletedAt: %v", err)
+	}
+	list, err := ListBitrotFindings(ctx, database, scan.ID, 10)
+	if err != nil || len(list) != 1 || list[0].ActualHash != "y" || list[0].HashedAt != nil || list[0].DetectedAt.IsZero() {
+		t.Fatalf("ListBitrotFindings = %+v, %v", list, err)
+	}
+	sn, _ := GetScan(ctx, database, scan.ID)
+	if !sn.Verify || sn.VerifyCompletedAt == nil || *sn.VerifiedFileCount != 5 {
+		t.Errorf("scan = %+v, want verify completed with 5 files", sn)
+	}
+
+	if err := ResetScanVerify(ctx, database, scan.ID); err != nil {
+		t.Fatalf("ResetScanVerify: %v", err)
+	}
+	if n, _ := CountBitrotFindings(ctx, database, scan.ID); n != 0 {
+		t.Errorf("after reset: %d findings, want 0", n)
+	}
+	if sn, _ := GetScan(ctx, database, scan.ID); sn.VerifyCompletedAt != nil || !sn.Verify {
+		t.Errorf("after reset: %+v, want verify pending", sn)
+	}
+}
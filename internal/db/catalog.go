@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SetScanCatalog makes the scan a catalog of the removable drive labelled label (see offline.Catalog), with the
+// drive's filesystem UUID when known. The scan itself runs like any other.
+func SetScanCatalog(ctx context.Context, database *sql.DB, scanID int64, label, uuid string) error {
+	_, err := database.ExecContext(ctx, `UPDATE scans SET catalog_label = $1, catalog_uuid = $2 WHERE id = $3`, label, uuid, scanID)
+	return err
+}
+
+// offlineMediaFolders selects the folders on offline media, for conditions on f.folder_id.
+const offlineMediaFolders = `(SELECT id FROM folders WHERE media_label <> '')`
+
+// HashGroupMedia returns, for each of the hashes with files in the given scans, the distinct media labels of
+// those files' folders, sorted ("" stands for online storage). A group with "" and a label has a copy both on
+// online storage and in the catalog of an offline drive.
+func HashGroupMedia(ctx context.Context, database *sql.DB, scanIDs []int64, hashes []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	if len(scanIDs) == 0 || len(hashes) == 0 {
+		return out, nil
+	}
+	args := idSlice(scanIDs)
+	for _, h := range hashes {
+		args = append(args, h)
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT DISTINCT f.hash, fo.media_label
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON fo.id = f.folder_id
+		 WHERE fs.scan_id IN (`+placeholders(len(scanIDs), 1)+`) AND f.hash_status = 'done'
+		   AND f.hash IN (`+placeholders(len(hashes), len(scanIDs)+1)+`)
+		 ORDER BY f.hash, fo.media_label`, args...) // #nosec G202 -- placeholders only; args passed separately
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h, label string
+		if err := rows.Scan(&h, &label); err != nil {
+			return nil, err
+		}
+		out[h] = append(out[h], label)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOfflineOnlineGroups(t *testing.T) {
+	ctx := context.Background()
+	database := TestPostgresDB(t)
+	nasID, _ := AddFolder(ctx, database, "/nas")
+	driveID, _ := AddFolder(ctx, database, "/mnt/usb")
+	if err := UpdateFolderMedia(ctx, database, driveID, "backup-2019", ""); err != nil {
+		t.Fatalf("UpdateFolderMedia: %v", err)
+	}
+	nas, _ := CreateScan(ctx, database, nasID)
+	drive, _ := CreateScan(ctx, database, driveID)
+	if err := SetScanCatalog(ctx, database, drive.ID, "backup-2019", "1234-ABCD"); err != nil {
+		t.Fatalf("SetScanCatalog: %v", err)
+	}
+	if sn, _ := GetScan(ctx, database, drive.ID); sn.CatalogLabel != "backup-2019" || sn.CatalogUUID != "1234-ABCD" {
+		t.Errorf("catalog scan = %q %q, want backup-2019 1234-ABCD", sn.CatalogLabel, sn.CatalogUUID)
+	}
+	dev := int64(1)
+	inode := int64(0)
+	add := func(folderID, scanID int64, path, hash string) {
+		inode++
+		id, err := UpsertFile(ctx, database, folderID, path, 100, 0, inode, &dev)
+		if err != nil {
+			t.Fatalf("UpsertFile %s: %v", path, err)
+		}
+		_ = InsertFileScan(ctx, database, id, scanID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+	}
+	// "both" has a copy on the drive and one on the NAS; "nas" and "drive" are duplicated on one side only.
+	add(nasID, nas.ID, "a.jpg", "both")
+	add(driveID, drive.ID, "a.jpg", "both")
+	add(nasID, nas.ID, "b.jpg", "nas")
+	add(nasID, nas.ID, "b2.jpg", "nas")
+	add(driveID, drive.ID, "c.jpg", "drive")
+	add(driveID, drive.ID, "c2.jpg", "drive")
+	scanIDs := []int64{nas.ID, drive.ID}
+
+	groups, err := DuplicateGroupsByHashPaginatedAcrossScans(ctx, database, scanIDs, DuplicateGroupFilter{OfflineOnline: true}, 10, 0)
+	if err != nil || len(groups) != 1 || groups[0].Hash != "both" {
+		t.Errorf("offline and online groups = %+v, %v; want only \"both\"", groups, err)
+	}
+	if n, err := DuplicateGroupsByHashCountAcrossScans(ctx, database, scanIDs, DuplicateGroupFilter{}); err != nil || n != 3 {
+		t.Errorf("all groups = %d, %v; want 3", n, err)
+	}
+	media, err := HashGroupMedia(ctx, database, scanIDs, []string{"both", "nas"})
+	if err != nil {
+		t.Fatalf("HashGroupMedia: %v", err)
+	}
+	if got := media["both"]; len(got) != 2 || got[0] != "" || got[1] != "backup-2019" {
+		t.Errorf("media of both = %q, want [\"\" backup-2019]", got)
+	}
+	if got := media["nas"]; len(got) != 1 || got[0] != "" {
+		t.Errorf("media of nas = %q, want [\"\"]", got)
+	}
+}
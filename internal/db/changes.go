@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ContentChange is a file present in two scans of the same folder whose content hash differs between them.
+type ContentChange struct {
+	FileID  int64
+	Path    string // full path (folder path || '/' || file path)
+	OldSize int64
+	NewSize int64
+	OldHash string
+	NewHash string
+}
+
+// SnapshotScanHashes copies each file's current size, mtime, and hash into the scan's file_scan rows.
+// Called at the end of the hash phase so later scans can be compared against this one
+// (files.hash is overwritten when a file is re-hashed; the ledger keeps what each scan saw).
+// Locked scans are left untouched.
+func SnapshotScanHashes(ctx context.Context, database *sql.DB, scanID int64) error {
+	_, err := database.ExecContext(ctx,
+		`UPDATE file_scan fs SET size = f.size, mtime = f.mtime, hash = f.hash
+		 FROM files f, scans s
+		 WHERE fs.file_id = f.id AND fs.scan_id = $1 AND s.id = fs.scan_id AND s.locked_at IS NULL`,
+		scanID)
+	return err
+}
+
+// PreviousCompletedScan returns the most recent scan of the same folder that started before scanID and whose
+// hash phase completed. Returns (nil, nil) when there is none.
+func PreviousCompletedScan(ctx context.Context, database *sql.DB, scanID int64) (*Scan, error) {
+	var prevID int64
+	err := database.QueryRowContext(ctx,
+		`SELECT p.id FROM scans p JOIN scans s ON p.folder_id = s.folder_id
+		 WHERE s.id = $1 AND p.id <> s.id AND p.started_at <= s.started_at AND p.hash_completed_at IS NOT NULL
+		 ORDER BY p.started_at DESC, p.id DESC LIMIT 1`,
+		scanID).Scan(&prevID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return GetScan(ctx, database, prevID)
+}
+
+// ContentChangesBetweenScans returns files present in both scans with a recorded hash in each, where the hashes differ.
+// Ordered by path. limit <= 0 means no limit.
+func ContentChangesBetweenScans(ctx context.Context, database *sql.DB, oldScanID, newScanID int64, limit int) ([]ContentChange, error) {
+	q := `SELECT f.id, (fo.path || '/' || f.path), COALESCE(o.size, 0), COALESCE(n.size, 0), o.hash, n.hash
+		  FROM file_scan n
+		  JOIN file_scan o ON o.file_id = n.file_id AND o.scan_id = $1
+		  JOIN files f ON f.id = n.file_id
+		  JOIN folders fo ON f.folder_id = fo.id
+		  WHERE n.scan_id = $2 AND o.hash IS NOT NULL AND n.hash IS NOT NULL AND o.hash <> n.hash
+		  ORDER BY f.path`
+	args := []interface{}{oldScanID, newScanID}
+	if limit > 0 {
+		q += fmt.Sprintf(" LIMIT $%d", len(args)+1) // #nosec G202 -- placeholder index only
+		args = append(args, limit)
+	}
+	rows, err := database.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ContentChange
+	for rows.Next() {
+		var c ContentChange
+		if err := rows.Scan(&c.FileID, &c.Path, &c.OldSize, &c.NewSize, &c.OldHash, &c.NewHash); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// NewDuplicateGroups counts the duplicate groups of scanID whose content was not duplicated in prevScanID
+// (0 = no previous scan: every group is new), and the bytes their extra copies waste. Both scans must have
+// their hashes snapshotted (SnapshotScanHashes). Copies hardlinked to each other count once; acknowledged
+// groups are left out.
+func NewDuplicateGroups(ctx context.Context, database *sql.DB, scanID, prevScanID int64) (groups, wastedBytes int64, err error) {
+	err = database.QueryRowContext(ctx,
+		`WITH cur AS (
+			SELECT fs.hash, MAX(fs.size) AS size, COUNT(DISTINCT (COALESCE(f.device_id, -1), f.inode)) AS inodes
+			FROM file_scan fs JOIN files f ON f.id = fs.file_id
+			WHERE fs.scan_id = $1 AND fs.hash IS NOT NULL AND fs.hash NOT IN (SELECT hash FROM acknowledged_groups)
+			GROUP BY fs.hash HAVING COUNT(*) > 1
+		), prev AS (
+			SELECT hash FROM file_scan WHERE scan_id = $2 AND hash IS NOT NULL GROUP BY hash HAVING COUNT(*) > 1
+		)
+		SELECT COUNT(*), COALESCE(SUM(size * (inodes - 1)), 0) FROM cur WHERE hash NOT IN (SELECT hash FROM prev)`,
+		scanID, prevScanID).Scan(&groups, &wastedBytes)
+	return groups, wastedBytes, err
+}
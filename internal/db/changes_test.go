@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestContentChangesBetweenScans(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	scan1, _ := CreateScan(ctx, database, folderID)
+	same, _ := UpsertFile(ctx, database, folderID, "same.txt", 10, 1, 1, nil)
+	edited, _ := UpsertFile(ctx, database, folderID, "edited.txt", 10, 1, 2, nil)
+	_ = InsertFileScan(ctx, database, same, scan1.ID)
+	_ = InsertFileScan(ctx, database, edited, scan1.ID)
+	_ = UpdateFileHash(ctx, database, same, "h-same", time.Now().UTC())
+	_ = UpdateFileHash(ctx, database, edited, "h-old", time.Now().UTC())
+	if err := SnapshotScanHashes(ctx, database, scan1.ID); err != nil {
+		t.Fatalf("SnapshotScanHashes: %v", err)
+	}
+	_ = UpdateScanCompletedAt(ctx, database, scan1.ID, 2, 0)
+	_ = UpdateScanHashCompletedAt(ctx, database, scan1.ID, 2, 20, 0, 0)
+
+	scan2, _ := CreateScan(ctx, database, folderID)
+	prev, err := PreviousCompletedScan(ctx, database, scan2.ID)
+	if err != nil {
+		t.Fatalf("PreviousCompletedScan: %v", err)
+	}
+	if prev == nil || prev.ID != scan1.ID {
+		t.Fatalf("PreviousCompletedScan = %v, want scan %d", prev, scan1.ID)
+	}
+	_, _ = UpsertFile(ctx, database, folderID, "same.txt", 10, 1, 1, nil)
+	_, _ = UpsertFile(ctx, database, folderID, "edited.txt", 12, 5, 2, nil) // size and mtime changed
+	_ = InsertFileScan(ctx, database, same, scan2.ID)
+	_ = InsertFileScan(ctx, database, edited, scan2.ID)
+	_ = UpdateFileHash(ctx, database, edited, "h-new", time.Now().UTC())
+	_ = SnapshotScanHashes(ctx, database, scan2.ID)
+
+	changes, err := ContentChangesBetweenScans(ctx, database, scan1.ID, scan2.ID, 0)
+	if err != nil {
+		t.Fatalf("ContentChangesBetweenScans: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1: %+v", len(changes), changes)
+	}
+	c := changes[0]
+	if c.FileID != edited || c.OldHash != "h-old" || c.NewHash != "h-new" || c.OldSize != 10 || c.NewSize != 12 {
+		t.Errorf("change = %+v", c)
+	}
+}
+
+func TestNewDuplicateGroups(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	add := func(scanID int64, path, hash string, inode int64) {
+		id, _ := UpsertFile(ctx, database, folderID, path, 10, 1, inode, nil)
+		_ = InsertFileScan(ctx, database, id, scanID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+	}
+	scan1, _ := CreateScan(ctx, database, folderID)
+	add(scan1.ID, "a", "old", 1)
+	add(scan1.ID, "b", "old", 2)
+	_ = SnapshotScanHashes(ctx, database, scan1.ID)
+
+	scan2, _ := CreateScan(ctx, database, folderID)
+	add(scan2.ID, "a", "old", 1)
+	add(scan2.ID, "b", "old", 2)
+	add(scan2.ID, "c", "new", 3)
+	add(scan2.ID, "d", "new", 4)
+	add(scan2.ID, "e", "new", 5)
+	_ = SnapshotScanHashes(ctx, database, scan2.ID)
+
+	groups, wasted, err := NewDuplicateGroups(ctx, database, scan2.ID, scan1.ID)
+	if err != nil {
+		t.Fatalf("NewDuplicateGroups: %v", err)
+	}
+	if groups != 1 || wasted != 20 {
+		t.Errorf("NewDuplicateGroups = %d groups, %d bytes; want 1, 20", groups, wasted)
+	}
+	if groups, _, _ := NewDuplicateGroups(ctx, database, scan2.ID, 0); groups != 2 {
+		t.Errorf("NewDuplicateGroups without previous scan = %d groups, want 2", groups)
+	}
+}
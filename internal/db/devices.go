@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// legacyDeviceKey is the key of a device known only by its st_dev number: a filesystem without a stable key, or
+// one recorded before devices had keys (migration 0046).
+func legacyDeviceKey(stDev int64) string {
+	return "dev:" + strconv.FormatInt(stDev, 10)
+}
+
+// ResolveDevice returns the id that files on device stDev store as their device_id. key names the filesystem
+// across remounts (volume.DeviceKey); with an empty key the device is known by stDev alone, as before. The first
+// time a key is seen, a device known only by the same stDev takes it over when it holds files of folderID (or is
+// that folder's recorded device), so files scanned before keys existed keep matching their hardlinks and earlier
+// hashes. st_dev numbers are reused across filesystems, so a legacy device of other folders is left alone and the
+// key gets a device of its own.
+func ResolveDevice(ctx context.Context, database *sql.DB, folderID, stDev int64, key string) (int64, error) {
+	now := NowUTC()
+	legacy := legacyDeviceKey(stDev)
+	if key == "" {
+		key = legacy
+	} else {
+		var id int64
+		err := database.QueryRowContext(ctx,
+			`UPDATE devices d SET uuid = $1, st_dev = $2, last_seen_at = $3
+			 WHERE d.uuid = $4 AND NOT EXISTS (SELECT 1 FROM devices WHERE uuid = $1)
+			   AND (EXISTS (SELECT 1 FROM files WHERE device_id = d.id AND folder_id = $5)
+			     OR EXISTS (SELECT 1 FROM folders WHERE id = $5 AND device_id = d.id))
+			 RETURNING d.id`, key, stDev, now, legacy, folderID).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+	}
+	var id int64
+	err := database.QueryRowContext(ctx,
+		`INSERT INTO devices (uuid, st_dev, created_at, last_seen_at) VALUES ($1, $2, $3, $3)
+		 ON CONFLICT (uuid) DO UPDATE SET st_dev = EXCLUDED.st_dev, last_seen_at = EXCLUDED.last_seen_at
+		 RETURNING id`, key, stDev, now).Scan(&id)
+	return id, err
+}
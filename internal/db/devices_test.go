@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveDevice_remountKeepsID(t *testing.T) {
+	ctx := context.Background()
+	database := TestPostgresDB(t)
+	folderID, _ := AddFolder(ctx, database, "/data")
+
+	disk, err := ResolveDevice(ctx, database, folderID, 2049, "3f1c-uuid")
+	if err != nil {
+		t.Fatalf("ResolveDevice: %v", err)
+	}
+	again, err := ResolveDevice(ctx, database, folderID, 2065, "3f1c-uuid")
+	if err != nil || again != disk {
+		t.Errorf("after a remount under another st_dev: id = %d, %v; want %d", again, err, disk)
+	}
+	other, err := ResolveDevice(ctx, database, folderID, 2049, "")
+	if err != nil || other == disk {
+		t.Errorf("a filesystem without a key now at the old st_dev: id = %d, %v; want a device of its own", other, err)
+	}
+	if same, err := ResolveDevice(ctx, database, folderID, 2049, ""); err != nil || same != other {
+		t.Errorf("same keyless device again: id = %d, %v; want %d", same, err, other)
+	}
+	var stDev int64
+	if err := database.QueryRowContext(ctx, "SELECT st_dev FROM devices WHERE id = $1", disk).Scan(&stDev); err != nil || stDev != 2065 {
+		t.Errorf("st_dev = %d, %v; want the latest, 2065", stDev, err)
+	}
+}
+
+func TestResolveDevice_adoptsLegacyDevice(t *testing.T) {
+	ctx := context.Background()
+	database := TestPostgresDB(t)
+	// As migration 0046 leaves files scanned before devices had keys.
+	var legacy int64
+	if err := database.QueryRowContext(ctx,
+		"INSERT INTO devices (uuid, st_dev) VALUES ('dev:2049', 2049) RETURNING id").Scan(&legacy); err != nil {
+		t.Fatalf("insert legacy device: %v", err)
+	}
+	folderID, _ := AddFolder(ctx, database, "/data")
+	fileID, _ := UpsertFile(ctx, database, folderID, "a.txt", 1, 0, 7, &legacy)
+
+	id, err := ResolveDevice(ctx, database, folderID, 2049, "3f1c-uuid")
+	if err != nil || id != legacy {
+		t.Fatalf("ResolveDevice = %d, %v; want the legacy device %d adopted", id, err, legacy)
+	}
+	var uuid string
+	if err := database.QueryRowContext(ctx, "SELECT uuid FROM devices WHERE id = $1", id).Scan(&uuid); err != nil || uuid != "3f1c-uuid" {
+		t.Errorf("uuid = %q, %v; want 3f1c-uuid", uuid, err)
+	}
+	f, err := GetFile(ctx, database, fileID)
+	if err != nil || f.DeviceID == nil || *f.DeviceID != legacy {
+		t.Errorf("file device = %+v, %v; want %d unchanged", f, err, legacy)
+	}
+	// Once the key is known, another filesystem at the same st_dev gets its own device.
+	if other, err := ResolveDevice(ctx, database, folderID, 2049, "9a2e-uuid"); err != nil || other == legacy {
+		t.Errorf("second key at st_dev 2049: id = %d, %v; want a new device", other, err)
+	}
+}
+
+func TestResolveDevice_legacyDeviceOnlyAdoptedByItsFolder(t *testing.T) {
+	ctx := context.Background()
+	database := TestPostgresDB(t)
+	var legacy int64
+	if err := database.QueryRowContext(ctx,
+		"INSERT INTO devices (uuid, st_dev) VALUES ('dev:2049', 2049) RETURNING id").Scan(&legacy); err != nil {
+		t.Fatalf("insert legacy device: %v", err)
+	}
+	photos, _ := AddFolder(ctx, database, "/mnt/photos")
+	backup, _ := AddFolder(ctx, database, "/mnt/backup")
+	if _, err := UpsertFile(ctx, database, photos, "a.jpg", 1, 0, 7, &legacy); err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+
+	// Another disk, now mounted under the st_dev the photos disk had, is scanned first.
+	other, err := ResolveDevice(ctx, database, backup, 2049, "9a2e-uuid")
+	if err != nil || other == legacy {
+		t.Fatalf("backup disk at st_dev 2049: id = %d, %v; want a device of its own, not %d", other, err, legacy)
+	}
+	id, err := ResolveDevice(ctx, database, photos, 2049, "3f1c-uuid")
+	if err != nil || id != legacy {
+		t.Errorf("photos disk at st_dev 2049: id = %d, %v; want the legacy device %d adopted", id, err, legacy)
+	}
+}
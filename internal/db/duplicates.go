@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // DuplicateGroupByHash is a group of files with the same content hash (duplicates).
@@ -21,9 +22,84 @@ type DuplicateGroupByInode struct {
 	Size     int64
 }
 
+// GroupSort orders duplicate-by-hash group listings.
+type GroupSort string
+
+const (
+	SortGroupTotal    GroupSort = ""          // total bytes of the group (default)
+	SortGroupWasted   GroupSort = "wasted"    // bytes of the extra copies: the group total minus one file
+	SortGroupCount    GroupSort = "count"     // number of files
+	SortGroupFileSize GroupSort = "file_size" // size of one file
+	SortGroupNewest   GroupSort = "newest"    // most recently modified member first
+)
+
+var groupSortOrder = map[GroupSort]string{
+	SortGroupTotal:    "SUM(f.size) DESC",
+	SortGroupWasted:   "SUM(f.size) - MAX(f.size) DESC",
+	SortGroupCount:    "COUNT(*) DESC, SUM(f.size) DESC",
+	SortGroupFileSize: "MAX(f.size) DESC",
+	SortGroupNewest:   "MAX(f.mtime) DESC",
+}
+
+// Valid reports whether s is a known sort.
+func (s GroupSort) Valid() bool {
+	_, ok := groupSortOrder[s]
+	return ok
+}
+
+// DuplicateGroupFilter narrows and orders a duplicate-by-hash group listing. The zero value lists every group,
+// largest total first.
+type DuplicateGroupFilter struct {
+	Sort       GroupSort
+	MinSize    int64  // only files of at least this many bytes (0: any)
+	PathPrefix string // only groups with a member under this directory (full path; "" or "/": any)
+	// PrefixOnly restricts the groups' members to PathPrefix as well: only files under it are counted, so a
+	// group is listed when it has at least two copies inside the subtree.
+	PrefixOnly bool
+	// OfflineOnline keeps only the groups with a copy in the catalog of an offline drive (a folder with a media
+	// label) and a copy on online storage.
+	OfflineOnline bool
+}
+
+// groupFilterSQL returns the WHERE and HAVING conditions (each starting with " AND ", or empty) and ORDER BY
+// for the filter over the given scans, with placeholders numbered from next; args are the values to append.
+// Files listed by two overlapping scans count once (see overlapCondition).
+func groupFilterSQL(ctx context.Context, database *sql.DB, scanIDs []int64, fl DuplicateGroupFilter, next int) (where, having, order string, args []interface{}, err error) {
+	order, ok := groupSortOrder[fl.Sort]
+	if !ok {
+		order = groupSortOrder[SortGroupTotal]
+	}
+	order += ", f.hash"
+	if fl.MinSize > 0 {
+		where += fmt.Sprintf(" AND f.size >= $%d", next+len(args))
+		args = append(args, fl.MinSize)
+	}
+	if strings.TrimRight(fl.PathPrefix, "/") != "" {
+		cond, cargs, err := pathPrefixCondition(ctx, database, scanIDs, fl.PathPrefix, next+len(args))
+		if err != nil {
+			return "", "", "", nil, err
+		}
+		args = append(args, cargs...)
+		if fl.PrefixOnly {
+			where += " AND " + cond
+		} else {
+			having += " AND bool_or(" + cond + ")"
+		}
+	}
+	if fl.OfflineOnline {
+		having += " AND bool_or(f.folder_id IN " + offlineMediaFolders + ") AND bool_or(f.folder_id NOT IN " + offlineMediaFolders + ")"
+	}
+	overlap, oargs, err := overlapCondition(ctx, database, scanIDs, next+len(args))
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	return where + overlap, having, order, append(args, oargs...), nil
+}
+
 // DuplicateGroupsByHash returns groups of files with the same hash (content duplicates) for the scan.
+// Here and in the other group listings and counts, acknowledged groups (AcknowledgeGroup) are left out.
 func DuplicateGroupsByHash(ctx context.Context, database *sql.DB, scanID int64) ([]DuplicateGroupByHash, error) {
-	return duplicateGroupsByHash(ctx, database, scanID, 0, 0)
+	return duplicateGroupsByHash(ctx, database, []int64{scanID}, DuplicateGroupFilter{}, 0, 0)
 }
 
 // DuplicateGroupsByHashCount returns the number of duplicate-by-hash groups for the scan.
@@ -32,25 +108,34 @@ func DuplicateGroupsByHashCount(ctx context.Context, database *sql.DB, scanID in
 	err := database.QueryRowContext(ctx,
 		`SELECT COUNT(*) FROM (
 			SELECT 1 FROM files f JOIN file_scan fs ON f.id = fs.file_id
-			WHERE fs.scan_id = $1 AND f.hash_status = 'done'
+			WHERE fs.scan_id = $1 AND f.hash_status = 'done' AND `+notAcknowledged+`
 			GROUP BY f.hash HAVING COUNT(*) > 1
 		) sub`,
 		scanID).Scan(&n)
 	return n, err
 }
 
-// DuplicateGroupsByHashPaginated returns duplicate-by-hash groups for the scan with limit and offset.
-func DuplicateGroupsByHashPaginated(ctx context.Context, database *sql.DB, scanID int64, limit, offset int) ([]DuplicateGroupByHash, error) {
-	return duplicateGroupsByHash(ctx, database, scanID, limit, offset)
+// DuplicateGroupsByHashPaginated returns duplicate-by-hash groups for the scan, narrowed and ordered by filter,
+// with limit and offset.
+func DuplicateGroupsByHashPaginated(ctx context.Context, database *sql.DB, scanID int64, filter DuplicateGroupFilter, limit, offset int) ([]DuplicateGroupByHash, error) {
+	return duplicateGroupsByHash(ctx, database, []int64{scanID}, filter, limit, offset)
 }
 
-func duplicateGroupsByHash(ctx context.Context, database *sql.DB, scanID int64, limit, offset int) ([]DuplicateGroupByHash, error) {
+func duplicateGroupsByHash(ctx context.Context, database *sql.DB, scanIDs []int64, filter DuplicateGroupFilter, limit, offset int) ([]DuplicateGroupByHash, error) {
+	if len(scanIDs) == 0 {
+		return nil, nil
+	}
+	args := idSlice(scanIDs)
+	where, having, order, fargs, err := groupFilterSQL(ctx, database, scanIDs, filter, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, fargs...)
 	q := `SELECT f.hash, COUNT(*), COALESCE(SUM(f.size), 0) FROM files f
 		  JOIN file_scan fs ON f.id = fs.file_id
-		  WHERE fs.scan_id = $1 AND f.hash_status = 'done'
-		  GROUP BY f.hash HAVING COUNT(*) > 1
-		  ORDER BY SUM(f.size) DESC`
-	args := []interface{}{scanID}
+		  WHERE fs.scan_id IN (` + placeholders(len(scanIDs), 1) + `) AND f.hash_status = 'done' AND ` + notAcknowledged + where + `
+		  GROUP BY f.hash HAVING COUNT(*) > 1` + having + `
+		  ORDER BY ` + order // #nosec G202 -- conditions and order are fixed strings; values are placeholders
 	if limit > 0 {
 		q += fmt.Sprintf(" LIMIT $%d", len(args)+1) // #nosec G202 -- placeholder index only, args passed separately
 		args = append(args, limit)
@@ -76,80 +161,238 @@ func duplicateGroupsByHash(ctx context.Context, database *sql.DB, scanID int64,
 	return groups, rows.Err()
 }
 
-// DuplicateGroupsByHashCountAcrossScans returns the number of duplicate-by-hash groups across the given scans.
-func DuplicateGroupsByHashCountAcrossScans(ctx context.Context, database *sql.DB, scanIDs []int64) (int64, error) {
+// DuplicateGroupsByHashCountAcrossScans returns the number of duplicate-by-hash groups across the given scans
+// that match filter (its Sort is ignored).
+func DuplicateGroupsByHashCountAcrossScans(ctx context.Context, database *sql.DB, scanIDs []int64, filter DuplicateGroupFilter) (int64, error) {
 	if len(scanIDs) == 0 {
 		return 0, nil
 	}
 	ph := placeholders(len(scanIDs), 1)
+	args := idSlice(scanIDs)
+	where, having, _, fargs, err := groupFilterSQL(ctx, database, scanIDs, filter, len(args)+1)
+	if err != nil {
+		return 0, err
+	}
+	args = append(args, fargs...)
 	q := `SELECT COUNT(*) FROM (
 		SELECT 1 FROM files f JOIN file_scan fs ON f.id = fs.file_id
-		WHERE fs.scan_id IN (` + ph + `) AND f.hash_status = 'done'
-		GROUP BY f.hash HAVING COUNT(*) > 1
-	) sub`
-	args := idSlice(scanIDs)
+		WHERE fs.scan_id IN (` + ph + `) AND f.hash_status = 'done' AND ` + notAcknowledged + where + `
+		GROUP BY f.hash HAVING COUNT(*) > 1` + having + `
+	) sub` // #nosec G202 -- conditions are fixed strings; values are placeholders
 	var n int64
-	err := database.QueryRowContext(ctx, q, args...).Scan(&n)
+	err = database.QueryRowContext(ctx, q, args...).Scan(&n)
 	return n, err
 }
 
-// DuplicateGroupsByHashPaginatedAcrossScans returns duplicate-by-hash groups across the given scans.
-func DuplicateGroupsByHashPaginatedAcrossScans(ctx context.Context, database *sql.DB, scanIDs []int64, limit, offset int) ([]DuplicateGroupByHash, error) {
+// DuplicateGroupsByHashPaginatedAcrossScans returns duplicate-by-hash groups across the given scans, narrowed
+// and ordered by filter, with limit and offset.
+func DuplicateGroupsByHashPaginatedAcrossScans(ctx context.Context, database *sql.DB, scanIDs []int64, filter DuplicateGroupFilter, limit, offset int) ([]DuplicateGroupByHash, error) {
+	return duplicateGroupsByHash(ctx, database, scanIDs, filter, limit, offset)
+}
+
+// FilesInHashGroupLimitAcrossScans returns up to limit files with the given hash in any of the given scans.
+func FilesInHashGroupLimitAcrossScans(ctx context.Context, database *sql.DB, scanIDs []int64, hash string, limit int) ([]File, error) {
 	if len(scanIDs) == 0 {
 		return nil, nil
 	}
 	ph := placeholders(len(scanIDs), 1)
-	q := `SELECT f.hash, COUNT(*), COALESCE(SUM(f.size), 0) FROM files f
-		  JOIN file_scan fs ON f.id = fs.file_id
-		  WHERE fs.scan_id IN (` + ph + `) AND f.hash_status = 'done'
-		  GROUP BY f.hash HAVING COUNT(*) > 1
-		  ORDER BY SUM(f.size) DESC` // #nosec G202 -- ph is placeholder count; args passed separately
 	args := idSlice(scanIDs)
+	args = append(args, hash)
+	overlap, oargs, err := overlapCondition(ctx, database, scanIDs, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, oargs...)
+	q := `SELECT f.id, fs.scan_id, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		  FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		  WHERE fs.scan_id IN (` + ph + `) AND f.hash_status = 'done' AND f.hash = $` + fmt.Sprint(len(scanIDs)+1) + overlap + ` ORDER BY fs.scan_id, f.path` // #nosec G202 -- ph, placeholder index and fixed conditions; args passed separately
 	if limit > 0 {
 		q += fmt.Sprintf(" LIMIT $%d", len(args)+1) // #nosec G202 -- placeholder index only
 		args = append(args, limit)
 	}
-	if offset > 0 {
-		q += fmt.Sprintf(" OFFSET $%d", len(args)+1) // #nosec G202 -- placeholder index only
-		args = append(args, offset)
-	}
 	rows, err := database.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var groups []DuplicateGroupByHash
-	for rows.Next() {
-		var g DuplicateGroupByHash
-		if err := rows.Scan(&g.Hash, &g.Count, &g.Size); err != nil {
-			return nil, err
-		}
-		groups = append(groups, g)
-	}
-	return groups, rows.Err()
+	return scanFiles(rows)
 }
 
-// FilesInHashGroupLimitAcrossScans returns up to limit files with the given hash in any of the given scans.
-func FilesInHashGroupLimitAcrossScans(ctx context.Context, database *sql.DB, scanIDs []int64, hash string, limit int) ([]File, error) {
+// FilesInHashGroupPage returns up to limit files with the given hash in any of the given scans, skipping
+// the first offset, in the order of FilesInHashGroupLimitAcrossScans. With a prefix, only files under it count
+// (see FilesInHashGroupUnderPrefix).
+func FilesInHashGroupPage(ctx context.Context, database *sql.DB, scanIDs []int64, hash, prefix string, limit, offset int) ([]File, error) {
 	if len(scanIDs) == 0 {
 		return nil, nil
 	}
-	ph := placeholders(len(scanIDs), 1)
+	where, args, err := hashGroupWhere(ctx, database, scanIDs, hash, prefix)
+	if err != nil {
+		return nil, err
+	}
 	q := `SELECT f.id, fs.scan_id, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
 		  FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
-		  WHERE fs.scan_id IN (` + ph + `) AND f.hash_status = 'done' AND f.hash = $` + fmt.Sprint(len(scanIDs)+1) + ` ORDER BY fs.scan_id, f.path` // #nosec G202 -- ph and placeholder index; args passed separately
+		  WHERE ` + where + `
+		  ORDER BY fs.scan_id, f.path
+		  LIMIT $` + fmt.Sprint(len(args)+1) + ` OFFSET $` + fmt.Sprint(len(args)+2) // #nosec G202 -- placeholders and fixed conditions; args passed separately
+	args = append(args, limit, offset)
+	rows, err := database.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
+
+// HashGroupStats returns how many files with the given hash are in any of the given scans (under prefix when
+// set) and how many devices they are on, counted as in HashGroupDevices. It sizes FilesInHashGroupPage.
+func HashGroupStats(ctx context.Context, database *sql.DB, scanIDs []int64, hash, prefix string) (files int64, devices int, err error) {
+	if len(scanIDs) == 0 {
+		return 0, 0, nil
+	}
+	where, args, err := hashGroupWhere(ctx, database, scanIDs, hash, prefix)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = database.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(DISTINCT COALESCE(f.device_id::text, 'file '||f.id::text))
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id
+		 WHERE `+where, args...).Scan(&files, &devices) // #nosec G202 -- placeholders and fixed conditions; args passed separately
+	return files, devices, err
+}
+
+// HashGroupFile returns the file with the given id if it is in the hash group of the given scans, nil if not.
+func HashGroupFile(ctx context.Context, database *sql.DB, scanIDs []int64, hash string, fileID int64) (*File, error) {
+	if len(scanIDs) == 0 {
+		return nil, nil
+	}
+	where, args, err := hashGroupWhere(ctx, database, scanIDs, hash, "")
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, fileID)
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, fs.scan_id, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE `+where+` AND f.id = $`+fmt.Sprint(len(args))+` ORDER BY fs.scan_id LIMIT 1`, args...) // #nosec G202 -- placeholders and fixed conditions; args passed separately
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files, err := scanFiles(rows)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	return &files[0], nil
+}
+
+// SameSizeOtherHash returns up to limit hashed files in the given scans that have the given size but not the
+// given hash: near-duplicates of a hash group that differ somewhere. Ordered like FilesInHashGroupPage.
+func SameSizeOtherHash(ctx context.Context, database *sql.DB, scanIDs []int64, size int64, hash string, limit int) ([]File, error) {
+	if len(scanIDs) == 0 {
+		return nil, nil
+	}
+	n := len(scanIDs)
+	args := idSlice(scanIDs)
+	args = append(args, size, hash, limit)
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, fs.scan_id, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id IN (`+placeholders(n, 1)+`) AND f.size = $`+fmt.Sprint(n+1)+`
+		   AND f.hash_status = 'done' AND f.hash <> $`+fmt.Sprint(n+2)+`
+		 ORDER BY fs.scan_id, f.path LIMIT $`+fmt.Sprint(n+3), args...) // #nosec G202 -- placeholders only; args passed separately
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
+
+// hashGroupWhere returns the condition selecting the hashed files with the given hash in any of scanIDs (joined
+// as f and fs), under prefix when set and without the rows of overlapping scans (see overlapCondition), and its
+// args (numbered from $1).
+func hashGroupWhere(ctx context.Context, database *sql.DB, scanIDs []int64, hash, prefix string) (string, []interface{}, error) {
 	args := idSlice(scanIDs)
 	args = append(args, hash)
-	if limit > 0 {
-		q += fmt.Sprintf(" LIMIT $%d", len(args)+1) // #nosec G202 -- placeholder index only
-		args = append(args, limit)
+	cond := "TRUE"
+	if prefix != "" {
+		c, cargs, err := pathPrefixCondition(ctx, database, scanIDs, prefix, len(args)+1)
+		if err != nil {
+			return "", nil, err
+		}
+		cond = c
+		args = append(args, cargs...)
 	}
-	rows, err := database.QueryContext(ctx, q, args...)
+	overlap, oargs, err := overlapCondition(ctx, database, scanIDs, len(args)+1)
+	if err != nil {
+		return "", nil, err
+	}
+	args = append(args, oargs...)
+	return `fs.scan_id IN (` + placeholders(len(scanIDs), 1) + `) AND f.hash_status = 'done'
+		    AND f.hash = $` + fmt.Sprint(len(scanIDs)+1) + ` AND ` + cond + overlap, args, nil
+}
+
+// HashGroupDevices returns, for each of the hashes, how many devices its files in the given scans are on.
+// A file without a device id counts as a device of its own.
+func HashGroupDevices(ctx context.Context, database *sql.DB, scanIDs []int64, hashes []string) (map[string]int, error) {
+	out := make(map[string]int)
+	if len(scanIDs) == 0 || len(hashes) == 0 {
+		return out, nil
+	}
+	args := idSlice(scanIDs)
+	for _, h := range hashes {
+		args = append(args, h)
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.hash, COUNT(DISTINCT f.device_id) + COUNT(*) FILTER (WHERE f.device_id IS NULL)
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id
+		 WHERE fs.scan_id IN (`+placeholders(len(scanIDs), 1)+`) AND f.hash_status = 'done'
+		   AND f.hash IN (`+placeholders(len(hashes), len(scanIDs)+1)+`)
+		 GROUP BY f.hash`, args...) // #nosec G202 -- placeholders only; args passed separately
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	return scanFiles(rows)
+	for rows.Next() {
+		var h string
+		var n int
+		if err := rows.Scan(&h, &n); err != nil {
+			return nil, err
+		}
+		out[h] = n
+	}
+	return out, rows.Err()
+}
+
+// HashGroupHosts returns, for each of the hashes with files in the given scans, the distinct hosts of those files'
+// folders, sorted ("" stands for the machine running ditto). A group with more than one host is a cross-host group:
+// the same content is on several machines.
+func HashGroupHosts(ctx context.Context, database *sql.DB, scanIDs []int64, hashes []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	if len(scanIDs) == 0 || len(hashes) == 0 {
+		return out, nil
+	}
+	args := idSlice(scanIDs)
+	for _, h := range hashes {
+		args = append(args, h)
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT DISTINCT f.hash, fo.host
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON fo.id = f.folder_id
+		 WHERE fs.scan_id IN (`+placeholders(len(scanIDs), 1)+`) AND f.hash_status = 'done'
+		   AND f.hash IN (`+placeholders(len(hashes), len(scanIDs)+1)+`)
+		 ORDER BY f.hash, fo.host`, args...) // #nosec G202 -- placeholders only; args passed separately
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h, host string
+		if err := rows.Scan(&h, &host); err != nil {
+			return nil, err
+		}
+		out[h] = append(out[h], host)
+	}
+	return out, rows.Err()
 }
 
 func placeholders(n, start int) string {
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 )
@@ -85,14 +86,14 @@ func TestDuplicateGroupsByHashAcrossScans(t *testing.T) {
 	}
 
 	scanIDs := []int64{scan1.ID, scan2.ID}
-	n, err := DuplicateGroupsByHashCountAcrossScans(ctx, db, scanIDs)
+	n, err := DuplicateGroupsByHashCountAcrossScans(ctx, db, scanIDs, DuplicateGroupFilter{})
 	if err != nil {
 		t.Fatalf("DuplicateGroupsByHashCountAcrossScans: %v", err)
 	}
 	if n != 1 {
 		t.Errorf("count = %d, want 1", n)
 	}
-	groups, err := DuplicateGroupsByHashPaginatedAcrossScans(ctx, db, scanIDs, 10, 0)
+	groups, err := DuplicateGroupsByHashPaginatedAcrossScans(ctx, db, scanIDs, DuplicateGroupFilter{}, 10, 0)
 	if err != nil {
 		t.Fatalf("DuplicateGroupsByHashPaginatedAcrossScans: %v", err)
 	}
@@ -106,12 +107,175 @@ func TestDuplicateGroupsByHashAcrossScans(t *testing.T) {
 	if len(files) != 4 {
 		t.Errorf("len(files) = %d, want 4", len(files))
 	}
-	n0, _ := DuplicateGroupsByHashCountAcrossScans(ctx, db, nil)
+	n0, _ := DuplicateGroupsByHashCountAcrossScans(ctx, db, nil, DuplicateGroupFilter{})
 	if n0 != 0 {
 		t.Errorf("count with nil = %d, want 0", n0)
 	}
-	groups0, _ := DuplicateGroupsByHashPaginatedAcrossScans(ctx, db, nil, 10, 0)
+	groups0, _ := DuplicateGroupsByHashPaginatedAcrossScans(ctx, db, nil, DuplicateGroupFilter{}, 10, 0)
 	if groups0 != nil {
 		t.Errorf("groups with nil = %v", groups0)
 	}
 }
+
+func TestDuplicateGroupsByHashPaginated_sortAndFilter(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	inode := int64(0)
+	add := func(path, hash string, size, mtime int64) {
+		inode++
+		id, err := UpsertFile(ctx, database, folderID, path, size, mtime, inode, nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+	}
+	add("photos/2020/a", "big", 1000, 1)
+	add("backup/a", "big", 1000, 1)
+	add("docs/x", "many", 10, 5)
+	add("docs/y", "many", 10, 5)
+	add("docs/z", "many", 10, 5)
+	add("docs/w", "many", 10, 9)
+	add("photos/20201/p", "other", 100, 3) // not under /data/photos/2020
+	add("misc/p", "other", 100, 3)
+
+	hashes := func(f DuplicateGroupFilter) []string {
+		t.Helper()
+		groups, err := DuplicateGroupsByHashPaginated(ctx, database, sn.ID, f, 10, 0)
+		if err != nil {
+			t.Fatalf("DuplicateGroupsByHashPaginated(%+v): %v", f, err)
+		}
+		var out []string
+		for _, g := range groups {
+			out = append(out, g.Hash)
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		filter DuplicateGroupFilter
+		want   string
+	}{
+		{DuplicateGroupFilter{}, "big,other,many"},
+		{DuplicateGroupFilter{Sort: SortGroupCount}, "many,big,other"},
+		{DuplicateGroupFilter{Sort: SortGroupNewest}, "many,other,big"},
+		{DuplicateGroupFilter{MinSize: 100}, "big,other"},
+		{DuplicateGroupFilter{PathPrefix: "/data/photos/2020/"}, "big"},
+	} {
+		if got := strings.Join(hashes(tc.filter), ","); got != tc.want {
+			t.Errorf("groups(%+v) = %s, want %s", tc.filter, got, tc.want)
+		}
+	}
+	n, err := DuplicateGroupsByHashCountAcrossScans(ctx, database, []int64{sn.ID}, DuplicateGroupFilter{PathPrefix: "/data/docs"})
+	if err != nil || n != 1 {
+		t.Errorf("count under /data/docs = %d, %v; want 1", n, err)
+	}
+}
+
+func TestFilesInHashGroupPage_andHashGroupDevices(t *testing.T) {
+	ctx := context.Background()
+	db := TestPostgresDB(t)
+
+	folderID, _ := AddFolder(ctx, db, "/data")
+	scan, _ := CreateScan(ctx, db, folderID)
+	dev1, dev2 := int64(1), int64(2)
+	now := time.Now().UTC()
+	for i, p := range []string{"a/1", "a/2", "b/3", "b/4", "b/5"} {
+		dev := &dev1
+		if i == 4 {
+			dev = &dev2
+		}
+		id, _ := UpsertFile(ctx, db, folderID, p, 10, 0, int64(i+1), dev)
+		_ = InsertFileScan(ctx, db, id, scan.ID)
+		_ = UpdateFileHash(ctx, db, id, "h", now)
+	}
+
+	var paths []string
+	for offset := 0; ; offset += 2 {
+		files, err := FilesInHashGroupPage(ctx, db, []int64{scan.ID}, "h", "", 2, offset)
+		if err != nil {
+			t.Fatalf("FilesInHashGroupPage: %v", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+	}
+	if strings.Join(paths, ",") != "/data/a/1,/data/a/2,/data/b/3,/data/b/4,/data/b/5" {
+		t.Errorf("paged paths = %v, want all five in path order", paths)
+	}
+	under, err := FilesInHashGroupPage(ctx, db, []int64{scan.ID}, "h", "/data/b", 10, 1)
+	if err != nil || len(under) != 2 || under[0].Path != "/data/b/4" {
+		t.Errorf("FilesInHashGroupPage(prefix /data/b, offset 1) = %+v, %v; want b/4 and b/5", under, err)
+	}
+
+	devices, err := HashGroupDevices(ctx, db, []int64{scan.ID}, []string{"h", "none"})
+	if err != nil || devices["h"] != 2 || devices["none"] != 0 {
+		t.Errorf("HashGroupDevices = %v, %v; want h on 2 devices", devices, err)
+	}
+}
+
+func TestHashGroupStats_andHashGroupFile(t *testing.T) {
+	ctx := context.Background()
+	db := TestPostgresDB(t)
+
+	folderID, _ := AddFolder(ctx, db, "/data")
+	scan, _ := CreateScan(ctx, db, folderID)
+	dev1, dev2 := int64(1), int64(2)
+	now := time.Now().UTC()
+	var ids []int64
+	for i, p := range []string{"a/1", "b/2", "b/3"} {
+		dev := &dev1
+		if i == 2 {
+			dev = &dev2
+		}
+		id, _ := UpsertFile(ctx, db, folderID, p, 10, 0, int64(i+1), dev)
+		_ = InsertFileScan(ctx, db, id, scan.ID)
+		_ = UpdateFileHash(ctx, db, id, "h", now)
+		ids = append(ids, id)
+	}
+
+	files, devices, err := HashGroupStats(ctx, db, []int64{scan.ID}, "h", "")
+	if err != nil || files != 3 || devices != 2 {
+		t.Errorf("HashGroupStats = %d files, %d devices, %v; want 3 on 2 devices", files, devices, err)
+	}
+	files, devices, err = HashGroupStats(ctx, db, []int64{scan.ID}, "h", "/data/a")
+	if err != nil || files != 1 || devices != 1 {
+		t.Errorf("HashGroupStats(prefix /data/a) = %d files, %d devices, %v; want 1 on 1 device", files, devices, err)
+	}
+
+	f, err := HashGroupFile(ctx, db, []int64{scan.ID}, "h", ids[2])
+	if err != nil || f == nil || f.Path != "/data/b/3" || f.ScanID != scan.ID {
+		t.Errorf("HashGroupFile = %+v, %v; want /data/b/3", f, err)
+	}
+	if f, err := HashGroupFile(ctx, db, []int64{scan.ID}, "other", ids[2]); err != nil || f != nil {
+		t.Errorf("HashGroupFile(other hash) = %+v, %v; want nil", f, err)
+	}
+}
+
+func TestSameSizeOtherHash(t *testing.T) {
+	ctx := context.Background()
+	db := TestPostgresDB(t)
+
+	folderID, _ := AddFolder(ctx, db, "/data")
+	scan, _ := CreateScan(ctx, db, folderID)
+	now := time.Now().UTC()
+	for i, f := range []struct {
+		path string
+		size int64
+		hash string
+	}{{"a", 10, "h"}, {"b", 10, "h"}, {"c", 10, "other"}, {"d", 11, "big"}} {
+		id, _ := UpsertFile(ctx, db, folderID, f.path, f.size, 0, int64(i+1), nil)
+		_ = InsertFileScan(ctx, db, id, scan.ID)
+		_ = UpdateFileHash(ctx, db, id, f.hash, now)
+	}
+
+	files, err := SameSizeOtherHash(ctx, db, []int64{scan.ID}, 10, "h", 5)
+	if err != nil || len(files) != 1 || files[0].Path != "/data/c" {
+		t.Errorf("SameSizeOtherHash = %+v, %v; want only /data/c", files, err)
+	}
+}
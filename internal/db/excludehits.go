@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ExcludeHit is how many paths one exclude pattern decided during a scan's walk: paths it skipped or, for a "!"
+// pattern, paths it kept. Skipped directories count once; their contents are never listed.
+type ExcludeHit struct {
+	Pattern string
+	Paths   int64
+}
+
+// SaveScanExcludeHits records the scan's per-pattern counts, in pattern order, replacing any recorded before.
+func SaveScanExcludeHits(ctx context.Context, database *sql.DB, scanID int64, hits []ExcludeHit) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scan_exclude_hits WHERE scan_id = $1`, scanID); err != nil {
+		return err
+	}
+	for i, h := range hits {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO scan_exclude_hits (scan_id, position, pattern, paths) VALUES ($1, $2, $3, $4)`,
+			scanID, i, h.Pattern, h.Paths); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ScanExcludeHits returns the scan's per-pattern counts in pattern order; empty when the walk recorded none.
+func ScanExcludeHits(ctx context.Context, database *sql.DB, scanID int64) ([]ExcludeHit, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT pattern, paths FROM scan_exclude_hits WHERE scan_id = $1 ORDER BY position`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []ExcludeHit
+	for rows.Next() {
+		var h ExcludeHit
+		if err := rows.Scan(&h.Pattern, &h.Paths); err != nil {
+			return nil, err
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
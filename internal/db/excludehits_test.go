@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScanExcludeHits_roundTripInPatternOrder(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+
+	if got, err := ScanExcludeHits(ctx, database, sn.ID); err != nil || len(got) != 0 {
+		t.Fatalf("ScanExcludeHits before the walk = %+v, %v; want none", got, err)
+	}
+	// The same pattern twice (built-in and .dittoignore) keeps both rows.
+	in := []ExcludeHit{{Pattern: ".*", Paths: 12}, {Pattern: "node_modules", Paths: 0}, {Pattern: ".*", Paths: 0}}
+	if err := SaveScanExcludeHits(ctx, database, sn.ID, in); err != nil {
+		t.Fatalf("SaveScanExcludeHits: %v", err)
+	}
+	if err := SaveScanExcludeHits(ctx, database, sn.ID, in); err != nil {
+		t.Fatalf("SaveScanExcludeHits again: %v", err)
+	}
+	got, err := ScanExcludeHits(ctx, database, sn.ID)
+	if err != nil || len(got) != len(in) {
+		t.Fatalf("ScanExcludeHits = %+v, %v; want %+v", got, err, in)
+	}
+	for i := range in {
+		if got[i] != in[i] {
+			t.Errorf("ScanExcludeHits[%d] = %+v, want %+v", i, got[i], in[i])
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExcludePattern is returned for an empty, multi-line or malformed glob exclude pattern.
+var ErrInvalidExcludePattern = errors.New("exclude pattern must be one non-empty line and a valid glob")
+
+// ErrExcludePatternExists is returned by UpdateFolderExclude when the folder already has the new pattern.
+var ErrExcludePatternExists = errors.New("the scan root already has this exclude pattern")
+
+// FolderExclude is an exclude pattern of a scan root managed in the web UI. It uses the .dittoignore syntax and
+// applies on top of the default patterns and the root's .dittoignore.
+type FolderExclude struct {
+	ID        int64
+	FolderID  int64
+	Pattern   string
+	CreatedAt time.Time
+}
+
+// NormalizeExcludePattern trims the pattern and checks it: one non-empty line that names something besides
+// the "!" and slashes of .gitignore syntax, and a valid glob when it contains '*' or '?'.
+func NormalizeExcludePattern(pattern string) (string, error) {
+	p := strings.TrimSpace(pattern)
+	if strings.Trim(strings.TrimPrefix(p, "!"), "/") == "" || strings.ContainsAny(p, "\r\n") {
+		return "", ErrInvalidExcludePattern
+	}
+	if _, err := filepath.Match(p, "x"); err != nil {
+		return "", ErrInvalidExcludePattern
+	}
+	return p, nil
+}
+
+// AddFolderExclude adds pattern to the folder's exclude list; adding an existing pattern is a no-op.
+// Returns ErrInvalidExcludePattern for a bad pattern.
+func AddFolderExclude(ctx context.Context, database *sql.DB, folderID int64, pattern string) error {
+	p, err := NormalizeExcludePattern(pattern)
+	if err != nil {
+		return err
+	}
+	_, err = database.ExecContext(ctx,
+		`INSERT INTO folder_excludes (folder_id, pattern, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (folder_id, pattern) DO NOTHING`,
+		folderID, p, NowUTC())
+	return err
+}
+
+// UpdateFolderExclude replaces the pattern of one of the folder's excludes. Returns ErrInvalidExcludePattern
+// for a bad pattern and ErrExcludePatternExists when another exclude of the folder already has it.
+func UpdateFolderExclude(ctx context.Context, database *sql.DB, folderID, id int64, pattern string) error {
+	p, err := NormalizeExcludePattern(pattern)
+	if err != nil {
+		return err
+	}
+	var exists bool
+	if err := database.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM folder_excludes WHERE folder_id = $1 AND pattern = $2 AND id <> $3)`,
+		folderID, p, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrExcludePatternExists
+	}
+	_, err = database.ExecContext(ctx,
+		`UPDATE folder_excludes SET pattern = $1 WHERE id = $2 AND folder_id = $3`, p, id, folderID)
+	return err
+}
+
+// DeleteFolderExclude removes one of the folder's excludes.
+func DeleteFolderExclude(ctx context.Context, database *sql.DB, folderID, id int64) error {
+	_, err := database.ExecContext(ctx, `DELETE FROM folder_excludes WHERE id = $1 AND folder_id = $2`, id, folderID)
+	return err
+}
+
+// ListFolderExcludes returns the folder's excludes in the order they were added.
+func ListFolderExcludes(ctx context.Context, database *sql.DB, folderID int64) ([]FolderExclude, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT id, folder_id, pattern, created_at FROM folder_excludes WHERE folder_id = $1 ORDER BY id`, folderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FolderExclude
+	for rows.Next() {
+		var e FolderExclude
+		if err := rows.Scan(&e.ID, &e.FolderID, &e.Pattern, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// FolderExcludePatterns returns the folder's exclude patterns, for scan.ScanOptions.ExcludePatterns.
+func FolderExcludePatterns(ctx context.Context, database *sql.DB, folderID int64) ([]string, error) {
+	list, err := ListFolderExcludes(ctx, database, folderID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(list))
+	for i, e := range list {
+		out[i] = e.Pattern
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeExcludePattern(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      error
+	}{
+		{" .cache ", ".cache", nil},
+		{"*.part", "*.part", nil},
+		{"", "", ErrInvalidExcludePattern},
+		{"a\nb", "", ErrInvalidExcludePattern},
+		{"[", "", ErrInvalidExcludePattern},
+	} {
+		got, err := NormalizeExcludePattern(tc.in)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("NormalizeExcludePattern(%q) = %q, %v; want %q, %v", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestFolderExcludes_addUpdateDelete(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	other, _ := AddFolder(ctx, database, "/other")
+	for _, p := range []string{".cache", "*.part", ".cache"} {
+		if err := AddFolderExclude(ctx, database, folderID, p); err != nil {
+			t.Fatalf("AddFolderExclude(%q): %v", p, err)
+		}
+	}
+	_ = AddFolderExclude(ctx, database, other, "tmp")
+	got, _ := FolderExcludePatterns(ctx, database, folderID)
+	if !reflect.DeepEqual(got, []string{".cache", "*.part"}) {
+		t.Fatalf("FolderExcludePatterns = %v, want [.cache *.part] (duplicate ignored, other folder's left out)", got)
+	}
+
+	list, _ := ListFolderExcludes(ctx, database, folderID)
+	if err := UpdateFolderExclude(ctx, database, folderID, list[1].ID, ".cache"); !errors.Is(err, ErrExcludePatternExists) {
+		t.Errorf("update to existing pattern: err = %v, want ErrExcludePatternExists", err)
+	}
+	if err := UpdateFolderExclude(ctx, database, folderID, list[1].ID, "*.crdownload"); err != nil {
+		t.Fatalf("UpdateFolderExclude: %v", err)
+	}
+	if err := DeleteFolderExclude(ctx, database, folderID, list[0].ID); err != nil {
+		t.Fatalf("DeleteFolderExclude: %v", err)
+	}
+	got, _ = FolderExcludePatterns(ctx, database, folderID)
+	if !reflect.DeepEqual(got, []string{"*.crdownload"}) {
+		t.Errorf("after update and delete: %v, want [*.crdownload]", got)
+	}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DuplicateExportRow is one file of a duplicate-by-hash group, as streamed by EachDuplicateFile.
+type DuplicateExportRow struct {
+	Hash       string
+	GroupFiles int64 // files in the group
+	GroupSize  int64 // total bytes in the group
+	File       File
+	Keeper     bool   // File is the group's pinned keeper (SetGroupKeeper)
+	HashSource string // how the file's hash was obtained (HashComputed, ...), "" when not recorded
+}
+
+// EachDuplicateFile calls fn for every file in every duplicate-by-hash group of the scan, without pagination.
+// Rows arrive grouped by hash, groups ordered by total size (largest first), files by path. Rows are read
+// as they come from the database, so exports of large scans are not held in memory. A non-nil error from
+// fn stops the iteration and is returned.
+func EachDuplicateFile(ctx context.Context, database *sql.DB, scanID int64, fn func(DuplicateExportRow) error) error {
+	rows, err := database.QueryContext(ctx,
+		`WITH g AS (
+			SELECT f.hash, COUNT(*) AS n, SUM(f.size) AS total FROM files f
+			JOIN file_scan fs ON f.id = fs.file_id
+			WHERE fs.scan_id = $1 AND f.hash_status = 'done'
+			GROUP BY f.hash HAVING COUNT(*) > 1
+		)
+		SELECT g.hash, g.n, g.total, f.id, (fo.path || '/' || f.path) AS full_path, f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at,
+			COALESCE(k.file_id = f.id, false) AS keeper, COALESCE(f.hash_source, '')
+		FROM g
+		JOIN files f ON f.hash = g.hash AND f.hash_status = 'done'
+		JOIN file_scan fs ON fs.file_id = f.id AND fs.scan_id = $1
+		JOIN folders fo ON f.folder_id = fo.id
+		LEFT JOIN group_keepers k ON k.hash = g.hash
+		ORDER BY g.total DESC, g.hash, full_path`,
+		scanID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r := DuplicateExportRow{File: File{ScanID: scanID}}
+		f := &r.File
+		var deviceID sql.NullInt64
+		var hash sql.NullString
+		var hashedAt nullRFC3339Time
+		if err := rows.Scan(&r.Hash, &r.GroupFiles, &r.GroupSize, &f.ID, &f.Path, &f.Size, &f.MTime, &f.Inode, &deviceID, &hash, &f.HashStatus, &hashedAt, &r.Keeper, &r.HashSource); err != nil {
+			return err
+		}
+		if deviceID.Valid {
+			v := deviceID.Int64
+			f.DeviceID = &v
+		}
+		if hash.Valid {
+			s := hash.String
+			f.Hash = &s
+		}
+		f.HashedAt = hashedAt.Ptr()
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
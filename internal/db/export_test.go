@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEachDuplicateFile_streamsGroupsLargestFirst(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	for i, f := range []struct {
+		path string
+		size int64
+		hash string
+	}{
+		{"small-b", 10, "s"}, {"small-a", 10, "s"},
+		{"big-a", 500, "b"}, {"big-b", 500, "b"},
+		{"unique", 999, "u"},
+	} {
+		id, err := UpsertFile(ctx, database, folderID, f.path, f.size, 1, int64(i+1), nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, f.hash, time.Now().UTC())
+	}
+
+	var got []string
+	err := EachDuplicateFile(ctx, database, sn.ID, func(r DuplicateExportRow) error {
+		if r.GroupFiles != 2 {
+			t.Errorf("%s: GroupFiles = %d, want 2", r.File.Path, r.GroupFiles)
+		}
+		got = append(got, r.Hash+":"+r.File.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachDuplicateFile: %v", err)
+	}
+	want := []string{"b:/data/big-a", "b:/data/big-b", "s:/data/small-a", "s:/data/small-b"}
+	if len(got) != len(want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	if err := EachDuplicateFile(ctx, database, sn.ID, func(DuplicateExportRow) error { n++; return stop }); !errors.Is(err, stop) || n != 1 {
+		t.Errorf("callback error: err = %v after %d rows, want stop after 1", err, n)
+	}
+}
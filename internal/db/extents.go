@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// FileExtents is how much disk space a file takes, as opposed to its size.
+type FileExtents struct {
+	Size      int64
+	Allocated *int64 // bytes allocated when scanned; nil = unknown
+	Shared    int64  // bytes in extents shared with other files (reflinks, block deduplication)
+}
+
+// Sparse reports whether fewer bytes are allocated to the file than its size.
+func (e FileExtents) Sparse() bool { return e.Allocated != nil && *e.Allocated < e.Size }
+
+// OnDisk returns the bytes the file takes on its own: its allocation (or size when unknown, never more than
+// the size) minus the bytes it shares with other files. It is what deleting the file frees.
+func (e FileExtents) OnDisk() int64 {
+	n := e.Size
+	if e.Allocated != nil && *e.Allocated < n {
+		n = *e.Allocated
+	}
+	return max(n-e.Shared, 0)
+}
+
+// fileOnDisk is FileExtents.OnDisk in SQL for files aliased f.
+const fileOnDisk = `GREATEST(LEAST(COALESCE(f.allocated, f.size), f.size) - COALESCE(f.shared_bytes, 0), 0)`
+
+// UpdateFileSharedBytes records the bytes of a file that lie in extents shared with other files (see package
+// extents), 0 included, so a copy that stopped sharing counts in full again. The value is cleared when a scan
+// finds the file changed or replaced.
+func UpdateFileSharedBytes(ctx context.Context, database *sql.DB, fileID, shared int64) error {
+	_, err := database.ExecContext(ctx, "UPDATE files SET shared_bytes = $2 WHERE id = $1", fileID, shared)
+	return err
+}
+
+// ExtentsByFileID returns the disk usage of those of the given files that are sparse or share extents.
+func ExtentsByFileID(ctx context.Context, database *sql.DB, fileIDs []int64) (map[int64]FileExtents, error) {
+	out := make(map[int64]FileExtents)
+	if len(fileIDs) == 0 {
+		return out, nil
+	}
+	// #nosec G202 -- placeholders built from len(fileIDs); all values passed as args
+	q := `SELECT id, size, allocated, COALESCE(shared_bytes, 0) FROM files
+		  WHERE (allocated < size OR shared_bytes > 0) AND id IN (` + placeholders(len(fileIDs), 1) + `)`
+	rows, err := database.QueryContext(ctx, q, idSlice(fileIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var e FileExtents
+		var allocated sql.NullInt64
+		if err := rows.Scan(&id, &e.Size, &allocated, &e.Shared); err != nil {
+			return nil, err
+		}
+		if allocated.Valid {
+			e.Allocated = &allocated.Int64
+		}
+		out[id] = e
+	}
+	return out, rows.Err()
+}
+
+// ForEachDuplicateFile calls fn with the id, full path and device of each of the scan's hashed files whose content
+// has another copy in any scan: the files whose shared extents count towards ProjectSavings.
+func ForEachDuplicateFile(ctx context.Context, database *sql.DB, scanID int64, fn func(id int64, path string, deviceID *int64) error) error {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, (fo.path || '/' || f.path), f.device_id FROM files f
+		 JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id = $1 AND f.hash_status = 'done'
+		 AND EXISTS (SELECT 1 FROM files o WHERE o.hash = f.hash AND o.id <> f.id AND o.hash_status = 'done')
+		 ORDER BY f.id`, scanID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var path string
+		var dev sql.NullInt64
+		if err := rows.Scan(&id, &path, &dev); err != nil {
+			return err
+		}
+		var deviceID *int64
+		if dev.Valid {
+			deviceID = &dev.Int64
+		}
+		if err := fn(id, path, deviceID); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
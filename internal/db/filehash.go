@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -52,6 +54,26 @@ func HashForInodeFromPreviousScan(ctx context.Context, database *sql.DB, current
 	return out, nil
 }
 
+// HashForMovedFile returns the hash of the other hashed files (any scan or folder) with the file's size and
+// modification time and, when name is not empty, that file name. It returns "" when there are none, or when they
+// disagree on the hash, since then size and time do not identify the content. Empty files are never matched.
+func HashForMovedFile(ctx context.Context, database *sql.DB, fileID, size, mtime int64, name string) (string, error) {
+	if size == 0 {
+		return "", nil
+	}
+	var hash sql.NullString
+	var hashes int
+	err := database.QueryRowContext(ctx,
+		`SELECT MIN(hash), COUNT(DISTINCT hash) FROM files
+		 WHERE size = $1 AND mtime = $2 AND hash IS NOT NULL AND id <> $3
+		   AND ($4 = '' OR regexp_replace(path, '^.*/', '') = $4)`,
+		size, mtime, fileID, name).Scan(&hash, &hashes)
+	if err != nil || hashes != 1 {
+		return "", err
+	}
+	return hash.String, nil
+}
+
 // ResetHashStatusHashingToPending sets hash_status to 'pending' for all files in the scan that are currently 'hashing'.
 func ResetHashStatusHashingToPending(ctx context.Context, database *sql.DB, scanID int64) error {
 	_, err := database.ExecContext(ctx,
@@ -62,11 +84,62 @@ func ResetHashStatusHashingToPending(ctx context.Context, database *sql.DB, scan
 	return err
 }
 
-// UpdateFileHash sets hash, hash_status = 'done', and hashed_at for the file.
+// Hash sources say how a file's hash was obtained (files.hash_source). Only HashComputed means the file's own
+// bytes were read; the others trust that the file has not changed since another file's bytes were.
+const (
+	HashComputed     = "computed" // the file was read and hashed
+	HashFromInode    = "inode"    // a hardlink of the file (same inode and device) in the same scan had the hash
+	HashFromPrevious = "previous" // the same inode and size had the hash in an earlier scan
+	HashFromMoved    = "moved"    // files elsewhere with the same size and modification time had it (DITTO_HASH_REUSE)
+	HashImported     = "imported" // a manifest or catalog of another machine supplied it
+)
+
+// UpdateFileHash sets hash, hash_status = 'done', and hashed_at for the file, whose bytes were read (HashComputed).
 func UpdateFileHash(ctx context.Context, database *sql.DB, fileID int64, hash string, hashedAt time.Time) error {
 	_, err := database.ExecContext(ctx,
-		"UPDATE files SET hash = $1, hash_status = 'done', hashed_at = $2 WHERE id = $3",
-		hash, hashedAt.UTC(), fileID)
+		"UPDATE files SET hash = $1, hash_status = 'done', hashed_at = $2, hash_source = $4 WHERE id = $3",
+		hash, hashedAt.UTC(), fileID, HashComputed)
+	return err
+}
+
+// ReplaceFileHash stores a hash just computed from the file's bytes on demand (a forced re-hash), in place of one
+// that may have been reused: the source becomes HashComputed and earlier failures are forgotten. When the hash
+// changed, the file's byte-by-byte verification and perceptual hash were of other bytes and are cleared.
+func ReplaceFileHash(ctx context.Context, database *sql.DB, fileID int64, hash string, hashedAt time.Time) error {
+	_, err := database.ExecContext(ctx,
+		`UPDATE files SET hash = $1, hash_status = 'done', hashed_at = $2, hash_source = $4, hash_attempts = 0, hash_error = NULL,
+			verified_at = CASE WHEN hash IS DISTINCT FROM $1 THEN NULL ELSE verified_at END,
+			phash = CASE WHEN hash IS DISTINCT FROM $1 THEN NULL ELSE phash END,
+			phash_status = CASE WHEN hash IS DISTINCT FROM $1 THEN NULL ELSE phash_status END
+		 WHERE id = $3`,
+		hash, hashedAt.UTC(), fileID, HashComputed)
+	return err
+}
+
+// FileHash is a file's hash and how it was obtained, as stored by UpdateFileHashes.
+type FileHash struct {
+	FileID int64
+	Hash   string
+	Source string // HashComputed, HashFromInode, ...
+}
+
+// UpdateFileHashes sets hash, hash_status = 'done', hashed_at and hash_source for several files in one statement,
+// as UpdateFileHash does for one.
+func UpdateFileHashes(ctx context.Context, database *sql.DB, hashes []FileHash, hashedAt time.Time) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, 3*len(hashes)+1)
+	args = append(args, hashedAt.UTC())
+	values := make([]string, len(hashes))
+	for i, h := range hashes {
+		values[i] = fmt.Sprintf("($%d::bigint, $%d::text, $%d::text)", 3*i+2, 3*i+3, 3*i+4)
+		args = append(args, h.FileID, h.Hash, h.Source)
+	}
+	// #nosec G202 -- placeholders built from len(hashes); all values passed as args
+	q := `UPDATE files AS f SET hash = v.hash, hash_status = 'done', hashed_at = $1, hash_source = NULLIF(v.source, '')
+		FROM (VALUES ` + strings.Join(values, ",") + `) AS v(id, hash, source) WHERE f.id = v.id`
+	_, err := database.ExecContext(ctx, q, args...)
 	return err
 }
 
@@ -77,3 +150,97 @@ func ResetFileHashStatusToPending(ctx context.Context, database *sql.DB, fileID
 		fileID)
 	return err
 }
+
+// MarkFileHashFailed gives up on hashing the file: hash_status becomes 'failed' with the number of attempts and
+// the last error. The file stays failed until a later scan or a retry from the scan's error page.
+func MarkFileHashFailed(ctx context.Context, database *sql.DB, fileID int64, attempts int, hashErr string) error {
+	_, err := database.ExecContext(ctx,
+		"UPDATE files SET hash_status = 'failed', hash_attempts = $2, hash_error = $3 WHERE id = $1",
+		fileID, attempts, hashErr)
+	return err
+}
+
+// MarkFilesVerified sets verified_at for the given files (byte-by-byte comparison succeeded).
+// Cleared automatically when a later scan sees the file's size or mtime change.
+func MarkFilesVerified(ctx context.Context, database *sql.DB, fileIDs []int64, at time.Time) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	args := idSlice(fileIDs)
+	args = append(args, at.UTC())
+	// #nosec G202 -- placeholders built from len(fileIDs); all values passed as args
+	q := `UPDATE files SET verified_at = $` + fmt.Sprint(len(fileIDs)+1) + ` WHERE id IN (` + placeholders(len(fileIDs), 1) + `)`
+	_, err := database.ExecContext(ctx, q, args...)
+	return err
+}
+
+// VerifiedAtByFileID returns verified_at for those of the given files that have been verified.
+func VerifiedAtByFileID(ctx context.Context, database *sql.DB, fileIDs []int64) (map[int64]time.Time, error) {
+	out := make(map[int64]time.Time)
+	if len(fileIDs) == 0 {
+		return out, nil
+	}
+	// #nosec G202 -- placeholders built from len(fileIDs); all values passed as args
+	q := `SELECT id, verified_at FROM files WHERE verified_at IS NOT NULL AND id IN (` + placeholders(len(fileIDs), 1) + `)`
+	rows, err := database.QueryContext(ctx, q, idSlice(fileIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		out[id] = at
+	}
+	return out, rows.Err()
+}
+
+// HashEvidence is what a file's place in a duplicate group rests on, besides its size: how its hash was obtained,
+// when, and how many other files are hardlinks of it (so share its inode and any hash reused through it).
+type HashEvidence struct {
+	Source    string // HashComputed, HashFromInode, ...; "" when the hash was stored before sources were recorded
+	HashedAt  *time.Time
+	Hardlinks int // other files with the same inode on the same device, in any folder
+}
+
+// Reused reports whether the hash was taken from another file or a manifest instead of read from this file.
+func (e HashEvidence) Reused() bool {
+	return e.Source != "" && e.Source != HashComputed
+}
+
+// HashEvidenceByFileID returns the hash evidence of the given files.
+func HashEvidenceByFileID(ctx context.Context, database *sql.DB, fileIDs []int64) (map[int64]HashEvidence, error) {
+	out := make(map[int64]HashEvidence)
+	if len(fileIDs) == 0 {
+		return out, nil
+	}
+	// #nosec G202 -- placeholders built from len(fileIDs); all values passed as args
+	q := `SELECT f.id, COALESCE(f.hash_source, ''), f.hashed_at,
+			(SELECT COUNT(*) FROM files o WHERE o.inode = f.inode AND o.device_id = f.device_id AND o.id <> f.id)
+		  FROM files f WHERE f.id IN (` + placeholders(len(fileIDs), 1) + `)`
+	rows, err := database.QueryContext(ctx, q, idSlice(fileIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var e HashEvidence
+		if err := rows.Scan(&id, &e.Source, &e.HashedAt, &e.Hardlinks); err != nil {
+			return nil, err
+		}
+		out[id] = e
+	}
+	return out, rows.Err()
+}
+
+// UpdateFileInode records the inode a file's path now has after it was replaced by a link to another copy
+// (see package dedupe), so hardlink groups and savings reflect it before the next scan. The content, hash and
+// modification time are unchanged; verified_at is kept since the bytes were compared before linking.
+func UpdateFileInode(ctx context.Context, database *sql.DB, fileID, inode int64) error {
+	_, err := database.ExecContext(ctx, "UPDATE files SET inode = $2 WHERE id = $1", fileID, inode)
+	return err
+}
@@ -92,6 +92,37 @@ func TestHashForInodeFromPreviousScan_unchangedFileReusesHash(t *testing.T) {
 	}
 }
 
+func TestHashForMovedFile_matchesSizeMTimeAndName(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	old, _ := AddFolder(ctx, database, "/old")
+	moved, _ := AddFolder(ctx, database, "/new")
+	hashed, _ := UpsertFile(ctx, database, old, "videos/trip.mkv", 5<<30, 1700000000, 1, nil)
+	_ = UpdateFileHash(ctx, database, hashed, "abc", time.Now().UTC())
+	id, _ := UpsertFile(ctx, database, moved, "archive/trip.mkv", 5<<30, 1700000000, 2, nil)
+
+	if got, err := HashForMovedFile(ctx, database, id, 5<<30, 1700000000, "trip.mkv"); err != nil || got != "abc" {
+		t.Errorf("HashForMovedFile(same name) = %q, %v; want abc", got, err)
+	}
+	if got, _ := HashForMovedFile(ctx, database, id, 5<<30, 1700000000, "other.mkv"); got != "" {
+		t.Errorf("HashForMovedFile(other name) = %q, want no match", got)
+	}
+	if got, _ := HashForMovedFile(ctx, database, id, 5<<30, 1700000001, ""); got != "" {
+		t.Errorf("HashForMovedFile(other mtime) = %q, want no match", got)
+	}
+
+	// A second hashed file with the same size and time but other content makes the match ambiguous.
+	other, _ := UpsertFile(ctx, database, old, "videos/trip2.mkv", 5<<30, 1700000000, 3, nil)
+	_ = UpdateFileHash(ctx, database, other, "def", time.Now().UTC())
+	if got, _ := HashForMovedFile(ctx, database, id, 5<<30, 1700000000, ""); got != "" {
+		t.Errorf("HashForMovedFile(ambiguous) = %q, want no match", got)
+	}
+	if got, _ := HashForMovedFile(ctx, database, id, 5<<30, 1700000000, "trip.mkv"); got != "abc" {
+		t.Errorf("HashForMovedFile(same name, ambiguous size) = %q, want abc", got)
+	}
+}
+
 func TestHashForInodeFromPreviousScan_differentSizeDoesNotReuse(t *testing.T) {
 	database := TestPostgresDB(t)
 	ctx := context.Background()
@@ -141,3 +172,130 @@ func TestHashForInode_differentScanDoesNotReuse(t *testing.T) {
 		t.Errorf("HashForInode(scan2, 123) = %q, want empty", got)
 	}
 }
+
+func TestMarkFilesVerified_andVerifiedAtByFileID(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	a, _ := UpsertFile(ctx, database, folderID, "a", 10, 1, 1, nil)
+	b, _ := UpsertFile(ctx, database, folderID, "b", 10, 1, 2, nil)
+	c, _ := UpsertFile(ctx, database, folderID, "c", 10, 1, 3, nil)
+
+	if err := MarkFilesVerified(ctx, database, []int64{a, b}, time.Now()); err != nil {
+		t.Fatalf("MarkFilesVerified: %v", err)
+	}
+	got, err := VerifiedAtByFileID(ctx, database, []int64{a, b, c})
+	if err != nil {
+		t.Fatalf("VerifiedAtByFileID: %v", err)
+	}
+	if len(got) != 2 || got[a].IsZero() || got[b].IsZero() {
+		t.Errorf("VerifiedAtByFileID = %v, want a and b", got)
+	}
+
+	// Content change (mtime) clears verification
+	_, _ = UpsertFile(ctx, database, folderID, "a", 10, 2, 1, nil)
+	got, _ = VerifiedAtByFileID(ctx, database, []int64{a})
+	if len(got) != 0 {
+		t.Errorf("after change: VerifiedAtByFileID = %v, want empty", got)
+	}
+}
+
+func TestMarkFileHashFailed_resetByNextScan(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	id, _ := UpsertFile(ctx, database, folderID, "a", 10, 1, 1, nil)
+	if err := MarkFileHashFailed(ctx, database, id, 3, "input/output error"); err != nil {
+		t.Fatalf("MarkFileHashFailed: %v", err)
+	}
+	status := func() (string, int) {
+		var s string
+		var n int
+		if err := database.QueryRowContext(ctx, `SELECT hash_status, hash_attempts FROM files WHERE id = $1`, id).Scan(&s, &n); err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		return s, n
+	}
+	if s, n := status(); s != "failed" || n != 3 {
+		t.Errorf("after MarkFileHashFailed: status %q, attempts %d; want failed, 3", s, n)
+	}
+
+	// Unchanged file seen by a later scan gets a fresh set of attempts
+	_, _ = UpsertFile(ctx, database, folderID, "a", 10, 1, 1, nil)
+	if s, n := status(); s != "pending" || n != 0 {
+		t.Errorf("after rescan: status %q, attempts %d; want pending, 0", s, n)
+	}
+}
+
+func TestUpdateFileHashes_storesEachFilesHash(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/tmp")
+	scan, _ := CreateScan(ctx, database, folderID)
+	want := map[int64]string{}
+	var hashes []FileHash
+	for i, name := range []string{"a", "b", "c"} {
+		id, _ := UpsertFile(ctx, database, folderID, name, 100, 1, int64(i+1), nil)
+		_ = InsertFileScan(ctx, database, id, scan.ID)
+		want[id] = "h" + name
+		hashes = append(hashes, FileHash{FileID: id, Hash: "h" + name})
+	}
+	untouched, _ := UpsertFile(ctx, database, folderID, "d", 100, 1, 4, nil)
+	_ = InsertFileScan(ctx, database, untouched, scan.ID)
+
+	if err := UpdateFileHashes(ctx, database, hashes, time.Now().UTC()); err != nil {
+		t.Fatalf("UpdateFileHashes: %v", err)
+	}
+	files, _ := GetFilesByScanID(ctx, database, scan.ID)
+	for _, f := range files {
+		if f.ID == untouched {
+			if f.Hash != nil || f.HashStatus != "pending" {
+				t.Errorf("%s: hash %v, status %q; want untouched", f.Path, f.Hash, f.HashStatus)
+			}
+			continue
+		}
+		if f.Hash == nil || *f.Hash != want[f.ID] || f.HashStatus != "done" || f.HashedAt == nil {
+			t.Errorf("%s: hash %v, status %q, hashed at %v; want %q, done", f.Path, f.Hash, f.HashStatus, f.HashedAt, want[f.ID])
+		}
+	}
+}
+
+func TestHashEvidence_sourceKeptUntilTheFileChanges(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	dev := int64(1)
+	read, _ := UpsertFile(ctx, database, folderID, "a", 10, 1, 5, &dev)
+	link, _ := UpsertFile(ctx, database, folderID, "b", 10, 1, 5, &dev)
+	if err := UpdateFileHashes(ctx, database, []FileHash{
+		{FileID: read, Hash: "h", Source: HashComputed},
+		{FileID: link, Hash: "h", Source: HashFromInode},
+	}, time.Now().UTC()); err != nil {
+		t.Fatalf("UpdateFileHashes: %v", err)
+	}
+	evidence, err := HashEvidenceByFileID(ctx, database, []int64{read, link})
+	if err != nil {
+		t.Fatalf("HashEvidenceByFileID: %v", err)
+	}
+	if e := evidence[read]; e.Source != HashComputed || e.Reused() || e.HashedAt == nil || e.Hardlinks != 1 {
+		t.Errorf("read file evidence = %+v, want computed, with its hardlink counted", e)
+	}
+	if e := evidence[link]; e.Source != HashFromInode || !e.Reused() {
+		t.Errorf("hardlink evidence = %+v, want reused from its inode", e)
+	}
+
+	// An unchanged file keeps how its hash was obtained; a changed one loses both.
+	_, _ = UpsertFile(ctx, database, folderID, "a", 10, 1, 5, &dev)
+	_, _ = UpsertFile(ctx, database, folderID, "b", 11, 2, 5, &dev)
+	evidence, _ = HashEvidenceByFileID(ctx, database, []int64{read, link})
+	if e := evidence[read]; e.Source != HashComputed {
+		t.Errorf("unchanged file: source %q, want computed", e.Source)
+	}
+	if e := evidence[link]; e.Source != "" || e.HashedAt != nil || e.Reused() {
+		t.Errorf("changed file evidence = %+v, want no source", e)
+	}
+}
@@ -3,9 +3,13 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 // File is a single file record (metadata and optional hash). Path may be relative (folder) or full (when joined with folder for display).
@@ -23,6 +27,29 @@ type File struct {
 	HashedAt   *time.Time
 }
 
+// fileChanged is true in upsertFileOnConflict when the path's content may have changed: size or mtime differ, or
+// it turned into (or stopped being, or now points elsewhere as) a recorded symlink.
+const fileChanged = `(files.size <> EXCLUDED.size OR files.mtime <> EXCLUDED.mtime OR files.symlink_target IS DISTINCT FROM EXCLUDED.symlink_target)`
+
+// rehash is true in upsertFileOnConflict when the file goes back to its inserted status: its content may have
+// changed, or hashing it failed in an earlier scan (each scan gives a 'failed' file a fresh set of attempts).
+const rehash = `(` + fileChanged + ` OR files.hash_status = 'failed')`
+
+// upsertFileOnConflict updates metadata for an existing (folder_id, path). When the content may have changed the
+// stored hash is cleared and the file goes back to its inserted status ('pending' for re-hashing, 'symlink' for links).
+const upsertFileOnConflict = `ON CONFLICT (folder_id, path) DO UPDATE SET size = EXCLUDED.size, mtime = EXCLUDED.mtime, inode = EXCLUDED.inode, device_id = EXCLUDED.device_id,
+		 symlink_target = EXCLUDED.symlink_target, allocated = EXCLUDED.allocated,
+		 shared_bytes = CASE WHEN ` + fileChanged + ` OR files.inode <> EXCLUDED.inode THEN NULL ELSE files.shared_bytes END,
+		 hash = CASE WHEN ` + fileChanged + ` THEN NULL ELSE files.hash END,
+		 hash_status = CASE WHEN ` + rehash + ` THEN EXCLUDED.hash_status ELSE files.hash_status END,
+		 hash_attempts = CASE WHEN ` + rehash + ` THEN 0 ELSE files.hash_attempts END,
+		 hash_error = CASE WHEN ` + rehash + ` THEN NULL ELSE files.hash_error END,
+		 hashed_at = CASE WHEN ` + fileChanged + ` THEN NULL ELSE files.hashed_at END,
+		 hash_source = CASE WHEN ` + fileChanged + ` THEN NULL ELSE files.hash_source END,
+		 verified_at = CASE WHEN ` + fileChanged + ` THEN NULL ELSE files.verified_at END,
+		 phash = CASE WHEN ` + fileChanged + ` THEN NULL ELSE files.phash END,
+		 phash_status = CASE WHEN ` + fileChanged + ` THEN NULL ELSE files.phash_status END`
+
 // UpsertFile inserts or updates a file by (folder_id, path) and returns the file id. Path must be relative to the folder root.
 func UpsertFile(ctx context.Context, db *sql.DB, folderID int64, path string, size, mtime, inode int64, deviceID *int64) (int64, error) {
 	var deviceVal interface{} = nil
@@ -33,7 +60,7 @@ func UpsertFile(ctx context.Context, db *sql.DB, folderID int64, path string, si
 	err := db.QueryRowContext(ctx,
 		`INSERT INTO files (folder_id, path, size, mtime, inode, device_id, hash_status)
 		 VALUES ($1, $2, $3, $4, $5, $6, 'pending')
-		 ON CONFLICT (folder_id, path) DO UPDATE SET size = EXCLUDED.size, mtime = EXCLUDED.mtime, inode = EXCLUDED.inode, device_id = EXCLUDED.device_id
+		 `+upsertFileOnConflict+`
 		 RETURNING id`,
 		folderID, path, size, mtime, inode, deviceVal).Scan(&id)
 	return id, err
@@ -47,41 +74,147 @@ func InsertFileScan(ctx context.Context, db *sql.DB, fileID, scanID int64) error
 	return err
 }
 
+// MaxPathBytes is the longest relative path a file row can have: (folder_id, path) is indexed, and PostgreSQL
+// rejects B-tree entries of more than about 2.7 KB.
+const MaxPathBytes = 2600
+
+// ErrPathTooLong is recorded for files whose path relative to the root is longer than MaxPathBytes.
+var ErrPathTooLong = errors.New("path is too long to catalog (more than 2600 bytes below the scan root)")
+
 // FileRow is a single file's metadata for batch insert. Path is relative to folder root.
 type FileRow struct {
-	Path     string
-	Size     int64
-	MTime    int64
-	Inode    int64
-	DeviceID *int64
+	Path          string
+	Size          int64
+	MTime         int64
+	Inode         int64
+	DeviceID      *int64
+	SymlinkTarget string // non-empty for a recorded symlink (stored with hash_status 'symlink', never hashed)
+	Allocated     *int64 // bytes allocated on disk; nil = unknown
 }
 
-// UpsertFilesBatch inserts or updates multiple files in one round-trip and returns their IDs in the same order.
-// Paths must be relative to the folder root. Empty slice returns nil, nil.
+// UpsertFilesBatch inserts or updates multiple files in one statement and returns their IDs in the same order.
+// On PostgreSQL the rows are sent with COPY (see upsertFilesCopy). Paths must be relative to the folder root.
+// Empty slice returns nil, nil.
 func UpsertFilesBatch(ctx context.Context, database *sql.DB, folderID int64, rows []FileRow) ([]int64, error) {
 	if len(rows) == 0 {
 		return nil, nil
 	}
-	// Build VALUES ($1..$6,'pending'), ($7..$12,'pending'), ... ON CONFLICT DO UPDATE RETURNING id
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var ids []int64
+	copied := false
+	err = conn.Raw(func(driverConn interface{}) error {
+		pc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return nil
+		}
+		copied = true
+		ids, err = upsertFilesCopy(ctx, pc.Conn(), folderID, rows)
+		return err
+	})
+	if copied || err != nil {
+		return ids, err
+	}
+	return upsertFilesValues(ctx, database, folderID, rows)
+}
+
+// fileRowStatus returns the hash_status and symlink_target a new row of r is inserted with.
+func fileRowStatus(r *FileRow) (string, sql.NullString) {
+	if r.SymlinkTarget != "" {
+		return "symlink", sql.NullString{String: r.SymlinkTarget, Valid: true}
+	}
+	return "pending", sql.NullString{}
+}
+
+// upsertFilesCopy is UpsertFilesBatch on PostgreSQL: the rows are streamed with COPY into a temporary table and
+// merged into files with one INSERT ... SELECT, instead of binding 9 parameters per row. The temporary table lives
+// as long as the connection and is emptied at commit.
+func upsertFilesCopy(ctx context.Context, conn *pgx.Conn, folderID int64, rows []FileRow) ([]int64, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE IF NOT EXISTS files_upsert (
+		path TEXT NOT NULL, size BIGINT NOT NULL, mtime BIGINT NOT NULL, inode BIGINT NOT NULL, device_id BIGINT,
+		hash_status TEXT NOT NULL, symlink_target TEXT, allocated BIGINT
+	) ON COMMIT DELETE ROWS`); err != nil {
+		return nil, err
+	}
+	cols := []string{"path", "size", "mtime", "inode", "device_id", "hash_status", "symlink_target", "allocated"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"files_upsert"}, cols, pgx.CopyFromSlice(len(rows), func(i int) ([]interface{}, error) {
+		r := &rows[i]
+		status, target := fileRowStatus(r)
+		return []interface{}{r.Path, r.Size, r.MTime, r.Inode, r.DeviceID, status, target, r.Allocated}, nil
+	})); err != nil {
+		return nil, err
+	}
+	// RETURNING does not promise the SELECT's order, so ids are matched back to rows by path.
+	res, err := tx.Query(ctx, `INSERT INTO files (folder_id, path, size, mtime, inode, device_id, hash_status, symlink_target, allocated)
+		SELECT $1, path, size, mtime, inode, device_id, hash_status, symlink_target, allocated FROM files_upsert
+		`+upsertFileOnConflict+`
+		RETURNING id, path`, folderID)
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]int64, len(rows))
+	for res.Next() {
+		var id int64
+		var path string
+		if err := res.Scan(&id, &path); err != nil {
+			res.Close()
+			return nil, err
+		}
+		byPath[path] = id
+	}
+	res.Close()
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(rows))
+	for i := range rows {
+		id, ok := byPath[rows[i].Path]
+		if !ok {
+			return nil, fmt.Errorf("UpsertFilesBatch: no id for %q", rows[i].Path)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// upsertFilesValues is UpsertFilesBatch with a multi-row VALUES, for drivers without COPY.
+func upsertFilesValues(ctx context.Context, database *sql.DB, folderID int64, rows []FileRow) ([]int64, error) {
+	// Build VALUES ($1..$9), ($10..$18), ... ON CONFLICT DO UPDATE RETURNING id
 	n := len(rows)
-	const colsPerRow = 6
+	const colsPerRow = 9
 	placeholders := make([]string, n)
 	args := make([]interface{}, 0, n*colsPerRow)
 	for i := 0; i < n; i++ {
 		base := i * colsPerRow
-		placeholders[i] = fmt.Sprintf("($%d,$%d,$%d,$%d,$%d,$%d,'pending')",
-			base+1, base+2, base+3, base+4, base+5, base+6)
+		placeholders[i] = fmt.Sprintf("($%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9)
 		r := &rows[i]
 		var dev interface{} = nil
 		if r.DeviceID != nil {
 			dev = *r.DeviceID
 		}
-		args = append(args, folderID, r.Path, r.Size, r.MTime, r.Inode, dev)
+		status, target := fileRowStatus(r)
+		var allocated interface{}
+		if r.Allocated != nil {
+			allocated = *r.Allocated
+		}
+		args = append(args, folderID, r.Path, r.Size, r.MTime, r.Inode, dev, status, target, allocated)
 	}
 	// #nosec G202 -- placeholders built from len(rows); all values passed as args
-	query := `INSERT INTO files (folder_id, path, size, mtime, inode, device_id, hash_status)
+	query := `INSERT INTO files (folder_id, path, size, mtime, inode, device_id, hash_status, symlink_target, allocated)
 		VALUES ` + strings.Join(placeholders, ", ") + `
-		ON CONFLICT (folder_id, path) DO UPDATE SET size = EXCLUDED.size, mtime = EXCLUDED.mtime, inode = EXCLUDED.inode, device_id = EXCLUDED.device_id
+		` + upsertFileOnConflict + `
 		RETURNING id`
 	rowsResult, err := database.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -129,6 +262,30 @@ func InsertFileScanBatch(ctx context.Context, database *sql.DB, fileIDs []int64,
 	return err
 }
 
+// GetFile returns the file with this id (full path: folder path || '/' || file path; FolderID set), or
+// sql.ErrNoRows.
+func GetFile(ctx context.Context, db *sql.DB, id int64) (*File, error) {
+	var f File
+	var deviceID sql.NullInt64
+	var hash sql.NullString
+	var hashedAt nullRFC3339Time
+	err := db.QueryRowContext(ctx,
+		`SELECT f.id, f.folder_id, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN folders fo ON f.folder_id = fo.id WHERE f.id = $1`, id).
+		Scan(&f.ID, &f.FolderID, &f.Path, &f.Size, &f.MTime, &f.Inode, &deviceID, &hash, &f.HashStatus, &hashedAt)
+	if err != nil {
+		return nil, err
+	}
+	if deviceID.Valid {
+		f.DeviceID = &deviceID.Int64
+	}
+	if hash.Valid {
+		f.Hash = &hash.String
+	}
+	f.HashedAt = hashedAt.Ptr()
+	return &f, nil
+}
+
 // GetFilesByScanID returns all files that appear in the given scan (with full path: folder path || '/' || file path). ScanID is set on each file.
 func GetFilesByScanID(ctx context.Context, db *sql.DB, scanID int64) ([]File, error) {
 	rows, err := db.QueryContext(ctx,
@@ -2,7 +2,10 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestUpsertFile_InsertFileScan_and_GetFilesByScanID(t *testing.T) {
@@ -128,3 +131,83 @@ func TestUpsertFilesBatch_InsertFileScanBatch(t *testing.T) {
 		t.Errorf("batch insert sizes: a=%d b=%d", byPath["/tmp/a"].Size, byPath["/tmp/b"].Size)
 	}
 }
+
+func TestUpsertFilesBatch_returnsIDsInRowOrderOnUpdate(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/tmp")
+	first, err := UpsertFilesBatch(ctx, database, folderID, []FileRow{{Path: "b", Size: 2, MTime: 1, Inode: 2}})
+	if err != nil {
+		t.Fatalf("UpsertFilesBatch: %v", err)
+	}
+	// "b" already exists and is updated, "a" and "c" are new: ids must still follow the rows.
+	rows := []FileRow{{Path: "c", Size: 3, MTime: 1, Inode: 3}, {Path: "b", Size: 5, MTime: 1, Inode: 2}, {Path: "a", Size: 1, MTime: 1, Inode: 1}}
+	ids, err := UpsertFilesBatch(ctx, database, folderID, rows)
+	if err != nil {
+		t.Fatalf("UpsertFilesBatch: %v", err)
+	}
+	if len(ids) != 3 || ids[1] != first[0] {
+		t.Fatalf("ids = %v, want b's id %d second", ids, first[0])
+	}
+	for i, id := range ids {
+		f, err := GetFile(ctx, database, id)
+		if err != nil {
+			t.Fatalf("GetFile(%d): %v", id, err)
+		}
+		if f.Path != "/tmp/"+rows[i].Path || f.Size != rows[i].Size {
+			t.Errorf("ids[%d] is %s (size %d), want /tmp/%s (size %d)", i, f.Path, f.Size, rows[i].Path, rows[i].Size)
+		}
+	}
+}
+
+func TestUpsertFile_changedSizeOrMtimeResetsHash(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	scan, _ := CreateScan(ctx, database, folderID)
+	unchanged, _ := UpsertFile(ctx, database, folderID, "a", 10, 1, 1, nil)
+	changed, _ := UpsertFile(ctx, database, folderID, "b", 10, 1, 2, nil)
+	_ = InsertFileScan(ctx, database, unchanged, scan.ID)
+	_ = InsertFileScan(ctx, database, changed, scan.ID)
+	_ = UpdateFileHash(ctx, database, unchanged, "ha", time.Now().UTC())
+	_ = UpdateFileHash(ctx, database, changed, "hb", time.Now().UTC())
+
+	_, _ = UpsertFile(ctx, database, folderID, "a", 10, 1, 1, nil)
+	_, _ = UpsertFile(ctx, database, folderID, "b", 10, 2, 2, nil) // mtime changed
+
+	files, _ := GetFilesByScanID(ctx, database, scan.ID)
+	for _, f := range files {
+		switch f.ID {
+		case unchanged:
+			if f.Hash == nil || *f.Hash != "ha" || f.HashStatus != "done" {
+				t.Errorf("unchanged file: hash=%v status=%q, want ha/done", f.Hash, f.HashStatus)
+			}
+		case changed:
+			if f.Hash != nil || f.HashStatus != "pending" {
+				t.Errorf("changed file: hash=%v status=%q, want nil/pending", f.Hash, f.HashStatus)
+			}
+		}
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	ctx := context.Background()
+	db := TestPostgresDB(t)
+
+	folderID, _ := AddFolder(ctx, db, "/data")
+	id, _ := UpsertFile(ctx, db, folderID, "a/b.jpg", 10, 0, 1, nil)
+	_ = UpdateFileHash(ctx, db, id, "h", time.Now())
+
+	f, err := GetFile(ctx, db, id)
+	if err != nil {
+		t.Fatalf("GetFile: %v", err)
+	}
+	if f.Path != "/data/a/b.jpg" || f.FolderID != folderID || f.Hash == nil || *f.Hash != "h" {
+		t.Errorf("GetFile = %+v, want /data/a/b.jpg with hash h", f)
+	}
+	if _, err := GetFile(ctx, db, id+1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFile(missing) err = %v, want sql.ErrNoRows", err)
+	}
+}
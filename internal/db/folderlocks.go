@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrFolderLocked is returned by AcquireFolderLock when another scan holds a live lock on the folder.
+var ErrFolderLocked = errors.New("folder is being scanned")
+
+// FolderLockedError reports which scan holds the folder lock. It matches ErrFolderLocked with errors.Is.
+type FolderLockedError struct {
+	FolderID    int64
+	ScanID      int64
+	Holder      string
+	HeartbeatAt time.Time
+}
+
+func (e *FolderLockedError) Error() string {
+	return fmt.Sprintf("folder %d is being scanned by scan %d (%s, last heartbeat %s)",
+		e.FolderID, e.ScanID, e.Holder, e.HeartbeatAt.Format(time.RFC3339))
+}
+
+func (e *FolderLockedError) Is(target error) bool { return target == ErrFolderLocked }
+
+// AcquireFolderLock takes the folder's scan lock for scanID. A lock whose heartbeat is older than staleAfter
+// (its holder crashed or was killed) is taken over; re-acquiring a lock already held by scanID succeeds.
+// Otherwise it returns a *FolderLockedError. The insert-or-takeover is a single statement, so two scans racing
+// for the same folder cannot both win.
+func AcquireFolderLock(ctx context.Context, database *sql.DB, folderID, scanID int64, holder string, staleAfter time.Duration) error {
+	now := NowUTC()
+	var got int64
+	err := database.QueryRowContext(ctx,
+		`INSERT INTO folder_locks (folder_id, scan_id, holder, acquired_at, heartbeat_at) VALUES ($1, $2, $3, $4, $4)
+		 ON CONFLICT (folder_id) DO UPDATE SET scan_id = EXCLUDED.scan_id, holder = EXCLUDED.holder,
+			acquired_at = EXCLUDED.acquired_at, heartbeat_at = EXCLUDED.heartbeat_at
+		 WHERE folder_locks.scan_id = EXCLUDED.scan_id OR folder_locks.heartbeat_at < $5
+		 RETURNING scan_id`,
+		folderID, scanID, holder, now, now.Add(-staleAfter)).Scan(&got)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	e := &FolderLockedError{FolderID: folderID}
+	if err := database.QueryRowContext(ctx,
+		`SELECT scan_id, holder, heartbeat_at FROM folder_locks WHERE folder_id = $1`, folderID).
+		Scan(&e.ScanID, &e.Holder, &e.HeartbeatAt); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return e
+}
+
+// HeartbeatFolderLock refreshes the lock's heartbeat. It returns false if scanID no longer holds the lock
+// (it went stale and another scan took it over).
+func HeartbeatFolderLock(ctx context.Context, database *sql.DB, folderID, scanID int64) (bool, error) {
+	res, err := database.ExecContext(ctx,
+		`UPDATE folder_locks SET heartbeat_at = $1 WHERE folder_id = $2 AND scan_id = $3`,
+		NowUTC(), folderID, scanID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseFolderLock drops the folder lock if scanID holds it.
+func ReleaseFolderLock(ctx context.Context, database *sql.DB, folderID, scanID int64) error {
+	_, err := database.ExecContext(ctx,
+		`DELETE FROM folder_locks WHERE folder_id = $1 AND scan_id = $2`, folderID, scanID)
+	return err
+}
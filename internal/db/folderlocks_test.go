@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFolderLock_exclusiveUntilReleasedOrStale(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	first, _ := CreateScan(ctx, database, folderID)
+	second, _ := CreateScan(ctx, database, folderID)
+
+	if err := AcquireFolderLock(ctx, database, folderID, first.ID, "a", time.Minute); err != nil {
+		t.Fatalf("AcquireFolderLock(first): %v", err)
+	}
+	if err := AcquireFolderLock(ctx, database, folderID, first.ID, "a", time.Minute); err != nil {
+		t.Errorf("re-acquire by holder: %v", err)
+	}
+	err := AcquireFolderLock(ctx, database, folderID, second.ID, "b", time.Minute)
+	var locked *FolderLockedError
+	if !errors.Is(err, ErrFolderLocked) || !errors.As(err, &locked) || locked.ScanID != first.ID {
+		t.Fatalf("AcquireFolderLock(second) = %v, want held by scan %d", err, first.ID)
+	}
+
+	// Simulate a crashed holder: its heartbeat is older than the stale threshold.
+	if _, err := database.ExecContext(ctx, `UPDATE folder_locks SET heartbeat_at = $1`, NowUTC().Add(-time.Hour)); err != nil {
+		t.Fatalf("age lock: %v", err)
+	}
+	if err := AcquireFolderLock(ctx, database, folderID, second.ID, "b", time.Minute); err != nil {
+		t.Fatalf("take over stale lock: %v", err)
+	}
+	if held, err := HeartbeatFolderLock(ctx, database, folderID, first.ID); err != nil || held {
+		t.Errorf("HeartbeatFolderLock(old holder) = %v, %v; want false", held, err)
+	}
+	if held, err := HeartbeatFolderLock(ctx, database, folderID, second.ID); err != nil || !held {
+		t.Errorf("HeartbeatFolderLock(new holder) = %v, %v; want true", held, err)
+	}
+
+	// Releasing with the wrong scan is a no-op; the holder's release frees the folder.
+	_ = ReleaseFolderLock(ctx, database, folderID, first.ID)
+	if err := AcquireFolderLock(ctx, database, folderID, first.ID, "a", time.Minute); !errors.Is(err, ErrFolderLocked) {
+		t.Errorf("acquire after foreign release = %v, want ErrFolderLocked", err)
+	}
+	if err := ReleaseFolderLock(ctx, database, folderID, second.ID); err != nil {
+		t.Fatalf("ReleaseFolderLock: %v", err)
+	}
+	if err := AcquireFolderLock(ctx, database, folderID, first.ID, "a", time.Minute); err != nil {
+		t.Errorf("acquire after release: %v", err)
+	}
+}
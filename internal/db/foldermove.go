@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrFolderPathTaken is returned by MoveFolder when another folder is registered at the new path.
+var ErrFolderPathTaken = errors.New("another scan root has this path")
+
+// ErrImportedFolder is returned by MoveFolder for an import, which has no place in the filesystem.
+var ErrImportedFolder = errors.New("an import has no path to move")
+
+// movedPaths are the columns holding full paths under a folder, with the condition limiting them to the folder
+// ($3 is its id). Files store paths relative to their folder and need no rewrite.
+var movedPaths = []struct{ table, column, scope string }{
+	{"scan_errors", "path", "scan_id IN (SELECT id FROM scans WHERE folder_id = $3)"},
+	{"scan_slow_dirs", "path", "scan_id IN (SELECT id FROM scans WHERE folder_id = $3)"},
+	{"bitrot_findings", "path", "scan_id IN (SELECT id FROM scans WHERE folder_id = $3)"},
+	{"hashes", "first_path", "first_folder_id = $3"},
+}
+
+// MoveFolder points the folder at path after its files moved there (a share reorganized or remounted), without
+// touching the disk. Files keep their ids, hashes, keepers and scan history, so the next scan finds them
+// unchanged instead of all new; full paths recorded under the old path (scan errors, slow directories, bit-rot
+// findings, where content was first seen, saved home views) are rewritten to the new one. Returns
+// a *FolderLockedError while a scan of the folder holds a lock fresher than staleAfter.
+func MoveFolder(ctx context.Context, database *sql.DB, id int64, path string, staleAfter time.Duration) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	var old string
+	var imported bool
+	if err := tx.QueryRowContext(ctx, "SELECT path, imported FROM folders WHERE id = $1 FOR UPDATE", id).Scan(&old, &imported); err != nil {
+		return err
+	}
+	if imported {
+		return ErrImportedFolder
+	}
+	if old == path {
+		return nil
+	}
+	var taken, locked bool
+	if err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM folders WHERE path = $1),
+			EXISTS (SELECT 1 FROM folder_locks WHERE folder_id = $2 AND heartbeat_at >= $3)`,
+		path, id, NowUTC().Add(-staleAfter)).Scan(&taken, &locked); err != nil {
+		return err
+	}
+	if taken {
+		return ErrFolderPathTaken
+	}
+	if locked {
+		return &FolderLockedError{FolderID: id}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE folders SET path = $1 WHERE id = $2", path, id); err != nil {
+		return err
+	}
+	for _, m := range movedPaths {
+		// #nosec G202 -- table, column and scope are fixed strings; values are placeholders
+		q := `UPDATE ` + m.table + ` SET ` + m.column + ` = $1::text || substr(` + m.column + `, char_length($2) + 1)
+			  WHERE ` + m.scope + ` AND (` + m.column + ` = $2 OR ` + m.column + ` LIKE $4)`
+		if _, err := tx.ExecContext(ctx, q, path, old, id, likeEscape(old)+"/%"); err != nil {
+			return err
+		}
+	}
+	// Saved home views name their folder by path and may filter on a directory under it.
+	if _, err := tx.ExecContext(ctx, "UPDATE preferences SET root_path = $1 WHERE root_path = $2", path, old); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE preferences SET prefix = $1::text || substr(prefix, char_length($2) + 1) WHERE prefix = $2 OR prefix LIKE $3`,
+		path, old, likeEscape(old)+"/%"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMoveFolder_keepsFilesAndRewritesPaths(t *testing.T) {
+	ctx := context.Background()
+	database := TestPostgresDB(t)
+	folderID, _ := AddFolder(ctx, database, "/volume1/photos")
+	otherID, _ := AddFolder(ctx, database, "/volume1/photos-old")
+	sc, _ := CreateScan(ctx, database, folderID)
+	dev := int64(1)
+	fileID, err := UpsertFile(ctx, database, folderID, "2024/a.jpg", 100, 0, 1, &dev)
+	if err != nil {
+		t.Fatalf("UpsertFile: %v", err)
+	}
+	_ = InsertFileScan(ctx, database, fileID, sc.ID)
+	_ = UpdateFileHash(ctx, database, fileID, "h", time.Now().UTC())
+	if err := InsertScanErrors(ctx, database, sc.ID, []ScanError{
+		{Path: "/volume1/photos/2024/b.jpg", Phase: PhaseScan, Error: "permission denied"},
+		{Path: "/volume1/photos-old/c.jpg", Phase: PhaseScan, Error: "permission denied"},
+	}); err != nil {
+		t.Fatalf("InsertScanErrors: %v", err)
+	}
+
+	if err := MoveFolder(ctx, database, folderID, "/volume2/photos", time.Minute); err != nil {
+		t.Fatalf("MoveFolder: %v", err)
+	}
+	f, err := GetFolder(ctx, database, folderID)
+	if err != nil || f.Path != "/volume2/photos" {
+		t.Fatalf("GetFolder = %+v, %v; want path /volume2/photos", f, err)
+	}
+	var n int
+	if err := database.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM files f JOIN file_scan fs ON fs.file_id = f.id
+		 WHERE f.id = $1 AND f.folder_id = $2 AND fs.scan_id = $3`, fileID, folderID, sc.ID).Scan(&n); err != nil || n != 1 {
+		t.Errorf("file %d in scan %d after the move: %d, %v; want kept", fileID, sc.ID, n, err)
+	}
+	errs, err := ListScanErrors(ctx, database, sc.ID, 10)
+	if err != nil {
+		t.Fatalf("ListScanErrors: %v", err)
+	}
+	paths := map[string]bool{}
+	for _, e := range errs {
+		paths[e.Path] = true
+	}
+	if !paths["/volume2/photos/2024/b.jpg"] || !paths["/volume1/photos-old/c.jpg"] {
+		t.Errorf("scan error paths = %v; want the moved one rewritten and the sibling left alone", paths)
+	}
+	var first string
+	if err := database.QueryRowContext(ctx, `SELECT first_path FROM hashes WHERE digest = 'h'`).Scan(&first); err != nil || first != "/volume2/photos/2024/a.jpg" {
+		t.Errorf("hashes.first_path = %q, %v; want /volume2/photos/2024/a.jpg", first, err)
+	}
+
+	if err := MoveFolder(ctx, database, otherID, "/volume2/photos", time.Minute); !errors.Is(err, ErrFolderPathTaken) {
+		t.Errorf("move onto another root's path = %v, want ErrFolderPathTaken", err)
+	}
+	otherScan, _ := CreateScan(ctx, database, otherID)
+	if err := AcquireFolderLock(ctx, database, otherID, otherScan.ID, "a", time.Minute); err != nil {
+		t.Fatalf("AcquireFolderLock: %v", err)
+	}
+	if err := MoveFolder(ctx, database, otherID, "/volume2/old", time.Minute); !errors.Is(err, ErrFolderLocked) {
+		t.Errorf("move while scanning = %v, want ErrFolderLocked", err)
+	}
+}
@@ -3,22 +3,68 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"path/filepath"
 	"time"
+
+	"github.com/eargollo/ditto/internal/pathnorm"
 )
 
 // Folder is a path configured as a scan root (folders table).
 // Exposed as ScanRoot in the API for compatibility.
 type Folder struct {
-	ID        int64
-	Path      string
-	CreatedAt time.Time
+	ID                 int64
+	Path               string
+	CreatedAt          time.Time
+	MaxReadBytesPerSec int64  // hash read throttle for this folder; 0 = unlimited
+	LowPriority        bool   // run scan/hash workers with lowered CPU and I/O priority
+	SimilarImages      bool   // compute perceptual hashes of images after the hash phase
+	SimilarMedia       bool   // probe audio/video duration (and fingerprint) after the hash phase
+	PhotoMetadata      bool   // read image dimensions and EXIF date and camera after the hash phase
+	MediaLabel         string // non-empty marks the root as offline media (removable drive or disk image)
+	MediaImage         string // disk image mounted at Path before scanning (needs DITTO_MOUNT_HELPER); "" = none
+	Imported           bool   // virtual folder filled from an external manifest; Path is "import:<name>" and cannot be scanned
+	Symlinks           string // how scans treat symlinks: SymlinksSkip, SymlinksRecord or SymlinksFollow
+	NetworkFS          string // NetworkFSAuto, NetworkFSOn or NetworkFSOff: list directories with timeouts and retries
+	OneFileSystem      bool   // do not descend into other filesystems mounted under Path
+	Archives           bool   // also list and hash the files inside zip and tar archives (see package archive)
+	UnicodeForm        string // pathnorm.None, pathnorm.NFC or pathnorm.NFD: the Unicode form file paths are stored in
+	FSType             string // filesystem type at Path when last scanned (e.g. ext4, nfs4); "" = unknown
+	DeviceID           *int64 // device id of Path when last scanned; nil = never scanned or unknown
+	Host               string // machine the files are on, as named by an agent or import; "" = this machine
+	MaxDepth           int    // skip directories more than this many levels below Path; 0 = DITTO_SCAN_MAX_DEPTH
+	MaxFiles           int64  // fail a scan that finds more files; 0 = DITTO_SCAN_MAX_FILES
+	// DisabledAt is when the folder was disabled (see SetFolderDisabled); nil = enabled.
+	DisabledAt *time.Time
 }
 
+// Symlink modes of a folder (folders.symlinks).
+const (
+	SymlinksSkip   = "skip"   // ignore symlinks (default)
+	SymlinksRecord = "record" // record each symlink as an entry with its target; never hashed
+	SymlinksFollow = "follow" // walk into linked directories and catalog linked files, with cycle detection
+)
+
+// Network-share modes of a folder (folders.network_fs).
+const (
+	NetworkFSAuto = "auto" // on when the root is on an NFS, SMB or other network filesystem (Linux); off elsewhere
+	NetworkFSOn   = "on"   // always time out and retry directory listings
+	NetworkFSOff  = "off"  // list directories without timeouts, as on local disks
+)
+
+// OfflineMedia reports whether the folder is a removable drive or disk image that may be unplugged.
+func (f *Folder) OfflineMedia() bool { return f.MediaLabel != "" }
+
+// Disabled reports whether the folder was disabled (see SetFolderDisabled).
+func (f *Folder) Disabled() bool { return f.DisabledAt != nil }
+
+// folderColumns is the SELECT list for Folder rows.
+const folderColumns = "id, path, created_at, max_read_bytes_per_sec, low_priority, similar_images, similar_media, photo_metadata, media_label, media_image, imported, symlinks, network_fs, one_file_system, archives, fs_type, device_id, unicode_form, host, disabled_at, max_depth, max_files"
+
 // ListFolders returns all folders (scan roots) ordered by id ascending.
 func ListFolders(ctx context.Context, database *sql.DB) ([]Folder, error) {
 	rows, err := database.QueryContext(ctx,
-		"SELECT id, path, created_at FROM folders ORDER BY id")
+		"SELECT "+folderColumns+" FROM folders ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -27,11 +73,9 @@ func ListFolders(ctx context.Context, database *sql.DB) ([]Folder, error) {
 	var list []Folder
 	for rows.Next() {
 		var f Folder
-		var createdAt time.Time
-		if err := rows.Scan(&f.ID, &f.Path, &createdAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.Path, &f.CreatedAt, &f.MaxReadBytesPerSec, &f.LowPriority, &f.SimilarImages, &f.SimilarMedia, &f.PhotoMetadata, &f.MediaLabel, &f.MediaImage, &f.Imported, &f.Symlinks, &f.NetworkFS, &f.OneFileSystem, &f.Archives, &f.FSType, &f.DeviceID, &f.UnicodeForm, &f.Host, &f.DisabledAt, &f.MaxDepth, &f.MaxFiles); err != nil {
 			return nil, err
 		}
-		f.CreatedAt = createdAt
 		list = append(list, f)
 	}
 	return list, rows.Err()
@@ -55,14 +99,213 @@ func AddFolder(ctx context.Context, database *sql.DB, path string) (int64, error
 func GetFolder(ctx context.Context, database *sql.DB, id int64) (*Folder, error) {
 	var f Folder
 	err := database.QueryRowContext(ctx,
-		"SELECT id, path, created_at FROM folders WHERE id = $1", id).
-		Scan(&f.ID, &f.Path, &f.CreatedAt)
+		"SELECT "+folderColumns+" FROM folders WHERE id = $1", id).
+		Scan(&f.ID, &f.Path, &f.CreatedAt, &f.MaxReadBytesPerSec, &f.LowPriority, &f.SimilarImages, &f.SimilarMedia, &f.PhotoMetadata, &f.MediaLabel, &f.MediaImage, &f.Imported, &f.Symlinks, &f.NetworkFS, &f.OneFileSystem, &f.Archives, &f.FSType, &f.DeviceID, &f.UnicodeForm, &f.Host, &f.DisabledAt, &f.MaxDepth, &f.MaxFiles)
 	if err != nil {
 		return nil, err
 	}
 	return &f, nil
 }
 
+// UpdateFolderIOSettings sets the folder's read throttle (bytes per second, 0 = unlimited) and low-priority flag.
+func UpdateFolderIOSettings(ctx context.Context, database *sql.DB, id int64, maxReadBytesPerSec int64, lowPriority bool) error {
+	if maxReadBytesPerSec < 0 {
+		maxReadBytesPerSec = 0
+	}
+	_, err := database.ExecContext(ctx,
+		"UPDATE folders SET max_read_bytes_per_sec = $1, low_priority = $2 WHERE id = $3",
+		maxReadBytesPerSec, lowPriority, id)
+	return err
+}
+
+// UpdateFolderWalkLimits sets the folder's maximum scan depth and file count (0 = the DITTO_SCAN_MAX_DEPTH and
+// DITTO_SCAN_MAX_FILES defaults).
+func UpdateFolderWalkLimits(ctx context.Context, database *sql.DB, id int64, maxDepth int, maxFiles int64) error {
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+	_, err := database.ExecContext(ctx, "UPDATE folders SET max_depth = $1, max_files = $2 WHERE id = $3", maxDepth, maxFiles, id)
+	return err
+}
+
+// UpdateFolderSimilarImages enables or disables perceptual hashing of the folder's images.
+func UpdateFolderSimilarImages(ctx context.Context, database *sql.DB, id int64, enabled bool) error {
+	_, err := database.ExecContext(ctx, "UPDATE folders SET similar_images = $1 WHERE id = $2", enabled, id)
+	return err
+}
+
+// UpdateFolderSimilarMedia enables or disables audio/video probing and fingerprinting for the folder.
+func UpdateFolderSimilarMedia(ctx context.Context, database *sql.DB, id int64, enabled bool) error {
+	_, err := database.ExecContext(ctx, "UPDATE folders SET similar_media = $1 WHERE id = $2", enabled, id)
+	return err
+}
+
+// UpdateFolderPhotoMetadata enables or disables reading the dimensions and EXIF metadata of the folder's images.
+func UpdateFolderPhotoMetadata(ctx context.Context, database *sql.DB, id int64, enabled bool) error {
+	_, err := database.ExecContext(ctx, "UPDATE folders SET photo_metadata = $1 WHERE id = $2", enabled, id)
+	return err
+}
+
+// UpdateFolderOneFileSystem sets whether scans of the folder stay on the filesystem holding its path.
+func UpdateFolderOneFileSystem(ctx context.Context, database *sql.DB, id int64, enabled bool) error {
+	_, err := database.ExecContext(ctx, "UPDATE folders SET one_file_system = $1 WHERE id = $2", enabled, id)
+	return err
+}
+
+// UpdateFolderArchives sets whether scans of the folder list the files inside zip and tar archives.
+func UpdateFolderArchives(ctx context.Context, database *sql.DB, id int64, enabled bool) error {
+	_, err := database.ExecContext(ctx, "UPDATE folders SET archives = $1 WHERE id = $2", enabled, id)
+	return err
+}
+
+// SetFolderFilesystem records the filesystem type and device id seen at the folder's path by a scan.
+func SetFolderFilesystem(ctx context.Context, database *sql.DB, id int64, fsType string, deviceID *int64) error {
+	_, err := database.ExecContext(ctx, "UPDATE folders SET fs_type = $1, device_id = $2 WHERE id = $3", fsType, deviceID, id)
+	return err
+}
+
+// UpdateFolderMedia tags the folder as offline media with the given label (empty label clears the tag) and
+// optional disk image path. Offline media keeps its last scan in duplicate detection while unplugged.
+func UpdateFolderMedia(ctx context.Context, database *sql.DB, id int64, label, image string) error {
+	_, err := database.ExecContext(ctx, "UPDATE folders SET media_label = $1, media_image = $2 WHERE id = $3", label, image, id)
+	return err
+}
+
+// UpdateFolderSymlinks sets how scans of the folder treat symlinks. Returns ErrInvalidSymlinkMode for unknown modes.
+func UpdateFolderSymlinks(ctx context.Context, database *sql.DB, id int64, mode string) error {
+	if !ValidSymlinkMode(mode) {
+		return ErrInvalidSymlinkMode
+	}
+	_, err := database.ExecContext(ctx, "UPDATE folders SET symlinks = $1 WHERE id = $2", mode, id)
+	return err
+}
+
+// ErrInvalidSymlinkMode is returned by UpdateFolderSymlinks for a mode other than the Symlinks* constants.
+var ErrInvalidSymlinkMode = errors.New("symlink mode must be skip, record or follow")
+
+// ValidSymlinkMode reports whether mode is one of SymlinksSkip, SymlinksRecord and SymlinksFollow.
+func ValidSymlinkMode(mode string) bool {
+	return mode == SymlinksSkip || mode == SymlinksRecord || mode == SymlinksFollow
+}
+
+// UpdateFolderNetworkFS sets whether scans of the folder treat it as a network share. Returns ErrInvalidNetworkFSMode
+// for unknown modes.
+func UpdateFolderNetworkFS(ctx context.Context, database *sql.DB, id int64, mode string) error {
+	if !ValidNetworkFSMode(mode) {
+		return ErrInvalidNetworkFSMode
+	}
+	_, err := database.ExecContext(ctx, "UPDATE folders SET network_fs = $1 WHERE id = $2", mode, id)
+	return err
+}
+
+// ErrInvalidNetworkFSMode is returned by UpdateFolderNetworkFS for a mode other than the NetworkFS* constants.
+var ErrInvalidNetworkFSMode = errors.New("network share mode must be auto, on or off")
+
+// ValidNetworkFSMode reports whether mode is one of NetworkFSAuto, NetworkFSOn and NetworkFSOff.
+func ValidNetworkFSMode(mode string) bool {
+	return mode == NetworkFSAuto || mode == NetworkFSOn || mode == NetworkFSOff
+}
+
+// ErrInvalidUnicodeForm is returned by UpdateFolderUnicodeForm for a form other than none, nfc and nfd.
+var ErrInvalidUnicodeForm = errors.New("unicode form must be none, nfc or nfd")
+
+// UpdateFolderUnicodeForm sets the Unicode form scans store the folder's paths in and renormalizes the paths
+// already stored. A path that was recorded in both forms (the same file seen from macOS and from Linux) is
+// merged into one file, keeping the row already in the new form and moving the other's scans to it. Returns
+// how many paths were renamed or merged. With pathnorm.None stored paths are left as they are.
+func UpdateFolderUnicodeForm(ctx context.Context, database *sql.DB, id int64, form string) (int64, error) {
+	if !pathnorm.Valid(form) {
+		return 0, ErrInvalidUnicodeForm
+	}
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, "UPDATE folders SET unicode_form = $1 WHERE id = $2", form, id); err != nil {
+		return 0, err
+	}
+	if form == pathnorm.None {
+		return 0, tx.Commit()
+	}
+	// Only paths with non-ASCII bytes can change form.
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, path FROM files WHERE folder_id = $1 AND octet_length(path) <> char_length(path) ORDER BY id", id)
+	if err != nil {
+		return 0, err
+	}
+	type rename struct {
+		id   int64
+		path string
+	}
+	var renames []rename
+	for rows.Next() {
+		var r rename
+		if err := rows.Scan(&r.id, &r.path); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if p := pathnorm.Normalize(form, r.path); p != r.path {
+			renames = append(renames, rename{r.id, p})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	merged := false
+	for _, r := range renames {
+		var existing int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM files WHERE folder_id = $1 AND path = $2", id, r.path).Scan(&existing)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if _, err := tx.ExecContext(ctx, "UPDATE files SET path = $1 WHERE id = $2", r.path, r.id); err != nil {
+				return 0, err
+			}
+		case err != nil:
+			return 0, err
+		default:
+			// Keep what each scan saw (SnapshotScanHashes) for the changes report and locked-scan checksums.
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO file_scan (file_id, scan_id, size, mtime, hash)
+				 SELECT $1, scan_id, size, mtime, hash FROM file_scan WHERE file_id = $2
+				 ON CONFLICT (file_id, scan_id) DO NOTHING`, existing, r.id); err != nil {
+				return 0, err
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM files WHERE id = $1", r.id); err != nil {
+				return 0, err
+			}
+			merged = true
+		}
+	}
+	if merged {
+		// Duplicate counts of the folder's scans change when phantom copies are merged.
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM scan_summaries WHERE scan_id IN (SELECT id FROM scans WHERE folder_id = $1)", id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(renames)), tx.Commit()
+}
+
+// SetFolderDisabled disables the folder, for a drive that is offline for a while, or enables it again. A disabled
+// folder keeps its scans and files, but is left out of All and of the home page's folders, and is not scanned
+// (ditto scan skips it, the web UI refuses to). Disabling a disabled folder keeps the time it was first disabled.
+// Returns false if there is no such folder.
+func SetFolderDisabled(ctx context.Context, database *sql.DB, id int64, disabled bool) (bool, error) {
+	res, err := database.ExecContext(ctx,
+		`UPDATE folders SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, $3) END WHERE id = $1`,
+		id, disabled, NowUTC())
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
 // DeleteFolder removes the folder with the given id. Returns false if no row was deleted.
 func DeleteFolder(ctx context.Context, database *sql.DB, id int64) (bool, error) {
 	res, err := database.ExecContext(ctx, "DELETE FROM folders WHERE id = $1", id)
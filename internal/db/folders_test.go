@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/eargollo/ditto/internal/pathnorm"
+)
+
+func TestUpdateFolderUnicodeForm_renamesAndMergesPaths(t *testing.T) {
+	db := TestPostgresDB(t)
+	ctx := context.Background()
+	const nfd, nfc = "cafe\u0301/a.jpg", "caf\u00e9/a.jpg"
+
+	folderID, _ := AddFolder(ctx, db, "/share")
+	mac, _ := CreateScan(ctx, db, folderID)
+	linux, _ := CreateScan(ctx, db, folderID)
+	macIDs, err := UpsertFilesBatch(ctx, db, folderID, []FileRow{{Path: nfd, Size: 1, MTime: 1, Inode: 1}, {Path: "b.jpg", Size: 1, MTime: 1, Inode: 2}})
+	if err != nil {
+		t.Fatalf("UpsertFilesBatch: %v", err)
+	}
+	_ = InsertFileScanBatch(ctx, db, macIDs, mac.ID)
+	_ = UpdateFileHash(ctx, db, macIDs[0], "h", time.Now())
+	_ = SnapshotScanHashes(ctx, db, mac.ID) // the earlier scan's ledger must survive the merge
+	linuxIDs, _ := UpsertFilesBatch(ctx, db, folderID, []FileRow{{Path: nfc, Size: 1, MTime: 1, Inode: 1}})
+	_ = InsertFileScanBatch(ctx, db, linuxIDs, linux.ID)
+
+	if _, err := UpdateFolderUnicodeForm(ctx, db, folderID, "nfkc"); err != ErrInvalidUnicodeForm {
+		t.Errorf("UpdateFolderUnicodeForm(nfkc) err = %v, want ErrInvalidUnicodeForm", err)
+	}
+	n, err := UpdateFolderUnicodeForm(ctx, db, folderID, pathnorm.NFC)
+	if err != nil || n != 1 {
+		t.Fatalf("UpdateFolderUnicodeForm(nfc) = %d, %v; want 1 path merged", n, err)
+	}
+	if f, _ := GetFolder(ctx, db, folderID); f.UnicodeForm != pathnorm.NFC {
+		t.Errorf("UnicodeForm = %q, want nfc", f.UnicodeForm)
+	}
+	var files, scans int
+	_ = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM files WHERE folder_id = $1", folderID).Scan(&files)
+	_ = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM file_scan WHERE file_id = $1", linuxIDs[0]).Scan(&scans)
+	if files != 2 || scans != 2 {
+		t.Errorf("after merge: %d files, NFC file in %d scans; want 2 files and the NFC one in both scans", files, scans)
+	}
+	var size, mtime sql.NullInt64
+	var hash sql.NullString
+	_ = db.QueryRowContext(ctx, "SELECT size, mtime, hash FROM file_scan WHERE file_id = $1 AND scan_id = $2", linuxIDs[0], mac.ID).
+		Scan(&size, &mtime, &hash)
+	if size.Int64 != 1 || mtime.Int64 != 1 || hash.String != "h" {
+		t.Errorf("merged snapshot of the earlier scan = %v, %v, %v; want size 1, mtime 1, hash h", size, mtime, hash)
+	}
+
+	n, err = UpdateFolderUnicodeForm(ctx, db, folderID, pathnorm.NFD)
+	if err != nil || n != 1 {
+		t.Fatalf("UpdateFolderUnicodeForm(nfd) = %d, %v; want 1 path renamed", n, err)
+	}
+	var path string
+	_ = db.QueryRowContext(ctx, "SELECT path FROM files WHERE id = $1", linuxIDs[0]).Scan(&path)
+	if path != nfd {
+		t.Errorf("path = %q, want the NFD form %q", path, nfd)
+	}
+}
+
+func TestSetFolderDisabled_keepsScansOutOfAll(t *testing.T) {
+	db := TestPostgresDB(t)
+	ctx := context.Background()
+	driveID, _ := AddFolder(ctx, db, "/mnt/drive")
+	photosID, _ := AddFolder(ctx, db, "/photos")
+	drive, _ := CreateScan(ctx, db, driveID)
+	photos, _ := CreateScan(ctx, db, photosID)
+
+	if ok, err := SetFolderDisabled(ctx, db, driveID, true); err != nil || !ok {
+		t.Fatalf("SetFolderDisabled(true) = %v, %v", ok, err)
+	}
+	f, _ := GetFolder(ctx, db, driveID)
+	if !f.Disabled() {
+		t.Fatalf("folder not disabled after SetFolderDisabled(true)")
+	}
+	since := *f.DisabledAt
+	_, _ = SetFolderDisabled(ctx, db, driveID, true)
+	if f, _ := GetFolder(ctx, db, driveID); !f.DisabledAt.Equal(since) {
+		t.Errorf("disabling again moved DisabledAt from %v to %v", since, f.DisabledAt)
+	}
+	got, err := FilterScansIncludedInAll(ctx, db, []int64{drive.ID, photos.ID})
+	if err != nil || len(got) != 1 || got[0] != photos.ID {
+		t.Errorf("FilterScansIncludedInAll with the drive disabled = %v, %v; want [%d]", got, err, photos.ID)
+	}
+	if _, err := GetScan(ctx, db, drive.ID); err != nil {
+		t.Errorf("scan of the disabled folder: %v", err)
+	}
+
+	if ok, err := SetFolderDisabled(ctx, db, driveID, false); err != nil || !ok {
+		t.Fatalf("SetFolderDisabled(false) = %v, %v", ok, err)
+	}
+	if f, _ := GetFolder(ctx, db, driveID); f.Disabled() {
+		t.Errorf("folder still disabled after SetFolderDisabled(false)")
+	}
+	if got, _ := FilterScansIncludedInAll(ctx, db, []int64{drive.ID, photos.ID}); len(got) != 2 {
+		t.Errorf("FilterScansIncludedInAll after enabling = %v, want both scans", got)
+	}
+	if ok, _ := SetFolderDisabled(ctx, db, -1, true); ok {
+		t.Errorf("SetFolderDisabled(missing folder) = true")
+	}
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// HashAlgorithm is the algorithm of every hash ditto computes (hashes.algorithm).
+const HashAlgorithm = "sha256"
+
+// KnownContent is a content ditto has hashed at least once (hashes table). It outlives the files and scans it
+// was seen in, so it remembers where and when the content was first seen.
+//
+// files.hash stays the source of truth and files.hash_id is derived from it by a trigger (migration 0037). Every
+// duplicate report groups, filters and joins files on the indexed hash text, and the scan snapshots (file_scan.hash)
+// and manifests store it too; moving all of them to hash_id would add a join to the hottest queries for no gain in
+// correctness. The table is the durable, content-addressed record that outlives files; hash_id only links to it.
+type KnownContent struct {
+	ID            int64
+	Algorithm     string
+	Digest        string
+	Size          int64
+	FirstSeenAt   time.Time
+	FirstFolderID *int64 // nil once the folder is deleted
+	FirstPath     string // full path of the first file seen with it
+}
+
+// ContentLocation is a file of any folder and scan that has a given content.
+type ContentLocation struct {
+	FileID       int64
+	FolderID     int64
+	Path         string // full path
+	MTime        int64
+	Scans        int64      // scans listing the file; 0 when its scans were deleted
+	LastScanID   *int64     // newest scan listing the file
+	LastScanTime *time.Time // when that scan started
+}
+
+// GetKnownContent returns the stored content with this digest, or (nil, nil) when ditto has never hashed it.
+// A digest shared by files of different sizes (never for SHA-256 in practice) returns the first one stored.
+func GetKnownContent(ctx context.Context, database *sql.DB, digest string) (*KnownContent, error) {
+	var c KnownContent
+	var folderID sql.NullInt64
+	err := database.QueryRowContext(ctx,
+		`SELECT id, algorithm, digest, size, first_seen_at, first_folder_id, first_path FROM hashes
+		 WHERE algorithm = $1 AND digest = $2 ORDER BY id LIMIT 1`, HashAlgorithm, digest).
+		Scan(&c.ID, &c.Algorithm, &c.Digest, &c.Size, &c.FirstSeenAt, &folderID, &c.FirstPath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if folderID.Valid {
+		c.FirstFolderID = &folderID.Int64
+	}
+	return &c, nil
+}
+
+// ContentLocations returns up to limit files with the content, in every folder visible to viewerID (all folders
+// when nil, see FolderVisibleTo) and whether or not a current scan lists them, most recently scanned first.
+// Folders are filtered before the limit, so hidden copies never crowd out visible ones.
+func ContentLocations(ctx context.Context, database *sql.DB, contentID int64, viewerID *int64, limit int) ([]ContentLocation, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, f.folder_id, fo.path || '/' || f.path, f.mtime, COUNT(fs.scan_id), MAX(fs.scan_id), MAX(s.started_at)
+		 FROM files f JOIN folders fo ON fo.id = f.folder_id
+		 LEFT JOIN file_scan fs ON fs.file_id = f.id LEFT JOIN scans s ON s.id = fs.scan_id
+		 WHERE f.hash_id = $1 AND ($2::bigint IS NULL OR fo.owner_id IS NULL OR fo.owner_id = $2)
+		 GROUP BY f.id, fo.path
+		 ORDER BY MAX(s.started_at) DESC NULLS LAST, 3
+		 LIMIT $3`, contentID, viewerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ContentLocation
+	for rows.Next() {
+		var l ContentLocation
+		var lastScan sql.NullInt64
+		var lastTime sql.NullTime
+		if err := rows.Scan(&l.FileID, &l.FolderID, &l.Path, &l.MTime, &l.Scans, &lastScan, &lastTime); err != nil {
+			return nil, err
+		}
+		if lastScan.Valid {
+			l.LastScanID = &lastScan.Int64
+		}
+		if lastTime.Valid {
+			l.LastScanTime = &lastTime.Time
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKnownContent_outlivesScansAndListsLocations(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	photos, _ := AddFolder(ctx, database, "/photos")
+	backup, _ := AddFolder(ctx, database, "/backup")
+	s1, _ := CreateScan(ctx, database, photos)
+	s2, _ := CreateScan(ctx, database, backup)
+	first, _ := UpsertFile(ctx, database, photos, "2019/a.jpg", 100, 1, 1, nil)
+	_ = InsertFileScan(ctx, database, first, s1.ID)
+	_ = UpdateFileHash(ctx, database, first, "h1", time.Now())
+	copyID, _ := UpsertFile(ctx, database, backup, "old/a.jpg", 100, 1, 2, nil)
+	_ = InsertFileScan(ctx, database, copyID, s2.ID)
+	_ = UpdateFileHash(ctx, database, copyID, "h1", time.Now())
+
+	if c, err := GetKnownContent(ctx, database, "unknown"); err != nil || c != nil {
+		t.Errorf("GetKnownContent(unknown) = %+v, %v; want nil", c, err)
+	}
+	if err := DeleteScan(ctx, database, s1.ID); err != nil {
+		t.Fatalf("DeleteScan: %v", err)
+	}
+	c, err := GetKnownContent(ctx, database, "h1")
+	if err != nil || c == nil {
+		t.Fatalf("GetKnownContent(h1) = %+v, %v", c, err)
+	}
+	if c.Size != 100 || c.Algorithm != HashAlgorithm || c.FirstPath != "/photos/2019/a.jpg" ||
+		c.FirstFolderID == nil || *c.FirstFolderID != photos {
+		t.Errorf("content = %+v, want first seen as /photos/2019/a.jpg", c)
+	}
+
+	locs, err := ContentLocations(ctx, database, c.ID, nil, 10)
+	if err != nil {
+		t.Fatalf("ContentLocations: %v", err)
+	}
+	if len(locs) != 2 || locs[0].Path != "/backup/old/a.jpg" || locs[0].Scans != 1 || locs[1].Scans != 0 {
+		t.Errorf("locations = %+v, want the backup copy (1 scan) then the unscanned original", locs)
+	}
+
+	// A user who cannot see /backup gets the visible copy even with a limit of one.
+	alice, _ := GetOrCreateUser(ctx, database, "alice", RoleViewer)
+	bob, _ := GetOrCreateUser(ctx, database, "bob", RoleViewer)
+	_ = SetFolderOwner(ctx, database, backup, &bob.ID)
+	if locs, err := ContentLocations(ctx, database, c.ID, &alice.ID, 1); err != nil || len(locs) != 1 || locs[0].Path != "/photos/2019/a.jpg" {
+		t.Errorf("locations visible to alice = %+v, %v; want only /photos/2019/a.jpg", locs, err)
+	}
+
+	// A rehash to other content moves the file off the old entry.
+	_ = UpdateFileHash(ctx, database, copyID, "h2", time.Now())
+	if locs, _ := ContentLocations(ctx, database, c.ID, nil, 10); len(locs) != 1 {
+		t.Errorf("after rehash locations = %+v, want 1", locs)
+	}
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// hashPlanHistoryScans is how many recent completed hash phases feed the throughput estimate.
+const hashPlanHistoryScans = 5
+
+// HashPlan estimates the work left in a scan's hash phase before it runs.
+type HashPlan struct {
+	Files         int64 // pending hash candidates
+	Bytes         int64
+	ReusableFiles int64 // candidates that match an already hashed file by (inode, device_id, size): no read needed
+	ReusableBytes int64
+	BytesPerSec   int64 // read throughput of recent hash phases; 0 when there is no history
+	HistoryScans  int   // completed hash phases the throughput is based on
+	FolderHistory bool  // throughput comes from this folder's scans (false: from all folders)
+}
+
+// ReadBytes is the number of bytes the hash phase is expected to read.
+func (p *HashPlan) ReadBytes() int64 { return p.Bytes - p.ReusableBytes }
+
+// Estimate is the expected hash-phase duration, or 0 when there is no throughput history.
+func (p *HashPlan) Estimate() time.Duration {
+	if p.BytesPerSec <= 0 {
+		return 0
+	}
+	return time.Duration(float64(p.ReadBytes()) / float64(p.BytesPerSec) * float64(time.Second)).Round(time.Second)
+}
+
+// PlanHashPhase counts the scan's pending hash candidates (leaving out hardlink-only groups) and estimates read throughput from the folder's
+// last completed hash phases (or all folders' when the folder has none). Pauses inside past phases count as
+// hashing time, so the estimate errs on the long side.
+func PlanHashPhase(ctx context.Context, database *sql.DB, scanID int64) (*HashPlan, error) {
+	p := &HashPlan{}
+	err := database.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(f.size), 0),
+			COUNT(*) FILTER (WHERE r.reusable), COALESCE(SUM(f.size) FILTER (WHERE r.reusable), 0)
+		FROM files f
+		JOIN file_scan fs ON f.id = fs.file_id
+		CROSS JOIN LATERAL (SELECT EXISTS (
+			SELECT 1 FROM files o WHERE o.inode = f.inode AND o.device_id IS NOT DISTINCT FROM f.device_id
+			AND o.size = f.size AND o.hash IS NOT NULL
+		) AS reusable) r
+		WHERE fs.scan_id = $1 AND f.hash_status = 'pending' AND f.size IN (`+sizeCandidateSubquery+`)
+		AND f.size NOT IN (`+hardlinkOnlySizes+`)`, scanID).
+		Scan(&p.Files, &p.Bytes, &p.ReusableFiles, &p.ReusableBytes)
+	if err != nil {
+		return nil, err
+	}
+	var folderID int64
+	if err := database.QueryRowContext(ctx, `SELECT folder_id FROM scans WHERE id = $1`, scanID).Scan(&folderID); err != nil {
+		return nil, err
+	}
+	// folder_id 0 matches no folder and widens the query to all folders.
+	for _, historyFolder := range []int64{folderID, 0} {
+		var bytes, seconds float64
+		var n int
+		err := database.QueryRowContext(ctx, `
+			SELECT COUNT(*), COALESCE(SUM(hash_read_bytes), 0),
+				COALESCE(SUM(EXTRACT(EPOCH FROM hash_completed_at - hash_started_at)), 0)
+			FROM (
+				SELECT hash_read_bytes, hash_started_at, hash_completed_at FROM scans
+				WHERE id <> $1 AND ($2 = 0 OR folder_id = $2)
+				AND hash_completed_at IS NOT NULL AND hash_started_at IS NOT NULL AND hash_read_bytes > 0
+				ORDER BY hash_completed_at DESC LIMIT $3
+			) recent`,
+			scanID, historyFolder, hashPlanHistoryScans).Scan(&n, &bytes, &seconds)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 && seconds > 0 && bytes >= seconds {
+			p.BytesPerSec, p.HistoryScans, p.FolderHistory = int64(bytes/seconds), n, historyFolder != 0
+			break
+		}
+	}
+	return p, nil
+}
+
+// AddScanHashReadBytes adds to the bytes read by the scan's hash phase (a paused phase resumes and adds more).
+func AddScanHashReadBytes(ctx context.Context, database *sql.DB, scanID, n int64) error {
+	_, err := database.ExecContext(ctx, `UPDATE scans SET hash_read_bytes = hash_read_bytes + $1 WHERE id = $2`, n, scanID)
+	return err
+}
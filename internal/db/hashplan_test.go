@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPlanHashPhase_countsCandidatesAndUsesFolderThroughput(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	otherID, _ := AddFolder(ctx, database, "/other")
+	// Already hashed elsewhere: a pending file with the same inode and size reuses it.
+	known, _ := UpsertFile(ctx, database, otherID, "known.bin", 100, 1, 5, nil)
+	if err := UpdateFileHash(ctx, database, known, "h", NowUTC()); err != nil {
+		t.Fatalf("UpdateFileHash: %v", err)
+	}
+
+	prev, _ := CreateScan(ctx, database, folderID)
+	start := NowUTC().Add(-time.Hour)
+	if _, err := database.ExecContext(ctx,
+		`UPDATE scans SET hash_started_at = $1, hash_completed_at = $2, hash_read_bytes = 1000 WHERE id = $3`,
+		start, start.Add(10*time.Second), prev.ID); err != nil {
+		t.Fatalf("set history: %v", err)
+	}
+
+	sn, _ := CreateScan(ctx, database, folderID)
+	for _, f := range []struct {
+		path        string
+		size, inode int64
+	}{{"reused.bin", 100, 5}, {"new.bin", 100, 6}, {"unique.bin", 7, 7}} {
+		id, _ := UpsertFile(ctx, database, folderID, f.path, f.size, 1, f.inode, nil)
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+	}
+
+	p, err := PlanHashPhase(ctx, database, sn.ID)
+	if err != nil {
+		t.Fatalf("PlanHashPhase: %v", err)
+	}
+	if p.Files != 2 || p.Bytes != 200 || p.ReusableFiles != 1 || p.ReusableBytes != 100 {
+		t.Errorf("plan = %d files %d bytes, %d reusable %d bytes; want 2, 200, 1, 100",
+			p.Files, p.Bytes, p.ReusableFiles, p.ReusableBytes)
+	}
+	if p.BytesPerSec != 100 || p.HistoryScans != 1 || !p.FolderHistory {
+		t.Errorf("throughput = %d B/s from %d scans (folder %v); want 100, 1, true", p.BytesPerSec, p.HistoryScans, p.FolderHistory)
+	}
+	if got := p.Estimate(); got != time.Second {
+		t.Errorf("Estimate() = %v, want 1s", got)
+	}
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// HashProgress is a running hash phase's progress, as last written by the phase.
+type HashProgress struct {
+	Total     int64     // files the phase set out to hash
+	Done      int64     // files processed so far (hashed, reused or failed)
+	ReadBytes int64     // bytes read so far
+	StartedAt time.Time // start of this run of the phase (a resumed phase starts over)
+	UpdatedAt time.Time
+}
+
+// Elapsed is the time from the start of the run to the last update.
+func (p *HashProgress) Elapsed() time.Duration { return p.UpdatedAt.Sub(p.StartedAt) }
+
+// Remaining is the number of files left; never negative.
+func (p *HashProgress) Remaining() int64 {
+	if p.Done >= p.Total {
+		return 0
+	}
+	return p.Total - p.Done
+}
+
+// Percent is the share of files processed, capped at 100.
+func (p *HashProgress) Percent() float64 {
+	if p.Total <= 0 || p.Done >= p.Total {
+		return 100
+	}
+	return 100 * float64(p.Done) / float64(p.Total)
+}
+
+// FilesPerSec is the average file rate since the start of the run.
+func (p *HashProgress) FilesPerSec() float64 {
+	if s := p.Elapsed().Seconds(); s > 0 {
+		return float64(p.Done) / s
+	}
+	return 0
+}
+
+// MBPerSec is the average read throughput since the start of the run, in MiB per second.
+func (p *HashProgress) MBPerSec() float64 {
+	if s := p.Elapsed().Seconds(); s > 0 {
+		return float64(p.ReadBytes) / (1024 * 1024) / s
+	}
+	return 0
+}
+
+// ETA extrapolates the time left from the file rate so far, or 0 when there is no rate yet (the first second).
+func (p *HashProgress) ETA() time.Duration {
+	rate := p.FilesPerSec()
+	if rate <= 0 || p.Elapsed() <= time.Second {
+		return 0
+	}
+	return time.Duration(float64(p.Remaining()) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// UpdateScanHashProgress records the running hash phase's counts and the time of the update.
+func UpdateScanHashProgress(ctx context.Context, database *sql.DB, scanID, total, done, readBytes int64) error {
+	_, err := database.ExecContext(ctx,
+		`UPDATE scans SET hash_progress_total = $1, hash_progress_done = $2, hash_progress_bytes = $3, hash_progress_at = $4
+		 WHERE id = $5`,
+		total, done, readBytes, NowUTC(), scanID)
+	return err
+}
+
+// GetScanHashProgress returns the progress last written by the scan's hash phase, or (nil, nil) when the
+// current run has not written any yet.
+func GetScanHashProgress(ctx context.Context, database *sql.DB, scanID int64) (*HashProgress, error) {
+	var p HashProgress
+	var total, done, readBytes sql.NullInt64
+	var startedAt, updatedAt sql.NullTime
+	err := database.QueryRowContext(ctx,
+		`SELECT hash_progress_total, hash_progress_done, hash_progress_bytes, hash_started_at, hash_progress_at
+		 FROM scans WHERE id = $1`, scanID).Scan(&total, &done, &readBytes, &startedAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if !total.Valid || !startedAt.Valid || !updatedAt.Valid {
+		return nil, nil
+	}
+	p.Total, p.Done, p.ReadBytes = total.Int64, done.Int64, readBytes.Int64
+	p.StartedAt, p.UpdatedAt = startedAt.Time, updatedAt.Time
+	return &p, nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHashProgress_rateAndETA(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := HashProgress{Total: 1000, Done: 250, ReadBytes: 600 << 20, StartedAt: start, UpdatedAt: start.Add(time.Minute)}
+	if got := p.Remaining(); got != 750 {
+		t.Errorf("Remaining = %d, want 750", got)
+	}
+	if got := p.FilesPerSec(); got < 4.16 || got > 4.17 {
+		t.Errorf("FilesPerSec = %v, want ~4.17", got)
+	}
+	if got := p.MBPerSec(); got != 10 {
+		t.Errorf("MBPerSec = %v, want 10", got)
+	}
+	if got := p.ETA(); got != 3*time.Minute {
+		t.Errorf("ETA = %v, want 3m", got)
+	}
+
+	p.UpdatedAt = start // no elapsed time: no rate, no estimate
+	if got := p.ETA(); got != 0 {
+		t.Errorf("ETA at start = %v, want 0", got)
+	}
+	p.Done = 1200 // workers may race past the counted total
+	if p.Remaining() != 0 || p.Percent() != 100 {
+		t.Errorf("past total: Remaining %d, Percent %v; want 0, 100", p.Remaining(), p.Percent())
+	}
+}
+
+func TestUpdateScanHashProgress_clearedByNextHashStart(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	scan, _ := CreateScan(ctx, database, folderID)
+	_ = UpdateScanHashStartedAt(ctx, database, scan.ID)
+	if p, err := GetScanHashProgress(ctx, database, scan.ID); err != nil || p != nil {
+		t.Fatalf("before first update: %+v, %v; want nil", p, err)
+	}
+	if err := UpdateScanHashProgress(ctx, database, scan.ID, 10, 4, 4096); err != nil {
+		t.Fatalf("UpdateScanHashProgress: %v", err)
+	}
+	p, err := GetScanHashProgress(ctx, database, scan.ID)
+	if err != nil || p == nil {
+		t.Fatalf("GetScanHashProgress = %v, %v", p, err)
+	}
+	if p.Total != 10 || p.Done != 4 || p.ReadBytes != 4096 || p.StartedAt.IsZero() || p.UpdatedAt.IsZero() {
+		t.Errorf("progress = %+v", p)
+	}
+
+	_ = UpdateScanHashStartedAt(ctx, database, scan.ID) // resumed phase starts over
+	if p, _ := GetScanHashProgress(ctx, database, scan.ID); p != nil {
+		t.Errorf("after restart: %+v, want nil", p)
+	}
+}
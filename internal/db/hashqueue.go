@@ -9,13 +9,14 @@ import (
 // - same size appears more than once in the current scan, OR
 // - same size as any already-hashed file (any scan), OR
 // - same size as any file in another scan (cross-folder duplicates when size is unique per scan).
+// Recorded symlinks have no content of their own and are left out.
 // Parameter $1 = current scan_id.
 const sizeCandidateSubquery = `
-		SELECT f2.size FROM files f2 JOIN file_scan fs2 ON f2.id = fs2.file_id WHERE fs2.scan_id = $1 GROUP BY f2.size HAVING COUNT(*) > 1
+		SELECT f2.size FROM files f2 JOIN file_scan fs2 ON f2.id = fs2.file_id WHERE fs2.scan_id = $1 AND f2.hash_status <> 'symlink' GROUP BY f2.size HAVING COUNT(*) > 1
 		UNION
 		SELECT size FROM files WHERE hash_status = 'done'
 		UNION
-		SELECT f2.size FROM files f2 JOIN file_scan fs2 ON f2.id = fs2.file_id WHERE fs2.scan_id != $1`
+		SELECT f2.size FROM files f2 JOIN file_scan fs2 ON f2.id = fs2.file_id WHERE fs2.scan_id != $1 AND f2.hash_status <> 'symlink'`
 
 // CountHashCandidates returns the number of files in this scan that are hash candidates.
 func CountHashCandidates(ctx context.Context, db *sql.DB, scanID int64) (int64, error) {
@@ -36,6 +37,67 @@ const pendingHashJobsQuery = `
 	AND f.size IN (` + sizeCandidateSubquery + `)
 	ORDER BY f.size DESC`
 
+// hardlinkOnlySizes selects the sizes whose files in scan $1 are all links to one inode (at least two of them),
+// with no file of that size anywhere else that has another inode or an already computed hash: such a group can only
+// be a set of hardlinks, which share their content and take no extra space, so reading it would be wasted I/O.
+const hardlinkOnlySizes = `
+		SELECT g.size FROM (
+			SELECT f.size, MIN(f.inode) AS inode, MIN(f.device_id) AS device_id FROM files f JOIN file_scan fs ON f.id = fs.file_id
+			WHERE fs.scan_id = $1 AND f.hash_status IN ('pending', 'hardlink')
+			GROUP BY f.size
+			HAVING COUNT(*) > 1 AND COUNT(DISTINCT f.inode) = 1 AND COUNT(DISTINCT COALESCE(f.device_id, -1)) = 1
+		) g
+		WHERE NOT EXISTS (
+			SELECT 1 FROM files o WHERE o.size = g.size AND o.hash_status <> 'symlink'
+			AND (o.hash_status = 'done' OR o.inode <> g.inode OR o.device_id IS DISTINCT FROM g.device_id)
+		)`
+
+// hardlinkBroken is true for a file of the files table that some other file of its size, in any folder, keeps from
+// being a hardlink-only group: a file with another inode or device, or one already hashed.
+const hardlinkBroken = `EXISTS (
+			SELECT 1 FROM files o WHERE o.size = files.size AND o.hash_status <> 'symlink'
+			AND (o.hash_status = 'done' OR o.inode <> files.inode OR o.device_id IS DISTINCT FROM files.device_id))`
+
+// MarkHardlinkOnlyGroups sets hash_status = 'hardlink' (no hash) on the scan's pending files whose size group consists
+// only of hardlinks to one inode (see hardlinkOnlySizes), so the hash phase skips them without reading content.
+// Files marked earlier go back to 'pending' when their size group no longer qualifies: in the scan, or in any folder
+// once a file of the same size appears elsewhere (hardlinkBroken), so the next scan of their folder hashes them.
+// Returns the number of files in the scan now marked 'hardlink'.
+func MarkHardlinkOnlyGroups(ctx context.Context, database *sql.DB, scanID int64) (int64, error) {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE files SET hash_status = 'pending'
+		 WHERE hash_status = 'hardlink' AND (`+hardlinkBroken+`
+		 OR (id IN (SELECT file_id FROM file_scan WHERE scan_id = $1) AND size NOT IN (`+hardlinkOnlySizes+`)))`,
+		scanID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE files SET hash_status = 'hardlink', hash = NULL, hashed_at = NULL, hash_source = NULL
+		 WHERE id IN (SELECT file_id FROM file_scan WHERE scan_id = $1) AND hash_status = 'pending'
+		 AND size IN (`+hardlinkOnlySizes+`)`,
+		scanID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return CountHardlinkOnlyFiles(ctx, database, scanID)
+}
+
+// CountHardlinkOnlyFiles returns the number of the scan's files skipped by the hash phase as hardlink-only groups.
+func CountHardlinkOnlyFiles(ctx context.Context, database *sql.DB, scanID int64) (int64, error) {
+	var n int64
+	err := database.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM files f JOIN file_scan fs ON f.id = fs.file_id WHERE fs.scan_id = $1 AND f.hash_status = 'hardlink'`,
+		scanID).Scan(&n)
+	return n, err
+}
+
 // ForEachPendingHashJob runs one query to stream all pending hash jobs for the scan. For each row it calls fn.
 func ForEachPendingHashJob(ctx context.Context, database *sql.DB, scanID int64, fn func(*File) error) error {
 	rows, err := database.QueryContext(ctx, pendingHashJobsQuery, scanID, scanID)
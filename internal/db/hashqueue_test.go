@@ -165,3 +165,61 @@ func TestClaimNextHashJob_setsStatusToHashingAndDoesNotReturnSameRowTwice(t *tes
 		t.Error("second claim returned same file as first")
 	}
 }
+
+func TestMarkHardlinkOnlyGroups_skipsGroupsOfOneInodeUntilAnotherFileAppears(t *testing.T) {
+	db := TestPostgresDB(t)
+	ctx := context.Background()
+	dev := int64(1)
+
+	add := func(folderID, scanID int64, path string, size, inode int64) {
+		t.Helper()
+		fileID, err := UpsertFile(ctx, db, folderID, path, size, 0, inode, &dev)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		if err := InsertFileScan(ctx, db, fileID, scanID); err != nil {
+			t.Fatalf("InsertFileScan: %v", err)
+		}
+	}
+	backups, _ := AddFolder(ctx, db, "/backups")
+	scan, _ := CreateScan(ctx, db, backups)
+	for _, p := range []string{"day1/a", "day2/a", "day3/a"} {
+		add(backups, scan.ID, p, 500, 7) // rsync --link-dest snapshots: one inode
+	}
+	add(backups, scan.ID, "day1/b", 600, 8) // real duplicates: two inodes
+	add(backups, scan.ID, "day2/b", 600, 9)
+	add(backups, scan.ID, "day1/c", 700, 10)
+	add(backups, scan.ID, "day2/c", 700, 10)
+	other, _ := AddFolder(ctx, db, "/other")
+	otherScan, _ := CreateScan(ctx, db, other)
+	add(other, otherScan.ID, "c", 700, 11) // same size elsewhere: the 700 group must be hashed
+
+	n, err := MarkHardlinkOnlyGroups(ctx, db, scan.ID)
+	if err != nil {
+		t.Fatalf("MarkHardlinkOnlyGroups: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("MarkHardlinkOnlyGroups = %d, want 3", n)
+	}
+	if c, _ := CountHashCandidates(ctx, db, scan.ID); c != 4 {
+		t.Errorf("CountHashCandidates = %d, want 4 (sizes 600 and 700)", c)
+	}
+
+	add(other, otherScan.ID, "a", 500, 12)
+	if _, err := MarkHardlinkOnlyGroups(ctx, db, otherScan.ID); err != nil { // the other folder's hash phase
+		t.Fatalf("MarkHardlinkOnlyGroups: %v", err)
+	}
+	if n, _ := CountHardlinkOnlyFiles(ctx, db, scan.ID); n != 0 {
+		t.Errorf("hardlink files after a same-size file appeared in another folder = %d, want 0", n)
+	}
+	n, err = MarkHardlinkOnlyGroups(ctx, db, scan.ID)
+	if err != nil {
+		t.Fatalf("MarkHardlinkOnlyGroups: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("MarkHardlinkOnlyGroups after a same-size file appeared = %d, want 0", n)
+	}
+	if c, _ := CountHashCandidates(ctx, db, scan.ID); c != 7 {
+		t.Errorf("CountHashCandidates = %d, want 7", c)
+	}
+}
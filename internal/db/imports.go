@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ImportFolderPrefix prefixes the path of virtual folders created for imported manifests.
+const ImportFolderPrefix = "import:"
+
+// GetOrCreateImportFolder returns the virtual folder for an imported manifest named name, creating it if needed.
+// Its path is ImportFolderPrefix + name, so it never collides with a real (absolute) scan root. A non-empty host
+// records the machine the manifest describes; an empty one keeps the host already recorded.
+func GetOrCreateImportFolder(ctx context.Context, database *sql.DB, name, host string) (int64, error) {
+	var id int64
+	err := database.QueryRowContext(ctx,
+		`INSERT INTO folders (path, created_at, imported, host) VALUES ($1, $2, TRUE, $3)
+		 ON CONFLICT (path) DO UPDATE SET imported = TRUE,
+			host = CASE WHEN EXCLUDED.host <> '' THEN EXCLUDED.host ELSE folders.host END
+		 RETURNING id`,
+		ImportFolderPrefix+name, NowUTC(), host).Scan(&id)
+	return id, err
+}
+
+// ImportedFile is one file of an external manifest. Path is relative to the manifest root; Hash is hex SHA-256.
+type ImportedFile struct {
+	Path string
+	Size int64
+	Hash string
+}
+
+// InsertImportedFiles stores a batch of manifest files as already hashed and links them to the scan.
+// Each file gets a synthetic negative inode (its negated id) and no device, so imported files never look like
+// hardlinks of each other or of local files, and always count as being on another device.
+func InsertImportedFiles(ctx context.Context, database *sql.DB, folderID, scanID int64, files []ImportedFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	const colsPerRow = 4
+	ph := make([]string, len(files))
+	args := make([]interface{}, 0, len(files)*colsPerRow+1)
+	args = append(args, NowUTC())
+	for i, f := range files {
+		base := 1 + i*colsPerRow
+		ph[i] = fmt.Sprintf("($%d,$%d,$%d,0,0,NULL,$%d,'done',$1,'imported')", base+1, base+2, base+3, base+4)
+		args = append(args, folderID, f.Path, f.Size, f.Hash)
+	}
+	// #nosec G202 -- placeholders built from len(files); all values passed as args
+	rows, err := database.QueryContext(ctx,
+		`INSERT INTO files (folder_id, path, size, mtime, inode, device_id, hash, hash_status, hashed_at, hash_source)
+		 VALUES `+strings.Join(ph, ", ")+`
+		 ON CONFLICT (folder_id, path) DO UPDATE SET size = EXCLUDED.size, hash = EXCLUDED.hash,
+			hash_status = 'done', hashed_at = EXCLUDED.hashed_at, hash_source = EXCLUDED.hash_source
+		 RETURNING id`, args...)
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, 0, len(files))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if _, err := database.ExecContext(ctx, "UPDATE files SET inode = -id WHERE id = ANY($1)", ids); err != nil {
+		return err
+	}
+	return InsertFileScanBatch(ctx, database, ids, scanID)
+}
+
+// CompleteImportScan marks an import scan as scanned and hashed, with every file counted as hashed.
+func CompleteImportScan(ctx context.Context, database *sql.DB, scanID int64) error {
+	var files, bytes int64
+	if err := database.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(f.size), 0) FROM files f JOIN file_scan fs ON f.id = fs.file_id WHERE fs.scan_id = $1`,
+		scanID).Scan(&files, &bytes); err != nil {
+		return err
+	}
+	if err := UpdateScanCompletedAt(ctx, database, scanID, files, 0); err != nil {
+		return err
+	}
+	if err := UpdateScanHashStartedAt(ctx, database, scanID); err != nil {
+		return err
+	}
+	if err := UpdateScanHashCompletedAt(ctx, database, scanID, files, bytes, 0, 0); err != nil {
+		return err
+	}
+	_, err := RefreshScanSummary(ctx, database, scanID)
+	return err
+}
+
+// ScanComparison summarises which files of a scan also exist (same content) in another scan.
+type ScanComparison struct {
+	Matched        []File // files of the scan whose hash appears in the other scan (up to the limit)
+	MatchedCount   int64
+	MatchedBytes   int64
+	UnhashedBySize int64 // files of the scan not hashed yet whose size matches a file in the other scan
+	OnlyHereCount  int64 // hashed files of the scan whose content is not in the other scan
+}
+
+// CompareScans reports the files of scanID whose content (hash) also appears in otherScanID, e.g. local files
+// already present on a drive known only from an imported manifest. At most limit matches are returned.
+func CompareScans(ctx context.Context, database *sql.DB, scanID, otherScanID int64, limit int) (*ScanComparison, error) {
+	c := &ScanComparison{}
+	const other = `SELECT f2.hash FROM files f2 JOIN file_scan fs2 ON f2.id = fs2.file_id WHERE fs2.scan_id = $2 AND f2.hash_status = 'done'`
+	err := database.QueryRowContext(ctx,
+		`SELECT
+			COUNT(*) FILTER (WHERE f.hash_status = 'done' AND f.hash IN (`+other+`)),
+			COALESCE(SUM(f.size) FILTER (WHERE f.hash_status = 'done' AND f.hash IN (`+other+`)), 0),
+			COUNT(*) FILTER (WHERE f.hash_status = 'done' AND f.hash NOT IN (`+other+`)),
+			COUNT(*) FILTER (WHERE f.hash_status <> 'done' AND f.size IN (
+				SELECT f3.size FROM files f3 JOIN file_scan fs3 ON f3.id = fs3.file_id WHERE fs3.scan_id = $2))
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id WHERE fs.scan_id = $1`,
+		scanID, otherScanID).Scan(&c.MatchedCount, &c.MatchedBytes, &c.OnlyHereCount, &c.UnhashedBySize)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, $1::bigint, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id = $1 AND f.hash_status = 'done' AND f.hash IN (`+other+`)
+		 ORDER BY f.size DESC, f.id LIMIT $3`,
+		scanID, otherScanID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if c.Matched, err = scanFiles(rows); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListImportScans returns the scans of imported manifests, newest first.
+func ListImportScans(ctx context.Context, database *sql.DB) ([]Scan, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT `+scanColumns+` FROM scans s JOIN folders f ON s.folder_id = f.id
+		 WHERE f.imported ORDER BY s.started_at DESC, s.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var scans []Scan
+	for rows.Next() {
+		s, err := scanScanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, *s)
+	}
+	return scans, rows.Err()
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestImportedFiles_compareWithLocalScan(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	importID, err := GetOrCreateImportFolder(ctx, database, "remote", "")
+	if err != nil {
+		t.Fatalf("GetOrCreateImportFolder: %v", err)
+	}
+	if again, _ := GetOrCreateImportFolder(ctx, database, "remote", ""); again != importID {
+		t.Errorf("GetOrCreateImportFolder twice = %d, %d; want same folder", importID, again)
+	}
+	imp, _ := CreateScan(ctx, database, importID)
+	if err := InsertImportedFiles(ctx, database, importID, imp.ID, []ImportedFile{
+		{Path: "a.jpg", Size: 100, Hash: "aaa"},
+		{Path: "b.jpg", Size: 200, Hash: "bbb"},
+	}); err != nil {
+		t.Fatalf("InsertImportedFiles: %v", err)
+	}
+	if err := CompleteImportScan(ctx, database, imp.ID); err != nil {
+		t.Fatalf("CompleteImportScan: %v", err)
+	}
+	if sn, _ := GetScan(ctx, database, imp.ID); sn.HashCompletedAt == nil || sn.FileCount == nil || *sn.FileCount != 2 {
+		t.Errorf("import scan = %+v, want completed with 2 files", sn)
+	}
+	if f, _ := GetFolder(ctx, database, importID); !f.Imported || f.Path != "import:remote" {
+		t.Errorf("import folder = %+v, want imported import:remote", f)
+	}
+
+	localID, _ := AddFolder(ctx, database, "/photos")
+	local, _ := CreateScan(ctx, database, localID)
+	for i, p := range []struct {
+		path, hash string
+		size       int64
+	}{{"copy.jpg", "aaa", 100}, {"new.jpg", "ccc", 300}, {"pending.jpg", "", 200}} {
+		id, err := UpsertFile(ctx, database, localID, p.path, p.size, 1, int64(i+1), nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, local.ID)
+		if p.hash != "" {
+			_ = UpdateFileHash(ctx, database, id, p.hash, NowUTC())
+		}
+	}
+
+	c, err := CompareScans(ctx, database, local.ID, imp.ID, 10)
+	if err != nil {
+		t.Fatalf("CompareScans: %v", err)
+	}
+	if c.MatchedCount != 1 || c.MatchedBytes != 100 || c.OnlyHereCount != 1 || c.UnhashedBySize != 1 {
+		t.Errorf("CompareScans = %+v, want 1 match (100 B), 1 only here, 1 unhashed by size", c)
+	}
+	if len(c.Matched) != 1 || c.Matched[0].Path != "/photos/copy.jpg" {
+		t.Errorf("CompareScans matched = %+v, want /photos/copy.jpg", c.Matched)
+	}
+
+	imports, err := ListImportScans(ctx, database)
+	if err != nil || len(imports) != 1 || imports[0].ID != imp.ID {
+		t.Errorf("ListImportScans = %+v, %v; want only scan %d", imports, err, imp.ID)
+	}
+}
+
+func TestHashGroupHosts_crossHostGroup(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	laptopID, err := GetOrCreateImportFolder(ctx, database, "laptop", "laptop.lan")
+	if err != nil {
+		t.Fatalf("GetOrCreateImportFolder: %v", err)
+	}
+	if again, _ := GetOrCreateImportFolder(ctx, database, "laptop", ""); again != laptopID {
+		t.Fatalf("GetOrCreateImportFolder twice = %d, %d; want same folder", laptopID, again)
+	}
+	if f, _ := GetFolder(ctx, database, laptopID); f.Host != "laptop.lan" {
+		t.Errorf("import folder host = %q after a hostless import, want laptop.lan kept", f.Host)
+	}
+	imp, _ := CreateScan(ctx, database, laptopID)
+	if err := InsertImportedFiles(ctx, database, laptopID, imp.ID, []ImportedFile{
+		{Path: "a.jpg", Size: 100, Hash: "aaa"},
+		{Path: "b.jpg", Size: 100, Hash: "bbb"},
+	}); err != nil {
+		t.Fatalf("InsertImportedFiles: %v", err)
+	}
+
+	localID, _ := AddFolder(ctx, database, "/photos")
+	local, _ := CreateScan(ctx, database, localID)
+	for i, h := range []string{"aaa", "bbb"} {
+		id, _ := UpsertFile(ctx, database, localID, fmt.Sprintf("/photos/%d.jpg", i), 100, 1, int64(i+1), nil)
+		_ = InsertFileScan(ctx, database, id, local.ID)
+		_ = UpdateFileHash(ctx, database, id, h, NowUTC())
+	}
+
+	hosts, err := HashGroupHosts(ctx, database, []int64{local.ID, imp.ID}, []string{"aaa"})
+	if err != nil {
+		t.Fatalf("HashGroupHosts: %v", err)
+	}
+	if got := hosts["aaa"]; len(got) != 2 || got[0] != "" || got[1] != "laptop.lan" {
+		t.Errorf("HashGroupHosts[aaa] = %q, want this machine and laptop.lan", got)
+	}
+	if got, _ := HashGroupHosts(ctx, database, []int64{local.ID}, []string{"bbb"}); len(got["bbb"]) != 1 {
+		t.Errorf("HashGroupHosts of the local scan = %q, want this machine only", got["bbb"])
+	}
+}
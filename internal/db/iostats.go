@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ScanIOStats is where a scan spent its time: the walk's filesystem calls and database batches (summed over
+// walkers and writers), and the hash phase's reads.
+type ScanIOStats struct {
+	FSTime    time.Duration // walkers listing directories and calling Lstat
+	DBTime    time.Duration // writers upserting batches
+	StallTime time.Duration // walkers blocked because the writers' queue was full
+	Dirs      int64         // directories listed
+	Lstats    int64         // Lstat calls
+	Batches   int64         // batches written
+	BatchP50  time.Duration // batch write latency percentiles
+	BatchP95  time.Duration
+	BatchP99  time.Duration
+
+	HashReadBytes int64         // bytes the hash phase read
+	HashTime      time.Duration // hash phase duration; 0 until it completes
+}
+
+// Bottleneck names the side that limited the walk: "database" when walkers spent longer waiting for the writers
+// than in filesystem calls, "filesystem" otherwise, "" when nothing was measured.
+func (s *ScanIOStats) Bottleneck() string {
+	switch {
+	case s.FSTime+s.StallTime <= 0:
+		return ""
+	case s.StallTime > s.FSTime:
+		return "database"
+	}
+	return "filesystem"
+}
+
+// StallPercent is the share of the walkers' measured time spent waiting for the writers.
+func (s *ScanIOStats) StallPercent() float64 {
+	if t := s.FSTime + s.StallTime; t > 0 {
+		return 100 * float64(s.StallTime) / float64(t)
+	}
+	return 0
+}
+
+// HashMBPerSec is the hash phase's read throughput in MiB per second, or 0 before it completes.
+func (s *ScanIOStats) HashMBPerSec() float64 {
+	if sec := s.HashTime.Seconds(); sec > 0 {
+		return float64(s.HashReadBytes) / (1024 * 1024) / sec
+	}
+	return 0
+}
+
+// SaveScanIOStats records the walk's statistics on the scan (the hash fields are ignored: the hash phase
+// records its own reads, see AddScanHashReadBytes).
+func SaveScanIOStats(ctx context.Context, database *sql.DB, scanID int64, s ScanIOStats) error {
+	_, err := database.ExecContext(ctx,
+		`UPDATE scans SET walk_fs_ms = $1, walk_db_ms = $2, walk_stall_ms = $3, walk_dirs = $4, walk_lstats = $5,
+		 walk_batches = $6, walk_batch_p50_us = $7, walk_batch_p95_us = $8, walk_batch_p99_us = $9 WHERE id = $10`,
+		s.FSTime.Milliseconds(), s.DBTime.Milliseconds(), s.StallTime.Milliseconds(), s.Dirs, s.Lstats,
+		s.Batches, s.BatchP50.Microseconds(), s.BatchP95.Microseconds(), s.BatchP99.Microseconds(), scanID)
+	return err
+}
+
+// GetScanIOStats returns the scan's I/O statistics, or (nil, nil) when its walk recorded none (it is running,
+// failed, or ran before they were kept).
+func GetScanIOStats(ctx context.Context, database *sql.DB, scanID int64) (*ScanIOStats, error) {
+	var fsMS, dbMS, stallMS, dirs, lstats, batches, p50, p95, p99 sql.NullInt64
+	var readBytes int64
+	var hashStarted, hashCompleted sql.NullTime
+	err := database.QueryRowContext(ctx,
+		`SELECT walk_fs_ms, walk_db_ms, walk_stall_ms, walk_dirs, walk_lstats, walk_batches,
+		 walk_batch_p50_us, walk_batch_p95_us, walk_batch_p99_us, COALESCE(hash_read_bytes, 0), hash_started_at, hash_completed_at
+		 FROM scans WHERE id = $1`, scanID).
+		Scan(&fsMS, &dbMS, &stallMS, &dirs, &lstats, &batches, &p50, &p95, &p99, &readBytes, &hashStarted, &hashCompleted)
+	if err != nil {
+		return nil, err
+	}
+	if !fsMS.Valid {
+		return nil, nil
+	}
+	s := &ScanIOStats{
+		FSTime:        time.Duration(fsMS.Int64) * time.Millisecond,
+		DBTime:        time.Duration(dbMS.Int64) * time.Millisecond,
+		StallTime:     time.Duration(stallMS.Int64) * time.Millisecond,
+		Dirs:          dirs.Int64,
+		Lstats:        lstats.Int64,
+		Batches:       batches.Int64,
+		BatchP50:      time.Duration(p50.Int64) * time.Microsecond,
+		BatchP95:      time.Duration(p95.Int64) * time.Microsecond,
+		BatchP99:      time.Duration(p99.Int64) * time.Microsecond,
+		HashReadBytes: readBytes,
+	}
+	if hashStarted.Valid && hashCompleted.Valid {
+		s.HashTime = hashCompleted.Time.Sub(hashStarted.Time)
+	}
+	return s, nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScanIOStats_roundTripAndBottleneck(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+
+	if got, err := GetScanIOStats(ctx, database, sn.ID); err != nil || got != nil {
+		t.Fatalf("GetScanIOStats before the walk = %+v, %v; want nil, nil", got, err)
+	}
+
+	in := ScanIOStats{
+		FSTime: 2 * time.Second, DBTime: 5 * time.Second, StallTime: 6 * time.Second,
+		Dirs: 10, Lstats: 400, Batches: 3,
+		BatchP50: 900 * time.Microsecond, BatchP95: 2 * time.Millisecond, BatchP99: 3 * time.Millisecond,
+	}
+	if err := SaveScanIOStats(ctx, database, sn.ID, in); err != nil {
+		t.Fatalf("SaveScanIOStats: %v", err)
+	}
+	start := NowUTC().Add(-time.Minute)
+	if _, err := database.ExecContext(ctx,
+		`UPDATE scans SET hash_started_at = $1, hash_completed_at = $2, hash_read_bytes = $3 WHERE id = $4`,
+		start, start.Add(4*time.Second), 8*1024*1024, sn.ID); err != nil {
+		t.Fatalf("set hash phase: %v", err)
+	}
+
+	got, err := GetScanIOStats(ctx, database, sn.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetScanIOStats = %+v, %v", got, err)
+	}
+	if got.FSTime != in.FSTime || got.StallTime != in.StallTime || got.Lstats != 400 || got.BatchP50 != in.BatchP50 {
+		t.Errorf("GetScanIOStats = %+v, want walk fields of %+v", got, in)
+	}
+	if got.Bottleneck() != "database" || got.StallPercent() != 75 {
+		t.Errorf("Bottleneck = %q (%.0f%% stalled), want database (75%%)", got.Bottleneck(), got.StallPercent())
+	}
+	if got.HashMBPerSec() != 2 {
+		t.Errorf("HashMBPerSec = %v, want 2", got.HashMBPerSec())
+	}
+
+	if b := (&ScanIOStats{FSTime: time.Second, StallTime: time.Millisecond}).Bottleneck(); b != "filesystem" {
+		t.Errorf("Bottleneck = %q, want filesystem", b)
+	}
+	if b := (&ScanIOStats{}).Bottleneck(); b != "" {
+		t.Errorf("Bottleneck of an empty walk = %q, want empty", b)
+	}
+}
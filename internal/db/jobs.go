@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Job types.
+const (
+	JobTypeScan = "scan" // payload ScanJobPayload: walk (if needed) and hash a scan
+)
+
+// Job statuses.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is a row of the persistent background job queue.
+type Job struct {
+	ID         int64
+	Type       string
+	Payload    json.RawMessage
+	Status     string
+	Attempts   int
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// ScanJobPayload is the payload of a JobTypeScan job.
+type ScanJobPayload struct {
+	ScanID int64 `json:"scan_id"`
+}
+
+const jobColumns = `id, type, payload, status, attempts, error, created_at, started_at, finished_at`
+
+func scanJob(row rowScanner) (*Job, error) {
+	var j Job
+	var errText sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	var payload []byte
+	if err := row.Scan(&j.ID, &j.Type, &payload, &j.Status, &j.Attempts, &errText, &j.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	j.Payload = payload
+	j.Error = errText.String
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return &j, nil
+}
+
+// EnqueueJob adds a pending job with payload marshalled as JSON and returns its id.
+func EnqueueJob(ctx context.Context, database *sql.DB, typ string, payload any) (int64, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = database.QueryRowContext(ctx,
+		`INSERT INTO jobs (type, payload, status, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		typ, string(b), JobPending, NowUTC()).Scan(&id)
+	return id, err
+}
+
+// EnqueueScanJob queues the scan for the worker unless it is already pending or running, and returns the job id.
+func EnqueueScanJob(ctx context.Context, database *sql.DB, scanID int64) (int64, error) {
+	var id int64
+	err := database.QueryRowContext(ctx,
+		`SELECT id FROM jobs WHERE type = $1 AND status IN ($2, $3) AND (payload->>'scan_id')::bigint = $4 ORDER BY id LIMIT 1`,
+		JobTypeScan, JobPending, JobRunning, scanID).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return EnqueueJob(ctx, database, JobTypeScan, ScanJobPayload{ScanID: scanID})
+}
+
+// ClaimNextJob marks the oldest pending job running (one more attempt) and returns it, or sql.ErrNoRows when
+// the queue is empty. SKIP LOCKED lets several workers claim concurrently without taking the same job.
+func ClaimNextJob(ctx context.Context, database *sql.DB) (*Job, error) {
+	return scanJob(database.QueryRowContext(ctx,
+		`UPDATE jobs SET status = $1, attempts = attempts + 1, started_at = $2, finished_at = NULL, error = NULL
+		 WHERE id = (SELECT id FROM jobs WHERE status = $3 ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED)
+		 RETURNING `+jobColumns,
+		JobRunning, NowUTC(), JobPending))
+}
+
+// FinishJob records the outcome of a running job: done when jobErr is nil, failed with its message otherwise.
+func FinishJob(ctx context.Context, database *sql.DB, id int64, jobErr error) error {
+	status, errText := JobDone, sql.NullString{}
+	if jobErr != nil {
+		status, errText = JobFailed, sql.NullString{String: jobErr.Error(), Valid: true}
+	}
+	_, err := database.ExecContext(ctx,
+		`UPDATE jobs SET status = $1, error = $2, finished_at = $3 WHERE id = $4`, status, errText, NowUTC(), id)
+	return err
+}
+
+// RequeueRunningJobs returns jobs left running by a stopped process to pending, so the worker resumes them.
+// Call it once at startup, before the worker claims jobs. Returns how many jobs were requeued.
+func RequeueRunningJobs(ctx context.Context, database *sql.DB) (int64, error) {
+	res, err := database.ExecContext(ctx, `UPDATE jobs SET status = $1, started_at = NULL WHERE status = $2`, JobPending, JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountJobsByStatus returns how many jobs have each status (statuses without jobs are absent).
+func CountJobsByStatus(ctx context.Context, database *sql.DB) (map[string]int64, error) {
+	rows, err := database.QueryContext(ctx, `SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		out[status] = n
+	}
+	return out, rows.Err()
+}
+
+// ListRecentJobs returns up to limit jobs, newest first.
+func ListRecentJobs(ctx context.Context, database *sql.DB, limit int) ([]Job, error) {
+	rows, err := database.QueryContext(ctx, `SELECT `+jobColumns+` FROM jobs ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+	return out, rows.Err()
+}
+
+// DeleteFinishedJobs removes done and failed jobs that finished before the given time. Returns how many.
+func DeleteFinishedJobs(ctx context.Context, database *sql.DB, before time.Time) (int64, error) {
+	res, err := database.ExecContext(ctx,
+		`DELETE FROM jobs WHERE status IN ($1, $2) AND finished_at < $3`, JobDone, JobFailed, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestJobs_enqueueClaimFinishAndRequeue(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	id1, err := EnqueueScanJob(ctx, database, 7)
+	if err != nil {
+		t.Fatalf("EnqueueScanJob: %v", err)
+	}
+	if again, _ := EnqueueScanJob(ctx, database, 7); again != id1 {
+		t.Errorf("EnqueueScanJob(same scan) = %d, want existing job %d", again, id1)
+	}
+	id2, _ := EnqueueScanJob(ctx, database, 8)
+
+	j, err := ClaimNextJob(ctx, database)
+	if err != nil {
+		t.Fatalf("ClaimNextJob: %v", err)
+	}
+	var p ScanJobPayload
+	if err := json.Unmarshal(j.Payload, &p); err != nil || j.ID != id1 || p.ScanID != 7 || j.Status != JobRunning || j.Attempts != 1 {
+		t.Fatalf("claimed %+v (payload %+v, %v); want job %d for scan 7, running, attempt 1", j, p, err, id1)
+	}
+	if err := FinishJob(ctx, database, j.ID, errors.New("boom")); err != nil {
+		t.Fatalf("FinishJob: %v", err)
+	}
+
+	// A process stopped while running job 2: on restart it is pending again.
+	j2, _ := ClaimNextJob(ctx, database)
+	if j2 == nil || j2.ID != id2 {
+		t.Fatalf("second claim = %+v, want job %d", j2, id2)
+	}
+	if n, err := RequeueRunningJobs(ctx, database); err != nil || n != 1 {
+		t.Fatalf("RequeueRunningJobs = %d, %v; want 1", n, err)
+	}
+	j2, _ = ClaimNextJob(ctx, database)
+	if j2 == nil || j2.ID != id2 || j2.Attempts != 2 {
+		t.Fatalf("claim after requeue = %+v, want job %d on attempt 2", j2, id2)
+	}
+	_ = FinishJob(ctx, database, j2.ID, nil)
+	if _, err := ClaimNextJob(ctx, database); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ClaimNextJob(empty) err = %v, want sql.ErrNoRows", err)
+	}
+
+	counts, _ := CountJobsByStatus(ctx, database)
+	if counts[JobFailed] != 1 || counts[JobDone] != 1 {
+		t.Errorf("CountJobsByStatus = %v, want 1 failed and 1 done", counts)
+	}
+	jobs, _ := ListRecentJobs(ctx, database, 10)
+	if len(jobs) != 2 || jobs[1].Error != "boom" {
+		t.Errorf("ListRecentJobs = %+v, want 2 jobs with the first failed on boom", jobs)
+	}
+}
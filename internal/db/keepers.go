@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrKeeperNotInGroup is returned by SetGroupKeeper when the file is not a hashed file of the group.
+var ErrKeeperNotInGroup = errors.New("file is not in this duplicate group")
+
+// ErrUnknownKeeperRule is returned by ApplyKeeperRule for a rule it does not know.
+var ErrUnknownKeeperRule = errors.New("unknown keeper rule")
+
+// KeeperRule chooses the keeper of a group when keepers are pinned in bulk.
+type KeeperRule string
+
+const (
+	KeeperFirstPath    KeeperRule = "first"    // first full path in sort order (the default survivor)
+	KeeperShortestPath KeeperRule = "shortest" // shortest full path, e.g. the copy outside "backup/old/..."
+	KeeperOldest       KeeperRule = "oldest"   // earliest modification time
+	KeeperNewest       KeeperRule = "newest"   // latest modification time
+
+	// Metadata rules prefer the best copy by photo or media metadata, then fall back to the oldest, then the
+	// first path. Byte-identical copies share their metadata, so they matter for similar images and videos.
+	KeeperHighestResolution KeeperRule = "resolution" // most pixels, then earliest date taken (photo metadata phase)
+	KeeperIntactEXIF        KeeperRule = "exif"       // has an EXIF date taken or camera (photo metadata phase)
+	KeeperHighestBitrate    KeeperRule = "bitrate"    // highest audio/video bitrate, size over duration (media phase)
+)
+
+// currentMetadata and currentMedia select the file's (alias f) metadata read at its current size and mtime.
+const (
+	currentMetadata = `FROM file_metadata md WHERE md.file_id = f.id AND md.size = f.size AND md.mtime = f.mtime AND md.error IS NULL`
+	currentMedia    = `FROM media_info mi WHERE mi.file_id = f.id AND mi.size = f.size AND mi.mtime = f.mtime AND mi.error IS NULL`
+)
+
+// keeperRuleOrder is the ORDER BY tail (after f.hash) that puts the rule's keeper first.
+var keeperRuleOrder = map[KeeperRule]string{
+	KeeperFirstPath:         "full_path",
+	KeeperShortestPath:      "LENGTH(fo.path || '/' || f.path), full_path",
+	KeeperOldest:            "f.mtime, full_path",
+	KeeperNewest:            "f.mtime DESC, full_path",
+	KeeperHighestResolution: "(SELECT md.width::bigint * md.height " + currentMetadata + ") DESC NULLS LAST, (SELECT md.taken_at " + currentMetadata + ") NULLS LAST, f.mtime, full_path",
+	KeeperIntactEXIF:        "EXISTS (SELECT 1 " + currentMetadata + " AND (md.taken_at IS NOT NULL OR md.camera <> '')) DESC, f.mtime, full_path",
+	KeeperHighestBitrate:    "(SELECT f.size * 8000 / mi.duration_ms " + currentMedia + " AND mi.duration_ms > 0) DESC NULLS LAST, f.mtime, full_path",
+}
+
+// KeeperCandidate is a file BestCopy chooses from, with the metadata the rules compare.
+type KeeperCandidate struct {
+	ID      int64
+	Path    string // full path
+	MTime   int64
+	Pixels  int64      // 0 when unknown
+	TakenAt *time.Time // EXIF date taken; nil when unknown
+	EXIF    bool       // has an EXIF date taken or camera
+	Bitrate int64      // bits per second; 0 when unknown
+}
+
+// BestCopy returns the id of the candidate rule keeps, in the order ApplyKeeperRule uses, for groups that are
+// not stored as hash groups, such as similar images. It returns 0 without candidates.
+func BestCopy(rule KeeperRule, candidates []KeeperCandidate) (int64, error) {
+	if _, ok := keeperRuleOrder[rule]; !ok {
+		return 0, ErrUnknownKeeperRule
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if keptBefore(rule, c, best) {
+			best = c
+		}
+	}
+	return best.ID, nil
+}
+
+// keptBefore reports whether rule prefers a to b (see keeperRuleOrder).
+func keptBefore(rule KeeperRule, a, b KeeperCandidate) bool {
+	switch rule {
+	case KeeperFirstPath:
+		return a.Path < b.Path
+	case KeeperShortestPath:
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		return a.Path < b.Path
+	case KeeperNewest:
+		if a.MTime != b.MTime {
+			return a.MTime > b.MTime
+		}
+		return a.Path < b.Path
+	case KeeperHighestResolution:
+		if a.Pixels != b.Pixels {
+			return a.Pixels > b.Pixels
+		}
+		if (a.TakenAt == nil) != (b.TakenAt == nil) {
+			return a.TakenAt != nil
+		}
+		if a.TakenAt != nil && !a.TakenAt.Equal(*b.TakenAt) {
+			return a.TakenAt.Before(*b.TakenAt)
+		}
+	case KeeperIntactEXIF:
+		if a.EXIF != b.EXIF {
+			return a.EXIF
+		}
+	case KeeperHighestBitrate:
+		if a.Bitrate != b.Bitrate {
+			return a.Bitrate > b.Bitrate
+		}
+	}
+	if a.MTime != b.MTime {
+		return a.MTime < b.MTime
+	}
+	return a.Path < b.Path
+}
+
+// stillKeeper is true when the pinned file (group_keepers alias k) still has the group's content.
+const stillKeeper = `EXISTS (SELECT 1 FROM files kf WHERE kf.id = k.file_id AND kf.hash = k.hash AND kf.hash_status = 'done')`
+
+// SetGroupKeeper pins fileID as the keeper of the duplicate group with this hash, replacing any previous
+// keeper. Bulk actions and rules must leave the keeper (and its hardlinks) in place.
+func SetGroupKeeper(ctx context.Context, database *sql.DB, hash string, fileID int64) error {
+	var ok bool
+	if err := database.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM files WHERE id = $1 AND hash = $2 AND hash_status = 'done')`,
+		fileID, hash).Scan(&ok); err != nil {
+		return err
+	}
+	if !ok {
+		return ErrKeeperNotInGroup
+	}
+	_, err := database.ExecContext(ctx,
+		`INSERT INTO group_keepers (hash, file_id, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (hash) DO UPDATE SET file_id = EXCLUDED.file_id, created_at = EXCLUDED.created_at`,
+		hash, fileID, NowUTC())
+	return err
+}
+
+// ApplyKeeperRule pins a keeper, chosen by rule among the group's files in the given scans, for each of the
+// given hashes. Groups whose keeper is already pinned keep it; a keeper whose content has changed is replaced.
+// It returns how many groups got a new keeper.
+func ApplyKeeperRule(ctx context.Context, database *sql.DB, scanIDs []int64, hashes []string, rule KeeperRule) (int64, error) {
+	order, ok := keeperRuleOrder[rule]
+	if !ok {
+		return 0, ErrUnknownKeeperRule
+	}
+	if len(scanIDs) == 0 || len(hashes) == 0 {
+		return 0, nil
+	}
+	args := idSlice(scanIDs)
+	for _, h := range hashes {
+		args = append(args, h)
+	}
+	args = append(args, NowUTC())
+	q := `INSERT INTO group_keepers AS k (hash, file_id, created_at)
+		SELECT hash, id, $` + strconv.Itoa(len(args)) + `::timestamptz FROM (
+			SELECT DISTINCT ON (f.hash) f.hash, f.id, (fo.path || '/' || f.path) AS full_path
+			FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+			WHERE fs.scan_id IN (` + placeholders(len(scanIDs), 1) + `) AND f.hash_status = 'done'
+			  AND f.hash IN (` + placeholders(len(hashes), len(scanIDs)+1) + `)
+			ORDER BY f.hash, ` + order + `
+		) pick
+		ON CONFLICT (hash) DO UPDATE SET file_id = EXCLUDED.file_id, created_at = EXCLUDED.created_at
+		WHERE NOT ` + stillKeeper // #nosec G202 -- order comes from keeperRuleOrder; values are placeholders
+	res, err := database.ExecContext(ctx, q, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ClearGroupKeeper unpins the keeper of the group with this hash, if any.
+func ClearGroupKeeper(ctx context.Context, database *sql.DB, hash string) error {
+	return ClearGroupKeepers(ctx, database, []string{hash})
+}
+
+// ClearGroupKeepers unpins the keepers of the groups with these hashes.
+func ClearGroupKeepers(ctx context.Context, database *sql.DB, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(hashes))
+	for i, h := range hashes {
+		args[i] = h
+	}
+	_, err := database.ExecContext(ctx, `DELETE FROM group_keepers WHERE hash IN (`+placeholders(len(hashes), 1)+`)`, args...)
+	return err
+}
+
+// GroupKeepers returns the pinned keeper file id per hash for the given hashes. Hashes without a keeper, or
+// whose keeper's content has since changed, are absent.
+func GroupKeepers(ctx context.Context, database *sql.DB, hashes []string) (map[string]int64, error) {
+	out := make(map[string]int64)
+	if len(hashes) == 0 {
+		return out, nil
+	}
+	args := make([]interface{}, len(hashes))
+	for i, h := range hashes {
+		args[i] = h
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT k.hash, k.file_id FROM group_keepers k
+		 JOIN files f ON f.id = k.file_id AND f.hash = k.hash AND f.hash_status = 'done'
+		 WHERE k.hash IN (`+placeholders(len(hashes), 1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h string
+		var id int64
+		if err := rows.Scan(&h, &id); err != nil {
+			return nil, err
+		}
+		out[h] = id
+	}
+	return out, rows.Err()
+}
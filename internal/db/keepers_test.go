@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetGroupKeeper_pinsReplacesAndClears(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	add := func(path, hash string, inode int64) int64 {
+		id, err := UpsertFile(ctx, database, folderID, path, 100, 1, inode, nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+		return id
+	}
+	a := add("a", "h", 1)
+	b := add("b", "h", 2)
+	other := add("c", "other", 3)
+
+	if err := SetGroupKeeper(ctx, database, "h", other); !errors.Is(err, ErrKeeperNotInGroup) {
+		t.Fatalf("SetGroupKeeper(other group) err = %v, want ErrKeeperNotInGroup", err)
+	}
+	if err := SetGroupKeeper(ctx, database, "h", a); err != nil {
+		t.Fatalf("SetGroupKeeper: %v", err)
+	}
+	if err := SetGroupKeeper(ctx, database, "h", b); err != nil {
+		t.Fatalf("SetGroupKeeper replace: %v", err)
+	}
+	k, err := GroupKeepers(ctx, database, []string{"h", "other"})
+	if err != nil {
+		t.Fatalf("GroupKeepers: %v", err)
+	}
+	if len(k) != 1 || k["h"] != b {
+		t.Errorf("GroupKeepers = %v, want h -> %d", k, b)
+	}
+	var keepers []string
+	_ = EachDuplicateFile(ctx, database, sn.ID, func(r DuplicateExportRow) error {
+		if r.Keeper {
+			keepers = append(keepers, r.File.Path)
+		}
+		return nil
+	})
+	if len(keepers) != 1 || keepers[0] != "/data/b" {
+		t.Errorf("export keepers = %v, want [/data/b]", keepers)
+	}
+
+	// The keeper's content changes: it no longer belongs to the group.
+	_ = UpdateFileHash(ctx, database, b, "changed", time.Now().UTC())
+	if k, _ := GroupKeepers(ctx, database, []string{"h"}); len(k) != 0 {
+		t.Errorf("GroupKeepers after content change = %v, want none", k)
+	}
+
+	if err := ClearGroupKeeper(ctx, database, "h"); err != nil {
+		t.Fatalf("ClearGroupKeeper: %v", err)
+	}
+	var n int
+	_ = database.QueryRowContext(ctx, `SELECT COUNT(*) FROM group_keepers`).Scan(&n)
+	if n != 0 {
+		t.Errorf("group_keepers rows after clear = %d, want 0", n)
+	}
+}
+
+func TestApplyKeeperRule_picksByRuleAndKeepsPinned(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	add := func(path, hash string, mtime, inode int64) int64 {
+		id, err := UpsertFile(ctx, database, folderID, path, 100, mtime, inode, nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+		return id
+	}
+	add("backup/old/a", "h1", 1, 1)
+	short := add("a", "h1", 2, 2)
+	add("x/b", "h2", 5, 3)
+	pinned := add("y/b", "h2", 9, 4)
+	if err := SetGroupKeeper(ctx, database, "h2", pinned); err != nil {
+		t.Fatalf("SetGroupKeeper: %v", err)
+	}
+
+	if _, err := ApplyKeeperRule(ctx, database, []int64{sn.ID}, []string{"h1"}, "largest"); !errors.Is(err, ErrUnknownKeeperRule) {
+		t.Fatalf("ApplyKeeperRule(unknown) err = %v, want ErrUnknownKeeperRule", err)
+	}
+	n, err := ApplyKeeperRule(ctx, database, []int64{sn.ID}, []string{"h1", "h2"}, KeeperShortestPath)
+	if err != nil {
+		t.Fatalf("ApplyKeeperRule: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("ApplyKeeperRule pinned %d groups, want 1 (h2 already pinned)", n)
+	}
+	k, _ := GroupKeepers(ctx, database, []string{"h1", "h2"})
+	if k["h1"] != short || k["h2"] != pinned {
+		t.Errorf("GroupKeepers = %v, want h1 -> %d, h2 -> %d", k, short, pinned)
+	}
+
+	if err := ClearGroupKeepers(ctx, database, []string{"h1", "h2"}); err != nil {
+		t.Fatalf("ClearGroupKeepers: %v", err)
+	}
+	if _, err := ApplyKeeperRule(ctx, database, []int64{sn.ID}, []string{"h1", "h2"}, KeeperOldest); err != nil {
+		t.Fatalf("ApplyKeeperRule oldest: %v", err)
+	}
+	k, _ = GroupKeepers(ctx, database, []string{"h1", "h2"})
+	if k["h1"] == short || k["h2"] == pinned {
+		t.Errorf("GroupKeepers after oldest rule = %v, want the earliest-modified files", k)
+	}
+	oldest := k
+
+	// Metadata rules run in SQL too; identical copies without metadata fall back to the oldest.
+	for _, rule := range []KeeperRule{KeeperHighestResolution, KeeperIntactEXIF, KeeperHighestBitrate} {
+		_ = ClearGroupKeepers(ctx, database, []string{"h1", "h2"})
+		if _, err := ApplyKeeperRule(ctx, database, []int64{sn.ID}, []string{"h1", "h2"}, rule); err != nil {
+			t.Fatalf("ApplyKeeperRule %s: %v", rule, err)
+		}
+		if k, _ = GroupKeepers(ctx, database, []string{"h1", "h2"}); k["h1"] != oldest["h1"] || k["h2"] != oldest["h2"] {
+			t.Errorf("GroupKeepers after %s rule = %v, want %v", rule, k, oldest)
+		}
+	}
+
+	if err := AcknowledgeGroups(ctx, database, []string{"h1", "h2"}, "archive"); err != nil {
+		t.Fatalf("AcknowledgeGroups: %v", err)
+	}
+	if groups, _ := DuplicateGroupsByHash(ctx, database, sn.ID); len(groups) != 0 {
+		t.Errorf("DuplicateGroupsByHash after AcknowledgeGroups = %+v, want none", groups)
+	}
+}
+
+func TestBestCopy_metadataRulesFallBackToOldest(t *testing.T) {
+	early := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	photos := []KeeperCandidate{
+		{ID: 1, Path: "/a/small.jpg", MTime: 10, Pixels: 480_000, TakenAt: &early, EXIF: true}, // resized copy
+		{ID: 2, Path: "/b/full.jpg", MTime: 30, Pixels: 12_000_000, TakenAt: &late, EXIF: true},
+		{ID: 3, Path: "/c/full.jpg", MTime: 40, Pixels: 12_000_000, TakenAt: &early, EXIF: true},
+		{ID: 4, Path: "/d/stripped.jpg", MTime: 5, Pixels: 12_000_000}, // EXIF removed on export
+	}
+	for rule, want := range map[KeeperRule]int64{
+		KeeperHighestResolution: 3, // full size, taken first
+		KeeperIntactEXIF:        1, // oldest of those with EXIF
+		KeeperHighestBitrate:    4, // no bitrate: oldest
+		KeeperOldest:            4,
+		KeeperNewest:            3,
+		KeeperShortestPath:      2,
+		KeeperFirstPath:         1,
+	} {
+		if got, err := BestCopy(rule, photos); err != nil || got != want {
+			t.Errorf("BestCopy(%s) = %d, %v; want %d", rule, got, err, want)
+		}
+	}
+	videos := []KeeperCandidate{{ID: 1, MTime: 1, Bitrate: 800_000}, {ID: 2, MTime: 2, Bitrate: 2_500_000}}
+	if got, _ := BestCopy(KeeperHighestBitrate, videos); got != 2 {
+		t.Errorf("BestCopy(bitrate) = %d, want 2", got)
+	}
+	if _, err := BestCopy("largest", videos); !errors.Is(err, ErrUnknownKeeperRule) {
+		t.Errorf("BestCopy(unknown) err = %v, want ErrUnknownKeeperRule", err)
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// NormalizeExtension returns ext lowercased with a leading dot ("JPG" -> ".jpg"), or "" for an empty filter.
+func NormalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext == "" || strings.HasPrefix(ext, ".") {
+		return ext
+	}
+	return "." + ext
+}
+
+// LargestFilesCount returns how many files in the scan match the extension filter (see LargestFiles).
+func LargestFilesCount(ctx context.Context, database *sql.DB, scanID int64, ext string) (int64, error) {
+	var n int64
+	err := database.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM files f JOIN file_scan fs ON f.id = fs.file_id
+		 WHERE fs.scan_id = $1 AND ($2 = '' OR lower(substring(f.path from '\.[^./]*$')) = $2)`,
+		scanID, NormalizeExtension(ext)).Scan(&n)
+	return n, err
+}
+
+// LargestFiles returns the scan's files ordered by size (largest first), regardless of duplication.
+// ext filters by extension ("" for all; see NormalizeExtension). Path is the full path (folder path || '/' || file path).
+func LargestFiles(ctx context.Context, database *sql.DB, scanID int64, ext string, limit, offset int) ([]File, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, $1::bigint, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id = $1 AND ($2 = '' OR lower(substring(f.path from '\.[^./]*$')) = $2)
+		 ORDER BY f.size DESC, f.id
+		 LIMIT $3 OFFSET $4`,
+		scanID, NormalizeExtension(ext), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
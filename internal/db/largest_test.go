@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLargestFiles_ordersBySizeWithExtensionFilter(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	for i, p := range []struct {
+		path string
+		size int64
+	}{{"small.txt", 10}, {"big.ISO", 3000}, {"mid.iso", 200}, {"noext", 5000}} {
+		id, err := UpsertFile(ctx, database, folderID, p.path, p.size, 1, int64(i+1), nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+	}
+
+	all, err := LargestFiles(ctx, database, sn.ID, "", 2, 0)
+	if err != nil {
+		t.Fatalf("LargestFiles: %v", err)
+	}
+	if len(all) != 2 || all[0].Path != "/data/noext" || all[1].Path != "/data/big.ISO" {
+		t.Errorf("LargestFiles(all, 2) = %+v, want noext then big.ISO", all)
+	}
+	iso, err := LargestFiles(ctx, database, sn.ID, "ISO", 10, 1)
+	if err != nil {
+		t.Fatalf("LargestFiles(iso): %v", err)
+	}
+	if len(iso) != 1 || iso[0].Path != "/data/mid.iso" {
+		t.Errorf("LargestFiles(iso, offset 1) = %+v, want mid.iso", iso)
+	}
+	if n, err := LargestFilesCount(ctx, database, sn.ID, ".iso"); err != nil || n != 2 {
+		t.Errorf("LargestFilesCount(.iso) = %d, %v; want 2", n, err)
+	}
+}
+
+func TestNormalizeExtension(t *testing.T) {
+	for in, want := range map[string]string{"": "", "JPG": ".jpg", ".Mp4": ".mp4", " iso ": ".iso"} {
+		if got := NormalizeExtension(in); got != want {
+			t.Errorf("NormalizeExtension(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MaintainOptions selects the work done by Maintain besides VACUUM and ANALYZE.
+type MaintainOptions struct {
+	Reindex bool // rebuild every index; blocks writes to each table while its indexes are rebuilt
+}
+
+// MaintenanceReport is the outcome of Maintain.
+type MaintenanceReport struct {
+	Tables     int
+	Reindexed  bool
+	SizeBefore int64 // pg_database_size, bytes
+	SizeAfter  int64
+	Duration   time.Duration
+}
+
+// Freed returns the bytes the database shrank by (negative when it grew meanwhile).
+func (r *MaintenanceReport) Freed() int64 { return r.SizeBefore - r.SizeAfter }
+
+// DatabaseSize returns the on-disk size of the current database in bytes.
+func DatabaseSize(ctx context.Context, database *sql.DB) (int64, error) {
+	var n int64
+	err := database.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&n)
+	return n, err
+}
+
+// Maintain runs VACUUM (ANALYZE) on every table in the current schema, and REINDEX when opts.Reindex is set,
+// reporting the database size before and after. Plain VACUUM returns dead rows (e.g. from deleted scans) to
+// Postgres for reuse; the file shrinks only when trailing pages become empty.
+func Maintain(ctx context.Context, database *sql.DB, opts MaintainOptions) (*MaintenanceReport, error) {
+	start := time.Now()
+	rep := &MaintenanceReport{Reindexed: opts.Reindex}
+	var err error
+	if rep.SizeBefore, err = DatabaseSize(ctx, database); err != nil {
+		return nil, err
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT quote_ident(tablename) FROM pg_tables WHERE schemaname = current_schema() ORDER BY tablename`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		// Table names come from quote_ident; VACUUM and REINDEX take no bind parameters.
+		if opts.Reindex {
+			if _, err := database.ExecContext(ctx, `REINDEX TABLE `+t); err != nil { // #nosec G202 -- quoted identifier
+				return nil, err
+			}
+		}
+		if _, err := database.ExecContext(ctx, `VACUUM (ANALYZE) `+t); err != nil { // #nosec G202 -- quoted identifier
+			return nil, err
+		}
+		rep.Tables++
+	}
+	if rep.SizeAfter, err = DatabaseSize(ctx, database); err != nil {
+		return nil, err
+	}
+	rep.Duration = time.Since(start).Round(time.Millisecond)
+	return rep, nil
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMaintain_vacuumsAndReindexesAllTables(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	rep, err := Maintain(ctx, database, MaintainOptions{Reindex: true})
+	if err != nil {
+		t.Fatalf("Maintain: %v", err)
+	}
+	if rep.Tables == 0 || !rep.Reindexed {
+		t.Errorf("report = %+v, want tables > 0 and reindexed", rep)
+	}
+	if rep.SizeBefore <= 0 || rep.SizeAfter <= 0 {
+		t.Errorf("sizes = %d, %d; want > 0", rep.SizeBefore, rep.SizeAfter)
+	}
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ScanManifest records the digest of a scan's exported manifest and the previous digest it chains to.
+type ScanManifest struct {
+	ScanID         int64
+	RootPath       string
+	Digest         string
+	PreviousDigest string
+	CreatedAt      time.Time
+}
+
+// GetScanManifest returns the recorded manifest for the scan, or (nil, nil) if none was exported yet.
+func GetScanManifest(ctx context.Context, database *sql.DB, scanID int64) (*ScanManifest, error) {
+	var m ScanManifest
+	var prev sql.NullString
+	err := database.QueryRowContext(ctx,
+		`SELECT m.scan_id, f.path, m.digest, m.previous_digest, m.created_at
+		 FROM scan_manifests m JOIN scans s ON m.scan_id = s.id JOIN folders f ON s.folder_id = f.id
+		 WHERE m.scan_id = $1`,
+		scanID).Scan(&m.ScanID, &m.RootPath, &m.Digest, &prev, &m.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	m.PreviousDigest = prev.String
+	return &m, nil
+}
+
+// PreviousScanManifestDigest returns the digest of the most recent recorded manifest for an earlier scan
+// of the same folder, or "" when there is none (start of the chain).
+func PreviousScanManifestDigest(ctx context.Context, database *sql.DB, scanID int64) (string, error) {
+	var digest string
+	err := database.QueryRowContext(ctx,
+		`SELECT m.digest FROM scan_manifests m
+		 JOIN scans p ON m.scan_id = p.id
+		 JOIN scans s ON s.folder_id = p.folder_id
+		 WHERE s.id = $1 AND p.id < s.id
+		 ORDER BY p.id DESC LIMIT 1`,
+		scanID).Scan(&digest)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return digest, err
+}
+
+// InsertScanManifest records a scan's manifest digest. previousDigest may be empty.
+func InsertScanManifest(ctx context.Context, database *sql.DB, scanID int64, digest, previousDigest string) error {
+	var prev interface{}
+	if previousDigest != "" {
+		prev = previousDigest
+	}
+	_, err := database.ExecContext(ctx,
+		`INSERT INTO scan_manifests (scan_id, digest, previous_digest, created_at) VALUES ($1, $2, $3, $4)`,
+		scanID, digest, prev, NowUTC())
+	return err
+}
+
+// ListScanManifests returns all recorded manifests ordered by scan id.
+func ListScanManifests(ctx context.Context, database *sql.DB) ([]ScanManifest, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT m.scan_id, f.path, m.digest, m.previous_digest, m.created_at
+		 FROM scan_manifests m JOIN scans s ON m.scan_id = s.id JOIN folders f ON s.folder_id = f.id
+		 ORDER BY m.scan_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ScanManifest
+	for rows.Next() {
+		var m ScanManifest
+		var prev sql.NullString
+		if err := rows.Scan(&m.ScanID, &m.RootPath, &m.Digest, &prev, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.PreviousDigest = prev.String
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
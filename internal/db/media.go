@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// MediaRecord is the probed metadata of an audio or video file. Err is set (and the rest empty) when the file
+// could not be probed; it is not retried until its size or mtime changes.
+type MediaRecord struct {
+	Kind        string
+	Container   string
+	Duration    time.Duration
+	Fingerprint []uint32
+	Err         string
+}
+
+// MediaFile is a probed media file in a scan.
+type MediaFile struct {
+	FileID      int64
+	Path        string // full path
+	Size        int64
+	MTime       int64
+	Hash        string // content hash; empty if not hashed
+	Kind        string
+	Container   string
+	Duration    time.Duration
+	Fingerprint []uint32 // nil when not fingerprinted
+}
+
+// Bitrate returns the file's average bitrate in bits per second (0 when its duration is unknown).
+func (m MediaFile) Bitrate() int64 {
+	if m.Duration <= 0 {
+		return 0
+	}
+	return m.Size * 8000 / m.Duration.Milliseconds()
+}
+
+// ListPendingMediaFiles returns the scan's files whose extension is in exts (lowercase, with leading dot) and that
+// have no media_info row for their current size and mtime. Path is the full path.
+func ListPendingMediaFiles(ctx context.Context, database *sql.DB, scanID int64, exts []string) ([]File, error) {
+	lower := make([]string, len(exts))
+	for i, e := range exts {
+		lower[i] = strings.ToLower(e)
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, $1::bigint, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 LEFT JOIN media_info m ON m.file_id = f.id AND m.size = f.size AND m.mtime = f.mtime
+		 WHERE fs.scan_id = $1 AND m.file_id IS NULL
+		   AND lower(substring(f.path from '\.[^./]*$')) = ANY($2)
+		 ORDER BY f.id`,
+		scanID, lower)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
+
+// UpsertMediaInfo stores the probe result for a file at the given size and mtime.
+func UpsertMediaInfo(ctx context.Context, database *sql.DB, fileID, size, mtime int64, rec MediaRecord) error {
+	var errVal, kind, container interface{}
+	if rec.Err != "" {
+		errVal = rec.Err
+	} else {
+		kind, container = rec.Kind, rec.Container
+	}
+	_, err := database.ExecContext(ctx,
+		`INSERT INTO media_info (file_id, size, mtime, kind, container, duration_ms, fingerprint, error, probed_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (file_id) DO UPDATE SET size = EXCLUDED.size, mtime = EXCLUDED.mtime, kind = EXCLUDED.kind,
+		   container = EXCLUDED.container, duration_ms = EXCLUDED.duration_ms, fingerprint = EXCLUDED.fingerprint,
+		   error = EXCLUDED.error, probed_at = EXCLUDED.probed_at`,
+		fileID, size, mtime, kind, container, rec.Duration.Milliseconds(), encodeFingerprint(rec.Fingerprint), errVal, NowUTC())
+	return err
+}
+
+// ListScanMedia returns the scan's successfully probed media files whose metadata matches their current size and mtime.
+func ListScanMedia(ctx context.Context, database *sql.DB, scanID int64) ([]MediaFile, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, (fo.path || '/' || f.path), f.size, f.mtime, COALESCE(f.hash, ''), m.kind, m.container, m.duration_ms, m.fingerprint
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 JOIN media_info m ON m.file_id = f.id AND m.size = f.size AND m.mtime = f.mtime
+		 WHERE fs.scan_id = $1 AND m.error IS NULL
+		 ORDER BY f.path`,
+		scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []MediaFile
+	for rows.Next() {
+		var m MediaFile
+		var ms int64
+		var fp []byte
+		if err := rows.Scan(&m.FileID, &m.Path, &m.Size, &m.MTime, &m.Hash, &m.Kind, &m.Container, &ms, &fp); err != nil {
+			return nil, err
+		}
+		m.Duration = time.Duration(ms) * time.Millisecond
+		m.Fingerprint = decodeFingerprint(fp)
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+func encodeFingerprint(fp []uint32) []byte {
+	if len(fp) == 0 {
+		return nil
+	}
+	b := make([]byte, 4*len(fp))
+	for i, v := range fp {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+func decodeFingerprint(b []byte) []uint32 {
+	if len(b) < 4 {
+		return nil
+	}
+	fp := make([]uint32, len(b)/4)
+	for i := range fp {
+		fp[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return fp
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// ImageMetadata is what the metadata phase read from an image file (file_metadata table).
+type ImageMetadata struct {
+	Width   int
+	Height  int
+	TakenAt *time.Time // EXIF date taken, in the camera's local time (shown as UTC); nil when absent
+	Camera  string     // EXIF make and model; "" when absent
+	Err     string     // set when the file could not be read; the other fields are then zero
+}
+
+// Pixels returns the image's resolution in pixels (0 when unknown).
+func (m ImageMetadata) Pixels() int64 { return int64(m.Width) * int64(m.Height) }
+
+// HasEXIF reports whether the image has an EXIF date taken or camera (see KeeperIntactEXIF).
+func (m ImageMetadata) HasEXIF() bool { return m.TakenAt != nil || m.Camera != "" }
+
+// ListPendingMetadataFiles returns the scan's files whose extension is in exts (lowercase, with leading dot) and
+// that have no file_metadata row for their current size and mtime. Path is the full path.
+func ListPendingMetadataFiles(ctx context.Context, database *sql.DB, scanID int64, exts []string) ([]File, error) {
+	lower := make([]string, len(exts))
+	for i, e := range exts {
+		lower[i] = strings.ToLower(e)
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, $1::bigint, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 LEFT JOIN file_metadata m ON m.file_id = f.id AND m.size = f.size AND m.mtime = f.mtime
+		 WHERE fs.scan_id = $1 AND m.file_id IS NULL
+		   AND lower(substring(f.path from '\.[^./]*$')) = ANY($2)
+		 ORDER BY f.id`,
+		scanID, lower)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
+
+// UpsertFileMetadata stores the metadata read from a file at the given size and mtime.
+func UpsertFileMetadata(ctx context.Context, database *sql.DB, fileID, size, mtime int64, m ImageMetadata) error {
+	var errVal interface{}
+	if m.Err != "" {
+		errVal = m.Err
+	}
+	_, err := database.ExecContext(ctx,
+		`INSERT INTO file_metadata (file_id, size, mtime, width, height, taken_at, camera, error, extracted_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (file_id) DO UPDATE SET size = EXCLUDED.size, mtime = EXCLUDED.mtime, width = EXCLUDED.width,
+		   height = EXCLUDED.height, taken_at = EXCLUDED.taken_at, camera = EXCLUDED.camera, error = EXCLUDED.error,
+		   extracted_at = EXCLUDED.extracted_at`,
+		fileID, size, mtime, m.Width, m.Height, m.TakenAt, m.Camera, errVal, NowUTC())
+	return err
+}
+
+// MetadataByFileID returns the metadata of those of the given files that were read successfully at their
+// current size and mtime.
+func MetadataByFileID(ctx context.Context, database *sql.DB, fileIDs []int64) (map[int64]ImageMetadata, error) {
+	out := make(map[int64]ImageMetadata)
+	if len(fileIDs) == 0 {
+		return out, nil
+	}
+	// #nosec G202 -- placeholders built from len(fileIDs); all values passed as args
+	q := `SELECT m.file_id, m.width, m.height, m.taken_at, m.camera
+		  FROM file_metadata m JOIN files f ON f.id = m.file_id AND f.size = m.size AND f.mtime = m.mtime
+		  WHERE m.error IS NULL AND m.file_id IN (` + placeholders(len(fileIDs), 1) + `)`
+	rows, err := database.QueryContext(ctx, q, idSlice(fileIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var m ImageMetadata
+		var taken sql.NullTime
+		if err := rows.Scan(&id, &m.Width, &m.Height, &taken, &m.Camera); err != nil {
+			return nil, err
+		}
+		if taken.Valid {
+			m.TakenAt = &taken.Time
+		}
+		out[id] = m
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFileMetadata_pendingUntilReadForCurrentVersion(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/photos")
+	sn, _ := CreateScan(ctx, database, folderID)
+	photo, _ := UpsertFile(ctx, database, folderID, "a.JPG", 100, 10, 1, nil)
+	broken, _ := UpsertFile(ctx, database, folderID, "b.png", 100, 10, 2, nil)
+	other, _ := UpsertFile(ctx, database, folderID, "c.txt", 100, 10, 3, nil)
+	for _, id := range []int64{photo, broken, other} {
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+	}
+	exts := []string{".jpg", ".png"}
+	if pending, err := ListPendingMetadataFiles(ctx, database, sn.ID, exts); err != nil || len(pending) != 2 {
+		t.Fatalf("ListPendingMetadataFiles = %d files, %v; want 2", len(pending), err)
+	}
+
+	taken := time.Date(2019, 5, 1, 10, 20, 30, 0, time.UTC)
+	if err := UpsertFileMetadata(ctx, database, photo, 100, 10, ImageMetadata{Width: 4000, Height: 3000, TakenAt: &taken, Camera: "Canon EOS 5D"}); err != nil {
+		t.Fatalf("UpsertFileMetadata: %v", err)
+	}
+	_ = UpsertFileMetadata(ctx, database, broken, 100, 10, ImageMetadata{Err: "unexpected EOF"})
+	if pending, _ := ListPendingMetadataFiles(ctx, database, sn.ID, exts); len(pending) != 0 {
+		t.Errorf("pending after reading = %d, want 0", len(pending))
+	}
+	meta, err := MetadataByFileID(ctx, database, []int64{photo, broken, other})
+	if err != nil {
+		t.Fatalf("MetadataByFileID: %v", err)
+	}
+	m, ok := meta[photo]
+	if len(meta) != 1 || !ok || m.Pixels() != 12_000_000 || m.Camera != "Canon EOS 5D" || m.TakenAt == nil || !m.TakenAt.Equal(taken) {
+		t.Errorf("MetadataByFileID = %+v, want only the photo's", meta)
+	}
+
+	// An edited photo is read again.
+	if _, err := UpsertFile(ctx, database, folderID, "a.JPG", 120, 11, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := ListPendingMetadataFiles(ctx, database, sn.ID, exts); len(pending) != 1 || pending[0].ID != photo {
+		t.Errorf("pending after an edit = %+v, want the photo", pending)
+	}
+	if meta, _ := MetadataByFileID(ctx, database, []int64{photo}); len(meta) != 0 {
+		t.Errorf("stale metadata returned: %+v", meta)
+	}
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFS holds the PostgreSQL schema as numbered migrations: NNNN_name.up.sql and NNNN_name.down.sql.
+// Add a new pair with the next number for every schema change; never edit a released migration.
+// Up migrations use IF NOT EXISTS so databases created before versioning adopt them without errors.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLockKey is the pg_advisory_lock key that serialises migration runs (ASCII "ditto" as an int).
+const migrationLockKey = 0x646974746f
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration and whether (and when) it was applied to a database.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version. Every version must have an up and a down file,
+// and versions must run 1, 2, 3, ... without gaps.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		base, dir, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (dir != "up" && dir != "down") {
+			return nil, fmt.Errorf("migration %s: want NNNN_name.up.sql or NNNN_name.down.sql", name)
+		}
+		num, label, _ := strings.Cut(base, "_")
+		v, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, num)
+		}
+		body, err := fs.ReadFile(migrationFS, path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[v]
+		if m == nil {
+			m = &Migration{Version: v, Name: label}
+			byVersion[v] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d: names %q and %q differ", v, m.Name, label)
+		}
+		if dir == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs both up and down files", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i, m := range list {
+		if m.Version != int64(i+1) {
+			return nil, fmt.Errorf("migration versions must be contiguous from 1: found %d at position %d", m.Version, i+1)
+		}
+	}
+	return list, nil
+}
+
+// LatestMigrationVersion returns the version of the newest embedded migration.
+func LatestMigrationVersion() (int64, error) {
+	list, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(list) == 0 {
+		return 0, nil
+	}
+	return list[len(list)-1].Version, nil
+}
+
+// MigratePostgres applies every pending migration (see MigrateTo). Safe to call on every startup.
+func MigratePostgres(db *sql.DB) error {
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+	return MigrateTo(context.Background(), db, latest)
+}
+
+// MigrateTo moves the schema to target: pending up migrations are applied in order, or applied migrations
+// above target are reverted newest first. Each migration runs in its own transaction together with its
+// schema_migrations row, and concurrent runners (several instances starting at once) wait on an advisory lock.
+// A database whose recorded version is newer than this binary's migrations is refused rather than guessed at.
+func MigrateTo(ctx context.Context, db *sql.DB, target int64) error {
+	list, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if target < 0 || target > int64(len(list)) {
+		return fmt.Errorf("migrate: target version %d out of range 0..%d", target, len(list))
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}()
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	var current int64
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("migrate: current version: %w", err)
+	}
+	if current > int64(len(list)) {
+		return fmt.Errorf("migrate: database is at version %d, newer than this build (%d); upgrade ditto", current, len(list))
+	}
+	for v := current + 1; v <= target; v++ {
+		m := list[v-1]
+		if err := runMigration(ctx, conn, m.Up,
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", m.Version, m.Name, NowUTC()); err != nil {
+			return fmt.Errorf("migrate: up %d_%s: %w", m.Version, m.Name, err)
+		}
+		log.Printf("[migrate] applied %d_%s", m.Version, m.Name)
+	}
+	for v := current; v > target; v-- {
+		m := list[v-1]
+		if err := runMigration(ctx, conn, m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version); err != nil {
+			return fmt.Errorf("migrate: down %d_%s: %w", m.Version, m.Name, err)
+		}
+		log.Printf("[migrate] reverted %d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// runMigration executes a migration script and its bookkeeping statement in one transaction.
+func runMigration(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// No arguments: the driver sends the script with the simple protocol, so it may hold several statements.
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus lists the embedded migrations with the time each was applied (nil when pending).
+func MigrationStatus(ctx context.Context, db *sql.DB) ([]MigrationState, error) {
+	list, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]time.Time)
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil && !isUndefinedTable(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var v int64
+			var at time.Time
+			if err := rows.Scan(&v, &at); err != nil {
+				return nil, err
+			}
+			applied[v] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]MigrationState, len(list))
+	for i, m := range list {
+		out[i] = MigrationState{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			out[i].AppliedAt = &at
+		}
+	}
+	return out, nil
+}
+
+// isUndefinedTable reports whether err is PostgreSQL's undefined_table (42P01), e.g. schema_migrations on a new database.
+func isUndefinedTable(err error) bool {
+	var coded interface{ SQLState() string }
+	return errors.As(err, &coded) && coded.SQLState() == "42P01"
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS file_scan;
+DROP TABLE IF EXISTS files;
+DROP TABLE IF EXISTS scans;
+DROP TABLE IF EXISTS folders;
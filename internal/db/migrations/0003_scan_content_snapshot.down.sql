@@ -0,0 +1,2 @@
+ALTER TABLE file_scan DROP COLUMN IF EXISTS hash;
+ALTER TABLE file_scan DROP COLUMN IF EXISTS size;
@@ -0,0 +1,3 @@
+-- Per-scan content snapshot (set at end of hash phase) so changes between scans can be reported.
+ALTER TABLE file_scan ADD COLUMN IF NOT EXISTS size BIGINT;
+ALTER TABLE file_scan ADD COLUMN IF NOT EXISTS hash TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE folders DROP COLUMN IF EXISTS low_priority;
+ALTER TABLE folders DROP COLUMN IF EXISTS max_read_bytes_per_sec;
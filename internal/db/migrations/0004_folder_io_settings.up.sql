@@ -0,0 +1,3 @@
+-- Per-folder I/O settings applied to scan walkers and hash workers.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS max_read_bytes_per_sec BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS low_priority BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Set when a byte-by-byte comparison confirmed the file matches the rest of its hash group.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ;
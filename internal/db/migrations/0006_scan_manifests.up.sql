@@ -0,0 +1,7 @@
+-- Digest of each exported scan manifest, chained to the folder's previous manifest.
+CREATE TABLE IF NOT EXISTS scan_manifests (
+	scan_id BIGINT PRIMARY KEY REFERENCES scans(id) ON DELETE CASCADE,
+	digest TEXT NOT NULL,
+	previous_digest TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
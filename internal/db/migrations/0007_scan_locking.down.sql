@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS scans_lock_immutable ON scans;
+DROP FUNCTION IF EXISTS ditto_scan_lock_immutable();
+DROP TRIGGER IF EXISTS file_scan_locked ON file_scan;
+DROP FUNCTION IF EXISTS ditto_file_scan_locked();
+ALTER TABLE scans DROP COLUMN IF EXISTS checksum;
+ALTER TABLE scans DROP COLUMN IF EXISTS locked_at;
+ALTER TABLE file_scan DROP COLUMN IF EXISTS mtime;
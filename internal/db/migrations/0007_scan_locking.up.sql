@@ -0,0 +1,30 @@
+-- Locked scans: the ledger snapshot is frozen and a checksum over it is stored for tamper detection.
+ALTER TABLE file_scan ADD COLUMN IF NOT EXISTS mtime BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS locked_at TIMESTAMPTZ;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS checksum TEXT;
+CREATE OR REPLACE FUNCTION ditto_file_scan_locked() RETURNS trigger AS $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM scans WHERE id = COALESCE(NEW.scan_id, OLD.scan_id) AND locked_at IS NOT NULL)
+		OR (TG_OP = 'UPDATE' AND EXISTS (SELECT 1 FROM scans WHERE id = OLD.scan_id AND locked_at IS NOT NULL)) THEN
+		RAISE EXCEPTION 'scan % is locked', COALESCE(NEW.scan_id, OLD.scan_id);
+	END IF;
+	IF TG_OP = 'DELETE' THEN
+		RETURN OLD;
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS file_scan_locked ON file_scan;
+CREATE TRIGGER file_scan_locked BEFORE INSERT OR UPDATE OR DELETE ON file_scan
+	FOR EACH ROW EXECUTE FUNCTION ditto_file_scan_locked();
+CREATE OR REPLACE FUNCTION ditto_scan_lock_immutable() RETURNS trigger AS $$
+BEGIN
+	IF OLD.locked_at IS NOT NULL AND (NEW.locked_at IS DISTINCT FROM OLD.locked_at OR NEW.checksum IS DISTINCT FROM OLD.checksum) THEN
+		RAISE EXCEPTION 'scan % is locked', OLD.id;
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS scans_lock_immutable ON scans;
+CREATE TRIGGER scans_lock_immutable BEFORE UPDATE ON scans
+	FOR EACH ROW EXECUTE FUNCTION ditto_scan_lock_immutable();
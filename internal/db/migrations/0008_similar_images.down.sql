@@ -0,0 +1,3 @@
+ALTER TABLE folders DROP COLUMN IF EXISTS similar_images;
+ALTER TABLE files DROP COLUMN IF EXISTS phash_status;
+ALTER TABLE files DROP COLUMN IF EXISTS phash;
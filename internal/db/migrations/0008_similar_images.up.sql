@@ -0,0 +1,4 @@
+-- Perceptual hash of image files for near-duplicate detection (opt-in per folder).
+ALTER TABLE files ADD COLUMN IF NOT EXISTS phash BIGINT;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS phash_status TEXT;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS similar_images BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS media_info;
+ALTER TABLE folders DROP COLUMN IF EXISTS similar_media;
@@ -0,0 +1,13 @@
+-- Audio/video metadata and optional Chromaprint fingerprint; stale once files.size or mtime differ.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS similar_media BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS media_info (
+	file_id BIGINT PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+	size BIGINT NOT NULL,
+	mtime BIGINT NOT NULL,
+	kind TEXT,
+	container TEXT,
+	duration_ms BIGINT NOT NULL DEFAULT 0,
+	fingerprint BYTEA,
+	error TEXT,
+	probed_at TIMESTAMPTZ NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
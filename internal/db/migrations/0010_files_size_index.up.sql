@@ -0,0 +1,2 @@
+-- Largest-files view orders a scan's files by size.
+CREATE INDEX IF NOT EXISTS idx_files_size ON files(size DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE folders DROP COLUMN IF EXISTS media_image;
+ALTER TABLE folders DROP COLUMN IF EXISTS media_label;
@@ -0,0 +1,3 @@
+-- Offline media: removable drives and disk images that stay in duplicate detection while unplugged.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS media_label TEXT NOT NULL DEFAULT '';
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS media_image TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE scans DROP COLUMN IF EXISTS volume_id;
+DROP TABLE IF EXISTS volumes;
@@ -0,0 +1,12 @@
+-- Physical volumes (by filesystem UUID) seen by scans, so removable drives are tracked per disk.
+CREATE TABLE IF NOT EXISTS volumes (
+	id BIGSERIAL PRIMARY KEY,
+	uuid TEXT NOT NULL UNIQUE,
+	label TEXT NOT NULL DEFAULT '',
+	fs_type TEXT NOT NULL DEFAULT '',
+	include_in_all BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC'),
+	last_seen_at TIMESTAMPTZ NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS volume_id BIGINT REFERENCES volumes(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_scans_volume_id ON scans(volume_id);
@@ -0,0 +1,2 @@
+-- Imported manifests: virtual folders whose files come from an external hash list, never from a walk.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS imported BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- At most one running scan walk per folder; heartbeat_at lets a crashed holder's row be taken over.
+CREATE TABLE IF NOT EXISTS folder_locks (
+	folder_id BIGINT PRIMARY KEY REFERENCES folders(id) ON DELETE CASCADE,
+	scan_id BIGINT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	holder TEXT NOT NULL,
+	acquired_at TIMESTAMPTZ NOT NULL,
+	heartbeat_at TIMESTAMPTZ NOT NULL
+);
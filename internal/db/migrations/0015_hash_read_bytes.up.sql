@@ -0,0 +1,2 @@
+-- Bytes actually read by a scan's hash phase (reused hashes excluded), for throughput-based estimates.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS hash_read_bytes BIGINT NOT NULL DEFAULT 0;
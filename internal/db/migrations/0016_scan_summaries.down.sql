@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scan_summary_extensions;
+DROP TABLE IF EXISTS scan_summaries;
@@ -0,0 +1,20 @@
+-- Duplicate statistics per scan, computed when the hash phase completes (the home page reads them instead of
+-- aggregating the scan's files on every load). Columns mirror SavingsProjection.
+CREATE TABLE IF NOT EXISTS scan_summaries (
+	scan_id BIGINT PRIMARY KEY REFERENCES scans(id) ON DELETE CASCADE,
+	group_count BIGINT NOT NULL,
+	duplicate_files BIGINT NOT NULL,
+	cross_device_groups BIGINT NOT NULL,
+	physical_bytes BIGINT NOT NULL,
+	delete_savings BIGINT NOT NULL,
+	link_savings BIGINT NOT NULL,
+	cross_device_bytes BIGINT NOT NULL,
+	computed_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS scan_summary_extensions (
+	scan_id BIGINT NOT NULL REFERENCES scan_summaries(scan_id) ON DELETE CASCADE,
+	ext TEXT NOT NULL,
+	files BIGINT NOT NULL,
+	wasted_bytes BIGINT NOT NULL,
+	PRIMARY KEY (scan_id, ext)
+);
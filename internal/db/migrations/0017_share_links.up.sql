@@ -0,0 +1,11 @@
+-- Expiring read-only links to one scan's duplicate report. Only the SHA-256 of the token is stored.
+CREATE TABLE IF NOT EXISTS share_links (
+	id BIGSERIAL PRIMARY KEY,
+	token_hash TEXT NOT NULL UNIQUE,
+	scan_id BIGINT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	ext TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_share_links_scan_id ON share_links(scan_id);
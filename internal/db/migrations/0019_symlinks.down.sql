@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN IF EXISTS symlink_target;
+ALTER TABLE folders DROP COLUMN IF EXISTS symlinks;
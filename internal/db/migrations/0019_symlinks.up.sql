@@ -0,0 +1,3 @@
+-- Symlink handling per scan root ('skip', 'record' or 'follow') and the target of recorded symlinks.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS symlinks TEXT NOT NULL DEFAULT 'skip';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS symlink_target TEXT;
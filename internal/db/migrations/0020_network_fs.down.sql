@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scan_slow_dirs;
+ALTER TABLE folders DROP COLUMN IF EXISTS network_fs;
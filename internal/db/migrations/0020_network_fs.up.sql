@@ -0,0 +1,12 @@
+-- Network-share handling per scan root ('auto', 'on' or 'off') and the directories a scan found slow or unreachable.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS network_fs TEXT NOT NULL DEFAULT 'auto';
+CREATE TABLE IF NOT EXISTS scan_slow_dirs (
+	id BIGSERIAL PRIMARY KEY,
+	scan_id BIGINT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	path TEXT NOT NULL,
+	duration_ms BIGINT NOT NULL,
+	attempts INTEGER NOT NULL,
+	outcome TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_scan_slow_dirs_scan ON scan_slow_dirs(scan_id);
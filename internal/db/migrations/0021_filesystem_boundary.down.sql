@@ -0,0 +1,3 @@
+ALTER TABLE folders DROP COLUMN IF EXISTS device_id;
+ALTER TABLE folders DROP COLUMN IF EXISTS fs_type;
+ALTER TABLE folders DROP COLUMN IF EXISTS one_file_system;
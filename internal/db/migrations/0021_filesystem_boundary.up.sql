@@ -0,0 +1,4 @@
+-- Per scan root: stay on the root's filesystem while walking, and the filesystem type and device last seen at the root.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS one_file_system BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS fs_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS device_id BIGINT;
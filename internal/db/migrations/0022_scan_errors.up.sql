@@ -0,0 +1,11 @@
+-- Errors met while walking or hashing a scan, shown on the scan page instead of only in the logs.
+CREATE TABLE IF NOT EXISTS scan_errors (
+	id BIGSERIAL PRIMARY KEY,
+	scan_id BIGINT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	file_id BIGINT,
+	path TEXT NOT NULL,
+	phase TEXT NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_scan_errors_scan ON scan_errors(scan_id, id);
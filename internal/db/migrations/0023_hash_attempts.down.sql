@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN IF EXISTS hash_error;
+ALTER TABLE files DROP COLUMN IF EXISTS hash_attempts;
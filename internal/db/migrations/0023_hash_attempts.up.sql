@@ -0,0 +1,4 @@
+-- Bounded hash retries: how many times the hash phase tried to read the file and the last error. A file that
+-- still fails after the last attempt gets hash_status 'failed' instead of going back to 'pending'.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS hash_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS hash_error TEXT;
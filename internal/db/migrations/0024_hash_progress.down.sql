@@ -0,0 +1,4 @@
+ALTER TABLE scans DROP COLUMN IF EXISTS hash_progress_at;
+ALTER TABLE scans DROP COLUMN IF EXISTS hash_progress_bytes;
+ALTER TABLE scans DROP COLUMN IF EXISTS hash_progress_done;
+ALTER TABLE scans DROP COLUMN IF EXISTS hash_progress_total;
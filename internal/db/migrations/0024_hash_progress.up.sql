@@ -0,0 +1,5 @@
+-- Running hash phase progress, written every few seconds so the web UI can show throughput and an ETA.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS hash_progress_total BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS hash_progress_done BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS hash_progress_bytes BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS hash_progress_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS bitrot_findings;
+ALTER TABLE scans DROP COLUMN IF EXISTS verified_file_count;
+ALTER TABLE scans DROP COLUMN IF EXISTS verify_completed_at;
+ALTER TABLE scans DROP COLUMN IF EXISTS verify;
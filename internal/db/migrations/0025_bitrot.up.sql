@@ -0,0 +1,18 @@
+-- Verification scans: after hashing, files unchanged since their last hash (same size and mtime) are read again
+-- and compared with the stored hash. A mismatch means the content changed without its metadata: possible bit rot.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS verify BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS verify_completed_at TIMESTAMPTZ;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS verified_file_count BIGINT;
+CREATE TABLE IF NOT EXISTS bitrot_findings (
+	id BIGSERIAL PRIMARY KEY,
+	scan_id BIGINT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	file_id BIGINT NOT NULL,
+	path TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	mtime BIGINT NOT NULL,
+	expected_hash TEXT NOT NULL,
+	actual_hash TEXT NOT NULL,
+	hashed_at TIMESTAMPTZ,
+	detected_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_bitrot_findings_scan ON bitrot_findings(scan_id, id);
@@ -0,0 +1,8 @@
+-- Exclude patterns per scan root managed in the web UI; applied on top of the defaults and the root's .dittoignore.
+CREATE TABLE IF NOT EXISTS folder_excludes (
+	id BIGSERIAL PRIMARY KEY,
+	folder_id BIGINT NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+	pattern TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (folder_id, pattern)
+);
@@ -0,0 +1,7 @@
+-- Duplicate groups marked as intentional (deliberate copies, backups): hidden from duplicate listings and left
+-- out of reclaimable-space totals. A group is its content hash, so the mark applies in every scan.
+CREATE TABLE IF NOT EXISTS acknowledged_groups (
+	hash TEXT PRIMARY KEY,
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,7 @@
+-- The file pinned as the canonical copy of a duplicate group: actions on the group never remove it.
+-- A group is its content hash; a keeper whose content later changes no longer belongs to the group.
+CREATE TABLE IF NOT EXISTS group_keepers (
+	hash TEXT PRIMARY KEY,
+	file_id BIGINT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL
+);
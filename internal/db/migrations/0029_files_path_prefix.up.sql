@@ -0,0 +1,3 @@
+-- Path-prefix filters on duplicate listings compare files.path with LIKE 'dir/%' within one folder;
+-- text_pattern_ops lets that use an index whatever the database collation.
+CREATE INDEX IF NOT EXISTS idx_files_folder_id_path_pattern ON files(folder_id, path text_pattern_ops);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_folders_owner_id;
+ALTER TABLE folders DROP COLUMN IF EXISTS owner_id;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,9 @@
+-- Users of a shared instance, identified by the name a reverse proxy passes in (see DITTO_USER_HEADER).
+-- A folder with an owner is visible only to that user; a folder without one is shared with everyone.
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL
+);
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS owner_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_folders_owner_id ON folders(owner_id);
@@ -0,0 +1,2 @@
+-- A user's role: admins change things (scans, links, deletions, settings); viewers only look.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'admin' CHECK (role IN ('admin', 'viewer'));
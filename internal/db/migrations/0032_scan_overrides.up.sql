@@ -0,0 +1,2 @@
+-- Per-run settings a scan was started with through the API (workers, read throttle, extra exclude patterns).
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS overrides JSONB;
@@ -0,0 +1,11 @@
+-- Bearer tokens for scripts and home automation. A token is read-only or admin and acts for the user who
+-- created it (none on a single-user instance). Only the SHA-256 of the token is stored.
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	token_hash TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	scope TEXT NOT NULL CHECK (scope IN ('read', 'admin')),
+	user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL,
+	last_used_at TIMESTAMPTZ
+);
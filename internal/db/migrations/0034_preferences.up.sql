@@ -0,0 +1,15 @@
+-- Saved home page settings: of a signed-in user, or of a browser (a random id in a cookie) when ditto has
+-- no users. Exactly one of user_id and browser_id is set.
+CREATE TABLE IF NOT EXISTS preferences (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+	browser_id TEXT UNIQUE,
+	page_size INTEGER NOT NULL,
+	root_path TEXT, -- default folder; NULL = the newest scanned, '' = All (latest per folder)
+	sort TEXT NOT NULL DEFAULT '',
+	min_mb BIGINT NOT NULL DEFAULT 0,
+	prefix TEXT NOT NULL DEFAULT '',
+	prefix_only BOOLEAN NOT NULL DEFAULT FALSE,
+	updated_at TIMESTAMPTZ NOT NULL,
+	CHECK ((user_id IS NULL) <> (browser_id IS NULL))
+);
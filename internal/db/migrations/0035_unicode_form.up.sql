@@ -0,0 +1,3 @@
+-- Unicode form scans store a folder's paths in ('none', 'nfc' or 'nfd'), so the same share scanned from macOS
+-- and Linux yields the same paths.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS unicode_form TEXT NOT NULL DEFAULT 'none';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_files_size_mtime_hashed;
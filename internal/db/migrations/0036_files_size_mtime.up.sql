@@ -0,0 +1,2 @@
+-- Looks up hashed files by size and modification time, to reuse the hash of a file that moved.
+CREATE INDEX IF NOT EXISTS idx_files_size_mtime_hashed ON files(size, mtime) WHERE hash IS NOT NULL;
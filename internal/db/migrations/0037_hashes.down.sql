@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS files_hash_id ON files;
+DROP FUNCTION IF EXISTS ditto_file_hash_id();
+DROP INDEX IF EXISTS idx_files_hash_id;
+ALTER TABLE files DROP COLUMN IF EXISTS hash_id;
+DROP TABLE IF EXISTS hashes;
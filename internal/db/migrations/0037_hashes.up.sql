@@ -0,0 +1,47 @@
+-- Content-addressed store of every hash ditto has computed, kept when the files and scans that had it are
+-- deleted: where and when each content was first seen. Files point at it through hash_id, set by a trigger
+-- whenever files.hash changes.
+CREATE TABLE IF NOT EXISTS hashes (
+	id BIGSERIAL PRIMARY KEY,
+	algorithm TEXT NOT NULL,
+	digest TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	first_seen_at TIMESTAMPTZ NOT NULL,
+	first_folder_id BIGINT REFERENCES folders(id) ON DELETE SET NULL,
+	first_path TEXT NOT NULL,
+	UNIQUE (algorithm, digest, size)
+);
+ALTER TABLE files ADD COLUMN IF NOT EXISTS hash_id BIGINT REFERENCES hashes(id);
+CREATE INDEX IF NOT EXISTS idx_files_hash_id ON files(hash_id) WHERE hash_id IS NOT NULL;
+INSERT INTO hashes (algorithm, digest, size, first_seen_at, first_folder_id, first_path)
+	SELECT DISTINCT ON (f.hash, f.size) 'sha256', f.hash, f.size, COALESCE(f.hashed_at, now()), f.folder_id, fo.path || '/' || f.path
+	FROM files f JOIN folders fo ON fo.id = f.folder_id
+	WHERE f.hash IS NOT NULL
+	ORDER BY f.hash, f.size, f.hashed_at NULLS LAST, f.id
+	ON CONFLICT (algorithm, digest, size) DO NOTHING;
+UPDATE files f SET hash_id = h.id FROM hashes h
+	WHERE f.hash IS NOT NULL AND f.hash_id IS NULL AND h.algorithm = 'sha256' AND h.digest = f.hash AND h.size = f.size;
+CREATE OR REPLACE FUNCTION ditto_file_hash_id() RETURNS trigger AS $$
+BEGIN
+	-- Scans rewrite hash on every upsert; only a changed hash needs a lookup.
+	IF TG_OP = 'UPDATE' AND NEW.hash IS NOT DISTINCT FROM OLD.hash AND NEW.size = OLD.size THEN
+		RETURN NEW;
+	END IF;
+	IF NEW.hash IS NULL THEN
+		NEW.hash_id := NULL;
+		RETURN NEW;
+	END IF;
+	SELECT id INTO NEW.hash_id FROM hashes WHERE algorithm = 'sha256' AND digest = NEW.hash AND size = NEW.size;
+	IF NEW.hash_id IS NULL THEN
+		INSERT INTO hashes (algorithm, digest, size, first_seen_at, first_folder_id, first_path)
+			SELECT 'sha256', NEW.hash, NEW.size, COALESCE(NEW.hashed_at, now()), NEW.folder_id, fo.path || '/' || NEW.path
+			FROM folders fo WHERE fo.id = NEW.folder_id
+			ON CONFLICT (algorithm, digest, size) DO NOTHING;
+		SELECT id INTO NEW.hash_id FROM hashes WHERE algorithm = 'sha256' AND digest = NEW.hash AND size = NEW.size;
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS files_hash_id ON files;
+CREATE TRIGGER files_hash_id BEFORE INSERT OR UPDATE OF hash, size ON files
+	FOR EACH ROW EXECUTE FUNCTION ditto_file_hash_id();
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS file_metadata;
+ALTER TABLE folders DROP COLUMN IF EXISTS photo_metadata;
@@ -0,0 +1,14 @@
+-- Photo metadata (EXIF date taken and camera, pixel dimensions) read after the hash phase for folders that
+-- opt in; stale once files.size or mtime differ. taken_at is the camera's local time, without a zone.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS photo_metadata BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS file_metadata (
+	file_id BIGINT PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+	size BIGINT NOT NULL,
+	mtime BIGINT NOT NULL,
+	width INTEGER NOT NULL DEFAULT 0,
+	height INTEGER NOT NULL DEFAULT 0,
+	taken_at TIMESTAMP,
+	camera TEXT NOT NULL DEFAULT '',
+	error TEXT,
+	extracted_at TIMESTAMPTZ NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
@@ -0,0 +1,3 @@
+-- Scans of folders that opt in also list the files inside zip and tar archives, stored under a virtual path
+-- "<archive>!/<member>" with inode 0 and no device (members are not hardlinks of one another).
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS archives BOOLEAN NOT NULL DEFAULT FALSE;
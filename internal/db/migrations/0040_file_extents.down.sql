@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN IF EXISTS shared_bytes;
+ALTER TABLE files DROP COLUMN IF EXISTS allocated;
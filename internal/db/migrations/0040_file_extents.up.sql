@@ -0,0 +1,5 @@
+-- Disk usage of each file, so savings do not count space the filesystem already saves: allocated is the bytes
+-- allocated to the file when scanned (less than size for a sparse file; NULL = unknown) and shared_bytes the
+-- bytes in extents it shares with other files (reflinks, block deduplication), measured when it is hashed.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS allocated BIGINT;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS shared_bytes BIGINT;
@@ -0,0 +1,9 @@
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_batch_p99_us;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_batch_p95_us;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_batch_p50_us;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_batches;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_lstats;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_dirs;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_stall_ms;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_db_ms;
+ALTER TABLE scans DROP COLUMN IF EXISTS walk_fs_ms;
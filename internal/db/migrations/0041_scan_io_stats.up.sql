@@ -0,0 +1,11 @@
+-- I/O statistics of a scan's walk, written when it completes: time in filesystem calls, in database batches and
+-- blocked on the queue between them, call counts and batch latency percentiles, for the scan page's bottleneck report.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_fs_ms BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_db_ms BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_stall_ms BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_dirs BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_lstats BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_batches BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_batch_p50_us BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_batch_p95_us BIGINT;
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS walk_batch_p99_us BIGINT;
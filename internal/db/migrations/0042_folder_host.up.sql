@@ -0,0 +1,3 @@
+-- Machine a folder's files are on: '' for the machine running ditto, else the host an agent or import named, so
+-- the same content found on two machines can be told apart from copies on one.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS host TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- How many paths each exclude pattern skipped during a scan's walk, in pattern order, so patterns that never match
+-- (typos, paths that moved) show up on the scan page.
+CREATE TABLE IF NOT EXISTS scan_exclude_hits (
+	scan_id BIGINT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	pattern TEXT NOT NULL,
+	paths BIGINT NOT NULL,
+	PRIMARY KEY (scan_id, position)
+);
@@ -0,0 +1,3 @@
+-- When a folder was disabled (a drive taken offline for a while): its scans and files are kept, but it is left out
+-- of All and new scans of it are refused until it is enabled again. NULL = enabled.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
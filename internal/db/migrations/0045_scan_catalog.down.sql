@@ -0,0 +1,2 @@
+ALTER TABLE scans DROP COLUMN IF EXISTS catalog_uuid;
+ALTER TABLE scans DROP COLUMN IF EXISTS catalog_label;
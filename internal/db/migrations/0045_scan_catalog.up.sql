@@ -0,0 +1,4 @@
+-- Catalog scans of removable drives record the drive's label and filesystem UUID as they were when the scan
+-- started, so the catalog still names its drive after the drive is unplugged or relabelled. '' = not a catalog.
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS catalog_label TEXT NOT NULL DEFAULT '';
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS catalog_uuid TEXT NOT NULL DEFAULT '';
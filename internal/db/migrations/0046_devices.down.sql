@@ -0,0 +1,2 @@
+UPDATE files f SET device_id = d.st_dev FROM devices d WHERE d.id = f.device_id;
+DROP TABLE IF EXISTS devices;
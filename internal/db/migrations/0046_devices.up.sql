@@ -0,0 +1,18 @@
+-- Devices give files.device_id a stable meaning: st_dev numbers change when a disk is mounted in another order or
+-- a share is remounted, so files store the id of their filesystem here (found by its UUID) instead. Hardlink
+-- detection and hash reuse from earlier scans then still match the same files after a remount.
+-- uuid is the filesystem UUID, or 'dev:<st_dev>' for a filesystem without one (network shares, tmpfs).
+CREATE TABLE IF NOT EXISTS devices (
+	id BIGSERIAL PRIMARY KEY,
+	uuid TEXT NOT NULL UNIQUE,
+	st_dev BIGINT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Existing files stored raw st_dev numbers: each becomes a device without a UUID yet, which the next scan of that
+-- filesystem adopts once it learns the UUID (db.ResolveDevice).
+INSERT INTO devices (uuid, st_dev)
+SELECT DISTINCT 'dev:' || device_id, device_id FROM files WHERE device_id IS NOT NULL
+ON CONFLICT (uuid) DO NOTHING;
+UPDATE files f SET device_id = d.id FROM devices d WHERE d.uuid = 'dev:' || f.device_id;
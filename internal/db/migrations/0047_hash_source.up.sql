@@ -0,0 +1,7 @@
+-- How each file's hash was obtained: 'computed' (the file was read), 'inode' (a hardlink in the same scan had it),
+-- 'previous' (the same inode and size had it in an earlier scan), 'moved' (files elsewhere with the same size and
+-- modification time had it) or 'imported' (a manifest supplied it). NULL for hashes stored before this column.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS hash_source TEXT;
+
+UPDATE files SET hash_source = 'imported'
+WHERE hash IS NOT NULL AND hash_source IS NULL AND folder_id IN (SELECT id FROM folders WHERE imported);
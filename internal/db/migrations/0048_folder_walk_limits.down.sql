@@ -0,0 +1,2 @@
+ALTER TABLE folders DROP COLUMN IF EXISTS max_files;
+ALTER TABLE folders DROP COLUMN IF EXISTS max_depth;
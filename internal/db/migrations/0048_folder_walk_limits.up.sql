@@ -0,0 +1,4 @@
+-- Per-folder safety limits of the scan walk: skip directories deeper than max_depth below the root and fail a scan
+-- that finds more than max_files files. 0 uses DITTO_SCAN_MAX_DEPTH / DITTO_SCAN_MAX_FILES (unlimited when unset).
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS max_depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS max_files BIGINT NOT NULL DEFAULT 0;
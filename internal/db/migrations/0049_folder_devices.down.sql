@@ -0,0 +1 @@
+UPDATE folders f SET device_id = d.st_dev FROM devices d WHERE d.id = f.device_id;
@@ -0,0 +1,6 @@
+-- folders.device_id still held the raw st_dev of the root (migration 0046 only converted files): store the id of
+-- its device instead, as files do, so it survives remounts. The next scan of the root gives it the device's UUID.
+INSERT INTO devices (uuid, st_dev)
+SELECT DISTINCT 'dev:' || device_id, device_id FROM folders WHERE device_id IS NOT NULL
+ON CONFLICT (uuid) DO NOTHING;
+UPDATE folders f SET device_id = d.id FROM devices d WHERE d.uuid = 'dev:' || f.device_id;
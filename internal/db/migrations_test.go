@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMigrations_embeddedAreContiguousWithUpAndDown(t *testing.T) {
+	list, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(list) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, m := range list {
+		if m.Version != int64(i+1) || m.Name == "" || strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d = {%d %q, up %d bytes, down %d bytes}", i, m.Version, m.Name, len(m.Up), len(m.Down))
+		}
+	}
+}
+
+func TestMigrateTo_downToZeroAndBackUp(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+	latest, _ := LatestMigrationVersion()
+
+	// Already migrated by TestPostgresDB: running again is a no-op.
+	if err := MigratePostgres(database); err != nil {
+		t.Fatalf("MigratePostgres again: %v", err)
+	}
+	status, err := MigrationStatus(ctx, database)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d_%s not applied", s.Version, s.Name)
+		}
+	}
+
+	if err := MigrateTo(ctx, database, 0); err != nil {
+		t.Fatalf("MigrateTo(0): %v", err)
+	}
+	var tables int
+	if err := database.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'public' AND table_name <> 'schema_migrations'`).Scan(&tables); err != nil {
+		t.Fatalf("count tables: %v", err)
+	}
+	if tables != 0 {
+		t.Errorf("after MigrateTo(0): %d tables left, want 0", tables)
+	}
+
+	if err := MigrateTo(ctx, database, latest); err != nil {
+		t.Fatalf("MigrateTo(%d): %v", latest, err)
+	}
+	folderID, err := AddFolder(ctx, database, "/data")
+	if err != nil || folderID == 0 {
+		t.Errorf("AddFolder after re-migrating: %d, %v", folderID, err)
+	}
+	if err := MigrateTo(ctx, database, latest+1); err == nil {
+		t.Error("MigrateTo(latest+1) = nil, want out of range error")
+	}
+}
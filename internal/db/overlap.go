@@ -0,0 +1,85 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FolderOverlap is a scan root registered inside another one (/data/photos inside /data): the scans of both
+// list the files under Inner, each as a file row of its own folder.
+type FolderOverlap struct {
+	OuterID   int64
+	OuterPath string
+	InnerID   int64
+	InnerPath string
+}
+
+// rel returns Inner's path relative to Outer, as stored in Outer's file paths.
+func (o FolderOverlap) rel() string {
+	return strings.TrimPrefix(strings.TrimPrefix(o.InnerPath, strings.TrimRight(o.OuterPath, "/")), "/")
+}
+
+// FolderOverlaps returns every pair of folders where one lies inside the other, by outer then inner path.
+// Imports have no place in the filesystem and never overlap.
+func FolderOverlaps(folders []Folder) []FolderOverlap {
+	var out []FolderOverlap
+	for _, outer := range folders {
+		if outer.Imported {
+			continue
+		}
+		prefix := strings.TrimRight(outer.Path, "/") + "/"
+		for _, inner := range folders {
+			if inner.Imported || inner.ID == outer.ID || !strings.HasPrefix(inner.Path, prefix) {
+				continue
+			}
+			out = append(out, FolderOverlap{OuterID: outer.ID, OuterPath: outer.Path, InnerID: inner.ID, InnerPath: inner.Path})
+		}
+	}
+	slices.SortFunc(out, func(a, b FolderOverlap) int {
+		return cmp.Or(cmp.Compare(a.OuterPath, b.OuterPath), cmp.Compare(a.InnerPath, b.InnerPath))
+	})
+	return out
+}
+
+// overlapCondition returns a condition (starting with " AND ", or empty) that leaves out, among the files
+// (joined as f) of the given scans, those an overlapping scan lists too: when the scans cover both a folder and
+// a folder inside it, the files under the inner folder are kept once, as the inner folder's rows, so a file
+// is not taken for a duplicate of itself. Placeholders are numbered from next.
+func overlapCondition(ctx context.Context, database *sql.DB, scanIDs []int64, next int) (string, []interface{}, error) {
+	if len(scanIDs) < 2 {
+		return "", nil, nil
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT DISTINCT fo.id, fo.path, fo.imported FROM scans s JOIN folders fo ON s.folder_id = fo.id
+		 WHERE s.id IN (`+placeholders(len(scanIDs), 1)+`) ORDER BY fo.id`, idSlice(scanIDs)...)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+	var folders []Folder
+	for rows.Next() {
+		var f Folder
+		if err := rows.Scan(&f.ID, &f.Path, &f.Imported); err != nil {
+			return "", nil, err
+		}
+		folders = append(folders, f)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	overlaps := FolderOverlaps(folders)
+	if len(overlaps) == 0 {
+		return "", nil, nil
+	}
+	conds := make([]string, 0, len(overlaps))
+	args := make([]interface{}, 0, 2*len(overlaps))
+	for _, o := range overlaps {
+		conds = append(conds, fmt.Sprintf("(f.folder_id = $%d AND f.path LIKE $%d)", next+len(args), next+len(args)+1))
+		args = append(args, o.OuterID, likeEscape(o.rel())+"/%")
+	}
+	return " AND NOT (" + strings.Join(conds, " OR ") + ")", args, nil
+}
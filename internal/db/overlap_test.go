@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFolderOverlaps(t *testing.T) {
+	folders := []Folder{
+		{ID: 1, Path: "/data"},
+		{ID: 2, Path: "/data/photos"},
+		{ID: 3, Path: "/database"},
+		{ID: 4, Path: "/data/photos/2024"},
+		{ID: 5, Path: "import:laptop", Imported: true},
+	}
+	got := FolderOverlaps(folders)
+	want := []FolderOverlap{
+		{OuterID: 1, OuterPath: "/data", InnerID: 2, InnerPath: "/data/photos"},
+		{OuterID: 1, OuterPath: "/data", InnerID: 4, InnerPath: "/data/photos/2024"},
+		{OuterID: 2, OuterPath: "/data/photos", InnerID: 4, InnerPath: "/data/photos/2024"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FolderOverlaps = %+v, want %+v", got, want)
+	}
+	if rel := want[1].rel(); rel != "photos/2024" {
+		t.Errorf("rel = %q, want photos/2024", rel)
+	}
+	if rel := (FolderOverlap{OuterPath: "/", InnerPath: "/data"}).rel(); rel != "data" {
+		t.Errorf("rel under / = %q, want data", rel)
+	}
+}
+
+func TestOverlappingScansCountSharedFilesOnce(t *testing.T) {
+	ctx := context.Background()
+	database := TestPostgresDB(t)
+	outerID, _ := AddFolder(ctx, database, "/data")
+	innerID, _ := AddFolder(ctx, database, "/data/photos")
+	outer, _ := CreateScan(ctx, database, outerID)
+	inner, _ := CreateScan(ctx, database, innerID)
+	dev := int64(1)
+	now := time.Now().UTC()
+	add := func(folderID, scanID int64, path string, inode int64) {
+		id, err := UpsertFile(ctx, database, folderID, path, 100, 0, inode, &dev)
+		if err != nil {
+			t.Fatalf("UpsertFile %s: %v", path, err)
+		}
+		_ = InsertFileScan(ctx, database, id, scanID)
+		_ = UpdateFileHash(ctx, database, id, "h", now)
+	}
+	// photos/a.jpg is one file listed by both scans; backup/a.jpg is a real second copy.
+	add(outerID, outer.ID, "photos/a.jpg", 1)
+	add(innerID, inner.ID, "a.jpg", 1)
+	scanIDs := []int64{outer.ID, inner.ID}
+
+	if n, err := DuplicateGroupsByHashCountAcrossScans(ctx, database, scanIDs, DuplicateGroupFilter{}); err != nil || n != 0 {
+		t.Errorf("one file seen by two overlapping scans: groups = %d, %v; want 0", n, err)
+	}
+
+	add(outerID, outer.ID, "backup/a.jpg", 2)
+	groups, err := DuplicateGroupsByHashPaginatedAcrossScans(ctx, database, scanIDs, DuplicateGroupFilter{}, 10, 0)
+	if err != nil || len(groups) != 1 || groups[0].Count != 2 || groups[0].Size != 200 {
+		t.Fatalf("groups = %+v, %v; want one group of 2 files and 200 bytes", groups, err)
+	}
+	files, err := FilesInHashGroupPage(ctx, database, scanIDs, "h", "", 10, 0)
+	if err != nil || len(files) != 2 {
+		t.Errorf("FilesInHashGroupPage = %d files, %v; want 2", len(files), err)
+	}
+	p, err := ProjectSavings(ctx, database, scanIDs)
+	if err != nil {
+		t.Fatalf("ProjectSavings: %v", err)
+	}
+	if p.Groups != 1 || p.DeleteSavings != 100 {
+		t.Errorf("savings = %+v, want 1 group and 100 bytes to delete", p)
+	}
+}
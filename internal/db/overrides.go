@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// ScanOverrides are per-run settings that take precedence over the folder's for one scan. Zero values keep
+// the folder setting; ExcludePatterns are added to the folder's patterns. IncludePatterns limit this run to
+// the matching paths (see scan.Includes).
+type ScanOverrides struct {
+	ExcludePatterns    []string `json:"exclude_patterns,omitempty"`
+	IncludePatterns    []string `json:"include_patterns,omitempty"`
+	Workers            int      `json:"workers,omitempty"`
+	MaxReadBytesPerSec int64    `json:"max_read_bytes_per_sec,omitempty"`
+}
+
+// SetScanOverrides stores the per-run settings of a scan. They are kept on the scan, so a paused and
+// resumed or requeued scan continues with them.
+func SetScanOverrides(ctx context.Context, database *sql.DB, scanID int64, o *ScanOverrides) error {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	_, err = database.ExecContext(ctx, `UPDATE scans SET overrides = $1 WHERE id = $2`, string(b), scanID)
+	return err
+}
+
+// GetScanOverrides returns the per-run settings of a scan, or nil when it was started without any.
+func GetScanOverrides(ctx context.Context, database *sql.DB, scanID int64) (*ScanOverrides, error) {
+	var raw sql.NullString
+	if err := database.QueryRowContext(ctx, `SELECT overrides FROM scans WHERE id = $1`, scanID).Scan(&raw); err != nil {
+		return nil, err
+	}
+	if !raw.Valid {
+		return nil, nil
+	}
+	var o ScanOverrides
+	if err := json.Unmarshal([]byte(raw.String), &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
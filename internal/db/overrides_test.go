@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestScanOverrides_storedOnTheScan(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	scan, _ := CreateScan(ctx, database, folderID)
+	if o, err := GetScanOverrides(ctx, database, scan.ID); err != nil || o != nil {
+		t.Fatalf("GetScanOverrides(new scan) = %+v, %v; want nil", o, err)
+	}
+	want := &ScanOverrides{ExcludePatterns: []string{"*.tmp"}, Workers: 2, MaxReadBytesPerSec: 1 << 20}
+	if err := SetScanOverrides(ctx, database, scan.ID, want); err != nil {
+		t.Fatalf("SetScanOverrides: %v", err)
+	}
+	got, err := GetScanOverrides(ctx, database, scan.ID)
+	if err != nil || got == nil || got.Workers != 2 || got.MaxReadBytesPerSec != 1<<20 || !slices.Equal(got.ExcludePatterns, want.ExcludePatterns) {
+		t.Fatalf("GetScanOverrides = %+v, %v; want %+v", got, err, want)
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// pathPrefixCondition returns a condition on files (alias f) that holds for files of the given scans under
+// prefix, a full directory path such as /photos/2020. The prefix is resolved against each scan's folder: a
+// folder at or under the prefix matches as a whole (f.folder_id = $n), a folder containing it matches the
+// relative subtree (f.folder_id = $n AND f.path LIKE 'rel/%'), so the condition uses the (folder_id, path)
+// indexes. Placeholders are numbered from next; args are the values to append. A prefix that matches no
+// folder gives FALSE.
+func pathPrefixCondition(ctx context.Context, database *sql.DB, scanIDs []int64, prefix string, next int) (string, []interface{}, error) {
+	prefix = strings.TrimRight(prefix, "/")
+	if len(scanIDs) == 0 {
+		return "FALSE", nil, nil
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT DISTINCT fo.id, fo.path FROM scans s JOIN folders fo ON s.folder_id = fo.id
+		 WHERE s.id IN (`+placeholders(len(scanIDs), 1)+`) ORDER BY fo.id`, idSlice(scanIDs)...)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+	var conds []string
+	var args []interface{}
+	for rows.Next() {
+		var id int64
+		var root string
+		if err := rows.Scan(&id, &root); err != nil {
+			return "", nil, err
+		}
+		root = strings.TrimRight(root, "/")
+		switch {
+		case prefix == "" || root == prefix || strings.HasPrefix(root, prefix+"/"):
+			conds = append(conds, fmt.Sprintf("f.folder_id = $%d", next+len(args)))
+			args = append(args, id)
+		case strings.HasPrefix(prefix, root+"/"):
+			rel := strings.TrimPrefix(prefix, root+"/")
+			conds = append(conds, fmt.Sprintf("(f.folder_id = $%d AND f.path LIKE $%d)", next+len(args), next+len(args)+1))
+			args = append(args, id, likeEscape(rel)+"/%")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	if len(conds) == 0 {
+		return "FALSE", nil, nil
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args, nil
+}
+
+// FilesInHashGroupUnderPrefix returns up to limit files (0: all) with the given hash in any of the given scans
+// whose full path is under prefix, ordered like FilesInHashGroupLimitAcrossScans.
+func FilesInHashGroupUnderPrefix(ctx context.Context, database *sql.DB, scanIDs []int64, hash, prefix string, limit int) ([]File, error) {
+	if len(scanIDs) == 0 {
+		return nil, nil
+	}
+	args := idSlice(scanIDs)
+	args = append(args, hash)
+	cond, cargs, err := pathPrefixCondition(ctx, database, scanIDs, prefix, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, cargs...)
+	overlap, oargs, err := overlapCondition(ctx, database, scanIDs, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, oargs...)
+	q := `SELECT f.id, fs.scan_id, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		  FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		  WHERE fs.scan_id IN (` + placeholders(len(scanIDs), 1) + `) AND f.hash_status = 'done'
+		    AND f.hash = $` + fmt.Sprint(len(scanIDs)+1) + ` AND ` + cond + overlap + `
+		  ORDER BY fs.scan_id, f.path` // #nosec G202 -- placeholders and fixed conditions; args passed separately
+	if limit > 0 {
+		q += fmt.Sprintf(" LIMIT $%d", len(args)+1) // #nosec G202 -- placeholder index only
+		args = append(args, limit)
+	}
+	rows, err := database.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
+
+// likeEscape escapes LIKE wildcards in s (with the default backslash escape).
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
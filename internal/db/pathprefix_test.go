@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPathPrefix_groupsAndMembersUnderSubtree(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	dataID, _ := AddFolder(ctx, database, "/data")
+	mediaID, _ := AddFolder(ctx, database, "/mnt/media")
+	snData, _ := CreateScan(ctx, database, dataID)
+	snMedia, _ := CreateScan(ctx, database, mediaID)
+	inode := int64(0)
+	add := func(folderID, scanID int64, path, hash string) {
+		inode++
+		id, err := UpsertFile(ctx, database, folderID, path, 100, 1, inode, nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, scanID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+	}
+	add(dataID, snData.ID, "photos/2020/a.jpg", "inside")
+	add(dataID, snData.ID, "photos/2020/copy/a.jpg", "inside")
+	add(dataID, snData.ID, "photos/2020/b.jpg", "straddle")
+	add(dataID, snData.ID, "old/b.jpg", "straddle")
+	add(dataID, snData.ID, "photos/2020_x/c.jpg", "outside") // LIKE wildcard must not match "2020_x"
+	add(dataID, snData.ID, "photos/2020x/c.jpg", "outside")
+	add(mediaID, snMedia.ID, "c.jpg", "media")
+	add(mediaID, snMedia.ID, "d.jpg", "media")
+	scans := []int64{snData.ID, snMedia.ID}
+
+	count := func(f DuplicateGroupFilter) int64 {
+		t.Helper()
+		n, err := DuplicateGroupsByHashCountAcrossScans(ctx, database, scans, f)
+		if err != nil {
+			t.Fatalf("DuplicateGroupsByHashCountAcrossScans(%+v): %v", f, err)
+		}
+		return n
+	}
+	for _, tc := range []struct {
+		filter DuplicateGroupFilter
+		want   int64
+	}{
+		{DuplicateGroupFilter{PathPrefix: "/data/photos/2020"}, 2},                   // inside, straddle
+		{DuplicateGroupFilter{PathPrefix: "/data/photos/2020", PrefixOnly: true}, 1}, // only inside has two copies there
+		{DuplicateGroupFilter{PathPrefix: "/mnt"}, 1},                                // a prefix above a root covers it
+		{DuplicateGroupFilter{PathPrefix: "/elsewhere"}, 0},
+	} {
+		if got := count(tc.filter); got != tc.want {
+			t.Errorf("count(%+v) = %d, want %d", tc.filter, got, tc.want)
+		}
+	}
+
+	files, err := FilesInHashGroupUnderPrefix(ctx, database, scans, "straddle", "/data/photos/2020/", 0)
+	if err != nil {
+		t.Fatalf("FilesInHashGroupUnderPrefix: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "/data/photos/2020/b.jpg" {
+		t.Errorf("FilesInHashGroupUnderPrefix = %+v, want only /data/photos/2020/b.jpg", files)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// ListPendingPHashFiles returns the scan's files whose extension is in exts (lowercase, with leading dot) and
+// that have no perceptual hash status yet. Path is the full path (folder path || '/' || file path).
+func ListPendingPHashFiles(ctx context.Context, database *sql.DB, scanID int64, exts []string) ([]File, error) {
+	lower := make([]string, len(exts))
+	for i, e := range exts {
+		lower[i] = strings.ToLower(e)
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, $1::bigint, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id = $1 AND f.phash_status IS NULL
+		   AND lower(substring(f.path from '\.[^./]*$')) = ANY($2)
+		 ORDER BY f.id`,
+		scanID, lower)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFiles(rows)
+}
+
+// UpdateFilePHash stores a file's perceptual hash. A nil phash records that the file could not be decoded,
+// so it is skipped until its content changes (see upsertFileOnConflict).
+func UpdateFilePHash(ctx context.Context, database *sql.DB, fileID int64, phash *int64) error {
+	status := "done"
+	var val interface{}
+	if phash != nil {
+		val = *phash
+	} else {
+		status = "error"
+	}
+	_, err := database.ExecContext(ctx,
+		"UPDATE files SET phash = $1, phash_status = $2 WHERE id = $3",
+		val, status, fileID)
+	return err
+}
+
+// PHashFile is an image in a scan with its perceptual hash.
+type PHashFile struct {
+	FileID int64
+	Path   string // full path
+	Size   int64
+	MTime  int64
+	Hash   string // content hash; empty if not hashed (unique size)
+	PHash  uint64
+}
+
+// ListScanPHashes returns every file in the scan that has a perceptual hash, ordered by path.
+func ListScanPHashes(ctx context.Context, database *sql.DB, scanID int64) ([]PHashFile, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, (fo.path || '/' || f.path), f.size, f.mtime, COALESCE(f.hash, ''), f.phash
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id = $1 AND f.phash IS NOT NULL
+		 ORDER BY f.path`,
+		scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PHashFile
+	for rows.Next() {
+		var p PHashFile
+		var ph int64
+		if err := rows.Scan(&p.FileID, &p.Path, &p.Size, &p.MTime, &p.Hash, &ph); err != nil {
+			return nil, err
+		}
+		p.PHash = uint64(ph)
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
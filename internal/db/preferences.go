@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+)
+
+// PageSizes are the home page sizes a user can choose.
+var PageSizes = []int{10, 20, 50, 100}
+
+// ErrInvalidPreferences is returned by SavePreferences for a page size, sort or size filter it does not know.
+var ErrInvalidPreferences = errors.New("page size must be one of 10, 20, 50 or 100, with a known sort and a minimum size of 0 or more")
+
+// Preferences are the saved home page defaults: what "/" shows without query parameters.
+type Preferences struct {
+	PageSize   int
+	RootPath   *string // default folder: nil = the newest scanned, "" = All (latest per folder)
+	Sort       GroupSort
+	MinMB      int64
+	PathPrefix string
+	PrefixOnly bool
+}
+
+// PreferencesOwner says whose preferences: a signed-in user's, or (when ditto has no users) a browser's.
+type PreferencesOwner struct {
+	UserID    *int64
+	BrowserID string
+}
+
+func (o PreferencesOwner) where() (string, any) {
+	if o.UserID != nil {
+		return "user_id = $1", *o.UserID
+	}
+	return "browser_id = $1", o.BrowserID
+}
+
+// GetPreferences returns the owner's saved preferences, or nil when there are none.
+func GetPreferences(ctx context.Context, database *sql.DB, owner PreferencesOwner) (*Preferences, error) {
+	cond, arg := owner.where()
+	var p Preferences
+	var root sql.NullString
+	err := database.QueryRowContext(ctx,
+		`SELECT page_size, root_path, sort, min_mb, prefix, prefix_only FROM preferences WHERE `+cond, arg). // #nosec G202 -- fixed condition
+		Scan(&p.PageSize, &root, &p.Sort, &p.MinMB, &p.PathPrefix, &p.PrefixOnly)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if root.Valid {
+		p.RootPath = &root.String
+	}
+	return &p, nil
+}
+
+// SavePreferences stores the owner's preferences, replacing any saved before.
+func SavePreferences(ctx context.Context, database *sql.DB, owner PreferencesOwner, p Preferences) error {
+	if !slices.Contains(PageSizes, p.PageSize) || !p.Sort.Valid() || p.MinMB < 0 {
+		return ErrInvalidPreferences
+	}
+	var browserID *string
+	conflict := "(user_id)"
+	if owner.UserID == nil {
+		browserID = &owner.BrowserID
+		conflict = "(browser_id)"
+	}
+	_, err := database.ExecContext(ctx,
+		`INSERT INTO preferences (user_id, browser_id, page_size, root_path, sort, min_mb, prefix, prefix_only, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT `+conflict+` DO UPDATE SET page_size = EXCLUDED.page_size, root_path = EXCLUDED.root_path,
+		   sort = EXCLUDED.sort, min_mb = EXCLUDED.min_mb, prefix = EXCLUDED.prefix, prefix_only = EXCLUDED.prefix_only,
+		   updated_at = EXCLUDED.updated_at`, // #nosec G202 -- fixed conflict target
+		owner.UserID, browserID, p.PageSize, p.RootPath, string(p.Sort), p.MinMB, p.PathPrefix, p.PrefixOnly, NowUTC())
+	return err
+}
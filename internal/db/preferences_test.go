@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPreferences_savedPerUserAndPerBrowser(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	alice, _ := GetOrCreateUser(ctx, database, "alice", RoleViewer)
+	aliceOwner := PreferencesOwner{UserID: &alice.ID}
+	browser := PreferencesOwner{BrowserID: "b1"}
+	if p, err := GetPreferences(ctx, database, aliceOwner); err != nil || p != nil {
+		t.Fatalf("GetPreferences before saving = %+v, %v; want nil", p, err)
+	}
+
+	all := ""
+	if err := SavePreferences(ctx, database, aliceOwner, Preferences{PageSize: 50, RootPath: &all, Sort: SortGroupWasted, MinMB: 10}); err != nil {
+		t.Fatalf("SavePreferences: %v", err)
+	}
+	if err := SavePreferences(ctx, database, browser, Preferences{PageSize: 20, PathPrefix: "/photos", PrefixOnly: true}); err != nil {
+		t.Fatalf("SavePreferences(browser): %v", err)
+	}
+	// Saving again replaces.
+	if err := SavePreferences(ctx, database, aliceOwner, Preferences{PageSize: 100, RootPath: &all, Sort: SortGroupCount}); err != nil {
+		t.Fatalf("SavePreferences again: %v", err)
+	}
+
+	p, err := GetPreferences(ctx, database, aliceOwner)
+	if err != nil || p == nil || p.PageSize != 100 || p.RootPath == nil || *p.RootPath != "" || p.Sort != SortGroupCount || p.MinMB != 0 {
+		t.Errorf("alice's preferences = %+v, %v; want 100 per page, All, by count", p, err)
+	}
+	p, err = GetPreferences(ctx, database, browser)
+	if err != nil || p == nil || p.PageSize != 20 || p.RootPath != nil || p.PathPrefix != "/photos" || !p.PrefixOnly {
+		t.Errorf("browser preferences = %+v, %v; want 20 per page under /photos", p, err)
+	}
+
+	if err := SavePreferences(ctx, database, browser, Preferences{PageSize: 7}); !errors.Is(err, ErrInvalidPreferences) {
+		t.Errorf("SavePreferences(page size 7) err = %v, want ErrInvalidPreferences", err)
+	}
+	if err := SavePreferences(ctx, database, browser, Preferences{PageSize: 20, Sort: "name"}); !errors.Is(err, ErrInvalidPreferences) {
+		t.Errorf("SavePreferences(unknown sort) err = %v, want ErrInvalidPreferences", err)
+	}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrScanIsLocked is returned by DeleteScan for a locked scan: its ledger snapshot is immutable (see LockScan).
+var ErrScanIsLocked = errors.New("scan is locked")
+
+// DeleteScan removes a scan with its ledger rows (file_scan) and manifest record. Locked scans return
+// ErrScanIsLocked and scans whose walk is running return ErrFolderLocked; a missing scan returns sql.ErrNoRows.
+// Files no longer in any scan are left for DeleteOrphanFiles.
+func DeleteScan(ctx context.Context, database *sql.DB, scanID int64) error {
+	var locked, walking bool
+	err := database.QueryRowContext(ctx,
+		`SELECT s.locked_at IS NOT NULL, EXISTS (SELECT 1 FROM folder_locks l WHERE l.scan_id = s.id)
+		 FROM scans s WHERE s.id = $1`, scanID).Scan(&locked, &walking)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrScanIsLocked
+	}
+	if walking {
+		return ErrFolderLocked
+	}
+	res, err := database.ExecContext(ctx,
+		`DELETE FROM scans s WHERE s.id = $1 AND s.locked_at IS NULL
+		 AND NOT EXISTS (SELECT 1 FROM folder_locks l WHERE l.scan_id = s.id)`, scanID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows // locked or started walking in between, or already deleted
+	}
+	return nil
+}
+
+// ScansBeyondRetention returns the scans that a "keep the newest keep scans per folder" policy would delete,
+// oldest first. Locked scans and scans whose walk is running are never selected (locked scans do not count
+// towards keep either, so they are kept in addition). keep <= 0 means keep everything.
+func ScansBeyondRetention(ctx context.Context, database *sql.DB, keep int) ([]int64, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT id FROM (
+			SELECT s.id, s.started_at, ROW_NUMBER() OVER (PARTITION BY s.folder_id ORDER BY s.started_at DESC, s.id DESC) AS rn
+			FROM scans s
+			WHERE s.locked_at IS NULL AND NOT EXISTS (SELECT 1 FROM folder_locks l WHERE l.scan_id = s.id)
+		 ) ranked
+		 WHERE rn > $1
+		 ORDER BY started_at, id`, keep)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteOrphanFiles removes files that no scan references any more (their media_info goes with them).
+// Folders with a walk in progress are skipped: the walk upserts files before linking them to its scan.
+func DeleteOrphanFiles(ctx context.Context, database *sql.DB) (int64, error) {
+	res, err := database.ExecContext(ctx,
+		`DELETE FROM files f
+		 WHERE NOT EXISTS (SELECT 1 FROM file_scan fs WHERE fs.file_id = f.id)
+		 AND NOT EXISTS (SELECT 1 FROM folder_locks l WHERE l.folder_id = f.folder_id)`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PruneScans applies the retention policy: it deletes scans beyond the newest keep per folder (see
+// ScansBeyondRetention), then files left without any scan. Returns how many scans and files were removed.
+// skip, if non-nil, reports scans that must stay (e.g. queued or hashing); they are left for a later run.
+func PruneScans(ctx context.Context, database *sql.DB, keep int, skip func(scanID int64) bool) (scans, files int64, err error) {
+	ids, err := ScansBeyondRetention(ctx, database, keep)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, id := range ids {
+		if skip != nil && skip(id) {
+			continue
+		}
+		if err := DeleteScan(ctx, database, id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrScanIsLocked) || errors.Is(err, ErrFolderLocked) {
+				continue
+			}
+			return scans, 0, err
+		}
+		scans++
+	}
+	files, err = DeleteOrphanFiles(ctx, database)
+	return scans, files, err
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPruneScans_keepsNewestPerFolderAndLockedScans(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	var scans []int64
+	for i := 0; i < 4; i++ {
+		sn, err := CreateScan(ctx, database, folderID)
+		if err != nil {
+			t.Fatalf("CreateScan: %v", err)
+		}
+		// Distinct start times so "newest" is well defined.
+		if _, err := database.ExecContext(ctx, `UPDATE scans SET started_at = $1 WHERE id = $2`,
+			NowUTC().Add(time.Duration(i-10)*time.Hour), sn.ID); err != nil {
+			t.Fatalf("set started_at: %v", err)
+		}
+		scans = append(scans, sn.ID)
+	}
+	// gone.txt is only in the oldest scan; kept.txt is in every scan.
+	gone, _ := UpsertFile(ctx, database, folderID, "gone.txt", 1, 1, 1, nil)
+	_ = InsertFileScan(ctx, database, gone, scans[0])
+	kept, _ := UpsertFile(ctx, database, folderID, "kept.txt", 1, 1, 2, nil)
+	for _, id := range scans {
+		_ = InsertFileScan(ctx, database, kept, id)
+	}
+	// Lock the second-oldest scan: it survives retention.
+	if _, err := database.ExecContext(ctx, `UPDATE scans SET locked_at = $1 WHERE id = $2`, NowUTC(), scans[1]); err != nil {
+		t.Fatalf("lock scan: %v", err)
+	}
+	if err := DeleteScan(ctx, database, scans[1]); !errors.Is(err, ErrScanIsLocked) {
+		t.Errorf("DeleteScan(locked) = %v, want ErrScanIsLocked", err)
+	}
+
+	ids, err := ScansBeyondRetention(ctx, database, 2)
+	if err != nil {
+		t.Fatalf("ScansBeyondRetention: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != scans[0] {
+		t.Fatalf("ScansBeyondRetention(2) = %v, want [%d]", ids, scans[0])
+	}
+	nScans, nFiles, err := PruneScans(ctx, database, 2, nil)
+	if err != nil {
+		t.Fatalf("PruneScans: %v", err)
+	}
+	if nScans != 1 || nFiles != 1 {
+		t.Errorf("PruneScans = %d scans, %d files; want 1, 1", nScans, nFiles)
+	}
+	if _, err := GetScan(ctx, database, scans[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetScan(pruned) err = %v, want sql.ErrNoRows", err)
+	}
+	var n int
+	_ = database.QueryRowContext(ctx, `SELECT COUNT(*) FROM files WHERE id = $1`, kept).Scan(&n)
+	if n != 1 {
+		t.Error("file still in later scans was deleted")
+	}
+	if err := DeleteScan(ctx, database, scans[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteScan(missing) = %v, want sql.ErrNoRows", err)
+	}
+}
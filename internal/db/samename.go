@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"strconv"
+)
+
+// NameGroup is a file name shared by files of a scan whose content differs, such as a document edited in
+// two places.
+type NameGroup struct {
+	Name     string
+	Count    int64      // files with this name
+	Versions int64      // distinct contents among them
+	Unhashed int64      // files whose content is not known yet (not hashed); not counted in Versions
+	Files    []NameFile // the files, grouped by version
+}
+
+// NameFile is one file of a NameGroup. Version numbers the group's distinct contents from 1; it is 0 for a
+// file not hashed yet.
+type NameFile struct {
+	File
+	Version int
+}
+
+// sameNameFiles selects the scan's files ($1) with their base name and a content key, optionally of one
+// extension ($2, see NormalizeExtension). The key is the hash, or the size for a hardlink-only size group
+// (one inode, so one content); it is NULL for a file not hashed yet (pending, failed), whose content is unknown:
+// guessing from the size would split identical files or merge different ones.
+const sameNameFiles = `SELECT f.id, regexp_replace(f.path, '^.*/', '') AS name,
+	   CASE f.hash_status WHEN 'done' THEN f.hash WHEN 'hardlink' THEN 'size:' || f.size::text END AS content
+	 FROM files f JOIN file_scan fs ON f.id = fs.file_id
+	 WHERE fs.scan_id = $1 AND f.hash_status <> 'symlink'
+	   AND ($2 = '' OR lower(substring(f.path from '\.[^./]*$')) = $2)`
+
+// SameNameGroupsCount returns how many names SameNameGroups lists for the scan.
+func SameNameGroupsCount(ctx context.Context, database *sql.DB, scanID int64, ext string) (int64, error) {
+	var n int64
+	err := database.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM (SELECT name FROM (`+sameNameFiles+`) n GROUP BY name HAVING COUNT(DISTINCT content) > 1) g`,
+		scanID, NormalizeExtension(ext)).Scan(&n)
+	return n, err
+}
+
+// SameNameGroups returns the file names that files of the scan share with different content, most versions
+// first, each with its files. ext filters by extension ("" for all).
+func SameNameGroups(ctx context.Context, database *sql.DB, scanID int64, ext string, limit, offset int) ([]NameGroup, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT name, COUNT(*), COUNT(DISTINCT content), COUNT(*) - COUNT(content) FROM (`+sameNameFiles+`) n
+		 GROUP BY name HAVING COUNT(DISTINCT content) > 1
+		 ORDER BY COUNT(DISTINCT content) DESC, COUNT(*) DESC, name
+		 LIMIT $3 OFFSET $4`,
+		scanID, NormalizeExtension(ext), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	var groups []NameGroup
+	for rows.Next() {
+		var g NameGroup
+		if err := rows.Scan(&g.Name, &g.Count, &g.Versions, &g.Unhashed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(groups) == 0 {
+		return groups, err
+	}
+
+	args := []interface{}{scanID, NormalizeExtension(ext)}
+	for _, g := range groups {
+		args = append(args, g.Name)
+	}
+	// #nosec G202 -- placeholders built from len(groups); names passed as args
+	rows, err = database.QueryContext(ctx,
+		`SELECT f.id, $1::bigint, (fo.path || '/' || f.path), f.size, f.mtime, f.inode, f.device_id, f.hash, f.hash_status, f.hashed_at
+		 FROM (`+sameNameFiles+`) n JOIN files f ON f.id = n.id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE n.name IN (`+placeholders(len(groups), 3)+`)
+		 ORDER BY n.name, n.content NULLS LAST, f.mtime DESC, f.path`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files, err := scanFiles(rows)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*NameGroup, len(groups))
+	for i := range groups {
+		byName[groups[i].Name] = &groups[i]
+	}
+	for _, f := range files {
+		g := byName[path.Base(f.Path)]
+		if g == nil {
+			continue
+		}
+		version := 0
+		if key := contentKey(f); key != "" {
+			version = 1
+			if n := len(g.Files); n > 0 {
+				prev := g.Files[n-1]
+				version = prev.Version
+				if contentKey(prev.File) != key {
+					version++
+				}
+			}
+		}
+		g.Files = append(g.Files, NameFile{File: f, Version: version})
+	}
+	return groups, nil
+}
+
+// contentKey matches the content column of sameNameFiles, with "" for NULL (content unknown).
+func contentKey(f File) string {
+	switch {
+	case f.HashStatus == "done" && f.Hash != nil:
+		return *f.Hash
+	case f.HashStatus == "hardlink":
+		return "size:" + strconv.FormatInt(f.Size, 10)
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSameNameGroups_listsNamesWithDifferentContent(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/docs")
+	sn, _ := CreateScan(ctx, database, folderID)
+	for i, p := range []struct {
+		path string
+		size int64
+		hash string
+	}{
+		{"a/report_v1.docx", 100, "h1"},
+		{"b/report_v1.docx", 100, "h2"}, // same size, edited
+		{"c/report_v1.docx", 120, ""},   // not hashed yet: content unknown
+		{"d/report_v1.docx", 100, ""},   // not hashed yet, same size as a and b
+		{"x/notes.txt", 10, "h3"},
+		{"y/notes.txt", 10, "h3"}, // a plain duplicate
+	} {
+		id, err := UpsertFile(ctx, database, folderID, p.path, p.size, 1, int64(i+1), nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		if p.hash != "" {
+			_ = UpdateFileHash(ctx, database, id, p.hash, time.Now())
+		}
+	}
+
+	if n, err := SameNameGroupsCount(ctx, database, sn.ID, ""); err != nil || n != 1 {
+		t.Errorf("SameNameGroupsCount = %d, %v; want 1", n, err)
+	}
+	groups, err := SameNameGroups(ctx, database, sn.ID, "", 10, 0)
+	if err != nil {
+		t.Fatalf("SameNameGroups: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Name != "report_v1.docx" || groups[0].Count != 4 || groups[0].Versions != 2 || groups[0].Unhashed != 2 {
+		t.Fatalf("SameNameGroups = %+v, want report_v1.docx with 4 files: 2 versions and 2 not hashed", groups)
+	}
+	if files := groups[0].Files; len(files) != 4 || files[0].Version != 1 || files[1].Version != 2 || files[2].Version != 0 || files[3].Version != 0 {
+		t.Errorf("files = %+v, want versions 1 and 2, then the unhashed files as 0", files)
+	}
+	if n, _ := SameNameGroupsCount(ctx, database, sn.ID, "txt"); n != 0 {
+		t.Errorf("SameNameGroupsCount(txt) = %d, want 0", n)
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SavingsProjection estimates the space freed by resolving the duplicate groups of a set of scans under
+// each strategy. Files already hardlinked to each other (same inode and device) are counted once, and each
+// copy counts only the disk space it takes on its own (FileExtents.OnDisk): the holes of a sparse file and
+// extents already shared with other files (reflinks, block deduplication) would not be freed.
+type SavingsProjection struct {
+	Groups            int64 // duplicate-by-hash groups
+	CrossDeviceGroups int64 // groups with copies on more than one device
+	PhysicalBytes     int64 // bytes currently used by the duplicated content (one per distinct inode, shared extents once)
+	DeleteSavings     int64 // delete all but one copy per group
+	LinkSavings       int64 // hardlink (or reflink) copies that share a device; one copy per device remains
+	CrossDeviceBytes  int64 // bytes that only deletion can free: links cannot span devices
+}
+
+// ProjectSavings computes the SavingsProjection for the duplicate groups across the given scans.
+// Files with an unknown device are treated as being on one device. Acknowledged groups are left out, and files
+// listed by two overlapping scans count once (see overlapCondition).
+// The copy left in each group (or on each device, for links) is the one taking the most space of its own.
+func ProjectSavings(ctx context.Context, database *sql.DB, scanIDs []int64) (*SavingsProjection, error) {
+	p := &SavingsProjection{}
+	if len(scanIDs) == 0 {
+		return p, nil
+	}
+	ph := placeholders(len(scanIDs), 1)
+	args := idSlice(scanIDs)
+	overlap, oargs, err := overlapCondition(ctx, database, scanIDs, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, oargs...)
+	q := `WITH d AS (
+			SELECT DISTINCT f.id, f.hash, f.size, COALESCE(f.device_id, -1) AS dev, f.inode,
+				LEAST(COALESCE(f.allocated, f.size), f.size) AS alloc, ` + fileOnDisk + ` AS own
+			FROM files f JOIN file_scan fs ON f.id = fs.file_id
+			WHERE fs.scan_id IN (` + ph + `) AND f.hash_status = 'done' AND ` + notAcknowledged + overlap + `
+		), g AS (
+			SELECT hash FROM d GROUP BY hash HAVING COUNT(*) > 1
+		), per_inode AS (
+			SELECT d.hash, d.dev, d.inode, MAX(d.alloc) AS alloc, MAX(d.own) AS own
+			FROM d JOIN g ON d.hash = g.hash GROUP BY d.hash, d.dev, d.inode
+		), per_dev AS (
+			SELECT hash, dev, SUM(own) AS own, MAX(own) AS kept, MAX(alloc - own) AS shared FROM per_inode GROUP BY hash, dev
+		), per_group AS (
+			SELECT hash, SUM(own) AS own, MAX(kept) AS kept, SUM(own - kept) AS linked, MAX(shared) AS shared, COUNT(*) AS devices
+			FROM per_dev GROUP BY hash
+		)
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE devices > 1),
+			COALESCE(SUM(own + shared), 0), COALESCE(SUM(own - kept), 0), COALESCE(SUM(linked), 0)
+		FROM per_group` // #nosec G202 -- ph is placeholder count, overlap a fixed condition; args passed separately
+	err = database.QueryRowContext(ctx, q, args...).Scan(
+		&p.Groups, &p.CrossDeviceGroups, &p.PhysicalBytes, &p.DeleteSavings, &p.LinkSavings)
+	if err != nil {
+		return nil, err
+	}
+	p.CrossDeviceBytes = p.DeleteSavings - p.LinkSavings
+	return p, nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProjectSavings_hardlinksAndCrossDevice(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	dev1, dev2 := int64(1), int64(2)
+	add := func(path string, inode int64, dev *int64) {
+		id, err := UpsertFile(ctx, database, folderID, path, 100, 1, inode, dev)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, "h", time.Now().UTC())
+	}
+	add("a", 10, &dev1)
+	add("a-link", 10, &dev1) // hardlink of a: no extra space
+	add("b", 11, &dev1)
+	add("c", 20, &dev2)
+
+	p, err := ProjectSavings(ctx, database, []int64{sn.ID})
+	if err != nil {
+		t.Fatalf("ProjectSavings: %v", err)
+	}
+	want := SavingsProjection{Groups: 1, CrossDeviceGroups: 1, PhysicalBytes: 300, DeleteSavings: 200, LinkSavings: 100, CrossDeviceBytes: 100}
+	if *p != want {
+		t.Errorf("ProjectSavings = %+v, want %+v", *p, want)
+	}
+}
+
+func TestProjectSavings_sparseAndSharedExtents(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	dev := int64(1)
+	var ids []int64
+	add := func(path string, inode, allocated, shared int64) {
+		got, err := UpsertFilesBatch(ctx, database, folderID, []FileRow{{Path: path, Size: 100, MTime: 1, Inode: inode, DeviceID: &dev, Allocated: &allocated}})
+		if err != nil {
+			t.Fatalf("UpsertFilesBatch: %v", err)
+		}
+		ids = append(ids, got[0])
+		_ = InsertFileScan(ctx, database, got[0], sn.ID)
+		_ = UpdateFileHash(ctx, database, got[0], "h", time.Now().UTC())
+		if shared > 0 {
+			_ = UpdateFileSharedBytes(ctx, database, got[0], shared)
+		}
+	}
+	add("a", 10, 100, 60)    // reflinked with b for 60 bytes
+	add("b", 11, 100, 60)    // 40 bytes of its own
+	add("sparse", 12, 30, 0) // only 30 bytes allocated
+
+	p, err := ProjectSavings(ctx, database, []int64{sn.ID})
+	if err != nil {
+		t.Fatalf("ProjectSavings: %v", err)
+	}
+	// Own bytes 40, 40 and 30: keeping a copy with 40 frees 70; the 60 shared bytes are counted once.
+	want := SavingsProjection{Groups: 1, PhysicalBytes: 170, DeleteSavings: 70, LinkSavings: 70}
+	if *p != want {
+		t.Errorf("ProjectSavings = %+v, want %+v", *p, want)
+	}
+
+	ext, err := ExtentsByFileID(ctx, database, ids)
+	if err != nil {
+		t.Fatalf("ExtentsByFileID: %v", err)
+	}
+	if len(ext) != 3 {
+		t.Errorf("ExtentsByFileID = %+v, want all three files (shared or sparse)", ext)
+	}
+
+	var dups int
+	if err := ForEachDuplicateFile(ctx, database, sn.ID, func(int64, string, *int64) error { dups++; return nil }); err != nil || dups != 3 {
+		t.Errorf("ForEachDuplicateFile: %d files, %v; want 3", dups, err)
+	}
+	_ = UpdateFileSharedBytes(ctx, database, ids[0], 0) // a's clone partner was rewritten
+	if ext, _ := ExtentsByFileID(ctx, database, ids[:1]); len(ext) != 0 {
+		t.Errorf("ExtentsByFileID after sharing stopped = %+v, want none", ext)
+	}
+}
+
+func TestFileExtents_OnDisk(t *testing.T) {
+	small, big := int64(40), int64(200)
+	for _, tc := range []struct {
+		e    FileExtents
+		want int64
+	}{
+		{FileExtents{Size: 100}, 100},
+		{FileExtents{Size: 100, Allocated: &small}, 40},
+		{FileExtents{Size: 100, Allocated: &big}, 100}, // rounded up to whole blocks
+		{FileExtents{Size: 100, Shared: 60}, 40},
+		{FileExtents{Size: 100, Allocated: &small, Shared: 60}, 0},
+	} {
+		if got := tc.e.OnDisk(); got != tc.want {
+			t.Errorf("%+v.OnDisk() = %d, want %d", tc.e, got, tc.want)
+		}
+	}
+}
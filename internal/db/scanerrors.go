@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Phases of a scan error (scan_errors.phase).
+const (
+	PhaseScan   = "scan"   // walking: listing a directory or reading a file's metadata
+	PhaseHash   = "hash"   // reading a file's content
+	PhaseVerify = "verify" // re-reading an unchanged file in a verification scan
+)
+
+// ScanError is an error met while walking or hashing a scan.
+type ScanError struct {
+	ID        int64
+	FileID    *int64 // hash errors: the file that failed; nil for walk errors
+	Path      string
+	Phase     string // PhaseScan, PhaseHash or PhaseVerify
+	Error     string
+	CreatedAt time.Time
+}
+
+// InsertScanErrors records errors of the scan in one transaction.
+func InsertScanErrors(ctx context.Context, database *sql.DB, scanID int64, errs []ScanError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, e := range errs {
+		at := e.CreatedAt
+		if at.IsZero() {
+			at = NowUTC()
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO scan_errors (scan_id, file_id, path, phase, error, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+			scanID, e.FileID, e.Path, e.Phase, e.Error, at); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CountScanErrors returns how many errors the scan recorded, and how many of them are hash errors.
+func CountScanErrors(ctx context.Context, database *sql.DB, scanID int64) (total, hash int64, err error) {
+	err = database.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE phase = $2) FROM scan_errors WHERE scan_id = $1`,
+		scanID, PhaseHash).Scan(&total, &hash)
+	return total, hash, err
+}
+
+// ListScanErrors returns up to limit errors of the scan in the order they happened.
+func ListScanErrors(ctx context.Context, database *sql.DB, scanID int64, limit int) ([]ScanError, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT id, file_id, path, phase, error, created_at FROM scan_errors WHERE scan_id = $1 ORDER BY id LIMIT $2`,
+		scanID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ScanError
+	for rows.Next() {
+		var e ScanError
+		if err := rows.Scan(&e.ID, &e.FileID, &e.Path, &e.Phase, &e.Error, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ClearScanHashErrors deletes the scan's hash errors and returns their files (including those marked 'failed')
+// to the hash queue with a fresh set of attempts, so the next run of the hash phase tries them again. Returns the
+// number of errors cleared.
+func ClearScanHashErrors(ctx context.Context, database *sql.DB, scanID int64) (int64, error) {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE files SET hash_status = 'pending', hash_attempts = 0, hash_error = NULL
+		 WHERE (hash_status = 'hashing'
+		   AND id IN (SELECT file_id FROM scan_errors WHERE scan_id = $1 AND phase = $2 AND file_id IS NOT NULL))
+		 OR (hash_status = 'failed' AND id IN (SELECT file_id FROM file_scan WHERE scan_id = $1))`,
+		scanID, PhaseHash); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM scan_errors WHERE scan_id = $1 AND phase = $2`, scanID, PhaseHash)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
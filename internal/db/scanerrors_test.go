@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScanErrors_recordCountListAndClearHashErrors(t *testing.T) {
+	db := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, db, "/data")
+	scan, _ := CreateScan(ctx, db, folderID)
+	ids, err := UpsertFilesBatch(ctx, db, folderID, []FileRow{{Path: "a.bin", Size: 5, MTime: 1, Inode: 1}})
+	if err != nil {
+		t.Fatalf("UpsertFilesBatch: %v", err)
+	}
+	if err := InsertFileScanBatch(ctx, db, ids, scan.ID); err != nil {
+		t.Fatalf("InsertFileScanBatch: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE files SET hash_status = 'hashing' WHERE id = $1`, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := []ScanError{
+		{Path: "/data/private", Phase: PhaseScan, Error: "permission denied"},
+		{FileID: &ids[0], Path: "/data/a.bin", Phase: PhaseHash, Error: "input/output error"},
+	}
+	if err := InsertScanErrors(ctx, db, scan.ID, errs); err != nil {
+		t.Fatalf("InsertScanErrors: %v", err)
+	}
+	total, hash, err := CountScanErrors(ctx, db, scan.ID)
+	if err != nil || total != 2 || hash != 1 {
+		t.Errorf("CountScanErrors = %d, %d, %v; want 2, 1", total, hash, err)
+	}
+	list, err := ListScanErrors(ctx, db, scan.ID, 10)
+	if err != nil {
+		t.Fatalf("ListScanErrors: %v", err)
+	}
+	if len(list) != 2 || list[0].Path != "/data/private" || list[1].FileID == nil || *list[1].FileID != ids[0] || list[1].CreatedAt.IsZero() {
+		t.Errorf("ListScanErrors = %+v", list)
+	}
+
+	n, err := ClearScanHashErrors(ctx, db, scan.ID)
+	if err != nil || n != 1 {
+		t.Fatalf("ClearScanHashErrors = %d, %v; want 1", n, err)
+	}
+	if total, _, _ := CountScanErrors(ctx, db, scan.ID); total != 1 {
+		t.Errorf("after clear: %d errors, want the walk error only", total)
+	}
+	var status string
+	_ = db.QueryRowContext(ctx, `SELECT hash_status FROM files WHERE id = $1`, ids[0]).Scan(&status)
+	if status != "pending" {
+		t.Errorf("hash_status = %q, want pending after clearing its error", status)
+	}
+}
@@ -8,9 +8,31 @@ import (
 
 // ScanRoot is a path configured as a scan root (folders table). Kept for API compatibility.
 type ScanRoot struct {
-	ID        int64
-	Path      string
-	CreatedAt time.Time
+	ID                 int64
+	Path               string
+	CreatedAt          time.Time
+	MaxReadBytesPerSec int64
+	LowPriority        bool
+	SimilarImages      bool
+	SimilarMedia       bool
+	PhotoMetadata      bool
+	MediaLabel         string
+	MediaImage         string
+	Imported           bool
+	Symlinks           string
+	NetworkFS          string
+	OneFileSystem      bool
+	Archives           bool
+	UnicodeForm        string
+	FSType             string
+	DeviceID           *int64
+	DisabledAt         *time.Time
+	MaxDepth           int
+	MaxFiles           int64
+}
+
+func scanRootFromFolder(f *Folder) ScanRoot {
+	return ScanRoot{ID: f.ID, Path: f.Path, CreatedAt: f.CreatedAt, MaxReadBytesPerSec: f.MaxReadBytesPerSec, LowPriority: f.LowPriority, SimilarImages: f.SimilarImages, SimilarMedia: f.SimilarMedia, PhotoMetadata: f.PhotoMetadata, MediaLabel: f.MediaLabel, MediaImage: f.MediaImage, Imported: f.Imported, Symlinks: f.Symlinks, NetworkFS: f.NetworkFS, OneFileSystem: f.OneFileSystem, Archives: f.Archives, UnicodeForm: f.UnicodeForm, FSType: f.FSType, DeviceID: f.DeviceID, DisabledAt: f.DisabledAt, MaxDepth: f.MaxDepth, MaxFiles: f.MaxFiles}
 }
 
 // ListScanRoots returns all folders (scan roots) ordered by id ascending.
@@ -21,7 +43,7 @@ func ListScanRoots(ctx context.Context, database *sql.DB) ([]ScanRoot, error) {
 	}
 	out := make([]ScanRoot, len(list))
 	for i := range list {
-		out[i] = ScanRoot{ID: list[i].ID, Path: list[i].Path, CreatedAt: list[i].CreatedAt}
+		out[i] = scanRootFromFolder(&list[i])
 	}
 	return out, nil
 }
@@ -37,7 +59,8 @@ func GetScanRoot(ctx context.Context, database *sql.DB, id int64) (*ScanRoot, er
 	if err != nil {
 		return nil, err
 	}
-	return &ScanRoot{ID: f.ID, Path: f.Path, CreatedAt: f.CreatedAt}, nil
+	r := scanRootFromFolder(f)
+	return &r, nil
 }
 
 // DeleteScanRoot removes the folder with the given id. Returns false if no row was deleted.
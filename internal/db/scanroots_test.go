@@ -89,3 +89,61 @@ func TestDeleteScanRoot(t *testing.T) {
 		t.Error("DeleteScanRoot(99999): want false")
 	}
 }
+
+func TestUpdateFolderIOSettings(t *testing.T) {
+	ctx := context.Background()
+	db := TestPostgresDB(t)
+
+	id, _ := AddScanRoot(ctx, db, "/tmp/foo")
+	root, _ := GetScanRoot(ctx, db, id)
+	if root.MaxReadBytesPerSec != 0 || root.LowPriority {
+		t.Errorf("defaults: %+v, want unlimited and normal priority", root)
+	}
+	if err := UpdateFolderIOSettings(ctx, db, id, 10<<20, true); err != nil {
+		t.Fatalf("UpdateFolderIOSettings: %v", err)
+	}
+	root, _ = GetScanRoot(ctx, db, id)
+	if root.MaxReadBytesPerSec != 10<<20 || !root.LowPriority {
+		t.Errorf("after update: %+v", root)
+	}
+}
+
+func TestUpdateFolderWalkLimits(t *testing.T) {
+	ctx := context.Background()
+	db := TestPostgresDB(t)
+
+	id, _ := AddScanRoot(ctx, db, "/")
+	root, _ := GetScanRoot(ctx, db, id)
+	if root.MaxDepth != 0 || root.MaxFiles != 0 {
+		t.Errorf("defaults: %+v, want no limits", root)
+	}
+	if err := UpdateFolderWalkLimits(ctx, db, id, 8, 1_000_000); err != nil {
+		t.Fatalf("UpdateFolderWalkLimits: %v", err)
+	}
+	root, _ = GetScanRoot(ctx, db, id)
+	if root.MaxDepth != 8 || root.MaxFiles != 1_000_000 {
+		t.Errorf("after update: %+v", root)
+	}
+}
+
+func TestUpdateFolderOneFileSystem_andSetFolderFilesystem(t *testing.T) {
+	ctx := context.Background()
+	db := TestPostgresDB(t)
+
+	id, _ := AddScanRoot(ctx, db, "/volume1")
+	root, _ := GetScanRoot(ctx, db, id)
+	if root.OneFileSystem || root.FSType != "" || root.DeviceID != nil {
+		t.Errorf("defaults: %+v, want crossing mounts allowed and no filesystem recorded", root)
+	}
+	if err := UpdateFolderOneFileSystem(ctx, db, id, true); err != nil {
+		t.Fatalf("UpdateFolderOneFileSystem: %v", err)
+	}
+	dev := int64(2049)
+	if err := SetFolderFilesystem(ctx, db, id, "btrfs", &dev); err != nil {
+		t.Fatalf("SetFolderFilesystem: %v", err)
+	}
+	root, _ = GetScanRoot(ctx, db, id)
+	if !root.OneFileSystem || root.FSType != "btrfs" || root.DeviceID == nil || *root.DeviceID != dev {
+		t.Errorf("after update: %+v", root)
+	}
+}
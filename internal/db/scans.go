@@ -8,19 +8,27 @@ import (
 
 // Scan is a single scan run (metadata and stats; file presence is in file_scan ledger).
 type Scan struct {
-	ID                 int64
-	FolderID           int64     // folder that was scanned
-	CreatedAt          time.Time
-	CompletedAt        *time.Time
-	RootPath           string    // folder path (from join)
-	HashStartedAt      *time.Time
-	HashCompletedAt    *time.Time
-	FileCount          *int64
-	ScanSkippedCount   *int64
-	HashedFileCount    *int64
-	HashedByteCount    *int64
-	HashReusedCount    *int64
-	HashErrorCount     *int64
+	ID                int64
+	FolderID          int64 // folder that was scanned
+	CreatedAt         time.Time
+	CompletedAt       *time.Time
+	RootPath          string // folder path (from join)
+	HashStartedAt     *time.Time
+	HashCompletedAt   *time.Time
+	FileCount         *int64
+	ScanSkippedCount  *int64
+	HashedFileCount   *int64
+	HashedByteCount   *int64
+	HashReusedCount   *int64
+	HashErrorCount    *int64
+	HashPausedAt      *time.Time // set while the hash phase is paused (or a pause was requested)
+	LockedAt          *time.Time // set when the scan was locked (ledger snapshot frozen)
+	Checksum          *string    // checksum over the locked snapshot (see SnapshotChecksum)
+	Verify            bool       // verification scan: unchanged files are re-read and checked for bit rot
+	VerifyCompletedAt *time.Time
+	VerifiedFileCount *int64
+	CatalogLabel      string // catalog scan of a removable drive: the drive's label when it started; "" = not a catalog
+	CatalogUUID       string // catalog scan: the drive's filesystem UUID; "" = unknown
 }
 
 // CreateScan inserts a new scan for the given folder_id and returns the scan.
@@ -35,18 +43,27 @@ func CreateScan(ctx context.Context, database *sql.DB, folderID int64) (*Scan, e
 	return GetScan(ctx, database, id)
 }
 
-// GetScan returns the scan with the given id (with root_path from folders join), or sql.ErrNoRows if not found.
-func GetScan(ctx context.Context, database *sql.DB, id int64) (*Scan, error) {
+// scanColumns is the SELECT list shared by GetScan and listScans (scans s JOIN folders f).
+const scanColumns = `s.id, s.folder_id, s.started_at, s.completed_at, f.path, s.hash_started_at, s.hash_completed_at,
+	s.file_count, s.scan_skipped_count, s.hashed_file_count, s.hashed_byte_count, s.hash_reused_count, s.hash_error_count,
+	s.hash_paused_at, s.locked_at, s.checksum, s.verify, s.verify_completed_at, s.verified_file_count,
+	s.catalog_label, s.catalog_uuid`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanScanRow reads one row selected with scanColumns into a Scan.
+func scanScanRow(row rowScanner) (*Scan, error) {
 	var s Scan
-	var completedAt, hashStartedAt, hashCompletedAt sql.NullTime
-	var fileCount, scanSkipped, hashedFileCount, hashedByteCount, hashReused, hashError sql.NullInt64
-	err := database.QueryRowContext(ctx,
-		`SELECT s.id, s.folder_id, s.started_at, s.completed_at, f.path, s.hash_started_at, s.hash_completed_at,
-		 s.file_count, s.scan_skipped_count, s.hashed_file_count, s.hashed_byte_count, s.hash_reused_count, s.hash_error_count
-		 FROM scans s JOIN folders f ON s.folder_id = f.id WHERE s.id = $1`,
-		id).Scan(&s.ID, &s.FolderID, &s.CreatedAt, &completedAt, &s.RootPath, &hashStartedAt, &hashCompletedAt,
-		&fileCount, &scanSkipped, &hashedFileCount, &hashedByteCount, &hashReused, &hashError)
-	if err != nil {
+	var completedAt, hashStartedAt, hashCompletedAt, hashPausedAt, lockedAt, verifyCompletedAt sql.NullTime
+	var checksum sql.NullString
+	var fileCount, scanSkipped, hashedFileCount, hashedByteCount, hashReused, hashError, verifiedFileCount sql.NullInt64
+	if err := row.Scan(&s.ID, &s.FolderID, &s.CreatedAt, &completedAt, &s.RootPath, &hashStartedAt, &hashCompletedAt,
+		&fileCount, &scanSkipped, &hashedFileCount, &hashedByteCount, &hashReused, &hashError,
+		&hashPausedAt, &lockedAt, &checksum, &s.Verify, &verifyCompletedAt, &verifiedFileCount,
+		&s.CatalogLabel, &s.CatalogUUID); err != nil {
 		return nil, err
 	}
 	if completedAt.Valid {
@@ -58,6 +75,15 @@ func GetScan(ctx context.Context, database *sql.DB, id int64) (*Scan, error) {
 	if hashCompletedAt.Valid {
 		s.HashCompletedAt = &hashCompletedAt.Time
 	}
+	if hashPausedAt.Valid {
+		s.HashPausedAt = &hashPausedAt.Time
+	}
+	if lockedAt.Valid {
+		s.LockedAt = &lockedAt.Time
+	}
+	if checksum.Valid {
+		s.Checksum = &checksum.String
+	}
 	if fileCount.Valid {
 		s.FileCount = &fileCount.Int64
 	}
@@ -76,9 +102,21 @@ func GetScan(ctx context.Context, database *sql.DB, id int64) (*Scan, error) {
 	if hashError.Valid {
 		s.HashErrorCount = &hashError.Int64
 	}
+	if verifyCompletedAt.Valid {
+		s.VerifyCompletedAt = &verifyCompletedAt.Time
+	}
+	if verifiedFileCount.Valid {
+		s.VerifiedFileCount = &verifiedFileCount.Int64
+	}
 	return &s, nil
 }
 
+// GetScan returns the scan with the given id (with root_path from folders join), or sql.ErrNoRows if not found.
+func GetScan(ctx context.Context, database *sql.DB, id int64) (*Scan, error) {
+	return scanScanRow(database.QueryRowContext(ctx,
+		`SELECT `+scanColumns+` FROM scans s JOIN folders f ON s.folder_id = f.id WHERE s.id = $1`, id))
+}
+
 // UpdateScanCompletedAt sets completed_at, file_count, and scan_skipped_count for the given scan.
 func UpdateScanCompletedAt(ctx context.Context, database *sql.DB, scanID int64, fileCount, scanSkippedCount int64) error {
 	_, err := database.ExecContext(ctx,
@@ -100,7 +138,9 @@ func UpdateScanFileCountProgress(ctx context.Context, database *sql.DB, scanID i
 func UpdateScanHashStartedAt(ctx context.Context, database *sql.DB, scanID int64) error {
 	_, err := database.ExecContext(ctx,
 		`UPDATE scans SET hash_started_at = $1, hash_completed_at = NULL, hashed_file_count = NULL,
-		 hashed_byte_count = NULL, hash_reused_count = NULL, hash_error_count = NULL WHERE id = $2`,
+		 hashed_byte_count = NULL, hash_reused_count = NULL, hash_error_count = NULL,
+		 hash_progress_total = NULL, hash_progress_done = NULL, hash_progress_bytes = NULL, hash_progress_at = NULL
+		 WHERE id = $2`,
 		NowUTC(), scanID)
 	return err
 }
@@ -134,9 +174,7 @@ func ListScansRecent(ctx context.Context, database *sql.DB, limit int) ([]Scan,
 }
 
 func listScans(ctx context.Context, database *sql.DB, limit int) ([]Scan, error) {
-	q := `SELECT s.id, s.folder_id, s.started_at, s.completed_at, f.path, s.hash_started_at, s.hash_completed_at,
-	      s.file_count, s.scan_skipped_count, s.hashed_file_count, s.hashed_byte_count, s.hash_reused_count, s.hash_error_count
-	      FROM scans s JOIN folders f ON s.folder_id = f.id ORDER BY s.started_at DESC, s.id DESC`
+	q := `SELECT ` + scanColumns + ` FROM scans s JOIN folders f ON s.folder_id = f.id ORDER BY s.started_at DESC, s.id DESC`
 	args := []interface{}{}
 	if limit > 0 {
 		q += " LIMIT $1"
@@ -150,41 +188,11 @@ func listScans(ctx context.Context, database *sql.DB, limit int) ([]Scan, error)
 
 	var scans []Scan
 	for rows.Next() {
-		var s Scan
-		var completedAt, hashStartedAt, hashCompletedAt sql.NullTime
-		var fileCount, scanSkipped, hashedFileCount, hashedByteCount, hashReused, hashError sql.NullInt64
-		if err := rows.Scan(&s.ID, &s.FolderID, &s.CreatedAt, &completedAt, &s.RootPath, &hashStartedAt, &hashCompletedAt,
-			&fileCount, &scanSkipped, &hashedFileCount, &hashedByteCount, &hashReused, &hashError); err != nil {
+		s, err := scanScanRow(rows)
+		if err != nil {
 			return nil, err
 		}
-		if completedAt.Valid {
-			s.CompletedAt = &completedAt.Time
-		}
-		if hashStartedAt.Valid {
-			s.HashStartedAt = &hashStartedAt.Time
-		}
-		if hashCompletedAt.Valid {
-			s.HashCompletedAt = &hashCompletedAt.Time
-		}
-		if fileCount.Valid {
-			s.FileCount = &fileCount.Int64
-		}
-		if scanSkipped.Valid {
-			s.ScanSkippedCount = &scanSkipped.Int64
-		}
-		if hashedFileCount.Valid {
-			s.HashedFileCount = &hashedFileCount.Int64
-		}
-		if hashedByteCount.Valid {
-			s.HashedByteCount = &hashedByteCount.Int64
-		}
-		if hashReused.Valid {
-			s.HashReusedCount = &hashReused.Int64
-		}
-		if hashError.Valid {
-			s.HashErrorCount = &hashError.Int64
-		}
-		scans = append(scans, s)
+		scans = append(scans, *s)
 	}
 	return scans, rows.Err()
 }
@@ -204,3 +212,31 @@ func GetLatestIncompleteScanForFolder(ctx context.Context, database *sql.DB, fol
 	}
 	return id, nil
 }
+
+// RequestScanHashPause marks the scan's hash phase as paused. A running hash phase (in this or another
+// process) notices the flag, lets workers finish their current file, and returns hash.ErrPaused.
+// Remaining files stay 'pending'. Returns false if the scan does not exist or its hash phase is already complete.
+func RequestScanHashPause(ctx context.Context, database *sql.DB, scanID int64) (bool, error) {
+	res, err := database.ExecContext(ctx,
+		"UPDATE scans SET hash_paused_at = $1 WHERE id = $2 AND hash_completed_at IS NULL AND hash_paused_at IS NULL",
+		NowUTC(), scanID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ClearScanHashPause clears the paused flag so the hash phase can be resumed.
+func ClearScanHashPause(ctx context.Context, database *sql.DB, scanID int64) error {
+	_, err := database.ExecContext(ctx, "UPDATE scans SET hash_paused_at = NULL WHERE id = $1", scanID)
+	return err
+}
+
+// IsScanHashPaused reports whether a pause has been requested for the scan's hash phase.
+func IsScanHashPaused(ctx context.Context, database *sql.DB, scanID int64) (bool, error) {
+	var paused bool
+	err := database.QueryRowContext(ctx,
+		"SELECT hash_paused_at IS NOT NULL FROM scans WHERE id = $1", scanID).Scan(&paused)
+	return paused, err
+}
@@ -128,3 +128,45 @@ func TestGetLatestIncompleteScanForFolder(t *testing.T) {
 		t.Errorf("other folder: got %d, want 0", id)
 	}
 }
+
+func TestRequestScanHashPause_andClear(t *testing.T) {
+	db := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, db, "/tmp")
+	s, _ := CreateScan(ctx, db, folderID)
+
+	ok, err := RequestScanHashPause(ctx, db, s.ID)
+	if err != nil {
+		t.Fatalf("RequestScanHashPause: %v", err)
+	}
+	if !ok {
+		t.Error("RequestScanHashPause: want true for running scan")
+	}
+	paused, _ := IsScanHashPaused(ctx, db, s.ID)
+	if !paused {
+		t.Error("IsScanHashPaused after pause: want true")
+	}
+	got, _ := GetScan(ctx, db, s.ID)
+	if got.HashPausedAt == nil {
+		t.Error("GetScan: HashPausedAt is nil after pause")
+	}
+	// Second pause is a no-op
+	if ok, _ := RequestScanHashPause(ctx, db, s.ID); ok {
+		t.Error("RequestScanHashPause twice: want false")
+	}
+
+	if err := ClearScanHashPause(ctx, db, s.ID); err != nil {
+		t.Fatalf("ClearScanHashPause: %v", err)
+	}
+	paused, _ = IsScanHashPaused(ctx, db, s.ID)
+	if paused {
+		t.Error("IsScanHashPaused after clear: want false")
+	}
+
+	// Completed hash phase cannot be paused
+	_ = UpdateScanHashCompletedAt(ctx, db, s.ID, 0, 0, 0, 0)
+	if ok, _ := RequestScanHashPause(ctx, db, s.ID); ok {
+		t.Error("RequestScanHashPause on completed scan: want false")
+	}
+}
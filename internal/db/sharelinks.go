@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Share link kinds: what the shared report shows.
+const (
+	ShareKindSummary    = "summary"    // scan summary (savings, top extensions)
+	ShareKindDuplicates = "duplicates" // summary plus the duplicate groups, optionally of one extension
+)
+
+// ErrShareLinkInvalid is returned by GetShareLink for an unknown or expired token.
+var ErrShareLinkInvalid = errors.New("share link is invalid or expired")
+
+// ShareLink grants read-only access to one scan's report until ExpiresAt.
+type ShareLink struct {
+	ID        int64
+	ScanID    int64
+	Kind      string // ShareKindSummary or ShareKindDuplicates
+	Ext       string // duplicates kind: only groups with a file of this extension ("" = all; see NormalizeExtension)
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the link is past its expiry.
+func (l *ShareLink) Expired() bool { return !NowUTC().Before(l.ExpiresAt) }
+
+// newToken returns a random URL-safe token. Share links and API tokens store only its hashToken.
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateShareLink creates a link to the scan's report valid for ttl and returns its token. The token is not
+// stored (only its hash), so it can only be shown now.
+func CreateShareLink(ctx context.Context, database *sql.DB, scanID int64, kind, ext string, ttl time.Duration) (string, *ShareLink, error) {
+	if kind != ShareKindSummary && kind != ShareKindDuplicates {
+		return "", nil, errors.New("unknown share link kind " + kind)
+	}
+	if kind == ShareKindSummary {
+		ext = ""
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	now := NowUTC()
+	l := &ShareLink{ScanID: scanID, Kind: kind, Ext: NormalizeExtension(ext), CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	err = database.QueryRowContext(ctx,
+		`INSERT INTO share_links (token_hash, scan_id, kind, ext, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		hashToken(token), scanID, l.Kind, l.Ext, l.CreatedAt, l.ExpiresAt).Scan(&l.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, l, nil
+}
+
+// GetShareLink returns the unexpired link for token, or ErrShareLinkInvalid.
+func GetShareLink(ctx context.Context, database *sql.DB, token string) (*ShareLink, error) {
+	var l ShareLink
+	err := database.QueryRowContext(ctx,
+		`SELECT id, scan_id, kind, ext, created_at, expires_at FROM share_links WHERE token_hash = $1 AND expires_at > $2`,
+		hashToken(token), NowUTC()).Scan(&l.ID, &l.ScanID, &l.Kind, &l.Ext, &l.CreatedAt, &l.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShareLinkInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListShareLinks returns the scan's share links (expired ones included), newest first.
+func ListShareLinks(ctx context.Context, database *sql.DB, scanID int64) ([]ShareLink, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT id, scan_id, kind, ext, created_at, expires_at FROM share_links WHERE scan_id = $1 ORDER BY created_at DESC, id DESC`,
+		scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ShareLink
+	for rows.Next() {
+		var l ShareLink
+		if err := rows.Scan(&l.ID, &l.ScanID, &l.Kind, &l.Ext, &l.CreatedAt, &l.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// DeleteShareLink revokes one of the scan's share links.
+func DeleteShareLink(ctx context.Context, database *sql.DB, scanID, id int64) error {
+	_, err := database.ExecContext(ctx, `DELETE FROM share_links WHERE id = $1 AND scan_id = $2`, id, scanID)
+	return err
+}
+
+// DuplicateGroupsByHashWithExt returns up to limit of the scan's duplicate-by-hash groups (largest first) that
+// contain a file with the extension ext ("" = all groups; see NormalizeExtension).
+func DuplicateGroupsByHashWithExt(ctx context.Context, database *sql.DB, scanID int64, ext string, limit int) ([]DuplicateGroupByHash, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.hash, COUNT(*), COALESCE(SUM(f.size), 0) FROM files f
+		 JOIN file_scan fs ON f.id = fs.file_id
+		 WHERE fs.scan_id = $1 AND f.hash_status = 'done' AND `+notAcknowledged+`
+		 GROUP BY f.hash
+		 HAVING COUNT(*) > 1 AND ($2 = '' OR bool_or(lower(substring(f.path from '\.[^./]*$')) = $2))
+		 ORDER BY SUM(f.size) DESC, f.hash
+		 LIMIT $3`,
+		scanID, NormalizeExtension(ext), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var groups []DuplicateGroupByHash
+	for rows.Next() {
+		var g DuplicateGroupByHash
+		if err := rows.Scan(&g.Hash, &g.Count, &g.Size); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShareLinks_createGetExpireAndRevoke(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	token, l, err := CreateShareLink(ctx, database, sn.ID, ShareKindDuplicates, "JPG", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+	if l.Ext != ".jpg" {
+		t.Errorf("Ext = %q, want .jpg", l.Ext)
+	}
+	got, err := GetShareLink(ctx, database, token)
+	if err != nil || got.ID != l.ID || got.Kind != ShareKindDuplicates {
+		t.Fatalf("GetShareLink = %+v, %v; want link %d", got, err, l.ID)
+	}
+	if _, err := GetShareLink(ctx, database, token+"x"); !errors.Is(err, ErrShareLinkInvalid) {
+		t.Errorf("GetShareLink(wrong token) err = %v, want ErrShareLinkInvalid", err)
+	}
+
+	expired, _, _ := CreateShareLink(ctx, database, sn.ID, ShareKindSummary, "", -time.Minute)
+	if _, err := GetShareLink(ctx, database, expired); !errors.Is(err, ErrShareLinkInvalid) {
+		t.Errorf("GetShareLink(expired) err = %v, want ErrShareLinkInvalid", err)
+	}
+
+	if err := DeleteShareLink(ctx, database, sn.ID, l.ID); err != nil {
+		t.Fatalf("DeleteShareLink: %v", err)
+	}
+	if _, err := GetShareLink(ctx, database, token); !errors.Is(err, ErrShareLinkInvalid) {
+		t.Errorf("GetShareLink(revoked) err = %v, want ErrShareLinkInvalid", err)
+	}
+	links, _ := ListShareLinks(ctx, database, sn.ID)
+	if len(links) != 1 || !links[0].Expired() {
+		t.Errorf("ListShareLinks = %+v, want the expired link only", links)
+	}
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// FileSighting is when a file (a path of a folder) was seen by its folder's scans, derived from file_scan.
+// Deleted scans no longer count, so FirstScanID is the oldest scan still kept that lists the file.
+type FileSighting struct {
+	FirstScanID int64
+	FirstSeenAt time.Time // when the first scan started
+	LastScanID  int64
+	Scans       int64 // scans listing the file
+	ScansAgo    int64 // completed scans of the folder after the first one; 0 when the file is new in the latest scan
+}
+
+// FileSightings returns the sightings of those of the given files that some scan lists, keyed by file id.
+// ScansAgo counts only scans whose hash phase completed, so a running, failed or cancelled scan does not age a file.
+func FileSightings(ctx context.Context, database *sql.DB, fileIDs []int64) (map[int64]FileSighting, error) {
+	out := make(map[int64]FileSighting)
+	if len(fileIDs) == 0 {
+		return out, nil
+	}
+	// #nosec G202 -- placeholders built from len(fileIDs); all values passed as args
+	q := `WITH seen AS (
+			SELECT file_id, MIN(scan_id) AS first_id, MAX(scan_id) AS last_id, COUNT(*) AS n
+			FROM file_scan WHERE file_id IN (` + placeholders(len(fileIDs), 1) + `) GROUP BY file_id)
+		  SELECT seen.file_id, seen.first_id, s.started_at, seen.last_id, seen.n,
+			(SELECT COUNT(*) FROM scans later WHERE later.folder_id = s.folder_id AND later.id > seen.first_id
+				AND later.hash_completed_at IS NOT NULL)
+		  FROM seen JOIN scans s ON s.id = seen.first_id`
+	rows, err := database.QueryContext(ctx, q, idSlice(fileIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var s FileSighting
+		if err := rows.Scan(&id, &s.FirstScanID, &s.FirstSeenAt, &s.LastScanID, &s.Scans, &s.ScansAgo); err != nil {
+			return nil, err
+		}
+		out[id] = s
+	}
+	return out, rows.Err()
+}
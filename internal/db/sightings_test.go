@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFileSightings_firstAndLastScan(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	s1, _ := CreateScan(ctx, database, folderID)
+	s2, _ := CreateScan(ctx, database, folderID)
+	s3, _ := CreateScan(ctx, database, folderID)
+	old, _ := UpsertFile(ctx, database, folderID, "old.txt", 10, 1, 1, nil)
+	fresh, _ := UpsertFile(ctx, database, folderID, "new.txt", 10, 1, 2, nil)
+	unlisted, _ := UpsertFile(ctx, database, folderID, "gone.txt", 10, 1, 3, nil)
+	for _, sid := range []int64{s1.ID, s2.ID, s3.ID} {
+		_ = InsertFileScan(ctx, database, old, sid)
+	}
+	_ = InsertFileScan(ctx, database, fresh, s3.ID)
+	for _, sid := range []int64{s1.ID, s2.ID, s3.ID} {
+		_ = UpdateScanHashCompletedAt(ctx, database, sid, 0, 0, 0, 0)
+	}
+	_, _ = CreateScan(ctx, database, folderID) // still running: does not age the files
+
+	got, err := FileSightings(ctx, database, []int64{old, fresh, unlisted})
+	if err != nil {
+		t.Fatalf("FileSightings: %v", err)
+	}
+	if s := got[old]; s.FirstScanID != s1.ID || s.LastScanID != s3.ID || s.Scans != 3 || s.ScansAgo != 2 {
+		t.Errorf("old = %+v, want first scan %d, 3 scans, 2 scans ago", s, s1.ID)
+	}
+	if s := got[fresh]; s.FirstScanID != s3.ID || s.ScansAgo != 0 {
+		t.Errorf("new = %+v, want first scan %d, 0 scans ago", s, s3.ID)
+	}
+	if _, ok := got[unlisted]; ok || len(got) != 2 {
+		t.Errorf("FileSightings = %+v, want only the listed files", got)
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Outcomes of a slow directory (scan_slow_dirs.outcome).
+const (
+	SlowDirSlow      = "slow"      // listed on the first attempt, but slowly
+	SlowDirRecovered = "recovered" // listed after one or more retries
+	SlowDirFailed    = "failed"    // still failing after the last retry (or not retryable); its contents are missing from the scan
+)
+
+// SlowDir is a directory whose listing was slow, needed retries or failed during a scan of a network share.
+type SlowDir struct {
+	Path     string
+	Duration time.Duration // total time spent listing, including retries and backoff
+	Attempts int
+	Outcome  string // SlowDirSlow, SlowDirRecovered or SlowDirFailed
+	Error    string // last error; empty when the listing eventually succeeded
+}
+
+// InsertScanSlowDirs records the scan's slow directories in one transaction.
+func InsertScanSlowDirs(ctx context.Context, database *sql.DB, scanID int64, dirs []SlowDir) error {
+	if len(dirs) == 0 {
+		return nil
+	}
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, d := range dirs {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO scan_slow_dirs (scan_id, path, duration_ms, attempts, outcome, error) VALUES ($1, $2, $3, $4, $5, $6)`,
+			scanID, d.Path, d.Duration.Milliseconds(), d.Attempts, d.Outcome, d.Error); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CountScanSlowDirs returns how many slow directories the scan recorded and how many of them failed.
+func CountScanSlowDirs(ctx context.Context, database *sql.DB, scanID int64) (total, failed int64, err error) {
+	err = database.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE outcome = $2) FROM scan_slow_dirs WHERE scan_id = $1`,
+		scanID, SlowDirFailed).Scan(&total, &failed)
+	return total, failed, err
+}
+
+// ScanSlowDirs returns the scan's slow directories: failed ones first, then slowest first.
+func ScanSlowDirs(ctx context.Context, database *sql.DB, scanID int64) ([]SlowDir, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT path, duration_ms, attempts, outcome, error FROM scan_slow_dirs WHERE scan_id = $1
+		 ORDER BY outcome = $2 DESC, duration_ms DESC, path`,
+		scanID, SlowDirFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SlowDir
+	for rows.Next() {
+		var d SlowDir
+		var ms int64
+		if err := rows.Scan(&d.Path, &ms, &d.Attempts, &d.Outcome, &d.Error); err != nil {
+			return nil, err
+		}
+		d.Duration = time.Duration(ms) * time.Millisecond
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
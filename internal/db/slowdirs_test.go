@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScanSlowDirs_failedFirstThenSlowest(t *testing.T) {
+	db := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, db, "/mnt/share")
+	if f, _ := GetFolder(ctx, db, folderID); f.NetworkFS != NetworkFSAuto {
+		t.Errorf("NetworkFS = %q, want %q by default", f.NetworkFS, NetworkFSAuto)
+	}
+	if err := UpdateFolderNetworkFS(ctx, db, folderID, NetworkFSOn); err != nil {
+		t.Fatalf("UpdateFolderNetworkFS: %v", err)
+	}
+	if err := UpdateFolderNetworkFS(ctx, db, folderID, "maybe"); err != ErrInvalidNetworkFSMode {
+		t.Errorf("UpdateFolderNetworkFS(maybe) err = %v, want ErrInvalidNetworkFSMode", err)
+	}
+	if f, _ := GetFolder(ctx, db, folderID); f.NetworkFS != NetworkFSOn {
+		t.Errorf("NetworkFS = %q, want %q", f.NetworkFS, NetworkFSOn)
+	}
+
+	scan, _ := CreateScan(ctx, db, folderID)
+	in := []SlowDir{
+		{Path: "/mnt/share/a", Duration: 12 * time.Second, Attempts: 1, Outcome: SlowDirSlow},
+		{Path: "/mnt/share/b", Duration: 40 * time.Second, Attempts: 3, Outcome: SlowDirRecovered},
+		{Path: "/mnt/share/c", Duration: 5 * time.Second, Attempts: 4, Outcome: SlowDirFailed, Error: "host is down"},
+	}
+	if err := InsertScanSlowDirs(ctx, db, scan.ID, in); err != nil {
+		t.Fatalf("InsertScanSlowDirs: %v", err)
+	}
+	total, failed, err := CountScanSlowDirs(ctx, db, scan.ID)
+	if err != nil || total != 3 || failed != 1 {
+		t.Errorf("CountScanSlowDirs = %d, %d, %v; want 3, 1", total, failed, err)
+	}
+	got, err := ScanSlowDirs(ctx, db, scan.ID)
+	if err != nil {
+		t.Fatalf("ScanSlowDirs: %v", err)
+	}
+	want := []string{"/mnt/share/c", "/mnt/share/b", "/mnt/share/a"}
+	if len(got) != len(want) {
+		t.Fatalf("ScanSlowDirs returned %d dirs, want %d", len(got), len(want))
+	}
+	for i, p := range want {
+		if got[i].Path != p {
+			t.Errorf("dir %d = %s, want %s", i, got[i].Path, p)
+		}
+	}
+	if got[0].Error != "host is down" || got[0].Attempts != 4 || got[1].Duration != 40*time.Second {
+		t.Errorf("ScanSlowDirs fields = %+v", got[:2])
+	}
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// ErrScanNotLockable is returned by LockScan when the scan's hash phase is not complete or it is already locked.
+var ErrScanNotLockable = errors.New("scan is not lockable (hash phase incomplete or already locked)")
+
+// ErrScanNotLocked is returned by CheckScanChecksum for a scan that has no stored checksum.
+var ErrScanNotLocked = errors.New("scan is not locked")
+
+// SnapshotEntry is one file as recorded in a scan's ledger snapshot (file_scan), with its full path.
+type SnapshotEntry struct {
+	FileID int64
+	Path   string
+	Size   int64
+	MTime  int64
+	Hash   string // empty when the file was not hashed
+}
+
+// GetScanSnapshot returns the scan's file set as recorded in file_scan, sorted by path (byte order).
+// Rows snapshotted before file_scan carried size/mtime/hash fall back to the current files values.
+func GetScanSnapshot(ctx context.Context, database *sql.DB, scanID int64) ([]SnapshotEntry, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, (fo.path || '/' || f.path), COALESCE(fs.size, f.size), COALESCE(fs.mtime, f.mtime), COALESCE(fs.hash, f.hash, '')
+		 FROM file_scan fs JOIN files f ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id = $1`,
+		scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SnapshotEntry
+	for rows.Next() {
+		var e SnapshotEntry
+		if err := rows.Scan(&e.FileID, &e.Path, &e.Size, &e.MTime, &e.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Sort in Go so the order (and so the checksum) does not depend on the database collation.
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// SnapshotChecksum returns the hex SHA-256 over the entries' path, size, mtime, and hash, in the given order.
+func SnapshotChecksum(entries []SnapshotEntry) string {
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e.Path))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.FormatInt(e.Size, 10)))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.FormatInt(e.MTime, 10)))
+		h.Write([]byte{0})
+		h.Write([]byte(e.Hash))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LockScan freezes a completed scan: missing snapshot values are filled from files, the scan-level checksum is
+// stored, and locked_at is set. Once locked, the database rejects any change to the scan's file_scan rows.
+// Returns ErrScanNotLockable if the hash phase is not complete or the scan is already locked.
+func LockScan(ctx context.Context, database *sql.DB, scanID int64) (string, error) {
+	sn, err := GetScan(ctx, database, scanID)
+	if err != nil {
+		return "", err
+	}
+	if sn.HashCompletedAt == nil || sn.LockedAt != nil {
+		return "", ErrScanNotLockable
+	}
+	if _, err := database.ExecContext(ctx,
+		`UPDATE file_scan fs SET size = f.size, mtime = f.mtime, hash = f.hash
+		 FROM files f WHERE fs.file_id = f.id AND fs.scan_id = $1 AND fs.size IS NULL`,
+		scanID); err != nil {
+		return "", err
+	}
+	entries, err := GetScanSnapshot(ctx, database, scanID)
+	if err != nil {
+		return "", err
+	}
+	checksum := SnapshotChecksum(entries)
+	res, err := database.ExecContext(ctx,
+		`UPDATE scans SET locked_at = $1, checksum = $2 WHERE id = $3 AND locked_at IS NULL AND hash_completed_at IS NOT NULL`,
+		NowUTC(), checksum, scanID)
+	if err != nil {
+		return "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", ErrScanNotLockable
+	}
+	return checksum, nil
+}
+
+// CheckScanChecksum recomputes a locked scan's checksum from its ledger snapshot and compares it with the stored one.
+// Returns ok=false (and the recomputed checksum) when the snapshot drifted. Returns ErrScanNotLocked for unlocked scans.
+func CheckScanChecksum(ctx context.Context, database *sql.DB, scanID int64) (ok bool, current string, err error) {
+	sn, err := GetScan(ctx, database, scanID)
+	if err != nil {
+		return false, "", err
+	}
+	if sn.Checksum == nil {
+		return false, "", ErrScanNotLocked
+	}
+	entries, err := GetScanSnapshot(ctx, database, scanID)
+	if err != nil {
+		return false, "", err
+	}
+	current = SnapshotChecksum(entries)
+	return current == *sn.Checksum, current, nil
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockScan_freezesSnapshotAndDetectsDrift(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	a, _ := UpsertFile(ctx, database, folderID, "a.txt", 10, 1, 1, nil)
+	_ = InsertFileScan(ctx, database, a, sn.ID)
+	_ = UpdateFileHash(ctx, database, a, "h-a", time.Now().UTC())
+
+	if _, err := LockScan(ctx, database, sn.ID); !errors.Is(err, ErrScanNotLockable) {
+		t.Fatalf("LockScan before hash completed: err = %v, want ErrScanNotLockable", err)
+	}
+	_ = SnapshotScanHashes(ctx, database, sn.ID)
+	_ = UpdateScanCompletedAt(ctx, database, sn.ID, 1, 0)
+	_ = UpdateScanHashCompletedAt(ctx, database, sn.ID, 1, 10, 0, 0)
+
+	checksum, err := LockScan(ctx, database, sn.ID)
+	if err != nil {
+		t.Fatalf("LockScan: %v", err)
+	}
+	if _, err := LockScan(ctx, database, sn.ID); !errors.Is(err, ErrScanNotLockable) {
+		t.Errorf("LockScan twice: err = %v, want ErrScanNotLockable", err)
+	}
+	ok, current, err := CheckScanChecksum(ctx, database, sn.ID)
+	if err != nil || !ok || current != checksum {
+		t.Fatalf("CheckScanChecksum = %v, %q, %v; want true, %q", ok, current, err, checksum)
+	}
+
+	// Ledger rows of a locked scan cannot be changed.
+	b, _ := UpsertFile(ctx, database, folderID, "b.txt", 5, 1, 2, nil)
+	if err := InsertFileScan(ctx, database, b, sn.ID); err == nil {
+		t.Error("InsertFileScan into locked scan: want error")
+	}
+	// Re-hashing the file changes files.hash but not the locked snapshot.
+	_, _ = UpsertFile(ctx, database, folderID, "a.txt", 11, 2, 1, nil)
+	_ = UpdateFileHash(ctx, database, a, "h-a2", time.Now().UTC())
+	_ = SnapshotScanHashes(ctx, database, sn.ID)
+	if ok, _, _ := CheckScanChecksum(ctx, database, sn.ID); !ok {
+		t.Error("CheckScanChecksum after file changed: want snapshot unchanged")
+	}
+}
+
+func TestSnapshotChecksum_dependsOnEveryField(t *testing.T) {
+	base := []SnapshotEntry{{Path: "/d/a", Size: 1, MTime: 2, Hash: "h"}}
+	want := SnapshotChecksum(base)
+	for _, e := range []SnapshotEntry{
+		{Path: "/d/b", Size: 1, MTime: 2, Hash: "h"},
+		{Path: "/d/a", Size: 3, MTime: 2, Hash: "h"},
+		{Path: "/d/a", Size: 1, MTime: 3, Hash: "h"},
+		{Path: "/d/a", Size: 1, MTime: 2, Hash: "x"},
+	} {
+		if got := SnapshotChecksum([]SnapshotEntry{e}); got == want {
+			t.Errorf("SnapshotChecksum(%+v) equals base checksum", e)
+		}
+	}
+}
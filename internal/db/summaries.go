@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// summaryTopExtensions is how many extensions RefreshScanSummary keeps, by wasted bytes.
+const summaryTopExtensions = 10
+
+// ScanSummary is a scan's duplicate statistics as materialized in scan_summaries.
+type ScanSummary struct {
+	ScanID         int64
+	DuplicateFiles int64             // files in duplicate-by-hash groups
+	Savings        SavingsProjection // Savings.Groups is the number of groups; DeleteSavings the wasted bytes
+	TopExtensions  []ExtensionWaste
+	ComputedAt     time.Time
+}
+
+// ExtensionWaste is the duplicated content of one extension: Files are the extra copies (every distinct inode
+// but the first path of each group, as in SavingsProjection.DeleteSavings) and WastedBytes their size.
+// Ext is lower-case with the dot, or "" for no extension.
+type ExtensionWaste struct {
+	Ext         string
+	Files       int64
+	WastedBytes int64
+}
+
+// RefreshScanSummary computes the scan's duplicate statistics and stores them in scan_summaries (replacing an
+// older row). Called when the hash phase completes; a later rehash of the folder's files is not reflected
+// in the scan's summary until it is refreshed again.
+func RefreshScanSummary(ctx context.Context, database *sql.DB, scanID int64) (*ScanSummary, error) {
+	sum := &ScanSummary{ScanID: scanID, ComputedAt: NowUTC()}
+	err := database.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(n), 0) FROM (
+			SELECT COUNT(*) AS n FROM files f JOIN file_scan fs ON f.id = fs.file_id
+			WHERE fs.scan_id = $1 AND f.hash_status = 'done' AND `+notAcknowledged+`
+			GROUP BY f.hash HAVING COUNT(*) > 1
+		) g`, scanID).Scan(&sum.DuplicateFiles)
+	if err != nil {
+		return nil, err
+	}
+	savings, err := ProjectSavings(ctx, database, []int64{scanID})
+	if err != nil {
+		return nil, err
+	}
+	sum.Savings = *savings
+	rows, err := database.QueryContext(ctx,
+		`WITH d AS (
+			-- one path per physical copy: hardlinks of a copy waste nothing
+			SELECT DISTINCT ON (f.hash, COALESCE(f.device_id, -1), f.inode) f.hash, f.path, f.size
+			FROM files f JOIN file_scan fs ON f.id = fs.file_id
+			WHERE fs.scan_id = $1 AND f.hash_status = 'done' AND `+notAcknowledged+`
+			ORDER BY f.hash, COALESCE(f.device_id, -1), f.inode, f.path
+		), extra AS (
+			SELECT COALESCE(lower(substring(path from '\.[^./]*$')), '') AS ext, size,
+				ROW_NUMBER() OVER (PARTITION BY hash ORDER BY path) AS rn
+			FROM d
+		)
+		SELECT ext, COUNT(*), SUM(size) FROM extra WHERE rn > 1
+		GROUP BY ext ORDER BY SUM(size) DESC, ext LIMIT $2`, scanID, summaryTopExtensions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e ExtensionWaste
+		if err := rows.Scan(&e.Ext, &e.Files, &e.WastedBytes); err != nil {
+			return nil, err
+		}
+		sum.TopExtensions = append(sum.TopExtensions, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	p := sum.Savings
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO scan_summaries (scan_id, group_count, duplicate_files, cross_device_groups, physical_bytes,
+			delete_savings, link_savings, cross_device_bytes, computed_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (scan_id) DO UPDATE SET group_count = $2, duplicate_files = $3, cross_device_groups = $4,
+			physical_bytes = $5, delete_savings = $6, link_savings = $7, cross_device_bytes = $8, computed_at = $9`,
+		scanID, p.Groups, sum.DuplicateFiles, p.CrossDeviceGroups, p.PhysicalBytes,
+		p.DeleteSavings, p.LinkSavings, p.CrossDeviceBytes, sum.ComputedAt); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scan_summary_extensions WHERE scan_id = $1`, scanID); err != nil {
+		return nil, err
+	}
+	for _, e := range sum.TopExtensions {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO scan_summary_extensions (scan_id, ext, files, wasted_bytes) VALUES ($1, $2, $3, $4)`,
+			scanID, e.Ext, e.Files, e.WastedBytes); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// dropSummariesForHash deletes the stored summaries of the scans containing files with hash $1.
+const dropSummariesForHash = `DELETE FROM scan_summaries WHERE scan_id IN (
+	SELECT fs.scan_id FROM file_scan fs JOIN files f ON f.id = fs.file_id WHERE f.hash = $1)`
+
+// DropScanSummariesForHash deletes the stored summaries of the scans containing the group with this hash, so
+// they are recomputed (ScanSummaryOrRefresh) after the group changed on disk.
+func DropScanSummariesForHash(ctx context.Context, database *sql.DB, hash string) error {
+	_, err := database.ExecContext(ctx, dropSummariesForHash, hash)
+	return err
+}
+
+// GetScanSummary returns the stored summary of the scan, or sql.ErrNoRows when none was computed.
+func GetScanSummary(ctx context.Context, database *sql.DB, scanID int64) (*ScanSummary, error) {
+	sum := &ScanSummary{ScanID: scanID}
+	p := &sum.Savings
+	err := database.QueryRowContext(ctx,
+		`SELECT group_count, duplicate_files, cross_device_groups, physical_bytes, delete_savings, link_savings,
+			cross_device_bytes, computed_at
+		 FROM scan_summaries WHERE scan_id = $1`, scanID).
+		Scan(&p.Groups, &sum.DuplicateFiles, &p.CrossDeviceGroups, &p.PhysicalBytes, &p.DeleteSavings, &p.LinkSavings,
+			&p.CrossDeviceBytes, &sum.ComputedAt)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := database.QueryContext(ctx,
+		`SELECT ext, files, wasted_bytes FROM scan_summary_extensions WHERE scan_id = $1 ORDER BY wasted_bytes DESC, ext`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e ExtensionWaste
+		if err := rows.Scan(&e.Ext, &e.Files, &e.WastedBytes); err != nil {
+			return nil, err
+		}
+		sum.TopExtensions = append(sum.TopExtensions, e)
+	}
+	return sum, rows.Err()
+}
+
+// ScanSummaryOrRefresh returns the stored summary, computing and storing it first for scans that have none
+// (scans completed before summaries existed).
+func ScanSummaryOrRefresh(ctx context.Context, database *sql.DB, scanID int64) (*ScanSummary, error) {
+	sum, err := GetScanSummary(ctx, database, scanID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RefreshScanSummary(ctx, database, scanID)
+	}
+	return sum, err
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshScanSummary_storesSavingsAndTopExtensions(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	add := func(path, hash string, size, inode int64) {
+		id, err := UpsertFile(ctx, database, folderID, path, size, 1, inode, nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, hash, time.Now().UTC())
+	}
+	add("a.JPG", "img", 100, 1)
+	add("b.jpg", "img", 100, 2)
+	add("b-link.jpg", "img", 100, 2) // hardlink of b.jpg: wastes nothing
+	add("x.txt", "txt", 10, 3)
+	add("y.txt", "txt", 10, 4)
+	add("z.bin", "unique", 5, 5)
+
+	if _, err := GetScanSummary(ctx, database, sn.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetScanSummary before refresh err = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := RefreshScanSummary(ctx, database, sn.ID); err != nil {
+		t.Fatalf("RefreshScanSummary: %v", err)
+	}
+	sum, err := ScanSummaryOrRefresh(ctx, database, sn.ID)
+	if err != nil {
+		t.Fatalf("ScanSummaryOrRefresh: %v", err)
+	}
+	if sum.Savings.Groups != 2 || sum.DuplicateFiles != 5 || sum.Savings.DeleteSavings != 110 {
+		t.Errorf("summary = %d groups, %d files, %d wasted; want 2, 5, 110", sum.Savings.Groups, sum.DuplicateFiles, sum.Savings.DeleteSavings)
+	}
+	want := []ExtensionWaste{{Ext: ".jpg", Files: 1, WastedBytes: 100}, {Ext: ".txt", Files: 1, WastedBytes: 10}}
+	if len(sum.TopExtensions) != len(want) {
+		t.Fatalf("TopExtensions = %+v, want %+v", sum.TopExtensions, want)
+	}
+	for i := range want {
+		if sum.TopExtensions[i] != want[i] {
+			t.Errorf("TopExtensions[%d] = %+v, want %+v", i, sum.TopExtensions[i], want[i])
+		}
+	}
+}
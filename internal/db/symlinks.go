@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Symlink is a symlink recorded by a scan of a folder in SymlinksRecord mode.
+type Symlink struct {
+	FileID int64
+	Path   string // full path of the link
+	Target string // link target as stored in the link (may be relative or dangling)
+	MTime  int64
+}
+
+// CountScanSymlinks returns the number of symlinks recorded by the scan.
+func CountScanSymlinks(ctx context.Context, database *sql.DB, scanID int64) (int64, error) {
+	var n int64
+	err := database.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM files f JOIN file_scan fs ON f.id = fs.file_id WHERE fs.scan_id = $1 AND f.hash_status = 'symlink'`,
+		scanID).Scan(&n)
+	return n, err
+}
+
+// ScanSymlinks returns the symlinks recorded by the scan ordered by path.
+func ScanSymlinks(ctx context.Context, database *sql.DB, scanID int64, limit, offset int) ([]Symlink, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, (fo.path || '/' || f.path), COALESCE(f.symlink_target, ''), f.mtime
+		 FROM files f JOIN file_scan fs ON f.id = fs.file_id JOIN folders fo ON f.folder_id = fo.id
+		 WHERE fs.scan_id = $1 AND f.hash_status = 'symlink'
+		 ORDER BY f.path LIMIT $2 OFFSET $3`,
+		scanID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Symlink
+	for rows.Next() {
+		var l Symlink
+		if err := rows.Scan(&l.FileID, &l.Path, &l.Target, &l.MTime); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUpsertFilesBatch_recordsSymlinksWithoutHashing(t *testing.T) {
+	db := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, db, "/library")
+	if err := UpdateFolderSymlinks(ctx, db, folderID, SymlinksRecord); err != nil {
+		t.Fatalf("UpdateFolderSymlinks: %v", err)
+	}
+	if err := UpdateFolderSymlinks(ctx, db, folderID, "sometimes"); err != ErrInvalidSymlinkMode {
+		t.Errorf("UpdateFolderSymlinks(sometimes) err = %v, want ErrInvalidSymlinkMode", err)
+	}
+	if f, _ := GetFolder(ctx, db, folderID); f.Symlinks != SymlinksRecord {
+		t.Errorf("Symlinks = %q, want %q", f.Symlinks, SymlinksRecord)
+	}
+
+	scan, _ := CreateScan(ctx, db, folderID)
+	rows := []FileRow{
+		{Path: "a.mp3", Size: 9, MTime: 1, Inode: 1},
+		{Path: "b.mp3", Size: 9, MTime: 1, Inode: 2},
+		{Path: "farm/a.mp3", Size: 9, MTime: 1, Inode: 3, SymlinkTarget: "../a.mp3"}, // same size as the target path length
+	}
+	ids, err := UpsertFilesBatch(ctx, db, folderID, rows)
+	if err != nil {
+		t.Fatalf("UpsertFilesBatch: %v", err)
+	}
+	if err := InsertFileScanBatch(ctx, db, ids, scan.ID); err != nil {
+		t.Fatalf("InsertFileScanBatch: %v", err)
+	}
+	if n, _ := CountHashCandidates(ctx, db, scan.ID); n != 2 {
+		t.Errorf("CountHashCandidates = %d, want 2 (the symlink is never hashed)", n)
+	}
+	links, err := ScanSymlinks(ctx, db, scan.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("ScanSymlinks: %v", err)
+	}
+	if len(links) != 1 || links[0].Path != "/library/farm/a.mp3" || links[0].Target != "../a.mp3" {
+		t.Errorf("ScanSymlinks = %+v, want /library/farm/a.mp3 -> ../a.mp3", links)
+	}
+
+	// The link was replaced by a regular file of the same size and mtime: it becomes a hash candidate.
+	if _, err := UpsertFilesBatch(ctx, db, folderID, []FileRow{{Path: "farm/a.mp3", Size: 9, MTime: 1, Inode: 3}}); err != nil {
+		t.Fatalf("UpsertFilesBatch: %v", err)
+	}
+	if n, _ := CountScanSymlinks(ctx, db, scan.ID); n != 0 {
+		t.Errorf("CountScanSymlinks = %d, want 0", n)
+	}
+	if n, _ := CountHashCandidates(ctx, db, scan.ID); n != 3 {
+		t.Errorf("CountHashCandidates = %d, want 3", n)
+	}
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTestPostgresDB_isolatedPerCall(t *testing.T) {
+	ctx := context.Background()
+	a := TestPostgresDB(t)
+	b := TestPostgresDB(t)
+	if _, err := AddFolder(ctx, a, "/only/in/a"); err != nil {
+		t.Fatalf("AddFolder: %v", err)
+	}
+	folders, err := ListFolders(ctx, b)
+	if err != nil {
+		t.Fatalf("ListFolders: %v", err)
+	}
+	if len(folders) != 0 {
+		t.Errorf("second test database sees %d folders, want 0", len(folders))
+	}
+}
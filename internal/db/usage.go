@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// DirUsage is the size of one entry directly below a directory in a scan: a subdirectory (totals over its
+// whole subtree) or, with IsDir false and empty Name, the files directly in the directory.
+type DirUsage struct {
+	Name           string
+	IsDir          bool
+	Files          int64
+	Bytes          int64
+	DuplicateBytes int64 // bytes in files whose content appears more than once in the scan
+}
+
+// DirectoryUsage returns per-child totals for dir (relative to the scan's folder root; "" for the root),
+// largest first, so a UI can drill into the subtrees that use or waste the most space.
+func DirectoryUsage(ctx context.Context, database *sql.DB, scanID int64, dir string) ([]DirUsage, error) {
+	prefix := strings.Trim(dir, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	rows, err := database.QueryContext(ctx,
+		`WITH dup AS (
+			SELECT f.hash FROM files f JOIN file_scan fs ON f.id = fs.file_id
+			WHERE fs.scan_id = $1 AND f.hash_status = 'done'
+			GROUP BY f.hash HAVING COUNT(*) > 1
+		), sub AS (
+			SELECT substr(f.path, length($2) + 1) AS rest, f.size, dup.hash IS NOT NULL AS is_dup
+			FROM files f JOIN file_scan fs ON f.id = fs.file_id
+			LEFT JOIN dup ON dup.hash = f.hash
+			WHERE fs.scan_id = $1 AND left(f.path, length($2)) = $2
+		)
+		SELECT CASE WHEN position('/' in rest) = 0 THEN '' ELSE split_part(rest, '/', 1) END AS name,
+			COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(CASE WHEN is_dup THEN size ELSE 0 END), 0)
+		FROM sub GROUP BY 1 ORDER BY 3 DESC, 1`,
+		scanID, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []DirUsage
+	for rows.Next() {
+		var u DirUsage
+		if err := rows.Scan(&u.Name, &u.Files, &u.Bytes, &u.DuplicateBytes); err != nil {
+			return nil, err
+		}
+		u.IsDir = u.Name != ""
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
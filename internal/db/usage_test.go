@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDirectoryUsage_groupsByChildWithDuplicateBytes(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	folderID, _ := AddFolder(ctx, database, "/data")
+	sn, _ := CreateScan(ctx, database, folderID)
+	for i, f := range []struct {
+		path string
+		size int64
+		hash string
+	}{
+		{"top.txt", 5, "t"},
+		{"photos/a.jpg", 100, "x"},
+		{"photos/2024/b.jpg", 100, "x"},
+		{"photos/2024/c.jpg", 50, "c"},
+		{"docs/d.pdf", 20, "d"},
+	} {
+		id, err := UpsertFile(ctx, database, folderID, f.path, f.size, 1, int64(i+1), nil)
+		if err != nil {
+			t.Fatalf("UpsertFile: %v", err)
+		}
+		_ = InsertFileScan(ctx, database, id, sn.ID)
+		_ = UpdateFileHash(ctx, database, id, f.hash, time.Now().UTC())
+	}
+
+	root, err := DirectoryUsage(ctx, database, sn.ID, "")
+	if err != nil {
+		t.Fatalf("DirectoryUsage(root): %v", err)
+	}
+	want := []DirUsage{
+		{Name: "photos", IsDir: true, Files: 3, Bytes: 250, DuplicateBytes: 200},
+		{Name: "docs", IsDir: true, Files: 1, Bytes: 20},
+		{Name: "", Files: 1, Bytes: 5},
+	}
+	if len(root) != len(want) {
+		t.Fatalf("DirectoryUsage(root) = %+v, want %+v", root, want)
+	}
+	for i := range want {
+		if root[i] != want[i] {
+			t.Errorf("root[%d] = %+v, want %+v", i, root[i], want[i])
+		}
+	}
+
+	photos, err := DirectoryUsage(ctx, database, sn.ID, "/photos/")
+	if err != nil {
+		t.Fatalf("DirectoryUsage(photos): %v", err)
+	}
+	if len(photos) != 2 || photos[0] != (DirUsage{Name: "2024", IsDir: true, Files: 2, Bytes: 150, DuplicateBytes: 100}) {
+		t.Errorf("DirectoryUsage(photos) = %+v", photos)
+	}
+}
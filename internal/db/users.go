@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrUnknownRole is returned by SetUserRole for a role it does not know.
+var ErrUnknownRole = errors.New("unknown role")
+
+// Role is what a user may do. Admins start scans, link or delete files and change settings; viewers only look.
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleViewer Role = "viewer"
+)
+
+// User is a person using a shared instance. Folders they add are theirs; other users do not see them.
+type User struct {
+	ID        int64
+	Name      string
+	Role      Role
+	CreatedAt time.Time
+}
+
+// GetOrCreateUser returns the user with this name, creating it with role on first sight. An existing user
+// keeps their role.
+func GetOrCreateUser(ctx context.Context, database *sql.DB, name string, role Role) (*User, error) {
+	var u User
+	err := database.QueryRowContext(ctx,
+		`INSERT INTO users (name, role, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		 RETURNING id, name, role, created_at`,
+		name, role, NowUTC()).Scan(&u.ID, &u.Name, &u.Role, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUser returns the user with this id, or sql.ErrNoRows.
+func GetUser(ctx context.Context, database *sql.DB, id int64) (*User, error) {
+	var u User
+	err := database.QueryRowContext(ctx, `SELECT id, name, role, created_at FROM users WHERE id = $1`, id).
+		Scan(&u.ID, &u.Name, &u.Role, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// ListUsers returns all users by name.
+func ListUsers(ctx context.Context, database *sql.DB) ([]User, error) {
+	rows, err := database.QueryContext(ctx, `SELECT id, name, role, created_at FROM users ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// SetUserRole changes the role of user id. Returns sql.ErrNoRows when there is no such user.
+func SetUserRole(ctx context.Context, database *sql.DB, id int64, role Role) error {
+	if role != RoleAdmin && role != RoleViewer {
+		return ErrUnknownRole
+	}
+	res, err := database.ExecContext(ctx, `UPDATE users SET role = $2 WHERE id = $1`, id, role)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FolderOwner returns the owner of the folder, or nil when the folder is shared (or does not exist).
+func FolderOwner(ctx context.Context, database *sql.DB, folderID int64) (*User, error) {
+	var u User
+	err := database.QueryRowContext(ctx,
+		`SELECT u.id, u.name, u.role, u.created_at FROM folders f JOIN users u ON u.id = f.owner_id WHERE f.id = $1`,
+		folderID).Scan(&u.ID, &u.Name, &u.Role, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SetFolderOwner gives the folder to userID, or shares it with everyone when userID is nil.
+func SetFolderOwner(ctx context.Context, database *sql.DB, folderID int64, userID *int64) error {
+	_, err := database.ExecContext(ctx, `UPDATE folders SET owner_id = $2 WHERE id = $1`, folderID, userID)
+	return err
+}
+
+// ClaimNewFolder makes userID the owner of a folder that was just added: one that has no owner and was
+// never scanned. A shared folder someone else already scans stays shared. Reports whether it was claimed.
+func ClaimNewFolder(ctx context.Context, database *sql.DB, folderID, userID int64) (bool, error) {
+	res, err := database.ExecContext(ctx,
+		`UPDATE folders SET owner_id = $2
+		 WHERE id = $1 AND owner_id IS NULL AND NOT EXISTS (SELECT 1 FROM scans WHERE folder_id = $1)`,
+		folderID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FolderVisibleTo reports whether the folder is shared or owned by userID. A missing folder counts as
+// visible, so callers report it as not found.
+func FolderVisibleTo(ctx context.Context, database *sql.DB, folderID, userID int64) (bool, error) {
+	var visible bool
+	err := database.QueryRowContext(ctx,
+		`SELECT NOT EXISTS (SELECT 1 FROM folders WHERE id = $1 AND owner_id IS NOT NULL AND owner_id <> $2)`,
+		folderID, userID).Scan(&visible)
+	return visible, err
+}
+
+// ScanVisibleTo reports whether the scan's folder is shared or owned by userID. A missing scan counts as
+// visible, so callers report it as not found.
+func ScanVisibleTo(ctx context.Context, database *sql.DB, scanID, userID int64) (bool, error) {
+	var visible bool
+	err := database.QueryRowContext(ctx,
+		`SELECT NOT EXISTS (
+			SELECT 1 FROM scans s JOIN folders f ON f.id = s.folder_id
+			WHERE s.id = $1 AND f.owner_id IS NOT NULL AND f.owner_id <> $2)`,
+		scanID, userID).Scan(&visible)
+	return visible, err
+}
+
+// HiddenFolderPaths returns the paths of folders owned by users other than userID, for filtering lists
+// of scans and roots (which carry the folder path).
+func HiddenFolderPaths(ctx context.Context, database *sql.DB, userID int64) (map[string]bool, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT path FROM folders WHERE owner_id IS NOT NULL AND owner_id <> $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hidden := make(map[string]bool)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		hidden[p] = true
+	}
+	return hidden, rows.Err()
+}
+
+// FolderOwnerNames returns the owner name of every owned folder by folder ID (shared folders are absent).
+func FolderOwnerNames(ctx context.Context, database *sql.DB) (map[int64]string, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT f.id, u.name FROM folders f JOIN users u ON u.id = f.owner_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
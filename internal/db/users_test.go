@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestUsers_folderOwnershipAndVisibility(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	alice, err := GetOrCreateUser(ctx, database, "alice", RoleAdmin)
+	if err != nil {
+		t.Fatalf("GetOrCreateUser: %v", err)
+	}
+	again, _ := GetOrCreateUser(ctx, database, "alice", RoleAdmin)
+	if again.ID != alice.ID {
+		t.Errorf("GetOrCreateUser twice: ids %d and %d, want the same", alice.ID, again.ID)
+	}
+	bob, _ := GetOrCreateUser(ctx, database, "bob", RoleViewer)
+
+	mine, _ := AddFolder(ctx, database, "/home/alice")
+	shared, _ := AddFolder(ctx, database, "/shared")
+	sharedScan, _ := CreateScan(ctx, database, shared)
+	if ok, err := ClaimNewFolder(ctx, database, mine, alice.ID); err != nil || !ok {
+		t.Fatalf("ClaimNewFolder(new) = %v, %v; want true", ok, err)
+	}
+	if ok, _ := ClaimNewFolder(ctx, database, shared, bob.ID); ok {
+		t.Error("ClaimNewFolder(scanned folder) = true, want false")
+	}
+	myScan, _ := CreateScan(ctx, database, mine)
+
+	if u, _ := FolderOwner(ctx, database, mine); u == nil || u.Name != "alice" {
+		t.Errorf("FolderOwner(mine) = %+v, want alice", u)
+	}
+	if u, _ := FolderOwner(ctx, database, shared); u != nil {
+		t.Errorf("FolderOwner(shared) = %+v, want nil", u)
+	}
+	if ok, _ := ScanVisibleTo(ctx, database, myScan.ID, bob.ID); ok {
+		t.Error("ScanVisibleTo(alice's scan, bob) = true, want false")
+	}
+	if ok, _ := ScanVisibleTo(ctx, database, sharedScan.ID, bob.ID); !ok {
+		t.Error("ScanVisibleTo(shared scan, bob) = false, want true")
+	}
+	if ok, _ := FolderVisibleTo(ctx, database, mine, alice.ID); !ok {
+		t.Error("FolderVisibleTo(mine, alice) = false, want true")
+	}
+	hidden, err := HiddenFolderPaths(ctx, database, bob.ID)
+	if err != nil || !hidden["/home/alice"] || hidden["/shared"] {
+		t.Errorf("HiddenFolderPaths(bob) = %v, %v; want only /home/alice", hidden, err)
+	}
+
+	if err := SetFolderOwner(ctx, database, mine, nil); err != nil {
+		t.Fatalf("SetFolderOwner(nil): %v", err)
+	}
+	if ok, _ := ScanVisibleTo(ctx, database, myScan.ID, bob.ID); !ok {
+		t.Error("ScanVisibleTo after sharing = false, want true")
+	}
+	if names, _ := FolderOwnerNames(ctx, database); len(names) != 0 {
+		t.Errorf("FolderOwnerNames = %v, want none", names)
+	}
+}
+
+func TestUsers_roles(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	u, err := GetOrCreateUser(ctx, database, "carol", RoleViewer)
+	if err != nil || u.Role != RoleViewer {
+		t.Fatalf("GetOrCreateUser = %+v, %v; want a viewer", u, err)
+	}
+	if err := SetUserRole(ctx, database, u.ID, RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole: %v", err)
+	}
+	// An existing user keeps their role whatever the default for new users is.
+	if again, _ := GetOrCreateUser(ctx, database, "carol", RoleViewer); again.Role != RoleAdmin {
+		t.Errorf("role after promotion = %q, want admin", again.Role)
+	}
+	if err := SetUserRole(ctx, database, u.ID, "owner"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("SetUserRole(owner) err = %v, want ErrUnknownRole", err)
+	}
+	if err := SetUserRole(ctx, database, u.ID+100, RoleViewer); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SetUserRole(missing) err = %v, want sql.ErrNoRows", err)
+	}
+	if users, _ := ListUsers(ctx, database); len(users) != 1 || users[0].Name != "carol" {
+		t.Errorf("ListUsers = %+v, want carol", users)
+	}
+}
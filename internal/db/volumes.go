@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Volume is a filesystem (identified by UUID) that scans were taken from, typically a removable drive.
+type Volume struct {
+	ID           int64
+	UUID         string
+	Label        string
+	FSType       string
+	IncludeInAll bool      // include this volume's scans in cross-root ("All") duplicate analysis
+	CreatedAt    time.Time // first seen
+	LastSeenAt   time.Time // last time a scan found the volume connected
+	ScanCount    int64
+	Roots        []string // distinct scan-root paths scanned on this volume
+}
+
+// RecordScanVolume records that the scan was taken from the volume with the given UUID: the volume is created
+// or its label, type and last-seen time are refreshed, and the scan is linked to it. Returns the volume id.
+func RecordScanVolume(ctx context.Context, database *sql.DB, scanID int64, uuid, label, fsType string) (int64, error) {
+	var id int64
+	err := database.QueryRowContext(ctx,
+		`INSERT INTO volumes (uuid, label, fs_type, last_seen_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (uuid) DO UPDATE SET label = EXCLUDED.label, fs_type = EXCLUDED.fs_type, last_seen_at = EXCLUDED.last_seen_at
+		 RETURNING id`,
+		uuid, label, fsType, NowUTC()).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	_, err = database.ExecContext(ctx, "UPDATE scans SET volume_id = $1 WHERE id = $2", id, scanID)
+	return id, err
+}
+
+// ListVolumes returns all known volumes, most recently seen first, with their scan counts and root paths.
+func ListVolumes(ctx context.Context, database *sql.DB) ([]Volume, error) {
+	rows, err := database.QueryContext(ctx,
+		`SELECT v.id, v.uuid, v.label, v.fs_type, v.include_in_all, v.created_at, v.last_seen_at,
+			COUNT(s.id), COALESCE(string_agg(DISTINCT fo.path, E'\n'), '')
+		 FROM volumes v
+		 LEFT JOIN scans s ON s.volume_id = v.id
+		 LEFT JOIN folders fo ON s.folder_id = fo.id
+		 GROUP BY v.id
+		 ORDER BY v.last_seen_at DESC, v.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Volume
+	for rows.Next() {
+		var v Volume
+		var roots string
+		if err := rows.Scan(&v.ID, &v.UUID, &v.Label, &v.FSType, &v.IncludeInAll, &v.CreatedAt, &v.LastSeenAt, &v.ScanCount, &roots); err != nil {
+			return nil, err
+		}
+		if roots != "" {
+			v.Roots = strings.Split(roots, "\n")
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+// SetVolumeIncludeInAll includes or excludes the volume's scans from cross-root duplicate analysis.
+// Returns false if no volume has the id.
+func SetVolumeIncludeInAll(ctx context.Context, database *sql.DB, id int64, include bool) (bool, error) {
+	res, err := database.ExecContext(ctx, "UPDATE volumes SET include_in_all = $1 WHERE id = $2", include, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// FilterScansIncludedInAll returns scanIDs without the scans taken from volumes excluded from cross-root
+// analysis or of disabled folders, keeping the input order. Scans without a known volume are otherwise included.
+func FilterScansIncludedInAll(ctx context.Context, database *sql.DB, scanIDs []int64) ([]int64, error) {
+	if len(scanIDs) == 0 {
+		return scanIDs, nil
+	}
+	// #nosec G202 -- placeholders built from len(scanIDs); all values passed as args
+	rows, err := database.QueryContext(ctx,
+		`SELECT s.id FROM scans s JOIN folders fo ON s.folder_id = fo.id LEFT JOIN volumes v ON s.volume_id = v.id
+		 WHERE (NOT COALESCE(v.include_in_all, true) OR fo.disabled_at IS NOT NULL)
+		   AND s.id IN (`+placeholders(len(scanIDs), 1)+`)`,
+		idSlice(scanIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	excluded := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		excluded[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]int64, 0, len(scanIDs))
+	for _, id := range scanIDs {
+		if !excluded[id] {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
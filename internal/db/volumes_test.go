@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordScanVolume_groupsScansAndFiltersExcluded(t *testing.T) {
+	database := TestPostgresDB(t)
+	ctx := context.Background()
+
+	usbA, _ := AddFolder(ctx, database, "/media/usb")
+	usbB, _ := AddFolder(ctx, database, "/mnt/other-mountpoint")
+	local, _ := AddFolder(ctx, database, "/data")
+	s1, _ := CreateScan(ctx, database, usbA)
+	s2, _ := CreateScan(ctx, database, usbB)
+	s3, _ := CreateScan(ctx, database, local)
+
+	v1, err := RecordScanVolume(ctx, database, s1.ID, "1234-ABCD", "BACKUP", "exfat")
+	if err != nil {
+		t.Fatalf("RecordScanVolume: %v", err)
+	}
+	// Same disk mounted elsewhere later, relabelled.
+	v2, err := RecordScanVolume(ctx, database, s2.ID, "1234-ABCD", "BACKUP2", "exfat")
+	if err != nil || v2 != v1 {
+		t.Fatalf("RecordScanVolume(same uuid) = %d, %v; want %d", v2, err, v1)
+	}
+
+	vols, err := ListVolumes(ctx, database)
+	if err != nil {
+		t.Fatalf("ListVolumes: %v", err)
+	}
+	if len(vols) != 1 || vols[0].Label != "BACKUP2" || vols[0].ScanCount != 2 || len(vols[0].Roots) != 2 || !vols[0].IncludeInAll {
+		t.Errorf("ListVolumes = %+v, want one included volume BACKUP2 with 2 scans on 2 roots", vols)
+	}
+
+	ids := []int64{s3.ID, s1.ID, s2.ID}
+	if got, _ := FilterScansIncludedInAll(ctx, database, ids); len(got) != 3 {
+		t.Errorf("FilterScansIncludedInAll before exclusion = %v, want all 3", got)
+	}
+	if ok, err := SetVolumeIncludeInAll(ctx, database, v1, false); err != nil || !ok {
+		t.Fatalf("SetVolumeIncludeInAll = %v, %v", ok, err)
+	}
+	got, err := FilterScansIncludedInAll(ctx, database, ids)
+	if err != nil {
+		t.Fatalf("FilterScansIncludedInAll: %v", err)
+	}
+	if len(got) != 1 || got[0] != s3.ID {
+		t.Errorf("FilterScansIncludedInAll after exclusion = %v, want [%d]", got, s3.ID)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+
+package dedupe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as an APFS clone of src (clonefile) with the mode, modification time and owner of like.
+func cloneFile(src, dst string, like os.FileInfo) error {
+	if err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW); err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return fmt.Errorf("%w: %v", ErrReflinkUnsupported, err)
+		}
+		return err
+	}
+	return copyMetadata(dst, like)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package dedupe
+
+import "os"
+
+// cloneFile is only implemented on Linux (FICLONE) and macOS (clonefile).
+func cloneFile(src, dst string, like os.FileInfo) error {
+	return ErrReflinkUnsupported
+}
@@ -0,0 +1,131 @@
+// Package dedupe replaces a duplicate file with a link to the copy that is kept, so both paths share one copy
+// of the data on disk.
+package dedupe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/hash"
+	"github.com/eargollo/ditto/internal/pathnorm"
+)
+
+// Method is how a duplicate is linked to the kept copy.
+type Method string
+
+const (
+	// Hardlink makes the duplicate another name of the kept file: one inode, so mode, owner and modification
+	// time are shared, and writing through either path changes both.
+	Hardlink Method = "hardlink"
+	// Reflink makes the duplicate a copy-on-write clone of the kept file (FICLONE on Btrfs and XFS, clonefile
+	// on APFS): it stays an independent file with its own metadata, sharing extents until one is written.
+	Reflink Method = "reflink"
+)
+
+var (
+	// ErrUnknownMethod is returned for a method other than Hardlink or Reflink.
+	ErrUnknownMethod = errors.New("unknown link method")
+	// ErrCrossDevice is returned when the two files are on different filesystems; they cannot be linked.
+	ErrCrossDevice = errors.New("files are on different devices")
+	// ErrReflinkUnsupported is returned when the filesystem (or platform) cannot clone files.
+	ErrReflinkUnsupported = errors.New("filesystem does not support reflinks")
+	// ErrContentChanged is returned when the files no longer have identical bytes.
+	ErrContentChanged = errors.New("files no longer have identical content")
+	// ErrNotRegular is returned when either path is not a regular file.
+	ErrNotRegular = errors.New("not a regular file")
+	// ErrInArchive is returned when either path names a file inside an archive, which cannot be linked.
+	ErrInArchive = errors.New("file is inside an archive")
+)
+
+// ParseMethod returns the method named s.
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(s); m {
+	case Hardlink, Reflink:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownMethod, s)
+}
+
+// Replace replaces dup with a link to keep. Both must be regular files on the same filesystem with identical
+// bytes, compared in full first so a file modified since it was hashed is never replaced. The link is made
+// under a temporary name next to dup and renamed over it, so dup is never missing; a reflink keeps dup's
+// mode, modification time and (when permitted) owner. Files that are already hardlinks of each other are
+// left as they are.
+func Replace(ctx context.Context, keep, dup string, m Method) error {
+	if _, err := ParseMethod(string(m)); err != nil {
+		return err
+	}
+	for _, p := range []string{keep, dup} {
+		if archive.IsVirtual(p) {
+			return fmt.Errorf("%s: %w", p, ErrInArchive)
+		}
+	}
+	// Stored paths may name files in another Unicode form than the disk: rename over the name on disk.
+	keep, dup = pathnorm.Resolve(keep), pathnorm.Resolve(dup)
+	keepInfo, err := os.Lstat(keep)
+	if err != nil {
+		return err
+	}
+	dupInfo, err := os.Lstat(dup)
+	if err != nil {
+		return err
+	}
+	if !keepInfo.Mode().IsRegular() {
+		return fmt.Errorf("%s: %w", keep, ErrNotRegular)
+	}
+	if !dupInfo.Mode().IsRegular() {
+		return fmt.Errorf("%s: %w", dup, ErrNotRegular)
+	}
+	if os.SameFile(keepInfo, dupInfo) {
+		return nil
+	}
+	same, err := hash.SameContent(ctx, keep, dup)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("%s: %w", dup, ErrContentChanged)
+	}
+	tmp, err := tempName(dup)
+	if err != nil {
+		return err
+	}
+	if m == Hardlink {
+		err = os.Link(keep, tmp)
+	} else {
+		err = cloneFile(keep, tmp, dupInfo)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return linkError(err)
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// tempName returns an unused-looking hidden name in dup's directory.
+func tempName(dup string) (string, error) {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(dup), "."+filepath.Base(dup)+".ditto-"+hex.EncodeToString(b[:])), nil
+}
+
+// linkError maps the system error of a failed link or clone to the package errors.
+func linkError(err error) error {
+	if errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("%w: %v", ErrCrossDevice, err)
+	}
+	return err
+}
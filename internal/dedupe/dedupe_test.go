@@ -0,0 +1,86 @@
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplace_hardlink(t *testing.T) {
+	dir := t.TempDir()
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	writeFile(t, keep, "same bytes")
+	writeFile(t, dup, "same bytes")
+
+	if err := Replace(context.Background(), keep, dup, Hardlink); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	ki, _ := os.Stat(keep)
+	di, _ := os.Stat(dup)
+	if !os.SameFile(ki, di) {
+		t.Error("dup is not a hardlink of keep after Replace")
+	}
+	if err := Replace(context.Background(), keep, dup, Hardlink); err != nil {
+		t.Errorf("Replace on existing hardlink: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("directory has %d entries, want 2 (temporary file left behind?)", len(entries))
+	}
+}
+
+func TestReplace_refusesChangedContentAndUnknownMethod(t *testing.T) {
+	dir := t.TempDir()
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	writeFile(t, keep, "original")
+	writeFile(t, dup, "modified")
+
+	if err := Replace(context.Background(), keep, dup, Hardlink); !errors.Is(err, ErrContentChanged) {
+		t.Errorf("Replace(different content) err = %v, want ErrContentChanged", err)
+	}
+	if b, _ := os.ReadFile(dup); string(b) != "modified" {
+		t.Errorf("dup content = %q after refused Replace, want it untouched", b)
+	}
+	if err := Replace(context.Background(), keep, dup, "symlink"); !errors.Is(err, ErrUnknownMethod) {
+		t.Errorf("Replace(unknown method) err = %v, want ErrUnknownMethod", err)
+	}
+	if err := Replace(context.Background(), keep, filepath.Join(dir, "backup.zip!/dup"), Hardlink); !errors.Is(err, ErrInArchive) {
+		t.Errorf("Replace(file in archive) err = %v, want ErrInArchive", err)
+	}
+}
+
+func TestReplace_reflinkKeepsMetadata(t *testing.T) {
+	dir := t.TempDir()
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	writeFile(t, keep, "same bytes")
+	writeFile(t, dup, "same bytes")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	_ = os.Chmod(dup, 0o600)
+	_ = os.Chtimes(dup, mtime, mtime)
+
+	err := Replace(context.Background(), keep, dup, Reflink)
+	if errors.Is(err, ErrReflinkUnsupported) {
+		t.Skipf("temporary directory does not support reflinks: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	ki, _ := os.Stat(keep)
+	di, _ := os.Stat(dup)
+	if os.SameFile(ki, di) {
+		t.Error("reflinked dup shares keep's inode")
+	}
+	if di.Mode().Perm() != 0o600 || !di.ModTime().Equal(mtime) {
+		t.Errorf("dup mode %v mtime %v, want 0600 and %v", di.Mode().Perm(), di.ModTime(), mtime)
+	}
+}
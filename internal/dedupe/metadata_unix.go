@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package dedupe
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyMetadata gives dst the permissions, modification time and (best effort) owner of like.
+func copyMetadata(dst string, like os.FileInfo) error {
+	if err := os.Chmod(dst, like.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(dst, like.ModTime(), like.ModTime()); err != nil {
+		return err
+	}
+	if st, ok := like.Sys().(*syscall.Stat_t); ok {
+		_ = os.Lchown(dst, int(st.Uid), int(st.Gid)) // only root may give files away; keep the process owner then
+	}
+	return nil
+}
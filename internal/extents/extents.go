@@ -0,0 +1,8 @@
+// Package extents measures how much of a file's data the filesystem already shares with other files (reflinks,
+// snapshots, block-level deduplication), so deleting or linking a copy is not credited with space it would not free.
+package extents
+
+import "errors"
+
+// ErrUnsupported is returned by Shared when the platform or filesystem cannot report shared extents.
+var ErrUnsupported = errors.New("shared extents are not reported on this filesystem")
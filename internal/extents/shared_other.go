@@ -0,0 +1,8 @@
+//go:build !linux
+
+package extents
+
+// Shared returns ErrUnsupported: only Linux reports shared extents (FIEMAP).
+func Shared(path string) (int64, error) {
+	return 0, ErrUnsupported
+}
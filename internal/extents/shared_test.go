@@ -0,0 +1,25 @@
+package extents
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShared_plainFileSharesNothing(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a")
+	if err := os.WriteFile(p, make([]byte, 64<<10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Shared(p)
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("filesystem does not report extents")
+	}
+	if err != nil || n != 0 {
+		t.Errorf("Shared = %d, %v; want 0 for a file that was just written", n, err)
+	}
+	if _, err := Shared(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Shared of a missing file: want an error")
+	}
+}
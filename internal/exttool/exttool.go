@@ -0,0 +1,94 @@
+// Package exttool builds links that launch user-configured external tools (for example a desktop image
+// compare tool registered for a custom URL scheme) on the files of a duplicate group.
+package exttool
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Placeholders in a tool's URL template. A tool uses either {path} (one file) or both {a} and {b} (a pair).
+const (
+	PlaceholderPath = "{path}"
+	PlaceholderA    = "{a}"
+	PlaceholderB    = "{b}"
+)
+
+// Tool is one configured external tool.
+type Tool struct {
+	Label string
+	Exts  []string // lower-case extensions without the dot; empty means any file
+	URL   string   // template with {path}, or {a} and {b}
+}
+
+// Parse reads tool definitions separated by ";", each "label|ext,ext|url-template", e.g.
+// "Compare|jpg,png|compare://diff?left={a}&right={b};Reveal||file://{path}". An empty ext list (or "*")
+// matches any file. Returns (nil, nil) for an empty string.
+func Parse(s string) ([]Tool, error) {
+	var tools []Tool
+	for _, def := range strings.Split(s, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		parts := strings.Split(def, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("external tool %q: want label|extensions|url", def)
+		}
+		t := Tool{Label: strings.TrimSpace(parts[0]), URL: strings.TrimSpace(parts[2])}
+		if t.Label == "" {
+			return nil, fmt.Errorf("external tool %q: label is empty", def)
+		}
+		hasPath := strings.Contains(t.URL, PlaceholderPath)
+		hasPair := strings.Contains(t.URL, PlaceholderA) && strings.Contains(t.URL, PlaceholderB)
+		if hasPath == hasPair {
+			return nil, fmt.Errorf("external tool %q: url must use either %s or both %s and %s", t.Label, PlaceholderPath, PlaceholderA, PlaceholderB)
+		}
+		if u, err := url.Parse(t.Link("/x", "/y")); err != nil || u.Scheme == "" {
+			return nil, fmt.Errorf("external tool %q: url needs a scheme (e.g. compare://...)", t.Label)
+		}
+		for _, ext := range strings.Split(parts[1], ",") {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext != "" && ext != "*" {
+				t.Exts = append(t.Exts, ext)
+			}
+		}
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+// Pair reports whether the tool takes two files ({a} and {b}) rather than one.
+func (t Tool) Pair() bool {
+	return !strings.Contains(t.URL, PlaceholderPath)
+}
+
+// Matches reports whether the tool applies to the file at path (by extension, case-insensitive).
+func (t Tool) Matches(path string) bool {
+	if len(t.Exts) == 0 {
+		return true
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	for _, e := range t.Exts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Link fills the URL template: {path} with path, or {a} and {b} with path and other. Paths are
+// percent-encoded (slashes kept) so spaces, "?" or "&" in names cannot break the URL.
+func (t Tool) Link(path, other string) string {
+	return strings.NewReplacer(
+		PlaceholderPath, escapePath(path),
+		PlaceholderA, escapePath(path),
+		PlaceholderB, escapePath(other),
+	).Replace(t.URL)
+}
+
+func escapePath(p string) string {
+	return strings.ReplaceAll((&url.URL{Path: p}).EscapedPath(), "&", "%26")
+}
@@ -0,0 +1,40 @@
+package exttool
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tools, err := Parse(" Compare | .JPG, png |compare://diff?l={a}&r={b} ; Reveal||file://{path};")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(tools) != 2 {
+		t.Fatalf("len(tools) = %d, want 2", len(tools))
+	}
+	if c := tools[0]; c.Label != "Compare" || !c.Pair() || len(c.Exts) != 2 || c.Exts[0] != "jpg" {
+		t.Errorf("tools[0] = %+v", c)
+	}
+	if r := tools[1]; r.Pair() || len(r.Exts) != 0 {
+		t.Errorf("tools[1] = %+v", r)
+	}
+	if tools, err := Parse(""); err != nil || tools != nil {
+		t.Errorf("Parse(\"\") = %v, %v; want nil, nil", tools, err)
+	}
+	for _, bad := range []string{"x", "|jpg|a://{path}", "A|jpg|a://{a}", "A|jpg|a://{path}?{a}&{b}", "A||{path}"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) err = nil, want error", bad)
+		}
+	}
+}
+
+func TestTool_MatchesAndLink(t *testing.T) {
+	tools, _ := Parse("Compare|jpg|compare://diff?l={a}&r={b}")
+	c := tools[0]
+	if !c.Matches("/p/IMG.JPG") || c.Matches("/p/a.png") || c.Matches("/p/jpg") {
+		t.Error("Matches: want case-insensitive extension match only")
+	}
+	got := c.Link("/p/a b.jpg", "/q/x&y?.jpg")
+	want := "compare://diff?l=/p/a%20b.jpg&r=/q/x%26y%3F.jpg"
+	if got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+}
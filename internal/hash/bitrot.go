@@ -0,0 +1,102 @@
+package hash
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/ioprio"
+	"golang.org/x/time/rate"
+)
+
+// verifyPageSize is how many candidate files RunVerifyPhase loads per query.
+const verifyPageSize = 1000
+
+// RunVerifyPhase is the bit-rot check of a verification scan (db.SetScanVerify), run after its hash phase:
+// every file whose stored hash predates the scan although the walk found its size and mtime unchanged is read
+// again. A different hash is recorded as a db.BitrotFinding; the stored hash is kept as the reference.
+// Unreadable files are recorded as verify errors. An interrupted run starts over. Uses opts' workers,
+// read throttle and priority.
+func RunVerifyPhase(ctx context.Context, database *sql.DB, scanID int64, opts *HashOptions) error {
+	if err := db.ResetScanVerify(ctx, database, scanID); err != nil {
+		return err
+	}
+	log.Printf("[verify] started for scan %d (%d worker(s))", scanID, opts.workers())
+	var byteLimiter *rate.Limiter
+	var priority ioprio.Settings
+	if opts != nil {
+		byteLimiter = newByteLimiter(opts.MaxBytesPerSecond)
+		priority = opts.Priority
+	}
+	jobs := make(chan db.File, verifyPageSize)
+	var verified, mismatched, failed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ioprio.ApplyToCurrentThread(priority); err != nil {
+				logFileIfThrottled("[verify] could not lower worker priority: %v", err)
+			}
+			for f := range jobs {
+				if ctx.Err() != nil {
+					continue // drain
+				}
+				h, err := HashFileLimited(ctx, f.Path, byteLimiter)
+				if err != nil {
+					if ctx.Err() == nil {
+						failed.Add(1)
+						e := db.ScanError{FileID: &f.ID, Path: f.Path, Phase: db.PhaseVerify, Error: err.Error()}
+						if dbErr := db.InsertScanErrors(ctx, database, scanID, []db.ScanError{e}); dbErr != nil {
+							log.Printf("[verify] record error for %s: %v", f.Path, dbErr)
+						}
+					}
+					continue
+				}
+				verified.Add(1)
+				if h == *f.Hash {
+					continue
+				}
+				mismatched.Add(1)
+				log.Printf("[verify] scan %d: %s changed without a size or mtime change (stored %s, now %s)", scanID, f.Path, *f.Hash, h)
+				finding := db.BitrotFinding{FileID: f.ID, Path: f.Path, Size: f.Size, MTime: f.MTime, ExpectedHash: *f.Hash, ActualHash: h, HashedAt: f.HashedAt}
+				if err := db.InsertBitrotFinding(ctx, database, scanID, finding); err != nil {
+					log.Printf("[verify] record mismatch for %s: %v", f.Path, err)
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	var afterID int64
+	var err error
+	for ctx.Err() == nil {
+		var page []db.File
+		page, err = db.VerifyCandidates(ctx, database, scanID, afterID, verifyPageSize)
+		if err != nil || len(page) == 0 {
+			break
+		}
+		for _, f := range page {
+			select {
+			case jobs <- f:
+			case <-ctx.Done():
+			}
+		}
+		afterID = page[len(page)-1].ID
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("[verify] completed for scan %d in %s: %d files read, %d mismatches, %d errors",
+		scanID, formatDuration(time.Since(start)), verified.Load(), mismatched.Load(), failed.Load())
+	return db.UpdateScanVerifyCompletedAt(ctx, database, scanID, verified.Load())
+}
@@ -0,0 +1,67 @@
+package hash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+func TestRunVerifyPhase_flagsContentChangedWithSameSizeAndMtime(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	folderID, _ := db.GetOrCreateFolderByPath(ctx, database, dir)
+	a := filepath.Join(dir, "a.bin")
+	b := filepath.Join(dir, "b.bin")
+	for _, p := range []string{a, b} {
+		if err := os.WriteFile(p, []byte("same"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	first, _ := db.CreateScan(ctx, database, folderID)
+	addFileToScan(ctx, database, dir, first.ID, a, 4, 100, 1, nil)
+	addFileToScan(ctx, database, dir, first.ID, b, 4, 100, 2, nil)
+	_ = db.UpdateScanCompletedAt(ctx, database, first.ID, 2, 0)
+	if err := RunHashPhase(ctx, database, first.ID, &HashOptions{Workers: 1}); err != nil {
+		t.Fatalf("RunHashPhase: %v", err)
+	}
+
+	// a rots in place (same size, mtime as recorded); b disappears before the verification reads it
+	if err := os.WriteFile(a, []byte("s4me"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Remove(b); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	second, _ := db.CreateScan(ctx, database, folderID)
+	addFileToScan(ctx, database, dir, second.ID, a, 4, 100, 1, nil)
+	addFileToScan(ctx, database, dir, second.ID, b, 4, 100, 2, nil)
+	_ = db.UpdateScanCompletedAt(ctx, database, second.ID, 2, 0)
+	_ = db.SetScanVerify(ctx, database, second.ID)
+	if err := RunHashPhase(ctx, database, second.ID, &HashOptions{Workers: 1}); err != nil {
+		t.Fatalf("RunHashPhase: %v", err)
+	}
+	if err := RunVerifyPhase(ctx, database, second.ID, &HashOptions{Workers: 2}); err != nil {
+		t.Fatalf("RunVerifyPhase: %v", err)
+	}
+
+	findings, err := db.ListBitrotFindings(ctx, database, second.ID, 10)
+	if err != nil {
+		t.Fatalf("ListBitrotFindings: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Path != a || findings[0].ExpectedHash == findings[0].ActualHash {
+		t.Fatalf("findings = %+v, want one mismatch for %s", findings, a)
+	}
+	errs, _ := db.ListScanErrors(ctx, database, second.ID, 10)
+	if len(errs) != 1 || errs[0].Path != b || errs[0].Phase != db.PhaseVerify {
+		t.Errorf("scan errors = %+v, want one verify error for %s", errs, b)
+	}
+	sn, _ := db.GetScan(ctx, database, second.ID)
+	if !sn.Verify || sn.VerifyCompletedAt == nil || sn.VerifiedFileCount == nil || *sn.VerifiedFileCount != 1 {
+		t.Errorf("scan verify = %v, completed %v, verified %v; want completed with 1 file read", sn.Verify, sn.VerifyCompletedAt, sn.VerifiedFileCount)
+	}
+}
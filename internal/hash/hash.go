@@ -1,16 +1,29 @@
 package hash
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
-	"os"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/limits"
+	"golang.org/x/time/rate"
 )
 
-// HashFile reads the file at path and returns its SHA-256 hash as a hex-encoded string.
+// readChunkSize is the read size used when throttling by bytes per second (one limiter token per byte).
+const readChunkSize = 64 * 1024
+
+// HashFile reads the file at path and returns its SHA-256 hash as a hex-encoded string. A virtual path
+// (see archive.Split) is read from inside its archive.
 // The file is streamed (io.Copy) so large files are handled without loading into memory.
 func HashFile(path string) (string, error) {
-	f, err := os.Open(path) // #nosec G304 -- path from our filesystem walk, not user input
+	release, err := limits.AcquireFiles(context.Background(), 1)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	f, err := archive.Open(path)
 	if err != nil {
 		return "", err
 	}
@@ -22,3 +35,61 @@ func HashFile(path string) (string, error) {
 	}
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// HashFileLimited is like HashFile but reads through limiter (tokens are bytes) so total read throughput
+// across all callers sharing the limiter stays under its rate. A nil limiter reads at full speed.
+func HashFileLimited(ctx context.Context, path string, limiter *rate.Limiter) (string, error) {
+	if limiter == nil {
+		return HashFile(path)
+	}
+	release, err := limits.AcquireFiles(ctx, 1)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	f, err := archive.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, &limitedReader{ctx: ctx, r: f, limiter: limiter}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// limitedReader waits on a byte-rate limiter before each read of at most readChunkSize bytes.
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n := len(p)
+	if n > readChunkSize {
+		n = readChunkSize
+	}
+	if b := l.limiter.Burst(); n > b {
+		n = b
+	}
+	if err := l.limiter.WaitN(l.ctx, n); err != nil {
+		return 0, err
+	}
+	return l.r.Read(p[:n])
+}
+
+// newByteLimiter returns a limiter allowing bytesPerSecond with a burst of one read chunk
+// (or the whole rate when it is smaller than a chunk). Returns nil when bytesPerSecond <= 0.
+func newByteLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := readChunkSize
+	if bytesPerSecond < int64(burst) {
+		burst = int(bytesPerSecond)
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
@@ -1,9 +1,11 @@
 package hash
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestHashFile_knownContentReturnsExpectedSHA256(t *testing.T) {
@@ -54,3 +56,55 @@ func TestHashFile_nonexistentPathReturnsError(t *testing.T) {
 		t.Errorf("HashFile on error should return empty string, got %q", got)
 	}
 }
+
+func TestHashFileLimited_sameHashAndThrottles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.bin")
+	content := make([]byte, 3*1024)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	want, _ := HashFile(path)
+
+	// 1 KiB/s with a 1 KiB burst: 3 KiB needs ~2s after the initial burst.
+	start := time.Now()
+	got, err := HashFileLimited(context.Background(), path, newByteLimiter(1024))
+	if err != nil {
+		t.Fatalf("HashFileLimited: %v", err)
+	}
+	if got != want {
+		t.Errorf("HashFileLimited = %q, want %q", got, want)
+	}
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Errorf("1 KiB/s over 3 KiB: elapsed %v, want >= 1.5s", elapsed)
+	}
+}
+
+func TestHashFileLimited_nilLimiterMatchesHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	got, err := HashFileLimited(context.Background(), path, nil)
+	if err != nil {
+		t.Fatalf("HashFileLimited: %v", err)
+	}
+	if want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("HashFileLimited(nil) = %q, want %q", got, want)
+	}
+}
+
+func BenchmarkHashFile(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "data.bin")
+	content := make([]byte, 8<<20)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		b.Fatalf("write file: %v", err)
+	}
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := HashFile(path); err != nil {
+			b.Fatalf("HashFile: %v", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package hash
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// RehashResult is the outcome of reading files again to replace their stored hashes.
+type RehashResult struct {
+	Same    []string          // paths whose bytes still have the stored hash
+	Changed map[string]string // path -> the other hash its bytes have now (the file left its group)
+	Errors  map[string]error
+}
+
+// OK reports whether every file was read and still has its stored hash.
+func (r *RehashResult) OK() bool {
+	return len(r.Changed) == 0 && len(r.Errors) == 0
+}
+
+// Rehash reads each file and stores the hash of its bytes as computed (db.ReplaceFileHash), replacing a hash
+// that was reused from a hardlink, an earlier scan or a moved file without reading the file itself. A file whose
+// bytes have another hash moves to that content's group. Files that cannot be read keep their stored hash and are
+// reported in Errors; only a database failure stops the run.
+func Rehash(ctx context.Context, database *sql.DB, files []db.File) (*RehashResult, error) {
+	res := &RehashResult{Changed: make(map[string]string), Errors: make(map[string]error)}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			res.Errors[f.Path] = ctx.Err()
+			continue
+		}
+		h, err := HashFileLimited(ctx, f.Path, nil)
+		if err != nil {
+			res.Errors[f.Path] = err
+			continue
+		}
+		if err := db.ReplaceFileHash(ctx, database, f.ID, h, time.Now()); err != nil {
+			return res, err
+		}
+		if f.Hash != nil && *f.Hash == h {
+			res.Same = append(res.Same, f.Path)
+		} else {
+			res.Changed[f.Path] = h
+		}
+	}
+	return res, nil
+}
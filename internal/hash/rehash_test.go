@@ -0,0 +1,57 @@
+package hash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+func TestRehash_replacesReusedHashes(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	folderID, _ := db.GetOrCreateFolderByPath(ctx, database, dir)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	for name, content := range map[string]string{"same.txt": "abc", "changed.txt": "xyz"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		addFileToScan(ctx, database, dir, scan.ID, path, 3, 1, 0, nil)
+	}
+	want, _ := HashFile(filepath.Join(dir, "same.txt"))
+	files, _ := db.GetFilesByScanID(ctx, database, scan.ID)
+	var stored []db.FileHash
+	for _, f := range files {
+		stored = append(stored, db.FileHash{FileID: f.ID, Hash: want, Source: db.HashFromPrevious})
+	}
+	if err := db.UpdateFileHashes(ctx, database, stored, time.Now()); err != nil {
+		t.Fatalf("UpdateFileHashes: %v", err)
+	}
+	_ = db.MarkFilesVerified(ctx, database, []int64{files[0].ID, files[1].ID}, time.Now())
+	files, _ = db.GetFilesByScanID(ctx, database, scan.ID)
+
+	res, err := Rehash(ctx, database, files)
+	if err != nil {
+		t.Fatalf("Rehash: %v", err)
+	}
+	changed := filepath.Join(dir, "changed.txt")
+	if len(res.Same) != 1 || len(res.Changed) != 1 || res.Changed[changed] == "" || res.Changed[changed] == want || res.OK() {
+		t.Errorf("Rehash = %+v; want same.txt unchanged and changed.txt with its own hash", res)
+	}
+	evidence, _ := db.HashEvidenceByFileID(ctx, database, []int64{files[0].ID, files[1].ID})
+	verified, _ := db.VerifiedAtByFileID(ctx, database, []int64{files[0].ID, files[1].ID})
+	for _, f := range files {
+		if evidence[f.ID].Source != db.HashComputed {
+			t.Errorf("%s: source %q after re-hashing, want computed", f.Path, evidence[f.ID].Source)
+		}
+		if _, ok := verified[f.ID]; ok != (f.Path != changed) {
+			t.Errorf("%s: verified = %v; want kept only where the hash did not change", f.Path, ok)
+		}
+	}
+}
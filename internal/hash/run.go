@@ -3,6 +3,7 @@ package hash
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
@@ -10,14 +11,27 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/config"
 	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/extents"
+	"github.com/eargollo/ditto/internal/ioprio"
+	"github.com/eargollo/ditto/internal/limits"
 	"golang.org/x/time/rate"
 )
 
 const hashProgressLogInterval = 50   // log "N/M files" every this many files
 const slowOpThreshold = 100 * time.Millisecond // log when a single DB op exceeds this (for investigation)
-const hashJobChannelCap = 1000       // bounded channel for producer-consumer; backpressure if consumers are slow
+const hashJobChannelCap = 1000       // files queued for hash workers at most, across workers; backpressure if consumers are slow
 const fileLogInterval = 5 * time.Second // at most one per-file log line every this long (avoid flooding)
+const pausePollInterval = time.Second   // how often a running hash phase checks the scan's pause flag
+const progressSaveInterval = 5 * time.Second // how often a running hash phase writes its counts for the web UI
+const hashWriteBatch = 100                   // hashes a worker stores per UPDATE
+const hashWriteInterval = time.Second        // a worker stores its hashes at least this often, even with fewer
+
+// ErrPaused is returned by RunHashPhase when it stopped because a pause was requested (db.RequestScanHashPause).
+// Files not yet hashed stay 'pending'; clear the flag and call RunHashPhase again to resume.
+var ErrPaused = errors.New("hash phase paused")
 
 func logSlowIf(op string, start time.Time) {
 	if d := time.Since(start); d > slowOpThreshold {
@@ -43,10 +57,29 @@ func logFileIfThrottled(format string, args ...interface{}) {
 
 // HashOptions configures the hash phase. Nil means defaults (single worker, no throttle).
 type HashOptions struct {
-	Workers             int // number of workers (default 1)
-	MaxHashesPerSecond  int // 0 = no throttle
+	Workers            int             // number of workers (default 1)
+	MaxHashesPerSecond int             // 0 = no throttle
+	MaxBytesPerSecond  int64           // cap on bytes read per second across all workers; 0 = no throttle
+	Priority           ioprio.Settings // lower CPU/I/O priority of worker threads (Linux); zero = unchanged
+	MaxAttempts        int             // reads of a file before it is marked 'failed' (default 3)
+	RetryBackoff       time.Duration   // wait before the second read; doubles for each further one (default 1s)
+	ReuseMoved         string          // config.HashReuse*: reuse the hash of a file that moved to a new inode; "" = off
+}
+
+// Retry defaults for files that cannot be read.
+const (
+	defaultMaxAttempts  = 3
+	defaultRetryBackoff = time.Second
+)
+
+// readError is a failure to read the file being hashed (as opposed to a database error, which aborts the phase).
+type readError struct {
+	err error
 }
 
+func (e *readError) Error() string { return e.err.Error() }
+func (e *readError) Unwrap() error { return e.err }
+
 func (o *HashOptions) workers() int {
 	if o == nil || o.Workers <= 0 {
 		return 1
@@ -54,6 +87,27 @@ func (o *HashOptions) workers() int {
 	return o.Workers
 }
 
+func (o *HashOptions) maxAttempts() int {
+	if o == nil || o.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return o.MaxAttempts
+}
+
+func (o *HashOptions) retryBackoff() time.Duration {
+	if o == nil || o.RetryBackoff <= 0 {
+		return defaultRetryBackoff
+	}
+	return o.RetryBackoff
+}
+
+func (o *HashOptions) reuseMoved() string {
+	if o == nil || o.ReuseMoved == "" {
+		return config.HashReuseOff
+	}
+	return o.ReuseMoved
+}
+
 func (o *HashOptions) maxHashesPerSecond() int {
 	if o == nil {
 		return 0
@@ -61,13 +115,27 @@ func (o *HashOptions) maxHashesPerSecond() int {
 	return o.MaxHashesPerSecond
 }
 
-// RunHashPhase runs the hash phase for the given scan: resets any orphaned 'hashing' to 'pending',
-// sets hash_started_at, then runs a producer-consumer pipeline (one query streams pending jobs to a channel,
+// RunHashPhase runs the hash phase for the given scan: resets any orphaned 'hashing' to 'pending', marks size groups
+// made only of hardlinks to one inode as 'hardlink' (never read; see db.MarkHardlinkOnlyGroups), sets hash_started_at,
+// then runs a producer-consumer pipeline (one query streams pending jobs to a channel,
 // N workers process them). Sets hash_completed_at when done. Respects context cancellation.
+// If the scan is paused (before or during the run), workers finish their current file and ErrPaused is returned.
 func RunHashPhase(ctx context.Context, database *sql.DB, scanID int64, opts *HashOptions) error {
+	if paused, err := db.IsScanHashPaused(ctx, database, scanID); err != nil {
+		return err
+	} else if paused {
+		return ErrPaused
+	}
 	if err := db.ResetHashStatusHashingToPending(ctx, database, scanID); err != nil {
 		return err
 	}
+	hardlinks, err := db.MarkHardlinkOnlyGroups(ctx, database, scanID)
+	if err != nil {
+		return err
+	}
+	if hardlinks > 0 {
+		log.Printf("[hash] scan %d: skipping %d files in hardlink-only size groups", scanID, hardlinks)
+	}
 	if err := db.UpdateScanHashStartedAt(ctx, database, scanID); err != nil {
 		return err
 	}
@@ -75,38 +143,221 @@ func RunHashPhase(ctx context.Context, database *sql.DB, scanID int64, opts *Has
 	n := opts.workers()
 	log.Printf("[hash] phase started for scan %d (%d worker(s), %d files to hash)", scanID, n, total)
 	phaseStart := time.Now().UTC()
-	var completed, reusedCount, hashErrorCount atomic.Int64
-	err := runHashPhaseProducerConsumer(ctx, database, scanID, total, &completed, &reusedCount, &hashErrorCount, phaseStart, opts, n)
+	var completed, reusedCount, hashErrorCount, readBytes atomic.Int64
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	paused := watchPause(watchCtx, database, scanID)
+	go saveProgress(watchCtx, database, scanID, total, &completed, &readBytes)
+	err = runHashPhaseProducerConsumer(ctx, database, scanID, total, &completed, &reusedCount, &hashErrorCount, &readBytes, phaseStart, opts, n, paused)
+	stopWatch()
+	// Record bytes read even when paused or failed: a resumed phase adds to them, and PlanHashPhase divides them
+	// by the whole phase duration.
+	if rb := readBytes.Load(); rb > 0 {
+		if rbErr := db.AddScanHashReadBytes(ctx, database, scanID, rb); rbErr != nil {
+			log.Printf("[hash] could not record bytes read for scan %d: %v", scanID, rbErr)
+		}
+	}
 	if err != nil {
 		log.Printf("[hash] phase failed for scan %d: %v", scanID, err)
 		return err
 	}
+	select {
+	case <-paused:
+		log.Printf("[hash] phase paused for scan %d after %d files", scanID, completed.Load())
+		return ErrPaused
+	default:
+	}
+	recordSharedExtents(ctx, database, scanID)
 	fileCount, byteCount, err := db.GetHashedFileCountAndBytes(ctx, database, scanID)
 	if err != nil {
 		return err
 	}
+	if err := db.SnapshotScanHashes(ctx, database, scanID); err != nil {
+		return err
+	}
 	log.Printf("[hash] phase completed for scan %d: %d files, %d bytes, %d reused, %d errors", scanID, fileCount, byteCount, reusedCount.Load(), hashErrorCount.Load())
-	return db.UpdateScanHashCompletedAt(ctx, database, scanID, fileCount, byteCount, reusedCount.Load(), hashErrorCount.Load())
+	if err := db.UpdateScanHashCompletedAt(ctx, database, scanID, fileCount, byteCount, reusedCount.Load(), hashErrorCount.Load()); err != nil {
+		return err
+	}
+	refreshSummary(ctx, database, scanID)
+	return nil
 }
 
-// runHashPhaseProducerConsumer: one producer sends pending jobs (from a single SELECT) to a bounded channel;
-// N consumers process jobs and update the DB. Producer closes channel when done; consumers exit when channel is closed.
-func runHashPhaseProducerConsumer(ctx context.Context, database *sql.DB, scanID int64, total int64, completed, reusedCount, hashErrorCount *atomic.Int64, phaseStart time.Time, opts *HashOptions, numWorkers int) error {
-	jobs := make(chan *db.File, hashJobChannelCap)
-	errCh := make(chan error, 1) // first error from producer or any consumer
+// refreshSummary stores the scan's duplicate statistics. A failure only costs speed: readers compute missing
+// summaries on demand (db.ScanSummaryOrRefresh).
+func refreshSummary(ctx context.Context, database *sql.DB, scanID int64) {
+	if _, err := db.RefreshScanSummary(ctx, database, scanID); err != nil {
+		log.Printf("[hash] could not store summary for scan %d: %v", scanID, err)
+	}
+}
 
-	// Producer: stream pending jobs from one query into the channel; close when done or on error.
+// recordSharedExtents stores, for each of the scan's files that has a duplicate, the bytes it shares with other
+// files (see package extents), so db.ProjectSavings does not count reflinked or block-deduplicated copies. It is
+// best-effort: a device whose filesystem does not report extents is not asked again, and errors are only logged.
+func recordSharedExtents(ctx context.Context, database *sql.DB, scanID int64) {
+	unsupported := make(map[int64]bool) // device id (-1 = unknown) -> no FIEMAP
+	var measured int
+	err := db.ForEachDuplicateFile(ctx, database, scanID, func(id int64, path string, deviceID *int64) error {
+		dev := int64(-1)
+		if deviceID != nil {
+			dev = *deviceID
+		}
+		if unsupported[dev] || archive.IsVirtual(path) {
+			return ctx.Err()
+		}
+		shared, err := extents.Shared(path)
+		if errors.Is(err, extents.ErrUnsupported) {
+			unsupported[dev] = true
+			return nil
+		}
+		if err != nil {
+			logFileIfThrottled("[hash] could not read extents of %s: %v", path, err)
+			return nil
+		}
+		measured++
+		return db.UpdateFileSharedBytes(ctx, database, id, shared)
+	})
+	if err != nil {
+		log.Printf("[hash] could not record shared extents for scan %d: %v", scanID, err)
+		return
+	}
+	if measured > 0 {
+		log.Printf("[hash] scan %d: read the extents of %d duplicate files", scanID, measured)
+	}
+}
+
+// SkipHashPhase completes a scan's hash phase without reading any file (e.g. after reviewing db.PlanHashPhase):
+// the scan records only hashes already known, and its pending files stay pending for a later scan to hash.
+// Hardlink-only groups are still re-evaluated, so files this scan found send other folders' groups back to pending.
+func SkipHashPhase(ctx context.Context, database *sql.DB, scanID int64) error {
+	if _, err := db.MarkHardlinkOnlyGroups(ctx, database, scanID); err != nil {
+		return err
+	}
+	if err := db.UpdateScanHashStartedAt(ctx, database, scanID); err != nil {
+		return err
+	}
+	fileCount, byteCount, err := db.GetHashedFileCountAndBytes(ctx, database, scanID)
+	if err != nil {
+		return err
+	}
+	if err := db.SnapshotScanHashes(ctx, database, scanID); err != nil {
+		return err
+	}
+	if err := db.UpdateScanHashCompletedAt(ctx, database, scanID, fileCount, byteCount, 0, 0); err != nil {
+		return err
+	}
+	refreshSummary(ctx, database, scanID)
+	log.Printf("[hash] phase skipped for scan %d: %d files already hashed", scanID, fileCount)
+	return db.ClearScanHashPause(ctx, database, scanID)
+}
+
+// watchPause polls the scan's pause flag every pausePollInterval and closes the returned channel once a pause
+// is requested. Polling the DB (rather than an in-process signal) lets "ditto pause" stop a scan run by the server.
+func watchPause(ctx context.Context, database *sql.DB, scanID int64) <-chan struct{} {
+	paused := make(chan struct{})
 	go func() {
-		defer close(jobs)
+		ticker := time.NewTicker(pausePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p, err := db.IsScanHashPaused(ctx, database, scanID)
+				if err != nil {
+					continue // transient; try again next tick
+				}
+				if p {
+					close(paused)
+					return
+				}
+			}
+		}
+	}()
+	return paused
+}
+
+// saveProgress writes the phase's counts to the scan row right away and then every progressSaveInterval until
+// ctx is done, so the web UI can show throughput and an ETA (see db.HashProgress). Failures are only logged.
+func saveProgress(ctx context.Context, database *sql.DB, scanID, total int64, completed, readBytes *atomic.Int64) {
+	ticker := time.NewTicker(progressSaveInterval)
+	defer ticker.Stop()
+	for {
+		done := min(completed.Load(), total)
+		if err := db.UpdateScanHashProgress(ctx, database, scanID, total, done, readBytes.Load()); err != nil && ctx.Err() == nil {
+			logFileIfThrottled("[hash] could not save progress for scan %d: %v", scanID, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// errConsumerFailed stops the hash phase's producer once a consumer has returned an error (sent on errCh).
+var errConsumerFailed = errors.New("hash worker failed")
+
+// inodeKey identifies a file's content by inode and device, as matched by db.HashForInode.
+type inodeKey struct {
+	inode     int64
+	deviceID  int64
+	hasDevice bool
+}
+
+func inodeKeyOf(f *db.File) inodeKey {
+	if f.DeviceID == nil {
+		return inodeKey{inode: f.Inode}
+	}
+	return inodeKey{inode: f.Inode, deviceID: *f.DeviceID, hasDevice: true}
+}
+
+// runHashPhaseProducerConsumer: one producer streams pending jobs (from a single SELECT, ordered by size) and sends
+// every file of a size group to the same worker, the one with the shortest queue when the group starts; N consumers
+// process their files and update the DB. Each worker's queue is a bounded channel of files (hashJobChannelCap shared
+// among them), so memory stays bounded however large a group is. Producer closes the channels when done; consumers
+// exit when theirs is closed. Because a group (and so every hardlink of an inode, which share a size) stays on one
+// worker, the worker reuses hashes it computed within the group without asking the DB again; it starts a new group
+// when the size changes. When paused is closed, the producer stops and each consumer returns after its current job;
+// unprocessed jobs stay pending.
+func runHashPhaseProducerConsumer(ctx context.Context, database *sql.DB, scanID int64, total int64, completed, reusedCount, hashErrorCount, readBytes *atomic.Int64, phaseStart time.Time, opts *HashOptions, numWorkers int, paused <-chan struct{}) error {
+	jobs := make([]chan *db.File, numWorkers) // one queue per worker
+	for i := range jobs {
+		jobs[i] = make(chan *db.File, max(hashJobChannelCap/numWorkers, 1))
+	}
+	errCh := make(chan error, 1)  // first error from producer or any consumer
+	failed := make(chan struct{}) // closed when a consumer stops on an error, so the producer stops feeding it
+	var failOnce sync.Once
+
+	// Producer: stream pending jobs from one query and send each to its size group's worker; close when done or on error.
+	go func() {
+		defer func() {
+			for _, ch := range jobs {
+				close(ch)
+			}
+		}()
+		var worker chan *db.File
+		size := int64(-1)
 		err := db.ForEachPendingHashJob(ctx, database, scanID, func(f *db.File) error {
+			if worker == nil || f.Size != size {
+				worker, size = jobs[0], f.Size
+				for _, ch := range jobs[1:] {
+					if len(ch) < len(worker) {
+						worker = ch
+					}
+				}
+			}
 			select {
-			case jobs <- f:
+			case worker <- f:
 				return nil
+			case <-paused:
+				return ErrPaused
+			case <-failed:
+				return errConsumerFailed
 			case <-ctx.Done():
 				return ctx.Err()
 			}
 		})
-		if err != nil && err != context.Canceled {
+		if err != nil && err != context.Canceled && err != ErrPaused && err != errConsumerFailed {
 			select {
 			case errCh <- err:
 			default:
@@ -117,36 +368,88 @@ func runHashPhaseProducerConsumer(ctx context.Context, database *sql.DB, scanID
 	// Consumers: read from channel until closed; process each job.
 	var wg sync.WaitGroup
 	now := time.Now().UTC()
-	var limiter *rate.Limiter
-	if opts != nil && opts.MaxHashesPerSecond > 0 {
-		limiter = rate.NewLimiter(rate.Limit(opts.MaxHashesPerSecond), 1)
+	var limiter, byteLimiter *rate.Limiter
+	var priority ioprio.Settings
+	if opts != nil {
+		if opts.MaxHashesPerSecond > 0 {
+			limiter = rate.NewLimiter(rate.Limit(opts.MaxHashesPerSecond), 1)
+		}
+		byteLimiter = newByteLimiter(opts.MaxBytesPerSecond)
+		priority = opts.Priority
 	}
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go func() {
+		go func(queue <-chan *db.File) {
 			defer wg.Done()
-			for job := range jobs {
+			if err := ioprio.ApplyToCurrentThread(priority); err != nil {
+				logFileIfThrottled("[hash] could not lower worker priority: %v", err)
+			}
+			w := &hashWriter{database: database, now: now}
+			defer func() {
+				// Store the last results, also when pausing or stopping on another file's error.
+				if err := w.flush(ctx); err != nil && ctx.Err() == nil {
+					select {
+					case errCh <- err:
+					default:
+					}
+				}
+			}()
+			var known map[inodeKey]string // hashes computed in the current size group, by inode
+			size := int64(-1)
+			for job := range queue {
+				if job.Size != size {
+					known, size = make(map[inodeKey]string), job.Size
+				}
 				if ctx.Err() != nil {
 					return
 				}
-				reused, err := processClaimedJob(ctx, database, job, opts, now, limiter)
+				select {
+				case <-paused:
+					return
+				default:
+				}
+				reused, attempts, err := hashWithRetry(ctx, database, job, opts, w, limiter, byteLimiter, known, paused)
+				var rerr *readError
+				if errors.As(err, &rerr) && ctx.Err() == nil {
+					// Out of attempts: mark the file failed, record the error and move on to the next file.
+					if hashErrorCount != nil {
+						hashErrorCount.Add(1)
+					}
+					if dbErr := db.MarkFileHashFailed(ctx, database, job.ID, attempts, err.Error()); dbErr != nil {
+						err = dbErr
+					} else {
+						recordHashError(ctx, database, scanID, job, err)
+						progressLog(completed, total, phaseStart)
+						continue
+					}
+				}
+				if errors.Is(err, ErrPaused) {
+					_ = db.ResetFileHashStatusToPending(ctx, database, job.ID)
+					return
+				}
 				if err != nil {
 					if hashErrorCount != nil {
 						hashErrorCount.Add(1)
 					}
 					_ = db.ResetFileHashStatusToPending(ctx, database, job.ID) // return to queue so it can be retried
+					if ctx.Err() == nil {
+						recordHashError(ctx, database, scanID, job, err)
+					}
 					select {
 					case errCh <- err:
 					default:
 					}
+					failOnce.Do(func() { close(failed) })
 					return
 				}
 				if reused && reusedCount != nil {
 					reusedCount.Add(1)
+				} else if !reused && readBytes != nil {
+					readBytes.Add(job.Size)
 				}
 				progressLog(completed, total, phaseStart)
 			}
-		}()
+		}(jobs[i])
 	}
 
 	wg.Wait()
@@ -159,8 +462,13 @@ func runHashPhaseProducerConsumer(ctx context.Context, database *sql.DB, scanID
 }
 
 // processClaimedJob hashes the file (or reuses inode/previous hash). Returns (reused, nil) on success, (false, err) on error.
-func processClaimedJob(ctx context.Context, database *sql.DB, job *db.File, opts *HashOptions, now time.Time, limiter *rate.Limiter) (reused bool, err error) {
-	// Same-scan inode reuse (hardlink)
+// known holds the hashes already set in the job's size group by inode; the job's hash is added to it on success.
+func processClaimedJob(ctx context.Context, database *sql.DB, job *db.File, opts *HashOptions, w *hashWriter, limiter, byteLimiter *rate.Limiter, known map[inodeKey]string) (reused bool, err error) {
+	if h, ok := known[inodeKeyOf(job)]; ok {
+		logFileIfThrottled("[hash] reused (inode) %s [%s]", job.Path, filepath.Base(job.Path))
+		return true, setHash(ctx, w, job, h, db.HashFromInode, known)
+	}
+	// Same-scan inode reuse (hardlink) from an earlier run of the phase
 	t0 := time.Now()
 	h, err := db.HashForInode(ctx, database, job.ScanID, job.Inode, job.DeviceID)
 	logSlowIf("HashForInode", t0)
@@ -169,10 +477,7 @@ func processClaimedJob(ctx context.Context, database *sql.DB, job *db.File, opts
 	}
 	if h != "" {
 		logFileIfThrottled("[hash] reused (inode) %s [%s]", job.Path, filepath.Base(job.Path))
-		t1 := time.Now()
-		err := db.UpdateFileHash(ctx, database, job.ID, h, now)
-		logSlowIf("UpdateFileHash", t1)
-		return true, err
+		return true, setHash(ctx, w, job, h, db.HashFromInode, known)
 	}
 	// Previous-scan unchanged file reuse
 	t2 := time.Now()
@@ -183,32 +488,120 @@ func processClaimedJob(ctx context.Context, database *sql.DB, job *db.File, opts
 	}
 	if h != "" {
 		logFileIfThrottled("[hash] reused (unchanged) %s [%s]", job.Path, filepath.Base(job.Path))
+		return true, setHash(ctx, w, job, h, db.HashFromPrevious, known)
+	}
+	// Moved file reuse: a new inode with the size and mtime of a file hashed elsewhere
+	if level := opts.reuseMoved(); level != config.HashReuseOff {
+		name := ""
+		if level == config.HashReuseSameName {
+			name = filepath.Base(job.Path)
+		}
 		t3 := time.Now()
-		err := db.UpdateFileHash(ctx, database, job.ID, h, now)
-		logSlowIf("UpdateFileHash", t3)
-		return true, err
+		h, err = db.HashForMovedFile(ctx, database, job.ID, job.Size, job.MTime, name)
+		logSlowIf("HashForMovedFile", t3)
+		if err != nil {
+			return false, err
+		}
+		if h != "" {
+			logFileIfThrottled("[hash] reused (moved) %s [%s]", job.Path, filepath.Base(job.Path))
+			return true, setHash(ctx, w, job, h, db.HashFromMoved, known)
+		}
 	}
 	// Throttle before reading (Step 6)
+	if err := limits.ThrottleCPU(ctx); err != nil {
+		return false, err
+	}
 	if limiter != nil {
 		if err := limiter.Wait(ctx); err != nil {
 			return false, err
 		}
 	}
 	logFileIfThrottled("[hash] hashing %s [%s] (%d bytes)", job.Path, filepath.Base(job.Path), job.Size)
-	h, err = HashFile(job.Path)
+	h, err = HashFileLimited(ctx, job.Path, byteLimiter)
 	if err != nil {
 		logFileIfThrottled("[hash] failed %s [%s]: %v", job.Path, filepath.Base(job.Path), err)
-		return false, err
+		return false, &readError{err: err}
 	}
 	logFileIfThrottled("[hash] hashed %s [%s]", job.Path, filepath.Base(job.Path))
-	t4 := time.Now()
-	err = db.UpdateFileHash(ctx, database, job.ID, h, now)
-	logSlowIf("UpdateFileHash", t4)
-	return false, err
+	return false, setHash(ctx, w, job, h, db.HashComputed, known)
+}
+
+// hashWithRetry runs processClaimedJob and, when the file cannot be read, tries again up to opts.maxAttempts()
+// times with doubling backoff (a NAS or USB disk that hiccups). Returns the number of attempts made; other errors
+// are returned at once. ErrPaused is returned when the scan is paused while waiting to retry.
+func hashWithRetry(ctx context.Context, database *sql.DB, job *db.File, opts *HashOptions, w *hashWriter, limiter, byteLimiter *rate.Limiter, known map[inodeKey]string, paused <-chan struct{}) (reused bool, attempts int, err error) {
+	for attempts = 1; ; attempts++ {
+		reused, err = processClaimedJob(ctx, database, job, opts, w, limiter, byteLimiter, known)
+		var rerr *readError
+		if !errors.As(err, &rerr) || attempts >= opts.maxAttempts() || ctx.Err() != nil {
+			return reused, attempts, err
+		}
+		wait := opts.retryBackoff() << (attempts - 1)
+		log.Printf("[hash] read %s failed (attempt %d of %d), retrying in %v: %v", job.Path, attempts, opts.maxAttempts(), wait, err)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false, attempts, ctx.Err()
+		case <-paused:
+			t.Stop()
+			return false, attempts, ErrPaused
+		case <-t.C:
+		}
+	}
+}
+
+// recordHashError adds the job's failure to the scan's error list.
+func recordHashError(ctx context.Context, database *sql.DB, scanID int64, job *db.File, err error) {
+	e := db.ScanError{FileID: &job.ID, Path: job.Path, Phase: db.PhaseHash, Error: err.Error()}
+	if dbErr := db.InsertScanErrors(ctx, database, scanID, []db.ScanError{e}); dbErr != nil {
+		log.Printf("[hash] record error for %s: %v", job.Path, dbErr)
+	}
+}
+
+// setHash queues the job's hash, obtained from source (db.HashComputed, ...), to be stored and remembers it for
+// the job's inode in known.
+func setHash(ctx context.Context, w *hashWriter, job *db.File, h, source string, known map[inodeKey]string) error {
+	known[inodeKeyOf(job)] = h
+	return w.add(ctx, job.ID, h, source)
+}
+
+// hashWriter stores a worker's hashes hashWriteBatch at a time, so files whose hash is reused (no read) cost a
+// fraction of a round-trip each. Until flushed, a hash is only in the worker's known map: the file stays 'pending'
+// and is hashed again if the phase stops before then.
+type hashWriter struct {
+	database *sql.DB
+	now      time.Time
+	pending  []db.FileHash
+	since    time.Time // when the oldest pending hash was queued
+}
+
+// add queues the file's hash and stores the queue when it is full or has waited hashWriteInterval.
+func (w *hashWriter) add(ctx context.Context, fileID int64, h, source string) error {
+	if len(w.pending) == 0 {
+		w.since = time.Now()
+	}
+	w.pending = append(w.pending, db.FileHash{FileID: fileID, Hash: h, Source: source})
+	if len(w.pending) < hashWriteBatch && time.Since(w.since) < hashWriteInterval {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+// flush stores the queued hashes.
+func (w *hashWriter) flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	t := time.Now()
+	err := db.UpdateFileHashes(ctx, w.database, w.pending, w.now)
+	logSlowIf("UpdateFileHashes", t)
+	w.pending = w.pending[:0]
+	return err
 }
 
 // progressLog logs "N/M files (X%)" and optionally ETA every hashProgressLogInterval or when done.
-// Rate = n/elapsed from start; remaining = (total-n)/rate; ETA = now+remaining. All values kept non-negative.
+// The estimate is db.HashProgress's (rate from start, remaining at that rate), as shown on the web progress page.
 func progressLog(completed *atomic.Int64, total int64, phaseStart time.Time) {
 	if total <= 0 {
 		return
@@ -218,44 +611,21 @@ func progressLog(completed *atomic.Int64, total int64, phaseStart time.Time) {
 		return
 	}
 	// Cap at total so we never show >100% or negative remaining when n races past total.
-	displayN := n
-	if displayN > total {
-		displayN = total
-	}
-	pct := float64(100) * float64(displayN) / float64(total)
-	msg := fmt.Sprintf("[hash] progress: %d/%d files (%.1f%%)", displayN, total, pct)
-	elapsed := time.Since(phaseStart)
-	if displayN >= total {
-		msg += fmt.Sprintf(" | done in %s", formatDuration(elapsed))
+	p := db.HashProgress{Total: total, Done: min(n, total), StartedAt: phaseStart, UpdatedAt: time.Now().UTC()}
+	msg := fmt.Sprintf("[hash] progress: %d/%d files (%.1f%%)", p.Done, total, p.Percent())
+	if p.Remaining() == 0 {
+		msg += fmt.Sprintf(" | done in %s", formatDuration(p.Elapsed()))
 		log.Print(msg)
 		return
 	}
-	// Mid-run: rate from start, extrapolate remaining and ETA (all positive).
-	if displayN <= 0 || elapsed <= time.Second {
+	// Mid-run: no estimate until there is a rate.
+	remaining := p.ETA()
+	if p.Done <= 0 || remaining <= 0 {
 		log.Print(msg)
 		return
 	}
-	elapsedSec := elapsed.Seconds()
-	if elapsedSec <= 0 {
-		log.Print(msg)
-		return
-	}
-	rate := float64(displayN) / elapsedSec
-	if rate <= 0 {
-		log.Print(msg)
-		return
-	}
-	remainingSec := float64(total-displayN) / rate
-	if remainingSec < 0 {
-		remainingSec = 0
-	}
-	remaining := time.Duration(remainingSec * float64(time.Second))
-	if remaining < 0 {
-		remaining = 0
-	}
-	eta := time.Now().Add(remaining)
 	msg += fmt.Sprintf(" | elapsed %s | remaining ~%s | ETA ~%s",
-		formatDuration(elapsed), formatDuration(remaining), formatETA(eta))
+		formatDuration(p.Elapsed()), formatDuration(remaining), formatETA(time.Now().Add(remaining)))
 	log.Print(msg)
 }
 
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/eargollo/ditto/internal/config"
 	"github.com/eargollo/ditto/internal/db"
 )
 
@@ -202,28 +203,96 @@ func TestRunHashPhase_hardlinkReuseOneRead(t *testing.T) {
 	if err := os.Link(path1, path2); err != nil {
 		t.Skipf("hardlink not supported: %v", err)
 	}
+	// A separate copy of the same size, so the group is not hardlinks only and gets hashed.
+	path3 := filepath.Join(dir, "c.txt")
+	if err := os.WriteFile(path3, []byte("x"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
 	abs1, _ := filepath.Abs(path1)
 	abs2, _ := filepath.Abs(path2)
+	abs3, _ := filepath.Abs(path3)
 	info1, _ := os.Stat(path1)
 	info2, _ := os.Stat(path2)
+	info3, _ := os.Stat(path3)
 	inode1 := inodeOf(info1)
 	inode2 := inodeOf(info2)
 	dev := deviceOf(info1)
 	addFileToScan(ctx, database, dir, scan.ID, abs1, 1, 1, inode1, dev)
 	addFileToScan(ctx, database, dir, scan.ID, abs2, 1, 2, inode2, dev)
-	db.UpdateScanCompletedAt(ctx, database, scan.ID, 2, 0)
+	addFileToScan(ctx, database, dir, scan.ID, abs3, 1, 3, inodeOf(info3), deviceOf(info3))
+	db.UpdateScanCompletedAt(ctx, database, scan.ID, 3, 0)
 
 	if err := RunHashPhase(ctx, database, scan.ID, nil); err != nil {
 		t.Fatalf("RunHashPhase: %v", err)
 	}
 
 	files, _ := db.GetFilesByScanID(ctx, database, scan.ID)
-	if len(files) != 2 {
-		t.Fatalf("want 2 files, got %d", len(files))
+	if len(files) != 3 {
+		t.Fatalf("want 3 files, got %d", len(files))
 	}
 	if files[0].Hash == nil || files[1].Hash == nil || *files[0].Hash != *files[1].Hash {
 		t.Errorf("hardlinks should have same hash: %v vs %v", files[0].Hash, files[1].Hash)
 	}
+	sn, _ := db.GetScan(ctx, database, scan.ID)
+	if sn.HashReusedCount == nil || *sn.HashReusedCount != 1 {
+		t.Errorf("HashReusedCount = %v, want 1 (second link reuses the first one's hash)", sn.HashReusedCount)
+	}
+	evidence, err := db.HashEvidenceByFileID(ctx, database, []int64{files[0].ID, files[1].ID, files[2].ID})
+	if err != nil {
+		t.Fatalf("HashEvidenceByFileID: %v", err)
+	}
+	sources := map[string]int{}
+	for _, f := range files {
+		e := evidence[f.ID]
+		sources[e.Source]++
+		wantLinks := 1
+		if filepath.Base(f.Path) == "c.txt" {
+			wantLinks = 0
+		}
+		if e.Hardlinks != wantLinks {
+			t.Errorf("%s: Hardlinks = %d, want %d", f.Path, e.Hardlinks, wantLinks)
+		}
+	}
+	if sources[db.HashComputed] != 2 || sources[db.HashFromInode] != 1 {
+		t.Errorf("hash sources = %v, want 2 computed and the second link from its inode", sources)
+	}
+}
+
+func TestRunHashPhase_hardlinkOnlyGroupNotRead(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	folderID, _ := db.GetOrCreateFolderByPath(ctx, database, dir)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	path1 := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path1, []byte("x"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	path2 := filepath.Join(dir, "b.txt")
+	if err := os.Link(path1, path2); err != nil {
+		t.Skipf("hardlink not supported: %v", err)
+	}
+	for i, p := range []string{path1, path2} {
+		info, _ := os.Stat(p)
+		abs, _ := filepath.Abs(p)
+		addFileToScan(ctx, database, dir, scan.ID, abs, 1, int64(i), inodeOf(info), deviceOf(info))
+	}
+	db.UpdateScanCompletedAt(ctx, database, scan.ID, 2, 0)
+
+	if err := RunHashPhase(ctx, database, scan.ID, nil); err != nil {
+		t.Fatalf("RunHashPhase: %v", err)
+	}
+
+	files, _ := db.GetFilesByScanID(ctx, database, scan.ID)
+	for _, f := range files {
+		if f.HashStatus != "hardlink" || f.Hash != nil {
+			t.Errorf("file %s: status=%q hash=%v, want hardlink without hash", f.Path, f.HashStatus, f.Hash)
+		}
+	}
+	if n, _ := db.CountHardlinkOnlyFiles(ctx, database, scan.ID); n != 2 {
+		t.Errorf("CountHardlinkOnlyFiles = %d, want 2", n)
+	}
 }
 
 func inodeOf(info os.FileInfo) int64 {
@@ -284,6 +353,63 @@ func TestRunHashPhase_twoWorkersAllFilesHashedOnce(t *testing.T) {
 	}
 }
 
+func TestRunHashPhase_twoWorkersHardlinksReadOncePerSizeGroup(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	folderID, _ := db.GetOrCreateFolderByPath(ctx, database, dir)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	// Three size groups, each one file with two extra hardlinks and a separate copy: the first link of each is
+	// read, the other two reuse its hash.
+	for g := 0; g < 3; g++ {
+		orig := filepath.Join(dir, fmt.Sprintf("g%d-0.txt", g))
+		content := []byte(fmt.Sprintf("content %d", g))
+		if err := os.WriteFile(orig, content, 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		for l := 0; l < 4; l++ {
+			path := filepath.Join(dir, fmt.Sprintf("g%d-%d.txt", g, l))
+			if l == 3 {
+				if err := os.WriteFile(path, content, 0644); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			} else if l > 0 {
+				if err := os.Link(orig, path); err != nil {
+					t.Skipf("hardlink not supported: %v", err)
+				}
+			}
+			info, _ := os.Stat(path)
+			abs, _ := filepath.Abs(path)
+			addFileToScan(ctx, database, dir, scan.ID, abs, int64(100+g), int64(l), inodeOf(info), deviceOf(info))
+		}
+	}
+	db.UpdateScanCompletedAt(ctx, database, scan.ID, 12, 0)
+
+	if err := RunHashPhase(ctx, database, scan.ID, &HashOptions{Workers: 2}); err != nil {
+		t.Fatalf("RunHashPhase: %v", err)
+	}
+
+	sn, err := db.GetScan(ctx, database, scan.ID)
+	if err != nil {
+		t.Fatalf("GetScan: %v", err)
+	}
+	if sn.HashReusedCount == nil || *sn.HashReusedCount != 6 {
+		t.Errorf("HashReusedCount = %v, want 6 (two extra links in each of three groups)", sn.HashReusedCount)
+	}
+	files, _ := db.GetFilesByScanID(ctx, database, scan.ID)
+	hashes := make(map[int64]string)
+	for _, f := range files {
+		if f.Hash == nil {
+			t.Fatalf("file %s not hashed", f.Path)
+		}
+		if h, ok := hashes[f.Size]; ok && h != *f.Hash {
+			t.Errorf("size %d: copies hashed differently (%s vs %s)", f.Size, h, *f.Hash)
+		}
+		hashes[f.Size] = *f.Hash
+	}
+}
+
 func TestRunHashPhase_throttleEnabledDelays(t *testing.T) {
 	database := testDB(t)
 	ctx := context.Background()
@@ -355,3 +481,130 @@ func TestRunHashPhase_throttleDisabledFast(t *testing.T) {
 		t.Errorf("throttle disabled: elapsed %v, want < 500ms", elapsed)
 	}
 }
+
+func TestRunHashPhase_pausedLeavesFilesPending(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	folderID, _ := db.GetOrCreateFolderByPath(ctx, database, dir)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("f%d.txt", i))
+		os.WriteFile(path, []byte("x"), 0644)
+		abs, _ := filepath.Abs(path)
+		addFileToScan(ctx, database, dir, scan.ID, abs, 1, int64(i), int64(i+1), nil)
+	}
+	db.UpdateScanCompletedAt(ctx, database, scan.ID, 3, 0)
+	if _, err := db.RequestScanHashPause(ctx, database, scan.ID); err != nil {
+		t.Fatalf("RequestScanHashPause: %v", err)
+	}
+
+	if err := RunHashPhase(ctx, database, scan.ID, nil); err != ErrPaused {
+		t.Fatalf("RunHashPhase while paused: err = %v, want ErrPaused", err)
+	}
+	files, _ := db.GetFilesByScanID(ctx, database, scan.ID)
+	for _, f := range files {
+		if f.HashStatus != "pending" {
+			t.Errorf("file %s: status = %q, want pending", f.Path, f.HashStatus)
+		}
+	}
+
+	// Resume: clear the flag and run again
+	_ = db.ClearScanHashPause(ctx, database, scan.ID)
+	if err := RunHashPhase(ctx, database, scan.ID, nil); err != nil {
+		t.Fatalf("RunHashPhase after resume: %v", err)
+	}
+	sn, _ := db.GetScan(ctx, database, scan.ID)
+	if sn.HashCompletedAt == nil {
+		t.Error("after resume: HashCompletedAt is nil")
+	}
+}
+
+func TestRunHashPhase_unreadableFileMarkedFailedAndRecorded(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	folderID, _ := db.GetOrCreateFolderByPath(ctx, database, dir)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	present := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(present, []byte("x"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	gone := filepath.Join(dir, "gone.txt") // deleted between walk and hash
+	addFileToScan(ctx, database, dir, scan.ID, present, 1, 1, 1, nil)
+	addFileToScan(ctx, database, dir, scan.ID, gone, 1, 1, 2, nil)
+	_ = db.UpdateScanCompletedAt(ctx, database, scan.ID, 2, 0)
+
+	if err := RunHashPhase(ctx, database, scan.ID, &HashOptions{Workers: 1, MaxAttempts: 2, RetryBackoff: time.Millisecond}); err != nil {
+		t.Fatalf("RunHashPhase: %v", err)
+	}
+	var status string
+	var attempts int
+	var hashErr sql.NullString
+	if err := database.QueryRowContext(ctx,
+		`SELECT hash_status, hash_attempts, hash_error FROM files WHERE folder_id = $1 AND path = 'gone.txt'`,
+		folderID).Scan(&status, &attempts, &hashErr); err != nil {
+		t.Fatalf("query gone.txt: %v", err)
+	}
+	if status != "failed" || attempts != 2 || !hashErr.Valid {
+		t.Errorf("gone.txt: status %q, attempts %d, error %v; want failed after 2 attempts with an error", status, attempts, hashErr)
+	}
+	sn, _ := db.GetScan(ctx, database, scan.ID)
+	if sn.HashCompletedAt == nil || sn.HashedFileCount == nil || *sn.HashedFileCount != 1 {
+		t.Errorf("scan: hash_completed_at %v, hashed %v; want completed with the present file hashed", sn.HashCompletedAt, sn.HashedFileCount)
+	}
+	errs, err := db.ListScanErrors(ctx, database, scan.ID, 10)
+	if err != nil {
+		t.Fatalf("ListScanErrors: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Path != gone || errs[0].Phase != db.PhaseHash || errs[0].FileID == nil {
+		t.Errorf("scan errors = %+v, want one hash error for %s", errs, gone)
+	}
+}
+
+func TestRunHashPhase_reusesHashOfMovedFile(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Hashed earlier in another folder, before the file was moved here.
+	oldFolder, _ := db.AddFolder(ctx, database, filepath.Join(dir, "old"))
+	oldID, _ := db.UpsertFile(ctx, database, oldFolder, "moved.bin", 2, 77, 1, nil)
+	_ = db.UpdateFileHash(ctx, database, oldID, "known", time.Now())
+
+	folderID, _ := db.GetOrCreateFolderByPath(ctx, database, dir)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	for i, name := range []string{"moved.bin", "other.bin"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("%02d", i)), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		addFileToScan(ctx, database, dir, scan.ID, path, 2, 77, int64(100+i), nil)
+	}
+	db.UpdateScanCompletedAt(ctx, database, scan.ID, 2, 0)
+
+	if err := RunHashPhase(ctx, database, scan.ID, &HashOptions{ReuseMoved: config.HashReuseSameName}); err != nil {
+		t.Fatalf("RunHashPhase: %v", err)
+	}
+	files, _ := db.GetFilesByScanID(ctx, database, scan.ID)
+	for _, f := range files {
+		moved := filepath.Base(f.Path) == "moved.bin"
+		if f.Hash == nil || (*f.Hash == "known") != moved {
+			t.Errorf("%s: hash = %v, want the moved file's known hash and the other file read", f.Path, f.Hash)
+		}
+	}
+	if sn, _ := db.GetScan(ctx, database, scan.ID); sn.HashReusedCount == nil || *sn.HashReusedCount != 1 {
+		t.Errorf("HashReusedCount = %v, want 1", sn.HashReusedCount)
+	}
+	evidence, _ := db.HashEvidenceByFileID(ctx, database, []int64{files[0].ID, files[1].ID})
+	for _, f := range files {
+		want := db.HashComputed
+		if filepath.Base(f.Path) == "moved.bin" {
+			want = db.HashFromMoved
+		}
+		if e := evidence[f.ID]; e.Source != want || e.Reused() != (want != db.HashComputed) {
+			t.Errorf("%s: hash source = %q, want %q", f.Path, e.Source, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package hash
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/limits"
+)
+
+// verifyBufSize is the chunk size used when comparing two files byte by byte.
+const verifyBufSize = 64 * 1024
+
+// VerifyResult is the outcome of comparing every file in a group against the first (reference) file.
+type VerifyResult struct {
+	Reference  string   // path every other file was compared to
+	Identical  []string // paths whose bytes match the reference (includes the reference)
+	Mismatched []string // paths that differ from the reference (changed since hashing, or a hash collision)
+	Errors     map[string]error
+}
+
+// OK reports whether all files were readable and identical.
+func (r *VerifyResult) OK() bool {
+	return len(r.Mismatched) == 0 && len(r.Errors) == 0
+}
+
+// VerifyGroup compares each path byte by byte with paths[0]. Hash equality already makes a mismatch
+// astronomically unlikely; this pass removes the residual risk before data is deleted or replaced,
+// and catches files modified since they were hashed.
+func VerifyGroup(ctx context.Context, paths []string) *VerifyResult {
+	res := &VerifyResult{Errors: make(map[string]error)}
+	if len(paths) == 0 {
+		return res
+	}
+	res.Reference = paths[0]
+	if _, _, err := archive.Stat(paths[0]); err != nil {
+		res.Errors[paths[0]] = err
+		return res
+	}
+	res.Identical = append(res.Identical, paths[0])
+	for _, p := range paths[1:] {
+		if ctx.Err() != nil {
+			res.Errors[p] = ctx.Err()
+			continue
+		}
+		same, err := SameContent(ctx, paths[0], p)
+		switch {
+		case err != nil:
+			res.Errors[p] = err
+		case same:
+			res.Identical = append(res.Identical, p)
+		default:
+			res.Mismatched = append(res.Mismatched, p)
+		}
+	}
+	return res
+}
+
+// SameContent reports whether the files at a and b have identical bytes.
+func SameContent(ctx context.Context, a, b string) (bool, error) {
+	release, err := limits.AcquireFiles(ctx, 2)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	sizeA, _, err := archive.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	sizeB, _, err := archive.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if sizeA != sizeB {
+		return false, nil
+	}
+	fa, err := archive.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := archive.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	bufA := make([]byte, verifyBufSize)
+	bufB := make([]byte, verifyBufSize)
+	for {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA && endB, nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package hash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyGroup_identicalAndMismatched(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	c := filepath.Join(dir, "c")
+	big := make([]byte, verifyBufSize+10)
+	other := make([]byte, verifyBufSize+10)
+	other[verifyBufSize+5] = 1 // differs only in the second chunk
+	os.WriteFile(a, big, 0644)
+	os.WriteFile(b, big, 0644)
+	os.WriteFile(c, other, 0644)
+
+	res := VerifyGroup(context.Background(), []string{a, b, c})
+	if len(res.Identical) != 2 || len(res.Mismatched) != 1 || res.Mismatched[0] != c {
+		t.Errorf("VerifyGroup: identical=%v mismatched=%v", res.Identical, res.Mismatched)
+	}
+	if res.OK() {
+		t.Error("OK() = true with a mismatched file")
+	}
+}
+
+func TestVerifyGroup_missingFileIsError(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	os.WriteFile(a, []byte("x"), 0644)
+
+	res := VerifyGroup(context.Background(), []string{a, filepath.Join(dir, "gone")})
+	if len(res.Errors) != 1 {
+		t.Errorf("Errors = %v, want one", res.Errors)
+	}
+}
+
+func TestSameContent_differentSize(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	os.WriteFile(a, []byte("abc"), 0644)
+	os.WriteFile(b, []byte("abcd"), 0644)
+	same, err := SameContent(context.Background(), a, b)
+	if err != nil || same {
+		t.Errorf("SameContent = %v, %v; want false, nil", same, err)
+	}
+}
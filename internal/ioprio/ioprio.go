@@ -0,0 +1,17 @@
+// Package ioprio lowers the CPU and I/O scheduling priority of the calling goroutine's OS thread,
+// so scan and hash workers can yield disk and CPU to other workloads on a busy NAS.
+package ioprio
+
+// Settings describes how much to lower a worker's priority. The zero value leaves priority unchanged.
+type Settings struct {
+	Nice   int  // 1-19: added CPU niceness (higher = lower priority); 0 = unchanged
+	IdleIO bool // use the idle I/O scheduling class (only get disk time when nobody else needs it)
+}
+
+// LowPriority is the preset used for folders marked "low priority" in the UI.
+var LowPriority = Settings{Nice: 10, IdleIO: true}
+
+// Enabled reports whether s changes anything.
+func (s Settings) Enabled() bool {
+	return s.Nice > 0 || s.IdleIO
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package ioprio
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set(2) constants (linux/ioprio.h).
+const (
+	ioprioWhoProcess = 1 // IOPRIO_WHO_PROCESS: a thread id on Linux
+	ioprioClassIdle  = 3 // IOPRIO_CLASS_IDLE
+	ioprioClassShift = 13
+)
+
+// ApplyToCurrentThread locks the calling goroutine to its OS thread and lowers that thread's priority.
+// The goroutine must not call runtime.UnlockOSThread: when it exits, Go discards the thread,
+// so the lowered priority never leaks to other goroutines. No-op when s is the zero value.
+func ApplyToCurrentThread(s Settings) error {
+	if !s.Enabled() {
+		return nil
+	}
+	runtime.LockOSThread()
+	tid := unix.Gettid()
+	if s.Nice > 0 {
+		nice := s.Nice
+		if nice > 19 {
+			nice = 19
+		}
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, nice); err != nil {
+			return err
+		}
+	}
+	if s.IdleIO {
+		prio := ioprioClassIdle << ioprioClassShift
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
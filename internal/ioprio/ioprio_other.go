@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ioprio
+
+// ApplyToCurrentThread is a no-op on platforms without per-thread nice/ionice support.
+func ApplyToCurrentThread(s Settings) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+package ioprio
+
+import "testing"
+
+func TestSettings_Enabled(t *testing.T) {
+	if (Settings{}).Enabled() {
+		t.Error("zero Settings: Enabled = true, want false")
+	}
+	if !LowPriority.Enabled() {
+		t.Error("LowPriority: Enabled = false, want true")
+	}
+}
+
+func TestApplyToCurrentThread_zeroIsNoop(t *testing.T) {
+	if err := ApplyToCurrentThread(Settings{}); err != nil {
+		t.Errorf("ApplyToCurrentThread(zero): %v", err)
+	}
+}
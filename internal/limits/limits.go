@@ -0,0 +1,172 @@
+// Package limits caps ditto's own use of open file descriptors and CPU, so a scan or hash run on a small NAS
+// stays clear of fd-exhaustion and overload alerts. Limits are process-wide and set once at startup.
+package limits
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// files caps files opened for reading; nil sem means no cap.
+var files struct {
+	mu    sync.RWMutex
+	sem   *semaphore.Weighted
+	max   int
+	inUse atomic.Int64
+}
+
+// SetMaxOpenFiles caps how many files AcquireFiles lets callers hold open at once. n <= 0 removes the cap.
+// Call before workers start; holders of the previous limit release into it.
+func SetMaxOpenFiles(n int) {
+	files.mu.Lock()
+	defer files.mu.Unlock()
+	if n <= 0 {
+		files.sem, files.max = nil, 0
+		return
+	}
+	files.sem, files.max = semaphore.NewWeighted(int64(n)), n
+}
+
+// AcquireFiles waits until n more files may be opened and returns a func that gives them back. Acquire every
+// file a task needs in one call (e.g. both sides of a comparison) so tasks cannot deadlock holding one each.
+func AcquireFiles(ctx context.Context, n int) (release func(), err error) {
+	files.mu.RLock()
+	sem, max := files.sem, files.max
+	files.mu.RUnlock()
+	if sem != nil {
+		if n > max {
+			n = max // a request larger than the cap would block forever
+		}
+		if err := sem.Acquire(ctx, int64(n)); err != nil {
+			return nil, err
+		}
+	}
+	files.inUse.Add(int64(n))
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			files.inUse.Add(-int64(n))
+			if sem != nil {
+				sem.Release(int64(n))
+			}
+		})
+	}, nil
+}
+
+// cpuSampleInterval is the window over which process CPU usage is measured for throttling.
+const cpuSampleInterval = 250 * time.Millisecond
+
+// cpuBackoff is how long ThrottleCPU sleeps before re-checking usage.
+const cpuBackoff = 100 * time.Millisecond
+
+var cpu struct {
+	mu         sync.Mutex
+	maxPercent int
+	lastWall   time.Time
+	lastCPU    time.Duration
+	percent    float64       // usage over the last complete window
+	throttled  time.Duration // total time callers slept in ThrottleCPU
+}
+
+// SetMaxCPUPercent makes ThrottleCPU hold callers back while the process uses more than percent (1-100)
+// of total CPU capacity (all cores). percent <= 0 disables throttling.
+func SetMaxCPUPercent(percent int) {
+	cpu.mu.Lock()
+	defer cpu.mu.Unlock()
+	cpu.maxPercent = percent
+}
+
+// ThrottleCPU blocks while recent process CPU usage is above the configured limit. It is cheap when usage is
+// under the limit (or no limit is set), so call it once per unit of work, e.g. before hashing each file.
+func ThrottleCPU(ctx context.Context) error {
+	for {
+		cpu.mu.Lock()
+		limit := cpu.maxPercent
+		pct := sampleCPULocked(time.Now())
+		cpu.mu.Unlock()
+		if limit <= 0 || pct <= float64(limit) {
+			return nil
+		}
+		t := time.NewTimer(cpuBackoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		cpu.mu.Lock()
+		cpu.throttled += cpuBackoff
+		cpu.mu.Unlock()
+	}
+}
+
+// sampleCPULocked refreshes cpu.percent once per cpuSampleInterval and returns it. Caller holds cpu.mu.
+func sampleCPULocked(now time.Time) float64 {
+	if !cpu.lastWall.IsZero() && now.Sub(cpu.lastWall) < cpuSampleInterval {
+		return cpu.percent
+	}
+	used, ok := processCPUTime()
+	if !ok {
+		return 0
+	}
+	if !cpu.lastWall.IsZero() {
+		wall := now.Sub(cpu.lastWall)
+		cpu.percent = cpuPercent(used-cpu.lastCPU, wall, runtime.NumCPU())
+	}
+	cpu.lastWall, cpu.lastCPU = now, used
+	return cpu.percent
+}
+
+// cpuPercent converts CPU time used over a wall-clock window into a share of total capacity across cores.
+func cpuPercent(used, wall time.Duration, cores int) float64 {
+	if wall <= 0 || cores <= 0 {
+		return 0
+	}
+	return float64(used) / float64(wall) / float64(cores) * 100
+}
+
+// Usage is a snapshot of the process's resource use and the configured limits, for the diagnostics page.
+type Usage struct {
+	OpenFDs       int    // descriptors currently open by the process (files, sockets, pipes); -1 if unknown
+	FDLimit       uint64 // soft RLIMIT_NOFILE; 0 if unknown
+	MaxOpenFiles  int    // cap from SetMaxOpenFiles; 0 = none
+	FilesInUse    int64  // files held through AcquireFiles right now
+	CPUPercent    float64
+	MaxCPUPercent int           // limit from SetMaxCPUPercent; 0 = none
+	CPUThrottled  time.Duration // total time hash workers were held back
+	CPUs          int
+	Goroutines    int
+	HeapBytes     int64
+	SysBytes      int64 // memory obtained from the OS by the Go runtime
+}
+
+// Current returns the current Usage.
+func Current() Usage {
+	files.mu.RLock()
+	maxFiles := files.max
+	files.mu.RUnlock()
+	cpu.mu.Lock()
+	pct := sampleCPULocked(time.Now())
+	maxCPU, throttled := cpu.maxPercent, cpu.throttled
+	cpu.mu.Unlock()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return Usage{
+		OpenFDs:       openFDs(),
+		FDLimit:       fdLimit(),
+		MaxOpenFiles:  maxFiles,
+		FilesInUse:    files.inUse.Load(),
+		CPUPercent:    pct,
+		MaxCPUPercent: maxCPU,
+		CPUThrottled:  throttled,
+		CPUs:          runtime.NumCPU(),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapBytes:     int64(ms.HeapAlloc), // #nosec G115 -- heap sizes fit in int64
+		SysBytes:      int64(ms.Sys),       // #nosec G115 -- heap sizes fit in int64
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package limits
+
+import "time"
+
+// CPU and descriptor accounting are only implemented on Linux; elsewhere ThrottleCPU never throttles.
+func processCPUTime() (time.Duration, bool) { return 0, false }
+
+func openFDs() int { return -1 }
+
+func fdLimit() uint64 { return 0 }
@@ -0,0 +1,57 @@
+package limits
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireFiles_capsConcurrentHolders(t *testing.T) {
+	SetMaxOpenFiles(2)
+	defer SetMaxOpenFiles(0)
+
+	release, err := AcquireFiles(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("AcquireFiles(2): %v", err)
+	}
+	if got := Current().FilesInUse; got != 2 {
+		t.Errorf("FilesInUse = %d, want 2", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := AcquireFiles(ctx, 1); err == nil {
+		t.Fatal("AcquireFiles over the cap succeeded, want it to block until the context ends")
+	}
+	release()
+	release()                                        // releasing twice is harmless
+	r2, err := AcquireFiles(context.Background(), 5) // larger than the cap: clamped, not deadlocked
+	if err != nil {
+		t.Fatalf("AcquireFiles(5) with cap 2: %v", err)
+	}
+	r2()
+	if got := Current().FilesInUse; got != 0 {
+		t.Errorf("FilesInUse after release = %d, want 0", got)
+	}
+}
+
+func TestThrottleCPU_noLimitReturnsImmediately(t *testing.T) {
+	SetMaxCPUPercent(0)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := ThrottleCPU(context.Background()); err != nil {
+			t.Fatalf("ThrottleCPU: %v", err)
+		}
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("100 unthrottled calls took %v", d)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	if got := cpuPercent(time.Second, time.Second, 4); got != 25 {
+		t.Errorf("cpuPercent(1s over 1s, 4 cores) = %v, want 25", got)
+	}
+	if got := cpuPercent(time.Second, 0, 4); got != 0 {
+		t.Errorf("cpuPercent with zero window = %v, want 0", got)
+	}
+}
@@ -0,0 +1,213 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// ExternalEntry is one file listed in an external manifest (see ParseExternal).
+type ExternalEntry struct {
+	Path string
+	Size int64  // 0 when the format carries no size (hashsum files)
+	Hash string // lowercase hex SHA-256
+}
+
+// ErrUnsupportedHash is returned when a manifest lists hashes that are not SHA-256 (e.g. MD5 or SHA-1).
+var ErrUnsupportedHash = errors.New("only SHA-256 hashes can be compared")
+
+// ParseExternal reads a list of files with content hashes produced elsewhere. Supported formats:
+//   - a ditto scan manifest (JSON); entries without a hash are skipped
+//   - CSV with a header row naming path, hash (or sha256) and optionally size columns, in any order
+//   - sha256sum / "rclone hashsum SHA-256" output: "<hash>  <path>" per line
+//
+// The format is detected from the content. Paths are kept as written, without a leading "/" or "./".
+func ParseExternal(r io.Reader) ([]ExternalEntry, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	head = bytes.TrimLeft(head, " \t\r\n\uFEFF")
+	switch {
+	case len(head) > 0 && head[0] == '{':
+		return parseDittoManifest(br)
+	case looksLikeHashsum(head):
+		return parseHashsum(br)
+	default:
+		return parseCSV(br)
+	}
+}
+
+// importBatchSize is the number of entries inserted per statement by Import.
+const importBatchSize = 500
+
+// Import stores entries as a new, already hashed scan of the virtual folder for name (see db.GetOrCreateImportFolder)
+// and returns the scan id. Compare it against a local scan to find files that already exist elsewhere.
+func Import(ctx context.Context, database *sql.DB, name, host string, entries []ExternalEntry) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, errors.New("import name is required")
+	}
+	folderID, err := db.GetOrCreateImportFolder(ctx, database, name, host)
+	if err != nil {
+		return 0, fmt.Errorf("import folder: %w", err)
+	}
+	sn, err := db.CreateScan(ctx, database, folderID)
+	if err != nil {
+		return 0, fmt.Errorf("create scan: %w", err)
+	}
+	batch := make([]db.ImportedFile, 0, importBatchSize)
+	seen := make(map[string]bool, len(entries))
+	flush := func() error {
+		err := db.InsertImportedFiles(ctx, database, folderID, sn.ID, batch)
+		batch = batch[:0]
+		return err
+	}
+	for _, e := range entries {
+		if e.Path == "" || seen[e.Path] {
+			continue // one row per path: a statement cannot upsert the same (folder, path) twice
+		}
+		seen[e.Path] = true
+		batch = append(batch, db.ImportedFile{Path: e.Path, Size: e.Size, Hash: e.Hash})
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return 0, fmt.Errorf("insert files: %w", err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, fmt.Errorf("insert files: %w", err)
+	}
+	if err := db.CompleteImportScan(ctx, database, sn.ID); err != nil {
+		return 0, fmt.Errorf("complete scan: %w", err)
+	}
+	return sn.ID, nil
+}
+
+func parseDittoManifest(r io.Reader) ([]ExternalEntry, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("ditto manifest: %w", err)
+	}
+	if m.Kind != KindScan {
+		return nil, fmt.Errorf("ditto manifest: kind %q, want %q", m.Kind, KindScan)
+	}
+	out := make([]ExternalEntry, 0, len(m.Entries))
+	for _, e := range m.Entries {
+		if e.Hash == "" {
+			continue
+		}
+		h, err := normalizeSHA256(e.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("ditto manifest: %s: %w", e.Path, err)
+		}
+		out = append(out, ExternalEntry{Path: cleanPath(e.Path), Size: e.Size, Hash: h})
+	}
+	return out, nil
+}
+
+// looksLikeHashsum reports whether the first line is "<hex>  <path>" or "<hex> *<path>".
+func looksLikeHashsum(head []byte) bool {
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	hash, rest, ok := bytes.Cut(line, []byte(" "))
+	if !ok || len(rest) == 0 || (rest[0] != ' ' && rest[0] != '*') {
+		return false
+	}
+	_, err := hex.DecodeString(string(hash))
+	return err == nil && len(hash) >= 32
+}
+
+func parseHashsum(r io.Reader) ([]ExternalEntry, error) {
+	var out []ExternalEntry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		hash, rest, ok := strings.Cut(line, " ")
+		if !ok || len(rest) < 2 {
+			return nil, fmt.Errorf("hashsum line %d: want \"<hash>  <path>\"", n)
+		}
+		h, err := normalizeSHA256(hash)
+		if err != nil {
+			return nil, fmt.Errorf("hashsum line %d: %w", n, err)
+		}
+		out = append(out, ExternalEntry{Path: cleanPath(rest[1:]), Hash: h}) // rest[0] is ' ' (text) or '*' (binary)
+	}
+	return out, sc.Err()
+}
+
+func parseCSV(r io.Reader) ([]ExternalEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	pathCol, sizeCol, hashCol := -1, -1, -1
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\uFEFF"))) {
+		case "path":
+			pathCol = i
+		case "size":
+			sizeCol = i
+		case "hash", "sha256", "sha-256":
+			hashCol = i
+		}
+	}
+	if pathCol < 0 || hashCol < 0 {
+		return nil, errors.New("csv: header must name path and hash (or sha256) columns")
+	}
+	var out []ExternalEntry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if pathCol >= len(rec) || hashCol >= len(rec) {
+			return nil, fmt.Errorf("csv line %d: missing columns", line)
+		}
+		h, err := normalizeSHA256(rec[hashCol])
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: %w", line, err)
+		}
+		e := ExternalEntry{Path: cleanPath(rec[pathCol]), Hash: h}
+		if sizeCol >= 0 && sizeCol < len(rec) && strings.TrimSpace(rec[sizeCol]) != "" {
+			if e.Size, err = strconv.ParseInt(strings.TrimSpace(rec[sizeCol]), 10, 64); err != nil || e.Size < 0 {
+				return nil, fmt.Errorf("csv line %d: invalid size %q", line, rec[sizeCol])
+			}
+		}
+		out = append(out, e)
+	}
+}
+
+func normalizeSHA256(h string) (string, error) {
+	h = strings.ToLower(strings.TrimSpace(h))
+	if _, err := hex.DecodeString(h); err != nil {
+		return "", fmt.Errorf("invalid hash %q", h)
+	}
+	if len(h) != 64 {
+		return "", fmt.Errorf("%w (got %d hex digits)", ErrUnsupportedHash, len(h))
+	}
+	return h, nil
+}
+
+func cleanPath(p string) string {
+	p = strings.TrimPrefix(p, "./")
+	return strings.TrimLeft(p, "/")
+}
@@ -0,0 +1,55 @@
+package manifest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	shaA = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	shaB = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+func TestParseExternal_formats(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []ExternalEntry
+	}{
+		{"hashsum", shaA + "  photos/a.jpg\n" + strings.ToUpper(shaB) + " */empty\n\n",
+			[]ExternalEntry{{Path: "photos/a.jpg", Hash: shaA}, {Path: "empty", Hash: shaB}}},
+		{"csv", "Size,Path,SHA256\n5,./photos/a.jpg," + shaA + "\n0,\"with, comma\"," + shaB + "\n",
+			[]ExternalEntry{{Path: "photos/a.jpg", Size: 5, Hash: shaA}, {Path: "with, comma", Hash: shaB}}},
+		{"ditto manifest", `{"kind":"ditto-scan-manifest","version":1,"entries":[{"path":"a","size":5,"mtime":1,"sha256":"` + shaA + `"},{"path":"unique","size":9,"mtime":1}]}`,
+			[]ExternalEntry{{Path: "a", Size: 5, Hash: shaA}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseExternal(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: ParseExternal: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: entry %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseExternal_rejectsOtherHashesAndBadInput(t *testing.T) {
+	if _, err := ParseExternal(strings.NewReader("d41d8cd98f00b204e9800998ecf8427e  file\n")); !errors.Is(err, ErrUnsupportedHash) {
+		t.Errorf("md5sum input: err = %v, want ErrUnsupportedHash", err)
+	}
+	if _, err := ParseExternal(strings.NewReader("name,checksum\na,b\n")); err == nil {
+		t.Error("csv without path/hash columns: want error")
+	}
+	if _, err := ParseExternal(strings.NewReader("path,hash\na,nothex\n")); err == nil {
+		t.Error("csv with invalid hash: want error")
+	}
+}
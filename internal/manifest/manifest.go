@@ -0,0 +1,231 @@
+// Package manifest exports per-scan hash manifests and a manifest-of-manifests index, optionally signed
+// (HMAC-SHA256 or ed25519), so one can later prove a file had given content at scan time.
+// Each scan manifest records the digest of the folder's previous manifest, forming a hash chain.
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// Document kinds.
+const (
+	KindScan  = "ditto-scan-manifest"
+	KindIndex = "ditto-manifest-index"
+)
+
+// Version is the manifest format version.
+const Version = 1
+
+// Entry is one file in a scan manifest.
+type Entry struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	MTime int64  `json:"mtime"`
+	Hash  string `json:"sha256,omitempty"` // empty when the file was not hashed (unique size)
+}
+
+// Manifest lists every file of one scan with its hash.
+type Manifest struct {
+	Kind           string     `json:"kind"`
+	Version        int        `json:"version"`
+	ScanID         int64      `json:"scan_id"`
+	Root           string     `json:"root"`
+	ScanStartedAt  time.Time  `json:"scan_started_at"`
+	PreviousDigest string     `json:"previous_digest,omitempty"` // digest of the folder's previous manifest (chain)
+	Entries        []Entry    `json:"entries"`
+	Digest         string     `json:"digest"` // sha256 of this document with Digest and Signature empty
+	Signature      *Signature `json:"signature,omitempty"`
+}
+
+// IndexEntry is one scan manifest referenced by the index.
+type IndexEntry struct {
+	ScanID         int64  `json:"scan_id"`
+	Root           string `json:"root"`
+	Digest         string `json:"digest"`
+	PreviousDigest string `json:"previous_digest,omitempty"`
+}
+
+// Index is the manifest-of-manifests: the digests of every exported scan manifest.
+type Index struct {
+	Kind        string       `json:"kind"`
+	Version     int          `json:"version"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Manifests   []IndexEntry `json:"manifests"`
+	Digest      string       `json:"digest"`
+	Signature   *Signature   `json:"signature,omitempty"`
+}
+
+// ErrScanNotHashed is returned when exporting a scan whose hash phase has not completed.
+var ErrScanNotHashed = errors.New("scan hash phase is not complete")
+
+// BuildScan builds the manifest for scanID, records its digest in scan_manifests (first export only; later
+// exports reuse the recorded chain link), and signs it when signer is non-nil.
+func BuildScan(ctx context.Context, database *sql.DB, scanID int64, signer *Signer) (*Manifest, error) {
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		return nil, err
+	}
+	if sn.HashCompletedAt == nil {
+		return nil, ErrScanNotHashed
+	}
+	files, err := db.GetScanSnapshot(ctx, database, scanID)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		Kind:          KindScan,
+		Version:       Version,
+		ScanID:        sn.ID,
+		Root:          sn.RootPath,
+		ScanStartedAt: sn.CreatedAt.UTC(),
+		Entries:       make([]Entry, 0, len(files)),
+	}
+	for _, f := range files {
+		m.Entries = append(m.Entries, Entry{Path: f.Path, Size: f.Size, MTime: f.MTime, Hash: f.Hash})
+	}
+
+	rec, err := db.GetScanManifest(ctx, database, scanID)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		m.PreviousDigest = rec.PreviousDigest
+	} else {
+		prev, err := db.PreviousScanManifestDigest(ctx, database, scanID)
+		if err != nil {
+			return nil, err
+		}
+		m.PreviousDigest = prev
+	}
+	if m.Digest, err = digestOf(m); err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		if err := db.InsertScanManifest(ctx, database, scanID, m.Digest, m.PreviousDigest); err != nil {
+			return nil, err
+		}
+	} else if rec.Digest != m.Digest {
+		return nil, fmt.Errorf("scan %d content no longer matches its recorded manifest digest %s", scanID, rec.Digest)
+	}
+	if signer != nil {
+		m.Signature = signer.Sign(m.Digest)
+	}
+	return m, nil
+}
+
+// BuildIndex builds the manifest-of-manifests from every recorded scan manifest and signs it when signer is non-nil.
+func BuildIndex(ctx context.Context, database *sql.DB, signer *Signer) (*Index, error) {
+	recs, err := db.ListScanManifests(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{Kind: KindIndex, Version: Version, GeneratedAt: time.Now().UTC().Truncate(time.Second), Manifests: make([]IndexEntry, 0, len(recs))}
+	for _, r := range recs {
+		idx.Manifests = append(idx.Manifests, IndexEntry{ScanID: r.ScanID, Root: r.RootPath, Digest: r.Digest, PreviousDigest: r.PreviousDigest})
+	}
+	if idx.Digest, err = digestOf(idx); err != nil {
+		return nil, err
+	}
+	if signer != nil {
+		idx.Signature = signer.Sign(idx.Digest)
+	}
+	return idx, nil
+}
+
+// Write encodes a manifest or index as indented JSON.
+func Write(w io.Writer, doc interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// VerifyResult describes a verified document.
+type VerifyResult struct {
+	Kind    string
+	Digest  string
+	Entries int  // files (scan manifest) or manifests (index)
+	Signed  bool // signature present and checked
+}
+
+// Verify reads a manifest or index from r, recomputes its digest, and checks the signature with signer.
+// With a nil signer only the digest is checked (and an unsigned document is accepted).
+func Verify(r io.Reader, signer *Signer) (*VerifyResult, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var head struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return nil, err
+	}
+	var digest, recomputed string
+	var sig *Signature
+	res := &VerifyResult{Kind: head.Kind}
+	switch head.Kind {
+	case KindScan:
+		var m Manifest
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		digest, sig, res.Entries = m.Digest, m.Signature, len(m.Entries)
+		recomputed, err = digestOf(&m)
+	case KindIndex:
+		var idx Index
+		if err := json.Unmarshal(raw, &idx); err != nil {
+			return nil, err
+		}
+		digest, sig, res.Entries = idx.Digest, idx.Signature, len(idx.Manifests)
+		recomputed, err = digestOf(&idx)
+	default:
+		return nil, fmt.Errorf("unknown document kind %q", head.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recomputed != digest {
+		return nil, fmt.Errorf("digest mismatch: document says %s, content hashes to %s", digest, recomputed)
+	}
+	res.Digest = digest
+	if signer != nil {
+		if err := signer.Verify(digest, sig); err != nil {
+			return nil, err
+		}
+		res.Signed = true
+	}
+	return res, nil
+}
+
+// digestOf hashes the JSON encoding of a copy of doc with Digest and Signature cleared.
+func digestOf(doc interface{}) (string, error) {
+	var body []byte
+	var err error
+	switch d := doc.(type) {
+	case *Manifest:
+		c := *d
+		c.Digest, c.Signature = "", nil
+		body, err = json.Marshal(&c)
+	case *Index:
+		c := *d
+		c.Digest, c.Signature = "", nil
+		body, err = json.Marshal(&c)
+	default:
+		return "", fmt.Errorf("digestOf: unsupported %T", doc)
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,89 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testManifest(t *testing.T, signer *Signer) []byte {
+	t.Helper()
+	m := &Manifest{
+		Kind:          KindScan,
+		Version:       Version,
+		ScanID:        7,
+		Root:          "/data",
+		ScanStartedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Entries: []Entry{
+			{Path: "/data/a", Size: 5, MTime: 100, Hash: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+			{Path: "/data/b", Size: 9, MTime: 200},
+		},
+	}
+	var err error
+	if m.Digest, err = digestOf(m); err != nil {
+		t.Fatalf("digestOf: %v", err)
+	}
+	if signer != nil {
+		m.Signature = signer.Sign(m.Digest)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, m); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerify_signedRoundTrip(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	for _, key := range []string{"secret", "hmac-sha256:secret", "ed25519:" + seed} {
+		signer, err := ParseKey(key)
+		if err != nil {
+			t.Fatalf("ParseKey(%q): %v", key, err)
+		}
+		raw := testManifest(t, signer)
+		res, err := Verify(bytes.NewReader(raw), signer)
+		if err != nil {
+			t.Fatalf("Verify(%q): %v", key, err)
+		}
+		if !res.Signed || res.Entries != 2 || res.Kind != KindScan {
+			t.Errorf("Verify(%q) = %+v", key, res)
+		}
+	}
+}
+
+func TestVerify_detectsTamperingAndWrongKey(t *testing.T) {
+	signer, _ := ParseKey("secret")
+	raw := testManifest(t, signer)
+
+	tampered := bytes.Replace(raw, []byte(`"size": 9`), []byte(`"size": 10`), 1)
+	if _, err := Verify(bytes.NewReader(tampered), signer); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("Verify(tampered) err = %v, want digest mismatch", err)
+	}
+
+	other, _ := ParseKey("other")
+	if _, err := Verify(bytes.NewReader(raw), other); err == nil {
+		t.Error("Verify with wrong key: want error")
+	}
+
+	unsigned := testManifest(t, nil)
+	if _, err := Verify(bytes.NewReader(unsigned), signer); err == nil {
+		t.Error("Verify(unsigned) with key: want error")
+	}
+	if res, err := Verify(bytes.NewReader(unsigned), nil); err != nil || res.Signed {
+		t.Errorf("Verify(unsigned, nil) = %+v, %v; want digest-only OK", res, err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if s, err := ParseKey(""); s != nil || err != nil {
+		t.Errorf("ParseKey(\"\") = %v, %v; want nil, nil", s, err)
+	}
+	if _, err := ParseKey("ed25519:c2hvcnQ="); err == nil {
+		t.Error("ParseKey(short ed25519 seed): want error")
+	}
+	if _, err := ParseKey("hmac-sha256:"); err == nil {
+		t.Error("ParseKey(empty hmac): want error")
+	}
+}
@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Signature algorithms.
+const (
+	AlgHMACSHA256 = "hmac-sha256"
+	AlgEd25519    = "ed25519"
+)
+
+// Signature is attached to a manifest or index. It signs the document's Digest.
+type Signature struct {
+	Alg       string `json:"alg"`
+	PublicKey string `json:"public_key,omitempty"` // base64 ed25519 public key (informational; verify with your own key)
+	Value     string `json:"value"`                // base64 signature or MAC
+}
+
+// Signer signs and verifies digests with a configured key.
+type Signer struct {
+	alg    string
+	secret []byte             // HMAC secret
+	priv   ed25519.PrivateKey // ed25519 private key
+}
+
+// ParseKey parses a signing key: "hmac-sha256:<secret>", "ed25519:<base64 32-byte seed>", or a bare HMAC secret.
+// Returns (nil, nil) for an empty key (unsigned manifests).
+func ParseKey(key string) (*Signer, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, nil
+	}
+	switch {
+	case strings.HasPrefix(key, AlgEd25519+":"):
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(key, AlgEd25519+":"))
+		if err != nil {
+			return nil, fmt.Errorf("ed25519 key: %w", err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("ed25519 key: seed must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+		}
+		return &Signer{alg: AlgEd25519, priv: ed25519.NewKeyFromSeed(seed)}, nil
+	case strings.HasPrefix(key, AlgHMACSHA256+":"):
+		key = strings.TrimPrefix(key, AlgHMACSHA256+":")
+	}
+	if key == "" {
+		return nil, errors.New("hmac key is empty")
+	}
+	return &Signer{alg: AlgHMACSHA256, secret: []byte(key)}, nil
+}
+
+// Sign returns a signature over digest.
+func (s *Signer) Sign(digest string) *Signature {
+	if s.alg == AlgEd25519 {
+		pub := s.priv.Public().(ed25519.PublicKey)
+		return &Signature{
+			Alg:       AlgEd25519,
+			PublicKey: base64.StdEncoding.EncodeToString(pub),
+			Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(s.priv, []byte(digest))),
+		}
+	}
+	return &Signature{Alg: AlgHMACSHA256, Value: base64.StdEncoding.EncodeToString(s.mac(digest))}
+}
+
+// Verify checks sig over digest with this signer's key. The public key embedded in sig is ignored:
+// trusting it would let anyone re-sign a modified manifest.
+func (s *Signer) Verify(digest string, sig *Signature) error {
+	if sig == nil {
+		return errors.New("manifest is not signed")
+	}
+	if sig.Alg != s.alg {
+		return fmt.Errorf("signature algorithm %q does not match key (%s)", sig.Alg, s.alg)
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("signature: %w", err)
+	}
+	if s.alg == AlgEd25519 {
+		if !ed25519.Verify(s.priv.Public().(ed25519.PublicKey), []byte(digest), value) {
+			return errors.New("ed25519 signature does not match")
+		}
+		return nil
+	}
+	if !hmac.Equal(value, s.mac(digest)) {
+		return errors.New("hmac does not match")
+	}
+	return nil
+}
+
+func (s *Signer) mac(digest string) []byte {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(digest))
+	return m.Sum(nil)
+}
@@ -0,0 +1,116 @@
+// Package notify tells people about finished scans (new duplicates, wasted space, errors) through channels
+// they already watch, for instances nobody opens every day.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// Report summarizes one finished scan.
+type Report struct {
+	ScanID         int64
+	Root           string
+	Files          int64 // files the scan saw
+	Groups         int64 // duplicate groups in the scan
+	WastedBytes    int64 // bytes taken by extra copies (delete all but one per group)
+	NewGroups      int64 // groups whose content was not duplicated in the previous scan of the folder
+	NewWastedBytes int64
+	Errors         int64  // files or directories the scan or hash phase could not read
+	URL            string // the scan in the web UI; "" when no base URL is configured
+}
+
+// Notifier delivers a report.
+type Notifier interface {
+	Notify(ctx context.Context, r *Report) error
+}
+
+// BuildReport collects the report of a scan whose hash phase has finished. baseURL (e.g.
+// "https://ditto.example.com") makes the report link to the scan; empty leaves it out.
+func BuildReport(ctx context.Context, database *sql.DB, scanID int64, baseURL string) (*Report, error) {
+	sn, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{ScanID: scanID, Root: sn.RootPath}
+	if sn.FileCount != nil {
+		r.Files = *sn.FileCount
+	}
+	savings, err := db.ProjectSavings(ctx, database, []int64{scanID})
+	if err != nil {
+		return nil, err
+	}
+	r.Groups, r.WastedBytes = savings.Groups, savings.DeleteSavings
+	var prevID int64
+	prev, err := db.PreviousCompletedScan(ctx, database, scanID)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		prevID = prev.ID
+	}
+	if r.NewGroups, r.NewWastedBytes, err = db.NewDuplicateGroups(ctx, database, scanID, prevID); err != nil {
+		return nil, err
+	}
+	if r.Errors, _, err = db.CountScanErrors(ctx, database, scanID); err != nil {
+		return nil, err
+	}
+	if baseURL != "" {
+		r.URL = strings.TrimSuffix(baseURL, "/") + "/scans/" + strconv.FormatInt(scanID, 10)
+	}
+	return r, nil
+}
+
+// Subject is a one-line summary of the report, used as mail subject or push title.
+func (r *Report) Subject() string {
+	if r.NewGroups == 0 {
+		return fmt.Sprintf("ditto: no new duplicates in %s", r.Root)
+	}
+	return fmt.Sprintf("ditto: %d new duplicate group(s) in %s (%s)", r.NewGroups, r.Root, formatBytes(r.NewWastedBytes))
+}
+
+// Text is the plain-text body of the report.
+func (r *Report) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scan %d of %s finished: %d files.\n\n", r.ScanID, r.Root, r.Files)
+	fmt.Fprintf(&b, "New duplicates: %d group(s), %s in extra copies.\n", r.NewGroups, formatBytes(r.NewWastedBytes))
+	fmt.Fprintf(&b, "All duplicates: %d group(s), %s in extra copies.\n", r.Groups, formatBytes(r.WastedBytes))
+	if r.Errors > 0 {
+		fmt.Fprintf(&b, "Errors: %d path(s) could not be read.\n", r.Errors)
+	}
+	if r.URL != "" {
+		fmt.Fprintf(&b, "\n%s\n", r.URL)
+	}
+	return b.String()
+}
+
+// Send delivers the report through every notifier and returns their errors joined; one failing channel
+// does not stop the others.
+func Send(ctx context.Context, r *Report, notifiers ...Notifier) error {
+	var errs []error
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit && exp < 3; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", float64(n)/float64(div), []string{"KB", "MB", "GB", "TB"}[exp])
+}
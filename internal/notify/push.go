@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// pushTimeout bounds one delivery so an unreachable push server cannot hold up a scan run.
+const pushTimeout = 30 * time.Second
+
+// ParseProviders reads push providers separated by ";", each "kind:value":
+//
+//	ntfy:https://ntfy.sh/my-topic              (optional ?token=tk_... for protected topics)
+//	gotify:https://gotify.example.com?token=APP_TOKEN
+//	pushover:USER_KEY@APP_TOKEN
+//
+// Returns (nil, nil) for an empty string.
+func ParseProviders(s string) ([]Notifier, error) {
+	var out []Notifier
+	for _, def := range strings.Split(s, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		kind, value, ok := strings.Cut(def, ":")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("notifier %q: want kind:value", def)
+		}
+		var n Notifier
+		var err error
+		switch kind {
+		case "ntfy":
+			n, err = parseNtfy(value)
+		case "gotify":
+			n, err = parseGotify(value)
+		case "pushover":
+			n, err = parsePushover(value)
+		default:
+			err = fmt.Errorf("unknown kind %q (want ntfy, gotify or pushover)", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %w", def, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Ntfy publishes to an ntfy topic (https://ntfy.sh or a self-hosted server).
+type Ntfy struct {
+	TopicURL string
+	Token    string // access token for protected topics; "" = none
+}
+
+func parseNtfy(v string) (*Ntfy, error) {
+	u, err := parseHTTPURL(v)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("url needs a topic, e.g. https://ntfy.sh/my-topic")
+	}
+	n := &Ntfy{Token: u.Query().Get("token")}
+	u.RawQuery = ""
+	n.TopicURL = u.String()
+	return n, nil
+}
+
+// Notify posts the report as a message with the subject as title.
+func (n *Ntfy) Notify(ctx context.Context, r *Report) error {
+	h := http.Header{"Title": {r.Subject()}, "Tags": {"ditto"}}
+	if r.URL != "" {
+		h.Set("Click", r.URL)
+	}
+	if n.Token != "" {
+		h.Set("Authorization", "Bearer "+n.Token)
+	}
+	return post(ctx, "ntfy", n.TopicURL, h, strings.NewReader(r.Text()))
+}
+
+// Gotify sends to a Gotify server as an application.
+type Gotify struct {
+	BaseURL string
+	Token   string // application token
+}
+
+func parseGotify(v string) (*Gotify, error) {
+	u, err := parseHTTPURL(v)
+	if err != nil {
+		return nil, err
+	}
+	g := &Gotify{Token: u.Query().Get("token")}
+	if g.Token == "" {
+		return nil, fmt.Errorf("url needs ?token=<application token>")
+	}
+	u.RawQuery = ""
+	g.BaseURL = strings.TrimSuffix(u.String(), "/")
+	return g, nil
+}
+
+// Notify posts the report to /message.
+func (g *Gotify) Notify(ctx context.Context, r *Report) error {
+	body, err := json.Marshal(map[string]any{"title": r.Subject(), "message": r.Text(), "priority": 5})
+	if err != nil {
+		return err
+	}
+	h := http.Header{"Content-Type": {"application/json"}, "X-Gotify-Key": {g.Token}}
+	return post(ctx, "gotify", g.BaseURL+"/message", h, bytes.NewReader(body))
+}
+
+// PushoverAPI is the Pushover message endpoint.
+const PushoverAPI = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends through the Pushover service.
+type Pushover struct {
+	UserKey string
+	Token   string // application API token
+	API     string // endpoint; "" = PushoverAPI
+}
+
+func parsePushover(v string) (*Pushover, error) {
+	user, token, ok := strings.Cut(v, "@")
+	if !ok || user == "" || token == "" {
+		return nil, fmt.Errorf("want USER_KEY@APP_TOKEN")
+	}
+	return &Pushover{UserKey: user, Token: token}, nil
+}
+
+// Notify posts the report as a message with a link to the scan.
+func (p *Pushover) Notify(ctx context.Context, r *Report) error {
+	form := url.Values{"token": {p.Token}, "user": {p.UserKey}, "title": {r.Subject()}, "message": {r.Text()}}
+	if r.URL != "" {
+		form.Set("url", r.URL)
+	}
+	api := p.API
+	if api == "" {
+		api = PushoverAPI
+	}
+	h := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	return post(ctx, "pushover", api, h, strings.NewReader(form.Encode()))
+}
+
+func parseHTTPURL(v string) (*url.URL, error) {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("want an http(s) url, got %q", v)
+	}
+	return u, nil
+}
+
+// post sends one request and turns a non-2xx answer into an error that carries the start of the body.
+func post(ctx context.Context, provider, target string, h http.Header, body io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	req.Header = h
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", provider, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
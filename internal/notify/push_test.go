@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseProviders(t *testing.T) {
+	ns, err := ParseProviders(" ntfy:https://ntfy.sh/backups?token=tk_1 ; gotify:https://gotify.lan/?token=A ;pushover:u123@t456;")
+	if err != nil {
+		t.Fatalf("ParseProviders: %v", err)
+	}
+	if len(ns) != 3 {
+		t.Fatalf("len = %d, want 3", len(ns))
+	}
+	if n := ns[0].(*Ntfy); n.TopicURL != "https://ntfy.sh/backups" || n.Token != "tk_1" {
+		t.Errorf("ntfy = %+v", n)
+	}
+	if g := ns[1].(*Gotify); g.BaseURL != "https://gotify.lan" || g.Token != "A" {
+		t.Errorf("gotify = %+v", g)
+	}
+	if p := ns[2].(*Pushover); p.UserKey != "u123" || p.Token != "t456" {
+		t.Errorf("pushover = %+v", p)
+	}
+	if ns, err := ParseProviders(""); ns != nil || err != nil {
+		t.Errorf("ParseProviders(\"\") = %v, %v; want nil, nil", ns, err)
+	}
+	for _, bad := range []string{"ntfy", "ntfy:https://ntfy.sh", "gotify:https://gotify.lan", "pushover:u123", "slack:https://x", "ntfy:ftp://h/t"} {
+		if _, err := ParseProviders(bad); err == nil {
+			t.Errorf("ParseProviders(%q) err = nil, want error", bad)
+		}
+	}
+}
+
+// capture records the last request a provider sent.
+type capture struct {
+	path, body string
+	header     http.Header
+}
+
+func server(t *testing.T, c *capture, status int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		c.path, c.body, c.header = r.URL.Path, string(b), r.Header
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("denied"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProviders_notify(t *testing.T) {
+	r := &Report{ScanID: 3, Root: "/photos", NewGroups: 1, NewWastedBytes: 1 << 20, URL: "https://ditto.lan/scans/3"}
+	ctx := context.Background()
+
+	var c capture
+	srv := server(t, &c, http.StatusOK)
+	if err := (&Ntfy{TopicURL: srv.URL + "/backups", Token: "tk"}).Notify(ctx, r); err != nil {
+		t.Fatalf("ntfy: %v", err)
+	}
+	if c.path != "/backups" || c.header.Get("Authorization") != "Bearer tk" || c.header.Get("Click") != r.URL || !strings.Contains(c.header.Get("Title"), "1 new duplicate") {
+		t.Errorf("ntfy request: path %q, headers %v", c.path, c.header)
+	}
+
+	if err := (&Gotify{BaseURL: srv.URL, Token: "app"}).Notify(ctx, r); err != nil {
+		t.Fatalf("gotify: %v", err)
+	}
+	if c.path != "/message" || c.header.Get("X-Gotify-Key") != "app" || !strings.Contains(c.body, `"title":"ditto: 1 new duplicate`) {
+		t.Errorf("gotify request: path %q, body %s", c.path, c.body)
+	}
+
+	if err := (&Pushover{UserKey: "u", Token: "t", API: srv.URL + "/1/messages.json"}).Notify(ctx, r); err != nil {
+		t.Fatalf("pushover: %v", err)
+	}
+	if c.path != "/1/messages.json" || !strings.Contains(c.body, "user=u") || !strings.Contains(c.body, "url=https%3A%2F%2Fditto.lan%2Fscans%2F3") {
+		t.Errorf("pushover request: path %q, body %s", c.path, c.body)
+	}
+
+	var rejected capture
+	bad := server(t, &rejected, http.StatusUnauthorized)
+	if err := (&Gotify{BaseURL: bad.URL, Token: "wrong"}).Notify(ctx, r); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("gotify with a rejected token: err = %v, want the server's answer", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Package offline prepares scan roots that live on removable drives or disk images ("offline media").
+// Such roots keep their last scan in duplicate detection while unplugged; a scan only runs when the
+// media is present, so an empty mountpoint never replaces the catalog of a disconnected drive.
+package offline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/volume"
+)
+
+// ErrNotMounted is returned by Attach when an offline-media root is not present and cannot be mounted.
+var ErrNotMounted = errors.New("offline media is not mounted")
+
+// Online reports whether root is present: an existing directory with at least one entry.
+// An empty directory is treated as an unmounted mountpoint.
+func Online(root string) bool {
+	f, err := os.Open(root) // #nosec G304 -- root is a configured scan root
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(1)
+	return err == nil && len(names) > 0
+}
+
+// Attach makes the folder's root available for a scan and returns a function that undoes it.
+// Folders that are not offline media, and media that are already online, need nothing. Otherwise, when the
+// folder has a disk image and helper is set, the image is mounted with "<helper> mount <image> <path>"
+// and detach runs "<helper> unmount <path>". Any other case returns ErrNotMounted.
+func Attach(ctx context.Context, helper string, f *db.Folder) (detach func(), err error) {
+	noop := func() {}
+	if !f.OfflineMedia() || Online(f.Path) {
+		return noop, nil
+	}
+	if f.MediaImage == "" || helper == "" {
+		return noop, fmt.Errorf("%w: %s (%s)", ErrNotMounted, f.MediaLabel, f.Path)
+	}
+	// #nosec G204 -- helper is operator configuration; image and path are configured scan-root settings
+	if out, err := exec.CommandContext(ctx, helper, "mount", f.MediaImage, f.Path).CombinedOutput(); err != nil {
+		return noop, fmt.Errorf("mount %s at %s: %v: %s", f.MediaImage, f.Path, err, out)
+	}
+	if !Online(f.Path) {
+		_ = exec.Command(helper, "unmount", f.Path).Run() // #nosec G204 -- see above
+		return noop, fmt.Errorf("%w: %s is empty after mounting %s", ErrNotMounted, f.Path, f.MediaImage)
+	}
+	log.Printf("[offline] mounted %s (%s) at %s", f.MediaImage, f.MediaLabel, f.Path)
+	return func() {
+		// Not tied to the scan's context: the image must be released even when the scan was cancelled.
+		if out, err := exec.Command(helper, "unmount", f.Path).CombinedOutput(); err != nil { // #nosec G204 -- see above
+			log.Printf("[offline] unmount %s: %v: %s", f.Path, err, out)
+			return
+		}
+		log.Printf("[offline] unmounted %s", f.Path)
+	}, nil
+}
+
+// Catalog prepares a catalog scan of the folder, a removable drive: it returns the drive's label and filesystem
+// UUID ("" when unknown) to record with the scan. A folder that is not yet offline media becomes offline media,
+// labelled after the drive (its filesystem label, else its UUID, else the folder's name), so its catalog stays in
+// duplicate detection once the drive is unplugged. Such a folder must be present: ErrNotMounted otherwise.
+func Catalog(ctx context.Context, database *sql.DB, f *db.Folder) (label, uuid string, err error) {
+	if !f.OfflineMedia() && !Online(f.Path) {
+		return "", "", fmt.Errorf("%w: %s", ErrNotMounted, f.Path)
+	}
+	var driveLabel string
+	if info, err := volume.Identify(f.Path); err == nil {
+		driveLabel, uuid = info.Label, info.UUID
+	}
+	if f.OfflineMedia() {
+		return f.MediaLabel, uuid, nil
+	}
+	label = driveLabel
+	if label == "" {
+		label = uuid
+	}
+	if label == "" {
+		label = filepath.Base(f.Path)
+	}
+	if err := db.UpdateFolderMedia(ctx, database, f.ID, label, f.MediaImage); err != nil {
+		return "", "", err
+	}
+	f.MediaLabel = label
+	log.Printf("[offline] %s is now offline media %q", f.Path, label)
+	return label, uuid, nil
+}
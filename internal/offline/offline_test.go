@@ -0,0 +1,87 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+func TestOnline_emptyDirIsOffline(t *testing.T) {
+	dir := t.TempDir()
+	if Online(dir) {
+		t.Error("Online(empty dir) = true, want false")
+	}
+	if Online(filepath.Join(dir, "missing")) {
+		t.Error("Online(missing) = true, want false")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "f"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !Online(dir) {
+		t.Error("Online(non-empty dir) = false, want true")
+	}
+}
+
+func TestAttach_notOfflineOrOnlineIsNoop(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Attach(context.Background(), "", &db.Folder{Path: dir}); err != nil {
+		t.Errorf("Attach(regular folder) = %v, want nil", err)
+	}
+	_ = os.WriteFile(filepath.Join(dir, "f"), nil, 0644)
+	if _, err := Attach(context.Background(), "", &db.Folder{Path: dir, MediaLabel: "usb"}); err != nil {
+		t.Errorf("Attach(online media) = %v, want nil", err)
+	}
+}
+
+func TestAttach_unpluggedWithoutImageFails(t *testing.T) {
+	_, err := Attach(context.Background(), "helper", &db.Folder{Path: t.TempDir(), MediaLabel: "usb"})
+	if !errors.Is(err, ErrNotMounted) {
+		t.Errorf("Attach = %v, want ErrNotMounted", err)
+	}
+}
+
+func TestAttach_runsHelperToMountAndUnmount(t *testing.T) {
+	dir := t.TempDir()
+	mnt := filepath.Join(dir, "mnt")
+	_ = os.Mkdir(mnt, 0755)
+	logFile := filepath.Join(dir, "calls")
+	// Fake helper: "mount" drops a file into the mountpoint, "unmount" removes it; every call is logged.
+	helper := filepath.Join(dir, "helper.sh")
+	script := "#!/bin/sh\necho \"$@\" >> " + logFile + "\n" +
+		"if [ \"$1\" = mount ]; then touch \"$3/file\"; else rm -f \"$2/file\"; fi\n"
+	if err := os.WriteFile(helper, []byte(script), 0755); err != nil { // #nosec G306 -- test helper must be executable
+		t.Fatal(err)
+	}
+	detach, err := Attach(context.Background(), helper, &db.Folder{Path: mnt, MediaLabel: "archive", MediaImage: "/images/a.img"})
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	if !Online(mnt) {
+		t.Error("mountpoint empty after Attach")
+	}
+	detach()
+	if Online(mnt) {
+		t.Error("mountpoint not empty after detach")
+	}
+	calls, _ := os.ReadFile(logFile)
+	want := "mount /images/a.img " + mnt + "\nunmount " + mnt + "\n"
+	if string(calls) != want {
+		t.Errorf("helper calls = %q, want %q", calls, want)
+	}
+}
+
+func TestCatalog_mediaKeepsItsLabelAndMissingFolderFails(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if _, _, err := Catalog(ctx, nil, &db.Folder{Path: dir}); !errors.Is(err, ErrNotMounted) {
+		t.Errorf("Catalog(empty folder) = %v, want ErrNotMounted", err)
+	}
+	label, _, err := Catalog(ctx, nil, &db.Folder{Path: filepath.Join(dir, "unplugged"), MediaLabel: "backup-2019"})
+	if err != nil || label != "backup-2019" {
+		t.Errorf("Catalog(labelled media) = %q, %v; want its label", label, err)
+	}
+}
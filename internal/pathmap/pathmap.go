@@ -0,0 +1,88 @@
+// Package pathmap translates between paths as ditto sees them inside a container and the same paths on
+// the host, so the UI can show host paths while scans and actions keep using the bind-mounted locations.
+package pathmap
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Mapping is one bind mount: Host is mounted at Container.
+type Mapping struct {
+	Host      string
+	Container string
+}
+
+// Map is a set of mappings. The zero value maps nothing and returns paths unchanged.
+type Map []Mapping
+
+// Parse reads mappings separated by ";", each "host:container" in the order of a Docker volume flag,
+// e.g. "/volume1/photos:/photos;/volume2/backup:/backup". The container side is split at the last ":",
+// so a Windows host path (C:\Users\me:/data) works. Returns (nil, nil) for an empty string.
+func Parse(s string) (Map, error) {
+	var m Map
+	for _, def := range strings.Split(s, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		i := strings.LastIndex(def, ":")
+		if i <= 0 || i == len(def)-1 {
+			return nil, fmt.Errorf("path mapping %q: want host:container", def)
+		}
+		host, container := strings.TrimSpace(def[:i]), strings.TrimSpace(def[i+1:])
+		if !strings.HasPrefix(container, "/") {
+			return nil, fmt.Errorf("path mapping %q: container path must be absolute", def)
+		}
+		m = append(m, Mapping{Host: trimSlash(host), Container: trimSlash(path.Clean(container))})
+	}
+	return m, nil
+}
+
+// ToHost returns p with its container prefix replaced by the host path of the longest matching mapping.
+// Paths outside every mapping are returned unchanged.
+func (m Map) ToHost(p string) string {
+	best := -1
+	for i, mp := range m {
+		if under(p, mp.Container) && (best < 0 || len(mp.Container) > len(m[best].Container)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return p
+	}
+	return m[best].Host + p[len(m[best].Container):]
+}
+
+// ToContainer is the inverse of ToHost: a host path (e.g. pasted from a file manager on the host) becomes
+// the path ditto can open. Paths outside every mapping are returned unchanged.
+func (m Map) ToContainer(p string) string {
+	best := -1
+	for i, mp := range m {
+		if under(p, mp.Host) && (best < 0 || len(mp.Host) > len(m[best].Host)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return p
+	}
+	return m[best].Container + p[len(m[best].Host):]
+}
+
+// under reports whether p is dir or inside it (on a path-element boundary, so /photos does not match /photos2).
+func under(p, dir string) bool {
+	if !strings.HasPrefix(p, dir) {
+		return false
+	}
+	rest := p[len(dir):]
+	return rest == "" || rest[0] == '/' || rest[0] == '\\' || dir == "/"
+}
+
+// trimSlash drops trailing separators except for a root ("/").
+func trimSlash(p string) string {
+	for len(p) > 1 && (strings.HasSuffix(p, "/") || strings.HasSuffix(p, "\\")) {
+		p = p[:len(p)-1]
+	}
+	return p
+}
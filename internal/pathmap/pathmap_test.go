@@ -0,0 +1,47 @@
+package pathmap
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	m, err := Parse(" /volume1/photos/ : /photos ; C:\\Users\\me:/home;")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(m) != 2 || m[0] != (Mapping{Host: "/volume1/photos", Container: "/photos"}) || m[1].Host != "C:\\Users\\me" {
+		t.Errorf("Parse = %+v", m)
+	}
+	if m, err := Parse(""); err != nil || m != nil {
+		t.Errorf("Parse(\"\") = %v, %v; want nil, nil", m, err)
+	}
+	for _, bad := range []string{"/photos", ":/photos", "/volume1/photos:", "/volume1/photos:photos"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) err = nil, want error", bad)
+		}
+	}
+}
+
+func TestMap_ToHostAndToContainer(t *testing.T) {
+	m, err := Parse("/volume1/photos:/photos;/volume1/photos/2020:/photos/2020;/volume2:/data")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	for _, c := range []struct{ container, host string }{
+		{"/photos/a.jpg", "/volume1/photos/a.jpg"},
+		{"/photos", "/volume1/photos"},
+		{"/photos/2020/b.jpg", "/volume1/photos/2020/b.jpg"},
+		{"/data/x", "/volume2/x"},
+		{"/photos2/c.jpg", "/photos2/c.jpg"}, // not under /photos
+		{"/other", "/other"},
+	} {
+		if got := m.ToHost(c.container); got != c.host {
+			t.Errorf("ToHost(%q) = %q, want %q", c.container, got, c.host)
+		}
+		if got := m.ToContainer(c.host); got != c.container {
+			t.Errorf("ToContainer(%q) = %q, want %q", c.host, got, c.container)
+		}
+	}
+	var none Map
+	if got := none.ToHost("/photos/a.jpg"); got != "/photos/a.jpg" {
+		t.Errorf("nil Map ToHost = %q", got)
+	}
+}
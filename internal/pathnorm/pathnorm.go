@@ -0,0 +1,94 @@
+// Package pathnorm normalizes the Unicode form of file names, so a share scanned from macOS (which writes
+// names decomposed, NFD) and from Linux (usually composed, NFC) yields the same paths, and finds a file on
+// disk again when its stored path is in another form than its name.
+package pathnorm
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Forms a folder's paths can be stored in.
+const (
+	None = "none" // store names byte for byte as the filesystem returns them (default)
+	NFC  = "nfc"  // composed: "é" is one code point (Linux, Windows)
+	NFD  = "nfd"  // decomposed: "é" is "e" plus a combining accent (macOS)
+)
+
+// Valid reports whether form is None, NFC or NFD.
+func Valid(form string) bool {
+	return form == None || form == NFC || form == NFD
+}
+
+// Normalize returns p in form; None (or an unknown form) returns p unchanged.
+func Normalize(form, p string) string {
+	if isASCII(p) {
+		return p
+	}
+	switch form {
+	case NFC:
+		return norm.NFC.String(p)
+	case NFD:
+		return norm.NFD.String(p)
+	}
+	return p
+}
+
+// Equal reports whether a and b are the same name in any form (canonically equivalent).
+func Equal(a, b string) bool {
+	return a == b || norm.NFC.String(a) == norm.NFC.String(b)
+}
+
+// Resolve returns the path on disk of p, which may name its files in another form than the filesystem does.
+// A p that exists is returned as it is; otherwise each missing component is looked up in its directory by
+// canonical equivalence. When nothing matches, p is returned.
+func Resolve(p string) string {
+	if isASCII(p) {
+		return p
+	}
+	if _, err := os.Lstat(p); !errors.Is(err, fs.ErrNotExist) {
+		return p
+	}
+	p = filepath.Clean(p)
+	dir, name := filepath.Split(p)
+	parent := filepath.Clean(dir)
+	if parent == p {
+		return p
+	}
+	parent = Resolve(parent)
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return p
+	}
+	for _, e := range entries {
+		if Equal(e.Name(), name) {
+			return filepath.Join(parent, e.Name())
+		}
+	}
+	return p
+}
+
+// Open opens p for reading like os.Open, retrying with the name Resolve finds when p does not exist.
+func Open(p string) (*os.File, error) {
+	f, err := os.Open(p) // #nosec G304 -- path comes from the scan ledger
+	if errors.Is(err, fs.ErrNotExist) {
+		if r := Resolve(p); r != p {
+			return os.Open(r) // #nosec G304 -- the same ledger path, as named on disk
+		}
+	}
+	return f, err
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package pathnorm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	composed   = "caf\u00e9"  // é as one code point
+	decomposed = "cafe\u0301" // e plus a combining acute accent
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct{ form, in, want string }{
+		{NFC, "photos/" + decomposed + ".jpg", "photos/" + composed + ".jpg"},
+		{NFD, "photos/" + composed + ".jpg", "photos/" + decomposed + ".jpg"},
+		{None, decomposed, decomposed},
+		{NFC, "plain/ascii.txt", "plain/ascii.txt"},
+	} {
+		if got := Normalize(tc.form, tc.in); got != tc.want {
+			t.Errorf("Normalize(%s, %q) = %q, want %q", tc.form, tc.in, got, tc.want)
+		}
+	}
+	if !Equal(composed, decomposed) || Equal(composed, "cafe") {
+		t.Errorf("Equal does not compare by canonical equivalence")
+	}
+}
+
+func TestResolveAndOpen_findNameInOtherForm(t *testing.T) {
+	root := t.TempDir()
+	onDisk := filepath.Join(root, decomposed, decomposed+".txt")
+	if err := os.MkdirAll(filepath.Dir(onDisk), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(onDisk, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stored := filepath.Join(root, composed, composed+".txt")
+	if _, err := os.Stat(stored); err == nil {
+		t.Skip("filesystem does not distinguish normalization forms")
+	}
+	if got := Resolve(stored); got != onDisk {
+		t.Errorf("Resolve(%q) = %q, want %q", stored, got, onDisk)
+	}
+	f, err := Open(stored)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	f.Close()
+	if missing := filepath.Join(root, composed, "gone.txt"); Resolve(missing) != missing {
+		t.Errorf("Resolve of a missing file changed the path")
+	}
+}
@@ -0,0 +1,166 @@
+// Package photoexport catalogs a photo-library export (a Google Takeout download, extracted or as its .zip/.tgz
+// archives; a folder exported from Apple Photos; or an Apple Photos library) and stores it as an import, so the
+// photos it holds can be checked against a NAS library before the two are merged.
+package photoexport
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/manifest"
+)
+
+// sidecarExtensions are the metadata files exports put next to the photos: Takeout's per-photo and album JSON
+// and its archive_browser.html, Apple's XMP and AAE edit files. They hold no photo, so they are not cataloged.
+var sidecarExtensions = map[string]bool{".json": true, ".xmp": true, ".aae": true, ".html": true, ".htm": true}
+
+// libraryOriginals are the directories of an Apple Photos library (a .photoslibrary package) that hold the
+// imported originals ("Masters" before Photos 5). The rest of the package is thumbnails, renders and the database.
+var libraryOriginals = []string{"originals", "Masters"}
+
+// Stats counts what a catalog run found.
+type Stats struct {
+	Photos   int   // photos and videos cataloged
+	Sidecars int   // metadata files skipped
+	Bytes    int64 // bytes hashed
+}
+
+// IsSidecar reports whether name is an export's metadata file rather than a photo or video.
+func IsSidecar(name string) bool {
+	return sidecarExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// Catalog hashes the photos and videos of the export at root, a directory or a single archive. Archives found in
+// the directory (Takeout splits a download into several) are read in one pass each and their members cataloged
+// under the archive's path (see archive.Path). Paths are relative to root. Files that cannot be read are logged
+// and skipped.
+func Catalog(ctx context.Context, root string) ([]manifest.ExternalEntry, Stats, error) {
+	c := &cataloger{ctx: ctx}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, c.stats, err
+	}
+	if !info.IsDir() {
+		if !archive.IsArchive(root) {
+			return nil, c.stats, errors.New("not a directory or a zip or tar archive: " + root)
+		}
+		err := c.addArchive(root, filepath.Base(root))
+		return c.entries, c.stats, err
+	}
+	if strings.HasSuffix(filepath.Clean(root), ".photoslibrary") {
+		for _, dir := range libraryOriginals {
+			if err := c.walk(root, filepath.Join(root, dir)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, c.stats, err
+			}
+		}
+		return c.entries, c.stats, nil
+	}
+	err = c.walk(root, root)
+	return c.entries, c.stats, err
+}
+
+// cataloger accumulates the entries of one Catalog run.
+type cataloger struct {
+	ctx     context.Context
+	entries []manifest.ExternalEntry
+	stats   Stats
+}
+
+// walk catalogs the files under dir, with paths relative to root.
+func (c *cataloger) walk(root, dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := c.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			log.Printf("[photoexport] skip %s: %v", p, err)
+			if d != nil && d.IsDir() && p != dir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if archive.IsArchive(p) {
+			if err := c.addArchive(p, rel); err != nil {
+				if ctxErr := c.ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				log.Printf("[photoexport] skip %s: %v", p, err)
+			}
+			return nil
+		}
+		if IsSidecar(p) {
+			c.stats.Sidecars++
+			return nil
+		}
+		f, err := os.Open(p) // #nosec G304 -- p is under the export root given on the command line
+		if err != nil {
+			log.Printf("[photoexport] skip %s: %v", p, err)
+			return nil
+		}
+		defer f.Close()
+		if err := c.add(rel, f); err != nil {
+			log.Printf("[photoexport] skip %s: %v", p, err)
+		}
+		return c.ctx.Err()
+	})
+}
+
+// addArchive catalogs the members of the archive at p under the relative path rel.
+func (c *cataloger) addArchive(p, rel string) error {
+	return archive.Read(p, func(m archive.Member, r io.Reader) error {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		if IsSidecar(m.Name) {
+			c.stats.Sidecars++
+			return nil
+		}
+		return c.add(archive.Path(rel, m.Name), r)
+	})
+}
+
+// add hashes r and records it as the photo at rel.
+func (c *cataloger) add(rel string, r io.Reader) error {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return err
+	}
+	c.stats.Photos++
+	c.stats.Bytes += n
+	c.entries = append(c.entries, manifest.ExternalEntry{Path: rel, Size: n, Hash: hex.EncodeToString(h.Sum(nil))})
+	return nil
+}
+
+// Import catalogs the export at root and stores it as a new scan of the import name. Returns the scan id and what
+// the catalog found.
+func Import(ctx context.Context, database *sql.DB, name, root string) (int64, Stats, error) {
+	entries, st, err := Catalog(ctx, root)
+	if err != nil {
+		return 0, st, err
+	}
+	scanID, err := manifest.Import(ctx, database, name, "", entries)
+	return scanID, st, err
+}
@@ -0,0 +1,80 @@
+package photoexport
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCatalog_takeoutDirectoryAndArchive(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"Takeout/Google Photos/Trip/IMG_1.jpg":      "photo one",
+		"Takeout/Google Photos/Trip/IMG_1.jpg.json": `{"title":"IMG_1.jpg"}`,
+		"Takeout/Google Photos/Trip/metadata.json":  `{"title":"Trip"}`,
+		"Takeout/archive_browser.html":              "<html>",
+	})
+	zf, err := os.Create(filepath.Join(root, "takeout-002.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	for name, content := range map[string]string{"Takeout/Google Photos/Trip/IMG_2.mp4": "video", "Takeout/Google Photos/Trip/IMG_2.mp4.json": "{}"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zf.Close()
+
+	entries, st, err := Catalog(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Catalog: %v", err)
+	}
+	got := map[string]int64{}
+	for _, e := range entries {
+		got[e.Path] = e.Size
+	}
+	want := map[string]int64{"Takeout/Google Photos/Trip/IMG_1.jpg": 9, "takeout-002.zip!/Takeout/Google Photos/Trip/IMG_2.mp4": 5}
+	if len(got) != len(want) || got["Takeout/Google Photos/Trip/IMG_1.jpg"] != 9 || got["takeout-002.zip!/Takeout/Google Photos/Trip/IMG_2.mp4"] != 5 {
+		t.Errorf("Catalog = %v, want %v", got, want)
+	}
+	if st.Photos != 2 || st.Sidecars != 4 || st.Bytes != 14 {
+		t.Errorf("stats = %+v, want 2 photos (14 bytes) and 4 sidecars", st)
+	}
+}
+
+func TestCatalog_applePhotosLibraryOnlyOriginals(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "Photos Library.photoslibrary")
+	writeFiles(t, root, map[string]string{
+		"originals/A/IMG_1.HEIC":          "original",
+		"resources/derivatives/IMG_1.jpg": "render",
+		"database/Photos.sqlite":          "db",
+	})
+	entries, _, err := Catalog(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Catalog: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "originals/A/IMG_1.HEIC" {
+		t.Errorf("Catalog = %+v, want only originals/A/IMG_1.HEIC", entries)
+	}
+}
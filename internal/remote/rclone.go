@@ -0,0 +1,124 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Rclone is the Backend for a path on an rclone remote ("s3:bucket/photos", "b2:archive", "webdav:"), listed
+// with "rclone lsjson" and read with "rclone cat". Remotes are configured with "rclone config" as usual.
+type Rclone struct {
+	binary string
+	root   string
+}
+
+// NewRclone returns the Backend for root, an rclone remote path. The rclone binary must be on PATH.
+func NewRclone(root string) (*Rclone, error) {
+	if !strings.Contains(root, ":") {
+		return nil, fmt.Errorf("%q is not an rclone remote path (want <remote>:<path>)", root)
+	}
+	bin, err := exec.LookPath("rclone")
+	if err != nil {
+		return nil, errors.New("rclone not found on PATH")
+	}
+	return &Rclone{binary: bin, root: root}, nil
+}
+
+// Host returns the name of root's remote ("s3" for "s3:bucket/photos"), to record as the host of its files.
+func (r *Rclone) Host() string {
+	name, _, _ := strings.Cut(r.root, ":")
+	return name
+}
+
+// List runs "rclone lsjson -R --files-only --hash --hash-type sha256" on the root and decodes its output as it
+// streams, so listing a large bucket does not hold the whole listing in memory.
+func (r *Rclone) List(ctx context.Context, fn func(Entry) error) error {
+	cmd := exec.CommandContext(ctx, r.binary, "lsjson", "-R", "--files-only", "--no-mimetype", "--hash", "--hash-type", "sha256", r.root) // #nosec G204 -- fixed tool, root is an argument
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	err = parseLsjson(out, fn)
+	if err != nil {
+		_ = cmd.Process.Kill()
+	}
+	if werr := cmd.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("rclone lsjson %s: %v: %s", r.root, werr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// Open runs "rclone cat" on the file at path under the root and returns its output.
+func (r *Rclone) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, r.binary, "cat", joinRemote(r.root, path)) // #nosec G204 -- fixed tool, path is an argument
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdReader{ReadCloser: out, cmd: cmd}, nil
+}
+
+// cmdReader is a command's output; Close waits for the command and reports its failure.
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c *cmdReader) Close() error {
+	_ = c.ReadCloser.Close()
+	return c.cmd.Wait()
+}
+
+// joinRemote appends a path relative to root to an rclone remote path.
+func joinRemote(root, path string) string {
+	if strings.HasSuffix(root, ":") || strings.HasSuffix(root, "/") {
+		return root + path
+	}
+	return root + "/" + path
+}
+
+// lsjsonEntry is the part of an "rclone lsjson" item the catalog uses.
+type lsjsonEntry struct {
+	Path   string
+	Size   int64
+	IsDir  bool
+	Hashes map[string]string
+}
+
+// parseLsjson decodes the JSON array "rclone lsjson" prints and calls fn for each file in it.
+func parseLsjson(r io.Reader, fn func(Entry) error) error {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("rclone lsjson: %w", err)
+	}
+	for dec.More() {
+		var e lsjsonEntry
+		if err := dec.Decode(&e); err != nil {
+			return fmt.Errorf("rclone lsjson: %w", err)
+		}
+		if e.IsDir {
+			continue
+		}
+		if e.Size < 0 {
+			e.Size = 0 // rclone prints -1 when the provider does not know the size
+		}
+		if err := fn(Entry{Path: e.Path, Size: e.Size, Hash: e.Hashes["sha256"]}); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,113 @@
+// Package remote catalogs files on storage ditto cannot walk as a local directory (S3, B2, WebDAV, ...) through a
+// Backend, and stores the catalog as an import (see manifest.Import) so it can be compared with local folders and
+// take part in duplicate groups. Rclone is the Backend for every provider rclone supports.
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/eargollo/ditto/internal/manifest"
+)
+
+// Entry is one file of a remote. Path is relative to the backend's root; Hash is the hex SHA-256 the provider
+// stores for it, or "" when it stores none (S3 and B2 only keep MD5 or SHA-1, which ditto cannot compare).
+type Entry struct {
+	Path string
+	Size int64
+	Hash string
+}
+
+// Backend lists and reads the files under one remote root.
+type Backend interface {
+	// List calls fn for every file under the root (directories are not listed). An error from fn stops the listing.
+	List(ctx context.Context, fn func(Entry) error) error
+	// Open returns the content of the file at path (relative to the root), for hashing files listed without a hash.
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// Options tunes a catalog run.
+type Options struct {
+	MinSize  int64 // skip files smaller than this many bytes (0: all)
+	Download bool  // read and hash files the provider stores no SHA-256 for; otherwise they are skipped
+}
+
+// Stats counts what a catalog run did with the remote's files.
+type Stats struct {
+	Listed     int   // files listed (of at least MinSize)
+	Downloaded int   // files read and hashed because the provider had no SHA-256
+	Skipped    int   // files left out: no SHA-256 and Download off, or reading them failed
+	ReadBytes  int64 // bytes read to hash downloaded files
+}
+
+// Catalog lists the backend and returns one entry per file with a SHA-256: the provider's, or one computed by
+// reading the file when opts.Download is set. Files that cannot be read are logged and skipped.
+func Catalog(ctx context.Context, b Backend, opts Options) ([]manifest.ExternalEntry, Stats, error) {
+	var out []manifest.ExternalEntry
+	var st Stats
+	err := b.List(ctx, func(e Entry) error {
+		if e.Size < opts.MinSize {
+			return nil
+		}
+		st.Listed++
+		if e.Hash == "" {
+			if !opts.Download {
+				st.Skipped++
+				return nil
+			}
+			h, n, err := hashContent(ctx, b, e.Path)
+			st.ReadBytes += n
+			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				log.Printf("[remote] skip %s: %v", e.Path, err)
+				st.Skipped++
+				return nil
+			}
+			st.Downloaded++
+			e.Hash = h
+		}
+		out = append(out, manifest.ExternalEntry{Path: e.Path, Size: e.Size, Hash: strings.ToLower(e.Hash)})
+		return nil
+	})
+	return out, st, err
+}
+
+// hashContent reads the file at path from the backend and returns its SHA-256 and the bytes read.
+func hashContent(ctx context.Context, b Backend, path string) (string, int64, error) {
+	r, err := b.Open(ctx, path)
+	if err != nil {
+		return "", 0, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Import catalogs the backend and stores the result as a new scan of the import name, recording host as the
+// machine (here: the remote) the files are on. Returns the scan id and what the catalog run did.
+func Import(ctx context.Context, database *sql.DB, name, host string, b Backend, opts Options) (int64, Stats, error) {
+	entries, st, err := Catalog(ctx, b, opts)
+	if err != nil {
+		return 0, st, fmt.Errorf("list remote: %w", err)
+	}
+	if len(entries) == 0 && st.Listed > 0 {
+		return 0, st, errors.New("the remote stores no SHA-256 for its files; hash them by reading them (download)")
+	}
+	scanID, err := manifest.Import(ctx, database, name, host, entries)
+	return scanID, st, err
+}
@@ -0,0 +1,98 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeBackend serves files from memory; hashes holds the SHA-256 the "provider" stores, when any.
+type fakeBackend struct {
+	files  map[string]string
+	hashes map[string]string
+	opened []string
+}
+
+func (f *fakeBackend) List(ctx context.Context, fn func(Entry) error) error {
+	for path, content := range f.files {
+		if err := fn(Entry{Path: path, Size: int64(len(content)), Hash: f.hashes[path]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeBackend) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	f.opened = append(f.opened, path)
+	if path == "broken.bin" {
+		return nil, errors.New("read failed")
+	}
+	return io.NopCloser(strings.NewReader(f.files[path])), nil
+}
+
+// sha256 of "same"
+const sameHash = "0967115f2813a3541eaef77de9d9d5773f1c0c04314b0bbfe4ff3b3b1c55b5d5"
+
+func TestCatalog_usesStoredHashesAndDownloadsTheRest(t *testing.T) {
+	b := &fakeBackend{
+		files:  map[string]string{"stored.jpg": "same", "unhashed.jpg": "same", "broken.bin": "xxxx", "tiny": "x"},
+		hashes: map[string]string{"stored.jpg": strings.ToUpper(sameHash)},
+	}
+	entries, st, err := Catalog(context.Background(), b, Options{MinSize: 2})
+	if err != nil {
+		t.Fatalf("Catalog: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "stored.jpg" || entries[0].Hash != sameHash || len(b.opened) != 0 {
+		t.Errorf("Catalog without download = %+v (opened %q); want only stored.jpg, nothing read", entries, b.opened)
+	}
+	if st.Listed != 3 || st.Skipped != 2 {
+		t.Errorf("stats = %+v; want 3 listed, 2 skipped", st)
+	}
+
+	entries, st, err = Catalog(context.Background(), b, Options{MinSize: 2, Download: true})
+	if err != nil {
+		t.Fatalf("Catalog: %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		got[e.Path] = e.Hash
+	}
+	if len(got) != 2 || got["unhashed.jpg"] != sameHash || got["stored.jpg"] != sameHash {
+		t.Errorf("Catalog with download = %v; want stored.jpg and unhashed.jpg with the same hash", got)
+	}
+	if st.Downloaded != 1 || st.Skipped != 1 || st.ReadBytes != 4 {
+		t.Errorf("stats = %+v; want 1 downloaded (4 bytes), broken.bin skipped", st)
+	}
+}
+
+func TestParseLsjson_filesWithSHA256(t *testing.T) {
+	out := `[
+{"Path":"photos","Name":"photos","Size":-1,"IsDir":true},
+{"Path":"photos/a.jpg","Name":"a.jpg","Size":4,"IsDir":false,"Hashes":{"sha256":"` + sameHash + `"}},
+{"Path":"b.jpg","Name":"b.jpg","Size":-1,"IsDir":false,"Hashes":{"md5":"abc"}}
+]`
+	var got []Entry
+	if err := parseLsjson(strings.NewReader(out), func(e Entry) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatalf("parseLsjson: %v", err)
+	}
+	want := []Entry{{Path: "photos/a.jpg", Size: 4, Hash: sameHash}, {Path: "b.jpg", Size: 0}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("parseLsjson = %+v, want %+v", got, want)
+	}
+	if err := parseLsjson(strings.NewReader(`not json`), func(Entry) error { return nil }); err == nil {
+		t.Error("parseLsjson of garbage = nil error, want one")
+	}
+}
+
+func TestJoinRemote(t *testing.T) {
+	for root, want := range map[string]string{"s3:": "s3:a/b", "s3:bucket": "s3:bucket/a/b", "s3:bucket/": "s3:bucket/a/b"} {
+		if got := joinRemote(root, "a/b"); got != want {
+			t.Errorf("joinRemote(%q) = %q, want %q", root, got, want)
+		}
+	}
+}
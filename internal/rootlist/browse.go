@@ -0,0 +1,74 @@
+package rootlist
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// systemPaths are the filesystem root and the operating system's own directories. A scan root there would walk
+// the whole system (or its programs), never a library worth deduplicating.
+var systemPaths = []string{"/", "/bin", "/boot", "/etc", "/lib", "/lib32", "/lib64", "/sbin", "/usr", "/var", "/System"}
+
+// virtualPaths hold kernel filesystems: nothing below them is a stored file.
+var virtualPaths = []string{"/dev", "/proc", "/run", "/sys"}
+
+// IsSystemPath reports whether p (absolute and clean) is a system directory or lies in a kernel filesystem,
+// which cannot be a scan root.
+func IsSystemPath(p string) bool {
+	if slices.Contains(systemPaths, p) {
+		return true
+	}
+	for _, v := range virtualPaths {
+		if Within(p, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Within reports whether p is dir or lies below it. Both must be absolute and clean.
+func Within(p, dir string) bool {
+	if p == dir || dir == "/" {
+		return strings.HasPrefix(p, "/")
+	}
+	return strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// containingRoot returns the registered root that p lies strictly below, the outermost one when roots are
+// nested; ok is false when there is none.
+func containingRoot(p string, roots map[string]int64) (root string, ok bool) {
+	for r := range roots {
+		if r != p && Within(p, r) && (!ok || len(r) < len(root)) {
+			root, ok = r, true
+		}
+	}
+	return root, ok
+}
+
+// ListDirs returns the names of dir's subdirectories (symlinks to directories included), sorted, leaving out
+// system directories; they are what a scan root can be chosen from.
+func ListDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, d := range entries {
+		p := filepath.Join(dir, d.Name())
+		if IsSystemPath(p) {
+			continue
+		}
+		if !d.IsDir() {
+			if d.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+				continue
+			}
+		}
+		names = append(names, d.Name())
+	}
+	return names, nil
+}
@@ -0,0 +1,57 @@
+package rootlist
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIsSystemPath(t *testing.T) {
+	for p, want := range map[string]bool{
+		"/": true, "/etc": true, "/usr": true, "/proc/1": true, "/dev": true,
+		"/usr/local/photos": false, "/volume1/photos": false, "/home": false, "/devices": false,
+	} {
+		if got := IsSystemPath(p); got != want {
+			t.Errorf("IsSystemPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestWithinAndContainingRoot(t *testing.T) {
+	if !Within("/data/photos", "/data") || !Within("/data", "/data") || !Within("/x", "/") || Within("/database", "/data") {
+		t.Error("Within: wrong answer for a prefix that is or is not a parent directory")
+	}
+	roots := map[string]int64{"/data": 1, "/data/photos": 2, "import:laptop": 3}
+	if r, ok := containingRoot("/data/photos/2024", roots); !ok || r != "/data" {
+		t.Errorf("containingRoot = %q, %v; want the outermost root /data", r, ok)
+	}
+	if _, ok := containingRoot("/data", roots); ok {
+		t.Error("containingRoot: a root does not contain itself")
+	}
+	if _, ok := containingRoot("/music", roots); ok {
+		t.Error("containingRoot: /music is in no root")
+	}
+}
+
+func TestListDirs(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"b", "a"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "f.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ListDirs(dir)
+	if err != nil {
+		t.Fatalf("ListDirs: %v", err)
+	}
+	if want := []string{"a", "b", "link"}; !slices.Equal(got, want) {
+		t.Errorf("ListDirs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,366 @@
+// Package rootlist registers many scan roots at once from a plain path list or a CSV with per-root settings.
+package rootlist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// Entry is one root listed in the input, with the settings to apply when it is added.
+type Entry struct {
+	Line               int // 1-based line in the input
+	Path               string
+	MaxReadBytesPerSec int64 // 0 = unlimited
+	LowPriority        bool
+	SimilarImages      bool
+	SimilarMedia       bool
+	PhotoMetadata      bool
+	OneFileSystem      bool
+	Archives           bool
+	MediaLabel         string
+	MediaImage         string
+	Symlinks           string // db.SymlinksSkip, db.SymlinksRecord or db.SymlinksFollow; "" = default (skip)
+	NetworkFS          string // db.NetworkFSAuto, db.NetworkFSOn or db.NetworkFSOff; "" = default (auto)
+	Err                error  // set when the line could not be parsed; Import reports it without touching the database
+}
+
+// Result statuses reported by Import.
+const (
+	StatusAdded  = "added"
+	StatusExists = "exists"
+	StatusError  = "error"
+)
+
+// Result is the outcome of importing one Entry.
+type Result struct {
+	Line     int
+	Path     string
+	FolderID int64 // 0 when the root was not added and did not already exist
+	Status   string
+	Err      error
+}
+
+// csvColumns are the recognised CSV header names; only path is required.
+var csvColumns = map[string]bool{
+	"path": true, "max_read_mbps": true, "low_priority": true, "similar_images": true,
+	"similar_media": true, "media_label": true, "media_image": true, "symlinks": true,
+	"network_fs": true, "one_file_system": true, "photo_metadata": true, "archives": true,
+}
+
+// Parse reads a list of roots. Two formats are accepted, detected from the first non-comment line:
+//   - one path per line
+//   - CSV with a header row naming path and optionally max_read_mbps, low_priority, similar_images,
+//     similar_media, photo_metadata, one_file_system, archives, media_label, media_image, symlinks and
+//     network_fs, in any order
+//
+// Blank lines and lines starting with "#" are ignored. Lines that cannot be parsed are returned with Err set,
+// so the caller can report them next to the ones that could.
+func Parse(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	if isCSV(data) {
+		return parseCSV(data)
+	}
+	var out []Entry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		p := strings.TrimSpace(sc.Text())
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		out = append(out, Entry{Line: line, Path: p})
+	}
+	return out, sc.Err()
+}
+
+// isCSV reports whether the first non-comment line is a CSV header naming a path column.
+func isCSV(data []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		for _, h := range strings.Split(l, ",") {
+			if strings.EqualFold(strings.TrimSpace(h), "path") {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func parseCSV(data []byte) ([]Entry, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !csvColumns[h] {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		col[h] = i
+	}
+	var out []Entry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				out = append(out, Entry{Line: pe.StartLine, Err: pe.Err})
+				continue
+			}
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		e := Entry{Line: line, Path: field("path")}
+		if e.Path == "" {
+			continue
+		}
+		e.Err = parseSettings(&e, field)
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// parseSettings fills e's settings from the CSV fields, with the same rules as the per-root settings form.
+func parseSettings(e *Entry, field func(string) string) error {
+	if v := field("max_read_mbps"); v != "" {
+		mbps, err := strconv.ParseFloat(v, 64)
+		if err != nil || mbps < 0 {
+			return fmt.Errorf("invalid max_read_mbps %q", v)
+		}
+		e.MaxReadBytesPerSec = int64(mbps * 1024 * 1024)
+	}
+	for _, b := range []struct {
+		name string
+		dst  *bool
+	}{{"low_priority", &e.LowPriority}, {"similar_images", &e.SimilarImages}, {"similar_media", &e.SimilarMedia}, {"photo_metadata", &e.PhotoMetadata}, {"one_file_system", &e.OneFileSystem}, {"archives", &e.Archives}} {
+		v := field(b.name)
+		if v == "" {
+			continue
+		}
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", b.name, v)
+		}
+		*b.dst = on
+	}
+	e.MediaLabel = field("media_label")
+	e.MediaImage = field("media_image")
+	if e.MediaImage != "" && e.MediaLabel == "" {
+		return errors.New("media_image requires media_label")
+	}
+	e.Symlinks = strings.ToLower(field("symlinks"))
+	if e.Symlinks != "" && !db.ValidSymlinkMode(e.Symlinks) {
+		return db.ErrInvalidSymlinkMode
+	}
+	e.NetworkFS = strings.ToLower(field("network_fs"))
+	if e.NetworkFS != "" && !db.ValidNetworkFSMode(e.NetworkFS) {
+		return db.ErrInvalidNetworkFSMode
+	}
+	return nil
+}
+
+// Import adds each valid entry as a scan root and applies its settings, returning one Result per entry in order.
+// Paths must be absolute and, unless the entry is offline media (which may be unplugged), an existing directory.
+// System directories (see IsSystemPath) and paths inside a registered root are refused. Roots that are already
+// registered, or listed twice, are reported as StatusExists and left unchanged.
+// Only database errors abort the import; the results so far are returned with the error.
+func Import(ctx context.Context, database *sql.DB, entries []Entry) ([]Result, error) {
+	existing, err := db.ListFolders(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]int64, len(existing)+len(entries))
+	for _, f := range existing {
+		known[f.Path] = f.ID
+	}
+	results := make([]Result, 0, len(entries))
+	for _, e := range entries {
+		res := Result{Line: e.Line, Path: e.Path}
+		if err := validate(&e); err != nil {
+			res.Status, res.Err = StatusError, err
+			results = append(results, res)
+			continue
+		}
+		res.Path = e.Path
+		if id, ok := known[e.Path]; ok {
+			res.Status, res.FolderID = StatusExists, id
+			results = append(results, res)
+			continue
+		}
+		if root, ok := containingRoot(e.Path, known); ok {
+			res.Status, res.Err = StatusError, fmt.Errorf("inside scan root %s", root)
+			results = append(results, res)
+			continue
+		}
+		id, err := add(ctx, database, e)
+		if err != nil {
+			return results, fmt.Errorf("line %d: %w", e.Line, err)
+		}
+		known[e.Path] = id
+		res.Status, res.FolderID = StatusAdded, id
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// validate checks a parsed entry and normalizes its path the way folders are stored.
+func validate(e *Entry) error {
+	if e.Err != nil {
+		return e.Err
+	}
+	if !filepath.IsAbs(e.Path) {
+		return errors.New("path must be absolute")
+	}
+	e.Path = filepath.Clean(e.Path)
+	if IsSystemPath(e.Path) {
+		return errors.New("system directory, not a scan root")
+	}
+	if e.MediaLabel != "" {
+		return nil
+	}
+	fi, err := os.Stat(e.Path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("not a directory")
+	}
+	return nil
+}
+
+// ErrInvalidRoot is returned by Move for a path that cannot be a scan root.
+var ErrInvalidRoot = errors.New("invalid scan root")
+
+// Move points the registered root id at path, after its files were moved there, keeping its files and scans
+// (see db.MoveFolder). The path is checked like a new root in Import: offline media may be unplugged, other
+// roots must exist as directories. Returns the path as stored.
+func Move(ctx context.Context, database *sql.DB, id int64, path string, staleAfter time.Duration) (string, error) {
+	folders, err := db.ListFolders(ctx, database)
+	if err != nil {
+		return "", err
+	}
+	i := slices.IndexFunc(folders, func(f db.Folder) bool { return f.ID == id })
+	if i < 0 {
+		return "", sql.ErrNoRows
+	}
+	e := Entry{Path: path, MediaLabel: folders[i].MediaLabel}
+	if err := validate(&e); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRoot, err)
+	}
+	known := make(map[string]int64, len(folders))
+	for _, f := range folders {
+		if f.ID != id {
+			known[f.Path] = f.ID
+		}
+	}
+	if root, ok := containingRoot(e.Path, known); ok {
+		return "", fmt.Errorf("%w: inside scan root %s", ErrInvalidRoot, root)
+	}
+	return e.Path, db.MoveFolder(ctx, database, id, e.Path, staleAfter)
+}
+
+func add(ctx context.Context, database *sql.DB, e Entry) (int64, error) {
+	id, err := db.AddScanRoot(ctx, database, e.Path)
+	if err != nil {
+		return 0, err
+	}
+	if e.MaxReadBytesPerSec > 0 || e.LowPriority {
+		if err := db.UpdateFolderIOSettings(ctx, database, id, e.MaxReadBytesPerSec, e.LowPriority); err != nil {
+			return 0, err
+		}
+	}
+	if e.SimilarImages {
+		if err := db.UpdateFolderSimilarImages(ctx, database, id, true); err != nil {
+			return 0, err
+		}
+	}
+	if e.SimilarMedia {
+		if err := db.UpdateFolderSimilarMedia(ctx, database, id, true); err != nil {
+			return 0, err
+		}
+	}
+	if e.PhotoMetadata {
+		if err := db.UpdateFolderPhotoMetadata(ctx, database, id, true); err != nil {
+			return 0, err
+		}
+	}
+	if e.OneFileSystem {
+		if err := db.UpdateFolderOneFileSystem(ctx, database, id, true); err != nil {
+			return 0, err
+		}
+	}
+	if e.Archives {
+		if err := db.UpdateFolderArchives(ctx, database, id, true); err != nil {
+			return 0, err
+		}
+	}
+	if e.MediaLabel != "" {
+		if err := db.UpdateFolderMedia(ctx, database, id, e.MediaLabel, e.MediaImage); err != nil {
+			return 0, err
+		}
+	}
+	if e.Symlinks != "" {
+		if err := db.UpdateFolderSymlinks(ctx, database, id, e.Symlinks); err != nil {
+			return 0, err
+		}
+	}
+	if e.NetworkFS != "" {
+		if err := db.UpdateFolderNetworkFS(ctx, database, id, e.NetworkFS); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// Counts returns how many results have each status.
+func Counts(results []Result) (added, exists, failed int) {
+	for _, r := range results {
+		switch r.Status {
+		case StatusAdded:
+			added++
+		case StatusExists:
+			exists++
+		default:
+			failed++
+		}
+	}
+	return added, exists, failed
+}
@@ -0,0 +1,97 @@
+package rootlist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse_plainList(t *testing.T) {
+	in := "# NAS shares\n/volume1/photos\n\n  /volume1/music  \r\n#/volume1/old\n/volume2/backup\n"
+	entries, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Entry{{Line: 2, Path: "/volume1/photos"}, {Line: 4, Path: "/volume1/music"}, {Line: 6, Path: "/volume2/backup"}}
+	if len(entries) != len(want) {
+		t.Fatalf("Parse returned %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		if entries[i] != w {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], w)
+		}
+	}
+}
+
+func TestParse_csvWithSettings(t *testing.T) {
+	in := "path,low_priority,max_read_mbps,media_label,media_image,symlinks,network_fs,one_file_system\n" +
+		"/volume1/photos,true,20,,,Follow,ON,1\n" +
+		"\"/mnt/usb, old\",,,Blue drive,,,,\n" +
+		"/volume1/music,maybe,,,,,,\n" +
+		"/volume1/iso,,,,/images/a.iso,,,\n" +
+		"/volume1/farm,,,,,sometimes,,\n" +
+		"/mnt/nas,,,,,,flaky,\n"
+	entries, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("Parse returned %d entries, want 6: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Line != 2 || !e.LowPriority || e.MaxReadBytesPerSec != 20*1024*1024 || e.Symlinks != "follow" || e.NetworkFS != "on" || !e.OneFileSystem || e.Err != nil {
+		t.Errorf("entry 0 = %+v, want line 2, low priority, 20 MB/s, follow symlinks, network share on, one filesystem", e)
+	}
+	if e := entries[1]; e.Path != "/mnt/usb, old" || e.MediaLabel != "Blue drive" || e.Err != nil {
+		t.Errorf("entry 1 = %+v, want quoted path with media label", e)
+	}
+	if entries[2].Err == nil {
+		t.Error("entry 2: Err = nil, want error for low_priority=maybe")
+	}
+	if entries[3].Err == nil {
+		t.Error("entry 3: Err = nil, want error for media_image without media_label")
+	}
+	if entries[4].Err == nil {
+		t.Error("entry 4: Err = nil, want error for symlinks=sometimes")
+	}
+	if entries[5].Err == nil {
+		t.Error("entry 5: Err = nil, want error for network_fs=flaky")
+	}
+
+	if _, err := Parse(strings.NewReader("path,colour\n/a,red\n")); err == nil {
+		t.Error("Parse: err = nil, want error for unknown column")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(file, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		e    Entry
+		ok   bool
+		name string
+	}{
+		{Entry{Path: dir + "/./"}, true, "existing directory"},
+		{Entry{Path: "relative/dir"}, false, "relative path"},
+		{Entry{Path: file}, false, "regular file"},
+		{Entry{Path: filepath.Join(dir, "missing")}, false, "missing directory"},
+		{Entry{Path: filepath.Join(dir, "missing"), MediaLabel: "USB"}, true, "unplugged offline media"},
+		{Entry{Path: "/"}, false, "filesystem root"},
+		{Entry{Path: "/proc/self", MediaLabel: "USB"}, false, "kernel filesystem"},
+	}
+	for _, c := range cases {
+		e := c.e
+		err := validate(&e)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: validate err = %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+	e := Entry{Path: dir + "/./"}
+	_ = validate(&e)
+	if e.Path != dir {
+		t.Errorf("validate path = %q, want cleaned %q", e.Path, dir)
+	}
+}
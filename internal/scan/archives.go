@@ -0,0 +1,58 @@
+package scan
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"golang.org/x/time/rate"
+)
+
+// sendArchiveMembers sends an Entry for each regular file inside the archive at absPath, under its virtual path
+// (archive.Path). An archive that cannot be read is logged and recorded in errs, not returned: the archive itself
+// is still cataloged. Only a cancelled context ends the walk.
+func sendArchiveMembers(ctx context.Context, rootPath, absPath string, deviceID *int64, excludes *Excludes, includes *Includes, limits *walkLimits, limiter *rate.Limiter,
+	fileChan chan<- Entry, metrics *ScanMetrics, errs *errorLog) error {
+	err := archive.List(absPath, func(m archive.Member) error {
+		p := archive.Path(absPath, m.Name)
+		if !includes.Allows(rootPath, p, false) || excludes.Excluded(rootPath, p, false) {
+			metrics.Skipped.Add(1)
+			return nil
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		e := Entry{Path: p, Size: m.Size, MTime: m.MTime, Inode: memberInode(p, m.MTime), DeviceID: deviceID}
+		sendStart := time.Now()
+		select {
+		case fileChan <- e:
+			metrics.sent(sendStart)
+			limits.sent(metrics.FilesWalked.Load())
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		metrics.Skipped.Add(1)
+		log.Printf("[scan] skipped (unreadable archive): %s: %v", absPath, err)
+		errs.add(absPath, err)
+		return nil
+	}
+	return err
+}
+
+// memberInode returns the inode recorded for a file inside an archive. Members have no inode of their own; a
+// negative number (real inodes are never negative) derived from the virtual path and modification time keeps
+// them out of hardlink groups and lets an unchanged member reuse its hash like an unchanged file.
+func memberInode(p string, mtime int64) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(p))
+	_, _ = h.Write([]byte(strconv.FormatInt(mtime, 10)))
+	return -int64(h.Sum64()>>1) - 1
+}
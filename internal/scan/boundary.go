@@ -0,0 +1,38 @@
+package scan
+
+import (
+	"log"
+	"os"
+)
+
+// fsBoundary keeps a walk on the filesystem holding its root, like find -xdev or rsync --one-file-system.
+// A nil boundary lets the walk cross into other filesystems mounted under the root, which is the default.
+type fsBoundary struct {
+	dev int64
+}
+
+// newFSBoundary returns the boundary of rootPath when sameDevice is set, or nil. Without a device id for the
+// root (some platforms and filesystems have none) the walk is not limited.
+func newFSBoundary(rootPath string, sameDevice bool) *fsBoundary {
+	if !sameDevice {
+		return nil
+	}
+	info, err := os.Stat(rootPath)
+	if err != nil {
+		return nil // the walk reports the error
+	}
+	if _, dev := inodeAndDev(rootPath, info); dev != 0 {
+		return &fsBoundary{dev: dev}
+	}
+	log.Printf("[scan] %s: no device id, cannot stay on one filesystem", rootPath)
+	return nil
+}
+
+// crosses reports whether the directory at path, described by info, is on another filesystem than the root.
+func (b *fsBoundary) crosses(path string, info os.FileInfo) bool {
+	if b == nil || info == nil {
+		return false
+	}
+	_, dev := inodeAndDev(path, info)
+	return dev != 0 && dev != b.dev
+}
@@ -0,0 +1,34 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFSBoundary_crossesOnlyOtherFilesystems(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	subInfo, _ := os.Lstat(sub)
+	if b := newFSBoundary(dir, false); b != nil || b.crosses(sub, subInfo) {
+		t.Fatalf("disabled boundary = %+v, want nil that never crosses", b)
+	}
+	b := newFSBoundary(dir, true)
+	if b == nil {
+		t.Skip("no device id for the temp dir on this platform")
+	}
+	if b.crosses(sub, subInfo) {
+		t.Error("crosses(subdir on the same filesystem) = true, want false")
+	}
+	// /proc is its own filesystem on Linux; elsewhere there is no portable second mount to compare with.
+	procInfo, err := os.Stat("/proc")
+	if err != nil {
+		return
+	}
+	if _, dev := inodeAndDev("/proc", procInfo); dev != 0 && dev != b.dev && !b.crosses("/proc", procInfo) {
+		t.Error("crosses(/proc) = false, want true for another filesystem")
+	}
+}
@@ -0,0 +1,39 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/volume"
+)
+
+// deviceMap turns the st_dev numbers the walkers read into the device ids files store (db.ResolveDevice), which
+// stay the same when the filesystem is mounted again under another number. Each device is looked up once per
+// scan; the writers share one map.
+type deviceMap struct {
+	database *sql.DB
+	folderID int64 // folder being scanned: only its legacy devices are adopted (see db.ResolveDevice)
+	mu       sync.Mutex
+	ids      map[int64]int64 // st_dev -> devices.id
+}
+
+func newDeviceMap(database *sql.DB, folderID int64) *deviceMap {
+	return &deviceMap{database: database, folderID: folderID, ids: make(map[int64]int64)}
+}
+
+// id returns the stored device id of st_dev dev, which holds path.
+func (m *deviceMap) id(ctx context.Context, path string, dev int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.ids[dev]; ok {
+		return id, nil
+	}
+	id, err := db.ResolveDevice(ctx, m.database, m.folderID, dev, volume.DeviceKey(path, uint64(dev))) // #nosec G115 -- st_dev read back from its unsigned value
+	if err != nil {
+		return 0, err
+	}
+	m.ids[dev] = id
+	return id, nil
+}
@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log"
+	"sync"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// maxScanErrors caps the walk errors kept for a scan's error list; further errors are only logged.
+const maxScanErrors = 1000
+
+// errorLog collects the walk's errors (unreadable directories and files) for the scan's error list.
+type errorLog struct {
+	mu      sync.Mutex
+	list    []db.ScanError
+	dropped int
+}
+
+func (l *errorLog) add(path string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.list) >= maxScanErrors {
+		l.dropped++
+		return
+	}
+	l.list = append(l.list, db.ScanError{Path: path, Phase: db.PhaseScan, Error: err.Error(), CreatedAt: db.NowUTC()})
+}
+
+// flush stores the collected errors on the scan.
+func (l *errorLog) flush(ctx context.Context, database *sql.DB, scanID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.list) == 0 {
+		return
+	}
+	if l.dropped > 0 {
+		log.Printf("[scan] scan %d: %d errors, only the first %d kept in the error list", scanID, len(l.list)+l.dropped, len(l.list))
+	}
+	if err := db.InsertScanErrors(ctx, database, scanID, l.list); err != nil {
+		log.Printf("[scan] record errors for scan %d: %v", scanID, err)
+	}
+}
+
+// errorPath returns the path err is about (the file of a failed Lstat, for instance), or dir.
+func errorPath(dir string, err error) string {
+	var pe *fs.PathError
+	if errors.As(err, &pe) && pe.Path != "" {
+		return pe.Path
+	}
+	return dir
+}
@@ -0,0 +1,23 @@
+package scan
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestErrorLog_capsAndUsesPathOfPathError(t *testing.T) {
+	var l errorLog
+	for i := 0; i < maxScanErrors+5; i++ {
+		l.add("/d", errors.New("boom"))
+	}
+	if len(l.list) != maxScanErrors || l.dropped != 5 {
+		t.Errorf("errorLog kept %d, dropped %d; want %d and 5", len(l.list), l.dropped, maxScanErrors)
+	}
+	if got := errorPath("/d", &fs.PathError{Op: "lstat", Path: "/d/f", Err: errors.New("eio")}); got != "/d/f" {
+		t.Errorf("errorPath(PathError) = %q, want /d/f", got)
+	}
+	if got := errorPath("/d", errors.New("boom")); got != "/d" {
+		t.Errorf("errorPath(plain) = %q, want /d", got)
+	}
+}
@@ -1,44 +1,180 @@
 package scan
 
 import (
+	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/db"
 )
 
-// ShouldExclude reports whether path should be excluded by any of the patterns.
-// If patterns is nil or empty, returns false.
-// Pattern format:
-//   - If pattern contains '*' or '?', it is treated as a glob matched against the path's base name (e.g. "*.log", "*.tmp").
-//   - Otherwise it is a path segment: any path that has that segment as a component is excluded (e.g. ".git", "node_modules").
-func ShouldExclude(path string, patterns []string) bool {
-	if len(patterns) == 0 {
+// Excludes is a list of exclude patterns compiled once per scan, matched with .gitignore semantics:
+//   - A pattern without a slash (other than a trailing one) matches a name at any depth: "node_modules",
+//     ".git" or the glob "*.log". This is the simple form older pattern lists use, and it behaves as before.
+//   - A pattern with a leading or inner slash is anchored at the scan root and matched against the whole
+//     relative path: "/build" or "photos/cache". "**" matches any number of directories ("**/tmp",
+//     "photos/**/thumbs"); a trailing "/**" matches everything inside a directory.
+//   - A trailing slash ("logs/") matches directories only.
+//   - A leading "!" re-includes what an earlier pattern excluded; the last matching pattern wins. As in git, a
+//     path inside an excluded directory cannot be re-included ("\!" matches a literal "!").
+//
+// Excludes counts the paths each pattern decided (see Hits); it is safe for concurrent use.
+type Excludes struct {
+	rules []excludeRule
+	hits  []atomic.Int64 // per rule
+}
+
+type excludeRule struct {
+	source   string   // the pattern as written
+	pattern  string   // unanchored: glob matched against one name
+	segments []string // anchored: glob per path component
+	negate   bool
+	dirOnly  bool
+}
+
+// CompileExcludes compiles patterns (in order: later patterns override earlier ones). Empty patterns are ignored.
+func CompileExcludes(patterns []string) *Excludes {
+	e := &Excludes{}
+	for _, p := range patterns {
+		if r, ok := parseRule(p); ok {
+			e.rules = append(e.rules, r)
+		}
+	}
+	e.hits = make([]atomic.Int64, len(e.rules))
+	return e
+}
+
+// parseRule parses one pattern; ok is false for an empty one.
+func parseRule(p string) (r excludeRule, ok bool) {
+	r.source = p
+	if strings.HasPrefix(p, "!") {
+		r.negate, p = true, p[1:]
+	} else if strings.HasPrefix(p, `\!`) {
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly, p = true, strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return r, false
+	}
+	if strings.Contains(p, "/") {
+		r.segments = strings.Split(strings.TrimPrefix(p, "/"), "/")
+	} else {
+		r.pattern = p
+	}
+	return r, true
+}
+
+// matches reports whether the rule matches the path whose components are segs (a directory when dir is set).
+func (r *excludeRule) matches(segs []string, dir bool) bool {
+	if r.dirOnly && !dir {
 		return false
 	}
-	base := filepath.Base(path)
-	normalized := filepath.ToSlash(path)
-	for _, p := range patterns {
-		if strings.ContainsAny(p, "*?") {
-			matched, _ := filepath.Match(p, base)
-			if matched {
-				return true
-			}
-		} else {
-			if segmentMatches(normalized, p) {
-				return true
+	if r.segments != nil {
+		return matchSegments(r.segments, segs)
+	}
+	ok, _ := path.Match(r.pattern, segs[len(segs)-1])
+	return ok
+}
+
+// relSegments returns the components of path relative to root (path itself when it is not under root), with the
+// members of an archive (see archive.Path) as if the archive were a directory.
+func relSegments(root, p string) []string {
+	if rel, err := filepath.Rel(root, p); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		p = rel
+	}
+	p = strings.ReplaceAll(filepath.ToSlash(p), archive.Sep, "/")
+	return strings.FieldsFunc(p, func(r rune) bool { return r == '/' })
+}
+
+// Hits returns, for each pattern in order, how many paths it decided so far: paths it excluded, or for a "!"
+// pattern paths it re-included. A pattern still at 0 after a scan matched nothing, which often means a typo.
+func (e *Excludes) Hits() []db.ExcludeHit {
+	if e == nil {
+		return nil
+	}
+	out := make([]db.ExcludeHit, len(e.rules))
+	for i, r := range e.rules {
+		out[i] = db.ExcludeHit{Pattern: r.source, Paths: e.hits[i].Load()}
+	}
+	return out
+}
+
+// Excluded reports whether path, a file or (isDir) a directory under root, is excluded. Anchored patterns are
+// matched against path relative to root; the members of an archive (see archive.Path) are matched as if the
+// archive were a directory.
+func (e *Excludes) Excluded(root, p string, isDir bool) bool {
+	if e == nil || len(e.rules) == 0 {
+		return false
+	}
+	return e.match(relSegments(root, p), isDir)
+}
+
+// Match reports whether rel, a path relative to the scan root, is excluded. A directory on rel's way is
+// excluded when a pattern excludes it, whatever the patterns say about rel itself.
+func (e *Excludes) Match(rel string, isDir bool) bool {
+	if e == nil || len(e.rules) == 0 {
+		return false
+	}
+	return e.match(relSegments("", rel), isDir)
+}
+
+func (e *Excludes) match(segs []string, isDir bool) bool {
+	excluded, decided := false, -1 // decided: rule that set excluded at the deepest level evaluated so far
+	for k := 1; k <= len(segs); k++ {
+		dir := k < len(segs) || isDir
+		excluded, decided = false, -1
+		for i := range e.rules {
+			r := &e.rules[i]
+			if excluded == r.negate && r.matches(segs[:k], dir) { // a "!" pattern only decides when it re-includes
+				excluded, decided = !r.negate, i
 			}
 		}
+		if excluded && k < len(segs) {
+			e.hits[decided].Add(1)
+			return true
+		}
+	}
+	if decided >= 0 {
+		e.hits[decided].Add(1)
 	}
-	return false
+	return excluded
 }
 
-// segmentMatches reports whether path has segment as a path component.
-func segmentMatches(path, segment string) bool {
-	if path == segment {
-		return true
+// matchSegments reports whether the path components segs match the anchored pattern's components pat.
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if len(pat) == 1 {
+				return len(segs) > 0 // "dir/**" matches what is inside dir, not dir itself
+			}
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
 	}
-	sep := "/"
-	if strings.HasSuffix(path, sep+segment) {
-		return true
+	return len(segs) == 0
+}
+
+// ShouldExclude reports whether path should be excluded by any of the patterns (see Excludes). Anchored patterns
+// are matched against path as given, so pass a path relative to the scan root; callers walking a root compile
+// the patterns once and use Excludes.Excluded instead. If patterns is nil or empty, returns false.
+func ShouldExclude(path string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
 	}
-	return strings.Contains(path, sep+segment+sep)
+	return CompileExcludes(patterns).Match(path, false)
 }
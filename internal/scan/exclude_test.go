@@ -42,3 +42,60 @@ func TestShouldExclude_globMatchesBasename(t *testing.T) {
 		t.Error("*.log and *.tmp should not match foo.txt")
 	}
 }
+
+func TestExcludes_gitignoreSemantics(t *testing.T) {
+	e := CompileExcludes([]string{
+		"*.log", "!keep.log", // negation: the last matching pattern wins
+		"/build",              // anchored at the root
+		"cache/",              // directories only
+		"photos/**/thumbs",    // ** spans directories
+		"docs/**",             // everything inside docs, not docs itself
+		"vendor", "!vendor/x", // a path inside an excluded directory stays excluded
+		`\!bang`,
+	})
+	for _, c := range []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"a/debug.log", false, true},
+		{"a/keep.log", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"src/build", true, false},
+		{"cache", true, true},
+		{"a/cache/x", false, true},
+		{"cache", false, false},
+		{"photos/thumbs", true, true},
+		{"photos/2024/trip/thumbs/a.jpg", false, true},
+		{"other/thumbs", true, false},
+		{"docs", true, false},
+		{"docs/a.md", false, true},
+		{"vendor/x", false, true},
+		{"!bang", false, true},
+		{"backup.zip!/app/debug.log", false, true},
+	} {
+		if got := e.Match(c.rel, c.isDir); got != c.want {
+			t.Errorf("Match(%q, dir=%v) = %v, want %v", c.rel, c.isDir, got, c.want)
+		}
+	}
+	if !e.Excluded("/data", "/data/build", true) || e.Excluded("/data", "/data/src/build", true) {
+		t.Error("Excluded should anchor /build at the root /data")
+	}
+}
+
+func TestExcludes_hitsPerPattern(t *testing.T) {
+	e := CompileExcludes([]string{"*.log", "!keep.log", "node_modules", "tmp/", "typo_dir"})
+	for _, p := range []struct {
+		rel   string
+		isDir bool
+	}{{"a.log", false}, {"b/c.log", false}, {"keep.log", false}, {"app/node_modules", true}, {"tmp", true}, {"src/main.go", false}} {
+		e.Match(p.rel, p.isDir)
+	}
+	want := []int64{2, 1, 1, 1, 0}
+	for i, h := range e.Hits() {
+		if h.Paths != want[i] {
+			t.Errorf("Hits()[%d] = %+v, want %d paths", i, h, want[i])
+		}
+	}
+}
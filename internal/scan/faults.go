@@ -0,0 +1,172 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// EnvFaults enables fault injection in the scan pipeline, for exercising error handling on demand.
+// Comma-separated key=value list, rates are probabilities in [0,1]:
+//
+//	eacces=0.01      directory listing fails with permission denied (skipped, like a real EACCES)
+//	eio=0.01         directory listing or Lstat fails with an I/O error
+//	slow=0.05:2s     directory listing is delayed by the given duration (default 1s)
+//	db=0.001         a writer batch fails before reaching the database
+//	seed=42          random seed (default: time-based)
+//
+// Example: DITTO_FAULTS=eacces=0.02,eio=0.01,slow=0.1:500ms,db=0.001. Never set this in production.
+const EnvFaults = "DITTO_FAULTS"
+
+// ErrInjectedFault marks errors produced by fault injection; use errors.Is to tell them from real failures.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultConfig sets per-operation failure rates for the scan pipeline. The zero value injects nothing.
+type FaultConfig struct {
+	EACCESRate   float64       // per directory listing
+	EIORate      float64       // per directory listing and per file Lstat
+	SlowDirRate  float64       // per directory listing
+	SlowDirDelay time.Duration // delay applied to slow directories
+	DBRate       float64       // per writer batch
+	Seed         int64         // 0 = seed from the clock
+}
+
+// ParseFaults parses an EnvFaults value. An empty spec returns nil, nil (no injection).
+func ParseFaults(spec string) (*FaultConfig, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	c := &FaultConfig{SlowDirDelay: time.Second}
+	for _, part := range strings.Split(spec, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s: %q: want key=value", EnvFaults, part)
+		}
+		if key == "seed" {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: seed: %v", EnvFaults, err)
+			}
+			c.Seed = n
+			continue
+		}
+		rateStr, delayStr, hasDelay := strings.Cut(val, ":")
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s: %s: rate %q must be between 0 and 1", EnvFaults, key, rateStr)
+		}
+		if hasDelay && key != "slow" {
+			return nil, fmt.Errorf("%s: %s: only slow takes a duration", EnvFaults, key)
+		}
+		switch key {
+		case "eacces":
+			c.EACCESRate = rate
+		case "eio":
+			c.EIORate = rate
+		case "slow":
+			c.SlowDirRate = rate
+			if hasDelay {
+				d, err := time.ParseDuration(delayStr)
+				if err != nil || d < 0 {
+					return nil, fmt.Errorf("%s: slow: invalid duration %q", EnvFaults, delayStr)
+				}
+				c.SlowDirDelay = d
+			}
+		case "db":
+			c.DBRate = rate
+		default:
+			return nil, fmt.Errorf("%s: unknown fault %q", EnvFaults, key)
+		}
+	}
+	return c, nil
+}
+
+func (c *FaultConfig) String() string {
+	return fmt.Sprintf("eacces=%g eio=%g slow=%g:%v db=%g", c.EACCESRate, c.EIORate, c.SlowDirRate, c.SlowDirDelay, c.DBRate)
+}
+
+// faultInjector draws faults for the pipeline's walkers and writers. A nil injector never fails.
+type faultInjector struct {
+	cfg FaultConfig
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newFaultInjector(c *FaultConfig) *faultInjector {
+	if c == nil {
+		return nil
+	}
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &faultInjector{cfg: *c, rng: rand.New(rand.NewSource(seed))} // #nosec G404 -- fault injection, not security
+}
+
+func (f *faultInjector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Float64() < rate
+}
+
+// readDir is called before listing dir: it may sleep (slow directory) and may return a simulated
+// EACCES or EIO wrapped in a *fs.PathError, as os.ReadDir would.
+func (f *faultInjector) readDir(ctx context.Context, dir string) error {
+	if f == nil {
+		return nil
+	}
+	if f.hit(f.cfg.SlowDirRate) {
+		t := time.NewTimer(f.cfg.SlowDirDelay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	if f.hit(f.cfg.EACCESRate) {
+		return &fs.PathError{Op: "open", Path: dir, Err: injectedErrno{syscall.EACCES}}
+	}
+	if f.hit(f.cfg.EIORate) {
+		return &fs.PathError{Op: "open", Path: dir, Err: injectedErrno{syscall.EIO}}
+	}
+	return nil
+}
+
+// lstat may return a simulated EIO for path.
+func (f *faultInjector) lstat(path string) error {
+	if f != nil && f.hit(f.cfg.EIORate) {
+		return &fs.PathError{Op: "lstat", Path: path, Err: injectedErrno{syscall.EIO}}
+	}
+	return nil
+}
+
+// dbWrite may fail a writer batch before it is sent to the database.
+func (f *faultInjector) dbWrite() error {
+	if f != nil && f.hit(f.cfg.DBRate) {
+		return fmt.Errorf("write batch: %w", ErrInjectedFault)
+	}
+	return nil
+}
+
+// injectedErrno behaves like the wrapped errno for errors.Is/os.IsPermission and also matches ErrInjectedFault.
+type injectedErrno struct{ errno syscall.Errno }
+
+func (e injectedErrno) Error() string { return e.errno.Error() + " (injected)" }
+
+func (e injectedErrno) Is(target error) bool {
+	return target == ErrInjectedFault || e.errno.Is(target)
+}
+
+func (e injectedErrno) Unwrap() error { return e.errno }
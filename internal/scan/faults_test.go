@@ -0,0 +1,115 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+func TestParseFaults_parsesRatesDelayAndSeed(t *testing.T) {
+	c, err := ParseFaults("eacces=0.1, eio=0.2,slow=0.5:250ms,db=0.01,seed=7")
+	if err != nil {
+		t.Fatalf("ParseFaults: %v", err)
+	}
+	want := FaultConfig{EACCESRate: 0.1, EIORate: 0.2, SlowDirRate: 0.5, SlowDirDelay: 250 * time.Millisecond, DBRate: 0.01, Seed: 7}
+	if *c != want {
+		t.Errorf("ParseFaults = %+v, want %+v", *c, want)
+	}
+	if c, err := ParseFaults(""); c != nil || err != nil {
+		t.Errorf("ParseFaults(\"\") = %v, %v; want nil, nil", c, err)
+	}
+}
+
+func TestParseFaults_rejectsInvalid(t *testing.T) {
+	for _, spec := range []string{"eio", "eio=2", "nfs=0.1", "db=0.1:1s", "slow=0.1:soon", "seed=x"} {
+		if _, err := ParseFaults(spec); err == nil {
+			t.Errorf("ParseFaults(%q): want error", spec)
+		}
+	}
+}
+
+func TestFaultInjector_errorsLookReal(t *testing.T) {
+	f := newFaultInjector(&FaultConfig{EACCESRate: 1, Seed: 1})
+	err := f.readDir(context.Background(), "/x")
+	if !isPermissionOrAccessError(err) {
+		t.Errorf("injected EACCES %v: want treated as permission error", err)
+	}
+	if !errors.Is(err, ErrInjectedFault) || !errors.Is(err, syscall.EACCES) {
+		t.Errorf("injected EACCES %v: want errors.Is ErrInjectedFault and EACCES", err)
+	}
+
+	f = newFaultInjector(&FaultConfig{EIORate: 1, DBRate: 1, Seed: 1})
+	if err := f.lstat("/x/y"); !errors.Is(err, syscall.EIO) || isPermissionOrAccessError(err) {
+		t.Errorf("injected EIO %v: want EIO, not a permission error", err)
+	}
+	if err := f.dbWrite(); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("dbWrite = %v, want ErrInjectedFault", err)
+	}
+
+	var none *faultInjector
+	if none.readDir(context.Background(), "/x") != nil || none.lstat("/x") != nil || none.dbWrite() != nil {
+		t.Error("nil injector must not inject faults")
+	}
+}
+
+func TestRunPipeline_injectedEACCESSkipsDirectories(t *testing.T) {
+	database := runTestDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/a.txt", []byte("x"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	t.Setenv(EnvFaults, "eacces=1")
+	scanID, err := RunScan(ctx, database, dir, nil)
+	if err != nil {
+		t.Fatalf("RunScan with eacces=1: %v", err)
+	}
+	s, err := db.GetScan(ctx, database, scanID)
+	if err != nil {
+		t.Fatalf("GetScan: %v", err)
+	}
+	if s.FileCount == nil || *s.FileCount != 0 || s.ScanSkippedCount == nil || *s.ScanSkippedCount != 1 {
+		t.Errorf("eacces=1: file_count=%v skipped=%v, want 0 files and the root skipped", s.FileCount, s.ScanSkippedCount)
+	}
+	if errs, _ := db.ListScanErrors(ctx, database, scanID, 10); len(errs) != 1 || errs[0].Path != dir || errs[0].Phase != db.PhaseScan {
+		t.Errorf("eacces=1: scan errors = %+v, want the root's permission error", errs)
+	}
+
+	t.Setenv(EnvFaults, "db=1")
+	if _, err := RunScan(ctx, database, dir, nil); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("RunScan with db=1: err = %v, want ErrInjectedFault", err)
+	}
+}
+
+func TestRunPipeline_networkShareSkipsUnreachableDirectoriesAndReportsThem(t *testing.T) {
+	database := runTestDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/a.txt", []byte("x"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	t.Setenv(EnvFaults, "eio=1")
+	opts := &ScanOptions{NetworkFS: db.NetworkFSOn, DirRetries: 1, RetryBackoff: time.Millisecond}
+	scanID, err := RunScan(ctx, database, dir, opts)
+	if err != nil {
+		t.Fatalf("RunScan with eio=1 on a network share: %v", err)
+	}
+	s, _ := db.GetScan(ctx, database, scanID)
+	if s.ScanSkippedCount == nil || *s.ScanSkippedCount != 1 {
+		t.Errorf("skipped=%v, want the unreachable root skipped", s.ScanSkippedCount)
+	}
+	slow, err := db.ScanSlowDirs(ctx, database, scanID)
+	if err != nil {
+		t.Fatalf("ScanSlowDirs: %v", err)
+	}
+	if len(slow) != 1 || slow[0].Path != dir || slow[0].Outcome != db.SlowDirFailed || slow[0].Attempts != 2 {
+		t.Errorf("slow dirs = %+v, want the root failed after 2 attempts", slow)
+	}
+}
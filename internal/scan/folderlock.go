@@ -0,0 +1,67 @@
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+const (
+	// FolderLockHeartbeat is how often a running scan refreshes its folder lock.
+	FolderLockHeartbeat = 30 * time.Second
+	// FolderLockStaleAfter is how long a lock may go without a heartbeat before another scan may take it over.
+	FolderLockStaleAfter = 4 * FolderLockHeartbeat
+)
+
+// lockHolder identifies this process in folder_locks, for diagnosing who holds a lock.
+func lockHolder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// lockFolder takes the folder's scan lock for scanID and keeps it alive until release is called.
+// The returned context is cancelled if the lock is lost (another scan took it over after missed heartbeats),
+// so the walk stops instead of writing ledger rows alongside the new holder.
+func lockFolder(ctx context.Context, database *sql.DB, folderID, scanID int64) (context.Context, func(), error) {
+	if err := db.AcquireFolderLock(ctx, database, folderID, scanID, lockHolder(), FolderLockStaleAfter); err != nil {
+		return nil, nil, err
+	}
+	lockCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(FolderLockHeartbeat)
+		defer t.Stop()
+		for {
+			select {
+			case <-lockCtx.Done():
+				return
+			case <-t.C:
+				held, err := db.HeartbeatFolderLock(lockCtx, database, folderID, scanID)
+				if err != nil {
+					log.Printf("[scan] scan %d: folder lock heartbeat: %v", scanID, err)
+					continue
+				}
+				if !held {
+					log.Printf("[scan] scan %d: lost folder lock on folder %d; stopping", scanID, folderID)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	release := func() {
+		cancel()
+		<-done
+		// The scan context may be done (cancelled scan); release with a fresh one so the lock does not linger.
+		if err := db.ReleaseFolderLock(context.Background(), database, folderID, scanID); err != nil {
+			log.Printf("[scan] scan %d: release folder lock: %v", scanID, err)
+		}
+	}
+	return lockCtx, release, nil
+}
@@ -0,0 +1,67 @@
+package scan
+
+// Includes limits a scan to the paths matching at least one include pattern, written like exclude patterns
+// (see Excludes; "!" is not supported): "*.jpg" keeps files with that name at any depth, "photos/2024" keeps one
+// subtree. Includes are evaluated before excludes, so an excluded path stays excluded even when included.
+// Directories are walked as long as they may hold included files; an archive (ScanOptions.Archives) is only read
+// when it is itself included.
+type Includes struct {
+	rules []excludeRule
+}
+
+// CompileIncludes compiles include patterns; nil when there are none, which includes everything.
+func CompileIncludes(patterns []string) *Includes {
+	var in Includes
+	for _, p := range patterns {
+		if r, ok := parseRule(p); ok && !r.negate {
+			in.rules = append(in.rules, r)
+		}
+	}
+	if len(in.rules) == 0 {
+		return nil
+	}
+	return &in
+}
+
+// Allows reports whether the walk keeps path under root: for a file, whether it or a directory holding it matches
+// a pattern; for a directory (isDir), whether it may hold such files.
+func (in *Includes) Allows(root, p string, isDir bool) bool {
+	if in == nil {
+		return true
+	}
+	segs := relSegments(root, p)
+	for k := 1; k <= len(segs); k++ {
+		dir := k < len(segs) || isDir
+		for i := range in.rules {
+			if in.rules[i].matches(segs[:k], dir) {
+				return true
+			}
+		}
+	}
+	if !isDir {
+		return false
+	}
+	for i := range in.rules {
+		if r := &in.rules[i]; r.segments == nil || mayMatchBelow(r.segments, segs) {
+			return true // a name pattern can match at any depth
+		}
+	}
+	return false
+}
+
+// mayMatchBelow reports whether a path inside the directory with components segs could match the anchored
+// pattern's components pat.
+func mayMatchBelow(pat, segs []string) bool {
+	for ; len(segs) > 0; pat, segs = pat[1:], segs[1:] {
+		if len(pat) == 0 {
+			return false
+		}
+		if pat[0] == "**" {
+			return true
+		}
+		if ok := matchSegments(pat[:1], segs[:1]); !ok {
+			return false
+		}
+	}
+	return len(pat) > 0
+}
@@ -0,0 +1,36 @@
+package scan
+
+import "testing"
+
+func TestIncludes_nameGlobsAndSubtrees(t *testing.T) {
+	if in := CompileIncludes(nil); in != nil || !in.Allows("/r", "/r/any.txt", false) {
+		t.Fatal("no include patterns should include everything")
+	}
+	in := CompileIncludes([]string{"*.jpg", "*.mp4", "/docs/2024"})
+	for _, c := range []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/r/a/b/photo.jpg", false, true},
+		{"/r/clip.mp4", false, true},
+		{"/r/notes.txt", false, false},
+		{"/r/a/b", true, true}, // may hold a .jpg
+		{"/r/docs", true, true},
+		{"/r/docs/2024/report.pdf", false, true},
+		{"/r/docs/2023/report.pdf", false, false},
+		{"/r/backup.zip!/photo.jpg", false, true},
+	} {
+		if got := in.Allows("/r", c.path, c.isDir); got != c.want {
+			t.Errorf("Allows(%q, dir=%v) = %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+
+	subtree := CompileIncludes([]string{"photos/2024/"})
+	if !subtree.Allows("/r", "/r/photos", true) || subtree.Allows("/r", "/r/music", true) || subtree.Allows("/r", "/r/photos/2023", true) {
+		t.Error("an anchored include should only walk the directories leading to it")
+	}
+	if !subtree.Allows("/r", "/r/photos/2024/x/a.raw", false) {
+		t.Error("files inside an included directory should be included")
+	}
+}
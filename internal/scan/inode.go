@@ -0,0 +1,14 @@
+package scan
+
+import "os"
+
+// InodeAndDev returns the inode and device of the file at path (a final symlink is not followed), as a scan
+// records them.
+func InodeAndDev(path string) (inode, dev int64, err error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	inode, dev = inodeAndDev(path, info)
+	return inode, dev, nil
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package scan
+
+import (
+	"math"
+	"os"
+	"syscall"
+)
+
+// inodeAndDev returns the inode and device of the file described by info; path is only needed on Windows.
+func inodeAndDev(path string, info os.FileInfo) (inode, dev int64) {
+	sys := info.Sys()
+	if sys == nil {
+		return 0, 0
+	}
+	if st, ok := sys.(*syscall.Stat_t); ok {
+		return statTToInt64(any(st.Ino)), statTToInt64(any(st.Dev))
+	}
+	return 0, 0
+}
+
+// statTToInt64 converts Stat_t Ino/Dev to int64 without overflow (type varies by OS: uint64, int32, etc.).
+func statTToInt64(v interface{}) int64 {
+	switch x := v.(type) {
+	case uint64:
+		if x > math.MaxInt64 {
+			return 0
+		}
+		return int64(x)
+	case int32:
+		return int64(x)
+	case uint32:
+		if x > math.MaxInt32 {
+			return 0
+		}
+		return int64(x)
+	default:
+		return 0
+	}
+}
+
+// allocatedBytes returns the bytes allocated to the file described by info (512-byte blocks, as st_blocks
+// counts them), or nil when the platform does not say. It is less than the size for a sparse file.
+func allocatedBytes(info os.FileInfo) *int64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	n := int64(st.Blocks) * 512 // #nosec G115 -- block counts fit in int64
+	return &n
+}
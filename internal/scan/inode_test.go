@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInodeAndDev_hardlinksShareIDs(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	c := filepath.Join(dir, "c.txt")
+	for _, p := range []string{a, c} {
+		if err := os.WriteFile(p, []byte("same"), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	if err := os.Link(a, b); err != nil {
+		t.Skipf("hardlink not supported: %v", err)
+	}
+	ids := func(p string) (int64, int64) {
+		info, err := os.Lstat(p)
+		if err != nil {
+			t.Fatalf("Lstat: %v", err)
+		}
+		return inodeAndDev(p, info)
+	}
+	inoA, devA := ids(a)
+	inoB, devB := ids(b)
+	inoC, devC := ids(c)
+	if inoA == 0 || devA == 0 {
+		t.Fatalf("inodeAndDev(a) = %d, %d, want non-zero", inoA, devA)
+	}
+	if inoA != inoB || devA != devB {
+		t.Errorf("hardlinks: a = %d/%d, b = %d/%d, want equal", inoA, devA, inoB, devB)
+	}
+	if inoC == inoA || devC != devA {
+		t.Errorf("separate file c = %d/%d, want different inode on the same device as a (%d/%d)", inoC, devC, inoA, devA)
+	}
+}
@@ -0,0 +1,37 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTooDeep is recorded as a scan error for each directory the walk skipped because it lies deeper below the
+// scan root than ScanOptions.MaxDepth allows.
+var ErrTooDeep = errors.New("deeper than the scan's maximum depth")
+
+// ErrTooManyFiles fails a scan whose walk found more files than ScanOptions.MaxFiles allows, which usually
+// means the scan root is wrong (such as "/" or a whole NAS volume).
+var ErrTooManyFiles = errors.New("more files than the scan's maximum")
+
+// walkLimits guards a walk against a scan root much larger than intended: directories deeper than maxDepth are
+// skipped with a warning, and finding more than maxFiles files stops the walk. Zero limits are unlimited; a nil
+// *walkLimits has none.
+type walkLimits struct {
+	maxDepth int
+	maxFiles int64
+	stop     context.CancelCauseFunc // cancels the walkers and writers with ErrTooManyFiles
+}
+
+// tooDeep reports whether the directory dir is deeper below rootPath than the walk may go. A directory directly
+// under the root is at depth 1.
+func (l *walkLimits) tooDeep(rootPath, dir string) bool {
+	return l != nil && l.maxDepth > 0 && len(relSegments(rootPath, dir)) > l.maxDepth
+}
+
+// sent checks the number of files walked so far, n, and stops the walk once it passes the limit.
+func (l *walkLimits) sent(n int64) {
+	if l != nil && l.maxFiles > 0 && n > l.maxFiles {
+		l.stop(fmt.Errorf("%w (%d); check the scan root or raise its max files", ErrTooManyFiles, l.maxFiles))
+	}
+}
@@ -0,0 +1,86 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+func TestWalkLimits_tooDeep(t *testing.T) {
+	l := &walkLimits{maxDepth: 2}
+	root := filepath.FromSlash("/data/photos")
+	for _, tc := range []struct {
+		dir  string
+		want bool
+	}{
+		{"/data/photos/2024", false},
+		{"/data/photos/2024/trip", false},
+		{"/data/photos/2024/trip/raw", true},
+	} {
+		if got := l.tooDeep(root, filepath.FromSlash(tc.dir)); got != tc.want {
+			t.Errorf("tooDeep(%q) = %v, want %v", tc.dir, got, tc.want)
+		}
+	}
+	var none *walkLimits
+	if none.tooDeep(root, filepath.FromSlash("/data/photos/a/b/c/d")) || (&walkLimits{}).tooDeep(root, filepath.FromSlash("/data/photos/a/b/c/d")) {
+		t.Error("no limit must allow any depth")
+	}
+}
+
+func TestWalkLimits_sentStopsPastMaxFiles(t *testing.T) {
+	ctx, stop := context.WithCancelCause(context.Background())
+	l := &walkLimits{maxFiles: 2, stop: stop}
+	l.sent(2)
+	if ctx.Err() != nil {
+		t.Fatal("stopped at the limit, want stopped past it")
+	}
+	l.sent(3)
+	if !errors.Is(context.Cause(ctx), ErrTooManyFiles) {
+		t.Errorf("cause = %v, want ErrTooManyFiles", context.Cause(ctx))
+	}
+	var none *walkLimits
+	none.sent(1 << 40) // must not panic
+}
+
+func TestRunScan_maxDepthAndMaxFiles(t *testing.T) {
+	database := runTestDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	deep := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, p := range []string{filepath.Join(dir, "top.txt"), filepath.Join(dir, "a", "mid.txt"), filepath.Join(deep, "low.txt")} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	scanID, err := RunScan(ctx, database, dir, &ScanOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("RunScan with max depth 1: %v", err)
+	}
+	if files, _ := db.GetFilesByScanID(ctx, database, scanID); len(files) != 2 {
+		t.Errorf("max depth 1: got %d files, want 2 (a/b skipped)", len(files))
+	}
+	if errs, _ := db.ListScanErrors(ctx, database, scanID, 10); len(errs) != 1 || errs[0].Path != deep {
+		t.Errorf("max depth 1: scan errors = %+v, want a warning for %s", errs, deep)
+	}
+
+	if _, err := RunScan(ctx, database, dir, &ScanOptions{MaxFiles: 2}); !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("RunScan with max files 2: err = %v, want ErrTooManyFiles", err)
+	}
+
+	// The environment only sets a default; the root's own limit wins.
+	t.Setenv(EnvScanMaxFiles, "2")
+	if _, err := RunScan(ctx, database, dir, nil); !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("RunScan with DITTO_SCAN_MAX_FILES=2: err = %v, want ErrTooManyFiles", err)
+	}
+	if _, err := RunScan(ctx, database, dir, &ScanOptions{MaxFiles: 10}); err != nil {
+		t.Errorf("RunScan with max files 10 over DITTO_SCAN_MAX_FILES=2: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/limits"
+	"github.com/eargollo/ditto/internal/volume"
+)
+
+// Network-share defaults; ScanOptions can override the timeout, retries and backoff.
+const (
+	defaultDirTimeout   = time.Minute     // per directory listing (and per file Lstat) on a network share
+	defaultDirRetries   = 3               // retries after the first failed listing
+	defaultRetryBackoff = 2 * time.Second // wait before the first retry; doubles for each further retry
+	slowDirThreshold    = 10 * time.Second
+	maxSlowDirs         = 1000 // slow directories kept for the scan's report
+)
+
+// errFSTimeout marks a directory listing or Lstat that did not return within the timeout.
+var errFSTimeout = errors.New("timed out (network share not responding?)")
+
+// dirReader lists directories for the walkers. On a network share each listing runs with a timeout and
+// transient errors are retried with backoff, so a dropped SMB/NFS mount fails its directories instead of hanging
+// the walker forever. Directories that were slow, needed retries or failed are kept for the scan's report.
+type dirReader struct {
+	network bool
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+	faults  *faultInjector
+
+	mu      sync.Mutex
+	slow    []db.SlowDir
+	dropped int // slow directories beyond maxSlowDirs
+}
+
+// newDirReader returns the reader for rootPath. opts.NetworkFS decides whether network-share handling is on;
+// db.NetworkFSAuto (or empty) turns it on when rootPath is on a network filesystem.
+func newDirReader(rootPath string, opts *ScanOptions, faults *faultInjector) *dirReader {
+	r := &dirReader{timeout: defaultDirTimeout, retries: defaultDirRetries, backoff: defaultRetryBackoff, faults: faults}
+	mode := db.NetworkFSAuto
+	if opts != nil {
+		if opts.NetworkFS != "" {
+			mode = opts.NetworkFS
+		}
+		if opts.DirTimeout > 0 {
+			r.timeout = opts.DirTimeout
+		}
+		if opts.DirRetries > 0 {
+			r.retries = opts.DirRetries
+		}
+		if opts.RetryBackoff > 0 {
+			r.backoff = opts.RetryBackoff
+		}
+	}
+	switch mode {
+	case db.NetworkFSOn:
+		r.network = true
+	case db.NetworkFSAuto:
+		if fsType, err := volume.FSType(rootPath); err == nil && volume.IsNetworkFS(fsType) {
+			r.network = true
+			log.Printf("[scan] %s is on %s: directory listings time out after %v and are retried up to %d times", rootPath, fsType, r.timeout, r.retries)
+		}
+	}
+	return r
+}
+
+// readDir lists dir. On a network share, a listing that times out or fails with a transient error is retried
+// after backoff, doubling each time. The returned error is the last attempt's.
+func (r *dirReader) readDir(ctx context.Context, dir string) ([]fs.DirEntry, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		entries, err := r.readDirOnce(ctx, dir)
+		if err == nil {
+			switch {
+			case attempt > 1:
+				r.record(dir, start, attempt, db.SlowDirRecovered, nil)
+			case time.Since(start) >= slowDirThreshold:
+				r.record(dir, start, attempt, db.SlowDirSlow, nil)
+			}
+			return entries, nil
+		}
+		if !r.network || ctx.Err() != nil || isPermissionOrAccessError(err) {
+			return nil, err
+		}
+		if attempt > r.retries || !isTransientFSError(err) {
+			r.record(dir, start, attempt, db.SlowDirFailed, err)
+			return nil, err
+		}
+		wait := r.backoff << (attempt - 1)
+		log.Printf("[scan] listing %s failed (attempt %d of %d), retrying in %v: %v", dir, attempt, r.retries+1, wait, err)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (r *dirReader) readDirOnce(ctx context.Context, dir string) ([]fs.DirEntry, error) {
+	return withTimeout(ctx, r, dir, "readdir", func() ([]fs.DirEntry, error) {
+		if err := r.faults.readDir(ctx, dir); err != nil {
+			return nil, err
+		}
+		release, err := limits.AcquireFiles(ctx, 1)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return os.ReadDir(dir)
+	})
+}
+
+// lstat is os.Lstat with the network-share timeout (no retries: the directory listing just succeeded).
+func (r *dirReader) lstat(ctx context.Context, path string) (os.FileInfo, error) {
+	return withTimeout(ctx, r, path, "lstat", func() (os.FileInfo, error) {
+		if err := r.faults.lstat(path); err != nil {
+			return nil, err
+		}
+		return os.Lstat(path)
+	})
+}
+
+// withTimeout runs fn directly, or on a network share in a goroutine that is abandoned after r.timeout: a call
+// stuck on a dead mount cannot be interrupted, but the walker moves on and the goroutine ends when the kernel
+// gives up.
+func withTimeout[T any](ctx context.Context, r *dirReader, path, op string, fn func() (T, error)) (T, error) {
+	if !r.network {
+		return fn()
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+	t := time.NewTimer(r.timeout)
+	defer t.Stop()
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case <-t.C:
+		var zero T
+		return zero, &fs.PathError{Op: op, Path: path, Err: fmt.Errorf("%w after %v", errFSTimeout, r.timeout)}
+	}
+}
+
+// record adds dir to the slow-directory report.
+func (r *dirReader) record(dir string, start time.Time, attempts int, outcome string, err error) {
+	d := db.SlowDir{Path: dir, Duration: time.Since(start), Attempts: attempts, Outcome: outcome}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.slow) >= maxSlowDirs {
+		r.dropped++
+		return
+	}
+	r.slow = append(r.slow, d)
+}
+
+// slowDirs returns the report and how many slow directories did not fit in it.
+func (r *dirReader) slowDirs() ([]db.SlowDir, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.slow, r.dropped
+}
+
+// isTransientFSError reports whether err is worth retrying on a network share: a timeout, an I/O error, or a
+// connection or stale-handle error the client may recover from once the server is back.
+func isTransientFSError(err error) bool {
+	if errors.Is(err, errFSTimeout) {
+		return true
+	}
+	for _, errno := range []syscall.Errno{
+		syscall.EIO, syscall.ETIMEDOUT, syscall.EAGAIN, syscall.EHOSTDOWN, syscall.EHOSTUNREACH,
+		syscall.ENETDOWN, syscall.ENETUNREACH, syscall.ENETRESET, syscall.ECONNRESET, syscall.ECONNABORTED,
+		syscall.ECONNREFUSED, syscall.ENOTCONN, syscall.ESTALE,
+	} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+func TestDirReader_networkTimeoutRetriedThenFailed(t *testing.T) {
+	dir := t.TempDir()
+	faults := newFaultInjector(&FaultConfig{SlowDirRate: 1, SlowDirDelay: time.Second, Seed: 1})
+	r := newDirReader(dir, &ScanOptions{NetworkFS: db.NetworkFSOn, DirTimeout: 20 * time.Millisecond, DirRetries: 2, RetryBackoff: time.Millisecond}, faults)
+
+	start := time.Now()
+	_, err := r.readDir(context.Background(), dir)
+	if !errors.Is(err, errFSTimeout) {
+		t.Fatalf("readDir err = %v, want timeout", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("readDir took %v, want it to give up after 3 short timeouts", d)
+	}
+	slow, dropped := r.slowDirs()
+	if len(slow) != 1 || dropped != 0 {
+		t.Fatalf("slowDirs = %+v (dropped %d), want one entry", slow, dropped)
+	}
+	if s := slow[0]; s.Path != dir || s.Outcome != db.SlowDirFailed || s.Attempts != 3 || s.Error == "" {
+		t.Errorf("slow dir = %+v, want failed after 3 attempts with an error", s)
+	}
+}
+
+func TestDirReader_networkTransientErrorRecovers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Seed 6: the first draw (0.36) injects EIO, the second (0.84) does not.
+	faults := newFaultInjector(&FaultConfig{EIORate: 0.5, Seed: 6})
+	r := newDirReader(dir, &ScanOptions{NetworkFS: db.NetworkFSOn, DirRetries: 3, RetryBackoff: time.Millisecond}, faults)
+
+	entries, err := r.readDir(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("readDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("readDir returned %d entries, want 1", len(entries))
+	}
+	slow, _ := r.slowDirs()
+	if len(slow) != 1 || slow[0].Outcome != db.SlowDirRecovered || slow[0].Attempts != 2 {
+		t.Errorf("slowDirs = %+v, want one recovered after 2 attempts", slow)
+	}
+}
+
+func TestDirReader_errorsNotRetried(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	r := newDirReader(missing, &ScanOptions{NetworkFS: db.NetworkFSOn, RetryBackoff: time.Hour}, nil)
+	if _, err := r.readDir(context.Background(), missing); !os.IsNotExist(err) {
+		t.Fatalf("readDir err = %v, want not-exist without waiting for a retry", err)
+	}
+	if slow, _ := r.slowDirs(); len(slow) != 1 || slow[0].Attempts != 1 || slow[0].Outcome != db.SlowDirFailed {
+		t.Errorf("slowDirs = %+v, want one failed after 1 attempt", slow)
+	}
+
+	// Off: no timeouts, no retries, nothing reported.
+	faults := newFaultInjector(&FaultConfig{EIORate: 1, Seed: 1})
+	dir := t.TempDir()
+	r = newDirReader(dir, &ScanOptions{NetworkFS: db.NetworkFSOff, RetryBackoff: time.Hour}, faults)
+	if _, err := r.readDir(context.Background(), dir); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("readDir err = %v, want the injected EIO", err)
+	}
+	if slow, _ := r.slowDirs(); len(slow) != 0 {
+		t.Errorf("slowDirs = %+v, want none with network handling off", slow)
+	}
+}
@@ -3,17 +3,22 @@ package scan
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/eargollo/ditto/internal/archive"
 	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/ioprio"
+	"github.com/eargollo/ditto/internal/pathnorm"
 	"golang.org/x/time/rate"
 )
 
@@ -115,6 +120,8 @@ const (
 	EnvScanWalkers   = "DITTO_SCAN_WALKERS"   // number of walker goroutines (default 4)
 	EnvScanWriters   = "DITTO_SCAN_WRITERS"   // number of writer goroutines (default 2)
 	EnvScanBatchSize = "DITTO_SCAN_BATCH_SIZE" // max entries per DB batch (default 500)
+	EnvScanMaxDepth  = "DITTO_SCAN_MAX_DEPTH"  // default for roots without a max depth (ScanOptions.MaxDepth); unset = unlimited
+	EnvScanMaxFiles  = "DITTO_SCAN_MAX_FILES"  // default for roots without a max file count (ScanOptions.MaxFiles); unset = unlimited
 )
 
 // pipelineConfigFromEnv returns a PipelineConfig from environment variables. Use when config is nil (e.g. from UI).
@@ -136,14 +143,32 @@ func pipelineConfigFromEnv() *PipelineConfig {
 			c.BatchSize = n
 		}
 	}
+	if s := os.Getenv(EnvScanMaxDepth); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			c.MaxDepth = n
+		}
+	}
+	if s := os.Getenv(EnvScanMaxFiles); s != "" {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			c.MaxFiles = n
+		}
+	}
+	faults, err := ParseFaults(os.Getenv(EnvFaults))
+	if err != nil {
+		log.Printf("[scan] ignoring fault injection: %v", err)
+	}
+	c.Faults = faults
 	return c
 }
 
 // PipelineConfig configures the parallel scan pipeline.
 type PipelineConfig struct {
-	NumWalkers int // number of goroutines that list directories and emit files
-	NumWriters int // number of goroutines that batch and write to DB
-	BatchSize  int // max entries per DB batch (0 = defaultBatchSize)
+	NumWalkers int          // number of goroutines that list directories and emit files
+	NumWriters int          // number of goroutines that batch and write to DB
+	BatchSize  int          // max entries per DB batch (0 = defaultBatchSize)
+	Faults     *FaultConfig // simulated walker/writer failures for testing (nil = none; see EnvFaults)
+	MaxDepth   int          // default ScanOptions.MaxDepth (0 = unlimited; see ErrTooDeep)
+	MaxFiles   int64        // default ScanOptions.MaxFiles (0 = unlimited; see ErrTooManyFiles)
 }
 
 func (c *PipelineConfig) numWalkers() int {
@@ -169,21 +194,68 @@ func (c *PipelineConfig) batchSize() int {
 
 // ScanMetrics holds instrumentation for the scan pipeline (FS vs DB time and counts).
 type ScanMetrics struct {
-	FsNanos       atomic.Int64 // cumulative nanoseconds in walkers (listing + Lstat)
+	FsNanos       atomic.Int64 // cumulative nanoseconds in walkers (listing + Lstat, and StallNanos)
 	DbNanos       atomic.Int64 // cumulative nanoseconds in writers (batch inserts)
+	StallNanos    atomic.Int64 // cumulative nanoseconds walkers waited to send to a full fileChan
 	FilesWalked   atomic.Int64 // files emitted by walkers
 	FilesWritten  atomic.Int64 // files written to DB by writers
 	DirsProcessed atomic.Int64
+	Lstats        atomic.Int64 // Lstat calls by walkers
 	Skipped       atomic.Int64 // paths skipped (permission or exclude)
 	FileQueueLen  atomic.Int64 // number of entries currently in fileChan (increment on send, decrement on receive)
 	StartTime     time.Time    // when the pipeline started (for progress rate)
+
+	batchMu    sync.Mutex
+	batchNanos []int64 // duration of each batch write, for percentiles
 }
 
 func (m *ScanMetrics) Log() {
 	fsSec := time.Duration(m.FsNanos.Load()).Seconds()
 	dbSec := time.Duration(m.DbNanos.Load()).Seconds()
-	log.Printf("[scan] metrics: fs=%.2fs db=%.2fs files_walked=%d files_written=%d dirs=%d skipped=%d",
-		fsSec, dbSec, m.FilesWalked.Load(), m.FilesWritten.Load(), m.DirsProcessed.Load(), m.Skipped.Load())
+	stallSec := time.Duration(m.StallNanos.Load()).Seconds()
+	log.Printf("[scan] metrics: fs=%.2fs db=%.2fs stall=%.2fs files_walked=%d files_written=%d dirs=%d lstats=%d skipped=%d",
+		fsSec, dbSec, stallSec, m.FilesWalked.Load(), m.FilesWritten.Load(), m.DirsProcessed.Load(), m.Lstats.Load(), m.Skipped.Load())
+}
+
+// addBatch records the duration of one batch write.
+func (m *ScanMetrics) addBatch(d time.Duration) {
+	m.DbNanos.Add(d.Nanoseconds())
+	m.batchMu.Lock()
+	m.batchNanos = append(m.batchNanos, d.Nanoseconds())
+	m.batchMu.Unlock()
+}
+
+// sent records that a walker sent an entry to fileChan after waiting since start.
+func (m *ScanMetrics) sent(start time.Time) {
+	m.StallNanos.Add(time.Since(start).Nanoseconds())
+	m.FileQueueLen.Add(1)
+	m.FilesWalked.Add(1)
+}
+
+// IOStats summarizes the metrics for db.SaveScanIOStats. Filesystem time excludes the time walkers were stalled.
+func (m *ScanMetrics) IOStats() db.ScanIOStats {
+	m.batchMu.Lock()
+	batches := slices.Clone(m.batchNanos)
+	m.batchMu.Unlock()
+	slices.Sort(batches)
+	percentile := func(p int) time.Duration {
+		if len(batches) == 0 {
+			return 0
+		}
+		return time.Duration(batches[(len(batches)-1)*p/100])
+	}
+	stall := m.StallNanos.Load()
+	return db.ScanIOStats{
+		FSTime:    time.Duration(max(m.FsNanos.Load()-stall, 0)),
+		DBTime:    time.Duration(m.DbNanos.Load()),
+		StallTime: time.Duration(stall),
+		Dirs:      m.DirsProcessed.Load(),
+		Lstats:    m.Lstats.Load(),
+		Batches:   int64(len(batches)),
+		BatchP50:  percentile(50),
+		BatchP95:  percentile(95),
+		BatchP99:  percentile(99),
+	}
 }
 
 // RunPipeline runs the parallel walk -> batched write pipeline for the given scan.
@@ -198,9 +270,20 @@ func RunPipeline(ctx context.Context, database *sql.DB, scanID, folderID int64,
 	if opts != nil && len(opts.ExcludePatterns) > 0 {
 		patterns = opts.ExcludePatterns
 	}
+	excludes := CompileExcludes(patterns)
+	var includes *Includes
+	if opts != nil {
+		includes = CompileIncludes(opts.IncludePatterns)
+	}
 	maxFilesPerSecond := 0
+	var priority ioprio.Settings
+	var symlinks *symlinkPolicy
+	var unicodeForm string
 	if opts != nil {
 		maxFilesPerSecond = opts.MaxFilesPerSecond
+		priority = opts.Priority
+		symlinks = newSymlinkPolicy(opts.Symlinks)
+		unicodeForm = opts.UnicodeForm
 	}
 
 	fileCap := pipelineChanCaps()
@@ -208,11 +291,35 @@ func RunPipeline(ctx context.Context, database *sql.DB, scanID, folderID int64,
 		log.Printf("[scan] pipeline: walkers=%d writers=%d batch=%d fileChan=%d (env: DITTO_SCAN_WALKERS, DITTO_SCAN_WRITERS, DITTO_SCAN_BATCH_SIZE, DITTO_SCAN_FILE_CHAN_CAP)",
 			config.numWalkers(), config.numWriters(), config.batchSize(), fileCap)
 	}
+	faults := newFaultInjector(config.Faults)
+	if faults != nil {
+		log.Printf("[scan] fault injection enabled: %s", config.Faults)
+	}
+	reader := newDirReader(rootPath, opts, faults)
+	boundary := newFSBoundary(rootPath, opts != nil && opts.SameDevice)
+	archives := opts != nil && opts.Archives
+	errs := &errorLog{}
 	dirs := newDirQueue()
 	fileChan := make(chan Entry, fileCap)
 	metrics = &ScanMetrics{StartTime: time.Now()}
 	var wg sync.WaitGroup
 
+	// Walkers and writers stop on walkCtx, cancelled when the walk finds more files than allowed; the scan's
+	// errors and statistics are still written with ctx.
+	walkCtx, stopWalk := context.WithCancelCause(ctx)
+	defer stopWalk(nil)
+	maxDepth, maxFiles := config.MaxDepth, config.MaxFiles
+	if opts != nil && opts.MaxDepth > 0 {
+		maxDepth = opts.MaxDepth
+	}
+	if opts != nil && opts.MaxFiles > 0 {
+		maxFiles = opts.MaxFiles
+	}
+	var limits *walkLimits
+	if maxDepth > 0 || maxFiles > 0 {
+		limits = &walkLimits{maxDepth: maxDepth, maxFiles: maxFiles, stop: stopWalk}
+	}
+
 	// Progress updater: write current file count to DB periodically so the UI shows live progress.
 	progressDone := make(chan struct{})
 	go runProgressUpdater(ctx, database, scanID, metrics, progressDone)
@@ -237,15 +344,16 @@ func RunPipeline(ctx context.Context, database *sql.DB, scanID, folderID int64,
 	// Start walkers: they consume from dirs.Out() and Push subdirs to dirs (unbounded).
 	numWalkers := config.numWalkers()
 	for i := 0; i < numWalkers; i++ {
-		go runWalker(ctx, rootPath, folderPath, patterns, maxFilesPerSecond, dirs, fileChan, &wg, metrics)
+		go runWalker(walkCtx, rootPath, folderPath, excludes, includes, limits, maxFilesPerSecond, priority, symlinks, boundary, archives, dirs, fileChan, &wg, metrics, reader, errs)
 	}
 
 	// Start writers
 	numWriters := config.numWriters()
 	batchSize := config.batchSize()
 	writerDone := make(chan error, numWriters)
+	devices := newDeviceMap(database, folderID)
 	for i := 0; i < numWriters; i++ {
-		go runWriterSafe(ctx, database, folderID, scanID, folderPath, fileChan, batchSize, metrics, writerDone)
+		go runWriterSafe(walkCtx, database, folderID, scanID, folderPath, unicodeForm, fileChan, batchSize, metrics, writerDone, faults, errs, devices)
 	}
 
 	// Wait for all writers to finish (they exit when fileChan is closed and drained)
@@ -255,15 +363,32 @@ func RunPipeline(ctx context.Context, database *sql.DB, scanID, folderID int64,
 			firstErr = e
 		}
 	}
+	if cause := context.Cause(walkCtx); errors.Is(cause, ErrTooManyFiles) {
+		firstErr = cause
+		log.Printf("[scan] stopped scan %d: %v", scanID, cause)
+	}
 	close(progressDone) // stop progress updater so it doesn't overwrite final count
 	if debugPipeline() {
 		close(debugDone)
 	}
+	errs.flush(ctx, database, scanID)
+	if slow, dropped := reader.slowDirs(); len(slow) > 0 {
+		log.Printf("[scan] %d slow or unreachable directories (see the scan's slow directories page)", len(slow)+dropped)
+		if err := db.InsertScanSlowDirs(ctx, database, scanID, slow); err != nil {
+			log.Printf("[scan] record slow directories for scan %d: %v", scanID, err)
+		}
+	}
 	if firstErr != nil {
 		return metrics.FilesWritten.Load(), metrics.Skipped.Load(), metrics, firstErr
 	}
 
 	metrics.Log()
+	if err := db.SaveScanIOStats(ctx, database, scanID, metrics.IOStats()); err != nil {
+		log.Printf("[scan] record I/O statistics for scan %d: %v", scanID, err)
+	}
+	if err := db.SaveScanExcludeHits(ctx, database, scanID, excludes.Hits()); err != nil {
+		log.Printf("[scan] record exclude pattern counts for scan %d: %v", scanID, err)
+	}
 	return metrics.FilesWritten.Load(), metrics.Skipped.Load(), metrics, nil
 }
 
@@ -321,8 +446,11 @@ func runProgressUpdater(ctx context.Context, database *sql.DB, scanID int64, met
 }
 
 // runWalker consumes dirs from the queue, lists each, Pushes subdirs (unbounded), and sends files to fileChan.
-func runWalker(ctx context.Context, rootPath, folderPath string, patterns []string, maxFilesPerSecond int,
-	dirs *dirQueue, fileChan chan<- Entry, wg *sync.WaitGroup, metrics *ScanMetrics) {
+func runWalker(ctx context.Context, rootPath, folderPath string, excludes *Excludes, includes *Includes, limits *walkLimits, maxFilesPerSecond int, priority ioprio.Settings,
+	symlinks *symlinkPolicy, boundary *fsBoundary, archives bool, dirs *dirQueue, fileChan chan<- Entry, wg *sync.WaitGroup, metrics *ScanMetrics, reader *dirReader, errs *errorLog) {
+	if err := ioprio.ApplyToCurrentThread(priority); err != nil {
+		log.Printf("[scan] could not lower walker priority: %v", err)
+	}
 	var limiter *rate.Limiter
 	if maxFilesPerSecond > 0 {
 		limiter = rate.NewLimiter(rate.Limit(maxFilesPerSecond), 1)
@@ -336,8 +464,11 @@ func runWalker(ctx context.Context, rootPath, folderPath string, patterns []stri
 				return
 			}
 			fsStart := time.Now()
-			if err := processOneDir(ctx, dir, rootPath, folderPath, patterns, limiter, dirs, fileChan, wg, metrics); err != nil {
+			if err := processOneDir(ctx, dir, rootPath, folderPath, excludes, includes, limits, limiter, symlinks, boundary, archives, dirs, fileChan, wg, metrics, reader, errs); err != nil {
 				log.Printf("[scan] walker error at %s: %v", dir, err)
+				if ctx.Err() == nil {
+					errs.add(errorPath(dir, err), err)
+				}
 			}
 			metrics.FsNanos.Add(time.Since(fsStart).Nanoseconds())
 			metrics.DirsProcessed.Add(1)
@@ -346,16 +477,39 @@ func runWalker(ctx context.Context, rootPath, folderPath string, patterns []stri
 	}
 }
 
-func processOneDir(ctx context.Context, dir string, rootPath, folderPath string, patterns []string, limiter *rate.Limiter,
-	dirs *dirQueue, fileChan chan<- Entry, wg *sync.WaitGroup, metrics *ScanMetrics) error {
+// processOneDir lists dir, Pushes subdirs (and, in follow mode, linked directories) and sends files to fileChan.
+// Symlinks are handled per symlinks (nil skips them); subdirs on another filesystem are skipped unless boundary is nil.
+// With archives set, the files inside zip and tar archives are sent too (see sendArchiveMembers).
+// A dir deeper than limits allow is skipped and added to errs, and every file sent is counted against limits.
+// Paths skipped because they could not be read are added to errs; the returned error is the caller's to record.
+func processOneDir(ctx context.Context, dir string, rootPath, folderPath string, excludes *Excludes, includes *Includes, limits *walkLimits, limiter *rate.Limiter,
+	symlinks *symlinkPolicy, boundary *fsBoundary, archives bool, dirs *dirQueue, fileChan chan<- Entry, wg *sync.WaitGroup, metrics *ScanMetrics, reader *dirReader, errs *errorLog) error {
+	if dir != rootPath && limits.tooDeep(rootPath, dir) {
+		metrics.Skipped.Add(1)
+		log.Printf("[scan] skipped (deeper than %d levels): %s", limits.maxDepth, dir)
+		errs.add(dir, fmt.Errorf("%w (%d levels)", ErrTooDeep, limits.maxDepth))
+		return nil
+	}
+	if !symlinks.firstVisit(dir) {
+		metrics.Skipped.Add(1)
+		log.Printf("[scan] skipped (already walked through another symlink or a cycle): %s", dir)
+		return nil
+	}
 	if os.Getenv(DebugScanEnv) != "" {
 		log.Printf("[scan] listing directory: %s", dir)
 	}
-	entries, err := os.ReadDir(dir)
+	entries, err := reader.readDir(ctx, dir)
 	if err != nil {
 		if isPermissionOrAccessError(err) {
 			metrics.Skipped.Add(1)
 			log.Printf("[scan] skipped (permission): %s: %v", dir, err)
+			errs.add(dir, err)
+			return nil
+		}
+		if reader.network && ctx.Err() == nil {
+			metrics.Skipped.Add(1)
+			log.Printf("[scan] skipped (unreachable): %s: %v", dir, err)
+			errs.add(dir, err)
 			return nil
 		}
 		return err
@@ -366,7 +520,7 @@ func processOneDir(ctx context.Context, dir string, rootPath, folderPath string,
 		}
 		name := d.Name()
 		fullPath := filepath.Join(dir, name)
-		if ShouldExclude(fullPath, patterns) {
+		if !includes.Allows(rootPath, fullPath, d.IsDir()) || excludes.Excluded(rootPath, fullPath, d.IsDir()) {
 			metrics.Skipped.Add(1)
 			if d.IsDir() {
 				continue
@@ -374,48 +528,89 @@ func processOneDir(ctx context.Context, dir string, rootPath, folderPath string,
 			continue
 		}
 		if d.IsDir() {
+			if boundary != nil {
+				metrics.Lstats.Add(1)
+				if info, err := reader.lstat(ctx, fullPath); err == nil && boundary.crosses(fullPath, info) {
+					metrics.Skipped.Add(1)
+					log.Printf("[scan] skipped (other filesystem): %s", fullPath)
+					continue
+				}
+			}
 			wg.Add(1)
 			dirs.Push(fullPath) // unbounded, never blocks on capacity
 			continue
 		}
+		var info os.FileInfo
+		var target string
 		if d.Type()&fs.ModeSymlink != 0 {
-			continue
-		}
-		if !d.Type().IsRegular() {
-			continue
-		}
-		info, err := os.Lstat(fullPath)
-		if err != nil {
-			log.Printf("[scan] error at %s (Lstat): %v", fullPath, err)
-			return err
+			var err error
+			info, target, err = symlinks.entry(fullPath)
+			if err != nil {
+				metrics.Skipped.Add(1)
+				log.Printf("[scan] skipped (symlink): %s: %v", fullPath, err)
+				errs.add(fullPath, err)
+				continue
+			}
+			if info == nil {
+				continue
+			}
+			if target == "" && info.IsDir() {
+				if boundary.crosses(fullPath, info) {
+					metrics.Skipped.Add(1)
+					log.Printf("[scan] skipped (symlink to other filesystem): %s", fullPath)
+					continue
+				}
+				wg.Add(1)
+				dirs.Push(fullPath) // followed: walked under the link's path
+				continue
+			}
+			if target == "" && !info.Mode().IsRegular() {
+				continue
+			}
+		} else {
+			if !d.Type().IsRegular() {
+				continue
+			}
+			var err error
+			metrics.Lstats.Add(1)
+			info, err = reader.lstat(ctx, fullPath)
+			if err != nil {
+				log.Printf("[scan] error at %s (Lstat): %v", fullPath, err)
+				return err
+			}
 		}
 		absPath, err := filepath.Abs(fullPath)
 		if err != nil {
 			log.Printf("[scan] error at %s (Abs): %v", fullPath, err)
 			return err
 		}
-		inode, dev := inodeAndDev(info)
+		inode, dev := inodeAndDev(fullPath, info)
 		var deviceID *int64
 		if dev != 0 {
 			deviceID = &dev
 		}
 		e := Entry{
-			Path:     absPath,
-			Size:     info.Size(),
-			MTime:    info.ModTime().Unix(),
-			Inode:    inode,
-			DeviceID: deviceID,
+			Path:          absPath,
+			Size:          info.Size(),
+			MTime:         info.ModTime().Unix(),
+			Inode:         inode,
+			DeviceID:      deviceID,
+			SymlinkTarget: target,
+		}
+		if target == "" {
+			e.Allocated = allocatedBytes(info)
 		}
 		if limiter != nil {
 			if err := limiter.Wait(ctx); err != nil {
 				return err
 			}
 		}
+		sendStart := time.Now()
 		select {
 		case fileChan <- e:
-			metrics.FileQueueLen.Add(1)
-			metrics.FilesWalked.Add(1)
+			metrics.sent(sendStart)
 			n := metrics.FilesWalked.Load()
+			limits.sent(n)
 			if n%scanProgressLogIntervalPipeline == 0 {
 				elapsed := time.Since(metrics.StartTime).Seconds()
 				rate := float64(n) / elapsed
@@ -430,43 +625,68 @@ func processOneDir(ctx context.Context, dir string, rootPath, folderPath string,
 		case <-ctx.Done():
 			return ctx.Err()
 		}
+		if archives && target == "" && archive.IsArchive(name) {
+			if err := sendArchiveMembers(ctx, rootPath, absPath, deviceID, excludes, includes, limits, limiter, fileChan, metrics, errs); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
 // runWriterSafe wraps runWriter with panic recovery so one failed writer doesn't hang the pipeline.
-func runWriterSafe(ctx context.Context, database *sql.DB, folderID, scanID int64, folderPath string,
-	fileChan <-chan Entry, batchSize int, metrics *ScanMetrics, done chan<- error) {
+func runWriterSafe(ctx context.Context, database *sql.DB, folderID, scanID int64, folderPath, unicodeForm string,
+	fileChan <-chan Entry, batchSize int, metrics *ScanMetrics, done chan<- error, faults *faultInjector, errs *errorLog, devices *deviceMap) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[scan] writer panic: %v", r)
 			done <- fmt.Errorf("writer panic: %v", r)
 		}
 	}()
-	runWriter(ctx, database, folderID, scanID, folderPath, fileChan, batchSize, metrics, done)
+	runWriter(ctx, database, folderID, scanID, folderPath, unicodeForm, fileChan, batchSize, metrics, done, faults, errs, devices)
 }
 
 // runWriter reads entries from fileChan, batches them, and writes via UpsertFilesBatch + InsertFileScanBatch.
-func runWriter(ctx context.Context, database *sql.DB, folderID, scanID int64, folderPath string,
-	fileChan <-chan Entry, batchSize int, metrics *ScanMetrics, done chan<- error) {
+// Paths are stored relative to folderPath in unicodeForm; a path too long to index is recorded as a scan error.
+// Device numbers are stored as their stable device ids (see deviceMap).
+func runWriter(ctx context.Context, database *sql.DB, folderID, scanID int64, folderPath, unicodeForm string,
+	fileChan <-chan Entry, batchSize int, metrics *ScanMetrics, done chan<- error, faults *faultInjector, errs *errorLog, devices *deviceMap) {
 	batch := make([]Entry, 0, batchSize)
 	flush := func() error {
 		if len(batch) == 0 {
 			return nil
 		}
-		rows := make([]db.FileRow, len(batch))
-		for i, e := range batch {
+		rows := make([]db.FileRow, 0, len(batch))
+		for _, e := range batch {
 			relPath, err := filepath.Rel(folderPath, e.Path)
 			if err != nil {
 				relPath = e.Path
 			}
-			rows[i] = db.FileRow{
-				Path:     relPath,
-				Size:     e.Size,
-				MTime:    e.MTime,
-				Inode:    e.Inode,
-				DeviceID: e.DeviceID,
+			relPath = pathnorm.Normalize(unicodeForm, relPath)
+			if len(relPath) > db.MaxPathBytes {
+				errs.add(e.Path, db.ErrPathTooLong)
+				continue
+			}
+			deviceID := e.DeviceID
+			if deviceID != nil {
+				id, err := devices.id(ctx, e.Path, *deviceID)
+				if err != nil {
+					return err
+				}
+				deviceID = &id
 			}
+			rows = append(rows, db.FileRow{
+				Path:          relPath,
+				Size:          e.Size,
+				MTime:         e.MTime,
+				Inode:         e.Inode,
+				DeviceID:      deviceID,
+				SymlinkTarget: e.SymlinkTarget,
+				Allocated:     e.Allocated,
+			})
+		}
+		if err := faults.dbWrite(); err != nil {
+			return err
 		}
 		t0 := time.Now()
 		ids, err := db.UpsertFilesBatch(ctx, database, folderID, rows)
@@ -476,9 +696,9 @@ func runWriter(ctx context.Context, database *sql.DB, folderID, scanID int64, fo
 		if err := db.InsertFileScanBatch(ctx, database, ids, scanID); err != nil {
 			return err
 		}
-		metrics.DbNanos.Add(time.Since(t0).Nanoseconds())
+		metrics.addBatch(time.Since(t0))
 		prevWritten := metrics.FilesWritten.Load()
-		written := metrics.FilesWritten.Add(int64(len(batch)))
+		written := metrics.FilesWritten.Add(int64(len(rows)))
 		batch = batch[:0]
 		// Log when we cross a 5k boundary so user sees writer progress (e.g. when walkers are blocked on full channel).
 		if written/scanProgressWriterLogInterval > prevWritten/scanProgressWriterLogInterval {
@@ -7,18 +7,34 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/ioprio"
+	"github.com/eargollo/ditto/internal/volume"
 )
 
 // ScanOptions configures a scan run.
 type ScanOptions struct {
 	ExcludePatterns   []string
+	IncludePatterns   []string // when set, only paths matching one of them are scanned (see Includes)
 	MaxFilesPerSecond int
+	Priority          ioprio.Settings // lower CPU/I/O priority of walker threads (Linux); zero = unchanged
+	Symlinks          string          // db.SymlinksRecord or db.SymlinksFollow; empty or db.SymlinksSkip ignores symlinks
+	NetworkFS         string          // db.NetworkFSAuto (or empty), db.NetworkFSOn or db.NetworkFSOff
+	DirTimeout        time.Duration   // network share: per-directory listing timeout; 0 = 1 minute
+	DirRetries        int             // network share: retries of a failed listing; 0 = 3
+	RetryBackoff      time.Duration   // network share: wait before the first retry, doubled for each next one; 0 = 2s
+	SameDevice        bool            // do not descend into other filesystems mounted under the root (--one-file-system)
+	Archives          bool            // also send the files inside zip and tar archives, under archive.Path virtual paths
+	UnicodeForm       string          // pathnorm.NFC or pathnorm.NFD stores paths in that Unicode form; empty or pathnorm.None as found
+	MaxDepth          int             // skip directories more than this many levels below the root; 0 = PipelineConfig.MaxDepth
+	MaxFiles          int64           // fail the scan when the walk finds more files; 0 = PipelineConfig.MaxFiles
 }
 
 // RunScan walks rootPath, ensures a folder exists for it, creates a scan, upserts files and ledger rows, then sets the scan's completed_at.
 // Uses the parallel pipeline (multiple walkers, batched DB writers). rootPath must be an existing directory. Returns scanID or error.
+// The walk holds the folder's lock (see lockFolder); if another scan of the folder is running it returns db.ErrFolderLocked.
 func RunScan(ctx context.Context, database *sql.DB, rootPath string, opts *ScanOptions) (int64, error) {
 	rootPath = filepath.Clean(rootPath)
 	info, err := os.Stat(rootPath)
@@ -45,19 +61,27 @@ func RunScan(ctx context.Context, database *sql.DB, rootPath string, opts *ScanO
 	}
 	scanID := s.ID
 
+	lockCtx, release, err := lockFolder(ctx, database, folderID, scanID)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
 	log.Printf("[scan] started for scan %d path %s (pipeline)", scanID, rootPath)
-	fileCount, skippedScan, _, err := RunPipeline(ctx, database, scanID, folderID, rootPath, folderPath, opts, nil)
+	recordVolume(lockCtx, database, scanID, rootPath)
+	recordFilesystem(lockCtx, database, folderID, rootPath, info)
+	fileCount, skippedScan, _, err := RunPipeline(lockCtx, database, scanID, folderID, rootPath, folderPath, opts, nil)
 	if err != nil {
 		return 0, err
 	}
-	if err := db.UpdateScanCompletedAt(ctx, database, scanID, fileCount, skippedScan); err != nil {
+	if err := db.UpdateScanCompletedAt(lockCtx, database, scanID, fileCount, skippedScan); err != nil {
 		return 0, err
 	}
 	return scanID, nil
 }
 
 // RunScanForExisting walks rootPath and upserts files + ledger for the existing scan (scanID). Use when the scan row was already created.
-// Uses the parallel pipeline (multiple walkers, batched DB writers).
+// Uses the parallel pipeline (multiple walkers, batched DB writers). Returns db.ErrFolderLocked if another scan of the folder is running.
 func RunScanForExisting(ctx context.Context, database *sql.DB, scanID int64, folderID int64, rootPath string, opts *ScanOptions) error {
 	rootPath = filepath.Clean(rootPath)
 	info, err := os.Stat(rootPath)
@@ -73,9 +97,50 @@ func RunScanForExisting(ctx context.Context, database *sql.DB, scanID int64, fol
 	}
 	folderPath := folder.Path
 
-	fileCount, skippedScan, _, err := RunPipeline(ctx, database, scanID, folderID, rootPath, folderPath, opts, nil)
+	lockCtx, release, err := lockFolder(ctx, database, folderID, scanID)
 	if err != nil {
 		return err
 	}
-	return db.UpdateScanCompletedAt(ctx, database, scanID, fileCount, skippedScan)
+	defer release()
+
+	recordVolume(lockCtx, database, scanID, rootPath)
+	recordFilesystem(lockCtx, database, folderID, rootPath, info)
+	fileCount, skippedScan, _, err := RunPipeline(lockCtx, database, scanID, folderID, rootPath, folderPath, opts, nil)
+	if err != nil {
+		return err
+	}
+	return db.UpdateScanCompletedAt(lockCtx, database, scanID, fileCount, skippedScan)
+}
+
+// recordFilesystem stores the filesystem type and device of rootPath (described by info) on the folder, for display.
+// The device is the stable id files store (db.ResolveDevice), not the st_dev number, which changes on remount.
+func recordFilesystem(ctx context.Context, database *sql.DB, folderID int64, rootPath string, info os.FileInfo) {
+	fsType, _ := volume.FSType(rootPath)
+	var deviceID *int64
+	if _, dev := inodeAndDev(rootPath, info); dev != 0 {
+		id, err := db.ResolveDevice(ctx, database, folderID, dev, volume.DeviceKey(rootPath, uint64(dev))) // #nosec G115 -- st_dev read back from its unsigned value
+		if err != nil {
+			log.Printf("[scan] resolve device of folder %d: %v", folderID, err)
+		} else {
+			deviceID = &id
+		}
+	}
+	if err := db.SetFolderFilesystem(ctx, database, folderID, fsType, deviceID); err != nil {
+		log.Printf("[scan] record filesystem of folder %d: %v", folderID, err)
+	}
+}
+
+// recordVolume links the scan to the filesystem (by UUID) holding rootPath, so scans of a removable drive are
+// grouped per physical disk. Filesystems without a known UUID leave the scan unlinked.
+func recordVolume(ctx context.Context, database *sql.DB, scanID int64, rootPath string) {
+	info, err := volume.Identify(rootPath)
+	if err != nil {
+		if !errors.Is(err, volume.ErrUnknown) {
+			log.Printf("[scan] identify volume of %s: %v", rootPath, err)
+		}
+		return
+	}
+	if _, err := db.RecordScanVolume(ctx, database, scanID, info.UUID, info.Label, info.FSType); err != nil {
+		log.Printf("[scan] record volume for scan %d: %v", scanID, err)
+	}
 }
@@ -1,6 +1,7 @@
 package scan
 
 import (
+	"archive/zip"
 	"context"
 	"database/sql"
 	"fmt"
@@ -62,6 +63,29 @@ func TestRunScan_populatesScanAndFilesWithCompletedAt(t *testing.T) {
 	if !paths[filepath.Join(dir, "a.txt")] || !paths[filepath.Join(dir, "b.txt")] {
 		t.Errorf("files = %v", files)
 	}
+
+	folder, err := db.GetFolder(ctx, database, s.FolderID)
+	if err != nil {
+		t.Fatalf("GetFolder: %v", err)
+	}
+	info, _ := os.Stat(dir)
+	// The folder and its files store the id of their device, which names the filesystem across remounts, not the
+	// raw st_dev.
+	if _, dev := inodeAndDev(dir, info); dev != 0 {
+		if folder.DeviceID == nil {
+			t.Error("folder has no device recorded by the scan")
+		}
+		for _, f := range files {
+			var stDev int64
+			if f.DeviceID == nil {
+				t.Errorf("%s has no device", f.Path)
+			} else if err := database.QueryRowContext(ctx, "SELECT st_dev FROM devices WHERE id = $1", *f.DeviceID).Scan(&stDev); err != nil || stDev != dev {
+				t.Errorf("%s: device %d has st_dev %d, %v; want %d", f.Path, *f.DeviceID, stDev, err, dev)
+			} else if folder.DeviceID != nil && *folder.DeviceID != *f.DeviceID {
+				t.Errorf("folder device = %d, want %d like its files", *folder.DeviceID, *f.DeviceID)
+			}
+		}
+	}
 }
 
 func TestRunScan_withExcludesReducesFileCount(t *testing.T) {
@@ -94,6 +118,92 @@ func TestRunScan_withExcludesReducesFileCount(t *testing.T) {
 	}
 }
 
+func TestRunScan_symlinkModes(t *testing.T) {
+	database := runTestDB(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		mode         string
+		wantFiles    int
+		wantSymlinks int64
+	}{
+		{db.SymlinksSkip, 1, 0},   // real/a.txt only
+		{db.SymlinksRecord, 4, 3}, // plus farm/a.txt, farm/loop, farm/dangling as links
+		{db.SymlinksFollow, 2, 0}, // real/a.txt and farm/a.txt; the loop and the dangling link are skipped
+	} {
+		dir := symlinkFarm(t)
+		scanID, err := RunScan(ctx, database, dir, &ScanOptions{Symlinks: tc.mode})
+		if err != nil {
+			t.Fatalf("%s: RunScan: %v", tc.mode, err)
+		}
+		files, err := db.GetFilesByScanID(ctx, database, scanID)
+		if err != nil {
+			t.Fatalf("%s: GetFilesByScanID: %v", tc.mode, err)
+		}
+		if len(files) != tc.wantFiles {
+			t.Errorf("%s: got %d files, want %d: %+v", tc.mode, len(files), tc.wantFiles, files)
+		}
+		if n, _ := db.CountScanSymlinks(ctx, database, scanID); n != tc.wantSymlinks {
+			t.Errorf("%s: CountScanSymlinks = %d, want %d", tc.mode, n, tc.wantSymlinks)
+		}
+	}
+}
+
+func TestRunScan_archives(t *testing.T) {
+	database := runTestDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "backup.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"a.txt", "photos/b.jpg"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(w, "content of %s", name)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, tc := range []struct {
+		archives bool
+		want     []string
+	}{
+		{false, []string{"backup.zip"}},
+		{true, []string{"backup.zip", "backup.zip!/a.txt", "backup.zip!/photos/b.jpg"}},
+	} {
+		scanID, err := RunScan(ctx, database, dir, &ScanOptions{Archives: tc.archives})
+		if err != nil {
+			t.Fatalf("archives=%v: RunScan: %v", tc.archives, err)
+		}
+		files, err := db.GetFilesByScanID(ctx, database, scanID)
+		if err != nil {
+			t.Fatalf("GetFilesByScanID: %v", err)
+		}
+		got := make(map[string]db.File)
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f.Path)
+			got[rel] = f
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("archives=%v: got files %v, want %v", tc.archives, got, tc.want)
+		}
+		for _, p := range tc.want {
+			if _, ok := got[p]; !ok {
+				t.Errorf("archives=%v: %s not cataloged", tc.archives, p)
+			}
+		}
+		if tc.archives && got["backup.zip!/a.txt"].Inode == got["backup.zip!/photos/b.jpg"].Inode {
+			t.Error("files inside an archive share an inode: they would look like hardlinks")
+		}
+	}
+}
+
 func TestRunScan_nonexistentRootReturnsErrorNoScanRow(t *testing.T) {
 	database := runTestDB(t)
 	ctx := context.Background()
@@ -0,0 +1,66 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// symlinkPolicy applies a folder's symlink mode (db.SymlinksSkip, db.SymlinksRecord or db.SymlinksFollow) during a
+// walk. A nil policy skips symlinks, which is the default.
+type symlinkPolicy struct {
+	mode string
+	seen sync.Map // follow mode: directories already walked, by device and inode (or resolved path)
+}
+
+// newSymlinkPolicy returns the policy for mode, or nil when symlinks are skipped.
+func newSymlinkPolicy(mode string) *symlinkPolicy {
+	if mode != db.SymlinksRecord && mode != db.SymlinksFollow {
+		return nil
+	}
+	return &symlinkPolicy{mode: mode}
+}
+
+// entry resolves the symlink at path. It returns the info to catalog and, in record mode, the link target;
+// a nil info means the link is skipped. In follow mode a dangling or looping link returns an error.
+func (p *symlinkPolicy) entry(path string) (info os.FileInfo, target string, err error) {
+	if p == nil {
+		return nil, "", nil
+	}
+	if p.mode == db.SymlinksRecord {
+		if target, err = os.Readlink(path); err != nil {
+			return nil, "", err
+		}
+		if info, err = os.Lstat(path); err != nil {
+			return nil, "", err
+		}
+		return info, target, nil
+	}
+	info, err = os.Stat(path)
+	return info, "", err
+}
+
+// firstVisit reports whether dir has not been walked yet in this scan. Only follow mode tracks directories: a link
+// back to an ancestor (a cycle) or to a directory walked through another path is then listed once.
+func (p *symlinkPolicy) firstVisit(dir string) bool {
+	if p == nil || p.mode != db.SymlinksFollow {
+		return true
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return true // let ReadDir report the error
+	}
+	var key string
+	if inode, dev := inodeAndDev(dir, info); inode != 0 {
+		key = strconv.FormatInt(dev, 10) + ":" + strconv.FormatInt(inode, 10)
+	} else if real, err := filepath.EvalSymlinks(dir); err == nil {
+		key = real // no inode for this file (e.g. some network filesystems)
+	} else {
+		return true
+	}
+	_, walked := p.seen.LoadOrStore(key, struct{}{})
+	return !walked
+}
@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eargollo/ditto/internal/db"
+)
+
+// symlinkFarm creates dir/real/a.txt, dir/farm/a.txt -> ../real/a.txt, dir/farm/loop -> .. and a dangling link.
+func symlinkFarm(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "real"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "farm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "real", "a.txt"), []byte("song"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"a.txt": "../real/a.txt", "loop": "..", "dangling": "../missing.txt"} {
+		if err := os.Symlink(target, filepath.Join(dir, "farm", link)); err != nil {
+			t.Skipf("symlink not supported: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestSymlinkPolicy_entry(t *testing.T) {
+	dir := symlinkFarm(t)
+	link := filepath.Join(dir, "farm", "a.txt")
+
+	if p := newSymlinkPolicy(db.SymlinksSkip); p != nil {
+		t.Fatalf("newSymlinkPolicy(skip) = %+v, want nil", p)
+	}
+	var skip *symlinkPolicy
+	if info, _, err := skip.entry(link); info != nil || err != nil {
+		t.Errorf("skip: entry = %v, %v; want nil, nil", info, err)
+	}
+
+	info, target, err := newSymlinkPolicy(db.SymlinksRecord).entry(link)
+	if err != nil || info == nil || target != "../real/a.txt" || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("record: entry = %v, %q, %v; want the link itself with its target", info, target, err)
+	}
+
+	follow := newSymlinkPolicy(db.SymlinksFollow)
+	info, target, err = follow.entry(link)
+	if err != nil || info == nil || target != "" || info.Size() != 4 {
+		t.Errorf("follow: entry = %v, %q, %v; want the 4-byte target file", info, target, err)
+	}
+	if _, _, err := follow.entry(filepath.Join(dir, "farm", "dangling")); err == nil {
+		t.Error("follow: dangling link err = nil, want error")
+	}
+}
+
+func TestSymlinkPolicy_firstVisitDetectsCycles(t *testing.T) {
+	dir := symlinkFarm(t)
+	follow := newSymlinkPolicy(db.SymlinksFollow)
+	if !follow.firstVisit(dir) {
+		t.Fatal("firstVisit(root) = false, want true")
+	}
+	if follow.firstVisit(filepath.Join(dir, "farm", "loop")) {
+		t.Error("firstVisit(farm/loop -> root) = true, want false")
+	}
+	if !follow.firstVisit(filepath.Join(dir, "farm")) {
+		t.Error("firstVisit(farm) = false, want true")
+	}
+}
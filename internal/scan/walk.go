@@ -4,11 +4,9 @@ import (
 	"context"
 	"io/fs"
 	"log"
-	"math"
 	"os"
 	"path/filepath"
 	"strings"
-	"syscall"
 
 	"golang.org/x/time/rate"
 )
@@ -18,14 +16,16 @@ import (
 // the last "[scan] listing directory: <path>" line is the path to add to default.dittoignore.
 const DebugScanEnv = "DITTO_DEBUG_SCAN"
 
-// Entry holds metadata for a single regular file (no content).
+// Entry holds metadata for a single regular file (no content), or for a recorded symlink when SymlinkTarget is set.
 // DeviceID is nil when the OS does not provide a device id (e.g. Windows).
 type Entry struct {
-	Path     string
-	Size     int64
-	MTime    int64
-	Inode    int64
-	DeviceID *int64
+	Path          string
+	Size          int64
+	MTime         int64
+	Inode         int64
+	DeviceID      *int64
+	SymlinkTarget string // set only for symlinks recorded in db.SymlinksRecord mode (pipeline only)
+	Allocated     *int64 // bytes allocated on disk (less than Size when sparse); nil = unknown (pipeline only)
 }
 
 // ScanStats holds optional counters updated during Walk (e.g. paths skipped).
@@ -38,7 +38,7 @@ type ScanStats struct {
 // followed and are not yielded (ADR-006). Directories are not yielded.
 // Uses Lstat so symlink targets are never followed.
 // If excludePatterns is non-nil and non-empty, paths matching any pattern are skipped
-// (see ShouldExclude). Excluded directories are not recursed into.
+// (see Excludes). Excluded directories are not recursed into.
 // If stats is non-nil, SkippedScan is incremented for each path skipped (permission or exclude).
 // If maxFilesPerSecond > 0, fn is rate-limited to that many files per second (burst 1);
 // if 0, no throttle (full speed).
@@ -47,6 +47,7 @@ func Walk(ctx context.Context, root string, excludePatterns []string, maxFilesPe
 	if maxFilesPerSecond > 0 {
 		limiter = rate.NewLimiter(rate.Limit(maxFilesPerSecond), 1)
 	}
+	excludes := CompileExcludes(excludePatterns)
 	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			if isPermissionOrAccessError(err) {
@@ -62,7 +63,7 @@ func Walk(ctx context.Context, root string, excludePatterns []string, maxFilesPe
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		if ShouldExclude(path, excludePatterns) {
+		if excludes.Excluded(root, path, d.IsDir()) {
 			if stats != nil && stats.SkippedScan != nil {
 				*stats.SkippedScan++
 			}
@@ -93,7 +94,7 @@ func Walk(ctx context.Context, root string, excludePatterns []string, maxFilesPe
 			log.Printf("[scan] error at %s (Abs): %v", path, err)
 			return err
 		}
-		inode, dev := inodeAndDev(info)
+		inode, dev := inodeAndDev(path, info)
 		var deviceID *int64
 		if dev != 0 {
 			deviceID = &dev
@@ -131,34 +132,3 @@ func isPermissionOrAccessError(err error) bool {
 		strings.Contains(msg, "permission denied") ||
 		strings.Contains(msg, "Permission denied")
 }
-
-func inodeAndDev(info os.FileInfo) (inode, dev int64) {
-	sys := info.Sys()
-	if sys == nil {
-		return 0, 0
-	}
-	if st, ok := sys.(*syscall.Stat_t); ok {
-		return statTToInt64(any(st.Ino)), statTToInt64(any(st.Dev))
-	}
-	return 0, 0
-}
-
-// statTToInt64 converts Stat_t Ino/Dev to int64 without overflow (type varies by OS: uint64, int32, etc.).
-func statTToInt64(v interface{}) int64 {
-	switch x := v.(type) {
-	case uint64:
-		if x > math.MaxInt64 {
-			return 0
-		}
-		return int64(x)
-	case int32:
-		return int64(x)
-	case uint32:
-		if x > math.MaxInt32 {
-			return 0
-		}
-		return int64(x)
-	default:
-		return 0
-	}
-}
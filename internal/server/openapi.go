@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiOperation describes a JSON endpoint for the OpenAPI document. Routes registered with s.api carry one,
+// so the document lists exactly the handlers the mux serves, with schemas derived from the Go types they
+// encode and decode.
+type apiOperation struct {
+	Summary  string
+	Query    []apiParam // query parameters (path parameters come from the pattern)
+	Request  any        // value of the JSON request body type; nil when there is no body
+	Response any        // value of the JSON response type; nil for an empty response
+	Status   int        // success status; 200 when zero
+	Admin    bool       // changes something: needs an admin (and an admin API token)
+}
+
+// apiParam is a query parameter of an apiOperation.
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// apiRoute is a registered JSON endpoint.
+type apiRoute struct {
+	Method, Path string
+	Op           apiOperation
+}
+
+// api registers a JSON endpoint (pattern "METHOD /path") on the mux and records it for the OpenAPI document.
+func (s *Server) api(pattern string, op apiOperation, h http.HandlerFunc) {
+	method, path, _ := strings.Cut(pattern, " ")
+	s.apiRoutes = append(s.apiRoutes, apiRoute{Method: method, Path: path, Op: op})
+	s.mux.HandleFunc(pattern, h)
+}
+
+// handleOpenAPI serves the OpenAPI 3 document of the JSON API.
+func (s *Server) handleOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(openAPIDocument(s.apiRoutes)); err != nil {
+			log.Printf("error: write OpenAPI document: %v", err)
+		}
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// openAPIDocument builds the OpenAPI 3 document for the routes.
+func openAPIDocument(routes []apiRoute) map[string]any {
+	schemas := make(map[string]any)
+	paths := make(map[string]map[string]any)
+	for _, rt := range routes {
+		op := map[string]any{"summary": rt.Op.Summary}
+		var params []any
+		for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+			schema := map[string]any{"type": "string"}
+			if m[1] == "id" {
+				schema = map[string]any{"type": "integer", "format": "int64"}
+			}
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": schema})
+		}
+		for _, q := range rt.Op.Query {
+			params = append(params, map[string]any{"name": q.Name, "in": "query", "required": q.Required, "description": q.Description, "schema": map[string]any{"type": "string"}})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if rt.Op.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(rt.Op.Request), schemas)}},
+			}
+		}
+		status := rt.Op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := map[string]any{"description": http.StatusText(status)}
+		if rt.Op.Response != nil {
+			ok["content"] = map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(rt.Op.Response), schemas)}}
+		}
+		responses := map[string]any{strconv.Itoa(status): ok, "401": map[string]any{"description": "Invalid API token or no signed-in user"}}
+		if rt.Op.Admin {
+			responses["403"] = map[string]any{"description": "Needs an admin and an admin API token"}
+		}
+		if len(params) > 0 {
+			responses["404"] = map[string]any{"description": "Not found"}
+		}
+		op["responses"] = responses
+		path := pathParam.ReplaceAllString(rt.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(rt.Method)] = op
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "ditto", "version": "1"},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas":         schemas,
+			"securitySchemes": map[string]any{"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"}},
+		},
+		// A single-user instance needs no token; otherwise send an API token.
+		"security": []any{map[string]any{}, map[string]any{"bearerAuth": []any{}}},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns the schema of t as encoding/json writes it. Named structs become components in
+// schemas and are referenced.
+func jsonSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := jsonSchema(t.Elem(), schemas)
+		if _, ref := s["$ref"]; ref {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = nil // placeholder: a type that refers to itself stops here
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the object schema of a struct's exported fields, named by their json tags.
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range structSchema(f.Type, schemas)["properties"].(map[string]any) {
+				props[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = jsonSchema(f.Type, schemas)
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// schemaName names a component after its Go type, with the package for types outside this one
+// (db.ScanRoot becomes "db.ScanRoot", startScanRequest "startScanRequest").
+func schemaName(t reflect.Type) string {
+	if t.PkgPath() == reflect.TypeOf(apiRoute{}).PkgPath() {
+		return t.Name()
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg + "." + t.Name()
+}
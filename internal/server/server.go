@@ -1,23 +1,52 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"embed"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
 	"io/fs"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/eargollo/ditto/internal/archive"
 	"github.com/eargollo/ditto/internal/config"
 	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/dedupe"
+	"github.com/eargollo/ditto/internal/extents"
 	"github.com/eargollo/ditto/internal/hash"
+	"github.com/eargollo/ditto/internal/ioprio"
+	"github.com/eargollo/ditto/internal/limits"
+	"github.com/eargollo/ditto/internal/manifest"
+	"github.com/eargollo/ditto/internal/offline"
+	"github.com/eargollo/ditto/internal/pathmap"
+	"github.com/eargollo/ditto/internal/pathnorm"
+	"github.com/eargollo/ditto/internal/rootlist"
 	"github.com/eargollo/ditto/internal/scan"
+	"github.com/eargollo/ditto/internal/similarity"
+	"github.com/eargollo/ditto/internal/textdiff"
+	"github.com/eargollo/ditto/internal/thumb"
+	"github.com/eargollo/ditto/internal/volume"
 )
 
 //go:embed templates/*
@@ -26,31 +55,75 @@ var templateFS embed.FS
 //go:embed static/*
 var staticFS embed.FS
 
-const scanQueueCap = 64
+const (
+	jobPollInterval = 5 * time.Second    // the worker also checks the jobs table this often (jobs queued by other processes)
+	jobRetention    = 7 * 24 * time.Hour // finished jobs are deleted after this long
+	readTimeout     = 8 * time.Second    // database work of a GET request is cancelled after this, below the WriteTimeout
+	groupCacheTTL   = 5 * time.Minute    // cached duplicate group pages are recomputed after this, see groupCache
+	groupCacheSize  = 64                 // cached duplicate group pages at most
+	exportFlushRows = 1000               // a streamed export is flushed to the client every this many rows
+)
 
 type Server struct {
-	cfg       *config.Config
-	db        *sql.DB
-	mux       *http.ServeMux
-	tmpl      *template.Template
-	scanQueue chan int64 // scan IDs to process; one worker runs them serially
+	cfg      *config.Config
+	db       *sql.DB
+	readDB   *sql.DB // optional replica for read-only handlers (nil = db)
+	mux      *http.ServeMux
+	tmpl     *template.Template
+	jobWake  chan struct{} // signals the worker that a job was queued (the queue itself is the jobs table)
+	running  atomic.Int64  // scan the worker is processing (0 = idle); it cannot be deleted meanwhile
+	maintain atomic.Bool   // database maintenance in progress (one run at a time)
+	users    sync.Map      // user name -> *db.User, so withUser writes each user once per process
+	thumbs   *thumb.Cache  // image previews, under the data directory
+
+	readTimeout time.Duration // deadline of GET requests, see withReadTimeout
+	groups      groupCache    // recent duplicate group pages
+
+	apiRoutes []apiRoute // JSON endpoints, for the OpenAPI document
 }
 
 // NewServer creates a server using the given config and database.
 func NewServer(cfg *config.Config, database *sql.DB) (*Server, error) {
+	var paths pathmap.Map
+	if cfg != nil {
+		paths = cfg.PathMap()
+	}
 	fm := template.FuncMap{
 		"formatBytes": formatBytes,
+		"hostPath":    paths.ToHost,
+		"mbps":        mbps,
+		"kbps":        kbps,
+		"unixTime":    unixTime,
+		"rank":        func(first, i int) int { return first + i },
+		"isImage":     isImage,
+		"duration":    func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
 	}
 	tmpl, err := template.New("").Funcs(fm).ParseFS(fs.FS(templateFS), "templates/*.html")
 	if err != nil {
 		return nil, err
 	}
-	s := &Server{cfg: cfg, db: database, mux: http.NewServeMux(), tmpl: tmpl, scanQueue: make(chan int64, scanQueueCap)}
+	s := &Server{cfg: cfg, db: database, mux: http.NewServeMux(), tmpl: tmpl, jobWake: make(chan struct{}, 1), readTimeout: readTimeout}
+	thumbDir := ""
+	if cfg != nil {
+		thumbDir = filepath.Join(cfg.DataDir(), "thumbnails")
+	}
+	s.thumbs = thumb.NewCache(thumbDir, thumb.DefaultSize)
 	s.routes()
 	return s, nil
 }
 
-func (s *Server) dbForRead() *sql.DB { return s.db }
+// SetReadDB sends the read-only queries of the web UI (those using dbForRead) to database, e.g. a streaming
+// replica, so heavy duplicate listings do not compete with scans writing to the primary.
+func (s *Server) SetReadDB(database *sql.DB) { s.readDB = database }
+
+// dbForRead returns the database for read-only queries: the read replica when one is set. A replica may lag the
+// primary, so handlers that must see their own writes use s.db.
+func (s *Server) dbForRead() *sql.DB {
+	if s.readDB != nil {
+		return s.readDB
+	}
+	return s.db
+}
 
 func formatBytes(n int64) string {
 	const unit = 1024
@@ -73,18 +146,110 @@ func formatBytes(n int64) string {
 	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + units[exp]
 }
 
+// mbps formats a bytes-per-second value as MB/s for form inputs (e.g. "12.5").
+func mbps(bytesPerSec int64) string {
+	return strconv.FormatFloat(float64(bytesPerSec)/(1024*1024), 'f', -1, 64)
+}
+
+// kbps formats a bitrate in bits per second as kilobits per second, e.g. "1411 kb/s".
+func kbps(bitsPerSec int64) string {
+	return strconv.FormatInt(bitsPerSec/1000, 10) + " kb/s"
+}
+
+// unixTime formats a file mtime (Unix seconds) as local "2006-01-02 15:04".
+func unixTime(sec int64) string {
+	return time.Unix(sec, 0).Format("2006-01-02 15:04")
+}
+
 func (s *Server) routes() {
 	s.mux.HandleFunc("GET /{$}", s.handleHome())
 	s.mux.HandleFunc("GET /scans", s.handleScans())
-	s.mux.HandleFunc("GET /scans/roots", s.handleScanRootsList())
+	s.api("GET /scans/roots", apiOperation{Summary: "List scan roots", Response: []db.ScanRoot{}}, s.handleScanRootsList())
 	s.mux.HandleFunc("POST /scans/roots", s.handleScanRootsAdd())
-	s.mux.HandleFunc("POST /scans/start", s.handleScansStart())
-	s.mux.HandleFunc("POST /scans/{id}/continue", s.handleScanContinue())
-	s.mux.HandleFunc("GET /scans/{id}/status", s.handleScanStatus())
-	s.mux.HandleFunc("GET /scans/{id}/duplicates/hash/{hash}", s.handleDuplicateHashGroup())
-	s.mux.HandleFunc("GET /scans/{id}/duplicates/inode", s.handleDuplicateInodeGroup())
-	s.mux.HandleFunc("GET /scans/{id}/duplicates", s.handleDuplicates())
-	s.mux.HandleFunc("GET /scans/{id}", s.handleScanProgress())
+	s.mux.HandleFunc("POST /scans/roots/import", s.handleScanRootsImport())
+	s.mux.HandleFunc("GET /scans/roots/browse", s.handleScanRootsBrowse())
+	s.mux.HandleFunc("POST /scans/roots/{id}/settings", s.ownFolder(s.handleScanRootSettings()))
+	s.mux.HandleFunc("POST /scans/roots/{id}/owner", s.ownFolder(s.handleScanRootOwner()))
+	s.mux.HandleFunc("POST /scans/roots/{id}/move", s.ownFolder(s.handleScanRootMove()))
+	s.mux.HandleFunc("POST /scans/roots/{id}/disable", s.ownFolder(s.handleScanRootDisabled(true)))
+	s.mux.HandleFunc("POST /scans/roots/{id}/enable", s.ownFolder(s.handleScanRootDisabled(false)))
+	s.mux.HandleFunc("GET /scans/roots/{id}/excludes", s.ownFolder(s.handleScanRootExcludes()))
+	s.mux.HandleFunc("POST /scans/roots/{id}/excludes", s.ownFolder(s.handleScanRootExcludeAdd()))
+	s.mux.HandleFunc("POST /scans/roots/{id}/excludes/{exclude}/edit", s.ownFolder(s.handleScanRootExcludeUpdate()))
+	s.mux.HandleFunc("POST /scans/roots/{id}/excludes/{exclude}/delete", s.ownFolder(s.handleScanRootExcludeDelete()))
+	s.api("POST /scans/start", apiOperation{
+		Summary: "Start a scan (a form post from the scans page redirects to the scan instead)",
+		Request: startScanRequest{}, Response: startScanResponse{}, Status: http.StatusAccepted, Admin: true,
+	}, s.handleScansStart())
+	s.api("GET /api/v1/scans/{id}", apiOperation{Summary: "Scan status and counts", Response: apiScan{}}, s.ownScan(s.handleAPIScan()))
+	s.api("GET /api/v1/openapi.json", apiOperation{Summary: "This document"}, s.handleOpenAPI())
+	s.mux.HandleFunc("POST /scans/{id}/continue", s.ownScan(s.handleScanContinue()))
+	s.mux.HandleFunc("POST /scans/{id}/pause", s.ownScan(s.handleScanPause()))
+	s.mux.HandleFunc("POST /scans/{id}/resume", s.ownScan(s.handleScanContinue()))
+	s.mux.HandleFunc("POST /scans/{id}/skip-hash", s.ownScan(s.handleScanSkipHash()))
+	s.mux.HandleFunc("GET /scans/{id}/status", s.ownScan(s.handleScanStatus()))
+	s.mux.HandleFunc("POST /preferences", s.handlePreferencesSave())
+	s.mux.HandleFunc("GET /preview", s.handlePreview())
+	s.mux.HandleFunc("GET /preview/text", s.handleTextPreview())
+	s.mux.HandleFunc("GET /diff", s.handleDiff())
+	s.mux.HandleFunc("GET /scans/{id}/duplicates/hash/{hash}", s.ownScan(s.handleDuplicateHashGroup()))
+	s.mux.HandleFunc("GET /scans/{id}/duplicates/hash/{hash}/paths", s.ownScan(s.handleGroupPaths()))
+	s.mux.HandleFunc("POST /scans/{id}/duplicates/hash/{hash}/verify", s.ownScan(s.handleVerifyHashGroup()))
+	s.mux.HandleFunc("POST /scans/{id}/duplicates/hash/{hash}/rehash", s.ownScan(s.handleRehashHashGroup()))
+	s.mux.HandleFunc("POST /scans/{id}/duplicates/hash/{hash}/link", s.ownScan(s.handleLinkHashGroup()))
+	s.mux.HandleFunc("POST /scans/{id}/duplicates/hash/{hash}/acknowledge", s.ownScan(s.handleAcknowledgeHashGroup()))
+	s.mux.HandleFunc("POST /scans/{id}/duplicates/hash/{hash}/unacknowledge", s.ownScan(s.handleUnacknowledgeHashGroup()))
+	s.mux.HandleFunc("POST /scans/{id}/duplicates/hash/{hash}/keeper", s.ownScan(s.handleHashGroupKeeper()))
+	s.mux.HandleFunc("POST /scans/{id}/duplicates/hash/{hash}/keeper/clear", s.ownScan(s.handleHashGroupKeeperClear()))
+	s.mux.HandleFunc("POST /duplicates/bulk", s.handleDuplicatesBulk())
+	s.mux.HandleFunc("GET /acknowledged", s.handleAcknowledgedGroups())
+	s.mux.HandleFunc("GET /content/{hash}", s.handleKnownContent())
+	s.mux.HandleFunc("POST /acknowledged/{hash}/delete", s.handleAcknowledgedGroupDelete())
+	s.mux.HandleFunc("GET /scans/{id}/duplicates/inode", s.ownScan(s.handleDuplicateInodeGroup()))
+	s.api("GET /scans/{id}/duplicates/export", apiOperation{
+		Summary:  "Export the scan's duplicate groups (format=csv is the default and returns CSV)",
+		Query:    []apiParam{{Name: "format", Description: "json or csv", Required: true}},
+		Response: []exportGroup{},
+	}, s.ownScan(s.handleDuplicatesExport()))
+	s.mux.HandleFunc("GET /scans/{id}/duplicates", s.ownScan(s.handleDuplicates()))
+	s.mux.HandleFunc("GET /scans/{id}/changes", s.ownScan(s.handleScanChanges()))
+	s.mux.HandleFunc("GET /scans/{id}/largest", s.ownScan(s.handleLargestFiles()))
+	s.mux.HandleFunc("GET /scans/{id}/names", s.ownScan(s.handleSameName()))
+	s.mux.HandleFunc("GET /scans/{id}/symlinks", s.ownScan(s.handleScanSymlinks()))
+	s.mux.HandleFunc("GET /scans/{id}/slow-dirs", s.ownScan(s.handleScanSlowDirs()))
+	s.mux.HandleFunc("GET /scans/{id}/errors", s.ownScan(s.handleScanErrors()))
+	s.mux.HandleFunc("GET /scans/{id}/bitrot", s.ownScan(s.handleScanBitrot()))
+	s.mux.HandleFunc("POST /scans/{id}/errors/retry", s.ownScan(s.handleScanErrorsRetry()))
+	s.mux.HandleFunc("GET /scans/{id}/usage", s.ownScan(s.handleDirectoryUsage()))
+	s.mux.HandleFunc("GET /scans/{id}/similar", s.ownScan(s.handleSimilarImages()))
+	s.mux.HandleFunc("GET /scans/{id}/similar-media", s.ownScan(s.handleSimilarMedia()))
+	s.api("GET /scans/{id}/manifest", apiOperation{Summary: "Signed manifest of a hashed scan", Response: manifest.Manifest{}}, s.ownScan(s.handleScanManifest()))
+	s.mux.HandleFunc("POST /scans/{id}/lock", s.ownScan(s.handleScanLock()))
+	s.mux.HandleFunc("DELETE /scans/{id}", s.ownScan(s.handleScanDelete()))
+	s.mux.HandleFunc("POST /scans/{id}/delete", s.ownScan(s.handleScanDelete()))
+	s.mux.HandleFunc("GET /scans/{id}/integrity", s.ownScan(s.handleScanIntegrity()))
+	s.mux.HandleFunc("GET /scans/{id}", s.ownScan(s.handleScanProgress()))
+	s.api("GET /manifests", apiOperation{Summary: "Digests of every exported scan manifest", Response: manifest.Index{}}, s.handleManifestIndex())
+	s.mux.HandleFunc("GET /scans/{id}/compare", s.ownScan(s.handleScanCompare()))
+	s.mux.HandleFunc("GET /imports", s.handleImports())
+	s.mux.HandleFunc("POST /imports", s.handleImportsUpload())
+	s.api("POST /api/v1/imports", apiOperation{
+		Summary:  "Import a catalog (multipart form: name, optional host, and manifest in any format the Imports page accepts), as sent by ditto agent",
+		Response: importResponse{}, Status: http.StatusCreated, Admin: true,
+	}, s.handleAPIImport())
+	s.mux.HandleFunc("GET /volumes", s.handleVolumes())
+	s.mux.HandleFunc("POST /volumes/{id}/settings", s.handleVolumeSettings())
+	s.mux.HandleFunc("GET /scans/{id}/share", s.ownScan(s.handleShareLinks()))
+	s.mux.HandleFunc("POST /scans/{id}/share", s.ownScan(s.handleShareLinkCreate()))
+	s.mux.HandleFunc("POST /scans/{id}/share/{link}/delete", s.ownScan(s.handleShareLinkDelete()))
+	s.mux.HandleFunc("GET /share/{token}", s.handleSharedReport())
+	s.mux.HandleFunc("GET /users", s.handleUsers())
+	s.mux.HandleFunc("POST /users/{id}/role", s.handleUserRole())
+	s.mux.HandleFunc("GET /tokens", s.handleAPITokens())
+	s.mux.HandleFunc("POST /tokens", s.handleAPITokenCreate())
+	s.mux.HandleFunc("POST /tokens/{id}/delete", s.handleAPITokenDelete())
+	s.mux.HandleFunc("GET /diagnostics", s.handleDiagnostics())
+	s.mux.HandleFunc("POST /diagnostics/maintain", s.handleMaintain())
 	s.mux.HandleFunc("GET /api/fragment", s.handleFragment())
 	s.mux.HandleFunc("GET /health", s.handleHealth())
 	staticRoot, _ := fs.Sub(staticFS, "static")
@@ -92,6 +257,385 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/", s.handle404())
 }
 
+type userKey struct{}
+
+// userFrom returns the signed-in user of the request, or nil on a single-user instance.
+func userFrom(ctx context.Context) *db.User {
+	u, _ := ctx.Value(userKey{}).(*db.User)
+	return u
+}
+
+// withReadTimeout puts a deadline on GET requests, so a pathological query (a duplicates listing of a huge scan
+// without filters) is cancelled in PostgreSQL instead of holding a connection after the browser gave up. When a
+// handler fails because of that deadline, the visitor gets a page that suggests adding filters instead of the
+// raw error.
+func (s *Server) withReadTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || strings.HasPrefix(r.URL.Path, "/static/") || isDownload(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), s.readTimeout)
+		defer cancel()
+		next.ServeHTTP(&readTimeoutWriter{ResponseWriter: w, s: s, r: r.WithContext(ctx)}, r.WithContext(ctx))
+	})
+}
+
+// isDownload reports whether the path is an export that streams a whole scan, which may take minutes for a large
+// one: it gets neither the read deadline nor the server's write timeout.
+func isDownload(path string) bool {
+	return strings.HasSuffix(path, "/duplicates/export") || strings.HasSuffix(path, "/manifest")
+}
+
+// readTimeoutWriter replaces a server error written after the request's deadline passed with the "took too long"
+// page, and drops the error body that follows.
+type readTimeoutWriter struct {
+	http.ResponseWriter
+	s        *Server
+	r        *http.Request
+	replaced bool
+}
+
+func (tw *readTimeoutWriter) WriteHeader(code int) {
+	if code >= http.StatusInternalServerError && errors.Is(tw.r.Context().Err(), context.DeadlineExceeded) {
+		tw.replaced = true
+		log.Printf("[http] %s %s: stopped after %v", tw.r.Method, tw.r.URL.Path, tw.s.readTimeout)
+		tw.s.renderTimeout(tw.ResponseWriter, tw.r)
+		return
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *readTimeoutWriter) Write(b []byte) (int, error) {
+	if tw.replaced {
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (to flush streamed responses).
+func (tw *readTimeoutWriter) Unwrap() http.ResponseWriter { return tw.ResponseWriter }
+
+// renderTimeout writes the "took too long" page with status 503; htmx requests get only its content.
+func (s *Server) renderTimeout(w http.ResponseWriter, r *http.Request) {
+	data := struct{ Timeout time.Duration }{s.readTimeout}
+	var buf bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&buf, "timeout-content", data); err != nil {
+		http.Error(w, "query took too long", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("HX-Request") == "" {
+		page := pageData{Content: template.HTML(buf.Bytes()), Data: data} // #nosec G203 -- content from our own templates, not user input
+		var layoutBuf bytes.Buffer
+		if err := s.tmpl.ExecuteTemplate(&layoutBuf, "layout.html", page); err != nil {
+			http.Error(w, "query took too long", http.StatusServiceUnavailable)
+			return
+		}
+		buf = layoutBuf
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// groupCache keeps recent pages of duplicate groups, since a review session reloads the same first page after
+// every action. Any change request (not GET) and every finished job drop the cache; entries also expire after
+// groupCacheTTL for changes made by another process (a "ditto scan" run).
+type groupCache struct {
+	mu      sync.Mutex
+	gen     uint64 // bumped by invalidate, so a page computed before a change is not stored after it
+	entries map[string]groupCacheEntry
+}
+
+type groupCacheEntry struct {
+	at     time.Time
+	groups []db.DuplicateGroupByHash
+}
+
+// get returns the cached page for key, and the generation to pass to put when there is none.
+func (c *groupCache) get(key string) ([]db.DuplicateGroupByHash, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && time.Since(e.at) < groupCacheTTL {
+		return e.groups, c.gen, true
+	}
+	return nil, c.gen, false
+}
+
+// put stores a page computed at generation gen, unless the cache was invalidated since.
+func (c *groupCache) put(key string, gen uint64, groups []db.DuplicateGroupByHash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if c.entries == nil || len(c.entries) >= groupCacheSize {
+		c.entries = make(map[string]groupCacheEntry)
+	}
+	c.entries[key] = groupCacheEntry{at: time.Now(), groups: groups}
+}
+
+func (c *groupCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = nil
+}
+
+// duplicateGroups returns a page of duplicate groups of the scans, from the cache when the same page was listed
+// recently. Pages are not cached while the worker runs a scan, whose hashes change as it goes.
+func (s *Server) duplicateGroups(ctx context.Context, scanIDs []int64, filter db.DuplicateGroupFilter, limit, offset int) ([]db.DuplicateGroupByHash, error) {
+	key := fmt.Sprint(scanIDs, filter, limit, offset)
+	groups, gen, ok := s.groups.get(key)
+	if ok {
+		return groups, nil
+	}
+	groups, err := db.DuplicateGroupsByHashPaginatedAcrossScans(ctx, s.dbForRead(), scanIDs, filter, limit, offset)
+	if err == nil && s.running.Load() == 0 {
+		s.groups.put(key, gen, groups)
+	}
+	return groups, err
+}
+
+// withGroupInvalidation drops the cached duplicate group pages after every request that may change them.
+func (s *Server) withGroupInvalidation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			s.groups.invalidate()
+		}
+	})
+}
+
+type tokenKey struct{}
+
+// tokenFrom returns the API token the request was made with, or nil for a browser request.
+func tokenFrom(ctx context.Context) *db.APIToken {
+	t, _ := ctx.Value(tokenKey{}).(*db.APIToken)
+	return t
+}
+
+// withUser resolves the user named by the DITTO_USER_HEADER header (set by a trusted reverse proxy) and
+// puts it in the request context. Without the header configured every request sees everything. Health
+// checks, static files and share links (which carry their own token) need no user. A request with an
+// Authorization: Bearer API token acts for the token's user instead, within the token's scope.
+func (s *Server) withUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || r.URL.Path == "/api/v1/openapi.json" ||
+			strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, "/share/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			s.serveWithToken(w, r, next, strings.TrimSpace(token))
+			return
+		}
+		if s.cfg == nil || s.cfg.UserHeader() == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name := strings.TrimSpace(r.Header.Get(s.cfg.UserHeader()))
+		if name == "" {
+			http.Error(w, "sign-in required", http.StatusUnauthorized)
+			return
+		}
+		u, ok := s.users.Load(name)
+		if !ok {
+			role := db.RoleAdmin
+			if len(s.cfg.Admins()) > 0 {
+				role = db.RoleViewer
+			}
+			created, err := db.GetOrCreateUser(r.Context(), s.db, name, role)
+			if err != nil {
+				log.Printf("error: get or create user %q: %v", name, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			u, _ = s.users.LoadOrStore(name, created)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, u))
+		// Viewers only look (and keep their own preferences): anything else but a read needs an admin.
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.URL.Path != "/preferences" && !s.requireAdmin(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveWithToken serves a request authenticated by an API token. Unknown and revoked tokens get 401;
+// read-only tokens (and tokens of viewers) only read.
+func (s *Server) serveWithToken(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	t, err := db.GetAPIToken(r.Context(), s.db, token)
+	if errors.Is(err, db.ErrAPITokenInvalid) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("error: look up API token: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx := context.WithValue(r.Context(), tokenKey{}, t)
+	if t.UserID != nil {
+		u, err := db.GetUser(ctx, s.db, *t.UserID)
+		if err != nil {
+			log.Printf("error: user %d of API token %d: %v", *t.UserID, t.ID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx = context.WithValue(ctx, userKey{}, u)
+	}
+	r = r.WithContext(ctx)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && !s.requireAdmin(w, r) {
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// isAdmin reports whether the request's user may change things. On a single-user instance everyone is,
+// except through a read-only API token.
+func (s *Server) isAdmin(ctx context.Context) bool {
+	if t := tokenFrom(ctx); t != nil && t.Scope != db.TokenScopeAdmin {
+		return false
+	}
+	u := userFrom(ctx)
+	return u == nil || u.Role == db.RoleAdmin || slices.Contains(s.cfg.Admins(), u.Name)
+}
+
+// requireAdmin answers 403 and returns false unless the request's user is an admin. Handlers that scan,
+// link or delete call it themselves too, so they stay guarded wherever they are mounted.
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.isAdmin(r.Context()) {
+		return true
+	}
+	http.Error(w, "viewers cannot change anything; ask an admin", http.StatusForbidden)
+	return false
+}
+
+// ownScan answers 404 for a scan ({id}) in a folder owned by another user, as if it did not exist (and
+// when visibility cannot be checked).
+func (s *Server) ownScan(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if userFrom(r.Context()) != nil {
+			scanID, err := parseScanID(r.PathValue("id"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !s.scanVisible(r.Context(), scanID) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// ownFolder answers 404 for a scan root ({id}) owned by another user.
+func (s *Server) ownFolder(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if userFrom(r.Context()) != nil {
+			folderID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+			if err != nil {
+				http.Error(w, "invalid root id", http.StatusBadRequest)
+				return
+			}
+			if !s.folderVisible(r.Context(), folderID) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// scanVisible reports whether the request's user may see the scan (false on error).
+func (s *Server) scanVisible(ctx context.Context, scanID int64) bool {
+	u := userFrom(ctx)
+	if u == nil {
+		return true
+	}
+	ok, err := db.ScanVisibleTo(ctx, s.dbForRead(), scanID, u.ID)
+	if err != nil {
+		log.Printf("error: scan %d visibility: %v", scanID, err)
+	}
+	return ok && err == nil
+}
+
+// folderVisible reports whether the request's user may see the folder (false on error).
+func (s *Server) folderVisible(ctx context.Context, folderID int64) bool {
+	u := userFrom(ctx)
+	if u == nil {
+		return true
+	}
+	ok, err := db.FolderVisibleTo(ctx, s.dbForRead(), folderID, u.ID)
+	if err != nil {
+		log.Printf("error: folder %d visibility: %v", folderID, err)
+	}
+	return ok && err == nil
+}
+
+// claimFolder makes the request's user the owner of a folder they just added (see db.ClaimNewFolder).
+func (s *Server) claimFolder(ctx context.Context, folderID int64) {
+	u := userFrom(ctx)
+	if u == nil {
+		return
+	}
+	if _, err := db.ClaimNewFolder(ctx, s.db, folderID, u.ID); err != nil {
+		log.Printf("error: claim folder %d for user %q: %v", folderID, u.Name, err)
+	}
+}
+
+// hiddenPaths returns the root paths of folders the request's user may not see (nil when all are visible).
+func (s *Server) hiddenPaths(ctx context.Context) (map[string]bool, error) {
+	u := userFrom(ctx)
+	if u == nil {
+		return nil, nil
+	}
+	return db.HiddenFolderPaths(ctx, s.dbForRead(), u.ID)
+}
+
+// visibleScans drops scans of folders owned by another user. On error it returns none: a user must never
+// see another user's folders because a query failed.
+func (s *Server) visibleScans(ctx context.Context, scans []db.Scan) []db.Scan {
+	hidden, err := s.hiddenPaths(ctx)
+	if err != nil {
+		log.Printf("error: hidden folders: %v", err)
+		return nil
+	}
+	if hidden == nil {
+		return scans
+	}
+	var out []db.Scan
+	for _, sc := range scans {
+		if !hidden[sc.RootPath] {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+// visibleRoots drops scan roots owned by another user (none on error, like visibleScans).
+func (s *Server) visibleRoots(ctx context.Context, roots []db.ScanRoot) []db.ScanRoot {
+	hidden, err := s.hiddenPaths(ctx)
+	if err != nil {
+		log.Printf("error: hidden folders: %v", err)
+		return nil
+	}
+	if hidden == nil {
+		return roots
+	}
+	var out []db.ScanRoot
+	for _, rt := range roots {
+		if !hidden[rt.Path] {
+			out = append(out, rt)
+		}
+	}
+	return out
+}
+
 type pageData struct {
 	Content template.HTML
 	Data    interface{}
@@ -117,38 +661,116 @@ func (s *Server) renderPage(w http.ResponseWriter, layoutName, contentName strin
 
 const homePageSize = 20
 const maxScansForRoots = 100
-const homeMaxPathsPerGroup = 50 // limit paths loaded per group so home page stays fast
-const homeListScansLimit = 300  // recent scans for dropdown (avoids loading huge scan table)
+const homeGroupPathsPage = 50  // paths per request when a home-page group is expanded
+const homeListScansLimit = 300 // recent scans for dropdown (avoids loading huge scan table)
+
+// mediaForPath returns the offline-media label of the root containing path, or "".
+func mediaForPath(labels map[string]string, path string) string {
+	for root, label := range labels {
+		if strings.HasPrefix(path, root+"/") {
+			return label
+		}
+	}
+	return ""
+}
 
 // ScanRootChoice is a root path with its latest scan id for the home dropdown.
 type ScanRootChoice struct {
-	RootPath  string
-	ScanID    int64
-	CreatedAt time.Time
+	RootPath   string
+	ScanID     int64
+	CreatedAt  time.Time
+	MediaLabel string // offline-media label of the root ("" for regular folders)
 }
 
-// GroupWithPaths is a duplicate group plus the file paths in it (for landing page).
+// GroupWithPaths is a duplicate group. The home page loads its paths when the group is expanded (PathsURL);
+// the shared report lists the first few in Paths.
 type GroupWithPaths struct {
 	Hash           string
 	Count          int64
-	Size           int64 // total group size (sum of file sizes)
-	PerFileSize    int64 // size of each file (Size/Count) for human-readable "X MB each"
-	Paths          []string
-	PathsTruncated bool // true when only first N paths loaded for performance
+	Size           int64    // total group size (sum of file sizes)
+	PerFileSize    int64    // size of each file (Size/Count) for human-readable "X MB each"
+	PathsURL       string   // home page: fragment with the group's paths, a page at a time
+	Paths          []string // shared report: the first paths
+	PathsTruncated bool     // shared report: the group has more files than Paths
+	KeeperPinned   bool     // the group has a pinned keeper
+	Devices        int      // distinct devices the group's files are on (see db.HashGroupDevices)
+	Hosts          []string // machines the group's files are on (see hostLabels); more than one: cross-host group
+	OfflineMedia   []string // offline media holding a copy, when the group also has a copy on online storage
 }
 
 // HomePageData is passed to the home template.
 type HomePageData struct {
-	Roots        []ScanRootChoice // unique roots (latest scan per root) for dropdown
-	SelectedScan int64            // scan id currently shown
-	SelectedRoot string           // root path label
-	Groups       []GroupWithPaths // duplicate groups with file paths
-	Page         int              // 1-based
-	PageSize     int
-	TotalGroups  int64
-	TotalPages   int
-	PrevPage     int // 0 if no prev
-	NextPage     int // 0 if no next
+	Roots           []ScanRootChoice // unique roots (latest scan per root) for dropdown
+	SelectedScan    int64            // scan id currently shown
+	SelectedRoot    string           // root path label
+	Groups          []GroupWithPaths // duplicate groups with file paths
+	Page            int              // 1-based
+	PageSize        int
+	TotalGroups     int64
+	TotalPages      int
+	PrevPage        int                   // 0 if no prev
+	NextPage        int                   // 0 if no next
+	Savings         *db.SavingsProjection // projected savings per strategy for the selection (nil on error)
+	TopExtensions   []db.ExtensionWaste   // extensions wasting the most space (single scan only)
+	ExcludedFromAll int                   // folders whose latest scan is on a volume excluded from All
+	Acknowledged    int64                 // groups marked intentional, hidden from the list and totals
+	Sort            string                // group order (?sort=, a db.GroupSort)
+	MinMB           int64                 // only files of at least this many MiB (?min_mb=, 0: any)
+	PathPrefix      string                // only groups with a file under this directory (?prefix=)
+	PrefixOnly      bool                  // only files under PathPrefix count and are shown (?prefix_only=1)
+	OfflineOnline   bool                  // only groups with copies both on offline media and online (?offline=1)
+	HasMedia        bool                  // some root in the dropdown is offline media
+	Space           []rootSpace           // capacity of the filesystem of each root in the selection
+	PageSizes       []int                 // page sizes to choose from when saving the view as default
+}
+
+// rootSpace is the filesystem capacity behind one scan root, for context next to reclaimable bytes.
+type rootSpace struct {
+	RootPath  string
+	Space     *volume.Space // nil when the root cannot be read (e.g. an unplugged drive)
+	FreeAfter int64         // free bytes after deleting the selection's extra copies (single root only)
+}
+
+// groupFilterFromForm reads the home page's group sort and filters (sort, min_mb, prefix, offline) from a query
+// or form.
+func groupFilterFromForm(v url.Values) (db.DuplicateGroupFilter, int64) {
+	var f db.DuplicateGroupFilter
+	if s := db.GroupSort(v.Get("sort")); s.Valid() {
+		f.Sort = s
+	}
+	minMB, err := strconv.ParseInt(v.Get("min_mb"), 10, 64)
+	if err != nil || minMB < 0 {
+		minMB = 0
+	}
+	f.MinSize = minMB << 20
+	f.PathPrefix = strings.TrimSpace(v.Get("prefix"))
+	f.PrefixOnly = f.PathPrefix != "" && v.Get("prefix_only") == "1"
+	f.OfflineOnline = v.Get("offline") == "1"
+	return f, minMB
+}
+
+// groupFilterQuery encodes the filter as home page query parameters (for redirects back to the same view).
+func groupFilterQuery(f db.DuplicateGroupFilter, minMB int64) string {
+	v := url.Values{}
+	if f.Sort != "" {
+		v.Set("sort", string(f.Sort))
+	}
+	if minMB > 0 {
+		v.Set("min_mb", strconv.FormatInt(minMB, 10))
+	}
+	if f.PathPrefix != "" {
+		v.Set("prefix", f.PathPrefix)
+	}
+	if f.PrefixOnly {
+		v.Set("prefix_only", "1")
+	}
+	if f.OfflineOnline {
+		v.Set("offline", "1")
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "&" + v.Encode()
 }
 
 func (s *Server) handleHome() http.HandlerFunc {
@@ -164,24 +786,43 @@ func (s *Server) handleHome() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		// Build unique roots: latest scan per root_path (scans are newest first).
+		scans = s.visibleScans(ctx, scans)
+		// Build unique roots: latest scan per root_path (scans are newest first). Disabled roots are left out.
+		disabled := s.disabledRoots(ctx)
 		seen := make(map[string]bool)
 		var roots []ScanRootChoice
 		for i := 0; i < len(scans) && len(roots) < maxScansForRoots; i++ {
 			sc := scans[i]
-			if seen[sc.RootPath] {
+			if seen[sc.RootPath] || disabled[sc.RootPath] {
 				continue
 			}
 			seen[sc.RootPath] = true
 			roots = append(roots, ScanRootChoice{RootPath: sc.RootPath, ScanID: sc.ID, CreatedAt: sc.CreatedAt})
 		}
+		// Label roots on offline media so duplicates on unplugged drives say where the copy lives.
+		mediaLabel := s.mediaLabels(ctx)
+		hasMedia := false
+		for i := range roots {
+			roots[i].MediaLabel = mediaLabel[roots[i].RootPath]
+			hasMedia = hasMedia || roots[i].MediaLabel != ""
+		}
 		if len(roots) == 0 {
 			s.renderPage(w, "layout.html", "home-content", HomePageData{Roots: roots})
 			return
 		}
+		// Without query parameters the saved preferences choose the view.
+		prefs := s.preferences(r)
+		pageSize := homePageSize
+		query := r.URL.Query()
+		if prefs != nil {
+			pageSize = prefs.PageSize
+			if len(query) == 0 {
+				query = preferenceQuery(prefs, roots)
+			}
+		}
 		// Selected scan: from ?scan_id= or default first. 0 = "All (latest per folder)".
 		selectedScanID := roots[0].ScanID
-		if idStr := r.URL.Query().Get("scan_id"); idStr != "" {
+		if idStr := query.Get("scan_id"); idStr != "" {
 			if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
 				if id == 0 {
 					selectedScanID = 0
@@ -207,54 +848,96 @@ func (s *Server) handleHome() http.HandlerFunc {
 			}
 		}
 		page := 1
-		if p := r.URL.Query().Get("page"); p != "" {
+		if p := query.Get("page"); p != "" {
 			if pn, err := strconv.Atoi(p); err == nil && pn >= 1 {
 				page = pn
 			}
 		}
+		filter, minMB := groupFilterFromForm(query)
+		filtered := filter.MinSize > 0 || filter.PathPrefix != ""
 		scanIDsForAll := make([]int64, len(roots))
 		for i := range roots {
 			scanIDsForAll[i] = roots[i].ScanID
 		}
+		// Volumes excluded on the Volumes page (e.g. a backup drive) stay selectable on their own but not in All.
+		if included, err := db.FilterScansIncludedInAll(ctx, s.dbForRead(), scanIDsForAll); err != nil {
+			log.Printf("error: home filter excluded volumes: %v", err)
+		} else {
+			scanIDsForAll = included
+		}
+		excludedFromAll := len(roots) - len(scanIDsForAll)
 		var totalGroups int64
+		var savings *db.SavingsProjection
+		var topExtensions []db.ExtensionWaste
 		if selectedScanID == 0 {
-			totalGroups, _ = db.DuplicateGroupsByHashCountAcrossScans(ctx, s.dbForRead(), scanIDsForAll)
+			totalGroups, _ = db.DuplicateGroupsByHashCountAcrossScans(ctx, s.dbForRead(), scanIDsForAll, filter)
+			var err error
+			if savings, err = db.ProjectSavings(ctx, s.dbForRead(), scanIDsForAll); err != nil {
+				log.Printf("error: home savings projection: %v", err)
+			}
+		} else if sum, err := db.ScanSummaryOrRefresh(ctx, s.db, selectedScanID); err != nil {
+			// One scan's statistics come from scan_summaries (stored when its hash phase completed).
+			log.Printf("error: home scan summary %d: %v", selectedScanID, err)
 		} else {
-			totalGroups, _ = db.DuplicateGroupsByHashCount(ctx, s.dbForRead(), selectedScanID)
+			totalGroups, savings, topExtensions = sum.Savings.Groups, &sum.Savings, sum.TopExtensions
+		}
+		if selectedScanID != 0 && filtered {
+			// The summary counts every group; the savings stay those of the whole scan.
+			totalGroups, _ = db.DuplicateGroupsByHashCountAcrossScans(ctx, s.dbForRead(), []int64{selectedScanID}, filter)
 		}
 		totalPages := 1
-		if totalGroups > 0 && homePageSize > 0 {
-			totalPages = int((totalGroups + int64(homePageSize) - 1) / int64(homePageSize))
+		if totalGroups > 0 && pageSize > 0 {
+			totalPages = int((totalGroups + int64(pageSize) - 1) / int64(pageSize))
 		}
 		if page > totalPages {
 			page = totalPages
 		}
-		offset := (page - 1) * homePageSize
+		offset := (page - 1) * pageSize
 		var groups []db.DuplicateGroupByHash
 		if selectedScanID == 0 {
-			groups, _ = db.DuplicateGroupsByHashPaginatedAcrossScans(ctx, s.dbForRead(), scanIDsForAll, homePageSize, offset)
+			groups, _ = s.duplicateGroups(ctx, scanIDsForAll, filter, pageSize, offset)
 		} else {
-			groups, _ = db.DuplicateGroupsByHashPaginated(ctx, s.dbForRead(), selectedScanID, homePageSize, offset)
+			groups, _ = s.duplicateGroups(ctx, []int64{selectedScanID}, filter, pageSize, offset)
+		}
+		hashes := make([]string, len(groups))
+		for i, g := range groups {
+			hashes[i] = g.Hash
+		}
+		keepers, err := db.GroupKeepers(ctx, s.dbForRead(), hashes)
+		if err != nil {
+			log.Printf("error: home group keepers: %v", err)
+		}
+		groupScanIDs := scanIDsForAll
+		if selectedScanID != 0 {
+			groupScanIDs = []int64{selectedScanID}
+		}
+		devices, err := db.HashGroupDevices(ctx, s.dbForRead(), groupScanIDs, hashes)
+		if err != nil {
+			log.Printf("error: home group devices: %v", err)
+		}
+		hosts, err := db.HashGroupHosts(ctx, s.dbForRead(), groupScanIDs, hashes)
+		if err != nil {
+			log.Printf("error: home group hosts: %v", err)
+		}
+		media, err := db.HashGroupMedia(ctx, s.dbForRead(), groupScanIDs, hashes)
+		if err != nil {
+			log.Printf("error: home group media: %v", err)
 		}
-		// Attach file paths to each group (limit per group so home page stays fast)
+		// Paths are loaded when a group is expanded, so the page stays fast for groups of any size.
 		groupsWithPaths := make([]GroupWithPaths, 0, len(groups))
 		for _, g := range groups {
-			var files []db.File
-			if selectedScanID == 0 {
-				files, _ = db.FilesInHashGroupLimitAcrossScans(ctx, s.dbForRead(), scanIDsForAll, g.Hash, homeMaxPathsPerGroup)
-			} else {
-				files, _ = db.FilesInHashGroupLimit(ctx, s.dbForRead(), selectedScanID, g.Hash, homeMaxPathsPerGroup)
-			}
-			paths := make([]string, len(files))
-			for i, f := range files {
-				paths[i] = f.Path
-			}
 			perFile := int64(0)
 			if g.Count > 0 {
 				perFile = g.Size / g.Count
 			}
-			truncated := g.Count > int64(len(paths))
-			groupsWithPaths = append(groupsWithPaths, GroupWithPaths{Hash: g.Hash, Count: g.Count, Size: g.Size, PerFileSize: perFile, Paths: paths, PathsTruncated: truncated})
+			_, pinned := keepers[g.Hash]
+			prefix := ""
+			if filter.PrefixOnly {
+				prefix = filter.PathPrefix
+			}
+			groupsWithPaths = append(groupsWithPaths, GroupWithPaths{Hash: g.Hash, Count: g.Count, Size: g.Size, PerFileSize: perFile,
+				PathsURL: groupPathsURL(selectedScanID, g.Hash, prefix, 0), KeeperPinned: pinned, Devices: devices[g.Hash],
+				Hosts: hostLabels(hosts[g.Hash]), OfflineMedia: offlineCopies(media[g.Hash])})
 		}
 		prevPage, nextPage := 0, 0
 		if page > 1 {
@@ -263,67 +946,556 @@ func (s *Server) handleHome() http.HandlerFunc {
 		if page < totalPages {
 			nextPage = page + 1
 		}
+		var space []rootSpace
+		for _, rt := range roots {
+			if selectedScanID != 0 && rt.ScanID != selectedScanID {
+				continue
+			}
+			if selectedScanID == 0 && !slices.Contains(scanIDsForAll, rt.ScanID) {
+				continue
+			}
+			rs := rootSpace{RootPath: rt.RootPath}
+			if sp, err := volume.DiskSpace(rt.RootPath); err == nil {
+				rs.Space = sp
+				if selectedScanID != 0 && savings != nil {
+					rs.FreeAfter = sp.Avail + savings.DeleteSavings
+				}
+			}
+			space = append(space, rs)
+		}
+		acknowledged, err := db.CountAcknowledgedGroups(ctx, s.dbForRead())
+		if err != nil {
+			log.Printf("error: home count acknowledged groups: %v", err)
+		}
 		data := HomePageData{
-			Roots:        roots,
-			SelectedScan: selectedScanID,
-			SelectedRoot: selectedRoot,
-			Groups:       groupsWithPaths,
-			Page:         page,
-			PageSize:     homePageSize,
-			TotalGroups:  totalGroups,
-			TotalPages:   totalPages,
-			PrevPage:     prevPage,
-			NextPage:     nextPage,
+			Acknowledged:    acknowledged,
+			ExcludedFromAll: excludedFromAll,
+			Roots:           roots,
+			SelectedScan:    selectedScanID,
+			SelectedRoot:    selectedRoot,
+			Groups:          groupsWithPaths,
+			Page:            page,
+			PageSize:        pageSize,
+			TotalGroups:     totalGroups,
+			TotalPages:      totalPages,
+			PrevPage:        prevPage,
+			NextPage:        nextPage,
+			Savings:         savings,
+			TopExtensions:   topExtensions,
+			Sort:            string(filter.Sort),
+			MinMB:           minMB,
+			PathPrefix:      filter.PathPrefix,
+			PrefixOnly:      filter.PrefixOnly,
+			OfflineOnline:   filter.OfflineOnline,
+			HasMedia:        hasMedia,
+			Space:           space,
+			PageSizes:       db.PageSizes,
 		}
 		s.renderPage(w, "layout.html", "home-content", data)
 	}
 }
 
-type scansPageData struct {
-	Scans                  []db.Scan
-	Roots                  []db.ScanRoot
-	IncompleteScanIDByRoot map[string]int64 // root path -> latest incomplete scan id (for Continue per folder)
+// browserCookie holds a random id naming a browser's preferences when ditto has no users.
+const browserCookie = "ditto_browser"
+
+// preferencesOwner returns whose preferences the request reads and writes: the signed-in user's, else the
+// browser's. With create, a browser without an id gets one in a cookie; without, ok is false.
+func (s *Server) preferencesOwner(w http.ResponseWriter, r *http.Request, create bool) (owner db.PreferencesOwner, ok bool) {
+	if u := userFrom(r.Context()); u != nil {
+		return db.PreferencesOwner{UserID: &u.ID}, true
+	}
+	if c, err := r.Cookie(browserCookie); err == nil && c.Value != "" {
+		return db.PreferencesOwner{BrowserID: c.Value}, true
+	}
+	if !create {
+		return owner, false
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return owner, false
+	}
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{Name: browserCookie, Value: id, Path: "/", MaxAge: 5 * 365 * 24 * 3600,
+		HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+	return db.PreferencesOwner{BrowserID: id}, true
 }
 
-func (s *Server) handleScans() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		scans, _ := db.ListScans(ctx, s.dbForRead())
-		roots, _ := db.ListScanRoots(ctx, s.dbForRead())
-		byRoot := make(map[string]int64)
-		for _, root := range roots {
-			id, _ := db.GetLatestIncompleteScanForFolder(ctx, s.dbForRead(), root.ID)
-			if id > 0 {
-				byRoot[root.Path] = id
+// preferences returns the request's saved preferences, nil when there are none (or on error).
+func (s *Server) preferences(r *http.Request) *db.Preferences {
+	owner, ok := s.preferencesOwner(nil, r, false)
+	if !ok {
+		return nil
+	}
+	p, err := db.GetPreferences(r.Context(), s.dbForRead(), owner)
+	if err != nil {
+		log.Printf("error: load preferences: %v", err)
+	}
+	return p
+}
+
+// preferenceQuery turns saved preferences into home page query parameters. A saved folder that is no longer
+// listed leaves the default (the newest scanned) in place.
+func preferenceQuery(p *db.Preferences, roots []ScanRootChoice) url.Values {
+	v := url.Values{}
+	if p.RootPath != nil {
+		if *p.RootPath == "" {
+			v.Set("scan_id", "0")
+		}
+		for _, rt := range roots {
+			if rt.RootPath == *p.RootPath {
+				v.Set("scan_id", strconv.FormatInt(rt.ScanID, 10))
 			}
 		}
-		s.renderPage(w, "layout.html", "scans-content", scansPageData{Scans: scans, Roots: roots, IncompleteScanIDByRoot: byRoot})
 	}
+	v.Set("sort", string(p.Sort))
+	v.Set("min_mb", strconv.FormatInt(p.MinMB, 10))
+	v.Set("prefix", p.PathPrefix)
+	if p.PrefixOnly {
+		v.Set("prefix_only", "1")
+	}
+	return v
 }
 
-func (s *Server) handleScansStart() http.HandlerFunc {
+// handlePreferencesSave saves the home page view (form: scan_id, the home filters and page_size) as what "/"
+// shows by default, for the signed-in user or this browser, and shows the home page.
+func (s *Server) handlePreferencesSave() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		path := strings.TrimSpace(r.FormValue("root_path"))
-		var folderID int64
-		if path == "" {
-			rootIDStr := r.FormValue("root_id")
-			if rootIDStr != "" {
-				var err error
-				folderID, err = strconv.ParseInt(rootIDStr, 10, 64)
-				if err != nil {
-					http.Error(w, "invalid root_id", http.StatusBadRequest)
+		ctx := r.Context()
+		filter, minMB := groupFilterFromForm(r.Form)
+		pageSize, _ := strconv.Atoi(r.FormValue("page_size"))
+		p := db.Preferences{PageSize: pageSize, Sort: filter.Sort, MinMB: minMB, PathPrefix: filter.PathPrefix, PrefixOnly: filter.PrefixOnly}
+		if v := r.FormValue("scan_id"); v != "" {
+			scanID, err := parseScanID(v)
+			if err != nil {
+				http.Error(w, "invalid scan_id", http.StatusBadRequest)
+				return
+			}
+			root := ""
+			if scanID != 0 {
+				sc, err := db.GetScan(ctx, s.dbForRead(), scanID)
+				if err != nil || !s.scanVisible(ctx, scanID) {
+					http.Error(w, "scan not found", http.StatusNotFound)
 					return
 				}
-				root, err := db.GetScanRoot(r.Context(), s.db, folderID)
-				if err != nil {
+				root = sc.RootPath
+			}
+			p.RootPath = &root
+		}
+		owner, ok := s.preferencesOwner(w, r, true)
+		if !ok {
+			http.Error(w, "cannot identify this browser", http.StatusInternalServerError)
+			return
+		}
+		if err := db.SavePreferences(ctx, s.db, owner, p); err != nil {
+			if errors.Is(err, db.ErrInvalidPreferences) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("error: save preferences: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// scanIDsForAll returns the scans behind "All (latest per folder)" on the home page: the latest scan of each
+// root, without those on volumes excluded from All.
+func (s *Server) scanIDsForAll(ctx context.Context) ([]int64, error) {
+	scans, err := db.ListScansRecent(ctx, s.dbForRead(), homeListScansLimit)
+	if err != nil {
+		return nil, err
+	}
+	scans = s.visibleScans(ctx, scans)
+	seen := make(map[string]bool)
+	var ids []int64
+	for i := 0; i < len(scans) && len(ids) < maxScansForRoots; i++ {
+		if seen[scans[i].RootPath] {
+			continue
+		}
+		seen[scans[i].RootPath] = true
+		ids = append(ids, scans[i].ID)
+	}
+	return db.FilterScansIncludedInAll(ctx, s.dbForRead(), ids)
+}
+
+// mediaLabels maps the path of each scan root on offline media to its label.
+func (s *Server) mediaLabels(ctx context.Context) map[string]string {
+	folders, _ := db.ListFolders(ctx, s.dbForRead())
+	labels := make(map[string]string)
+	for _, f := range folders {
+		if f.OfflineMedia() {
+			labels[f.Path] = f.MediaLabel
+		}
+	}
+	return labels
+}
+
+// disabledRoots returns the paths of the disabled scan roots.
+func (s *Server) disabledRoots(ctx context.Context) map[string]bool {
+	folders, _ := db.ListFolders(ctx, s.dbForRead())
+	disabled := make(map[string]bool)
+	for _, f := range folders {
+		if f.Disabled() {
+			disabled[f.Path] = true
+		}
+	}
+	return disabled
+}
+
+// groupPathsURL is the fragment URL for the paths of a home-page group from offset on (scan 0: All). With a
+// prefix, only the copies under it are listed.
+func groupPathsURL(scanID int64, hash, prefix string, offset int) string {
+	v := url.Values{}
+	if prefix != "" {
+		v.Set("prefix", prefix)
+	}
+	if offset > 0 {
+		v.Set("offset", strconv.Itoa(offset))
+	}
+	u := fmt.Sprintf("/scans/%d/duplicates/hash/%s/paths", scanID, url.PathEscape(hash))
+	if len(v) > 0 {
+		u += "?" + v.Encode()
+	}
+	return u
+}
+
+// groupPageURL is the URL of a page of the duplicate group page, with the listing's prefix (page 1 and an
+// empty prefix are left out).
+func groupPageURL(scanID int64, hash, prefix string, page int) string {
+	v := url.Values{}
+	if prefix != "" {
+		v.Set("prefix", prefix)
+	}
+	if page > 1 {
+		v.Set("page", strconv.Itoa(page))
+	}
+	u := fmt.Sprintf("/scans/%d/duplicates/hash/%s", scanID, url.PathEscape(hash))
+	if len(v) > 0 {
+		u += "?" + v.Encode()
+	}
+	return u
+}
+
+// groupPath is one file of a group in the home page's paths fragment.
+type groupPath struct {
+	Path   string
+	Media  string // offline-media label ("" on a regular folder)
+	Keeper bool   // the group's pinned keeper
+}
+
+type groupPathsData struct {
+	Files []groupPath
+	More  string // URL of the next page; "" after the last
+}
+
+// handleGroupPaths renders one page of a duplicate group's paths for the home page (query: offset, prefix).
+// Each page ends with a button loading the next, so a group of any size expands without loading it whole.
+func (s *Server) handleGroupPaths() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hash := r.PathValue("hash")
+		prefix := r.URL.Query().Get("prefix")
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset < 0 {
+			offset = 0
+		}
+		scanIDs := []int64{scanID}
+		if scanID == 0 {
+			if scanIDs, err = s.scanIDsForAll(ctx); err != nil {
+				log.Printf("error: group paths scans: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		files, err := db.FilesInHashGroupPage(ctx, s.dbForRead(), scanIDs, hash, prefix, homeGroupPathsPage+1, offset)
+		if err != nil {
+			log.Printf("error: group %s paths: %v", hash, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var data groupPathsData
+		if len(files) > homeGroupPathsPage {
+			files = files[:homeGroupPathsPage]
+			data.More = groupPathsURL(scanID, hash, prefix, offset+homeGroupPathsPage)
+		}
+		keepers, err := db.GroupKeepers(ctx, s.dbForRead(), []string{hash})
+		if err != nil {
+			log.Printf("error: group %s keeper: %v", hash, err)
+		}
+		labels := s.mediaLabels(ctx)
+		for _, f := range files {
+			data.Files = append(data.Files, groupPath{Path: f.Path, Media: mediaForPath(labels, f.Path), Keeper: keepers[hash] == f.ID})
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		var buf bytes.Buffer
+		if err := s.tmpl.ExecuteTemplate(&buf, "group-paths-fragment", data); err != nil {
+			log.Printf("error: group paths fragment: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// handleDuplicatesBulk applies one action to many duplicate groups of the home page selection and shows the
+// page again. Form: scan_id, page and the home filters (the selection shown), scope (selected: the checked hash values; page:
+// every page_hash; scan: every group of the selection), action (acknowledge with an optional note; keeper
+// with a rule, which never replaces a pinned keeper; unpin).
+func (s *Server) handleDuplicatesBulk() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		scanID, err := parseScanID(r.FormValue("scan_id"))
+		if err != nil {
+			http.Error(w, "invalid scan_id", http.StatusBadRequest)
+			return
+		}
+		if scanID != 0 && !s.scanVisible(ctx, scanID) {
+			http.NotFound(w, r)
+			return
+		}
+		var scanIDs []int64
+		if scanID == 0 {
+			if scanIDs, err = s.scanIDsForAll(ctx); err != nil {
+				log.Printf("error: bulk action list scans: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			if _, err := db.GetScan(ctx, s.dbForRead(), scanID); err != nil {
+				http.Error(w, "scan not found", http.StatusNotFound)
+				return
+			}
+			scanIDs = []int64{scanID}
+		}
+		filter, minMB := groupFilterFromForm(r.Form)
+		var hashes []string
+		switch r.FormValue("scope") {
+		case "selected":
+			hashes = r.Form["hash"]
+		case "page":
+			hashes = r.Form["page_hash"]
+		case "scan":
+			groups, err := db.DuplicateGroupsByHashPaginatedAcrossScans(ctx, s.dbForRead(), scanIDs, filter, 0, 0)
+			if err != nil {
+				log.Printf("error: bulk action list groups: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, g := range groups {
+				hashes = append(hashes, g.Hash)
+			}
+		default:
+			http.Error(w, "scope must be selected, page or scan", http.StatusBadRequest)
+			return
+		}
+		action := r.FormValue("action")
+		switch action {
+		case "acknowledge":
+			err = db.AcknowledgeGroups(ctx, s.db, hashes, strings.TrimSpace(r.FormValue("note")))
+		case "keeper":
+			_, err = db.ApplyKeeperRule(ctx, s.db, scanIDs, hashes, db.KeeperRule(r.FormValue("rule")))
+			if errors.Is(err, db.ErrUnknownKeeperRule) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case "unpin":
+			err = db.ClearGroupKeepers(ctx, s.db, hashes)
+		default:
+			http.Error(w, "action must be acknowledge, keeper or unpin", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("error: bulk %s on %d groups: %v", action, len(hashes), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page := 1
+		if pn, err := strconv.Atoi(r.FormValue("page")); err == nil && pn >= 1 {
+			page = pn
+		}
+		http.Redirect(w, r, "/?scan_id="+strconv.FormatInt(scanID, 10)+"&page="+strconv.Itoa(page)+groupFilterQuery(filter, minMB), http.StatusSeeOther)
+	}
+}
+
+type scansPageData struct {
+	Scans                  []db.Scan
+	Roots                  []db.ScanRoot
+	IncompleteScanIDByRoot map[string]int64 // root path -> latest incomplete scan id (for Continue per folder)
+	Unplugged              map[string]bool  // offline-media root paths that are not currently connected
+	User                   *db.User         // signed-in user (nil on a single-user instance)
+	Owners                 map[int64]string // folder id -> owner name (shared folders are absent)
+	Overlaps               map[int64]string // folder id -> the roots it contains or lies inside, for a warning
+}
+
+func (s *Server) handleScans() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		scans, _ := db.ListScans(ctx, s.dbForRead())
+		roots, _ := db.ListScanRoots(ctx, s.dbForRead())
+		scans, roots = s.visibleScans(ctx, scans), s.visibleRoots(ctx, roots)
+		byRoot := make(map[string]int64)
+		unplugged := make(map[string]bool)
+		for _, root := range roots {
+			id, _ := db.GetLatestIncompleteScanForFolder(ctx, s.dbForRead(), root.ID)
+			if id > 0 {
+				byRoot[root.Path] = id
+			}
+			if root.MediaLabel != "" && !offline.Online(root.Path) {
+				unplugged[root.Path] = true
+			}
+		}
+		data := scansPageData{Scans: scans, Roots: roots, IncompleteScanIDByRoot: byRoot, Unplugged: unplugged, User: userFrom(ctx), Overlaps: s.rootOverlaps(roots)}
+		if data.User != nil {
+			data.Owners, _ = db.FolderOwnerNames(ctx, s.dbForRead())
+		}
+		s.renderPage(w, "layout.html", "scans-content", data)
+	}
+}
+
+// rootOverlaps describes, for each root nested in or holding another one, the roots it overlaps with (host
+// paths), e.g. "inside /data" or "contains /data/photos; /data/music".
+func (s *Server) rootOverlaps(roots []db.ScanRoot) map[int64]string {
+	folders := make([]db.Folder, len(roots))
+	for i, r := range roots {
+		folders[i] = db.Folder{ID: r.ID, Path: r.Path, Imported: r.Imported}
+	}
+	contains, inside := make(map[int64][]string), make(map[int64][]string)
+	for _, o := range db.FolderOverlaps(folders) {
+		contains[o.OuterID] = append(contains[o.OuterID], s.hostPath(o.InnerPath))
+		inside[o.InnerID] = append(inside[o.InnerID], s.hostPath(o.OuterPath))
+	}
+	out := make(map[int64]string)
+	for id, paths := range inside {
+		out[id] = "inside " + strings.Join(paths, "; ")
+	}
+	for id, paths := range contains {
+		if out[id] != "" {
+			out[id] += ", "
+		}
+		out[id] += "contains " + strings.Join(paths, "; ")
+	}
+	return out
+}
+
+// startScanResponse is the reply to a JSON POST /scans/start.
+type startScanResponse struct {
+	ScanID    int64  `json:"scan_id"`
+	StatusURL string `json:"status_url"` // GET it for the scan's progress (apiScan)
+}
+
+// startScanRequest is the JSON body of POST /scans/start. RootPath or FolderID picks the folder; the other
+// fields override the folder's settings for this run only.
+type startScanRequest struct {
+	RootPath        string   `json:"root_path"`
+	FolderID        int64    `json:"folder_id"`
+	ExcludePatterns []string `json:"exclude_patterns"`
+	IncludePatterns []string `json:"include_patterns"` // scan only matching paths, e.g. ["*.jpg", "*.mp4"]
+	Workers         int      `json:"workers"`
+	MaxReadMBps     float64  `json:"max_read_mbps"`
+	HashAlgorithm   string   `json:"hash_algorithm"` // only "sha256" (the default) is supported
+	Verify          bool     `json:"verify"`
+	Plan            bool     `json:"plan"`
+	Catalog         bool     `json:"catalog"` // catalog a removable drive (see offline.Catalog)
+}
+
+// overrides returns the per-run settings of the request, or nil when it has none.
+func (req *startScanRequest) overrides() (*db.ScanOverrides, error) {
+	if req.Workers < 0 || req.Workers > 64 {
+		return nil, errors.New("workers must be between 0 and 64 (0 = default)")
+	}
+	if req.MaxReadMBps < 0 {
+		return nil, errors.New("invalid max_read_mbps")
+	}
+	if a := strings.ToLower(req.HashAlgorithm); a != "" && a != "sha256" {
+		return nil, fmt.Errorf("unsupported hash_algorithm %q (only sha256)", req.HashAlgorithm)
+	}
+	var patterns, includes []string
+	for _, p := range req.ExcludePatterns {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	for _, raw := range req.IncludePatterns {
+		p, err := db.NormalizeExcludePattern(raw)
+		if err != nil || strings.HasPrefix(p, "!") {
+			return nil, fmt.Errorf("invalid include pattern %q", raw)
+		}
+		includes = append(includes, p)
+	}
+	if len(patterns) == 0 && len(includes) == 0 && req.Workers == 0 && req.MaxReadMBps == 0 {
+		return nil, nil
+	}
+	return &db.ScanOverrides{
+		ExcludePatterns:    patterns,
+		IncludePatterns:    includes,
+		Workers:            req.Workers,
+		MaxReadBytesPerSec: int64(req.MaxReadMBps * 1024 * 1024),
+	}, nil
+}
+
+// handleScansStart creates and queues a scan. The scans page posts a form and is redirected to the scan;
+// scripts post a JSON startScanRequest and get {"scan_id": ..., "status_url": ...} back with 202 Accepted.
+func (s *Server) handleScansStart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		var req startScanRequest
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		asJSON := mediaType == "application/json"
+		if asJSON {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.RootPath = r.FormValue("root_path")
+			if v := r.FormValue("root_id"); v != "" {
+				id, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					http.Error(w, "invalid root_id", http.StatusBadRequest)
+					return
+				}
+				req.FolderID = id
+			}
+			req.Verify = r.FormValue("verify") != ""
+			req.Plan = r.FormValue("plan") != ""
+			req.Catalog = r.FormValue("catalog") != ""
+		}
+		overrides, err := req.overrides()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		path := s.containerPath(strings.TrimSpace(req.RootPath))
+		var folderID int64
+		if path == "" {
+			if req.FolderID != 0 {
+				folderID = req.FolderID
+				root, err := db.GetScanRoot(r.Context(), s.db, folderID)
+				if err != nil || !s.folderVisible(r.Context(), folderID) {
 					http.Error(w, "root not found", http.StatusNotFound)
 					return
 				}
@@ -331,7 +1503,7 @@ func (s *Server) handleScansStart() http.HandlerFunc {
 			}
 		}
 		if path == "" {
-			http.Error(w, "root_path or root_id required", http.StatusBadRequest)
+			http.Error(w, "root_path or root_id (folder_id in JSON) required", http.StatusBadRequest)
 			return
 		}
 		if folderID == 0 {
@@ -342,6 +1514,42 @@ func (s *Server) handleScansStart() http.HandlerFunc {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			if !s.folderVisible(r.Context(), folderID) {
+				http.Error(w, "root not found", http.StatusNotFound)
+				return
+			}
+			s.claimFolder(r.Context(), folderID)
+		}
+		if folder, err := db.GetFolder(r.Context(), s.db, folderID); err == nil && folder.Imported {
+			http.Error(w, "imported manifests cannot be scanned; import a new manifest instead", http.StatusConflict)
+			return
+		}
+		if folder, err := db.GetFolder(r.Context(), s.db, folderID); err == nil && folder.Disabled() {
+			http.Error(w, "scan root "+s.hostPath(folder.Path)+" is disabled; enable it on the Scans page to scan it", http.StatusConflict)
+			return
+		}
+		// Offline media that is unplugged keeps its last scan; refuse before creating an empty scan that would replace it.
+		if folder, err := db.GetFolder(r.Context(), s.db, folderID); err == nil && folder.OfflineMedia() &&
+			!offline.Online(folder.Path) && (folder.MediaImage == "" || s.cfg.MountHelper() == "") {
+			http.Error(w, fmt.Sprintf("offline media %q is not connected at %s", folder.MediaLabel, folder.Path), http.StatusConflict)
+			return
+		}
+		// A catalog names its drive, and turns a root that is not yet offline media into one.
+		var catalogLabel, catalogUUID string
+		if req.Catalog {
+			folder, err := db.GetFolder(r.Context(), s.db, folderID)
+			if err == nil {
+				catalogLabel, catalogUUID, err = offline.Catalog(r.Context(), s.db, folder)
+			}
+			if errors.Is(err, offline.ErrNotMounted) {
+				http.Error(w, "nothing to catalog: "+err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				log.Printf("error: catalog folder %d: %v", folderID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 		}
 		scanRow, err := db.CreateScan(r.Context(), s.db, folderID)
 		if err != nil {
@@ -350,270 +1558,3438 @@ func (s *Server) handleScansStart() http.HandlerFunc {
 			return
 		}
 		scanID := scanRow.ID
-		select {
-		case s.scanQueue <- scanID:
-			// queued
-		default:
-			http.Error(w, "scan queue is full, try again later", http.StatusServiceUnavailable)
+		if req.Catalog {
+			if err := db.SetScanCatalog(r.Context(), s.db, scanID, catalogLabel, catalogUUID); err != nil {
+				log.Printf("error: make scan %d a catalog: %v", scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		// "Estimate first": a pause requested up front lets the walk run and stops the hash phase before it
+		// starts, so the scan page can show the hash plan; resuming or skipping continues from there.
+		if overrides != nil {
+			if err := db.SetScanOverrides(r.Context(), s.db, scanID, overrides); err != nil {
+				log.Printf("error: store overrides of scan %d: %v", scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.Verify {
+			if err := db.SetScanVerify(r.Context(), s.db, scanID); err != nil {
+				log.Printf("error: make scan %d a verification scan: %v", scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.Plan {
+			if _, err := db.RequestScanHashPause(r.Context(), s.db, scanID); err != nil {
+				log.Printf("error: pause scan %d for planning: %v", scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := s.enqueueScan(r.Context(), scanID); err != nil {
+			log.Printf("error: queue scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if asJSON {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(startScanResponse{ScanID: scanID, StatusURL: fmt.Sprintf("/api/v1/scans/%d", scanID)})
+			return
+		}
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+	}
+}
+
+func (s *Server) handleScanContinue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		// Already fully complete: just go to progress page
+		if sn.CompletedAt != nil && sn.HashCompletedAt != nil {
+			http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+			return
+		}
+		// Continuing a paused scan resumes it.
+		if err := db.ClearScanHashPause(r.Context(), s.db, scanID); err != nil {
+			log.Printf("error: clear pause for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Return any files stuck in 'hashing' (from a cancelled run) to the queue so they get retried.
+		if err := db.ResetHashStatusHashingToPending(r.Context(), s.db, scanID); err != nil {
+			log.Printf("error: reset hash status for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.enqueueScan(r.Context(), scanID); err != nil {
+			log.Printf("error: queue scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+	}
+}
+
+// handleScanPause requests a pause of the scan's hash phase. Workers finish the file they are hashing;
+// remaining files stay pending until the scan is resumed (POST /scans/{id}/resume).
+func (s *Server) handleScanPause() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.GetScan(r.Context(), s.dbForRead(), scanID); err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		if _, err := db.RequestScanHashPause(r.Context(), s.db, scanID); err != nil {
+			log.Printf("error: pause scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+	}
+}
+
+// handleScanSkipHash completes a scan that is waiting on its hash plan without hashing: known hashes are kept
+// and pending files stay pending for a later scan.
+func (s *Server) handleScanSkipHash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		if !awaitingHashPlan(sn) {
+			http.Error(w, "scan is not waiting for a hashing decision", http.StatusConflict)
+			return
+		}
+		if err := hash.SkipHashPhase(r.Context(), s.db, scanID); err != nil {
+			log.Printf("error: skip hash phase for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+	}
+}
+
+// awaitingHashPlan reports whether the scan finished its walk and was paused before its hash phase started.
+func awaitingHashPlan(sn *db.Scan) bool {
+	return sn.CompletedAt != nil && sn.HashPausedAt != nil && sn.HashStartedAt == nil && sn.HashCompletedAt == nil
+}
+
+// apiScan is a scan's status as the JSON API reports it.
+type apiScan struct {
+	ID              int64      `json:"id"`
+	FolderID        int64      `json:"folder_id"`
+	RootPath        string     `json:"root_path"`
+	Phase           string     `json:"phase"` // walking, awaiting_plan, hashing, paused, verifying or done
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`      // end of the walk
+	HashCompletedAt *time.Time `json:"hash_completed_at,omitempty"` // end of the hash phase
+	Files           *int64     `json:"files,omitempty"`
+	HashedFiles     *int64     `json:"hashed_files,omitempty"`
+	HashErrors      *int64     `json:"hash_errors,omitempty"`
+	HashTotal       int64      `json:"hash_total,omitempty"` // while hashing: files this run set out to hash
+	HashDone        int64      `json:"hash_done,omitempty"`  // while hashing: files processed so far
+}
+
+// scanPhase names where the scan is for the JSON API.
+func scanPhase(sn *db.Scan) string {
+	switch {
+	case sn.CompletedAt == nil:
+		return "walking"
+	case awaitingHashPlan(sn):
+		return "awaiting_plan"
+	case sn.HashCompletedAt == nil && sn.HashPausedAt != nil:
+		return "paused"
+	case sn.HashCompletedAt == nil:
+		return "hashing"
+	case sn.Verify && sn.VerifyCompletedAt == nil:
+		return "verifying"
+	}
+	return "done"
+}
+
+// handleAPIScan reports a scan's phase and counts as JSON, for scripts polling a scan they started.
+func (s *Server) handleAPIScan() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("error: get scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out := apiScan{
+			ID: sn.ID, FolderID: sn.FolderID, RootPath: sn.RootPath, Phase: scanPhase(sn),
+			StartedAt: sn.CreatedAt, CompletedAt: sn.CompletedAt, HashCompletedAt: sn.HashCompletedAt,
+			Files: sn.FileCount, HashedFiles: sn.HashedFileCount, HashErrors: sn.HashErrorCount,
+		}
+		if out.Phase == "hashing" {
+			if p, err := db.GetScanHashProgress(r.Context(), s.dbForRead(), scanID); err == nil && p != nil {
+				out.HashTotal, out.HashDone = p.Total, p.Done
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	}
+}
+
+// scanStatusData is the scan status fragment's data; Plan is set while the scan awaits a hashing decision.
+type scanStatusData struct {
+	*db.Scan
+	Plan         *db.HashPlan
+	Progress     *db.HashProgress // set while the hash phase runs
+	HardlinkOnly int64            // files not hashed because their size group is only hardlinks to one inode
+	Symlinks     int64            // symlinks recorded by the scan (folder in "record" mode)
+	SlowDirs     int64            // directories that were slow, retried or unreachable (network shares)
+	FailedDirs   int64            // of SlowDirs, those still unreachable after the last retry
+	Bitrot       int64            // verification scans: files whose content changed without a size or mtime change
+	Errors       int64            // walk, hash and verify errors recorded for the scan
+	HashErrors   int64            // of Errors, those of the hash phase
+	IO           *db.ScanIOStats  // where the walk and hash phase spent their time, once the walk completed
+	Excludes     []db.ExcludeHit  // paths each exclude pattern decided during the walk, in pattern order
+}
+
+// UnusedExcludes counts the exclude patterns that matched nothing during the walk.
+func (d scanStatusData) UnusedExcludes() int {
+	n := 0
+	for _, h := range d.Excludes {
+		if h.Paths == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) handleScanProgress() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		if idStr == "" {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		scanID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		s.renderPage(w, "layout.html", "scan-progress-content", sn)
+	}
+}
+
+func (s *Server) handleScanStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		if idStr == "" {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		scanID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("<p>Scan not found.</p>"))
+			return
+		}
+		data := scanStatusData{Scan: sn}
+		if awaitingHashPlan(sn) {
+			plan, err := db.PlanHashPhase(r.Context(), s.dbForRead(), scanID)
+			if err != nil {
+				log.Printf("error: hash plan for scan %d: %v", scanID, err)
+			}
+			data.Plan = plan
+		}
+		if sn.HashStartedAt != nil && sn.HashCompletedAt == nil && sn.HashPausedAt == nil {
+			p, err := db.GetScanHashProgress(r.Context(), s.dbForRead(), scanID)
+			if err != nil {
+				log.Printf("error: hash progress for scan %d: %v", scanID, err)
+			}
+			data.Progress = p
+		}
+		if n, hashErrs, err := db.CountScanErrors(r.Context(), s.dbForRead(), scanID); err == nil {
+			data.Errors, data.HashErrors = n, hashErrs
+		}
+		if sn.CompletedAt != nil {
+			if st, err := db.GetScanIOStats(r.Context(), s.dbForRead(), scanID); err == nil {
+				data.IO = st
+			}
+			if hits, err := db.ScanExcludeHits(r.Context(), s.dbForRead(), scanID); err == nil {
+				data.Excludes = hits
+			}
+		}
+		if sn.VerifyCompletedAt != nil {
+			if n, err := db.CountBitrotFindings(r.Context(), s.dbForRead(), scanID); err == nil {
+				data.Bitrot = n
+			}
+		}
+		if sn.HashCompletedAt != nil {
+			if n, err := db.CountHardlinkOnlyFiles(r.Context(), s.dbForRead(), scanID); err == nil {
+				data.HardlinkOnly = n
+			}
+			if n, err := db.CountScanSymlinks(r.Context(), s.dbForRead(), scanID); err == nil {
+				data.Symlinks = n
+			}
+			if n, failed, err := db.CountScanSlowDirs(r.Context(), s.dbForRead(), scanID); err == nil {
+				data.SlowDirs, data.FailedDirs = n, failed
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		var buf bytes.Buffer
+		if err := s.tmpl.ExecuteTemplate(&buf, "scan-status-fragment", data); err != nil {
+			log.Printf("error: scan status fragment: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+type duplicatesPageData struct {
+	ScanID  int64
+	ByHash  []db.DuplicateGroupByHash
+	ByInode []db.DuplicateGroupByInode
+}
+
+type hashGroupData struct {
+	ScanID           int64
+	Hash             string
+	Files            []db.File
+	RootPathByScanID map[int64]string          // when ScanID is 0 (All), root path per scan for display
+	VerifiedAt       map[int64]time.Time       // file id -> last successful byte-by-byte verification
+	Evidence         map[int64]db.HashEvidence // file id -> how its hash was obtained ("Why is this a duplicate?")
+	Sightings        map[int64]db.FileSighting // file id -> first and last scan listing it
+	Extents          map[int64]db.FileExtents  // file id -> disk usage, for copies that are sparse or share extents
+	Photo            *db.ImageMetadata         // dimensions and EXIF of the content, when the metadata phase read it
+	Verify           *hash.VerifyResult        // set right after a verification run
+	Rehash           *hash.RehashResult        // set right after re-hashing copies
+	ToolLinks        map[int64][]toolLink      // file id -> configured external tool links (DITTO_EXTERNAL_TOOLS)
+	Acknowledged     *db.AcknowledgedGroup     // set when the group is marked intentional
+	Keeper           int64                     // pinned keeper file id, 0 if none
+	Survivor         int64                     // file kept when the group is resolved: the keeper, else the first file
+	PathPrefix       string                    // when set, only the files under this directory are listed (?prefix=)
+	Link             *linkResult               // set after linking the group's copies to Survivor
+	Options          map[int64]memberOption    // file id -> how the copy can be resolved against Survivor
+	Devices          int                       // distinct devices in the group (a file of unknown device counts as its own)
+	Hosts            []string                  // machines the group's files are on (see hostLabels)
+	Total            int64                     // files in the group (under PathPrefix); Files holds one page of them
+	Page             int                       // 1-based
+	TotalPages       int
+	PrevPage         int       // 0 if no prev
+	NextPage         int       // 0 if no next
+	Rank             int       // position of the first file on the page (1-based)
+	SameSize         []db.File // files of the same size with other content, for small (text-sized) groups
+}
+
+// sameSizeLimit caps the same-size, different-content files listed on a group page.
+const sameSizeLimit = 10
+
+// groupPageSize is how many files the duplicate group page lists at a time.
+const groupPageSize = 200
+
+// setPage sets the paging fields for page (clamped to the pages of total files) and returns its offset.
+func (d *hashGroupData) setPage(page int, total int64) int {
+	d.Total = total
+	d.TotalPages = max(int((total+groupPageSize-1)/groupPageSize), 1)
+	d.Page = min(max(page, 1), d.TotalPages)
+	d.PrevPage, d.NextPage = 0, 0
+	if d.Page > 1 {
+		d.PrevPage = d.Page - 1
+	}
+	if d.Page < d.TotalPages {
+		d.NextPage = d.Page + 1
+	}
+	offset := (d.Page - 1) * groupPageSize
+	d.Rank = offset + 1
+	return offset
+}
+
+// LastRank is the position of the last file on the page (1-based).
+func (d *hashGroupData) LastRank() int {
+	return d.Rank + len(d.Files) - 1
+}
+
+// firstPage trims a fully loaded group to its first page for display.
+func (d *hashGroupData) firstPage() {
+	d.setPage(1, int64(len(d.Files)))
+	if len(d.Files) > groupPageSize {
+		d.Files = d.Files[:groupPageSize]
+	}
+}
+
+// linkResult is the outcome of linking a group's copies to its kept copy.
+type linkResult struct {
+	Method  dedupe.Method
+	Linked  []string          // copies now sharing the kept copy's data
+	Skipped []string          // copies on another device than the kept copy
+	Errors  map[string]string // path -> why it was left as it was
+}
+
+// memberOption says how one copy of a group can be resolved against the group's surviving copy.
+type memberOption struct {
+	Label       string
+	CrossDevice bool // on another (or an unknown) device: hardlinks and reflinks are impossible, only delete
+}
+
+// groupDevices counts the distinct devices of files; files without a device id count one each, since nothing
+// says they share one.
+func groupDevices(files []db.File) int {
+	seen := make(map[int64]bool)
+	n := 0
+	for _, f := range files {
+		if f.DeviceID == nil {
+			n++
+		} else if !seen[*f.DeviceID] {
+			seen[*f.DeviceID] = true
+			n++
+		}
+	}
+	return n
+}
+
+// localHost names the machine running ditto in host lists: its hostname, or "this machine" when unknown.
+var localHost = sync.OnceValue(func() string {
+	if h, err := os.Hostname(); err == nil && h != "" {
+		return h
+	}
+	return "this machine"
+})
+
+// hostLabels returns the display names of folder hosts (db.Folder.Host): "" is the machine running ditto.
+func hostLabels(hosts []string) []string {
+	out := make([]string, len(hosts))
+	for i, h := range hosts {
+		if h == "" {
+			h = localHost()
+		}
+		out[i] = h
+	}
+	return out
+}
+
+// offlineCopies returns the offline media of a group's media labels (see db.HashGroupMedia) when the group also
+// has a copy on online storage: those copies are catalog entries of unplugged drives duplicating online files.
+func offlineCopies(labels []string) []string {
+	if len(labels) < 2 || labels[0] != "" {
+		return nil
+	}
+	return labels[1:]
+}
+
+// memberOptions describes, for each file of a group, what can be done with it once survivor is kept. Links
+// (hardlink or reflink) only work within one device; across devices the copy can only be deleted, and moving
+// it onto the survivor's device would copy the data.
+func memberOptions(files []db.File, survivor int64) map[int64]memberOption {
+	var keep *db.File
+	for i := range files {
+		if files[i].ID == survivor {
+			keep = &files[i]
+		}
+	}
+	out := make(map[int64]memberOption, len(files))
+	for _, f := range files {
+		switch {
+		case keep == nil:
+		case f.ID == keep.ID:
+			out[f.ID] = memberOption{Label: "Kept copy"}
+		case f.DeviceID == nil || keep.DeviceID == nil || *f.DeviceID != *keep.DeviceID:
+			out[f.ID] = memberOption{Label: "Other device: delete, or keep as a backup (cannot be linked)", CrossDevice: true}
+		case f.Inode == keep.Inode:
+			out[f.ID] = memberOption{Label: "Already a hardlink of the kept copy"}
+		default:
+			out[f.ID] = memberOption{Label: "Same device: hardlink or reflink to the kept copy, or delete"}
+		}
+	}
+	return out
+}
+
+// toolLink is one external tool button. URL is trusted: its scheme comes from the operator's configuration,
+// and file paths in it are percent-encoded.
+type toolLink struct {
+	Label string
+	URL   template.URL
+}
+
+// hostPath returns p as the host sees it, per DITTO_PATH_MAP (unchanged without a mapping).
+func (s *Server) hostPath(p string) string {
+	if s.cfg == nil {
+		return p
+	}
+	return s.cfg.PathMap().ToHost(p)
+}
+
+// containerPath resolves a path typed as the host sees it to the bind-mounted path ditto can open.
+func (s *Server) containerPath(p string) string {
+	if s.cfg == nil {
+		return p
+	}
+	return s.cfg.PathMap().ToContainer(p)
+}
+
+// toolLinks returns the configured external tool links per file. One-file tools link every matching file;
+// two-file tools link every later file against the first one, when both match. Tools run on the user's
+// machine, so they get host paths.
+func (s *Server) toolLinks(files []db.File) map[int64][]toolLink {
+	if s.cfg == nil || len(s.cfg.ExternalTools()) == 0 || len(files) == 0 {
+		return nil
+	}
+	links := make(map[int64][]toolLink)
+	first := s.hostPath(files[0].Path)
+	for _, t := range s.cfg.ExternalTools() {
+		for i, f := range files {
+			if !t.Matches(f.Path) {
+				continue
+			}
+			if !t.Pair() {
+				links[f.ID] = append(links[f.ID], toolLink{Label: t.Label, URL: template.URL(t.Link(s.hostPath(f.Path), ""))}) // #nosec G203 -- operator-configured URL, escaped paths
+			} else if i > 0 && t.Matches(first) {
+				links[f.ID] = append(links[f.ID], toolLink{Label: t.Label + " with first", URL: template.URL(t.Link(first, s.hostPath(f.Path)))}) // #nosec G203 -- operator-configured URL, escaped paths
+			}
+		}
+	}
+	return links
+}
+
+type inodeGroupData struct {
+	ScanID   int64
+	Inode    int64
+	DeviceID *int64
+	Files    []db.File
+}
+
+func (s *Server) handleDuplicates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			log.Printf("error: duplicates parse scan id %q: %v", r.PathValue("id"), err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := db.GetScan(r.Context(), s.dbForRead(), scanID); err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		byHash, _ := db.DuplicateGroupsByHash(r.Context(), s.dbForRead(), scanID)
+		byInode, _ := db.DuplicateGroupsByInode(r.Context(), s.dbForRead(), scanID)
+		s.renderPage(w, "layout.html", "duplicates-content", duplicatesPageData{ScanID: scanID, ByHash: byHash, ByInode: byInode})
+	}
+}
+
+// errScanNotFound is returned by loaders when the requested scan does not exist (rendered as 404).
+var errScanNotFound = errors.New("scan not found")
+
+// loadHashGroup loads the files of a hash group for a scan, or across the latest scan per folder when scanID is 0.
+// A non-empty prefix keeps only the files under that directory. Page 0 loads every file (for actions on the
+// whole group); otherwise only that page of groupPageSize files is loaded.
+func (s *Server) loadHashGroup(ctx context.Context, scanID int64, hash, prefix string, page int) (*hashGroupData, error) {
+	return s.loadHashGroupFrom(ctx, s.dbForRead(), scanID, hash, prefix, page)
+}
+
+// loadHashGroupFrom is loadHashGroup reading from database: actions that change the group reload it from the
+// primary (s.db), since a replica may not show their changes yet.
+func (s *Server) loadHashGroupFrom(ctx context.Context, database *sql.DB, scanID int64, hash, prefix string, page int) (*hashGroupData, error) {
+	data := &hashGroupData{ScanID: scanID, Hash: hash, PathPrefix: prefix}
+	var scanIDs []int64
+	if scanID == 0 {
+		// "All (latest per folder)": use latest scan per root
+		scans, _ := db.ListScansRecent(ctx, database, homeListScansLimit)
+		scans = s.visibleScans(ctx, scans)
+		seen := make(map[string]bool)
+		for _, sc := range scans {
+			if seen[sc.RootPath] {
+				continue
+			}
+			seen[sc.RootPath] = true
+			scanIDs = append(scanIDs, sc.ID)
+		}
+		if included, err := db.FilterScansIncludedInAll(ctx, database, scanIDs); err == nil {
+			scanIDs = included
+		}
+		if page == 0 {
+			if prefix != "" {
+				data.Files, _ = db.FilesInHashGroupUnderPrefix(ctx, database, scanIDs, hash, prefix, 0)
+			} else {
+				data.Files, _ = db.FilesInHashGroupAcrossScans(ctx, database, scanIDs, hash)
+			}
+		}
+		data.RootPathByScanID = make(map[int64]string)
+		for _, sc := range scans {
+			if _, ok := data.RootPathByScanID[sc.ID]; !ok {
+				data.RootPathByScanID[sc.ID] = sc.RootPath
+			}
+		}
+	} else {
+		if _, err := db.GetScan(ctx, database, scanID); err != nil {
+			return nil, errScanNotFound
+		}
+		scanIDs = []int64{scanID}
+		if page == 0 {
+			var files []db.File
+			var err error
+			if prefix != "" {
+				files, err = db.FilesInHashGroupUnderPrefix(ctx, database, scanIDs, hash, prefix, 0)
+			} else {
+				files, err = db.FilesInHashGroup(ctx, database, scanID, hash)
+			}
+			if err != nil {
+				return nil, err
+			}
+			data.Files = files
+		}
+	}
+	if page > 0 {
+		total, devices, err := db.HashGroupStats(ctx, database, scanIDs, hash, prefix)
+		if err != nil {
+			return nil, err
+		}
+		data.Devices = devices
+		offset := data.setPage(page, total)
+		data.Files, err = db.FilesInHashGroupPage(ctx, database, scanIDs, hash, prefix, groupPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ids := make([]int64, len(data.Files))
+	for i, f := range data.Files {
+		ids[i] = f.ID
+	}
+	data.VerifiedAt, _ = db.VerifiedAtByFileID(ctx, database, ids)
+	data.Evidence, _ = db.HashEvidenceByFileID(ctx, database, ids)
+	if hosts, err := db.HashGroupHosts(ctx, database, scanIDs, []string{hash}); err == nil {
+		data.Hosts = hostLabels(hosts[hash])
+	}
+	data.Sightings, _ = db.FileSightings(ctx, database, ids)
+	data.Extents, _ = db.ExtentsByFileID(ctx, database, ids)
+	if meta, _ := db.MetadataByFileID(ctx, database, ids); len(meta) > 0 {
+		for _, f := range data.Files { // copies share their bytes, so any copy's metadata describes the group
+			if m, ok := meta[f.ID]; ok {
+				data.Photo = &m
+				break
+			}
+		}
+	}
+	data.ToolLinks = s.toolLinks(data.Files)
+	ack, err := db.GetAcknowledgedGroup(ctx, database, hash)
+	if err != nil {
+		return nil, err
+	}
+	data.Acknowledged = ack
+	keepers, err := db.GroupKeepers(ctx, database, []string{hash})
+	if err != nil {
+		return nil, err
+	}
+	data.Keeper = keepers[hash]
+	data.Survivor = data.Keeper
+	if page == 0 {
+		if data.Survivor == 0 && len(data.Files) > 0 {
+			data.Survivor = data.Files[0].ID
+		}
+		data.Options = memberOptions(data.Files, data.Survivor)
+		data.Devices = groupDevices(data.Files)
+		return data, nil
+	}
+	// A page may not hold the survivor (the keeper, else the group's first file); options compare against it.
+	var keep *db.File
+	if data.Survivor == 0 {
+		first, err := db.FilesInHashGroupPage(ctx, database, scanIDs, hash, "", 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(first) > 0 {
+			keep = &first[0]
+		}
+	} else if keep, err = db.HashGroupFile(ctx, database, scanIDs, hash, data.Survivor); err != nil {
+		return nil, err
+	}
+	files := data.Files
+	if keep != nil {
+		data.Survivor = keep.ID
+		files = append([]db.File{*keep}, files...)
+		if keep.Size <= textPreviewLimit {
+			if data.SameSize, err = db.SameSizeOtherHash(ctx, database, scanIDs, keep.Size, hash, sameSizeLimit); err != nil {
+				return nil, err
+			}
+		}
+	}
+	data.Options = memberOptions(files, data.Survivor)
+	return data, nil
+}
+
+func (s *Server) handleDuplicateHashGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			log.Printf("error: duplicate hash group parse scan id %q: %v", r.PathValue("id"), err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hash := r.PathValue("hash")
+		if hash == "" {
+			http.Error(w, "hash required", http.StatusBadRequest)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		data, err := s.loadHashGroup(r.Context(), scanID, hash, strings.TrimSpace(r.URL.Query().Get("prefix")), max(page, 1))
+		if err != nil {
+			s.groupLoadError(w, scanID, hash, err)
+			return
+		}
+		s.renderPage(w, "layout.html", "duplicate-group-content", data)
+	}
+}
+
+// handleAcknowledgeHashGroup marks the group as intentional (form: optional note), hiding it from duplicate
+// listings and reclaimable-space totals, and shows the group page again.
+func (s *Server) handleAcknowledgeHashGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hashStr := r.PathValue("hash")
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.AcknowledgeGroup(r.Context(), s.db, hashStr, strings.TrimSpace(r.FormValue("note"))); err != nil {
+			log.Printf("error: acknowledge hash=%s: %v", hashStr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10)+"/duplicates/hash/"+hashStr, http.StatusSeeOther)
+	}
+}
+
+// handleUnacknowledgeHashGroup removes the group's intentional mark and shows the group page again.
+func (s *Server) handleUnacknowledgeHashGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hashStr := r.PathValue("hash")
+		if err := db.UnacknowledgeGroup(r.Context(), s.db, hashStr); err != nil {
+			log.Printf("error: unacknowledge hash=%s: %v", hashStr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10)+"/duplicates/hash/"+hashStr, http.StatusSeeOther)
+	}
+}
+
+// handleHashGroupKeeper pins a file of the group as its keeper (form: file_id, and the page and prefix to
+// return to) and shows the group page again.
+func (s *Server) handleHashGroupKeeper() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hashStr := r.PathValue("hash")
+		fileID, err := strconv.ParseInt(r.FormValue("file_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid file_id", http.StatusBadRequest)
+			return
+		}
+		if err := db.SetGroupKeeper(r.Context(), s.db, hashStr, fileID); err != nil {
+			if errors.Is(err, db.ErrKeeperNotInGroup) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("error: set keeper hash=%s file=%d: %v", hashStr, fileID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		http.Redirect(w, r, groupPageURL(scanID, hashStr, r.FormValue("prefix"), page), http.StatusSeeOther)
+	}
+}
+
+// handleHashGroupKeeperClear unpins the group's keeper and shows the group page again.
+func (s *Server) handleHashGroupKeeperClear() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hashStr := r.PathValue("hash")
+		if err := db.ClearGroupKeeper(r.Context(), s.db, hashStr); err != nil {
+			log.Printf("error: clear keeper hash=%s: %v", hashStr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		http.Redirect(w, r, groupPageURL(scanID, hashStr, r.FormValue("prefix"), page), http.StatusSeeOther)
+	}
+}
+
+// handleAcknowledgedGroups lists the groups marked as intentional.
+func (s *Server) handleAcknowledgedGroups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := db.ListAcknowledgedGroups(r.Context(), s.dbForRead())
+		if err != nil {
+			log.Printf("error: list acknowledged groups: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderPage(w, "layout.html", "acknowledged-content", list)
+	}
+}
+
+// handleAcknowledgedGroupDelete removes a group's intentional mark from the acknowledged list.
+func (s *Server) handleAcknowledgedGroupDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hashStr := r.PathValue("hash")
+		if err := db.UnacknowledgeGroup(r.Context(), s.db, hashStr); err != nil {
+			log.Printf("error: unacknowledge hash=%s: %v", hashStr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/acknowledged", http.StatusSeeOther)
+	}
+}
+
+// handleLinkHashGroup replaces every copy of the group on the kept copy's device with a link to it (form:
+// method, hardlink or reflink), and renders the group page with the result. Copies on another device are
+// skipped, since they cannot be linked. Copies whose hash was reused or that changed on disk since the scan are
+// re-hashed first (linkChecks), and each copy is compared byte by byte with the kept one.
+func (s *Server) handleLinkHashGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hashStr := r.PathValue("hash")
+		method, err := dedupe.ParseMethod(r.FormValue("method"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		data, err := s.loadHashGroupFrom(ctx, s.db, scanID, hashStr, "", 0)
+		if err != nil {
+			s.groupLoadError(w, scanID, hashStr, err)
+			return
+		}
+		// Re-hash first: a reused hash that turns out wrong, or a file changed since the scan, takes its file out of
+		// the group before anything is linked.
+		rehashed, err := s.rehashGroup(ctx, hashStr, linkChecks(data))
+		if err != nil {
+			log.Printf("error: rehash before link hash=%s: %v", hashStr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(rehashed.Changed) > 0 {
+			if data, err = s.loadHashGroupFrom(ctx, s.db, scanID, hashStr, "", 0); err != nil {
+				s.groupLoadError(w, scanID, hashStr, err)
+				return
+			}
+		}
+		var keep *db.File
+		for i := range data.Files {
+			if data.Files[i].ID == data.Survivor {
+				keep = &data.Files[i]
+			}
+		}
+		if keep == nil {
+			http.Error(w, "group has no files", http.StatusNotFound)
+			return
+		}
+		res := &linkResult{Method: method, Errors: make(map[string]string)}
+		for _, f := range data.Files {
+			opt := data.Options[f.ID]
+			switch {
+			case f.ID == keep.ID || (f.Inode == keep.Inode && !opt.CrossDevice):
+				continue
+			case opt.CrossDevice:
+				res.Skipped = append(res.Skipped, f.Path)
+				continue
+			}
+			if err, ok := rehashed.Errors[f.Path]; ok {
+				res.Errors[f.Path] = "not linked: could not be re-hashed: " + err.Error()
+				continue
+			}
+			if err := dedupe.Replace(ctx, keep.Path, f.Path, method); err != nil {
+				res.Errors[f.Path] = err.Error()
+				continue
+			}
+			res.Linked = append(res.Linked, f.Path)
+			if inode, _, err := scan.InodeAndDev(f.Path); err == nil {
+				if err := db.UpdateFileInode(ctx, s.db, f.ID, inode); err != nil {
+					log.Printf("error: record inode of linked %s: %v", f.Path, err)
+				}
+			}
+			if method == dedupe.Reflink {
+				s.recordSharedBytes(ctx, f)
+			}
+		}
+		if method == dedupe.Reflink && len(res.Linked) > 0 {
+			s.recordSharedBytes(ctx, *keep)
+		}
+		if len(res.Linked) > 0 {
+			if err := db.DropScanSummariesForHash(ctx, s.db, hashStr); err != nil {
+				log.Printf("error: drop summaries hash=%s: %v", hashStr, err)
+			}
+		}
+		log.Printf("[link] hash group %s (%s): %d linked, %d on other devices, %d errors", hashStr, method, len(res.Linked), len(res.Skipped), len(res.Errors))
+		if data, err = s.loadHashGroupFrom(ctx, s.db, scanID, hashStr, "", 1); err != nil {
+			s.groupLoadError(w, scanID, hashStr, err)
+			return
+		}
+		data.Link = res
+		data.Rehash = rehashed
+		s.renderPage(w, "layout.html", "duplicate-group-content", data)
+	}
+}
+
+// recordSharedBytes stores the bytes f now shares with other files, so savings stop counting a reflinked copy
+// before the next scan. Filesystems that do not report shared extents are left as they are.
+func (s *Server) recordSharedBytes(ctx context.Context, f db.File) {
+	shared, err := extents.Shared(f.Path)
+	if err != nil {
+		return
+	}
+	if err := db.UpdateFileSharedBytes(ctx, s.db, f.ID, shared); err != nil {
+		log.Printf("error: record shared extents of %s: %v", f.Path, err)
+	}
+}
+
+// handleVerifyHashGroup compares every file in the group byte by byte, records verified_at for files that
+// match, and renders the group page with the result (mismatches mean a file changed since it was hashed).
+func (s *Server) handleVerifyHashGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hashStr := r.PathValue("hash")
+		ctx := r.Context()
+		data, err := s.loadHashGroup(ctx, scanID, hashStr, "", 0)
+		if err != nil {
+			s.groupLoadError(w, scanID, hashStr, err)
+			return
+		}
+		paths := make([]string, len(data.Files))
+		idByPath := make(map[string]int64, len(data.Files))
+		for i, f := range data.Files {
+			paths[i] = f.Path
+			idByPath[f.Path] = f.ID
+		}
+		res := hash.VerifyGroup(ctx, paths)
+		var verified []int64
+		for _, p := range res.Identical {
+			verified = append(verified, idByPath[p])
+		}
+		// A lone readable file proves nothing; only record verification when at least two copies matched.
+		if len(verified) > 1 {
+			if err := db.MarkFilesVerified(ctx, s.db, verified, time.Now()); err != nil {
+				log.Printf("error: mark verified hash=%s: %v", hashStr, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data.VerifiedAt, _ = db.VerifiedAtByFileID(ctx, s.dbForRead(), verified)
+		}
+		log.Printf("[verify] hash group %s: %d identical, %d mismatched, %d errors", hashStr, len(res.Identical), len(res.Mismatched), len(res.Errors))
+		data.Verify = res
+		data.firstPage()
+		s.renderPage(w, "layout.html", "duplicate-group-content", data)
+	}
+}
+
+// rehashTargets returns the files of a fully loaded group to read again: the one with id fileID, or for fileID 0
+// every file whose hash was not computed from its own bytes (reused, or stored before sources were recorded).
+func rehashTargets(data *hashGroupData, fileID int64) []db.File {
+	var out []db.File
+	for _, f := range data.Files {
+		if f.ID == fileID || (fileID == 0 && data.Evidence[f.ID].Source != db.HashComputed) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// linkChecks returns the files of a fully loaded group to read again before linking: those whose hash was not
+// computed from their own bytes (rehashTargets), and those whose size or modification time no longer match the
+// scan, or that cannot be checked.
+func linkChecks(data *hashGroupData) []db.File {
+	out := rehashTargets(data, 0)
+	reused := make(map[int64]bool, len(out))
+	for _, f := range out {
+		reused[f.ID] = true
+	}
+	for _, f := range data.Files {
+		if reused[f.ID] {
+			continue
+		}
+		if size, mtime, err := archive.Stat(f.Path); err != nil || size != f.Size || mtime != f.MTime {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// rehashGroup reads files of the group hashStr again (hash.Rehash). When a file's bytes turn out to have another
+// hash, the stored summaries of both contents are dropped, since the file moved from one group to the other.
+func (s *Server) rehashGroup(ctx context.Context, hashStr string, files []db.File) (*hash.RehashResult, error) {
+	res, err := hash.Rehash(ctx, s.db, files)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Changed) > 0 {
+		dropped := map[string]bool{hashStr: true}
+		for _, h := range res.Changed {
+			dropped[h] = true
+		}
+		for h := range dropped {
+			if err := db.DropScanSummariesForHash(ctx, s.db, h); err != nil {
+				log.Printf("error: drop summaries hash=%s: %v", h, err)
+			}
+		}
+	}
+	if len(files) > 0 {
+		log.Printf("[rehash] hash group %s: %d unchanged, %d changed, %d errors", hashStr, len(res.Same), len(res.Changed), len(res.Errors))
+	}
+	return res, nil
+}
+
+// handleRehashHashGroup reads copies of the group again and stores the hash of their bytes in place of a reused
+// one (form: file_id for one copy, else every copy whose hash was not computed from its own bytes; and the page
+// and prefix to show again). A copy whose bytes have another hash leaves the group.
+func (s *Server) handleRehashHashGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		hashStr := r.PathValue("hash")
+		var fileID int64
+		if v := r.FormValue("file_id"); v != "" {
+			if fileID, err = strconv.ParseInt(v, 10, 64); err != nil || fileID <= 0 {
+				http.Error(w, "invalid file_id", http.StatusBadRequest)
+				return
+			}
+		}
+		ctx := r.Context()
+		data, err := s.loadHashGroup(ctx, scanID, hashStr, "", 0)
+		if err != nil {
+			s.groupLoadError(w, scanID, hashStr, err)
+			return
+		}
+		files := rehashTargets(data, fileID)
+		if fileID != 0 && len(files) == 0 {
+			http.Error(w, "file is not in this group", http.StatusNotFound)
+			return
+		}
+		res, err := s.rehashGroup(ctx, hashStr, files)
+		if err != nil {
+			log.Printf("error: rehash hash=%s: %v", hashStr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		if data, err = s.loadHashGroupFrom(ctx, s.db, scanID, hashStr, strings.TrimSpace(r.FormValue("prefix")), max(page, 1)); err != nil {
+			s.groupLoadError(w, scanID, hashStr, err)
+			return
+		}
+		data.Rehash = res
+		s.renderPage(w, "layout.html", "duplicate-group-content", data)
+	}
+}
+
+// visibleFile loads a file the request's user may see. Otherwise it answers 404 (or 500) and returns false.
+func (s *Server) visibleFile(w http.ResponseWriter, r *http.Request, fileID int64) (*db.File, bool) {
+	f, err := db.GetFile(r.Context(), s.dbForRead(), fileID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !s.folderVisible(r.Context(), f.FolderID)) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("error: load file %d: %v", fileID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return f, true
+}
+
+// textPreviewLimit is how much of a file the text preview shows, and the largest file the diff reads.
+const textPreviewLimit = 64 << 10
+
+// readHead reads up to n bytes of the file at path; truncated is true when the file is longer.
+func readHead(path string, n int64) (b []byte, truncated bool, err error) {
+	release, err := limits.AcquireFiles(context.Background(), 1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+	f, err := archive.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	b, err = io.ReadAll(io.LimitReader(f, n+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(b)) > n {
+		return b[:n], true, nil
+	}
+	return b, false, nil
+}
+
+// textPreview is the text preview fragment of one file.
+type textPreview struct {
+	Text      string
+	Truncated bool // only the first textPreviewLimit bytes are shown
+	Binary    bool // not text: nothing is shown
+}
+
+// handleTextPreview renders the start of a text file (?file_id=) as a fragment for the group page.
+func (s *Server) handleTextPreview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fileID, err := strconv.ParseInt(r.URL.Query().Get("file_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid file_id", http.StatusBadRequest)
+			return
+		}
+		f, ok := s.visibleFile(w, r, fileID)
+		if !ok {
+			return
+		}
+		b, truncated, err := readHead(f.Path, textPreviewLimit)
+		if err != nil {
+			log.Printf("error: text preview %s: %v", f.Path, err)
+			http.Error(w, "cannot read file", http.StatusUnprocessableEntity)
+			return
+		}
+		data := textPreview{Text: string(b), Truncated: truncated, Binary: !textdiff.IsText(b)}
+		var buf bytes.Buffer
+		if err := s.tmpl.ExecuteTemplate(&buf, "text-preview-fragment", data); err != nil {
+			log.Printf("error: render text preview: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+type diffPageData struct {
+	A, B  *db.File
+	Lines []textdiff.Line
+	Error string // why there is no diff (not text, too large, unreadable)
+}
+
+// handleDiff shows a line diff of two small text files (?a=&b= file ids), e.g. a group's kept copy and a
+// file of the same size whose content differs.
+func (s *Server) handleDiff() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data diffPageData
+		var texts [2]string
+		for i, name := range []string{"a", "b"} {
+			id, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+			if err != nil {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			f, ok := s.visibleFile(w, r, id)
+			if !ok {
+				return
+			}
+			if i == 0 {
+				data.A = f
+			} else {
+				data.B = f
+			}
+			if data.Error != "" {
+				continue
+			}
+			b, truncated, err := readHead(f.Path, textPreviewLimit)
+			switch {
+			case err != nil:
+				data.Error = fmt.Sprintf("Cannot read %s: %v", s.hostPath(f.Path), err)
+			case truncated:
+				data.Error = fmt.Sprintf("%s is larger than %s; only smaller files are compared.", s.hostPath(f.Path), formatBytes(textPreviewLimit))
+			case !textdiff.IsText(b):
+				data.Error = fmt.Sprintf("%s is not a text file.", s.hostPath(f.Path))
+			}
+			texts[i] = string(b)
+		}
+		if data.Error == "" {
+			var err error
+			if data.Lines, err = textdiff.Diff(texts[0], texts[1]); err != nil {
+				data.Error = "The files differ in too many lines to show."
+			}
+		}
+		s.renderPage(w, "layout.html", "diff-content", data)
+	}
+}
+
+// isImage reports whether path has an extension the image decoders read (thumbnails are shown for those).
+func isImage(path string) bool {
+	return slices.Contains(similarity.ImageExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// handlePreview streams a thumbnail of an image file (?file_id=), made once per content hash and kept in the
+// data directory. The browser may keep it for a day.
+func (s *Server) handlePreview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fileID, err := strconv.ParseInt(r.URL.Query().Get("file_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid file_id", http.StatusBadRequest)
+			return
+		}
+		f, ok := s.visibleFile(w, r, fileID)
+		if !ok {
+			return
+		}
+		if !isImage(f.Path) {
+			http.Error(w, "not an image", http.StatusUnsupportedMediaType)
+			return
+		}
+		key := ""
+		if f.Hash != nil && f.HashStatus == "done" {
+			key = *f.Hash
+		}
+		b, err := s.thumbs.Get(key, f.Path)
+		if err != nil {
+			log.Printf("error: preview %s: %v", f.Path, err)
+			http.Error(w, "cannot read image", http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		_, _ = w.Write(b)
+	}
+}
+
+func (s *Server) groupLoadError(w http.ResponseWriter, scanID int64, hash string, err error) {
+	if errors.Is(err, errScanNotFound) {
+		http.Error(w, "scan not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("error: files in hash group scan=%d hash=%s: %v", scanID, hash, err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (s *Server) handleDuplicateInodeGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			log.Printf("error: duplicate inode group parse scan id %q: %v", r.PathValue("id"), err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		inode, err := strconv.ParseInt(r.URL.Query().Get("inode"), 10, 64)
+		if err != nil {
+			http.Error(w, "inode required", http.StatusBadRequest)
+			return
+		}
+		var deviceID *int64
+		if d := r.URL.Query().Get("device_id"); d != "" {
+			v, err := strconv.ParseInt(d, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid device_id", http.StatusBadRequest)
+				return
+			}
+			deviceID = &v
+		}
+		if _, err := db.GetScan(r.Context(), s.dbForRead(), scanID); err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		files, err := db.FilesInInodeGroup(r.Context(), s.dbForRead(), scanID, inode, deviceID)
+		if err != nil {
+			log.Printf("error: files in inode group scan=%d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderPage(w, "layout.html", "duplicate-inode-group-content", inodeGroupData{ScanID: scanID, Inode: inode, DeviceID: deviceID, Files: files})
+	}
+}
+
+const changesPageLimit = 1000 // max changed files listed on the changes page
+
+type changesPageData struct {
+	Scan      *db.Scan
+	Previous  *db.Scan // nil when there is no earlier completed scan of the folder
+	Changes   []db.ContentChange
+	Truncated bool
+}
+
+// handleScanChanges lists files whose content hash changed between the previous completed scan of the folder and this one.
+func (s *Server) handleScanChanges() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		prev, err := db.PreviousCompletedScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			log.Printf("error: previous scan for %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := changesPageData{Scan: sn, Previous: prev}
+		if prev != nil {
+			changes, err := db.ContentChangesBetweenScans(ctx, s.dbForRead(), prev.ID, scanID, changesPageLimit+1)
+			if err != nil {
+				log.Printf("error: content changes %d..%d: %v", prev.ID, scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(changes) > changesPageLimit {
+				changes = changes[:changesPageLimit]
+				data.Truncated = true
+			}
+			data.Changes = changes
+		}
+		s.renderPage(w, "layout.html", "changes-content", data)
+	}
+}
+
+const largestPageSize = 50
+
+type largestPageData struct {
+	Scan       *db.Scan
+	Files      []db.File
+	Ext        string // extension filter as entered (empty for all)
+	Total      int64
+	Page       int // 1-based
+	TotalPages int
+	PrevPage   int // 0 if no prev
+	NextPage   int // 0 if no next
+	Rank       int // rank of the first file on the page (1-based)
+}
+
+// handleLargestFiles lists the scan's files by size, largest first, with pagination and an optional ?ext= filter.
+// Unlike the duplicates pages it includes every file, duplicated or not.
+func (s *Server) handleLargestFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		ext := r.URL.Query().Get("ext")
+		total, err := db.LargestFilesCount(ctx, s.dbForRead(), scanID, ext)
+		if err != nil {
+			log.Printf("error: largest files count scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			if pn, err := strconv.Atoi(p); err == nil && pn >= 1 {
+				page = pn
+			}
+		}
+		totalPages := 1
+		if total > 0 {
+			totalPages = int((total + largestPageSize - 1) / largestPageSize)
+		}
+		if page > totalPages {
+			page = totalPages
+		}
+		offset := (page - 1) * largestPageSize
+		files, err := db.LargestFiles(ctx, s.dbForRead(), scanID, ext, largestPageSize, offset)
+		if err != nil {
+			log.Printf("error: largest files scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := largestPageData{Scan: sn, Files: files, Ext: ext, Total: total, Page: page, TotalPages: totalPages, Rank: offset + 1}
+		if page > 1 {
+			data.PrevPage = page - 1
+		}
+		if page < totalPages {
+			data.NextPage = page + 1
+		}
+		s.renderPage(w, "layout.html", "largest-content", data)
+	}
+}
+
+const sameNamePageSize = 20
+
+type sameNamePageData struct {
+	Scan       *db.Scan
+	Groups     []db.NameGroup
+	Ext        string // extension filter as entered (empty for all)
+	Total      int64
+	Page       int // 1-based
+	TotalPages int
+	PrevPage   int // 0 if no prev
+	NextPage   int // 0 if no next
+}
+
+// handleSameName lists file names the scan's files share with different content (divergent copies of a
+// document), most versions first, with pagination and an optional ?ext= filter.
+func (s *Server) handleSameName() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		ext := r.URL.Query().Get("ext")
+		total, err := db.SameNameGroupsCount(ctx, s.dbForRead(), scanID, ext)
+		if err != nil {
+			log.Printf("error: same-name count scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			if pn, err := strconv.Atoi(p); err == nil && pn >= 1 {
+				page = pn
+			}
+		}
+		totalPages := 1
+		if total > 0 {
+			totalPages = int((total + sameNamePageSize - 1) / sameNamePageSize)
+		}
+		if page > totalPages {
+			page = totalPages
+		}
+		groups, err := db.SameNameGroups(ctx, s.dbForRead(), scanID, ext, sameNamePageSize, (page-1)*sameNamePageSize)
+		if err != nil {
+			log.Printf("error: same-name groups scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := sameNamePageData{Scan: sn, Groups: groups, Ext: ext, Total: total, Page: page, TotalPages: totalPages}
+		if page > 1 {
+			data.PrevPage = page - 1
+		}
+		if page < totalPages {
+			data.NextPage = page + 1
+		}
+		s.renderPage(w, "layout.html", "names-content", data)
+	}
+}
+
+// contentLocationsLimit caps the files listed on the content page.
+const contentLocationsLimit = 200
+
+type contentPageData struct {
+	Hash      string
+	Content   *db.KnownContent // nil when ditto has never hashed the content
+	FirstPath string           // "" when its folder is deleted or not visible to the user
+	Locations []db.ContentLocation
+}
+
+// handleKnownContent shows where and when a content (by hash) was first seen, and every file that has had it
+// in any folder or scan, including files of deleted scans. Only folders visible to the user are listed.
+func (s *Server) handleKnownContent() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		hashStr := r.PathValue("hash")
+		c, err := db.GetKnownContent(ctx, s.dbForRead(), hashStr)
+		if err != nil {
+			log.Printf("error: known content hash=%s: %v", hashStr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := contentPageData{Hash: hashStr, Content: c}
+		if c != nil {
+			if c.FirstFolderID != nil && s.folderVisible(ctx, *c.FirstFolderID) {
+				data.FirstPath = c.FirstPath
+			}
+			var viewer *int64
+			if u := userFrom(ctx); u != nil {
+				viewer = &u.ID
+			}
+			data.Locations, err = db.ContentLocations(ctx, s.dbForRead(), c.ID, viewer, contentLocationsLimit)
+			if err != nil {
+				log.Printf("error: content locations hash=%s: %v", hashStr, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		s.renderPage(w, "layout.html", "content-content", data)
+	}
+}
+
+// usageRow is one child of the directory on the usage page, with its share of the directory's bytes.
+type usageRow struct {
+	db.DirUsage
+	Path       string  // relative path to drill into (subdirectories only)
+	Percent    float64 // share of the directory's total bytes
+	DupPercent float64 // share of this entry's bytes that are duplicates
+}
+
+type usageCrumb struct {
+	Name string
+	Path string
+}
+
+type usagePageData struct {
+	Scan        *db.Scan
+	Dir         string       // relative to the scan root; "" for the root
+	Breadcrumbs []usageCrumb // path from the root to Dir
+	Rows        []usageRow
+	Total       db.DirUsage // totals for Dir
+}
+
+// handleDirectoryUsage shows per-directory size totals and duplicate share for a scan, one level at a time (?dir=).
+func (s *Server) handleDirectoryUsage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		dir := strings.Trim(path.Clean("/"+r.URL.Query().Get("dir")), "/")
+		usage, err := db.DirectoryUsage(ctx, s.dbForRead(), scanID, dir)
+		if err != nil {
+			log.Printf("error: directory usage scan %d dir %q: %v", scanID, dir, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := usagePageData{Scan: sn, Dir: dir}
+		if dir != "" {
+			parts := strings.Split(dir, "/")
+			for i, p := range parts {
+				data.Breadcrumbs = append(data.Breadcrumbs, usageCrumb{Name: p, Path: strings.Join(parts[:i+1], "/")})
+			}
+		}
+		for _, u := range usage {
+			data.Total.Files += u.Files
+			data.Total.Bytes += u.Bytes
+			data.Total.DuplicateBytes += u.DuplicateBytes
+		}
+		for _, u := range usage {
+			row := usageRow{DirUsage: u}
+			if u.IsDir {
+				row.Path = strings.TrimPrefix(dir+"/"+u.Name, "/")
+			}
+			if data.Total.Bytes > 0 {
+				row.Percent = 100 * float64(u.Bytes) / float64(data.Total.Bytes)
+			}
+			if u.Bytes > 0 {
+				row.DupPercent = 100 * float64(u.DuplicateBytes) / float64(u.Bytes)
+			}
+			data.Rows = append(data.Rows, row)
+		}
+		s.renderPage(w, "layout.html", "usage-content", data)
+	}
+}
+
+// handleDuplicatesExport streams every duplicate group of the scan with its file paths (not paginated) as
+// ?format=csv (default) or ?format=json, as a download for offline review or cleanup scripts.
+func (s *Server) handleDuplicatesExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		var exp duplicateExporter
+		switch format {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			exp = newCSVDuplicateExporter(w)
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			exp = newJSONDuplicateExporter(w)
+		default:
+			http.Error(w, "format must be csv or json", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.GetScan(r.Context(), s.dbForRead(), scanID); err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ditto-scan-%d-duplicates.%s"`, scanID, format))
+		// Rows go from the database cursor to the client as they arrive, flushed every exportFlushRows rows, so a
+		// scan of millions of files is neither held in memory nor cut off by the server's write timeout.
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+		rows := 0
+		row := func(row db.DuplicateExportRow) error {
+			if err := exp.Row(row); err != nil {
+				return err
+			}
+			if rows++; rows%exportFlushRows != 0 {
+				return nil
+			}
+			if err := exp.Flush(); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			return nil
+		}
+		// Headers are sent with the first row, so a failure midway can only be logged; the file is then truncated.
+		if err := db.EachDuplicateFile(r.Context(), s.dbForRead(), scanID, row); err != nil {
+			log.Printf("error: export duplicates for scan %d: %v", scanID, err)
+			return
+		}
+		if err := exp.Close(); err != nil {
+			log.Printf("error: export duplicates for scan %d: %v", scanID, err)
+		}
+	}
+}
+
+// duplicateExporter writes streamed duplicate rows in one export format.
+type duplicateExporter interface {
+	Row(db.DuplicateExportRow) error
+	Flush() error // writes the complete rows buffered so far
+	Close() error // flushes buffered output and terminates the document
+}
+
+// csvDuplicateExporter writes one line per file, repeating the group columns.
+type csvDuplicateExporter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVDuplicateExporter(w io.Writer) *csvDuplicateExporter {
+	return &csvDuplicateExporter{w: csv.NewWriter(w)}
+}
+
+func (e *csvDuplicateExporter) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write([]string{"hash", "group_files", "group_bytes", "path", "size", "mtime", "inode", "device_id", "keeper", "hash_source"})
+}
+
+func (e *csvDuplicateExporter) Row(r db.DuplicateExportRow) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	dev := ""
+	if r.File.DeviceID != nil {
+		dev = strconv.FormatInt(*r.File.DeviceID, 10)
+	}
+	return e.w.Write([]string{
+		r.Hash, strconv.FormatInt(r.GroupFiles, 10), strconv.FormatInt(r.GroupSize, 10), r.File.Path,
+		strconv.FormatInt(r.File.Size, 10), strconv.FormatInt(r.File.MTime, 10), strconv.FormatInt(r.File.Inode, 10), dev,
+		strconv.FormatBool(r.Keeper), r.HashSource,
+	})
+}
+
+func (e *csvDuplicateExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvDuplicateExporter) Close() error {
+	if err := e.writeHeader(); err != nil { // an empty export still has its header
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// exportGroup and exportFile are the JSON shape of an exported duplicate group.
+type exportGroup struct {
+	Hash  string       `json:"hash"`
+	Count int64        `json:"count"`
+	Bytes int64        `json:"bytes"`
+	Files []exportFile `json:"files"`
+}
+
+type exportFile struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	MTime      int64  `json:"mtime"`
+	Inode      int64  `json:"inode"`
+	DeviceID   *int64 `json:"device_id,omitempty"`
+	Keeper     bool   `json:"keeper,omitempty"`
+	HashSource string `json:"hash_source,omitempty"` // db.HashComputed, ...
+}
+
+// jsonDuplicateExporter writes a JSON array of groups, emitting each group once its last file has arrived.
+type jsonDuplicateExporter struct {
+	w     *bufio.Writer
+	cur   *exportGroup
+	count int
+}
+
+func newJSONDuplicateExporter(w io.Writer) *jsonDuplicateExporter {
+	return &jsonDuplicateExporter{w: bufio.NewWriter(w)}
+}
+
+func (e *jsonDuplicateExporter) Row(r db.DuplicateExportRow) error {
+	if e.cur != nil && e.cur.Hash != r.Hash {
+		if err := e.flushGroup(); err != nil {
+			return err
+		}
+	}
+	if e.cur == nil {
+		e.cur = &exportGroup{Hash: r.Hash, Count: r.GroupFiles, Bytes: r.GroupSize}
+	}
+	e.cur.Files = append(e.cur.Files, exportFile{Path: r.File.Path, Size: r.File.Size, MTime: r.File.MTime, Inode: r.File.Inode, DeviceID: r.File.DeviceID, Keeper: r.Keeper, HashSource: r.HashSource})
+	return nil
+}
+
+func (e *jsonDuplicateExporter) flushGroup() error {
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "[\n"
+	}
+	b, err := json.Marshal(e.cur)
+	if err != nil {
+		return err
+	}
+	e.count++
+	e.cur = nil
+	if _, err := e.w.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+// Flush writes the groups completed so far; the group still receiving files stays buffered.
+func (e *jsonDuplicateExporter) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *jsonDuplicateExporter) Close() error {
+	if e.cur != nil {
+		if err := e.flushGroup(); err != nil {
+			return err
+		}
+	}
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := e.w.WriteString(end); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// handleScanManifest serves the scan's hash manifest as a JSON download, signed when a signing key is configured.
+// The first export records the manifest digest, chaining it to the folder's previous manifest.
+func (s *Server) handleScanManifest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		signer, err := manifest.ParseKey(s.cfg.ManifestSigningKey())
+		if err != nil {
+			log.Printf("error: manifest signing key: %v", err)
+			http.Error(w, "invalid manifest signing key", http.StatusInternalServerError)
+			return
+		}
+		m, err := manifest.BuildScan(r.Context(), s.db, scanID, signer)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "scan not found", http.StatusNotFound)
+			case errors.Is(err, manifest.ErrScanNotHashed):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				log.Printf("error: manifest for scan %d: %v", scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ditto-scan-%d-manifest.json"`, scanID))
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{}) // a large scan's manifest takes a while to send
+		if err := manifest.Write(w, m); err != nil {
+			log.Printf("error: write manifest for scan %d: %v", scanID, err)
+		}
+	}
+}
+
+// handleManifestIndex serves the manifest-of-manifests: digests of every exported scan manifest.
+func (s *Server) handleManifestIndex() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		signer, err := manifest.ParseKey(s.cfg.ManifestSigningKey())
+		if err != nil {
+			log.Printf("error: manifest signing key: %v", err)
+			http.Error(w, "invalid manifest signing key", http.StatusInternalServerError)
+			return
+		}
+		idx, err := manifest.BuildIndex(r.Context(), s.dbForRead(), signer)
+		if err != nil {
+			log.Printf("error: manifest index: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := manifest.Write(w, idx); err != nil {
+			log.Printf("error: write manifest index: %v", err)
+		}
+	}
+}
+
+// handleScanLock locks a completed scan: its ledger snapshot is frozen and a checksum over it is stored.
+func (s *Server) handleScanLock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.LockScan(r.Context(), s.db, scanID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "scan not found", http.StatusNotFound)
+			case errors.Is(err, db.ErrScanNotLockable):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				log.Printf("error: lock scan %d: %v", scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+	}
+}
+
+// handleScanDelete deletes a scan (DELETE /scans/{id}, or POST /scans/{id}/delete from the scans page)
+// and then the files no remaining scan references. Locked scans and the scan being processed are refused.
+func (s *Server) handleScanDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if s.running.Load() == scanID {
+			http.Error(w, "scan is running; pause or wait for it to finish", http.StatusConflict)
+			return
+		}
+		if err := db.DeleteScan(r.Context(), s.db, scanID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "scan not found", http.StatusNotFound)
+			case errors.Is(err, db.ErrScanIsLocked), errors.Is(err, db.ErrFolderLocked):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				log.Printf("error: delete scan %d: %v", scanID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if n, err := db.DeleteOrphanFiles(r.Context(), s.db); err != nil {
+			log.Printf("error: delete orphan files after scan %d: %v", scanID, err)
+		} else {
+			log.Printf("[retention] deleted scan %d and %d unreferenced files", scanID, n)
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "/scans", http.StatusSeeOther)
+	}
+}
+
+// pruneScans applies the DITTO_KEEP_SCANS retention policy. The scan being processed is never pruned.
+func (s *Server) pruneScans(ctx context.Context) {
+	if s.cfg == nil || s.cfg.KeepScans() <= 0 {
+		return
+	}
+	running := s.running.Load()
+	scans, files, err := db.PruneScans(ctx, s.db, s.cfg.KeepScans(), func(id int64) bool { return id == running })
+	if err != nil {
+		log.Printf("[retention] prune failed: %v", err)
+		return
+	}
+	if scans > 0 || files > 0 {
+		log.Printf("[retention] deleted %d scans beyond the newest %d per folder and %d unreferenced files", scans, s.cfg.KeepScans(), files)
+	}
+	if scans > 0 && s.cfg.MaintainAfterRetention() {
+		if _, err := s.runMaintenance(ctx, db.MaintainOptions{}); err != nil && !errors.Is(err, errMaintenanceRunning) {
+			log.Printf("[maintain] after retention: %v", err)
+		}
+	}
+}
+
+// errMaintenanceRunning is returned by runMaintenance while another maintenance run is in progress.
+var errMaintenanceRunning = errors.New("database maintenance is already running")
+
+// runMaintenance runs db.Maintain unless another run is in progress, and logs the report.
+func (s *Server) runMaintenance(ctx context.Context, opts db.MaintainOptions) (*db.MaintenanceReport, error) {
+	if !s.maintain.CompareAndSwap(false, true) {
+		return nil, errMaintenanceRunning
+	}
+	defer s.maintain.Store(false)
+	rep, err := db.Maintain(ctx, s.db, opts)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[maintain] %d tables vacuumed (reindexed: %v) in %v; database %d -> %d bytes",
+		rep.Tables, rep.Reindexed, rep.Duration, rep.SizeBefore, rep.SizeAfter)
+	return rep, nil
+}
+
+// integrityPageData is the data for the scan integrity page.
+type integrityPageData struct {
+	Scan    *db.Scan
+	OK      bool
+	Current string // checksum recomputed from the ledger snapshot
+}
+
+// handleScanIntegrity recomputes a locked scan's checksum and reports whether it still matches the stored one.
+func (s *Server) handleScanIntegrity() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		ok, current, err := db.CheckScanChecksum(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			if errors.Is(err, db.ErrScanNotLocked) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			log.Printf("error: check scan %d checksum: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			log.Printf("warning: scan %d checksum mismatch: stored %s, current %s", scanID, *sn.Checksum, current)
+		}
+		s.renderPage(w, "layout.html", "integrity-content", integrityPageData{Scan: sn, OK: ok, Current: current})
+	}
+}
+
+// Near-duplicate image view: default and maximum Hamming distance between perceptual hashes, and groups shown.
+const (
+	similarDefaultDistance = 8
+	similarMaxDistance     = 20
+	similarGroupsLimit     = 200
+)
+
+// similarGroup is a set of visually similar images; at least two have different content.
+type similarGroup struct {
+	Files    []db.PHashFile
+	Metadata map[int64]db.ImageMetadata // file id -> dimensions and EXIF, when the metadata phase read it
+	Best     int64                      // copy the page's keeper rule keeps
+}
+
+// keepRuleOption is a keeper rule offered for near-duplicate groups (?keep=).
+type keepRuleOption struct {
+	Rule  db.KeeperRule
+	Label string
+}
+
+var (
+	similarImageRules = []keepRuleOption{
+		{db.KeeperHighestResolution, "Highest resolution"}, {db.KeeperIntactEXIF, "Intact EXIF"},
+		{db.KeeperOldest, "Oldest"}, {db.KeeperNewest, "Newest"}, {db.KeeperShortestPath, "Shortest path"},
+	}
+	similarMediaRules = []keepRuleOption{
+		{db.KeeperHighestBitrate, "Highest bitrate"}, {db.KeeperOldest, "Oldest"}, {db.KeeperNewest, "Newest"},
+		{db.KeeperShortestPath, "Shortest path"},
+	}
+)
+
+// keepRule returns the ?keep= rule of the request if it is one of options, else the first option's.
+func keepRule(r *http.Request, options []keepRuleOption) db.KeeperRule {
+	want := db.KeeperRule(r.URL.Query().Get("keep"))
+	for _, o := range options {
+		if o.Rule == want {
+			return want
+		}
+	}
+	return options[0].Rule
+}
+
+type similarPageData struct {
+	Scan        *db.Scan
+	Distance    int
+	MaxDistance int
+	Keep        db.KeeperRule // rule choosing each group's Best
+	Rules       []keepRuleOption
+	Groups      []similarGroup
+	Truncated   bool
+	ImageCount  int
+}
+
+// handleSimilarImages lists groups of visually similar images (perceptual hash within ?d= bits) that are not
+// all byte-identical. Groups of byte-identical files are already shown as duplicates.
+func (s *Server) handleSimilarImages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		distance := similarDefaultDistance
+		if v, err := strconv.Atoi(r.URL.Query().Get("d")); err == nil && v >= 0 && v <= similarMaxDistance {
+			distance = v
+		}
+		images, err := db.ListScanPHashes(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			log.Printf("error: perceptual hashes for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hashes := make([]uint64, len(images))
+		for i, img := range images {
+			hashes[i] = img.PHash
+		}
+		data := similarPageData{Scan: sn, Distance: distance, MaxDistance: similarMaxDistance, ImageCount: len(images),
+			Keep: keepRule(r, similarImageRules), Rules: similarImageRules}
+		for _, idx := range similarity.Group(hashes, distance) {
+			g := similarGroup{Files: make([]db.PHashFile, len(idx))}
+			contents := make(map[string]bool)
+			for i, j := range idx {
+				g.Files[i] = images[j]
+				contents[images[j].Hash] = true
+			}
+			if len(contents) < 2 && !contents[""] {
+				continue // all byte-identical: a plain duplicate group
+			}
+			if len(data.Groups) == similarGroupsLimit {
+				data.Truncated = true
+				break
+			}
+			data.Groups = append(data.Groups, g)
+		}
+		var ids []int64
+		for _, g := range data.Groups {
+			for _, f := range g.Files {
+				ids = append(ids, f.FileID)
+			}
+		}
+		meta, err := db.MetadataByFileID(ctx, s.dbForRead(), ids)
+		if err != nil {
+			log.Printf("error: image metadata for scan %d: %v", scanID, err)
+		}
+		for i := range data.Groups {
+			g := &data.Groups[i]
+			g.Metadata = meta
+			candidates := make([]db.KeeperCandidate, len(g.Files))
+			for j, f := range g.Files {
+				m := meta[f.FileID]
+				candidates[j] = db.KeeperCandidate{ID: f.FileID, Path: f.Path, MTime: f.MTime, Pixels: m.Pixels(), TakenAt: m.TakenAt, EXIF: m.HasEXIF()}
+			}
+			g.Best, _ = db.BestCopy(data.Keep, candidates)
+		}
+		s.renderPage(w, "layout.html", "similar-content", data)
+	}
+}
+
+// similarMediaGroup is a set of audio or video files that likely come from the same source.
+type similarMediaGroup struct {
+	Files         []db.MediaFile
+	Best          int64 // copy the page's keeper rule keeps
+	Confidence    int   // percent
+	Fingerprinted bool  // confidence based on audio fingerprints (otherwise duration only)
+}
+
+type similarMediaPageData struct {
+	Scan       *db.Scan
+	Keep       db.KeeperRule // rule choosing each group's Best
+	Rules      []keepRuleOption
+	Groups     []similarMediaGroup
+	Truncated  bool
+	MediaCount int
+}
+
+// handleSimilarMedia lists groups of audio/video files with matching duration (and audio fingerprint, when
+// available) that are not all byte-identical, each with a confidence score.
+func (s *Server) handleSimilarMedia() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		media, err := db.ListScanMedia(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			log.Printf("error: media for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items := make([]similarity.MediaItem, len(media))
+		for i, m := range media {
+			items[i] = similarity.MediaItem{Kind: m.Kind, Duration: m.Duration, Fingerprint: m.Fingerprint}
+		}
+		data := similarMediaPageData{Scan: sn, MediaCount: len(media), Keep: keepRule(r, similarMediaRules), Rules: similarMediaRules}
+		for _, mg := range similarity.GroupMedia(items) {
+			g := similarMediaGroup{Files: make([]db.MediaFile, len(mg.Members)), Confidence: int(mg.Confidence*100 + 0.5), Fingerprinted: mg.Fingerprinted}
+			contents := make(map[string]bool)
+			candidates := make([]db.KeeperCandidate, len(mg.Members))
+			for i, j := range mg.Members {
+				g.Files[i] = media[j]
+				contents[media[j].Hash] = true
+				candidates[i] = db.KeeperCandidate{ID: media[j].FileID, Path: media[j].Path, MTime: media[j].MTime, Bitrate: media[j].Bitrate()}
+			}
+			g.Best, _ = db.BestCopy(data.Keep, candidates)
+			if len(contents) < 2 && !contents[""] {
+				continue // all byte-identical: a plain duplicate group
+			}
+			if len(data.Groups) == similarGroupsLimit {
+				data.Truncated = true
+				break
+			}
+			data.Groups = append(data.Groups, g)
+		}
+		s.renderPage(w, "layout.html", "similar-media-content", data)
+	}
+}
+
+func parseScanID(idStr string) (int64, error) {
+	return strconv.ParseInt(idStr, 10, 64)
+}
+
+func (s *Server) handleScanRootsList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roots, err := db.ListScanRoots(r.Context(), s.dbForRead())
+		if err != nil {
+			log.Printf("error: list scan roots: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		roots = s.visibleRoots(r.Context(), roots)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(roots)
+	}
+}
+
+func (s *Server) handleScanRootsAdd() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "" && !strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") && !strings.Contains(r.Header.Get("Content-Type"), "multipart/form-data") {
+			http.Error(w, "expect form", http.StatusBadRequest)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			log.Printf("error: parse form (scan roots add): %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		path := s.containerPath(strings.TrimSpace(r.FormValue("path")))
+		if path == "" {
+			http.Error(w, "path required", http.StatusBadRequest)
+			return
+		}
+		results, err := rootlist.Import(r.Context(), s.db, []rootlist.Entry{{Line: 1, Path: path}})
+		if err != nil {
+			log.Printf("error: add scan root %q: %v", path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		switch res := results[0]; res.Status {
+		case rootlist.StatusError:
+			http.Error(w, s.hostPath(res.Path)+": "+res.Err.Error(), http.StatusBadRequest)
+			return
+		case rootlist.StatusAdded:
+			s.claimFolder(r.Context(), res.FolderID)
+		}
+		http.Redirect(w, r, "/scans", http.StatusSeeOther)
+	}
+}
+
+// browseData is the scan-root browser: the subdirectories of Path, or the browse roots when Path is empty.
+// Paths are host paths, like the ones typed into the add form.
+type browseData struct {
+	Path      string
+	Up        string // parent directory; "" with HasUp goes back to the list of browse roots
+	HasUp     bool
+	CanChoose bool // Path may be added as a scan root
+	Dirs      []browseDir
+	Error     string
+}
+
+type browseDir struct {
+	Name string
+	Path string
+	Root bool // already a scan root
+}
+
+// browseRoots returns the directories the scan-root browser may list (see config.EnvBrowseRoots).
+func (s *Server) browseRoots() []string {
+	if s.cfg == nil {
+		return []string{"/"}
+	}
+	return s.cfg.BrowseRoots()
+}
+
+// handleScanRootsBrowse lists the subdirectories of ?path= (a host path) for the add-root dialog, within the
+// browse roots only; system directories are left out. Without a path it starts at the only browse root, or
+// lists them. Admins only, since it shows the server's directories.
+func (s *Server) handleScanRootsBrowse() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		bases := s.browseRoots()
+		dir := s.containerPath(strings.TrimSpace(r.URL.Query().Get("path")))
+		if dir == "" && len(bases) == 1 {
+			dir = bases[0]
+		}
+		var data browseData
+		if dir == "" {
+			for _, b := range bases {
+				data.Dirs = append(data.Dirs, browseDir{Name: s.hostPath(b), Path: s.hostPath(b)})
+			}
+		} else {
+			dir = filepath.Clean(dir)
+			base := ""
+			for _, b := range bases {
+				if filepath.IsAbs(dir) && rootlist.Within(dir, b) && len(b) > len(base) {
+					base = b
+				}
+			}
+			if base == "" {
+				http.Error(w, "path is outside the browsable directories", http.StatusForbidden)
+				return
+			}
+			data.Path, data.CanChoose = s.hostPath(dir), !rootlist.IsSystemPath(dir)
+			if dir != base {
+				data.Up, data.HasUp = s.hostPath(filepath.Dir(dir)), true
+			} else {
+				data.HasUp = len(bases) > 1
+			}
+			names, err := rootlist.ListDirs(dir)
+			if err != nil {
+				data.Error = err.Error()
+			}
+			roots, err := db.ListFolders(r.Context(), s.dbForRead())
+			if err != nil {
+				log.Printf("error: list folders (browse): %v", err)
+			}
+			known := make(map[string]bool, len(roots))
+			for _, f := range roots {
+				known[f.Path] = true
+			}
+			for _, name := range names {
+				p := filepath.Join(dir, name)
+				data.Dirs = append(data.Dirs, browseDir{Name: name, Path: s.hostPath(p), Root: known[p]})
+			}
+		}
+		if r.Header.Get("HX-Request") == "" {
+			s.renderPage(w, "layout.html", "roots-browse", data)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		var buf bytes.Buffer
+		if err := s.tmpl.ExecuteTemplate(&buf, "roots-browse", data); err != nil {
+			log.Printf("error: roots browse fragment: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// handleScanRootOwner shares a root with every user (form shared=1) or makes it private to the signed-in user.
+// Another user's root never gets here (ownFolder), so a user can share their own roots and take shared ones.
+func (s *Server) handleScanRootOwner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := userFrom(r.Context())
+		if u == nil {
+			http.Error(w, "folders have no owners without "+config.EnvUserHeader, http.StatusBadRequest)
+			return
+		}
+		folderID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid root id", http.StatusBadRequest)
+			return
+		}
+		owner := &u.ID
+		if r.FormValue("shared") == "1" {
+			owner = nil
+		}
+		if err := db.SetFolderOwner(r.Context(), s.db, folderID, owner); err != nil {
+			log.Printf("error: set owner of folder %d: %v", folderID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans", http.StatusSeeOther)
+	}
+}
+
+// handleScanRootMove points a root at the path its files were moved to (form field path), keeping its files
+// and scan history so the next scan does not take them for new ones. Nothing is moved on disk.
+func (s *Server) handleScanRootMove() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid root id", http.StatusBadRequest)
+			return
+		}
+		path := s.containerPath(strings.TrimSpace(r.FormValue("path")))
+		if path == "" {
+			http.Error(w, "path required", http.StatusBadRequest)
+			return
+		}
+		moved, err := rootlist.Move(r.Context(), s.db, id, path, scan.FolderLockStaleAfter)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "root not found", http.StatusNotFound)
+			case errors.Is(err, db.ErrFolderLocked):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, rootlist.ErrInvalidRoot), errors.Is(err, db.ErrFolderPathTaken), errors.Is(err, db.ErrImportedFolder):
+				http.Error(w, s.hostPath(path)+": "+err.Error(), http.StatusBadRequest)
+			default:
+				log.Printf("error: move folder %d: %v", id, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		log.Printf("[scan] moved root %d to %s", id, moved)
+		http.Redirect(w, r, "/scans", http.StatusSeeOther)
+	}
+}
+
+// handleScanRootDisabled disables a root (disabled) or enables it again. Nothing is deleted: a disabled root
+// keeps its scans and files, but leaves All and the home page's folders and cannot be scanned.
+func (s *Server) handleScanRootDisabled(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid root id", http.StatusBadRequest)
+			return
+		}
+		ok, err := db.SetFolderDisabled(r.Context(), s.db, id, disabled)
+		if err != nil {
+			log.Printf("error: set folder %d disabled: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "root not found", http.StatusNotFound)
+			return
+		}
+		http.Redirect(w, r, "/scans", http.StatusSeeOther)
+	}
+}
+
+type usersPageData struct {
+	Users  []db.User
+	Me     *db.User
+	Admins []string // from DITTO_ADMINS: always admins, whatever their stored role
+}
+
+// handleUsers lists the users of a shared instance with their roles.
+func (s *Server) handleUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := db.ListUsers(r.Context(), s.dbForRead())
+		if err != nil {
+			log.Printf("error: list users: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := usersPageData{Users: users, Me: userFrom(r.Context())}
+		if s.cfg != nil {
+			data.Admins = s.cfg.Admins()
+		}
+		s.renderPage(w, "layout.html", "users-content", data)
+	}
+}
+
+// handleUserRole sets a user's role (form role=admin|viewer). Admins cannot demote themselves, so an
+// instance always keeps the admin who is making changes.
+func (s *Server) handleUserRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return
+		}
+		if me := userFrom(r.Context()); me != nil && me.ID == id {
+			http.Error(w, "you cannot change your own role", http.StatusBadRequest)
+			return
+		}
+		err = db.SetUserRole(r.Context(), s.db, id, db.Role(r.FormValue("role")))
+		if errors.Is(err, db.ErrUnknownRole) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("error: set role of user %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.users.Clear() // roles are cached with the users
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+	}
+}
+
+type apiTokensPageData struct {
+	Tokens   []db.APIToken
+	NewToken string // shown once, right after creation
+}
+
+// requireTokenAdmin answers 403 and returns false unless an admin manages tokens from the browser: a token
+// cannot mint or revoke tokens.
+func (s *Server) requireTokenAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if tokenFrom(r.Context()) != nil {
+		http.Error(w, "API tokens are managed from the web UI", http.StatusForbidden)
+		return false
+	}
+	return s.requireAdmin(w, r)
+}
+
+func (s *Server) renderAPITokens(w http.ResponseWriter, r *http.Request, newToken string) {
+	tokens, err := db.ListAPITokens(r.Context(), s.dbForRead())
+	if err != nil {
+		log.Printf("error: list API tokens: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.renderPage(w, "layout.html", "tokens-content", apiTokensPageData{Tokens: tokens, NewToken: newToken})
+}
+
+// handleAPITokens lists the API tokens with a form to create one. Admins only.
+func (s *Server) handleAPITokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireTokenAdmin(w, r) {
+			return
+		}
+		s.renderAPITokens(w, r, "")
+	}
+}
+
+// handleAPITokenCreate creates an API token (form: name, scope=read|admin) acting for the signed-in user,
+// and shows it once.
+func (s *Server) handleAPITokenCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireTokenAdmin(w, r) {
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var userID *int64
+		if u := userFrom(r.Context()); u != nil {
+			userID = &u.ID
+		}
+		token, _, err := db.CreateAPIToken(r.Context(), s.db, r.FormValue("name"), db.TokenScope(r.FormValue("scope")), userID)
+		if err != nil {
+			log.Printf("error: create API token: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.renderAPITokens(w, r, token)
+	}
+}
+
+// handleAPITokenDelete revokes an API token.
+func (s *Server) handleAPITokenDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireTokenAdmin(w, r) {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid token id", http.StatusBadRequest)
+			return
+		}
+		if err := db.DeleteAPIToken(r.Context(), s.db, id); err != nil {
+			log.Printf("error: delete API token %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/tokens", http.StatusSeeOther)
+	}
+}
+
+type rootsImportData struct {
+	Results               []rootlist.Result
+	Added, Exists, Failed int
+}
+
+// handleScanRootsImport registers many roots at once from an uploaded file ("list") or pasted text ("paths"):
+// one path per line, or CSV with a path column and optional per-root settings (see rootlist.Parse).
+// It renders one result per listed root.
+func (s *Server) handleScanRootsImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(maxImportMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var in io.Reader = strings.NewReader(r.FormValue("paths"))
+		if file, _, err := r.FormFile("list"); err == nil {
+			defer file.Close()
+			in = file
+		}
+		entries, err := rootlist.Parse(in)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(entries) == 0 {
+			http.Error(w, "no paths listed", http.StatusBadRequest)
+			return
+		}
+		results, err := rootlist.Import(r.Context(), s.db, entries)
+		for _, res := range results {
+			if res.Status == rootlist.StatusAdded {
+				s.claimFolder(r.Context(), res.FolderID)
+			}
+		}
+		if err != nil {
+			log.Printf("error: import scan roots: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := rootsImportData{Results: results}
+		data.Added, data.Exists, data.Failed = rootlist.Counts(results)
+		log.Printf("[roots] import: %d added, %d already present, %d failed", data.Added, data.Exists, data.Failed)
+		s.renderPage(w, "layout.html", "roots-import-content", data)
+	}
+}
+
+// excludesPageData is the data for a scan root's exclude list page.
+type excludesPageData struct {
+	Root         *db.ScanRoot
+	Excludes     []db.FolderExclude // managed here
+	FilePatterns []string           // from the root's .dittoignore (read-only here)
+	FileError    string             // set when .dittoignore could not be read
+	Defaults     []string           // built-in patterns, always applied
+}
+
+// handleScanRootExcludes shows a scan root's exclude patterns: those managed in the web UI (editable), the
+// root's .dittoignore and the built-in defaults.
+func (s *Server) handleScanRootExcludes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		root, err := db.GetScanRoot(r.Context(), s.dbForRead(), id)
+		if err != nil {
+			http.Error(w, "root not found", http.StatusNotFound)
+			return
+		}
+		list, err := db.ListFolderExcludes(r.Context(), s.dbForRead(), id)
+		if err != nil {
+			log.Printf("error: list excludes for root %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := excludesPageData{Root: root, Excludes: list, Defaults: scan.DefaultExcludePatterns()}
+		if data.FilePatterns, err = scan.LoadExcludeFile(scan.ExcludeFileInRoot(root.Path)); err != nil {
+			data.FileError = err.Error()
+		}
+		s.renderPage(w, "layout.html", "excludes-content", data)
+	}
+}
+
+// handleScanRootExcludeAdd adds the form's pattern to a scan root's exclude list. It applies from the next scan.
+func (s *Server) handleScanRootExcludeAdd() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := db.GetScanRoot(r.Context(), s.dbForRead(), id); err != nil {
+			http.Error(w, "root not found", http.StatusNotFound)
+			return
+		}
+		if err := db.AddFolderExclude(r.Context(), s.db, id, r.FormValue("pattern")); err != nil {
+			if errors.Is(err, db.ErrInvalidExcludePattern) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("error: add exclude for root %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/roots/"+strconv.FormatInt(id, 10)+"/excludes", http.StatusSeeOther)
+	}
+}
+
+// handleScanRootExcludeUpdate replaces the pattern of one of a scan root's excludes.
+func (s *Server) handleScanRootExcludeUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		excludeID, err := strconv.ParseInt(r.PathValue("exclude"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid exclude id", http.StatusBadRequest)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.UpdateFolderExclude(r.Context(), s.db, id, excludeID, r.FormValue("pattern")); err != nil {
+			if errors.Is(err, db.ErrInvalidExcludePattern) || errors.Is(err, db.ErrExcludePatternExists) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("error: update exclude %d: %v", excludeID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/roots/"+strconv.FormatInt(id, 10)+"/excludes", http.StatusSeeOther)
+	}
+}
+
+// handleScanRootExcludeDelete removes one of a scan root's excludes.
+func (s *Server) handleScanRootExcludeDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		excludeID, err := strconv.ParseInt(r.PathValue("exclude"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid exclude id", http.StatusBadRequest)
+			return
+		}
+		if err := db.DeleteFolderExclude(r.Context(), s.db, id, excludeID); err != nil {
+			log.Printf("error: delete exclude %d: %v", excludeID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/scans/roots/"+strconv.FormatInt(id, 10)+"/excludes", http.StatusSeeOther)
+	}
+}
+
+// handleScanRootSettings updates a folder's I/O settings: max_read_mbps (MB/s read cap for hashing, empty or 0 = unlimited)
+// and low_priority (checkbox: run workers with lowered CPU/I/O priority), max_depth and max_files (walk limits, empty or
+// 0 = the DITTO_SCAN_MAX_DEPTH / DITTO_SCAN_MAX_FILES defaults), plus its similarity, one-filesystem, offline media,
+// symlink (skip, record or follow) and network share (auto, on or off) settings; an empty mode keeps the current one.
+func (s *Server) handleScanRootSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var maxBytes int64
+		if v := strings.TrimSpace(r.FormValue("max_read_mbps")); v != "" {
+			mbps, err := strconv.ParseFloat(v, 64)
+			if err != nil || mbps < 0 {
+				http.Error(w, "invalid max_read_mbps", http.StatusBadRequest)
+				return
+			}
+			maxBytes = int64(mbps * 1024 * 1024)
+		}
+		lowPriority := r.FormValue("low_priority") != ""
+		var maxDepth int
+		if v := strings.TrimSpace(r.FormValue("max_depth")); v != "" {
+			if maxDepth, err = strconv.Atoi(v); err != nil || maxDepth < 0 {
+				http.Error(w, "invalid max_depth", http.StatusBadRequest)
+				return
+			}
+		}
+		var maxFiles int64
+		if v := strings.TrimSpace(r.FormValue("max_files")); v != "" {
+			if maxFiles, err = strconv.ParseInt(v, 10, 64); err != nil || maxFiles < 0 {
+				http.Error(w, "invalid max_files", http.StatusBadRequest)
+				return
+			}
+		}
+		similarImages := r.FormValue("similar_images") != ""
+		similarMedia := r.FormValue("similar_media") != ""
+		photoMetadata := r.FormValue("photo_metadata") != ""
+		oneFileSystem := r.FormValue("one_file_system") != ""
+		archives := r.FormValue("archives") != ""
+		mediaLabel := strings.TrimSpace(r.FormValue("media_label"))
+		mediaImage := strings.TrimSpace(r.FormValue("media_image"))
+		if mediaImage != "" && mediaLabel == "" {
+			http.Error(w, "media_image requires media_label", http.StatusBadRequest)
+			return
+		}
+		symlinks := r.FormValue("symlinks")
+		if symlinks != "" && !db.ValidSymlinkMode(symlinks) {
+			http.Error(w, db.ErrInvalidSymlinkMode.Error(), http.StatusBadRequest)
+			return
+		}
+		networkFS := r.FormValue("network_fs")
+		if networkFS != "" && !db.ValidNetworkFSMode(networkFS) {
+			http.Error(w, db.ErrInvalidNetworkFSMode.Error(), http.StatusBadRequest)
+			return
+		}
+		unicodeForm := r.FormValue("unicode_form")
+		if unicodeForm != "" && !pathnorm.Valid(unicodeForm) {
+			http.Error(w, db.ErrInvalidUnicodeForm.Error(), http.StatusBadRequest)
+			return
+		}
+		folder, err := db.GetFolder(r.Context(), s.db, id)
+		if err != nil {
+			http.Error(w, "root not found", http.StatusNotFound)
+			return
+		}
+		if err := db.UpdateFolderIOSettings(r.Context(), s.db, id, maxBytes, lowPriority); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.UpdateFolderWalkLimits(r.Context(), s.db, id, maxDepth, maxFiles); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.UpdateFolderSimilarImages(r.Context(), s.db, id, similarImages); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.UpdateFolderSimilarMedia(r.Context(), s.db, id, similarMedia); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.UpdateFolderPhotoMetadata(r.Context(), s.db, id, photoMetadata); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.UpdateFolderOneFileSystem(r.Context(), s.db, id, oneFileSystem); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.UpdateFolderArchives(r.Context(), s.db, id, archives); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.UpdateFolderMedia(r.Context(), s.db, id, mediaLabel, mediaImage); err != nil {
+			log.Printf("error: update folder %d settings: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if symlinks != "" {
+			if err := db.UpdateFolderSymlinks(r.Context(), s.db, id, symlinks); err != nil {
+				log.Printf("error: update folder %d settings: %v", id, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if networkFS != "" {
+			if err := db.UpdateFolderNetworkFS(r.Context(), s.db, id, networkFS); err != nil {
+				log.Printf("error: update folder %d settings: %v", id, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if unicodeForm != "" && unicodeForm != folder.UnicodeForm {
+			n, err := db.UpdateFolderUnicodeForm(r.Context(), s.db, id, unicodeForm)
+			if err != nil {
+				log.Printf("error: update folder %d settings: %v", id, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			log.Printf("[scan] folder %d: paths now stored as %s, %d renamed", id, unicodeForm, n)
+		}
+		http.Redirect(w, r, "/scans", http.StatusSeeOther)
+	}
+}
+
+// handleVolumes lists the physical volumes (by filesystem UUID) scans were taken from, with last-connected times.
+func (s *Server) handleVolumes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vols, err := db.ListVolumes(r.Context(), s.dbForRead())
+		if err != nil {
+			log.Printf("error: list volumes: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderPage(w, "layout.html", "volumes-content", vols)
+	}
+}
+
+// handleVolumeSettings includes or excludes a volume's scans from cross-root ("All") duplicate analysis.
+func (s *Server) handleVolumeSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ok, err := db.SetVolumeIncludeInAll(r.Context(), s.db, id, r.FormValue("include_in_all") != "")
+		if err != nil {
+			log.Printf("error: update volume %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "volume not found", http.StatusNotFound)
+			return
+		}
+		http.Redirect(w, r, "/volumes", http.StatusSeeOther)
+	}
+}
+
+// maxImportMemory is the part of an uploaded manifest kept in memory; the rest spills to a temporary file.
+const maxImportMemory = 32 << 20
+
+// symlinkListLimit caps the symlinks listed on a scan's symlinks page.
+const symlinkListLimit = 1000
+
+type symlinksPageData struct {
+	Scan      *db.Scan
+	Links     []db.Symlink
+	Total     int64
+	Truncated bool
+}
+
+// handleScanSymlinks lists the symlinks a scan recorded (folders in "record" mode), with their targets.
+func (s *Server) handleScanSymlinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		total, err := db.CountScanSymlinks(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			log.Printf("error: count symlinks scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		links, err := db.ScanSymlinks(ctx, s.dbForRead(), scanID, symlinkListLimit, 0)
+		if err != nil {
+			log.Printf("error: list symlinks scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderPage(w, "layout.html", "symlinks-content", symlinksPageData{Scan: sn, Links: links, Total: total, Truncated: total > int64(len(links))})
+	}
+}
+
+type slowDirsPageData struct {
+	Scan *db.Scan
+	Dirs []db.SlowDir
+}
+
+// handleScanSlowDirs lists the directories a scan found slow, had to retry, or could not list (network shares).
+func (s *Server) handleScanSlowDirs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		dirs, err := db.ScanSlowDirs(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			log.Printf("error: list slow dirs scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderPage(w, "layout.html", "slow-dirs-content", slowDirsPageData{Scan: sn, Dirs: dirs})
+	}
+}
+
+// scanErrorListLimit caps the errors listed on a scan's errors page.
+const scanErrorListLimit = 1000
+
+type scanErrorsPageData struct {
+	Scan       *db.Scan
+	Errors     []db.ScanError
+	Total      int64
+	HashErrors int64
+	Truncated  bool
+}
+
+// handleScanErrors lists the errors met while walking and hashing the scan.
+func (s *Server) handleScanErrors() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		total, hashErrs, err := db.CountScanErrors(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			log.Printf("error: count errors scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list, err := db.ListScanErrors(ctx, s.dbForRead(), scanID, scanErrorListLimit)
+		if err != nil {
+			log.Printf("error: list errors scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderPage(w, "layout.html", "scan-errors-content", scanErrorsPageData{Scan: sn, Errors: list, Total: total, HashErrors: hashErrs, Truncated: total > int64(len(list))})
+	}
+}
+
+// handleScanErrorsRetry clears the scan's hash errors, returns their files to the hash queue and queues the scan,
+// so the hash phase tries them again. Errors that happen again are recorded again.
+func (s *Server) handleScanErrorsRetry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		if _, err := db.GetScan(ctx, s.dbForRead(), scanID); err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		n, err := db.ClearScanHashErrors(ctx, s.db, scanID)
+		if err != nil {
+			log.Printf("error: clear hash errors for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := db.ClearScanHashPause(ctx, s.db, scanID); err != nil {
+			log.Printf("error: clear pause for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.enqueueScan(ctx, scanID); err != nil {
+			log.Printf("error: queue scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[hash] scan %d: retrying %d failed file(s)", scanID, n)
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+	}
+}
+
+// bitrotListLimit caps the mismatches listed on a verification scan's bit-rot page.
+const bitrotListLimit = 1000
+
+type bitrotPageData struct {
+	Scan      *db.Scan
+	Findings  []db.BitrotFinding
+	Total     int64
+	Truncated bool
+}
+
+// handleScanBitrot lists the files a verification scan found changed without a size or mtime change.
+func (s *Server) handleScanBitrot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scanID, err := parseScanID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		total, err := db.CountBitrotFindings(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			log.Printf("error: count bit-rot findings scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list, err := db.ListBitrotFindings(ctx, s.dbForRead(), scanID, bitrotListLimit)
+		if err != nil {
+			log.Printf("error: list bit-rot findings scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderPage(w, "layout.html", "bitrot-content", bitrotPageData{Scan: sn, Findings: list, Total: total, Truncated: total > int64(len(list))})
+	}
+}
+
+// compareListLimit caps the matching files listed on the compare page.
+const compareListLimit = 500
+
+type importsPageData struct {
+	Imports []db.Scan
+	Local   []db.Scan // scans of real folders, to compare an import against
+}
+
+func (s *Server) handleImports() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		imports, err := db.ListImportScans(ctx, s.dbForRead())
+		if err != nil {
+			log.Printf("error: list imports: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		imports = s.visibleScans(ctx, imports)
+		scans, _ := db.ListScansRecent(ctx, s.dbForRead(), homeListScansLimit)
+		scans = s.visibleScans(ctx, scans)
+		var local []db.Scan
+		for _, sc := range scans {
+			if !strings.HasPrefix(sc.RootPath, db.ImportFolderPrefix) {
+				local = append(local, sc)
+			}
+		}
+		s.renderPage(w, "layout.html", "imports-content", importsPageData{Imports: imports, Local: local})
+	}
+}
+
+// handleImportsUpload stores an uploaded external manifest (CSV, sha256sum/rclone hashsum, or ditto JSON) as a new import scan.
+func (s *Server) handleImportsUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, status, err := s.importUpload(r); err != nil {
+			http.Error(w, err.Error(), status)
 			return
 		}
-		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+		http.Redirect(w, r, "/imports", http.StatusSeeOther)
 	}
 }
 
-func (s *Server) handleScanContinue() http.HandlerFunc {
+// importResponse is the JSON answer of POST /api/v1/imports.
+type importResponse struct {
+	ScanID int64 `json:"scan_id"`
+	Files  int   `json:"files"`
+}
+
+// handleAPIImport stores a catalog uploaded by "ditto agent" (or any client) as an import, like the Imports page.
+func (s *Server) handleAPIImport() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		// A catalog of a whole machine takes longer to upload than the server's read timeout.
+		_ = http.NewResponseController(w).SetReadDeadline(time.Time{})
+		res, status, err := s.importUpload(r)
+		if err != nil {
+			http.Error(w, err.Error(), status)
 			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+// importUpload stores the multipart upload of r (fields name, manifest and optional host) as a new scan of the
+// import name, given to the request's user. On error it returns the HTTP status to answer with.
+func (s *Server) importUpload(r *http.Request) (*importResponse, int, error) {
+	if err := r.ParseMultipartForm(maxImportMemory); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		return nil, http.StatusBadRequest, errors.New("name required")
+	}
+	file, _, err := r.FormFile("manifest")
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("manifest file required")
+	}
+	defer file.Close()
+	entries, err := manifest.ParseExternal(file)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	scanID, err := manifest.Import(r.Context(), s.db, name, strings.TrimSpace(r.FormValue("host")), entries)
+	if err != nil {
+		log.Printf("error: import manifest %q: %v", name, err)
+		return nil, http.StatusInternalServerError, err
+	}
+	// The import's folder is new and already has its scan, so it is given to the user directly.
+	if u := userFrom(r.Context()); u != nil {
+		if sn, err := db.GetScan(r.Context(), s.db, scanID); err == nil {
+			if err := db.SetFolderOwner(r.Context(), s.db, sn.FolderID, &u.ID); err != nil {
+				log.Printf("error: give import %q to user %q: %v", name, u.Name, err)
+			}
+		}
+	}
+	log.Printf("[import] %q: %d files as scan %d", name, len(entries), scanID)
+	return &importResponse{ScanID: scanID, Files: len(entries)}, 0, nil
+}
+
+type comparePageData struct {
+	Scan       *db.Scan
+	Other      *db.Scan
+	Comparison *db.ScanComparison
+	Limit      int
+}
+
+// handleScanCompare lists files of a scan whose content also exists in another scan (?with=), typically an import.
+func (s *Server) handleScanCompare() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		scanID, err := parseScanID(r.PathValue("id"))
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
 			return
 		}
-		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		otherID, err := parseScanID(r.URL.Query().Get("with"))
 		if err != nil {
+			http.Error(w, "invalid with", http.StatusBadRequest)
+			return
+		}
+		if !s.scanVisible(r.Context(), otherID) {
 			http.Error(w, "scan not found", http.StatusNotFound)
 			return
 		}
-		// Already fully complete: just go to progress page
-		if sn.CompletedAt != nil && sn.HashCompletedAt != nil {
-			http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+		ctx := r.Context()
+		sn, err := db.GetScan(ctx, s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
 			return
 		}
-		// Return any files stuck in 'hashing' (from a cancelled run) to the queue so they get retried.
-		if err := db.ResetHashStatusHashingToPending(r.Context(), s.db, scanID); err != nil {
-			log.Printf("error: reset hash status for scan %d: %v", scanID, err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		other, err := db.GetScan(ctx, s.dbForRead(), otherID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
 			return
 		}
-		select {
-		case s.scanQueue <- scanID:
-			// queued
-		default:
-			http.Error(w, "scan queue is full, try again later", http.StatusServiceUnavailable)
+		c, err := db.CompareScans(ctx, s.dbForRead(), scanID, otherID, compareListLimit)
+		if err != nil {
+			log.Printf("error: compare scan %d with %d: %v", scanID, otherID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10), http.StatusSeeOther)
+		s.renderPage(w, "layout.html", "compare-content", comparePageData{Scan: sn, Other: other, Comparison: c, Limit: compareListLimit})
 	}
 }
 
-func (s *Server) handleScanProgress() http.HandlerFunc {
+const diagnosticsRecentJobs = 10
+
+type diagnosticsPageData struct {
+	Usage       limits.Usage
+	DBStats     sql.DBStats
+	DBSize      int64
+	JobCounts   map[string]int64 // jobs per status
+	RecentJobs  []db.Job
+	CPUPercent  string
+	Maintenance *db.MaintenanceReport // set right after a maintenance run
+}
+
+func (s *Server) diagnosticsData(ctx context.Context) diagnosticsPageData {
+	u := limits.Current()
+	data := diagnosticsPageData{Usage: u, CPUPercent: strconv.FormatFloat(u.CPUPercent, 'f', 1, 64)}
+	if s.db != nil {
+		data.DBStats = s.db.Stats()
+		data.DBSize, _ = db.DatabaseSize(ctx, s.dbForRead())
+		data.JobCounts, _ = db.CountJobsByStatus(ctx, s.dbForRead())
+		data.RecentJobs, _ = db.ListRecentJobs(ctx, s.dbForRead(), diagnosticsRecentJobs)
+	}
+	return data
+}
+
+// handleDiagnostics shows the process's resource use against the configured self-limits.
+func (s *Server) handleDiagnostics() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.PathValue("id")
-		if idStr == "" {
-			http.Error(w, "id required", http.StatusBadRequest)
-			return
-		}
-		scanID, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			http.Error(w, "invalid id", http.StatusBadRequest)
-			return
-		}
-		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
-		if err != nil {
-			http.Error(w, "scan not found", http.StatusNotFound)
-			return
-		}
-		s.renderPage(w, "layout.html", "scan-progress-content", sn)
+		s.renderPage(w, "layout.html", "diagnostics-content", s.diagnosticsData(r.Context()))
 	}
 }
 
-func (s *Server) handleScanStatus() http.HandlerFunc {
+// handleMaintain runs VACUUM/ANALYZE (and REINDEX when the "reindex" field is set) and shows the report on
+// the diagnostics page. Reindexing blocks writes, so it is refused while a scan is being processed.
+func (s *Server) handleMaintain() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.PathValue("id")
-		if idStr == "" {
-			http.Error(w, "id required", http.StatusBadRequest)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		scanID, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			http.Error(w, "invalid id", http.StatusBadRequest)
+		opts := db.MaintainOptions{Reindex: r.FormValue("reindex") != ""}
+		if opts.Reindex && s.running.Load() != 0 {
+			http.Error(w, "a scan is running; reindex when it has finished", http.StatusConflict)
 			return
 		}
-		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			_, _ = w.Write([]byte("<p>Scan not found.</p>"))
+		rep, err := s.runMaintenance(r.Context(), opts)
+		if errors.Is(err, errMaintenanceRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
 			return
 		}
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		var buf bytes.Buffer
-		if err := s.tmpl.ExecuteTemplate(&buf, "scan-status-fragment", sn); err != nil {
-			log.Printf("error: scan status fragment: %v", err)
+		if err != nil {
+			log.Printf("error: maintenance: %v", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		_, _ = w.Write(buf.Bytes())
+		data := s.diagnosticsData(r.Context())
+		data.Maintenance = rep
+		s.renderPage(w, "layout.html", "diagnostics-content", data)
 	}
 }
 
-type duplicatesPageData struct {
-	ScanID  int64
-	ByHash  []db.DuplicateGroupByHash
-	ByInode []db.DuplicateGroupByInode
+const (
+	shareDefaultDays     = 7
+	shareMaxDays         = 90
+	shareReportGroups    = 100 // duplicate groups shown in a shared report
+	shareReportGroupPath = 20  // paths shown per group in a shared report
+)
+
+// shareLinksPageData is the data for a scan's share-link management page.
+type shareLinksPageData struct {
+	Scan   *db.Scan
+	Links  []db.ShareLink
+	NewURL string // set right after creating a link: the only time its token is shown
 }
 
-type hashGroupData struct {
-	ScanID           int64
-	Hash             string
-	Files            []db.File
-	RootPathByScanID map[int64]string // when ScanID is 0 (All), root path per scan for display
+// shareReportData is the data for the public shared report.
+type shareReportData struct {
+	Scan    *db.Scan
+	Link    *db.ShareLink
+	Summary *db.ScanSummary
+	Groups  []GroupWithPaths // duplicates kind only
 }
 
-type inodeGroupData struct {
-	ScanID   int64
-	Inode    int64
-	DeviceID *int64
-	Files    []db.File
+func (s *Server) renderShareLinks(w http.ResponseWriter, r *http.Request, scanID int64, newURL string) {
+	sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+	if err != nil {
+		http.Error(w, "scan not found", http.StatusNotFound)
+		return
+	}
+	links, err := db.ListShareLinks(r.Context(), s.dbForRead(), scanID)
+	if err != nil {
+		log.Printf("error: list share links for scan %d: %v", scanID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.renderPage(w, "layout.html", "share-links-content", shareLinksPageData{Scan: sn, Links: links, NewURL: newURL})
 }
 
-func (s *Server) handleDuplicates() http.HandlerFunc {
+// handleShareLinks lists the scan's share links with a form to create one.
+func (s *Server) handleShareLinks() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		scanID, err := parseScanID(r.PathValue("id"))
 		if err != nil {
-			log.Printf("error: duplicates parse scan id %q: %v", r.PathValue("id"), err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if _, err := db.GetScan(r.Context(), s.dbForRead(), scanID); err != nil {
-			http.Error(w, "scan not found", http.StatusNotFound)
+			http.Error(w, "invalid id", http.StatusBadRequest)
 			return
 		}
-		byHash, _ := db.DuplicateGroupsByHash(r.Context(), s.dbForRead(), scanID)
-		byInode, _ := db.DuplicateGroupsByInode(r.Context(), s.dbForRead(), scanID)
-		s.renderPage(w, "layout.html", "duplicates-content", duplicatesPageData{ScanID: scanID, ByHash: byHash, ByInode: byInode})
+		s.renderShareLinks(w, r, scanID, "")
 	}
 }
 
-func (s *Server) handleDuplicateHashGroup() http.HandlerFunc {
+// handleShareLinkCreate creates an expiring share link (form: kind, ext, days) for a scan whose hash phase is
+// complete, and shows its URL once.
+func (s *Server) handleShareLinkCreate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		scanID, err := parseScanID(r.PathValue("id"))
 		if err != nil {
-			log.Printf("error: duplicate hash group parse scan id %q: %v", r.PathValue("id"), err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, "invalid id", http.StatusBadRequest)
 			return
 		}
-		hash := r.PathValue("hash")
-		if hash == "" {
-			http.Error(w, "hash required", http.StatusBadRequest)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		ctx := r.Context()
-		database := s.dbForRead()
-		if scanID == 0 {
-			// "All (latest per folder)": use latest scan per root
-			scans, _ := db.ListScansRecent(ctx, database, homeListScansLimit)
-			seen := make(map[string]bool)
-			var scanIDs []int64
-			for _, sc := range scans {
-				if seen[sc.RootPath] {
-					continue
-				}
-				seen[sc.RootPath] = true
-				scanIDs = append(scanIDs, sc.ID)
-			}
-			files, _ := db.FilesInHashGroupAcrossScans(ctx, database, scanIDs, hash)
-			rootByScan := make(map[int64]string)
-			for _, sc := range scans {
-				if _, ok := rootByScan[sc.ID]; !ok {
-					rootByScan[sc.ID] = sc.RootPath
-				}
-			}
-			s.renderPage(w, "layout.html", "duplicate-group-content", hashGroupData{ScanID: 0, Hash: hash, Files: files, RootPathByScanID: rootByScan})
+		sn, err := db.GetScan(r.Context(), s.dbForRead(), scanID)
+		if err != nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
 			return
 		}
-		if _, err := db.GetScan(ctx, database, scanID); err != nil {
-			http.Error(w, "scan not found", http.StatusNotFound)
+		if sn.HashCompletedAt == nil {
+			http.Error(w, "the scan's hash phase has not completed", http.StatusConflict)
 			return
 		}
-		files, err := db.FilesInHashGroup(r.Context(), database, scanID, hash)
+		days := shareDefaultDays
+		if v := r.FormValue("days"); v != "" {
+			if days, err = strconv.Atoi(v); err != nil || days < 1 || days > shareMaxDays {
+				http.Error(w, fmt.Sprintf("days must be between 1 and %d", shareMaxDays), http.StatusBadRequest)
+				return
+			}
+		}
+		token, _, err := db.CreateShareLink(r.Context(), s.db, scanID, r.FormValue("kind"), r.FormValue("ext"), time.Duration(days)*24*time.Hour)
 		if err != nil {
-			log.Printf("error: files in hash group scan=%d hash=%s: %v", scanID, hash, err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Printf("error: create share link for scan %d: %v", scanID, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		s.renderPage(w, "layout.html", "duplicate-group-content", hashGroupData{ScanID: scanID, Hash: hash, Files: files})
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		s.renderShareLinks(w, r, scanID, scheme+"://"+r.Host+"/share/"+token)
 	}
 }
 
-func (s *Server) handleDuplicateInodeGroup() http.HandlerFunc {
+// handleShareLinkDelete revokes a share link.
+func (s *Server) handleShareLinkDelete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		scanID, err := parseScanID(r.PathValue("id"))
 		if err != nil {
-			log.Printf("error: duplicate inode group parse scan id %q: %v", r.PathValue("id"), err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, "invalid id", http.StatusBadRequest)
 			return
 		}
-		inode, err := strconv.ParseInt(r.URL.Query().Get("inode"), 10, 64)
+		linkID, err := strconv.ParseInt(r.PathValue("link"), 10, 64)
 		if err != nil {
-			http.Error(w, "inode required", http.StatusBadRequest)
-			return
-		}
-		var deviceID *int64
-		if d := r.URL.Query().Get("device_id"); d != "" {
-			v, err := strconv.ParseInt(d, 10, 64)
-			if err != nil {
-				http.Error(w, "invalid device_id", http.StatusBadRequest)
-				return
-			}
-			deviceID = &v
-		}
-		if _, err := db.GetScan(r.Context(), s.dbForRead(), scanID); err != nil {
-			http.Error(w, "scan not found", http.StatusNotFound)
+			http.Error(w, "invalid link id", http.StatusBadRequest)
 			return
 		}
-		files, err := db.FilesInInodeGroup(r.Context(), s.dbForRead(), scanID, inode, deviceID)
-		if err != nil {
-			log.Printf("error: files in inode group scan=%d: %v", scanID, err)
+		if err := db.DeleteShareLink(r.Context(), s.db, scanID, linkID); err != nil {
+			log.Printf("error: delete share link %d: %v", linkID, err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		s.renderPage(w, "layout.html", "duplicate-inode-group-content", inodeGroupData{ScanID: scanID, Inode: inode, DeviceID: deviceID, Files: files})
+		http.Redirect(w, r, "/scans/"+strconv.FormatInt(scanID, 10)+"/share", http.StatusSeeOther)
 	}
 }
 
-func parseScanID(idStr string) (int64, error) {
-	return strconv.ParseInt(idStr, 10, 64)
-}
-
-func (s *Server) handleScanRootsList() http.HandlerFunc {
+// handleSharedReport renders the read-only report a share link grants, in a layout without navigation.
+// Unknown, revoked, and expired tokens all get the same 404.
+func (s *Server) handleSharedReport() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		roots, err := db.ListScanRoots(r.Context(), s.dbForRead())
+		ctx := r.Context()
+		link, err := db.GetShareLink(ctx, s.dbForRead(), r.PathValue("token"))
 		if err != nil {
-			log.Printf("error: list scan roots: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(roots)
-	}
-}
-
-func (s *Server) handleScanRootsAdd() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Content-Type") != "" && !strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") && !strings.Contains(r.Header.Get("Content-Type"), "multipart/form-data") {
-			http.Error(w, "expect form", http.StatusBadRequest)
-			return
-		}
-		if err := r.ParseForm(); err != nil {
-			log.Printf("error: parse form (scan roots add): %v", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			if !errors.Is(err, db.ErrShareLinkInvalid) {
+				log.Printf("error: shared report: %v", err)
+			}
+			http.Error(w, "this link is invalid or has expired", http.StatusNotFound)
 			return
 		}
-		path := strings.TrimSpace(r.FormValue("path"))
-		if path == "" {
-			http.Error(w, "path required", http.StatusBadRequest)
+		sn, err := db.GetScan(ctx, s.dbForRead(), link.ScanID)
+		if err != nil {
+			http.Error(w, "this link is invalid or has expired", http.StatusNotFound)
 			return
 		}
-		_, err := db.AddScanRoot(r.Context(), s.db, path)
+		sum, err := db.ScanSummaryOrRefresh(ctx, s.db, link.ScanID)
 		if err != nil {
-			log.Printf("error: add scan root %q: %v", path, err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Printf("error: shared report summary for scan %d: %v", link.ScanID, err)
+			http.Error(w, "report unavailable", http.StatusInternalServerError)
 			return
 		}
-		http.Redirect(w, r, "/scans", http.StatusSeeOther)
+		data := shareReportData{Scan: sn, Link: link, Summary: sum}
+		if link.Kind == db.ShareKindDuplicates {
+			groups, err := db.DuplicateGroupsByHashWithExt(ctx, s.dbForRead(), link.ScanID, link.Ext, shareReportGroups)
+			if err != nil {
+				log.Printf("error: shared report groups for scan %d: %v", link.ScanID, err)
+				http.Error(w, "report unavailable", http.StatusInternalServerError)
+				return
+			}
+			for _, g := range groups {
+				files, _ := db.FilesInHashGroupLimit(ctx, s.dbForRead(), link.ScanID, g.Hash, shareReportGroupPath)
+				gp := GroupWithPaths{Hash: g.Hash, Count: g.Count, Size: g.Size, PathsTruncated: int64(len(files)) < g.Count}
+				if g.Count > 0 {
+					gp.PerFileSize = g.Size / g.Count
+				}
+				for _, f := range files {
+					gp.Paths = append(gp.Paths, f.Path)
+				}
+				data.Groups = append(data.Groups, gp)
+			}
+		}
+		w.Header().Set("X-Robots-Tag", "noindex")
+		s.renderPage(w, "share-layout.html", "share-report-content", data)
 	}
 }
 
@@ -650,7 +5026,7 @@ func (s *Server) Run(ctx context.Context) error {
 	go s.runScanWorker(ctx)
 	srv := &http.Server{
 		Addr:         ":" + strconv.Itoa(s.cfg.Port()),
-		Handler:      s.mux,
+		Handler:      s.withReadTimeout(s.withUser(s.withGroupInvalidation(s.mux))),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
@@ -667,43 +5043,176 @@ func (s *Server) Run(ctx context.Context) error {
 	return err
 }
 
-// runScanWorker processes one scan at a time from the queue. Scans are serialized to avoid SQLITE_BUSY.
+// enqueueScan adds a job for the scan to the persistent queue (unless one is already pending or running)
+// and wakes the worker.
+func (s *Server) enqueueScan(ctx context.Context, scanID int64) error {
+	if _, err := db.EnqueueScanJob(ctx, s.db, scanID); err != nil {
+		return err
+	}
+	select {
+	case s.jobWake <- struct{}{}:
+	default: // a wake-up is already pending
+	}
+	return nil
+}
+
+// runScanWorker processes one job at a time from the jobs table. Jobs a previous process left running
+// (e.g. the container restarted mid-scan) are requeued first, so they resume where they stopped.
 func (s *Server) runScanWorker(ctx context.Context) {
+	if n, err := db.RequeueRunningJobs(ctx, s.db); err != nil {
+		log.Printf("[jobs] requeue interrupted jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("[jobs] resuming %d job(s) interrupted by a restart", n)
+	}
+	s.pruneScans(ctx)
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
 	for {
+		job, err := db.ClaimNextJob(ctx, s.db)
+		switch {
+		case err == nil:
+			jobErr := s.runJob(ctx, job)
+			if ctx.Err() != nil {
+				return // shutting down: the job stays running and is requeued on the next start
+			}
+			if err := db.FinishJob(ctx, s.db, job.ID, jobErr); err != nil {
+				log.Printf("[jobs] finish job %d: %v", job.ID, err)
+			}
+			s.pruneScans(ctx)
+			s.groups.invalidate()
+			if _, err := db.DeleteFinishedJobs(ctx, s.db, time.Now().Add(-jobRetention)); err != nil {
+				log.Printf("[jobs] delete finished jobs: %v", err)
+			}
+			continue
+		case !errors.Is(err, sql.ErrNoRows):
+			log.Printf("[jobs] claim: %v", err)
+		}
 		select {
 		case <-ctx.Done():
 			return
-		case scanID, ok := <-s.scanQueue:
-			if !ok {
-				return
-			}
-			s.runOneScan(ctx, scanID)
+		case <-s.jobWake:
+		case <-ticker.C:
 		}
 	}
 }
 
-// runOneScan runs the scan phase (if needed) and hash phase for the given scan. Used by the serialized worker.
-func (s *Server) runOneScan(ctx context.Context, scanID int64) {
+// runJob runs one claimed job. A panic fails the job instead of stopping the worker.
+func (s *Server) runJob(ctx context.Context, job *db.Job) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("[scan] panic for scan %d: %v", scanID, r)
+			log.Printf("[jobs] panic in job %d: %v", job.ID, r)
+			err = fmt.Errorf("panic: %v", r)
 		}
 	}()
+	switch job.Type {
+	case db.JobTypeScan:
+		var p db.ScanJobPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("scan job payload: %w", err)
+		}
+		s.running.Store(p.ScanID)
+		defer s.running.Store(0)
+		return s.runOneScan(ctx, p.ScanID)
+	default:
+		return fmt.Errorf("unknown job type %q", job.Type)
+	}
+}
+
+// runOneScan runs the scan phase (if needed) and hash phase for the given scan. Used by the serialized worker.
+// A paused hash phase is not an error: resuming queues a new job.
+func (s *Server) runOneScan(ctx context.Context, scanID int64) error {
 	sn, err := db.GetScan(ctx, s.db, scanID)
 	if err != nil {
 		log.Printf("[scan] scan %d not found: %v", scanID, err)
-		return
+		return err
 	}
 	path := sn.RootPath
-	opts, _ := scan.OptionsForRoot(path)
+	opts, err := scan.OptionsForRoot(path)
+	if err != nil {
+		log.Printf("[scan] scan %d: exclude file: %v", scanID, err)
+		return err
+	}
+	hashOpts := &hash.HashOptions{Workers: 6, ReuseMoved: s.cfg.HashReuse()}
+	similarOpts := &similarity.Options{Workers: 2}
+	var similarImages, similarMedia, photoMetadata bool // folder opted in to near-duplicate detection
+	if folder, err := db.GetFolder(ctx, s.db, sn.FolderID); err == nil {
+		detach, err := offline.Attach(ctx, s.cfg.MountHelper(), folder)
+		if err != nil {
+			log.Printf("[scan] scan %d: %v", scanID, err)
+			return err
+		}
+		defer detach()
+		hashOpts.MaxBytesPerSecond = folder.MaxReadBytesPerSec
+		similarImages, similarMedia, photoMetadata = folder.SimilarImages, folder.SimilarMedia, folder.PhotoMetadata
+		opts.Symlinks = folder.Symlinks
+		opts.NetworkFS = folder.NetworkFS
+		opts.SameDevice = folder.OneFileSystem
+		opts.Archives = folder.Archives
+		opts.UnicodeForm = folder.UnicodeForm
+		opts.MaxDepth = folder.MaxDepth
+		opts.MaxFiles = folder.MaxFiles
+		patterns, err := db.FolderExcludePatterns(ctx, s.db, folder.ID)
+		if err != nil {
+			log.Printf("[scan] scan %d: exclude patterns: %v", scanID, err)
+			return err
+		}
+		opts.ExcludePatterns = append(opts.ExcludePatterns, patterns...)
+		if folder.LowPriority {
+			hashOpts.Priority = ioprio.LowPriority
+			similarOpts.Priority = ioprio.LowPriority
+			opts.Priority = ioprio.LowPriority
+		}
+	}
+	overrides, err := db.GetScanOverrides(ctx, s.db, scanID)
+	if err != nil {
+		log.Printf("[scan] scan %d: overrides: %v", scanID, err)
+		return err
+	}
+	if overrides != nil {
+		if overrides.Workers > 0 {
+			hashOpts.Workers = overrides.Workers
+		}
+		if overrides.MaxReadBytesPerSec > 0 {
+			hashOpts.MaxBytesPerSecond = overrides.MaxReadBytesPerSec
+		}
+		opts.ExcludePatterns = append(opts.ExcludePatterns, overrides.ExcludePatterns...)
+		opts.IncludePatterns = overrides.IncludePatterns
+	}
 	log.Printf("[scan] started for scan %d path %s", scanID, path)
 	if sn.CompletedAt == nil {
 		if err := scan.RunScanForExisting(ctx, s.db, scanID, sn.FolderID, path, opts); err != nil {
 			log.Printf("[scan] failed for scan %d: %v", scanID, err)
-			return
+			return err
 		}
 	}
-	if err := hash.RunHashPhase(ctx, s.db, scanID, &hash.HashOptions{Workers: 6}); err != nil {
+	if err := hash.RunHashPhase(ctx, s.db, scanID, hashOpts); err != nil {
+		if errors.Is(err, hash.ErrPaused) {
+			log.Printf("[hash] scan %d paused; resume from the scan page", scanID)
+			return nil
+		}
 		log.Printf("[hash] background phase failed for scan %d: %v", scanID, err)
+		return err
+	}
+	if sn.Verify && sn.VerifyCompletedAt == nil {
+		if err := hash.RunVerifyPhase(ctx, s.db, scanID, hashOpts); err != nil {
+			log.Printf("[verify] failed for scan %d: %v", scanID, err)
+			return err
+		}
+	}
+	if similarImages {
+		if err := similarity.RunPhase(ctx, s.db, scanID, similarOpts); err != nil {
+			log.Printf("[similar] image phase failed for scan %d: %v", scanID, err)
+		}
+	}
+	if similarMedia {
+		if err := similarity.RunMediaPhase(ctx, s.db, scanID, similarOpts); err != nil {
+			log.Printf("[similar] media phase failed for scan %d: %v", scanID, err)
+		}
+	}
+	if photoMetadata {
+		if err := similarity.RunMetadataPhase(ctx, s.db, scanID, similarOpts); err != nil {
+			log.Printf("[metadata] phase failed for scan %d: %v", scanID, err)
+		}
 	}
+	return nil
 }
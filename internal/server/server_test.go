@@ -1,17 +1,29 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/eargollo/ditto/internal/config"
 	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/hash"
+	"github.com/eargollo/ditto/internal/scan"
+	"github.com/eargollo/ditto/internal/thumb"
 )
 
 func testServer(t *testing.T) (*Server, *sql.DB) {
@@ -117,3 +129,931 @@ func TestServer_RunContextCancelShutsDown(t *testing.T) {
 		t.Errorf("Run after cancel: err = %v", err)
 	}
 }
+
+func TestServer_readTimeoutShowsTooSlowPage(t *testing.T) {
+	srv, err := NewServer(nil, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	srv.readTimeout = 10 * time.Millisecond
+	slow := srv.withReadTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // a query cancelled by the deadline
+		http.Error(w, r.Context().Err().Error(), http.StatusInternalServerError)
+	}))
+	rec := httptest.NewRecorder()
+	slow.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scans/1/duplicates", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "took too long") ||
+		strings.Contains(rec.Body.String(), "deadline") {
+		t.Errorf("slow GET: code %d, body %q; want 503 with the too-slow page", rec.Code, rec.Body.String())
+	}
+
+	failing := srv.withReadTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	rec = httptest.NewRecorder()
+	failing.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scans", nil))
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "boom") {
+		t.Errorf("failing GET: code %d, body %q; want the handler's 500", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGroupCache_invalidateDropsPagesAndLateResults(t *testing.T) {
+	var c groupCache
+	page := []db.DuplicateGroupByHash{{Hash: "h", Count: 2, Size: 10}}
+	_, gen, ok := c.get("k")
+	if ok {
+		t.Fatal("get on an empty cache = hit")
+	}
+	c.put("k", gen, page)
+	if got, _, ok := c.get("k"); !ok || len(got) != 1 || got[0].Hash != "h" {
+		t.Fatalf("get after put = %v, %v; want the page", got, ok)
+	}
+
+	_, gen, _ = c.get("other")
+	c.invalidate()
+	if _, _, ok := c.get("k"); ok {
+		t.Error("get after invalidate = hit, want miss")
+	}
+	c.put("other", gen, page) // computed before the change
+	if _, _, ok := c.get("other"); ok {
+		t.Error("page computed before invalidate was stored")
+	}
+}
+
+func TestDuplicateExporters_csvAndJSON(t *testing.T) {
+	dev := int64(7)
+	rows := []db.DuplicateExportRow{
+		{Hash: "h1", GroupFiles: 2, GroupSize: 20, File: db.File{Path: "/d/a,b", Size: 10, MTime: 1, Inode: 3, DeviceID: &dev}, Keeper: true, HashSource: db.HashComputed},
+		{Hash: "h1", GroupFiles: 2, GroupSize: 20, File: db.File{Path: "/d/c", Size: 10, MTime: 2, Inode: 4}, HashSource: db.HashFromPrevious},
+		{Hash: "h2", GroupFiles: 2, GroupSize: 2, File: db.File{Path: "/d/x", Size: 1, MTime: 3, Inode: 5}},
+		{Hash: "h2", GroupFiles: 2, GroupSize: 2, File: db.File{Path: "/d/y", Size: 1, MTime: 4, Inode: 6}},
+	}
+	export := func(e duplicateExporter) {
+		for _, r := range rows {
+			if err := e.Row(r); err != nil {
+				t.Fatalf("Row: %v", err)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	var csvBuf strings.Builder
+	export(newCSVDuplicateExporter(&csvBuf))
+	wantCSV := "hash,group_files,group_bytes,path,size,mtime,inode,device_id,keeper,hash_source\n" +
+		"h1,2,20,\"/d/a,b\",10,1,3,7,true,computed\n" +
+		"h1,2,20,/d/c,10,2,4,,false,previous\n" +
+		"h2,2,2,/d/x,1,3,5,,false,\n" +
+		"h2,2,2,/d/y,1,4,6,,false,\n"
+	if csvBuf.String() != wantCSV {
+		t.Errorf("csv =\n%s\nwant\n%s", csvBuf.String(), wantCSV)
+	}
+
+	var jsonBuf strings.Builder
+	export(newJSONDuplicateExporter(&jsonBuf))
+	var groups []exportGroup
+	if err := json.Unmarshal([]byte(jsonBuf.String()), &groups); err != nil {
+		t.Fatalf("json output does not parse: %v\n%s", err, jsonBuf.String())
+	}
+	if len(groups) != 2 || groups[0].Hash != "h1" || len(groups[0].Files) != 2 || groups[1].Files[1].Path != "/d/y" || !groups[0].Files[0].Keeper {
+		t.Errorf("json groups = %+v", groups)
+	}
+	if groups[0].Files[0].DeviceID == nil || *groups[0].Files[0].DeviceID != 7 {
+		t.Errorf("device_id not exported: %+v", groups[0].Files[0])
+	}
+	if groups[0].Files[1].HashSource != db.HashFromPrevious || groups[1].Files[0].HashSource != "" {
+		t.Errorf("hash_source not exported: %+v", groups)
+	}
+
+	var empty strings.Builder
+	e := newJSONDuplicateExporter(&empty)
+	if err := e.Close(); err != nil || empty.String() != "[]\n" {
+		t.Errorf("empty json export = %q, %v; want \"[]\\n\"", empty.String(), err)
+	}
+}
+
+func TestDuplicateExporters_flushWritesCompletedRows(t *testing.T) {
+	row := func(hash, path string) db.DuplicateExportRow {
+		return db.DuplicateExportRow{Hash: hash, GroupFiles: 2, GroupSize: 2, File: db.File{Path: path, Size: 1}}
+	}
+	var csvBuf strings.Builder
+	c := newCSVDuplicateExporter(&csvBuf)
+	_ = c.Row(row("h1", "/a"))
+	if err := c.Flush(); err != nil || !strings.Contains(csvBuf.String(), "/a") {
+		t.Errorf("csv after Flush = %q, %v; want the row", csvBuf.String(), err)
+	}
+
+	var jsonBuf strings.Builder
+	j := newJSONDuplicateExporter(&jsonBuf)
+	for _, r := range []db.DuplicateExportRow{row("h1", "/a"), row("h1", "/b"), row("h2", "/c")} {
+		_ = j.Row(r)
+	}
+	if err := j.Flush(); err != nil || !strings.Contains(jsonBuf.String(), `"/b"`) || strings.Contains(jsonBuf.String(), `"/c"`) {
+		t.Errorf("json after Flush = %q, %v; want group h1 only", jsonBuf.String(), err)
+	}
+	if err := j.Close(); err != nil || !strings.Contains(jsonBuf.String(), `"/c"`) {
+		t.Errorf("json after Close = %q, %v; want group h2 too", jsonBuf.String(), err)
+	}
+}
+
+func TestMemberOptions_crossDeviceCopiesCannotBeLinked(t *testing.T) {
+	d1, d2 := int64(1), int64(2)
+	files := []db.File{
+		{ID: 1, DeviceID: &d1, Inode: 5},
+		{ID: 2, DeviceID: &d1, Inode: 5}, // hardlink of the kept copy
+		{ID: 3, DeviceID: &d1, Inode: 6},
+		{ID: 4, DeviceID: &d2, Inode: 5}, // same inode number, other device
+		{ID: 5, Inode: 7},                // device unknown
+	}
+	if n := groupDevices(files); n != 3 {
+		t.Errorf("groupDevices = %d, want 3", n)
+	}
+	opts := memberOptions(files, 1)
+	for id, wantCross := range map[int64]bool{1: false, 2: false, 3: false, 4: true, 5: true} {
+		if opts[id].CrossDevice != wantCross {
+			t.Errorf("file %d: CrossDevice = %v, want %v (%q)", id, opts[id].CrossDevice, wantCross, opts[id].Label)
+		}
+	}
+	if opts[2].Label == opts[3].Label {
+		t.Errorf("hardlink and separate copy share the label %q", opts[2].Label)
+	}
+}
+
+func TestServer_usersSeeOnlySharedAndOwnFolders(t *testing.T) {
+	t.Setenv(config.EnvUserHeader, "Remote-User")
+	srv, database := testServer(t)
+	h := srv.withUser(srv.mux)
+	do := func(user, method, target string, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		if form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if user != "" {
+			req.Header.Set("Remote-User", user)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("", http.MethodGet, "/scans", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /scans without user: code = %d, want 401", rec.Code)
+	}
+	if rec := do("", http.MethodGet, "/health", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /health without user: code = %d, want 200", rec.Code)
+	}
+
+	dir := t.TempDir()
+	if rec := do("alice", http.MethodPost, "/scans/roots", "path="+dir); rec.Code != http.StatusSeeOther {
+		t.Fatalf("alice adds root: code = %d, want 303", rec.Code)
+	}
+	folders, _ := db.ListFolders(context.Background(), database)
+	if len(folders) != 1 {
+		t.Fatalf("folders = %d, want 1", len(folders))
+	}
+	id := folders[0].ID
+	if body := do("alice", http.MethodGet, "/scans", "").Body.String(); !strings.Contains(body, dir) {
+		t.Error("alice's /scans does not list her root")
+	}
+	if body := do("bob", http.MethodGet, "/scans", "").Body.String(); strings.Contains(body, dir) {
+		t.Error("bob's /scans lists alice's root")
+	}
+	settings := "/scans/roots/" + strconv.FormatInt(id, 10) + "/settings"
+	if rec := do("bob", http.MethodPost, settings, "low_priority=1"); rec.Code != http.StatusNotFound {
+		t.Errorf("bob changes alice's root: code = %d, want 404", rec.Code)
+	}
+
+	if rec := do("alice", http.MethodPost, "/scans/roots/"+strconv.FormatInt(id, 10)+"/owner", "shared=1"); rec.Code != http.StatusSeeOther {
+		t.Fatalf("alice shares root: code = %d, want 303", rec.Code)
+	}
+	if body := do("bob", http.MethodGet, "/scans", "").Body.String(); !strings.Contains(body, dir) {
+		t.Error("bob's /scans does not list the shared root")
+	}
+}
+
+func TestServer_viewersCannotChangeAnything(t *testing.T) {
+	t.Setenv(config.EnvUserHeader, "Remote-User")
+	t.Setenv(config.EnvAdmins, "alice")
+	srv, _ := testServer(t)
+	h := srv.withUser(srv.mux)
+	do := func(user, method, target, form string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Remote-User", user)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	dir := t.TempDir()
+	if code := do("bob", http.MethodPost, "/scans/roots", "path="+dir); code != http.StatusForbidden {
+		t.Errorf("viewer adds root: code = %d, want 403", code)
+	}
+	if code := do("bob", http.MethodPost, "/scans/start", "root_path="+dir); code != http.StatusForbidden {
+		t.Errorf("viewer starts scan: code = %d, want 403", code)
+	}
+	if code := do("bob", http.MethodGet, "/scans", ""); code != http.StatusOK {
+		t.Errorf("viewer lists scans: code = %d, want 200", code)
+	}
+	if code := do("alice", http.MethodPost, "/scans/roots", "path="+dir); code != http.StatusSeeOther {
+		t.Errorf("admin adds root: code = %d, want 303", code)
+	}
+}
+
+func TestServer_scanRootWalkLimits(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	id, _ := db.AddFolder(ctx, database, "/")
+	settings := "/scans/roots/" + strconv.FormatInt(id, 10) + "/settings"
+	post := func(form string) int {
+		req := httptest.NewRequest(http.MethodPost, settings, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("max_depth=6&max_files=500000"); code != http.StatusSeeOther {
+		t.Fatalf("save limits: code = %d, want 303", code)
+	}
+	folder, _ := db.GetFolder(ctx, database, id)
+	if folder.MaxDepth != 6 || folder.MaxFiles != 500000 {
+		t.Errorf("limits = %d, %d; want 6, 500000", folder.MaxDepth, folder.MaxFiles)
+	}
+	if code := post("max_depth=-1"); code != http.StatusBadRequest {
+		t.Errorf("negative max_depth: code = %d, want 400", code)
+	}
+	if code := post("max_depth=&max_files="); code != http.StatusSeeOther {
+		t.Fatalf("clear limits: code = %d, want 303", code)
+	}
+	if folder, _ := db.GetFolder(ctx, database, id); folder.MaxDepth != 0 || folder.MaxFiles != 0 {
+		t.Errorf("cleared limits = %d, %d; want 0, 0", folder.MaxDepth, folder.MaxFiles)
+	}
+}
+
+func TestServer_runOneScanFailsOnUnreadableExcludeFile(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, scan.DefaultExcludeFileName), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	folderID, _ := db.AddFolder(ctx, database, dir)
+	sn, err := db.CreateScan(ctx, database, folderID)
+	if err != nil {
+		t.Fatalf("CreateScan: %v", err)
+	}
+	if err := srv.runOneScan(ctx, sn.ID); err == nil {
+		t.Error("runOneScan with a .dittoignore that cannot be read: err = nil, want the read error")
+	}
+	if got, _ := db.GetScan(ctx, database, sn.ID); got.CompletedAt != nil {
+		t.Error("scan completed without its exclude file")
+	}
+}
+
+func TestServer_scansStartJSONReturnsScanIDAndStoresOverrides(t *testing.T) {
+	srv, database := testServer(t)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/scans/start", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	dir := t.TempDir()
+	rec := post(`{"root_path": "` + dir + `", "exclude_patterns": ["*.tmp"], "workers": 2, "max_read_mbps": 1}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /scans/start (JSON): code = %d, want 202; body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		ScanID    int64  `json:"scan_id"`
+		StatusURL string `json:"status_url"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.ScanID == 0 {
+		t.Fatalf("response = %+v, %v; want a scan id", resp, err)
+	}
+	if want := "/api/v1/scans/" + strconv.FormatInt(resp.ScanID, 10); resp.StatusURL != want {
+		t.Errorf("status_url = %q, want %q", resp.StatusURL, want)
+	}
+	o, err := db.GetScanOverrides(context.Background(), database, resp.ScanID)
+	if err != nil || o == nil || o.Workers != 2 || o.MaxReadBytesPerSec != 1<<20 || len(o.ExcludePatterns) != 1 {
+		t.Errorf("stored overrides = %+v, %v; want workers 2, 1 MiB/s, one pattern", o, err)
+	}
+
+	if rec := post(`{"root_path": "` + dir + `", "hash_algorithm": "md5"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported hash algorithm: code = %d, want 400", rec.Code)
+	}
+	if rec := post(`{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("no root: code = %d, want 400", rec.Code)
+	}
+}
+
+func TestServer_apiTokensAreScoped(t *testing.T) {
+	srv, database := testServer(t)
+	h := srv.withUser(srv.mux)
+	ctx := context.Background()
+	readToken, _, _ := db.CreateAPIToken(ctx, database, "dashboard", db.TokenScopeRead, nil)
+	adminToken, _, _ := db.CreateAPIToken(ctx, database, "home assistant", db.TokenScopeAdmin, nil)
+	do := func(token, method, target, body string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	start := `{"root_path": "` + t.TempDir() + `"}`
+	if code := do("ditto_unknown", http.MethodGet, "/scans", ""); code != http.StatusUnauthorized {
+		t.Errorf("unknown token: code = %d, want 401", code)
+	}
+	if code := do(readToken, http.MethodGet, "/scans", ""); code != http.StatusOK {
+		t.Errorf("read token lists scans: code = %d, want 200", code)
+	}
+	if code := do(readToken, http.MethodPost, "/scans/start", start); code != http.StatusForbidden {
+		t.Errorf("read token starts scan: code = %d, want 403", code)
+	}
+	if code := do(adminToken, http.MethodPost, "/scans/start", start); code != http.StatusAccepted {
+		t.Errorf("admin token starts scan: code = %d, want 202", code)
+	}
+	if code := do(adminToken, http.MethodGet, "/tokens", ""); code != http.StatusForbidden {
+		t.Errorf("admin token lists tokens: code = %d, want 403", code)
+	}
+}
+
+func TestServer_openAPIDocumentsRegisteredJSONRoutes(t *testing.T) {
+	srv, err := NewServer(nil, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/openapi.json: code = %d, want 200", rec.Code)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Ref string `json:"$ref"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("document does not parse: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", doc.OpenAPI)
+	}
+	start, ok := doc.Paths["/scans/start"]["post"]
+	if !ok || start.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/startScanRequest" {
+		t.Fatalf("POST /scans/start = %+v, want a startScanRequest body", start)
+	}
+	if _, ok := doc.Components.Schemas["startScanRequest"].Properties["exclude_patterns"]; !ok {
+		t.Errorf("startScanRequest schema = %+v, want its json fields", doc.Components.Schemas["startScanRequest"])
+	}
+
+	// Every documented operation is what the mux serves for its path.
+	for _, rt := range srv.apiRoutes {
+		if _, ok := doc.Paths[rt.Path][strings.ToLower(rt.Method)]; !ok {
+			t.Errorf("%s %s missing from the document", rt.Method, rt.Path)
+		}
+		req := httptest.NewRequest(rt.Method, pathParam.ReplaceAllString(rt.Path, "1"), nil)
+		if _, pattern := srv.mux.Handler(req); pattern != rt.Method+" "+rt.Path {
+			t.Errorf("%s %s is served by %q", rt.Method, rt.Path, pattern)
+		}
+	}
+}
+
+func TestServer_groupPathsLoadAPageAtATime(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	folderID, _ := db.AddFolder(ctx, database, "/data")
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	for i := 0; i < homeGroupPathsPage+1; i++ {
+		id, _ := db.UpsertFile(ctx, database, folderID, fmt.Sprintf("f%03d", i), 10, 0, int64(i+1), nil)
+		_ = db.InsertFileScan(ctx, database, id, scan.ID)
+		_ = db.UpdateFileHash(ctx, database, id, "h", time.Now())
+	}
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: code = %d, want 200", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	first := get(groupPathsURL(scan.ID, "h", "", 0))
+	if strings.Count(first, "/data/f") != homeGroupPathsPage || !strings.Contains(first, "offset=50") {
+		t.Errorf("first page has %d paths, want %d and a link to the next page", strings.Count(first, "/data/f"), homeGroupPathsPage)
+	}
+	last := get(groupPathsURL(scan.ID, "h", "", homeGroupPathsPage))
+	if !strings.Contains(last, "/data/f050") || strings.Contains(last, "Show more") {
+		t.Errorf("last page = %q, want only /data/f050", last)
+	}
+}
+
+func TestServer_hashGroupPageListsAPageAtATime(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	folderID, _ := db.AddFolder(ctx, database, "/data")
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	var last int64
+	for i := 0; i < groupPageSize+1; i++ {
+		id, _ := db.UpsertFile(ctx, database, folderID, fmt.Sprintf("f%03d", i), 10, 0, int64(i+1), nil)
+		_ = db.InsertFileScan(ctx, database, id, scan.ID)
+		_ = db.UpdateFileHash(ctx, database, id, "h", time.Now())
+		last = id
+	}
+	// The keeper is on the second page; the first page still describes its copies against it.
+	if err := db.SetGroupKeeper(ctx, database, "h", last); err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: code = %d, want 200", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	base := fmt.Sprintf("/scans/%d/duplicates/hash/h", scan.ID)
+	first := get(base)
+	if n := strings.Count(first, "Keep this copy<"); n != groupPageSize {
+		t.Errorf("first page lists %d files, want %d", n, groupPageSize)
+	}
+	if !strings.Contains(first, fmt.Sprintf("Files 1–%d of %d", groupPageSize, groupPageSize+1)) || !strings.Contains(first, "?page=2") {
+		t.Errorf("first page lacks the count or a link to page 2")
+	}
+	if !strings.Contains(first, "Other device: delete") || strings.Contains(first, "Kept (default)") {
+		t.Errorf("first page options are not against the keeper on page 2")
+	}
+	second := get(base + "?page=2")
+	if !strings.Contains(second, fmt.Sprintf("/data/f%03d", groupPageSize)) || !strings.Contains(second, "Kept copy") || strings.Contains(second, "/data/f000") {
+		t.Errorf("second page should list only the last file, the keeper")
+	}
+
+	// Unpinning from page 2 comes back to page 2.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, base+"/keeper/clear", strings.NewReader("page=2&prefix=%2Fdata"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	srv.mux.ServeHTTP(rec, req)
+	if want := base + "?page=2&prefix=%2Fdata"; rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != want {
+		t.Errorf("unpin: code = %d, Location = %q; want 303 to %q", rec.Code, rec.Header().Get("Location"), want)
+	}
+}
+
+func TestServer_pagesLoadThemeBeforePaint(t *testing.T) {
+	srv, err := NewServer(nil, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.renderPage(rec, "layout.html", "tokens-content", apiTokensPageData{})
+	head, _, _ := strings.Cut(rec.Body.String(), "</head>")
+	if !strings.Contains(head, `<script src="/static/theme.js"></script>`) || !strings.Contains(rec.Body.String(), `id="theme"`) {
+		t.Errorf("layout lacks theme.js in <head> or the theme picker")
+	}
+	rec = httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/theme.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /static/theme.js: code = %d, want 200", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/app.css", nil))
+	if !strings.Contains(rec.Body.String(), `:root[data-theme="dark"]`) {
+		t.Errorf("app.css has no dark palette")
+	}
+}
+
+func TestServer_previewServesThumbnails(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(config.EnvDataDir, dataDir)
+	srv, database := testServer(t)
+	ctx := context.Background()
+	root := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1024, 512))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.png"), buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("text"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	folderID, _ := db.AddFolder(ctx, database, root)
+	imgID, _ := db.UpsertFile(ctx, database, folderID, "a.png", int64(buf.Len()), 0, 1, nil)
+	_ = db.UpdateFileHash(ctx, database, imgID, "0123456789abcdef0123456789abcdef", time.Now())
+	txtID, _ := db.UpsertFile(ctx, database, folderID, "a.txt", 4, 0, 2, nil)
+	get := func(id int64) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/preview?file_id=%d", id), nil))
+		return rec
+	}
+
+	rec := get(imgID)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("preview: code = %d, type %q; want a JPEG", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	img, err := jpeg.Decode(rec.Body)
+	if err != nil || img.Bounds().Dx() != thumb.DefaultSize {
+		t.Errorf("preview = %v, %v; want %d pixels wide", img.Bounds(), err, thumb.DefaultSize)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "thumbnails", "01", "0123456789abcdef0123456789abcdef.jpg")); err != nil {
+		t.Errorf("thumbnail not cached in the data directory: %v", err)
+	}
+	if rec := get(txtID); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("preview of a text file: code = %d, want 415", rec.Code)
+	}
+	if rec := get(txtID + 100); rec.Code != http.StatusNotFound {
+		t.Errorf("preview of a missing file: code = %d, want 404", rec.Code)
+	}
+}
+
+func TestServer_textPreviewAndSameSizeDiff(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	root := t.TempDir()
+	for name, text := range map[string]string{"a.txt": "one\ntwo\n", "b.txt": "one\ntwo\n", "c.txt": "one\nTWO\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	folderID, _ := db.AddFolder(ctx, database, root)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	ids := make(map[string]int64)
+	for i, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		id, _ := db.UpsertFile(ctx, database, folderID, name, 8, 0, int64(i+1), nil)
+		_ = db.InsertFileScan(ctx, database, id, scan.ID)
+		hash := "h"
+		if name == "c.txt" {
+			hash = "other"
+		}
+		_ = db.UpdateFileHash(ctx, database, id, hash, time.Now())
+		ids[name] = id
+	}
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: code = %d, want 200", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	group := get(fmt.Sprintf("/scans/%d/duplicates/hash/h", scan.ID))
+	diffURL := fmt.Sprintf("/diff?a=%d&amp;b=%d", ids["a.txt"], ids["c.txt"])
+	if !strings.Contains(group, "Same size, different content") || !strings.Contains(group, diffURL) {
+		t.Errorf("group page lacks the same-size file with a diff link %s", diffURL)
+	}
+	if preview := get(fmt.Sprintf("/preview/text?file_id=%d", ids["a.txt"])); !strings.Contains(preview, "one\ntwo\n") {
+		t.Errorf("text preview = %q, want the file's text", preview)
+	}
+	diff := get(fmt.Sprintf("/diff?a=%d&b=%d", ids["a.txt"], ids["c.txt"]))
+	if !strings.Contains(diff, `<span class="diff-removed">- two`) || !strings.Contains(diff, `<span class="diff-added">&#43; TWO`) {
+		t.Errorf("diff page does not mark the changed line")
+	}
+}
+
+func TestServer_preferencesSetHomeDefaults(t *testing.T) {
+	srv, _ := testServer(t)
+	post := func(form string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/preferences", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("page_size=7"); rec.Code != http.StatusBadRequest {
+		t.Errorf("page_size=7: code = %d, want 400", rec.Code)
+	}
+	rec := post("page_size=50&scan_id=0&sort=wasted&min_mb=5")
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("save: code = %d, want 303", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != browserCookie {
+		t.Fatalf("save: cookies = %v, want a %s cookie", cookies, browserCookie)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	home := httptest.NewRecorder()
+	srv.mux.ServeHTTP(home, req)
+	body := home.Body.String()
+	if !strings.Contains(body, `<option value="50" selected>`) || !strings.Contains(body, `<option value="wasted" selected>`) {
+		t.Errorf("GET / with saved preferences: page size 50 and sort wasted not selected")
+	}
+	if rec := post("page_size=10", cookies[0]); len(rec.Result().Cookies()) != 0 {
+		t.Errorf("second save set a new cookie, want the browser's id reused")
+	}
+}
+
+func TestServer_sameNameListsDivergentCopies(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	folderID, _ := db.AddFolder(ctx, database, "/docs")
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	for i, p := range []string{"a/report.docx", "b/report.docx"} {
+		id, _ := db.UpsertFile(ctx, database, folderID, p, 10, 0, int64(i+1), nil)
+		_ = db.InsertFileScan(ctx, database, id, scan.ID)
+		_ = db.UpdateFileHash(ctx, database, id, fmt.Sprintf("h%d", i), time.Now())
+	}
+
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/scans/%d/names?ext=docx", scan.ID), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET names: code = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "2 files, 2 versions") || !strings.Contains(body, "/docs/b/report.docx") {
+		t.Errorf("names page does not list report.docx in 2 versions: %s", body)
+	}
+}
+
+func TestServer_knownContentListsFilesOfDeletedScans(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	folderID, _ := db.AddFolder(ctx, database, "/photos")
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	id, _ := db.UpsertFile(ctx, database, folderID, "2019/a.jpg", 10, 0, 1, nil)
+	_ = db.InsertFileScan(ctx, database, id, scan.ID)
+	_ = db.UpdateFileHash(ctx, database, id, "h1", time.Now())
+	if err := db.DeleteScan(ctx, database, scan.ID); err != nil {
+		t.Fatalf("DeleteScan: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/content/h1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET content: code = %d, want 200", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "first seen") || !strings.Contains(body, "/photos/2019/a.jpg") {
+		t.Errorf("content page does not show where h1 was first seen: %s", body)
+	}
+	rec = httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/content/unknown", nil))
+	if !strings.Contains(rec.Body.String(), "never hashed") {
+		t.Errorf("content page for an unknown hash: %s", rec.Body.String())
+	}
+}
+
+func TestServer_hashGroupShowsWhenEachCopyAppeared(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	folderID, _ := db.AddFolder(ctx, database, "/data")
+	s1, _ := db.CreateScan(ctx, database, folderID)
+	s2, _ := db.CreateScan(ctx, database, folderID)
+	orig, _ := db.UpsertFile(ctx, database, folderID, "orig.txt", 10, 0, 1, nil)
+	copyID, _ := db.UpsertFile(ctx, database, folderID, "copy.txt", 10, 0, 2, nil)
+	_ = db.InsertFileScan(ctx, database, orig, s1.ID)
+	_ = db.InsertFileScan(ctx, database, orig, s2.ID)
+	_ = db.InsertFileScan(ctx, database, copyID, s2.ID)
+	_ = db.UpdateFileHash(ctx, database, orig, "h", time.Now())
+	_ = db.UpdateFileHash(ctx, database, copyID, "h", time.Now())
+
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/scans/%d/duplicates/hash/h", s2.ID), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET group: code = %d, want 200", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "appeared 1 scan ago") || !strings.Contains(body, "new in the latest scan") {
+		t.Errorf("group page does not tell the original from the new copy: %s", body)
+	}
+}
+
+func TestServer_scanRootBrowserAndValidation(t *testing.T) {
+	base := t.TempDir()
+	photos := filepath.Join(base, "photos")
+	if err := os.MkdirAll(filepath.Join(photos, "2024"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.EnvBrowseRoots, base)
+	srv, _ := testServer(t)
+	do := func(method, target, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		if form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/scans/roots/browse", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "photos/") {
+		t.Fatalf("browse the base: code = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/scans/roots/browse?path=/etc", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("browse outside the browse roots: code = %d, want 403", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/scans/roots", "path="+photos); rec.Code != http.StatusSeeOther {
+		t.Fatalf("add %s: code = %d, want 303: %s", photos, rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/scans/roots/browse?path="+base, ""); !strings.Contains(rec.Body.String(), "scan root") {
+		t.Errorf("browse does not mark the registered root: %s", rec.Body.String())
+	}
+	for _, p := range []string{filepath.Join(photos, "2024"), filepath.Join(base, "missing"), "/"} {
+		if rec := do(http.MethodPost, "/scans/roots", "path="+p); rec.Code != http.StatusBadRequest {
+			t.Errorf("add %s: code = %d, want 400", p, rec.Code)
+		}
+	}
+}
+
+func TestServer_scanRootMove(t *testing.T) {
+	base := t.TempDir()
+	for _, d := range []string{"old", "new", "other"} {
+		if err := os.Mkdir(filepath.Join(base, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, database := testServer(t)
+	ctx := context.Background()
+	id, _ := db.AddFolder(ctx, database, filepath.Join(base, "old"))
+	_, _ = db.AddFolder(ctx, database, filepath.Join(base, "other"))
+	move := func(p string) int {
+		req := httptest.NewRequest(http.MethodPost, "/scans/roots/"+strconv.FormatInt(id, 10)+"/move", strings.NewReader("path="+p))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, p := range []string{filepath.Join(base, "missing"), filepath.Join(base, "other"), filepath.Join(base, "other", "sub"), "/etc"} {
+		if code := move(p); code != http.StatusBadRequest {
+			t.Errorf("move to %s: code = %d, want 400", p, code)
+		}
+	}
+	if code := move(filepath.Join(base, "new")); code != http.StatusSeeOther {
+		t.Fatalf("move to new: code = %d, want 303", code)
+	}
+	if f, err := db.GetFolder(ctx, database, id); err != nil || f.Path != filepath.Join(base, "new") {
+		t.Errorf("folder after move = %+v, %v", f, err)
+	}
+}
+
+func TestServer_disabledRootIsNotScanned(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	id, _ := db.AddFolder(ctx, database, dir)
+	post := func(target, form string) int {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	root := "/scans/roots/" + strconv.FormatInt(id, 10)
+
+	if code := post(root+"/disable", ""); code != http.StatusSeeOther {
+		t.Fatalf("disable: code = %d, want 303", code)
+	}
+	if code := post("/scans/start", "root_id="+strconv.FormatInt(id, 10)); code != http.StatusConflict {
+		t.Errorf("start a scan of a disabled root: code = %d, want 409", code)
+	}
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scans", nil))
+	if !strings.Contains(rec.Body.String(), "disabled since") || !strings.Contains(rec.Body.String(), root+"/enable") {
+		t.Errorf("scans page does not offer to enable the disabled root")
+	}
+	if code := post(root+"/enable", ""); code != http.StatusSeeOther {
+		t.Fatalf("enable: code = %d, want 303", code)
+	}
+	if f, err := db.GetFolder(ctx, database, id); err != nil || f.Disabled() {
+		t.Errorf("folder after enable = %+v, %v; want enabled", f, err)
+	}
+	if code := post("/scans/roots/999999/disable", ""); code != http.StatusNotFound {
+		t.Errorf("disable a missing root: code = %d, want 404", code)
+	}
+}
+
+func TestServer_rehashReusedCopies(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	folderID, _ := db.AddFolder(ctx, database, dir)
+	scan, _ := db.CreateScan(ctx, database, folderID)
+	ids := map[string]int64{}
+	var stored []db.FileHash
+	for _, f := range []struct{ name, content, source string }{
+		{"a.txt", "same", db.HashComputed},
+		{"b.txt", "same", db.HashFromPrevious},
+		{"c.txt", "diff", db.HashFromInode}, // wrongly reused: its bytes are other content
+	} {
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(f.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		id, _ := db.UpsertFile(ctx, database, folderID, f.name, 4, 0, int64(len(ids)+1), nil)
+		_ = db.InsertFileScan(ctx, database, id, scan.ID)
+		ids[f.name] = id
+		stored = append(stored, db.FileHash{FileID: id, Hash: "h", Source: f.source})
+	}
+	if err := db.UpdateFileHashes(ctx, database, stored, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	post := func(form string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/scans/%d/duplicates/hash/h/rehash", scan.ID), strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := post("file_id=999999"); code != http.StatusNotFound {
+		t.Errorf("re-hash a file outside the group: code = %d, want 404", code)
+	}
+	code, body := post("")
+	if code != http.StatusOK || !strings.Contains(body, "left the group") {
+		t.Fatalf("re-hash reused copies: code = %d; want 200 reporting the copies that left the group", code)
+	}
+	evidence, _ := db.HashEvidenceByFileID(ctx, database, []int64{ids["a.txt"], ids["b.txt"], ids["c.txt"]})
+	for name, id := range ids {
+		if evidence[id].Source != db.HashComputed {
+			t.Errorf("%s: source %q, want computed", name, evidence[id].Source)
+		}
+	}
+	files, _ := db.FilesInHashGroup(ctx, database, scan.ID, "h")
+	if len(files) != 1 || files[0].ID != ids["a.txt"] {
+		t.Errorf("group h after re-hashing = %+v; want only a.txt, whose computed hash was not read again", files)
+	}
+}
+
+func TestServer_linkRehashesChangedCopiesFirst(t *testing.T) {
+	srv, database := testServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	folderID, _ := db.AddFolder(ctx, database, dir)
+	sn, _ := db.CreateScan(ctx, database, folderID)
+	for name, content := range map[string]string{"a.txt": "same", "b.txt": "same", "c.txt": "diff"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := hash.HashFileLimited(ctx, filepath.Join(dir, "a.txt"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int64{}
+	var stored []db.FileHash
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		p := filepath.Join(dir, name)
+		info, _ := os.Stat(p)
+		inode, dev, _ := scan.InodeAndDev(p)
+		deviceID, err := db.ResolveDevice(ctx, database, folderID, dev, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mtime := info.ModTime().Unix()
+		if name == "c.txt" {
+			mtime-- // edited after the scan hashed it
+		}
+		id, _ := db.UpsertFile(ctx, database, folderID, name, 4, mtime, inode, &deviceID)
+		_ = db.InsertFileScan(ctx, database, id, sn.ID)
+		ids[name] = id
+		stored = append(stored, db.FileHash{FileID: id, Hash: h, Source: db.HashComputed})
+	}
+	if err := db.UpdateFileHashes(ctx, database, stored, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/scans/%d/duplicates/hash/%s/link", sn.ID, h), strings.NewReader("method=hardlink"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("link: code = %d, want 200; body %s", rec.Code, rec.Body.String())
+	}
+	a, _ := os.Stat(filepath.Join(dir, "a.txt"))
+	b, _ := os.Stat(filepath.Join(dir, "b.txt"))
+	if !os.SameFile(a, b) {
+		t.Error("a.txt and b.txt were not linked")
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "c.txt")); string(content) != "diff" {
+		t.Errorf("c.txt = %q after linking, want its own content kept", content)
+	}
+	files, _ := db.FilesInHashGroup(ctx, database, sn.ID, h)
+	if len(files) != 2 {
+		t.Errorf("group after linking has %d files, want 2 (c.txt re-hashed out of it)", len(files))
+	}
+}
@@ -0,0 +1,29 @@
+// Swipe to keep: on the duplicate group page, swiping a copy's card to the right submits its
+// "Keep this copy" form (the card's data-swipe-keep names the form). Taps and vertical scrolls are left alone.
+(function () {
+  var threshold = 80; // px to the right before the swipe counts
+  document.addEventListener("DOMContentLoaded", function () {
+    document.querySelectorAll("[data-swipe-keep]").forEach(function (card) {
+      var x0 = null, y0 = 0;
+      card.addEventListener("touchstart", function (e) {
+        if (e.touches.length !== 1) { x0 = null; return; }
+        x0 = e.touches[0].clientX;
+        y0 = e.touches[0].clientY;
+      }, { passive: true });
+      card.addEventListener("touchmove", function (e) {
+        if (x0 === null) return;
+        var dx = e.touches[0].clientX - x0, dy = e.touches[0].clientY - y0;
+        if (Math.abs(dy) > Math.abs(dx)) { x0 = null; card.style.transform = ""; return; }
+        card.style.transform = "translateX(" + Math.max(0, Math.min(dx, threshold)) + "px)";
+      }, { passive: true });
+      card.addEventListener("touchend", function (e) {
+        if (x0 === null) return;
+        var dx = e.changedTouches[0].clientX - x0;
+        x0 = null;
+        card.style.transform = "";
+        var form = document.getElementById(card.dataset.swipeKeep);
+        if (dx >= threshold && form) form.requestSubmit();
+      });
+    });
+  });
+})();
@@ -0,0 +1,24 @@
+// Theme: "light", "dark" or "auto" (follow the system), kept per browser in localStorage. Loaded in <head>
+// so the page is painted in the chosen theme.
+(function () {
+  var key = "ditto-theme";
+  var dark = window.matchMedia("(prefers-color-scheme: dark)");
+  function choice() {
+    try { return localStorage.getItem(key) || "auto"; } catch (e) { return "auto"; }
+  }
+  function apply() {
+    var c = choice();
+    document.documentElement.setAttribute("data-theme", c === "auto" ? (dark.matches ? "dark" : "light") : c);
+  }
+  apply();
+  dark.addEventListener("change", apply);
+  document.addEventListener("DOMContentLoaded", function () {
+    var sel = document.getElementById("theme");
+    if (!sel) return;
+    sel.value = choice();
+    sel.addEventListener("change", function () {
+      try { localStorage.setItem(key, sel.value); } catch (e) {}
+      apply();
+    });
+  });
+})();
@@ -0,0 +1,224 @@
+package similarity
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/limits"
+)
+
+// ImageInfo is the metadata of a photo used to tell copies apart: pixel dimensions (as displayed, after the EXIF
+// orientation) and, for JPEG, the EXIF date taken and camera.
+type ImageInfo struct {
+	Width   int
+	Height  int
+	TakenAt *time.Time // camera's local time, without a zone; nil when absent
+	Camera  string     // make and model; "" when absent
+}
+
+// MetadataExtensions are the lowercase file extensions the metadata phase reads. EXIF is read from JPEG only.
+var MetadataExtensions = ImageExtensions
+
+// errNoEXIF is returned by readEXIF for a file without an EXIF block.
+var errNoEXIF = errors.New("no EXIF data")
+
+// ReadImageInfo reads the dimensions of the image at path from its header and, when present, its EXIF date
+// taken and camera. Only the header and metadata are read, not the pixels.
+func ReadImageInfo(path string) (ImageInfo, error) {
+	release, err := limits.AcquireFiles(context.Background(), 1)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	defer release()
+	f, err := archive.Open(path)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	cfg, _, err := image.DecodeConfig(bufio.NewReader(f))
+	f.Close()
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	info := ImageInfo{Width: cfg.Width, Height: cfg.Height}
+	// Opened again rather than rewound: a file inside an archive cannot seek.
+	f, err = archive.Open(path)
+	if err != nil {
+		return info, nil
+	}
+	defer f.Close()
+	x, err := readEXIF(bufio.NewReader(f))
+	if err != nil {
+		return info, nil // dimensions are enough; most PNG and GIF files have no EXIF
+	}
+	info.TakenAt, info.Camera = x.takenAt, x.camera
+	if x.orientation >= 5 && x.orientation <= 8 { // rotated by 90°: stored sideways
+		info.Width, info.Height = info.Height, info.Width
+	}
+	return info, nil
+}
+
+// exifData is the part of an EXIF block ReadImageInfo uses.
+type exifData struct {
+	takenAt     *time.Time
+	camera      string
+	orientation int
+}
+
+// EXIF tags read by parseTIFF.
+const (
+	tagMake              = 0x010f
+	tagModel             = 0x0110
+	tagOrientation       = 0x0112
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+)
+
+// readEXIF finds the EXIF block (APP1 segment) of a JPEG stream and parses it. It stops at the image data.
+func readEXIF(r *bufio.Reader) (exifData, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return exifData{}, errNoEXIF
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return exifData{}, errNoEXIF
+		}
+		if b != 0xff {
+			return exifData{}, errNoEXIF
+		}
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xff { // fill bytes
+			marker, err = r.ReadByte()
+		}
+		if err != nil || marker == 0xda || marker == 0xd9 { // start of scan or end of image
+			return exifData{}, errNoEXIF
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return exifData{}, errNoEXIF
+		}
+		n := int(binary.BigEndian.Uint16(size[:])) - 2
+		if n < 0 {
+			return exifData{}, errNoEXIF
+		}
+		if marker != 0xe1 {
+			if _, err := r.Discard(n); err != nil {
+				return exifData{}, errNoEXIF
+			}
+			continue
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return exifData{}, errNoEXIF
+		}
+		if tiff, ok := bytes.CutPrefix(seg, []byte("Exif\x00\x00")); ok {
+			return parseTIFF(tiff)
+		}
+		// An APP1 segment with XMP; the EXIF one may follow.
+	}
+}
+
+// parseTIFF reads the tags ReadImageInfo uses from IFD0 and the EXIF sub-IFD of a TIFF-structured EXIF block.
+func parseTIFF(b []byte) (exifData, error) {
+	if len(b) < 8 {
+		return exifData{}, errNoEXIF
+	}
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return exifData{}, errNoEXIF
+	}
+	if order.Uint16(b[2:]) != 42 {
+		return exifData{}, errNoEXIF
+	}
+	tags := make(map[uint16][]byte)
+	ifd0 := readIFD(b, order, order.Uint32(b[4:]), tags)
+	if ptr, ok := ifd0[tagExifIFD]; ok {
+		readIFD(b, order, ptr, tags)
+	}
+
+	var x exifData
+	if v, ok := tags[tagOrientation]; ok && len(v) >= 2 {
+		x.orientation = int(order.Uint16(v))
+	}
+	for _, tag := range []uint16{tagDateTimeOriginal, tagDateTimeDigitized, tagDateTime} {
+		if t, err := time.Parse("2006:01:02 15:04:05", asciiValue(tags[tag])); err == nil {
+			x.takenAt = &t
+			break
+		}
+	}
+	maker, model := asciiValue(tags[tagMake]), asciiValue(tags[tagModel])
+	switch {
+	case model == "":
+		x.camera = maker
+	case maker == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)):
+		x.camera = model // e.g. "Canon EOS 5D" from Canon
+	default:
+		x.camera = maker + " " + model
+	}
+	return x, nil
+}
+
+// readIFD stores the raw value of each entry of the IFD at off in tags, and returns the LONG values by tag
+// (for sub-IFD pointers). Entries pointing outside b are skipped.
+func readIFD(b []byte, order binary.ByteOrder, off uint32, tags map[uint16][]byte) map[uint16]uint32 {
+	longs := make(map[uint16]uint32)
+	if uint64(off)+2 > uint64(len(b)) {
+		return longs
+	}
+	count := int(order.Uint16(b[off:]))
+	for i := 0; i < count; i++ {
+		e := uint64(off) + 2 + uint64(i)*12
+		if e+12 > uint64(len(b)) {
+			break
+		}
+		entry := b[e : e+12]
+		tag, typ, n := order.Uint16(entry), order.Uint16(entry[2:]), uint64(order.Uint32(entry[4:]))
+		var unit uint64
+		switch typ {
+		case 1, 2, 7: // BYTE, ASCII, UNDEFINED
+			unit = 1
+		case 3: // SHORT
+			unit = 2
+		case 4: // LONG
+			unit = 4
+			longs[tag] = order.Uint32(entry[8:])
+		default:
+			continue
+		}
+		size := unit * n
+		if size <= 4 {
+			tags[tag] = entry[8 : 8+size]
+			continue
+		}
+		at := uint64(order.Uint32(entry[8:]))
+		if at+size > uint64(len(b)) {
+			continue
+		}
+		tags[tag] = b[at : at+size]
+	}
+	return longs
+}
+
+// asciiValue returns an EXIF ASCII value without its NUL terminator and padding.
+func asciiValue(v []byte) string {
+	if i := bytes.IndexByte(v, 0); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(string(v))
+}
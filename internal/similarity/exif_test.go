@@ -0,0 +1,106 @@
+package similarity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// exifSegment builds an APP1 EXIF segment (little-endian) with make, model and orientation in IFD0 and
+// DateTimeOriginal in the EXIF sub-IFD.
+func exifSegment(maker, model, taken string, orientation uint16) []byte {
+	le := binary.LittleEndian
+	var tiff bytes.Buffer
+	u16 := func(v uint16) { _ = binary.Write(&tiff, le, v) }
+	u32 := func(v uint32) { _ = binary.Write(&tiff, le, v) }
+	maker, model, taken = maker+"\x00", model+"\x00", taken+"\x00"
+	const ifd0 = 8
+	const ifd0Size = 2 + 4*12 + 4
+	const exifIFD = ifd0 + ifd0Size
+	const exifSize = 2 + 12 + 4
+	data := uint32(exifIFD + exifSize)
+
+	tiff.WriteString("II")
+	u16(42)
+	u32(ifd0)
+	u16(4)
+	u16(tagMake)
+	u16(2)
+	u32(uint32(len(maker)))
+	u32(data)
+	u16(tagModel)
+	u16(2)
+	u32(uint32(len(model)))
+	u32(data + uint32(len(maker)))
+	u16(tagOrientation)
+	u16(3)
+	u32(1)
+	u16(orientation)
+	u16(0)
+	u16(tagExifIFD)
+	u16(4)
+	u32(1)
+	u32(exifIFD)
+	u32(0)
+	u16(1)
+	u16(tagDateTimeOriginal)
+	u16(2)
+	u32(uint32(len(taken)))
+	u32(data + uint32(len(maker)+len(model)))
+	u32(0)
+	tiff.WriteString(maker + model + taken)
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	seg := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func TestReadImageInfo_jpegWithEXIF(t *testing.T) {
+	var enc bytes.Buffer
+	if err := jpeg.Encode(&enc, image.NewGray(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	raw := enc.Bytes()
+	withEXIF := append(append(append([]byte(nil), raw[:2]...), exifSegment("Canon", "Canon EOS 5D", "2019:05:01 10:20:30", 6)...), raw[2:]...)
+
+	info, err := ReadImageInfo(writeTemp(t, "a.jpg", withEXIF))
+	if err != nil {
+		t.Fatalf("ReadImageInfo: %v", err)
+	}
+	if info.Width != 30 || info.Height != 40 {
+		t.Errorf("size = %dx%d, want 30x40 (rotated by the orientation tag)", info.Width, info.Height)
+	}
+	if info.Camera != "Canon EOS 5D" {
+		t.Errorf("camera = %q, want %q", info.Camera, "Canon EOS 5D")
+	}
+	if info.TakenAt == nil || info.TakenAt.Format("2006-01-02 15:04:05") != "2019-05-01 10:20:30" {
+		t.Errorf("taken = %v, want 2019-05-01 10:20:30", info.TakenAt)
+	}
+
+	plain, err := ReadImageInfo(writeTemp(t, "b.jpg", raw))
+	if err != nil || plain.Width != 40 || plain.TakenAt != nil || plain.Camera != "" {
+		t.Errorf("JPEG without EXIF = %+v, %v; want 40x30 only", plain, err)
+	}
+}
+
+func TestReadImageInfo_pngAndGarbage(t *testing.T) {
+	var enc bytes.Buffer
+	if err := png.Encode(&enc, image.NewGray(image.Rect(0, 0, 7, 5))); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := ReadImageInfo(writeTemp(t, "a.png", enc.Bytes())); err != nil || info.Width != 7 || info.Height != 5 {
+		t.Errorf("PNG = %+v, %v; want 7x5", info, err)
+	}
+	if _, err := ReadImageInfo(writeTemp(t, "bad.jpg", []byte("not an image"))); err == nil {
+		t.Error("ReadImageInfo of a non-image: want an error")
+	}
+	// A truncated or corrupt EXIF block must not panic.
+	tiff := exifSegment("Nikon", "D90", "2020:01:01 00:00:00", 1)[10:]
+	for n := range tiff {
+		_, _ = parseTIFF(tiff[:n])
+	}
+}
@@ -0,0 +1,75 @@
+package similarity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/bits"
+	"os/exec"
+	"sync"
+)
+
+// fpcalcMaxSeconds limits how much audio fpcalc fingerprints (from the start of the file).
+const fpcalcMaxSeconds = "120"
+
+// fpcalcMaxOffset is how many fingerprint items (~0.12s each) two fingerprints may be shifted when compared.
+const fpcalcMaxOffset = 40
+
+var (
+	fpcalcOnce sync.Once
+	fpcalcPath string
+)
+
+// FingerprintAvailable reports whether the Chromaprint fpcalc tool is on PATH. Without it, media are matched
+// on duration only.
+func FingerprintAvailable() bool {
+	fpcalcOnce.Do(func() {
+		fpcalcPath, _ = exec.LookPath("fpcalc")
+	})
+	return fpcalcPath != ""
+}
+
+// Fingerprint returns the raw Chromaprint fingerprint of the file's audio, computed by fpcalc.
+func Fingerprint(ctx context.Context, path string) ([]uint32, error) {
+	if !FingerprintAvailable() {
+		return nil, errors.New("fpcalc not found on PATH")
+	}
+	out, err := exec.CommandContext(ctx, fpcalcPath, "-raw", "-json", "-length", fpcalcMaxSeconds, path).Output() // #nosec G204 -- fixed tool, path is an argument
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Fingerprint []uint32 `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Fingerprint) == 0 {
+		return nil, errors.New("fpcalc: empty fingerprint")
+	}
+	return res.Fingerprint, nil
+}
+
+// FingerprintSimilarity returns the fraction of matching bits (0..1) between two fingerprints at the best
+// alignment within fpcalcMaxOffset items. Unrelated audio scores about 0.5.
+func FingerprintSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for off := -fpcalcMaxOffset; off <= fpcalcMaxOffset; off++ {
+		var diff, n int
+		for i := range a {
+			j := i + off
+			if j < 0 || j >= len(b) {
+				continue
+			}
+			diff += bits.OnesCount32(a[i] ^ b[j])
+			n++
+		}
+		if n < 20 { // too little overlap to be meaningful
+			continue
+		}
+		if s := 1 - float64(diff)/float64(32*n); s > best {
+			best = s
+		}
+	}
+	return best
+}
@@ -0,0 +1,85 @@
+package similarity
+
+// bkNode is a node of a BK-tree keyed by Hamming distance, used to find hashes within a radius without
+// comparing every pair.
+type bkNode struct {
+	hash     uint64
+	idx      int
+	children map[int]*bkNode
+}
+
+func (n *bkNode) insert(hash uint64, idx int) {
+	for {
+		d := Distance(n.hash, hash)
+		child, ok := n.children[d]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[int]*bkNode)
+			}
+			n.children[d] = &bkNode{hash: hash, idx: idx}
+			return
+		}
+		n = child
+	}
+}
+
+// within calls fn for every indexed hash at distance <= radius from hash.
+func (n *bkNode) within(hash uint64, radius int, fn func(idx int)) {
+	d := Distance(n.hash, hash)
+	if d <= radius {
+		fn(n.idx)
+	}
+	for cd, child := range n.children {
+		if cd >= d-radius && cd <= d+radius {
+			child.within(hash, radius, fn)
+		}
+	}
+}
+
+// Group clusters hashes that are within maxDistance of each other (transitively) and returns the clusters
+// with at least two members, as indexes into hashes. Clusters and their members keep input order.
+func Group(hashes []uint64, maxDistance int) [][]int {
+	if len(hashes) < 2 {
+		return nil
+	}
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	root := &bkNode{hash: hashes[0], idx: 0}
+	for i := 1; i < len(hashes); i++ {
+		root.within(hashes[i], maxDistance, func(j int) {
+			if a, b := find(i), find(j); a != b {
+				parent[a] = b
+			}
+		})
+		root.insert(hashes[i], i)
+	}
+	byRoot := make(map[int]int) // root index -> position in out
+	var out [][]int
+	for i := range hashes {
+		r := find(i)
+		pos, ok := byRoot[r]
+		if !ok {
+			pos = len(out)
+			byRoot[r] = pos
+			out = append(out, nil)
+		}
+		out[pos] = append(out[pos], i)
+	}
+	groups := out[:0]
+	for _, g := range out {
+		if len(g) >= 2 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,510 @@
+package similarity
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eargollo/ditto/internal/limits"
+	"github.com/eargollo/ditto/internal/pathnorm"
+)
+
+// Media kinds.
+const (
+	KindAudio = "audio"
+	KindVideo = "video"
+)
+
+// MediaExtensions are the lowercase file extensions probed for duration and container metadata.
+var MediaExtensions = []string{".mp3", ".flac", ".wav", ".m4a", ".mp4", ".m4v", ".mov", ".avi", ".mkv", ".webm"}
+
+// ErrUnsupportedMedia is returned by ProbeMedia for files whose container it cannot parse.
+var ErrUnsupportedMedia = errors.New("unsupported media container")
+
+// moovReadLimit caps how much of an MP4/MOV 'moov' box is read into memory.
+const moovReadLimit = 64 << 20
+
+// MediaInfo is the container metadata of an audio or video file.
+type MediaInfo struct {
+	Kind      string // KindAudio or KindVideo
+	Container string // mp3, flac, wav, mp4, mov, avi, mkv
+	Duration  time.Duration
+}
+
+// ProbeMedia reads the container headers of the file at path and returns its kind, container, and duration.
+// Only headers are read; the streams are not decoded.
+func ProbeMedia(path string) (MediaInfo, error) {
+	release, err := limits.AcquireFiles(context.Background(), 1)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	defer release()
+	f, err := pathnorm.Open(path)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	var info MediaInfo
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		info, err = probeMP3(f, st.Size())
+	case ".flac":
+		info, err = probeFLAC(f)
+	case ".wav":
+		info, err = probeWAV(f)
+	case ".m4a", ".mp4", ".m4v", ".mov":
+		info, err = probeMP4(f, st.Size())
+	case ".avi":
+		info, err = probeAVI(f)
+	case ".mkv", ".webm":
+		info, err = probeMKV(f)
+	default:
+		return MediaInfo{}, ErrUnsupportedMedia
+	}
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	if info.Duration <= 0 {
+		return MediaInfo{}, fmt.Errorf("%s: no duration", info.Container)
+	}
+	return info, nil
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// --- MP3 ---
+
+var (
+	mp3Bitrates = map[[2]int][16]int{ // [mpeg1?, layer] -> kbps by index
+		{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		{0, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mp3SampleRates = [3]int{44100, 48000, 32000}
+)
+
+// probeMP3 reads the first frame header and uses the Xing/Info or VBRI frame count when present,
+// otherwise estimates the duration from the (constant) bitrate and the audio size.
+func probeMP3(r io.ReadSeeker, size int64) (MediaInfo, error) {
+	info := MediaInfo{Kind: KindAudio, Container: "mp3"}
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return info, err
+	}
+	var start int64
+	if bytes.Equal(head[:3], []byte("ID3")) {
+		start = 10 + (int64(head[6]&0x7f)<<21 | int64(head[7]&0x7f)<<14 | int64(head[8]&0x7f)<<7 | int64(head[9]&0x7f))
+		if head[5]&0x10 != 0 {
+			start += 10 // footer
+		}
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return info, err
+	}
+	buf := make([]byte, 64<<10)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return info, err
+	}
+	buf = buf[:n]
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xff || buf[i+1]&0xe0 != 0xe0 {
+			continue
+		}
+		h := buf[i:]
+		versionBits := (h[1] >> 3) & 3 // 0 = 2.5, 2 = 2, 3 = 1
+		layerBits := (h[1] >> 1) & 3   // 1 = III, 2 = II, 3 = I
+		brIdx := int(h[2] >> 4)
+		srIdx := int((h[2] >> 2) & 3)
+		if versionBits == 1 || layerBits == 0 || brIdx == 0 || brIdx == 15 || srIdx == 3 {
+			continue
+		}
+		layer := 4 - int(layerBits)
+		mpeg1 := 0
+		if versionBits == 3 {
+			mpeg1 = 1
+		}
+		sampleRate := mp3SampleRates[srIdx]
+		switch versionBits {
+		case 2:
+			sampleRate /= 2
+		case 0:
+			sampleRate /= 4
+		}
+		samplesPerFrame := 1152
+		if layer == 1 {
+			samplesPerFrame = 384
+		} else if layer == 3 && mpeg1 == 0 {
+			samplesPerFrame = 576
+		}
+		mono := h[3]>>6 == 3
+		sideInfo := 32
+		switch {
+		case mpeg1 == 1 && mono:
+			sideInfo = 17
+		case mpeg1 == 0 && !mono:
+			sideInfo = 17
+		case mpeg1 == 0 && mono:
+			sideInfo = 9
+		}
+		if frames := vbrFrameCount(h, sideInfo); frames > 0 {
+			info.Duration = secondsToDuration(float64(frames) * float64(samplesPerFrame) / float64(sampleRate))
+			return info, nil
+		}
+		bitrate := mp3Bitrates[[2]int{mpeg1, layer}][brIdx] * 1000
+		audio := size - start - int64(i)
+		if size >= 128 {
+			tag := make([]byte, 3)
+			if _, err := r.Seek(size-128, io.SeekStart); err == nil {
+				if _, err := io.ReadFull(r, tag); err == nil && string(tag) == "TAG" {
+					audio -= 128
+				}
+			}
+		}
+		info.Duration = secondsToDuration(float64(audio) * 8 / float64(bitrate))
+		return info, nil
+	}
+	return info, errors.New("mp3: no frame header")
+}
+
+// vbrFrameCount returns the frame count from a Xing/Info or VBRI header in the first frame, or 0.
+func vbrFrameCount(frame []byte, sideInfo int) uint32 {
+	if x := 4 + sideInfo; len(frame) >= x+12 {
+		tag := string(frame[x : x+4])
+		if (tag == "Xing" || tag == "Info") && binary.BigEndian.Uint32(frame[x+4:])&1 != 0 {
+			return binary.BigEndian.Uint32(frame[x+8:])
+		}
+	}
+	if v := 4 + 32; len(frame) >= v+18 && string(frame[v:v+4]) == "VBRI" {
+		return binary.BigEndian.Uint32(frame[v+14:])
+	}
+	return 0
+}
+
+// --- FLAC ---
+
+func probeFLAC(r io.Reader) (MediaInfo, error) {
+	info := MediaInfo{Kind: KindAudio, Container: "flac"}
+	b := make([]byte, 4+4+34)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return info, err
+	}
+	if string(b[:4]) != "fLaC" || b[4]&0x7f != 0 {
+		return info, errors.New("flac: missing STREAMINFO")
+	}
+	v := binary.BigEndian.Uint64(b[8+10:])
+	sampleRate := v >> 44
+	totalSamples := v & (1<<36 - 1)
+	if sampleRate == 0 {
+		return info, errors.New("flac: zero sample rate")
+	}
+	info.Duration = secondsToDuration(float64(totalSamples) / float64(sampleRate))
+	return info, nil
+}
+
+// --- WAV / AVI (RIFF) ---
+
+func probeWAV(r io.Reader) (MediaInfo, error) {
+	info := MediaInfo{Kind: KindAudio, Container: "wav"}
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return info, err
+	}
+	if string(hdr[:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return info, errors.New("wav: not a RIFF/WAVE file")
+	}
+	var byteRate uint32
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return info, fmt.Errorf("wav: %w", err)
+		}
+		id, size := string(chunk[:4]), binary.LittleEndian.Uint32(chunk[4:])
+		switch id {
+		case "fmt ":
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil || size < 12 {
+				return info, errors.New("wav: short fmt chunk")
+			}
+			byteRate = binary.LittleEndian.Uint32(body[8:])
+			if size%2 == 1 {
+				_, _ = io.CopyN(io.Discard, r, 1)
+			}
+		case "data":
+			if byteRate == 0 {
+				return info, errors.New("wav: data before fmt")
+			}
+			info.Duration = secondsToDuration(float64(size) / float64(byteRate))
+			return info, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return info, err
+			}
+		}
+	}
+}
+
+func probeAVI(r io.Reader) (MediaInfo, error) {
+	info := MediaInfo{Kind: KindVideo, Container: "avi"}
+	b := make([]byte, 512)
+	n, err := io.ReadFull(r, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return info, err
+	}
+	b = b[:n]
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "AVI " {
+		return info, errors.New("avi: not a RIFF/AVI file")
+	}
+	i := bytes.Index(b, []byte("avih"))
+	if i < 0 || len(b) < i+8+20 {
+		return info, errors.New("avi: no main header")
+	}
+	h := b[i+8:]
+	usPerFrame := binary.LittleEndian.Uint32(h[0:])
+	frames := binary.LittleEndian.Uint32(h[16:])
+	info.Duration = time.Duration(usPerFrame) * time.Duration(frames) * time.Microsecond
+	return info, nil
+}
+
+// --- MP4 / MOV (ISO base media) ---
+
+func probeMP4(r io.ReadSeeker, size int64) (MediaInfo, error) {
+	info := MediaInfo{Kind: KindAudio, Container: "mp4"}
+	var off int64
+	for off+8 <= size {
+		if _, err := r.Seek(off, io.SeekStart); err != nil {
+			return info, err
+		}
+		hdr := make([]byte, 16)
+		if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+			return info, err
+		}
+		boxSize, typ, hdrLen := int64(binary.BigEndian.Uint32(hdr)), string(hdr[4:8]), int64(8)
+		if boxSize == 1 {
+			if _, err := io.ReadFull(r, hdr[8:16]); err != nil {
+				return info, err
+			}
+			boxSize, hdrLen = int64(binary.BigEndian.Uint64(hdr[8:])), 16
+		} else if boxSize == 0 {
+			boxSize = size - off
+		}
+		if boxSize < hdrLen {
+			return info, errors.New("mp4: invalid box size")
+		}
+		switch typ {
+		case "ftyp":
+			brand := make([]byte, 4)
+			if _, err := io.ReadFull(r, brand); err == nil && string(brand) == "qt  " {
+				info.Container = "mov"
+			}
+		case "moov":
+			if boxSize-hdrLen > moovReadLimit {
+				return info, errors.New("mp4: moov box too large")
+			}
+			moov := make([]byte, boxSize-hdrLen)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return info, err
+			}
+			return info, parseMoov(moov, &info)
+		}
+		off += boxSize
+	}
+	return info, errors.New("mp4: no moov box")
+}
+
+// mp4Boxes calls fn for each box in b with its type and payload.
+func mp4Boxes(b []byte, fn func(typ string, payload []byte)) {
+	for len(b) >= 8 {
+		size, hdrLen := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		typ := string(b[4:8])
+		if size == 1 && len(b) >= 16 {
+			size, hdrLen = binary.BigEndian.Uint64(b[8:]), 16
+		} else if size == 0 {
+			size = uint64(len(b))
+		}
+		if size < hdrLen || size > uint64(len(b)) {
+			return
+		}
+		fn(typ, b[hdrLen:size])
+		b = b[size:]
+	}
+}
+
+func parseMoov(moov []byte, info *MediaInfo) error {
+	mp4Boxes(moov, func(typ string, p []byte) {
+		switch typ {
+		case "mvhd":
+			if len(p) < 20 {
+				return
+			}
+			var timescale uint32
+			var duration uint64
+			if p[0] == 1 && len(p) >= 32 {
+				timescale, duration = binary.BigEndian.Uint32(p[20:]), binary.BigEndian.Uint64(p[24:])
+			} else {
+				timescale, duration = binary.BigEndian.Uint32(p[12:]), uint64(binary.BigEndian.Uint32(p[16:]))
+			}
+			if timescale > 0 {
+				info.Duration = secondsToDuration(float64(duration) / float64(timescale))
+			}
+		case "trak":
+			mp4Boxes(p, func(typ string, p []byte) {
+				if typ != "mdia" {
+					return
+				}
+				mp4Boxes(p, func(typ string, p []byte) {
+					if typ == "hdlr" && len(p) >= 12 && string(p[8:12]) == "vide" {
+						info.Kind = KindVideo
+					}
+				})
+			})
+		}
+	})
+	if info.Duration == 0 {
+		return errors.New("mp4: no movie header")
+	}
+	return nil
+}
+
+// --- Matroska / WebM (EBML) ---
+
+const (
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+	ebmlHeader        = 0x1A45DFA3
+)
+
+// ebmlVint reads an EBML variable-length integer. keepMarker keeps the length marker bit (element IDs).
+// Returns the value, its length, and whether all value bits were 1 (unknown size).
+func ebmlVint(r io.Reader, keepMarker bool) (uint64, int, bool, error) {
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return 0, 0, false, err
+	}
+	n := 1
+	for mask := byte(0x80); n <= 8 && first[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, false, errors.New("mkv: invalid vint")
+	}
+	v := uint64(first[0])
+	if !keepMarker {
+		v &= uint64(0xff >> n)
+	}
+	rest := make([]byte, n-1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, false, err
+	}
+	allOnes := v == uint64(0xff>>n)
+	for _, b := range rest {
+		v = v<<8 | uint64(b)
+		allOnes = allOnes && b == 0xff
+	}
+	return v, n, allOnes && !keepMarker, nil
+}
+
+func probeMKV(r io.ReadSeeker) (MediaInfo, error) {
+	info := MediaInfo{Kind: KindVideo, Container: "mkv"}
+	id, _, _, err := ebmlVint(r, true)
+	if err != nil || id != ebmlHeader {
+		return info, errors.New("mkv: not an EBML file")
+	}
+	size, _, _, err := ebmlVint(r, false)
+	if err != nil {
+		return info, err
+	}
+	if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+		return info, err
+	}
+	if id, _, _, err = ebmlVint(r, true); err != nil || id != ebmlSegment {
+		return info, errors.New("mkv: no segment")
+	}
+	if _, _, _, err := ebmlVint(r, false); err != nil {
+		return info, err
+	}
+	// Walk the segment's children until Info; it precedes the clusters in practice.
+	for i := 0; i < 64; i++ {
+		id, _, _, err := ebmlVint(r, true)
+		if err != nil {
+			return info, err
+		}
+		size, _, unknown, err := ebmlVint(r, false)
+		if err != nil {
+			return info, err
+		}
+		if unknown {
+			return info, errors.New("mkv: element of unknown size before Info")
+		}
+		if id != ebmlInfo {
+			if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+				return info, err
+			}
+			continue
+		}
+		if size > 1<<20 {
+			return info, errors.New("mkv: Info element too large")
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return info, err
+		}
+		return info, parseMKVInfo(body, &info)
+	}
+	return info, errors.New("mkv: no Info element")
+}
+
+func parseMKVInfo(body []byte, info *MediaInfo) error {
+	scale := uint64(1000000) // default TimecodeScale: 1ms in ns
+	var duration float64
+	br := bytes.NewReader(body)
+	for br.Len() > 0 {
+		id, _, _, err := ebmlVint(br, true)
+		if err != nil {
+			return err
+		}
+		size, _, _, err := ebmlVint(br, false)
+		if err != nil || size > uint64(br.Len()) {
+			return errors.New("mkv: malformed Info")
+		}
+		v := make([]byte, size)
+		_, _ = io.ReadFull(br, v)
+		switch id {
+		case ebmlTimecodeScale:
+			scale = 0
+			for _, b := range v {
+				scale = scale<<8 | uint64(b)
+			}
+		case ebmlDuration:
+			switch size {
+			case 4:
+				duration = float64(math.Float32frombits(binary.BigEndian.Uint32(v)))
+			case 8:
+				duration = math.Float64frombits(binary.BigEndian.Uint64(v))
+			}
+		}
+	}
+	if duration <= 0 {
+		return errors.New("mkv: no duration")
+	}
+	info.Duration = time.Duration(duration * float64(scale))
+	return nil
+}
@@ -0,0 +1,118 @@
+package similarity
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	durationTolerance    = time.Second // media whose durations differ by more than this (or 1%) never match
+	minFingerprintMatch  = 0.5         // minimum fingerprint confidence to group two fingerprinted files
+	durationOnlyMaxScore = 0.5         // confidence cap when at least one file has no fingerprint
+)
+
+// MediaItem is an audio or video file to group.
+type MediaItem struct {
+	Kind        string
+	Duration    time.Duration
+	Fingerprint []uint32 // nil when not fingerprinted
+}
+
+// MediaGroup is a cluster of media that likely come from the same source.
+type MediaGroup struct {
+	Members       []int   // indexes into the input
+	Confidence    float64 // 0..1: weakest link in the group
+	Fingerprinted bool    // every link was confirmed by audio fingerprints (otherwise duration only)
+}
+
+func tolerance(d time.Duration) time.Duration {
+	if t := d / 100; t > durationTolerance {
+		return t
+	}
+	return durationTolerance
+}
+
+// pairConfidence scores two media of the same kind with close durations. ok is false if they should not be linked.
+func pairConfidence(a, b MediaItem) (confidence float64, fingerprinted, ok bool) {
+	if a.Fingerprint != nil && b.Fingerprint != nil {
+		c := (FingerprintSimilarity(a.Fingerprint, b.Fingerprint) - 0.5) * 2
+		return c, true, c >= minFingerprintMatch
+	}
+	delta := a.Duration - b.Duration
+	if delta < 0 {
+		delta = -delta
+	}
+	tol := tolerance(max(a.Duration, b.Duration))
+	return durationOnlyMaxScore * (1 - float64(delta)/float64(tol)), false, delta <= tol
+}
+
+// GroupMedia clusters media of the same kind whose durations match within tolerance and, when both have
+// fingerprints, whose audio matches. Groups have at least two members and are ordered by confidence, highest first.
+func GroupMedia(items []MediaItem) []MediaGroup {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(x, y int) bool {
+		a, b := items[order[x]], items[order[y]]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Duration < b.Duration
+	})
+	parent := make([]int, len(items))
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	type edge struct {
+		a, b          int
+		confidence    float64
+		fingerprinted bool
+	}
+	var edges []edge
+	for x, i := range order {
+		for _, j := range order[x+1:] {
+			if items[j].Kind != items[i].Kind || items[j].Duration-items[i].Duration > tolerance(items[j].Duration) {
+				break
+			}
+			if c, fp, ok := pairConfidence(items[i], items[j]); ok {
+				edges = append(edges, edge{i, j, c, fp})
+				if ra, rb := find(i), find(j); ra != rb {
+					parent[ra] = rb
+				}
+			}
+		}
+	}
+	byRoot := make(map[int]*MediaGroup)
+	var roots []int
+	for i := range items {
+		r := find(i)
+		g, ok := byRoot[r]
+		if !ok {
+			g = &MediaGroup{Confidence: 1, Fingerprinted: true}
+			byRoot[r] = g
+			roots = append(roots, r)
+		}
+		g.Members = append(g.Members, i)
+	}
+	for _, e := range edges {
+		g := byRoot[find(e.a)]
+		g.Confidence = min(g.Confidence, e.confidence)
+		g.Fingerprinted = g.Fingerprinted && e.fingerprinted
+	}
+	var out []MediaGroup
+	for _, r := range roots {
+		if g := byRoot[r]; len(g.Members) >= 2 {
+			out = append(out, *g)
+		}
+	}
+	sort.SliceStable(out, func(x, y int) bool { return out[x].Confidence > out[y].Confidence })
+	return out
+}
@@ -0,0 +1,137 @@
+package similarity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func le32(v uint32) []byte { b := make([]byte, 4); binary.LittleEndian.PutUint32(b, v); return b }
+func be32(v uint32) []byte { b := make([]byte, 4); binary.BigEndian.PutUint32(b, v); return b }
+
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return append(append(be32(uint32(8+len(body))), typ...), body...)
+}
+
+func TestProbeMedia_containers(t *testing.T) {
+	// WAV: 2 s of 8 kHz mono 16-bit (byte rate 16000).
+	var wav bytes.Buffer
+	wav.WriteString("RIFF")
+	wav.Write(le32(36 + 32000))
+	wav.WriteString("WAVEfmt ")
+	wav.Write(le32(16))
+	wav.Write([]byte{1, 0, 1, 0})
+	wav.Write(le32(8000))
+	wav.Write(le32(16000))
+	wav.Write([]byte{2, 0, 16, 0})
+	wav.WriteString("data")
+	wav.Write(le32(32000))
+	wav.Write(make([]byte, 32000))
+
+	// FLAC: STREAMINFO with 44100 Hz and 441000 samples (10 s).
+	flac := []byte("fLaC")
+	flac = append(flac, 0x80, 0, 0, 34)
+	si := make([]byte, 34)
+	binary.BigEndian.PutUint64(si[10:], uint64(44100)<<44|uint64(1)<<41|uint64(15)<<36|441000)
+	flac = append(flac, si...)
+
+	// MP4: ftyp + moov/mvhd (timescale 1000, duration 90500) with a video track.
+	mvhd := make([]byte, 20)
+	copy(mvhd[12:], be32(1000))
+	copy(mvhd[16:], be32(90500))
+	hdlr := append(make([]byte, 8), "vide"...)
+	mp4 := append(box("ftyp", []byte("isom"), make([]byte, 4)), box("moov", box("mvhd", mvhd), box("trak", box("mdia", box("hdlr", hdlr))))...)
+
+	// MP3: MPEG-1 Layer III 128 kbps 44.1 kHz CBR, 16000 bytes of frames = 1 s.
+	mp3 := make([]byte, 16000)
+	copy(mp3, []byte{0xff, 0xfb, 0x90, 0x00})
+
+	// MKV: EBML header, Segment, Info with TimecodeScale 1ms and Duration 5000.0 (float64).
+	dur := make([]byte, 8)
+	binary.BigEndian.PutUint64(dur, math.Float64bits(5000))
+	info := append([]byte{0x2A, 0xD7, 0xB1, 0x83, 0x0F, 0x42, 0x40}, append([]byte{0x44, 0x89, 0x88}, dur...)...)
+	mkv := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x80}
+	mkv = append(mkv, 0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	mkv = append(mkv, 0x15, 0x49, 0xA9, 0x66, byte(0x80|len(info)))
+	mkv = append(mkv, info...)
+
+	for _, tc := range []struct {
+		name      string
+		data      []byte
+		kind      string
+		container string
+		duration  time.Duration
+	}{
+		{"a.wav", wav.Bytes(), KindAudio, "wav", 2 * time.Second},
+		{"a.flac", flac, KindAudio, "flac", 10 * time.Second},
+		{"v.mp4", mp4, KindVideo, "mp4", 90500 * time.Millisecond},
+		{"a.mp3", mp3, KindAudio, "mp3", time.Second},
+		{"v.mkv", mkv, KindVideo, "mkv", 5 * time.Second},
+	} {
+		got, err := ProbeMedia(writeTemp(t, tc.name, tc.data))
+		if err != nil {
+			t.Errorf("ProbeMedia(%s): %v", tc.name, err)
+			continue
+		}
+		if got.Kind != tc.kind || got.Container != tc.container || (got.Duration-tc.duration).Abs() > 10*time.Millisecond {
+			t.Errorf("ProbeMedia(%s) = %+v, want %s/%s %v", tc.name, got, tc.kind, tc.container, tc.duration)
+		}
+	}
+}
+
+func TestProbeMedia_unsupported(t *testing.T) {
+	if _, err := ProbeMedia(writeTemp(t, "x.txt", []byte("hello"))); err != ErrUnsupportedMedia {
+		t.Errorf("ProbeMedia(.txt) err = %v, want ErrUnsupportedMedia", err)
+	}
+	if _, err := ProbeMedia(writeTemp(t, "x.flac", []byte("garbage data here"))); err == nil {
+		t.Error("ProbeMedia(garbage .flac): want error")
+	}
+}
+
+func TestGroupMedia_confidence(t *testing.T) {
+	fp := make([]uint32, 100)
+	for i := range fp {
+		fp[i] = uint32(i) * 2654435761
+	}
+	noisy := append([]uint32(nil), fp...)
+	for i := range noisy {
+		noisy[i] ^= 1 // 1 of 32 bits differs
+	}
+	other := make([]uint32, 100)
+	for i := range other {
+		other[i] = ^fp[i]
+	}
+	items := []MediaItem{
+		{Kind: KindAudio, Duration: 200 * time.Second, Fingerprint: fp},
+		{Kind: KindAudio, Duration: 200*time.Second + 300*time.Millisecond, Fingerprint: noisy},
+		{Kind: KindAudio, Duration: 200 * time.Second, Fingerprint: other}, // same length, different audio
+		{Kind: KindVideo, Duration: 60 * time.Second},
+		{Kind: KindVideo, Duration: 60*time.Second + 200*time.Millisecond},
+		{Kind: KindAudio, Duration: 60 * time.Second}, // other kind: not grouped with the videos
+	}
+	groups := GroupMedia(items)
+	if len(groups) != 2 {
+		t.Fatalf("GroupMedia = %+v, want 2 groups", groups)
+	}
+	fpGroup, durGroup := groups[0], groups[1]
+	if !fpGroup.Fingerprinted || len(fpGroup.Members) != 2 || fpGroup.Confidence < 0.9 {
+		t.Errorf("fingerprint group = %+v, want members [0 1] with confidence >= 0.9", fpGroup)
+	}
+	if durGroup.Fingerprinted || len(durGroup.Members) != 2 || durGroup.Confidence > durationOnlyMaxScore {
+		t.Errorf("duration group = %+v, want members [3 4] with confidence <= %v", durGroup, durationOnlyMaxScore)
+	}
+}
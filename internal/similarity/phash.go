@@ -0,0 +1,149 @@
+// Package similarity finds visually similar images that are not byte-identical (different resolution,
+// re-encoded, re-saved) using a DCT-based perceptual hash (pHash).
+package similarity
+
+import (
+	"context"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/limits"
+)
+
+const (
+	sampleSize = 32 // image is reduced to sampleSize x sampleSize grayscale before the DCT
+	lowFreq    = 8  // the top-left lowFreq x lowFreq DCT coefficients form the 64-bit hash
+)
+
+// ImageExtensions are the lowercase file extensions decoded for perceptual hashing (standard library decoders).
+var ImageExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// HashImageFile decodes the image at path and returns its perceptual hash.
+func HashImageFile(path string) (uint64, error) {
+	release, err := limits.AcquireFiles(context.Background(), 1)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	f, err := archive.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, err
+	}
+	return PHash(img), nil
+}
+
+// PHash returns the 64-bit perceptual hash of img: the image is reduced to 32x32 grayscale, transformed with a
+// 2D DCT, and each of the 8x8 lowest-frequency coefficients becomes one bit (1 if above their median).
+// Similar-looking images have hashes with a small Distance.
+func PHash(img image.Image) uint64 {
+	pixels := grayscale(img)
+	coeffs := dct2D(pixels)
+	vals := make([]float64, 0, lowFreq*lowFreq)
+	for y := 0; y < lowFreq; y++ {
+		for x := 0; x < lowFreq; x++ {
+			vals = append(vals, coeffs[y][x])
+		}
+	}
+	// The DC term (average brightness) dominates; exclude it from the median.
+	sorted := append([]float64(nil), vals[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	var h uint64
+	for i, v := range vals {
+		if v > median {
+			h |= 1 << uint(i)
+		}
+	}
+	return h
+}
+
+// Distance returns the Hamming distance between two perceptual hashes (0 = same, 64 = opposite).
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// grayscale box-averages img down to sampleSize x sampleSize luminance values. Each cell averages its source
+// rectangle; images smaller than sampleSize repeat source pixels.
+func grayscale(img image.Image) [sampleSize][sampleSize]float64 {
+	var out [sampleSize][sampleSize]float64
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return out
+	}
+	ycc, isYCC := img.(*image.YCbCr)
+	for ty := 0; ty < sampleSize; ty++ {
+		y0, y1 := span(ty, h)
+		for tx := 0; tx < sampleSize; tx++ {
+			x0, x1 := span(tx, w)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					if isYCC {
+						sum += float64(ycc.Y[ycc.YOffset(b.Min.X+x, b.Min.Y+y)])
+						continue
+					}
+					r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+					sum += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+				}
+			}
+			out[ty][tx] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return out
+}
+
+// span returns the source range [lo, hi) covered by target cell i when n source pixels map to sampleSize cells.
+func span(i, n int) (lo, hi int) {
+	lo = i * n / sampleSize
+	hi = (i + 1) * n / sampleSize
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+var dctCos = func() (c [sampleSize][sampleSize]float64) {
+	for u := 0; u < sampleSize; u++ {
+		for x := 0; x < sampleSize; x++ {
+			c[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * sampleSize))
+		}
+	}
+	return c
+}()
+
+// dct2D computes the (unnormalized) 2D DCT-II, only for the lowFreq x lowFreq coefficients used by the hash.
+func dct2D(p [sampleSize][sampleSize]float64) [lowFreq][lowFreq]float64 {
+	var rows [sampleSize][lowFreq]float64 // DCT along x for each row
+	for y := 0; y < sampleSize; y++ {
+		for u := 0; u < lowFreq; u++ {
+			var s float64
+			for x := 0; x < sampleSize; x++ {
+				s += p[y][x] * dctCos[u][x]
+			}
+			rows[y][u] = s
+		}
+	}
+	var out [lowFreq][lowFreq]float64
+	for v := 0; v < lowFreq; v++ {
+		for u := 0; u < lowFreq; u++ {
+			var s float64
+			for y := 0; y < sampleSize; y++ {
+				s += rows[y][u] * dctCos[v][y]
+			}
+			out[v][u] = s
+		}
+	}
+	return out
+}
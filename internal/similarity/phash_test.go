@@ -0,0 +1,98 @@
+package similarity
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// gradient returns a w x h image with a diagonal gradient and a dark square, so it has low-frequency structure.
+func gradient(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*255/h) / 2)
+			if x > w/4 && x < w/2 && y > h/4 && y < h/2 {
+				v = 20
+			}
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestPHash_resizedAndReencodedImagesAreClose(t *testing.T) {
+	big := PHash(gradient(640, 480))
+	small := PHash(gradient(160, 120))
+	if d := Distance(big, small); d > 4 {
+		t.Errorf("Distance(640x480, 160x120) = %d, want <= 4", d)
+	}
+
+	path := filepath.Join(t.TempDir(), "g.jpg")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := jpeg.Encode(f, gradient(640, 480), &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	f.Close()
+	fromJPEG, err := HashImageFile(path)
+	if err != nil {
+		t.Fatalf("HashImageFile: %v", err)
+	}
+	if d := Distance(big, fromJPEG); d > 6 {
+		t.Errorf("Distance(original, jpeg q40) = %d, want <= 6", d)
+	}
+}
+
+func TestPHash_differentImagesAreFar(t *testing.T) {
+	a := PHash(gradient(200, 200))
+	flipped := gradient(200, 200)
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 100; x++ {
+			l, r := flipped.At(x, y), flipped.At(199-x, 199-y)
+			flipped.Set(x, y, r)
+			flipped.Set(199-x, 199-y, l)
+		}
+	}
+	if d := Distance(a, PHash(flipped)); d < 16 {
+		t.Errorf("Distance(image, rotated 180°) = %d, want >= 16", d)
+	}
+}
+
+func TestHashImageFile_notAnImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.png")
+	if err := os.WriteFile(path, []byte("not an image"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := HashImageFile(path); err == nil {
+		t.Error("HashImageFile(text file): want error")
+	}
+}
+
+func TestGroup_transitiveClusters(t *testing.T) {
+	hashes := []uint64{
+		0b0000,         // 0
+		0b0001,         // 1: 1 bit from 0
+		0b0011,         // 2: 1 bit from 1, 2 from 0
+		0xFFFF << 32,   // 3: far from all
+		0xFFFF<<32 | 1, // 4: 1 bit from 3
+	}
+	groups := Group(hashes, 1)
+	if len(groups) != 2 {
+		t.Fatalf("Group = %v, want 2 groups", groups)
+	}
+	if len(groups[0]) != 3 || groups[0][0] != 0 || groups[0][2] != 2 {
+		t.Errorf("groups[0] = %v, want [0 1 2]", groups[0])
+	}
+	if len(groups[1]) != 2 || groups[1][0] != 3 || groups[1][1] != 4 {
+		t.Errorf("groups[1] = %v, want [3 4]", groups[1])
+	}
+	if g := Group(hashes, 0); len(g) != 0 {
+		t.Errorf("Group(distance 0) = %v, want none", g)
+	}
+}
@@ -0,0 +1,150 @@
+package similarity
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eargollo/ditto/internal/db"
+	"github.com/eargollo/ditto/internal/ioprio"
+)
+
+// Options configures the similarity phases. Nil means defaults (single worker).
+type Options struct {
+	Workers  int             // number of decode workers (default 1)
+	Priority ioprio.Settings // lower CPU/I/O priority of worker threads (Linux); zero = unchanged
+}
+
+func (o *Options) workers() int {
+	if o == nil || o.Workers <= 0 {
+		return 1
+	}
+	return o.Workers
+}
+
+// RunPhase computes perceptual hashes for the scan's image files that do not have one yet (new or changed
+// since they were last hashed). Files that fail to decode are marked so they are not retried until they change.
+// Respects context cancellation; already computed hashes are kept.
+func RunPhase(ctx context.Context, database *sql.DB, scanID int64, opts *Options) error {
+	files, err := db.ListPendingPHashFiles(ctx, database, scanID, ImageExtensions)
+	if err != nil {
+		return err
+	}
+	log.Printf("[similar] image phase started for scan %d (%d worker(s), %d images)", scanID, opts.workers(), len(files))
+	start := time.Now()
+	ok, failed, err := runWorkers(ctx, files, opts, func(f db.File) (bool, error) {
+		var ph *int64
+		if h, err := HashImageFile(f.Path); err == nil {
+			v := int64(h)
+			ph = &v
+		}
+		return ph != nil, db.UpdateFilePHash(ctx, database, f.ID, ph)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[similar] image phase completed for scan %d: %d hashed, %d not decodable, in %v", scanID, ok, failed, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// RunMediaPhase probes the scan's audio and video files for container and duration and, when fpcalc is
+// available, computes an audio fingerprint. Files already probed at their current size and mtime are skipped.
+func RunMediaPhase(ctx context.Context, database *sql.DB, scanID int64, opts *Options) error {
+	files, err := db.ListPendingMediaFiles(ctx, database, scanID, MediaExtensions)
+	if err != nil {
+		return err
+	}
+	fingerprint := FingerprintAvailable()
+	log.Printf("[similar] media phase started for scan %d (%d worker(s), %d files, fingerprints: %v)", scanID, opts.workers(), len(files), fingerprint)
+	start := time.Now()
+	ok, failed, err := runWorkers(ctx, files, opts, func(f db.File) (bool, error) {
+		info, perr := ProbeMedia(f.Path)
+		rec := db.MediaRecord{Kind: info.Kind, Container: info.Container, Duration: info.Duration}
+		if perr != nil {
+			rec = db.MediaRecord{Err: perr.Error()}
+		} else if fingerprint {
+			if fp, err := Fingerprint(ctx, f.Path); err == nil {
+				rec.Fingerprint = fp
+			}
+		}
+		return perr == nil, db.UpsertMediaInfo(ctx, database, f.ID, f.Size, f.MTime, rec)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[similar] media phase completed for scan %d: %d probed, %d unsupported, in %v", scanID, ok, failed, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// RunMetadataPhase reads the dimensions and EXIF date taken and camera of the scan's images, used to tell
+// similar copies apart (highest resolution, earliest date). Files already read at their current size and
+// mtime are skipped; files that cannot be read are recorded so they are not retried until they change.
+func RunMetadataPhase(ctx context.Context, database *sql.DB, scanID int64, opts *Options) error {
+	files, err := db.ListPendingMetadataFiles(ctx, database, scanID, MetadataExtensions)
+	if err != nil {
+		return err
+	}
+	log.Printf("[metadata] phase started for scan %d (%d worker(s), %d images)", scanID, opts.workers(), len(files))
+	start := time.Now()
+	ok, failed, err := runWorkers(ctx, files, opts, func(f db.File) (bool, error) {
+		info, rerr := ReadImageInfo(f.Path)
+		rec := db.ImageMetadata{Width: info.Width, Height: info.Height, TakenAt: info.TakenAt, Camera: info.Camera}
+		if rerr != nil {
+			rec = db.ImageMetadata{Err: rerr.Error()}
+		}
+		return rerr == nil, db.UpsertFileMetadata(ctx, database, f.ID, f.Size, f.MTime, rec)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[metadata] phase completed for scan %d: %d read, %d unreadable, in %v", scanID, ok, failed, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// runWorkers calls fn for each file on opts.workers() goroutines. fn reports whether the file was processed
+// successfully; a returned error (DB failure) is returned after in-flight work finishes.
+func runWorkers(ctx context.Context, files []db.File, opts *Options, fn func(db.File) (bool, error)) (ok, failed int, err error) {
+	var priority ioprio.Settings
+	if opts != nil {
+		priority = opts.Priority
+	}
+	jobs := make(chan db.File)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if perr := ioprio.ApplyToCurrentThread(priority); perr != nil {
+				log.Printf("[similar] could not lower worker priority: %v", perr)
+			}
+			for f := range jobs {
+				good, ferr := fn(f)
+				mu.Lock()
+				if good {
+					ok++
+				} else {
+					failed++
+				}
+				if ferr != nil && err == nil {
+					err = ferr
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return ok, failed, err
+}
@@ -0,0 +1,122 @@
+// Package textdiff compares small text files line by line, to show why two files of the same size are not
+// identical.
+package textdiff
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxCells caps the work of one diff: lines of a times lines of b, after their common start and end are
+// set aside.
+const MaxCells = 4_000_000
+
+// ErrTooLarge is returned when the files differ in too many lines to diff.
+var ErrTooLarge = errors.New("files differ in too many lines to compare")
+
+// Op says whether a line is in both files, only the first, or only the second.
+type Op byte
+
+const (
+	Same    Op = ' '
+	Removed Op = '-' // only in a
+	Added   Op = '+' // only in b
+)
+
+// Line is one line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// IsText reports whether b looks like text: valid UTF-8 without NUL bytes. A multi-byte character cut at the
+// end of b (a truncated read) still counts.
+func IsText(b []byte) bool {
+	if bytes.IndexByte(b, 0) >= 0 {
+		return false
+	}
+	for i := 0; i < utf8.UTFMax && len(b) > 0 && !utf8.Valid(b); i++ {
+		b = b[:len(b)-1]
+	}
+	return utf8.Valid(b)
+}
+
+// Diff returns the lines of a and b in order, each marked Same, Removed or Added, keeping as many lines in
+// common as possible (longest common subsequence).
+func Diff(a, b string) ([]Line, error) {
+	la, lb := splitLines(a), splitLines(b)
+	pre := 0
+	for pre < len(la) && pre < len(lb) && la[pre] == lb[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(la)-pre && suf < len(lb)-pre && la[len(la)-1-suf] == lb[len(lb)-1-suf] {
+		suf++
+	}
+	ma, mb := la[pre:len(la)-suf], lb[pre:len(lb)-suf]
+	if (len(ma)+1)*(len(mb)+1) > MaxCells {
+		return nil, ErrTooLarge
+	}
+	out := make([]Line, 0, len(la)+len(lb)-pre-suf)
+	for _, l := range la[:pre] {
+		out = append(out, Line{Same, l})
+	}
+	out = append(out, lcs(ma, mb)...)
+	for _, l := range la[len(la)-suf:] {
+		out = append(out, Line{Same, l})
+	}
+	return out, nil
+}
+
+// lcs diffs a and b with the classic dynamic program: n[i][j] is the longest common subsequence of a[i:]
+// and b[j:].
+func lcs(a, b []string) []Line {
+	w := len(b) + 1
+	n := make([]int32, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				n[i*w+j] = n[(i+1)*w+j+1] + 1
+			} else {
+				n[i*w+j] = max(n[(i+1)*w+j], n[i*w+j+1])
+			}
+		}
+	}
+	var out []Line
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{Same, a[i]})
+			i, j = i+1, j+1
+		case n[(i+1)*w+j] >= n[i*w+j+1]:
+			out = append(out, Line{Removed, a[i]})
+			i++
+		default:
+			out = append(out, Line{Added, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, Line{Removed, a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, Line{Added, b[j]})
+	}
+	return out
+}
+
+// splitLines splits s after each newline; a final line without one is kept. "\r\n" stays part of the line,
+// so files that only differ in line endings show as different.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,57 @@
+package textdiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteByte(byte(l.Op))
+		b.WriteString(l.Text)
+	}
+	return b.String()
+}
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name, a, b, want string
+	}{
+		{"identical", "x\ny\n", "x\ny\n", " x\n y\n"},
+		{"changed line", "a\nb\nc\n", "a\nB\nc\n", " a\n-b\n+B\n c\n"},
+		{"insert and delete", "a\nb\nc\n", "b\nc\nd\n", "-a\n b\n c\n+d\n"},
+		{"line endings", "a\r\nb\n", "a\nb\n", "-a\r\n+a\n b\n"},
+		{"no final newline", "a\nb", "a\nc", " a\n-b+c"},
+		{"empty", "", "a\n", "+a\n"},
+	} {
+		got, err := Diff(tc.a, tc.b)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if r := render(got); r != tc.want {
+			t.Errorf("%s: Diff = %q, want %q", tc.name, r, tc.want)
+		}
+	}
+}
+
+func TestDiff_tooLarge(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		a.WriteString("a\n")
+		b.WriteString("b\n")
+	}
+	if _, err := Diff(a.String(), b.String()); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Diff of 3000 different lines: err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestIsText(t *testing.T) {
+	if !IsText([]byte("héllo\n")) || !IsText([]byte("h\xc3")) {
+		t.Errorf("IsText rejected UTF-8 text (or text cut inside a character)")
+	}
+	if IsText([]byte("a\x00b")) || IsText([]byte{0xff, 0xfe, 'a', 'b', 'c', 'd', 'e'}) {
+		t.Errorf("IsText accepted binary data")
+	}
+}
@@ -0,0 +1,127 @@
+// Package thumb makes small JPEG previews of images for the web UI and caches them on disk by content hash,
+// so every copy in a duplicate group shares one thumbnail.
+package thumb
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/eargollo/ditto/internal/archive"
+	"github.com/eargollo/ditto/internal/limits"
+)
+
+// DefaultSize is the longest side of a thumbnail in pixels.
+const DefaultSize = 256
+
+const quality = 80
+
+// Make decodes the image at path and returns it as a JPEG that fits in size x size. Smaller images keep
+// their size.
+func Make(path string, size int) ([]byte, error) {
+	release, err := limits.AcquireFiles(context.Background(), 1)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	f, err := archive.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, Scale(img, size), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Scale box-averages img down to fit in size x size, keeping its aspect ratio. Each target pixel averages
+// its source rectangle; an image that already fits is returned as it is.
+func Scale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+	out := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, (ty+1)*h/th
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, (tx+1)*w/tw
+			var r, g, bl, a uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			out.SetRGBA(tx, ty, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: uint8(a / n >> 8)}) // #nosec G115 -- averages of 16-bit channels fit in 8 bits after >> 8
+		}
+	}
+	return out
+}
+
+// Cache keeps thumbnails in a directory, one file per content hash. Concurrent requests for the same
+// thumbnail decode the image once.
+type Cache struct {
+	dir    string
+	size   int
+	flight singleflight.Group
+}
+
+// NewCache returns a cache of size-pixel thumbnails in dir. With an empty dir nothing is stored and every
+// thumbnail is made anew.
+func NewCache(dir string, size int) *Cache {
+	return &Cache{dir: dir, size: size}
+}
+
+var cacheKey = regexp.MustCompile(`^[0-9a-f]{16,128}$`)
+
+// Get returns the thumbnail of the image at path, whose content hash is key. Only hex keys are cached
+// (an unhashed file passes ""). A failed write to the cache is ignored: the thumbnail is still returned.
+func (c *Cache) Get(key, path string) ([]byte, error) {
+	if c.dir == "" || !cacheKey.MatchString(key) {
+		return Make(path, c.size)
+	}
+	file := filepath.Join(c.dir, key[:2], key+".jpg")
+	if b, err := os.ReadFile(file); err == nil { // #nosec G304 -- name built from a hex key
+		return b, nil
+	}
+	v, err, _ := c.flight.Do(key, func() (any, error) {
+		b, err := Make(path, c.size)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0o750); err == nil {
+			tmp := file + ".tmp"
+			if os.WriteFile(tmp, b, 0o600) == nil {
+				_ = os.Rename(tmp, file)
+			}
+		}
+		return b, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
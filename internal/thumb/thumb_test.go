@@ -0,0 +1,64 @@
+package thumb
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScale_fitsAndKeepsAspect(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 800; x++ {
+			img.Set(x, y, color.RGBA{200, 100, 50, 255})
+		}
+	}
+	out := Scale(img, 100)
+	if b := out.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("Scale(800x400, 100) = %dx%d, want 100x50", b.Dx(), b.Dy())
+	}
+	if r, g, bl, _ := out.At(10, 10).RGBA(); r>>8 != 200 || g>>8 != 100 || bl>>8 != 50 {
+		t.Errorf("Scale changed a flat color: %d,%d,%d", r>>8, g>>8, bl>>8)
+	}
+	if small := image.NewRGBA(image.Rect(0, 0, 40, 30)); Scale(small, 100) != image.Image(small) {
+		t.Errorf("Scale enlarged or copied an image that already fits")
+	}
+}
+
+func TestCache_storesByHashAndServesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 600, 300))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(filepath.Join(dir, "cache"), 64)
+	key := "0123456789abcdef0123456789abcdef"
+
+	b, err := c.Get(key, path)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(b))
+	if err != nil || img.Bounds().Dx() != 64 || img.Bounds().Dy() != 32 {
+		t.Fatalf("thumbnail = %v, %v; want a 64x32 JPEG", img.Bounds(), err)
+	}
+	// Served from the cache once the source is gone.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := c.Get(key, path); err != nil || !bytes.Equal(again, b) {
+		t.Errorf("second Get = %d bytes, %v; want the cached thumbnail", len(again), err)
+	}
+	if _, err := c.Get("../../etc", path); err == nil {
+		t.Errorf("Get with a non-hex key read the missing source or the cache")
+	}
+}
@@ -0,0 +1,158 @@
+// Package volume identifies the filesystem holding a path by its UUID, so scans of removable drives can be
+// grouped per physical disk no matter where (or under which path) the disk was mounted.
+package volume
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknown is returned when the filesystem of a path has no UUID that can be determined (e.g. network
+// shares, overlay or tmpfs mounts, containers without /dev/disk, or platforms other than Linux).
+var ErrUnknown = errors.New("filesystem UUID unknown")
+
+// Info describes the filesystem that holds a path.
+type Info struct {
+	UUID       string // filesystem UUID (from /dev/disk/by-uuid)
+	Label      string // filesystem label, if any
+	FSType     string // e.g. ext4, exfat, vfat
+	MountPoint string // where the filesystem is mounted
+}
+
+// Space is the capacity of the filesystem holding a path, in bytes.
+type Space struct {
+	Total int64
+	Free  int64 // free blocks, including those reserved for root
+	Avail int64 // free blocks available to unprivileged users
+}
+
+// Used returns the bytes in use.
+func (s *Space) Used() int64 { return s.Total - s.Free }
+
+// networkFSTypes are the mountinfo type names of filesystems served over the network.
+var networkFSTypes = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true, "afs": true, "9p": true,
+	"ceph": true, "glusterfs": true, "lustre": true, "davfs": true,
+	"fuse.sshfs": true, "fuse.rclone": true, "fuse.davfs": true, "fuse.glusterfs": true, "fuse.smbnetfs": true,
+}
+
+// IsNetworkFS reports whether fsType (as in Info.FSType) is a network filesystem such as NFS or SMB.
+func IsNetworkFS(fsType string) bool { return networkFSTypes[fsType] }
+
+// networkKey returns the stable key of a network share mounted at m (see DeviceKey), "" for any other mount.
+func networkKey(m *mountEntry) string {
+	if m == nil || !IsNetworkFS(m.FSType) || m.Source == "" {
+		return ""
+	}
+	return m.FSType + ":" + m.Source
+}
+
+// mountEntry is one line of /proc/self/mountinfo.
+type mountEntry struct {
+	Major, Minor uint32
+	MountPoint   string
+	FSType       string
+	Source       string
+}
+
+// parseMountInfo parses the proc(5) mountinfo format:
+// "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue".
+func parseMountInfo(r io.Reader) ([]mountEntry, error) {
+	var out []mountEntry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || len(fields) < sep+3 {
+			continue
+		}
+		majStr, minStr, ok := strings.Cut(fields[2], ":")
+		if !ok {
+			continue
+		}
+		maj, err1 := strconv.ParseUint(majStr, 10, 32)
+		min, err2 := strconv.ParseUint(minStr, 10, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		out = append(out, mountEntry{
+			Major:      uint32(maj),
+			Minor:      uint32(min),
+			MountPoint: unescapeOctal(fields[4]),
+			FSType:     fields[sep+1],
+			Source:     unescapeOctal(fields[sep+2]),
+		})
+	}
+	return out, sc.Err()
+}
+
+// unescapeOctal decodes the \040-style escapes mountinfo uses for spaces, tabs, newlines and backslashes.
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescapeUdev decodes the \x20-style escapes udev uses in /dev/disk/by-label names.
+func unescapeUdev(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) && s[i+1] == 'x' {
+			if n, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// mountFor returns the entry for device maj:min whose mount point is the longest prefix of path
+// (the same device can be mounted, or bind-mounted, in several places).
+func mountFor(entries []mountEntry, maj, min uint32, path string) *mountEntry {
+	var best *mountEntry
+	for i := range entries {
+		e := &entries[i]
+		if e.Major != maj || e.Minor != min || !under(path, e.MountPoint) {
+			continue
+		}
+		if best == nil || len(e.MountPoint) > len(best.MountPoint) {
+			best = e
+		}
+	}
+	return best
+}
+
+// under reports whether path is mountPoint or inside it.
+func under(path, mountPoint string) bool {
+	if mountPoint == "/" || path == mountPoint {
+		return true
+	}
+	return strings.HasPrefix(path, mountPoint+"/")
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	mountInfoPath = "/proc/self/mountinfo"
+	byUUIDDir     = "/dev/disk/by-uuid"
+	byLabelDir    = "/dev/disk/by-label"
+)
+
+// Identify returns the filesystem holding path. The UUID comes from the udev symlink in /dev/disk/by-uuid
+// whose block device matches the path's device; ErrUnknown is returned when there is none.
+func Identify(path string) (*Info, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	dev := uint64(st.Dev) // #nosec G115 -- Dev is unsigned on all Linux architectures
+	info := &Info{}
+	if f, err := os.Open(mountInfoPath); err == nil {
+		entries, _ := parseMountInfo(f)
+		_ = f.Close()
+		if m := mountFor(entries, unix.Major(dev), unix.Minor(dev), path); m != nil {
+			info.MountPoint, info.FSType = m.MountPoint, m.FSType
+		}
+	}
+	info.UUID = deviceLink(byUUIDDir, dev)
+	if info.UUID == "" {
+		return nil, fmt.Errorf("%s: %w", path, ErrUnknown)
+	}
+	info.Label = unescapeUdev(deviceLink(byLabelDir, dev))
+	return info, nil
+}
+
+// FSType returns the type of the filesystem holding path (e.g. ext4, nfs4, cifs) from /proc/self/mountinfo.
+// Unlike Identify it needs no UUID, so it works for network shares. Returns ErrUnknown when no mount matches.
+func FSType(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return "", &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	dev := uint64(st.Dev) // #nosec G115 -- see Identify
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return "", err
+	}
+	entries, err := parseMountInfo(f)
+	_ = f.Close()
+	if err != nil {
+		return "", err
+	}
+	if m := mountFor(entries, unix.Major(dev), unix.Minor(dev), path); m != nil {
+		return m.FSType, nil
+	}
+	return "", fmt.Errorf("%s: %w", path, ErrUnknown)
+}
+
+// DeviceKey returns a name for the filesystem of device dev, which holds path, that stays the same when the
+// filesystem is mounted again under another device number: its UUID, or for a network share its type and
+// source ("nfs4:nas:/volume1"). Returns "" when the filesystem has neither (tmpfs, overlay, containers without
+// /dev/disk).
+func DeviceKey(path string, dev uint64) string {
+	if uuid := deviceLink(byUUIDDir, dev); uuid != "" {
+		return uuid
+	}
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return ""
+	}
+	entries, _ := parseMountInfo(f)
+	_ = f.Close()
+	return networkKey(mountFor(entries, unix.Major(dev), unix.Minor(dev), path))
+}
+
+// deviceLink returns the name of the symlink in dir (udev's by-uuid or by-label) that points to block device dev.
+func deviceLink(dir string, dev uint64) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		var st unix.Stat_t
+		if unix.Stat(filepath.Join(dir, e.Name()), &st) != nil {
+			continue
+		}
+		if st.Mode&unix.S_IFMT == unix.S_IFBLK && uint64(st.Rdev) == dev { // #nosec G115 -- see Identify
+			return e.Name()
+		}
+	}
+	return ""
+}
+
+// DiskSpace returns the capacity of the filesystem holding path (statfs).
+func DiskSpace(path string) (*Space, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	bs := int64(st.Frsize)
+	if bs <= 0 {
+		bs = int64(st.Bsize)
+	}
+	return &Space{
+		Total: int64(st.Blocks) * bs, // #nosec G115 -- block counts of real filesystems fit in int64
+		Free:  int64(st.Bfree) * bs,  // #nosec G115
+		Avail: int64(st.Bavail) * bs, // #nosec G115
+	}, nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package volume
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FSType is only implemented on Linux; elsewhere every path reports ErrUnknown.
+func FSType(path string) (string, error) {
+	return "", fmt.Errorf("%s: %w", path, ErrUnknown)
+}
+
+// DeviceKey is only implemented on Linux; elsewhere no filesystem has a stable key.
+func DeviceKey(path string, dev uint64) string {
+	return ""
+}
+
+// Identify is only implemented on Linux; elsewhere every path reports ErrUnknown.
+func Identify(path string) (*Info, error) {
+	return nil, fmt.Errorf("%s: %w", path, ErrUnknown)
+}
+
+// DiskSpace is only implemented on Linux.
+func DiskSpace(path string) (*Space, error) {
+	return nil, fmt.Errorf("%s: %w", path, errors.ErrUnsupported)
+}
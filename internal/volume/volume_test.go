@@ -0,0 +1,119 @@
+package volume
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const sampleMountInfo = `22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+35 22 8:17 / /media/usb\040disk rw,nosuid shared:20 - exfat /dev/sdb1 rw,uid=1000
+36 22 8:17 /photos /srv/photos rw shared:20 - exfat /dev/sdb1 rw
+40 22 0:45 / /mnt/share rw - nfs4 nas:/export rw
+bogus line
+`
+
+func TestParseMountInfo_fieldsAndEscapes(t *testing.T) {
+	entries, err := parseMountInfo(strings.NewReader(sampleMountInfo))
+	if err != nil {
+		t.Fatalf("parseMountInfo: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4: %+v", len(entries), entries)
+	}
+	want := mountEntry{Major: 8, Minor: 17, MountPoint: "/media/usb disk", FSType: "exfat", Source: "/dev/sdb1"}
+	if entries[1] != want {
+		t.Errorf("entries[1] = %+v, want %+v", entries[1], want)
+	}
+}
+
+func TestMountFor_longestMatchingMountPoint(t *testing.T) {
+	entries, _ := parseMountInfo(strings.NewReader(sampleMountInfo))
+	if m := mountFor(entries, 8, 17, "/media/usb disk/DCIM"); m == nil || m.MountPoint != "/media/usb disk" {
+		t.Errorf("mountFor(usb) = %+v, want /media/usb disk", m)
+	}
+	if m := mountFor(entries, 8, 17, "/srv/photos/2024"); m == nil || m.MountPoint != "/srv/photos" {
+		t.Errorf("mountFor(bind mount) = %+v, want /srv/photos", m)
+	}
+	if m := mountFor(entries, 259, 2, "/home/me"); m == nil || m.MountPoint != "/" {
+		t.Errorf("mountFor(root) = %+v, want /", m)
+	}
+	if m := mountFor(entries, 8, 17, "/srv/photosx"); m != nil {
+		t.Errorf("mountFor(sibling prefix) = %+v, want nil", m)
+	}
+}
+
+func TestUnescapeUdev(t *testing.T) {
+	for in, want := range map[string]string{"BACKUP": "BACKUP", `My\x20Passport`: "My Passport", `bad\x2`: `bad\x2`} {
+		if got := unescapeUdev(in); got != want {
+			t.Errorf("unescapeUdev(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIdentify_tempDirHasNoUUIDOrSucceeds(t *testing.T) {
+	// Depends on the host: tmpfs/overlay have no UUID, a disk-backed /tmp does. Either answer is valid.
+	info, err := Identify(t.TempDir())
+	if err != nil && !errors.Is(err, ErrUnknown) {
+		t.Fatalf("Identify: %v", err)
+	}
+	if err == nil && info.UUID == "" {
+		t.Error("Identify returned no error but an empty UUID")
+	}
+}
+
+func TestIsNetworkFS(t *testing.T) {
+	for fsType, want := range map[string]bool{"nfs4": true, "cifs": true, "fuse.sshfs": true, "ext4": false, "btrfs": false, "": false} {
+		if got := IsNetworkFS(fsType); got != want {
+			t.Errorf("IsNetworkFS(%q) = %v, want %v", fsType, got, want)
+		}
+	}
+}
+
+func TestNetworkKey_onlyNetworkShares(t *testing.T) {
+	for _, tc := range []struct {
+		m    *mountEntry
+		want string
+	}{
+		{&mountEntry{FSType: "nfs4", Source: "nas:/volume1"}, "nfs4:nas:/volume1"},
+		{&mountEntry{FSType: "cifs", Source: "//nas/photos"}, "cifs://nas/photos"},
+		{&mountEntry{FSType: "ext4", Source: "/dev/sda1"}, ""},
+		{&mountEntry{FSType: "tmpfs", Source: "tmpfs"}, ""},
+		{nil, ""},
+	} {
+		if got := networkKey(tc.m); got != tc.want {
+			t.Errorf("networkKey(%+v) = %q, want %q", tc.m, got, tc.want)
+		}
+	}
+}
+
+func TestFSType_tempDir(t *testing.T) {
+	// Only Linux reads mountinfo; elsewhere (or in unusual sandboxes) ErrUnknown is the valid answer.
+	fsType, err := FSType(t.TempDir())
+	if err != nil && !errors.Is(err, ErrUnknown) {
+		t.Fatalf("FSType: %v", err)
+	}
+	if err == nil && fsType == "" {
+		t.Error("FSType returned no error but an empty type")
+	}
+}
+
+func TestDiskSpace_tempDir(t *testing.T) {
+	// Only Linux calls statfs; elsewhere ErrUnsupported is the valid answer.
+	sp, err := DiskSpace(t.TempDir())
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("DiskSpace: %v", err)
+	}
+	if sp.Total <= 0 || sp.Free < 0 || sp.Free > sp.Total || sp.Avail > sp.Free || sp.Used() < 0 {
+		t.Errorf("DiskSpace = %+v (used %d), want 0 <= avail <= free <= total", *sp, sp.Used())
+	}
+}
+
+func TestDiskSpace_missingPath(t *testing.T) {
+	if _, err := DiskSpace("/nonexistent/ditto-test"); err == nil {
+		t.Error("DiskSpace of a missing path returned no error")
+	}
+}